@@ -41,5 +41,19 @@ func main() {
 	fmt.Println("Running failure scenarios...")
 	examples.FailureScenarioTransaction()
 
+	fmt.Println("\nWaiting 3 seconds before running the 3PC comparison...")
+	time.Sleep(3 * time.Second)
+
+	// 运行2PC与3PC阻塞行为对比示例
+	fmt.Println("\n===== TWO-PHASE VS THREE-PHASE COMMIT EXAMPLE =====")
+	examples.ThreePhaseCommitComparison()
+
+	fmt.Println("\nWaiting 3 seconds before running the mode comparison...")
+	time.Sleep(3 * time.Second)
+
+	// 运行2PC、Saga与事务性发件箱的负载对比示例
+	fmt.Println("\n===== 2PC VS SAGA VS OUTBOX COMPARISON =====")
+	examples.ModeComparison()
+
 	fmt.Println("\nAll examples completed.")
 }
@@ -1,6 +1,7 @@
 package examples
 
 import (
+	"context"
 	"distribute-tx/internal/config"
 	"fmt"
 	"log"
@@ -130,7 +131,8 @@ func insufficientInventoryScenario(dbManager *db.DBConnectionManager) {
 	fmt.Printf("Starting transaction with insufficient inventory (trying to order %d items, only 1 available)\n", quantity)
 
 	// 开始事务
-	xid, err := txCoordinator.Begin("Create order with insufficient inventory")
+	ctx := context.Background()
+	xid, err := txCoordinator.Begin(ctx, "Create order with insufficient inventory")
 	if err != nil {
 		log.Fatalf("Failed to begin transaction: %v", err)
 	}
@@ -207,14 +209,14 @@ func insufficientInventoryScenario(dbManager *db.DBConnectionManager) {
 
 	// 执行准备阶段
 	fmt.Println("Executing prepare phase...")
-	prepared, err := txCoordinator.Prepare(xid, participantActions)
+	prepared, err := txCoordinator.Prepare(ctx, xid, participantActions)
 
 	// 预期准备阶段会失败，因为库存不足
 	if err != nil {
 		fmt.Printf("Prepare phase failed as expected: %v\n", err)
 		fmt.Println("Executing rollback...")
 
-		_, rollbackErr := txCoordinator.Rollback(xid)
+		_, rollbackErr := txCoordinator.Rollback(ctx, xid)
 		if rollbackErr != nil {
 			fmt.Printf("Rollback error: %v\n", rollbackErr)
 		} else {
@@ -257,7 +259,8 @@ func paymentFailureScenario(dbManager *db.DBConnectionManager) {
 	fmt.Printf("Starting transaction with insufficient account balance (order amount: %.2f, account balance: 50.00)\n", orderAmount)
 
 	// 开始事务
-	xid, _ := txCoordinator.Begin("Create order with payment failure")
+	ctx := context.Background()
+	xid, _ := txCoordinator.Begin(ctx, "Create order with payment failure")
 
 	// 定义参与者动作
 	participantActions := map[string]func(*gorm.DB) error{
@@ -313,13 +316,13 @@ func paymentFailureScenario(dbManager *db.DBConnectionManager) {
 
 	// 执行准备阶段
 	fmt.Println("Executing prepare phase...")
-	prepared, err := txCoordinator.Prepare(xid, participantActions)
+	prepared, err := txCoordinator.Prepare(ctx, xid, participantActions)
 
 	if err != nil {
 		fmt.Printf("Prepare phase failed as expected: %v\n", err)
 		fmt.Println("Executing rollback...")
 
-		_, rollbackErr := txCoordinator.Rollback(xid)
+		_, rollbackErr := txCoordinator.Rollback(ctx, xid)
 		if rollbackErr != nil {
 			fmt.Printf("Rollback error: %v\n", rollbackErr)
 		} else {
@@ -343,7 +346,7 @@ func commitFailureScenario(dbManager *db.DBConnectionManager) {
 	inventoryService := participant.NewParticipant("inventory_service", "inventory_service", dbManager)
 
 	// 模拟提交时会失败的参与者
-	// 这里我们使用正常参与者，但在提交阶段前修改其LocalTx为nil来模拟失败
+	// 这里我们使用正常参与者，但在提交阶段前回滚它已准备的XA分支来模拟失败
 	paymentService := participant.NewParticipant("payment_service", "payment_service", dbManager)
 
 	txCoordinator.RegisterParticipant(orderService)
@@ -360,7 +363,8 @@ func commitFailureScenario(dbManager *db.DBConnectionManager) {
 	fmt.Println("Starting transaction with commit phase failure simulation")
 
 	// 开始事务
-	xid, _ := txCoordinator.Begin("Create order with commit failure")
+	ctx := context.Background()
+	xid, _ := txCoordinator.Begin(ctx, "Create order with commit failure")
 
 	// 定义参与者动作
 	participantActions := map[string]func(*gorm.DB) error{
@@ -414,7 +418,7 @@ func commitFailureScenario(dbManager *db.DBConnectionManager) {
 
 	// 执行准备阶段
 	fmt.Println("Executing prepare phase...")
-	prepared, err := txCoordinator.Prepare(xid, participantActions)
+	prepared, err := txCoordinator.Prepare(ctx, xid, participantActions)
 
 	if err != nil {
 		fmt.Printf("Prepare phase failed: %v\n", err)
@@ -425,13 +429,13 @@ func commitFailureScenario(dbManager *db.DBConnectionManager) {
 		fmt.Println("Prepare phase completed successfully")
 
 		// 模拟支付服务在提交阶段失败（例如崩溃）
-		// 通过清除LocalTx引用来模拟
+		// 通过回滚支付服务已准备的XA分支来模拟（如资源数据库的管理员手动执行了 XA ROLLBACK）
 		fmt.Println("Simulating payment service failure before commit...")
-		paymentService.LocalTx = nil
+		paymentService.Rollback(ctx, "coordinator", xid)
 
 		// 尝试提交
 		fmt.Println("Executing commit phase...")
-		committed, err := txCoordinator.Commit(xid)
+		committed, err := txCoordinator.Commit(ctx, xid)
 
 		if err != nil {
 			fmt.Printf("Commit failed as expected: %v\n", err)
@@ -0,0 +1,626 @@
+package examples
+
+import (
+	"context"
+	"distribute-tx/internal/config"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+
+	"distribute-tx/internal/coordinator"
+	"distribute-tx/internal/db"
+	"distribute-tx/internal/model"
+	"distribute-tx/internal/participant"
+)
+
+// 模式对比示例的负载参数
+const (
+	comparisonOrders    = 40            // 每种模式执行的订单数
+	comparisonWorkers   = 4             // 并发下单的工作协程数
+	comparisonFailEvery = 5             // 每N个订单注入一次支付服务故障（首次调用失败）
+	comparisonProductID = "product-cmp" // 所有订单争用同一行库存，便于观察锁持有时间
+	comparisonStock     = 1000          // 每种模式开始前重置的库存
+	comparisonQuantity  = 1             // 每个订单的购买数量
+	comparisonUnitPrice = 50.0          // 商品单价
+	outboxPollInterval  = 20 * time.Millisecond
+	outboxDrainTimeout  = 30 * time.Second
+)
+
+// errInjectedPaymentFailure 注入的支付服务故障
+var errInjectedPaymentFailure = errors.New("injected payment service failure")
+
+// outboxEvent 事务性发件箱中的一条待投递事件，与订单在同一个本地事务中写入订单库
+type outboxEvent struct {
+	ID          uint       `gorm:"primaryKey"`
+	OrderNo     string     `gorm:"column:order_no;type:varchar(64);index"` // 订单号
+	Target      string     `gorm:"column:target;type:varchar(64)"`         // 目标服务
+	Attempts    int        `gorm:"column:attempts"`                        // 已尝试投递次数
+	Delivered   bool       `gorm:"column:delivered;index"`                 // 是否已投递成功
+	CreatedAt   time.Time  // 写入时间（即订单提交时间）
+	DeliveredAt *time.Time `gorm:"column:delivered_at"` // 投递成功时间
+}
+
+// TableName 定义发件箱表名
+func (outboxEvent) TableName() string {
+	return "comparison_outbox"
+}
+
+// modeResult 单个模式的测量结果
+type modeResult struct {
+	Mode         string
+	Orders       int
+	Committed    int             // 最终成功的订单数
+	Aborted      int             // 回滚或补偿掉的订单数
+	Elapsed      time.Duration   // 下单阶段总耗时
+	Latencies    []time.Duration // 调用方等待下单结果的时间
+	LockHolds    []time.Duration // 库存行锁持有时间
+	Convergence  []time.Duration // 下单到所有服务生效的时间（仅发件箱模式）
+	Compensated  int             // 已提交的中间状态被补偿撤销的订单数（对其他事务可见的脏数据）
+	Retries      int             // 注入故障后的重试投递次数
+	Inconsistent int             // 结束后对账不一致的订单数
+	mu           sync.Mutex      // 保护并发记录
+}
+
+// record 记录一个订单的结果
+func (r *modeResult) record(latency time.Duration, hold time.Duration, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.Latencies = append(r.Latencies, latency)
+	if hold > 0 {
+		r.LockHolds = append(r.LockHolds, hold)
+	}
+	if err != nil {
+		r.Aborted++
+	} else {
+		r.Committed++
+	}
+}
+
+// ModeComparison 在同一个订单负载下对比2PC、Saga和事务性发件箱三种模式
+// 每种模式并发执行相同数量的订单（创建订单、扣减库存、创建支付记录），并按固定比例注入支付服务故障，
+// 测量延迟、吞吐、库存行锁持有时间以及异常数量，最后打印对比表
+// Saga和发件箱在本示例中以最简形式实现，只用于说明各模式的取舍，协调者本身只实现了2PC/3PC
+func ModeComparison() {
+	dbManager := db.NewDBConnectionManager()
+	defer dbManager.Close()
+
+	dbConfig := config.DefaultDBConfig
+	for _, service := range []string{"coordinator", "order_service", "inventory_service", "payment_service"} {
+		if err := dbManager.ConnectDB(service, dbConfig); err != nil {
+			log.Fatalf("Failed to connect to %s database: %v", service, err)
+		}
+		// 关闭SQL日志，避免大量输出影响延迟测量
+		dbManager.DBs[service].Logger = logger.Default.LogMode(logger.Silent)
+	}
+	if err := dbManager.InitTransactionTables("coordinator"); err != nil {
+		log.Fatalf("Failed to initialize transaction tables: %v", err)
+	}
+	if err := dbManager.InitBusinessTables(); err != nil {
+		log.Fatalf("Failed to initialize business tables: %v", err)
+	}
+	orderDB, _ := dbManager.GetDB("order_service")
+	if err := orderDB.AutoMigrate(&outboxEvent{}); err != nil {
+		log.Fatalf("Failed to initialize outbox table: %v", err)
+	}
+	dbManager.StartPoolMonitor(time.Second)
+
+	runID := uuid.New().String()[0:8]
+	modes := []struct {
+		name string
+		run  func(*db.DBConnectionManager, string) *modeResult
+	}{
+		{"2PC", runTwoPhaseMode},
+		{"Saga", runSagaMode},
+		{"Outbox", runOutboxMode},
+	}
+
+	results := make([]*modeResult, 0, len(modes))
+	for _, mode := range modes {
+		fmt.Printf("\nRunning %d orders under %s with %d workers...\n", comparisonOrders, mode.name, comparisonWorkers)
+		resetComparisonInventory(dbManager)
+
+		prefix := fmt.Sprintf("CMP-%s-%s-", mode.name, runID)
+		result := mode.run(dbManager, prefix)
+		result.Inconsistent = reconcileOrders(dbManager, prefix)
+		results = append(results, result)
+	}
+
+	printComparison(results)
+	printPoolStats(dbManager)
+}
+
+// runWorkload 以固定数量的工作协程执行订单，每个工作协程通过newWorker创建自己的下单函数
+// 下单函数返回库存行锁持有时间及错误
+func runWorkload(result *modeResult, newWorker func() func(i int) (time.Duration, error)) {
+	orders := make(chan int)
+	var wg sync.WaitGroup
+
+	start := time.Now()
+	for w := 0; w < comparisonWorkers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			place := newWorker()
+			for i := range orders {
+				begin := time.Now()
+				hold, err := place(i)
+				result.record(time.Since(begin), hold, err)
+			}
+		}()
+	}
+	for i := 0; i < comparisonOrders; i++ {
+		orders <- i
+	}
+	close(orders)
+	wg.Wait()
+
+	result.Orders = comparisonOrders
+	result.Elapsed = time.Since(start)
+}
+
+// injectFailure 判断第i个订单的支付服务在第attempt次调用时是否注入故障
+func injectFailure(i int, attempt int) bool {
+	return i%comparisonFailEvery == comparisonFailEvery-1 && attempt == 1
+}
+
+// runTwoPhaseMode 2PC：三个服务在同一个全局事务中准备和提交，任一服务失败则整体回滚
+// 库存行锁从准备阶段扣减库存开始，一直持有到提交/回滚完成
+func runTwoPhaseMode(dbManager *db.DBConnectionManager, prefix string) *modeResult {
+	result := &modeResult{Mode: "2PC"}
+
+	runWorkload(result, func() func(int) (time.Duration, error) {
+		// 参与者同一时间只能处于一个全局事务中，每个工作协程使用独立的协调者和参与者
+		c := coordinator.NewCoordinator("coordinator", dbManager, 30*time.Second)
+		for _, service := range []string{"order_service", "inventory_service", "payment_service"} {
+			c.RegisterParticipant(participant.NewParticipant(service, service, dbManager))
+		}
+
+		return func(i int) (time.Duration, error) {
+			ctx := context.Background()
+			orderNo := fmt.Sprintf("%s%d", prefix, i)
+
+			xid, err := c.Begin(ctx, "mode comparison 2PC order "+orderNo)
+			if err != nil {
+				return 0, err
+			}
+
+			// Prepare等待所有参与者完成后才返回，读取lockedAt不需要额外同步
+			var lockedAt time.Time
+			actions := map[string]func(*gorm.DB) error{
+				"order_service": func(tx *gorm.DB) error {
+					return createComparisonOrder(tx, orderNo, "completed")
+				},
+				"inventory_service": func(tx *gorm.DB) error {
+					if err := reserveComparisonStock(tx); err != nil {
+						return err
+					}
+					lockedAt = time.Now()
+					return nil
+				},
+				"payment_service": func(tx *gorm.DB) error {
+					if injectFailure(i, 1) {
+						return errInjectedPaymentFailure
+					}
+					return createComparisonPayment(tx, orderNo)
+				},
+			}
+
+			holdSince := func() time.Duration {
+				if lockedAt.IsZero() {
+					return 0
+				}
+				return time.Since(lockedAt)
+			}
+
+			if _, err := c.Prepare(ctx, xid, actions); err != nil {
+				c.Rollback(ctx, xid)
+				return holdSince(), err
+			}
+			if _, err := c.Commit(ctx, xid); err != nil {
+				return holdSince(), err
+			}
+			return holdSince(), nil
+		}
+	})
+
+	return result
+}
+
+// runSagaMode Saga：每一步在本地事务中立即提交，后续步骤失败时按相反顺序执行已完成步骤的补偿
+// 库存行锁只在扣减库存的本地事务中持有；补偿前扣减结果已经对其他事务可见
+func runSagaMode(dbManager *db.DBConnectionManager, prefix string) *modeResult {
+	result := &modeResult{Mode: "Saga"}
+	orderDB, _ := dbManager.GetDB("order_service")
+	inventoryDB, _ := dbManager.GetDB("inventory_service")
+	paymentDB, _ := dbManager.GetDB("payment_service")
+
+	runWorkload(result, func() func(int) (time.Duration, error) {
+		// Saga不经过两阶段协议，协调者只负责补偿的重试与人工处理队列
+		// Compensate按注册顺序执行补偿，因此按步骤的相反顺序注册参与者
+		c := coordinator.NewCoordinator("coordinator", dbManager, 30*time.Second)
+		for _, service := range []string{"payment_service", "inventory_service", "order_service"} {
+			c.RegisterParticipant(participant.NewParticipant(service, service, dbManager))
+		}
+
+		return func(i int) (time.Duration, error) {
+			ctx := context.Background()
+			orderNo := fmt.Sprintf("%s%d", prefix, i)
+			sagaID := uuid.New().String()
+			compensations := make(map[string]func(context.Context) error)
+
+			fail := func(err error) error {
+				if _, compErr := c.Compensate(ctx, sagaID, compensations); compErr != nil {
+					return errors.Join(err, compErr)
+				}
+				result.mu.Lock()
+				result.Compensated++
+				result.mu.Unlock()
+				return err
+			}
+
+			// 步骤1: 创建待处理订单
+			if err := orderDB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+				return createComparisonOrder(tx, orderNo, "pending")
+			}); err != nil {
+				return 0, err
+			}
+			compensations["order_service"] = func(ctx context.Context) error {
+				return orderDB.WithContext(ctx).Model(&model.Order{}).
+					Where("order_no = ?", orderNo).Update("status", "cancelled").Error
+			}
+
+			// 步骤2: 扣减库存，行锁只持有到本地事务提交
+			lockedAt := time.Now()
+			err := inventoryDB.WithContext(ctx).Transaction(reserveComparisonStock)
+			hold := time.Since(lockedAt)
+			if err != nil {
+				return hold, fail(err)
+			}
+			compensations["inventory_service"] = func(ctx context.Context) error {
+				return releaseComparisonStock(inventoryDB.WithContext(ctx))
+			}
+
+			// 步骤3: 创建支付记录
+			if injectFailure(i, 1) {
+				return hold, fail(errInjectedPaymentFailure)
+			}
+			if err := paymentDB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+				return createComparisonPayment(tx, orderNo)
+			}); err != nil {
+				return hold, fail(err)
+			}
+
+			// 所有步骤完成，订单进入最终状态
+			err = orderDB.WithContext(ctx).Model(&model.Order{}).
+				Where("order_no = ?", orderNo).Update("status", "completed").Error
+			return hold, err
+		}
+	})
+
+	return result
+}
+
+// runOutboxMode 事务性发件箱：订单与待投递事件在订单库的同一个本地事务中写入，
+// 由后台投递协程异步扣减库存、创建支付记录，失败的投递在下一轮重试，直到所有服务生效
+// 调用方只等待订单库的本地事务；注入的故障变为投递重试，订单最终一致
+func runOutboxMode(dbManager *db.DBConnectionManager, prefix string) *modeResult {
+	result := &modeResult{Mode: "Outbox"}
+	orderDB, _ := dbManager.GetDB("order_service")
+
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		relayOutbox(dbManager, prefix, result, stop)
+	}()
+
+	runWorkload(result, func() func(int) (time.Duration, error) {
+		return func(i int) (time.Duration, error) {
+			orderNo := fmt.Sprintf("%s%d", prefix, i)
+			return 0, orderDB.Transaction(func(tx *gorm.DB) error {
+				if err := createComparisonOrder(tx, orderNo, "pending"); err != nil {
+					return err
+				}
+				events := []outboxEvent{
+					{OrderNo: orderNo, Target: "inventory_service"},
+					{OrderNo: orderNo, Target: "payment_service"},
+				}
+				return tx.Create(&events).Error
+			})
+		}
+	})
+
+	// 等待投递协程把所有事件投递完毕
+	deadline := time.Now().Add(outboxDrainTimeout)
+	for time.Now().Before(deadline) {
+		var pending int64
+		orderDB.Model(&outboxEvent{}).Where("order_no LIKE ? AND delivered = ?", prefix+"%", false).Count(&pending)
+		if pending == 0 {
+			break
+		}
+		time.Sleep(outboxPollInterval)
+	}
+	close(stop)
+	<-done
+
+	return result
+}
+
+// relayOutbox 轮询发件箱并投递事件，一个订单的全部事件投递成功后将订单标记为完成
+// 投递与标记不在同一个事务中（至少一次投递），单个投递协程时不会出现重复投递
+func relayOutbox(dbManager *db.DBConnectionManager, prefix string, result *modeResult, stop <-chan struct{}) {
+	orderDB, _ := dbManager.GetDB("order_service")
+	inventoryDB, _ := dbManager.GetDB("inventory_service")
+	paymentDB, _ := dbManager.GetDB("payment_service")
+
+	deliver := func(event outboxEvent) (time.Duration, error) {
+		switch event.Target {
+		case "inventory_service":
+			lockedAt := time.Now()
+			err := inventoryDB.Transaction(reserveComparisonStock)
+			return time.Since(lockedAt), err
+		case "payment_service":
+			var i int
+			fmt.Sscanf(event.OrderNo[len(prefix):], "%d", &i)
+			if injectFailure(i, event.Attempts+1) {
+				return 0, errInjectedPaymentFailure
+			}
+			return 0, paymentDB.Transaction(func(tx *gorm.DB) error {
+				return createComparisonPayment(tx, event.OrderNo)
+			})
+		}
+		return 0, fmt.Errorf("unknown outbox target %s", event.Target)
+	}
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-time.After(outboxPollInterval):
+		}
+
+		var events []outboxEvent
+		if err := orderDB.Where("order_no LIKE ? AND delivered = ?", prefix+"%", false).
+			Order("id").Limit(100).Find(&events).Error; err != nil {
+			log.Printf("Failed to poll outbox: %v", err)
+			continue
+		}
+
+		for _, event := range events {
+			hold, err := deliver(event)
+
+			result.mu.Lock()
+			if hold > 0 {
+				result.LockHolds = append(result.LockHolds, hold)
+			}
+			if event.Attempts > 0 {
+				result.Retries++
+			}
+			result.mu.Unlock()
+
+			if err != nil {
+				orderDB.Model(&outboxEvent{}).Where("id = ?", event.ID).Update("attempts", event.Attempts+1)
+				continue
+			}
+
+			now := time.Now()
+			orderDB.Model(&outboxEvent{}).Where("id = ?", event.ID).Updates(map[string]interface{}{
+				"attempts":     event.Attempts + 1,
+				"delivered":    true,
+				"delivered_at": now,
+			})
+			completeOutboxOrder(orderDB, event.OrderNo, result)
+		}
+	}
+}
+
+// completeOutboxOrder 订单的全部事件已投递时将订单标记为完成，并记录从下单到生效的时间
+func completeOutboxOrder(orderDB *gorm.DB, orderNo string, result *modeResult) {
+	var pending int64
+	orderDB.Model(&outboxEvent{}).Where("order_no = ? AND delivered = ?", orderNo, false).Count(&pending)
+	if pending > 0 {
+		return
+	}
+
+	var order model.Order
+	if err := orderDB.Where("order_no = ?", orderNo).First(&order).Error; err != nil {
+		return
+	}
+	orderDB.Model(&order).Update("status", "completed")
+
+	result.mu.Lock()
+	result.Convergence = append(result.Convergence, time.Since(order.CreatedAt))
+	result.mu.Unlock()
+}
+
+// createComparisonOrder 创建订单及订单项
+func createComparisonOrder(tx *gorm.DB, orderNo string, status string) error {
+	order := model.Order{
+		OrderNo:     orderNo,
+		UserID:      "user-cmp",
+		TotalAmount: comparisonUnitPrice * comparisonQuantity,
+		Status:      status,
+	}
+	if err := tx.Create(&order).Error; err != nil {
+		return fmt.Errorf("failed to create order: %w", err)
+	}
+	item := model.OrderItem{
+		OrderNo:   orderNo,
+		ProductID: comparisonProductID,
+		Quantity:  comparisonQuantity,
+		UnitPrice: comparisonUnitPrice,
+	}
+	if err := tx.Create(&item).Error; err != nil {
+		return fmt.Errorf("failed to create order item: %w", err)
+	}
+	return nil
+}
+
+// reserveComparisonStock 扣减库存，更新语句会持有库存行锁直到所在事务结束
+func reserveComparisonStock(tx *gorm.DB) error {
+	result := tx.Model(&model.Inventory{}).
+		Where("product_id = ? AND quantity >= ?", comparisonProductID, comparisonQuantity).
+		Updates(map[string]interface{}{
+			"quantity": gorm.Expr("quantity - ?", comparisonQuantity),
+			"reserved": gorm.Expr("reserved + ?", comparisonQuantity),
+		})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("insufficient inventory for product %s", comparisonProductID)
+	}
+	return nil
+}
+
+// releaseComparisonStock 归还扣减的库存（Saga补偿）
+func releaseComparisonStock(db *gorm.DB) error {
+	return db.Model(&model.Inventory{}).
+		Where("product_id = ?", comparisonProductID).
+		Updates(map[string]interface{}{
+			"quantity": gorm.Expr("quantity + ?", comparisonQuantity),
+			"reserved": gorm.Expr("reserved - ?", comparisonQuantity),
+		}).Error
+}
+
+// createComparisonPayment 创建支付记录
+func createComparisonPayment(tx *gorm.DB, orderNo string) error {
+	payment := model.PaymentRecord{
+		PaymentID: fmt.Sprintf("PAY-%s", uuid.New().String()[0:8]),
+		OrderNo:   orderNo,
+		UserID:    "user-cmp",
+		Amount:    comparisonUnitPrice * comparisonQuantity,
+		Status:    "success",
+	}
+	if err := tx.Create(&payment).Error; err != nil {
+		return fmt.Errorf("failed to create payment record: %w", err)
+	}
+	return nil
+}
+
+// resetComparisonInventory 重置对比使用的商品库存
+func resetComparisonInventory(dbManager *db.DBConnectionManager) {
+	inventoryDB, _ := dbManager.GetDB("inventory_service")
+	inventoryDB.Unscoped().Where("product_id = ?", comparisonProductID).Delete(&model.Inventory{})
+
+	inventory := model.Inventory{
+		ProductID:   comparisonProductID,
+		ProductName: "Mode Comparison Item",
+		Quantity:    comparisonStock,
+		Reserved:    0,
+	}
+	if err := inventoryDB.Create(&inventory).Error; err != nil {
+		log.Fatalf("Failed to create inventory data: %v", err)
+	}
+}
+
+// reconcileOrders 对账：已完成的订单必须有支付记录且扣减了库存，未完成的订单不能有支付记录
+// 返回不一致的订单数（库存差额按订单数折算）
+func reconcileOrders(dbManager *db.DBConnectionManager, prefix string) int {
+	orderDB, _ := dbManager.GetDB("order_service")
+	inventoryDB, _ := dbManager.GetDB("inventory_service")
+	paymentDB, _ := dbManager.GetDB("payment_service")
+
+	var orders []model.Order
+	orderDB.Where("order_no LIKE ?", prefix+"%").Find(&orders)
+
+	var paid []string
+	paymentDB.Model(&model.PaymentRecord{}).Where("order_no LIKE ?", prefix+"%").Pluck("order_no", &paid)
+	paidSet := make(map[string]bool, len(paid))
+	for _, orderNo := range paid {
+		paidSet[orderNo] = true
+	}
+
+	inconsistent := 0
+	completed := 0
+	for _, order := range orders {
+		isCompleted := order.Status == "completed"
+		if isCompleted {
+			completed++
+		}
+		if isCompleted != paidSet[order.OrderNo] {
+			inconsistent++
+		}
+	}
+
+	var inventory model.Inventory
+	inventoryDB.Where("product_id = ?", comparisonProductID).First(&inventory)
+	consumed := (comparisonStock - inventory.Quantity) / comparisonQuantity
+	if diff := consumed - completed; diff != 0 {
+		if diff < 0 {
+			diff = -diff
+		}
+		inconsistent += diff
+	}
+	return inconsistent
+}
+
+// percentile 计算耗时的百分位数，样本为空时返回0
+func percentile(samples []time.Duration, p float64) time.Duration {
+	if len(samples) == 0 {
+		return 0
+	}
+	sorted := append([]time.Duration(nil), samples...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	index := int(float64(len(sorted)-1) * p)
+	return sorted[index]
+}
+
+// printComparison 打印各模式的对比表
+func printComparison(results []*modeResult) {
+	ms := func(d time.Duration) string {
+		return fmt.Sprintf("%.1fms", float64(d.Microseconds())/1000)
+	}
+
+	fmt.Println("\n=== MODE COMPARISON ===")
+	fmt.Printf("Workload: %d orders x %d workers, payment failure injected on every %dth order\n\n",
+		comparisonOrders, comparisonWorkers, comparisonFailEvery)
+	fmt.Printf("%-8s %9s %7s %10s %10s %10s %10s %10s %12s %11s %7s %12s\n",
+		"Mode", "Committed", "Aborted", "Orders/s", "p50", "p95", "LockAvg", "LockMax",
+		"Converge95", "Compensated", "Retries", "Inconsistent")
+
+	for _, r := range results {
+		var total time.Duration
+		for _, hold := range r.LockHolds {
+			total += hold
+		}
+		var lockAvg time.Duration
+		if len(r.LockHolds) > 0 {
+			lockAvg = total / time.Duration(len(r.LockHolds))
+		}
+		converge := "-"
+		if len(r.Convergence) > 0 {
+			converge = ms(percentile(r.Convergence, 0.95))
+		}
+
+		fmt.Printf("%-8s %9d %7d %10.1f %10s %10s %10s %10s %12s %11d %7d %12d\n",
+			r.Mode, r.Committed, r.Aborted, float64(r.Orders)/r.Elapsed.Seconds(),
+			ms(percentile(r.Latencies, 0.5)), ms(percentile(r.Latencies, 0.95)),
+			ms(lockAvg), ms(percentile(r.LockHolds, 1)),
+			converge, r.Compensated, r.Retries, r.Inconsistent)
+	}
+
+	fmt.Println("\nCommitted/Aborted: orders accepted/rejected by the caller (outbox orders are accepted before downstream effects apply)")
+	fmt.Println("LockAvg/LockMax:   inventory row lock hold time")
+	fmt.Println("Converge95:        p95 time from order commit until every service applied it (outbox only)")
+	fmt.Println("Compensated:       orders whose committed intermediate state was visible and later undone")
+	fmt.Println("Retries:           redelivered outbox events after injected failures")
+	fmt.Println("Inconsistent:      orders failing reconciliation after the run")
+}
+
+// printPoolStats 打印负载结束后各服务的连接池统计
+func printPoolStats(dbManager *db.DBConnectionManager) {
+	fmt.Println("\n=== CONNECTION POOLS ===")
+	fmt.Printf("%-18s %7s %7s %6s %6s %9s %10s\n", "Service", "MaxOpen", "MaxIdle", "Open", "InUse", "Waits", "WaitTotal")
+	for _, s := range dbManager.PoolStats() {
+		fmt.Printf("%-18s %7d %7d %6d %6d %9d %8.1fms\n",
+			s.Service, s.Config.MaxOpenConns, s.Config.MaxIdleConns, s.Open, s.InUse, s.WaitCount, s.WaitDurationMs)
+	}
+}
@@ -1,6 +1,7 @@
 package examples
 
 import (
+	"context"
 	"distribute-tx/internal/config"
 	"fmt"
 	"log"
@@ -74,11 +75,21 @@ func SimpleOrderTransaction() {
 	orderNo := fmt.Sprintf("ORD-%s", uuid.New().String()[0:8])
 
 	fmt.Println("Starting distributed transaction for order creation...")
-	xid, err := txCoordinator.Begin("Create order and process payment")
+	// 整个全局事务的截止时间由ctx决定，超时后协调者拒绝提交并回滚
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	xid, err := txCoordinator.Begin(ctx, "Create order and process payment")
 	if err != nil {
 		log.Fatalf("Failed to begin transaction: %v", err)
 	}
 
+	// 记录执行前快照，提交或回滚后协调者会自动采集执行后快照
+	scope := model.SnapshotScope{OrderNos: []string{orderNo}, ProductIDs: []string{productID}}
+	if err := txCoordinator.TrackSnapshot(ctx, xid, scope); err != nil {
+		fmt.Printf("Warning: failed to capture before snapshot: %v\n", err)
+	}
+	defer showSnapshot(context.WithoutCancel(ctx), txCoordinator, xid)
+
 	// 步骤7: 定义各参与者的动作
 	participantActions := map[string]func(*gorm.DB) error{
 		// 订单服务动作：创建订单
@@ -157,11 +168,11 @@ func SimpleOrderTransaction() {
 
 	// 步骤8: 执行准备阶段
 	fmt.Println("Executing prepare phase...")
-	prepared, err := txCoordinator.Prepare(xid, participantActions)
+	prepared, err := txCoordinator.Prepare(ctx, xid, participantActions)
 	if err != nil {
 		fmt.Printf("Prepare phase failed: %v\n", err)
 		fmt.Println("Executing rollback...")
-		_, rollbackErr := txCoordinator.Rollback(xid)
+		_, rollbackErr := txCoordinator.Rollback(ctx, xid)
 		if rollbackErr != nil {
 			fmt.Printf("Rollback failed: %v\n", rollbackErr)
 		} else {
@@ -175,7 +186,7 @@ func SimpleOrderTransaction() {
 
 		// 步骤9: 执行提交阶段
 		fmt.Println("Executing commit phase...")
-		committed, err := txCoordinator.Commit(xid)
+		committed, err := txCoordinator.Commit(ctx, xid)
 		if err != nil {
 			fmt.Printf("Commit failed: %v\n", err)
 			return
@@ -234,3 +245,21 @@ func showTransactionResult(dbManager *db.DBConnectionManager, orderNo string, pr
 	paymentDB.Where("order_no = ?", orderNo).First(&payment)
 	fmt.Printf("Payment status: %s, Amount: %.2f\n", payment.Status, payment.Amount)
 }
+
+// showSnapshot 打印事务前后快照中发生变化的业务行
+func showSnapshot(ctx context.Context, txCoordinator *coordinator.TransactionCoordinator, xid string) {
+	report, err := txCoordinator.GetSnapshot(ctx, xid)
+	if err != nil {
+		fmt.Printf("Failed to load snapshot: %v\n", err)
+		return
+	}
+
+	fmt.Printf("Snapshot of transaction %s (outcome: %s):\n", xid, report.Outcome)
+	for _, row := range report.Rows {
+		if !row.Changed {
+			fmt.Printf("  %s.%s[%s]: unchanged\n", row.Service, row.Table, row.RowKey)
+			continue
+		}
+		fmt.Printf("  %s.%s[%s]:\n    before: %s\n    after:  %s\n", row.Service, row.Table, row.RowKey, row.Before, row.After)
+	}
+}
@@ -0,0 +1,157 @@
+package examples
+
+import (
+	"context"
+	"distribute-tx/internal/config"
+	"fmt"
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+
+	"distribute-tx/internal/coordinator"
+	"distribute-tx/internal/db"
+	"distribute-tx/internal/model"
+	"distribute-tx/internal/participant"
+)
+
+// ThreePhaseCommitComparison 对比2PC和3PC在协调者于两个阶段之间崩溃时的阻塞行为
+// 2PC：参与者准备后一直持有行锁等待协调者的决定，协调者恢复前其他事务被阻塞
+// 3PC：参与者预提交后若超时未收到决定则默认提交，锁在超时后自动释放
+func ThreePhaseCommitComparison() {
+	// 步骤1: 连接数据库并初始化表
+	dbManager := db.NewDBConnectionManager()
+	defer dbManager.Close()
+
+	dbConfig := config.DefaultDBConfig
+	for _, service := range []string{"coordinator", "inventory_service"} {
+		if err := dbManager.ConnectDB(service, dbConfig); err != nil {
+			log.Fatalf("Failed to connect to %s database: %v", service, err)
+		}
+	}
+	if err := dbManager.InitTransactionTables("coordinator"); err != nil {
+		log.Fatalf("Failed to initialize transaction tables: %v", err)
+	}
+	if err := dbManager.InitBusinessTables(); err != nil {
+		log.Fatalf("Failed to initialize business tables: %v", err)
+	}
+
+	productID := "product456"
+	quantity := 2
+
+	// 检查库存是否充足（CanCommit阶段使用，只读不加锁）
+	checkInventory := func(db *gorm.DB) error {
+		var inventory model.Inventory
+		if err := db.Where("product_id = ?", productID).First(&inventory).Error; err != nil {
+			return fmt.Errorf("product not found: %w", err)
+		}
+		if inventory.Quantity < quantity {
+			return fmt.Errorf("insufficient inventory for product %s", productID)
+		}
+		return nil
+	}
+
+	// 扣减库存（Prepare/PreCommit阶段使用，会持有行锁直到本地事务结束）
+	reserveInventory := func(tx *gorm.DB) error {
+		result := tx.Model(&model.Inventory{}).
+			Where("product_id = ? AND quantity >= ?", productID, quantity).
+			Updates(map[string]interface{}{
+				"quantity": gorm.Expr("quantity - ?", quantity),
+				"reserved": gorm.Expr("reserved + ?", quantity),
+			})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return fmt.Errorf("insufficient inventory for product %s", productID)
+		}
+		return nil
+	}
+
+	// 场景1: 2PC，协调者在Prepare之后崩溃
+	fmt.Println("\n=== 2PC: COORDINATOR CRASHES AFTER PREPARE ===")
+	prepareInventoryData(dbManager)
+
+	twoPC := coordinator.NewCoordinator("coordinator", dbManager, 30*time.Second)
+	twoPC.RegisterParticipant(participant.NewParticipant("inventory_service", "inventory_service", dbManager))
+
+	ctx := context.Background()
+	xid, err := twoPC.Begin(ctx, "2PC blocking demo")
+	if err != nil {
+		log.Fatalf("Failed to begin transaction: %v", err)
+	}
+	if _, err := twoPC.Prepare(ctx, xid, map[string]func(*gorm.DB) error{"inventory_service": reserveInventory}); err != nil {
+		log.Fatalf("Prepare failed: %v", err)
+	}
+	fmt.Println("Participants prepared, coordinator crashes before sending Commit")
+
+	for i := 1; i <= 3; i++ {
+		time.Sleep(time.Second)
+		fmt.Printf("t+%ds: another transaction updating the row -> %s\n", i, probeRowLock(dbManager, productID))
+	}
+	fmt.Println("Participants cannot decide on their own: the row stays locked until the coordinator recovers")
+
+	// 协调者恢复后根据事务日志完成决定
+	if _, err := twoPC.Rollback(ctx, xid); err != nil {
+		fmt.Printf("Recovery rollback failed: %v\n", err)
+	}
+	fmt.Printf("After coordinator recovery: %s\n", probeRowLock(dbManager, productID))
+
+	// 场景2: 3PC，协调者在PreCommit之后崩溃
+	fmt.Println("\n=== 3PC: COORDINATOR CRASHES AFTER PRECOMMIT ===")
+	prepareInventoryData(dbManager)
+
+	threePC := coordinator.NewCoordinator("coordinator", dbManager, 30*time.Second)
+	threePC.ThreePhase.DoCommit = 2 * time.Second
+	threePC.RegisterParticipant(participant.NewParticipant("inventory_service", "inventory_service", dbManager))
+
+	xid, err = threePC.Begin(ctx, "3PC non-blocking demo")
+	if err != nil {
+		log.Fatalf("Failed to begin transaction: %v", err)
+	}
+	if _, err := threePC.CanCommit(ctx, xid, map[string]func(*gorm.DB) error{"inventory_service": checkInventory}); err != nil {
+		log.Fatalf("CanCommit failed: %v", err)
+	}
+	fmt.Println("All participants voted yes in CanCommit")
+	if _, err := threePC.PreCommit(ctx, xid, map[string]func(*gorm.DB) error{"inventory_service": reserveInventory}); err != nil {
+		log.Fatalf("PreCommit failed: %v", err)
+	}
+	fmt.Printf("Participants pre-committed, coordinator crashes before sending DoCommit (participant timeout %v)\n",
+		threePC.ThreePhase.DoCommit)
+
+	for i := 1; i <= 3; i++ {
+		time.Sleep(time.Second)
+		fmt.Printf("t+%ds: another transaction updating the row -> %s\n", i, probeRowLock(dbManager, productID))
+	}
+
+	inventoryDB, _ := dbManager.GetDB("inventory_service")
+	var inventory model.Inventory
+	inventoryDB.Where("product_id = ?", productID).First(&inventory)
+	fmt.Printf("Participant committed by default: Available: %d, Reserved: %d\n", inventory.Quantity, inventory.Reserved)
+
+	fmt.Println("\nSummary:")
+	fmt.Println("- 2PC blocks while the coordinator is down: prepared participants hold locks and cannot decide alone")
+	fmt.Println("- 3PC bounds the blocking time: after PreCommit every participant knows all others voted yes,")
+	fmt.Println("  so committing on timeout is safe as long as the coordinator did not decide to abort")
+	fmt.Println("- The price is an extra round trip, and under a network partition a participant that missed")
+	fmt.Println("  an Abort may still commit on timeout, which is why 3PC is rarely used in practice")
+}
+
+// probeRowLock 在另一个事务中尝试更新库存行，用较短的锁等待超时判断行是否被锁住
+func probeRowLock(dbManager *db.DBConnectionManager, productID string) string {
+	inventoryDB, err := dbManager.GetDB("inventory_service")
+	if err != nil {
+		return err.Error()
+	}
+
+	err = inventoryDB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("SET SESSION innodb_lock_wait_timeout = 1").Error; err != nil {
+			return err
+		}
+		return tx.Exec("UPDATE inventory SET reserved = reserved WHERE product_id = ?", productID).Error
+	})
+	if err != nil {
+		return fmt.Sprintf("BLOCKED (%v)", err)
+	}
+	return "ok"
+}
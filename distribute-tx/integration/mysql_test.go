@@ -0,0 +1,135 @@
+//go:build integration
+
+package integration
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"os/exec"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+
+	"distribute-tx/internal/config"
+)
+
+// 测试用MySQL容器的参数，镜像可以用 DTX_IT_MYSQL_IMAGE 覆盖
+const (
+	defaultMySQLImage = "mysql:8.0"
+	mysqlPassword     = "dtx-it"
+	mysqlStartTimeout = 3 * time.Minute
+)
+
+var (
+	containerName string       // 所有测试共用的MySQL容器
+	containerPort int          // 容器映射到本机的端口
+	skipReason    string       // 无法启动容器时跳过所有测试的原因
+	databaseSeq   atomic.Int64 // 测试数据库的序号
+)
+
+func TestMain(m *testing.M) {
+	if err := exec.Command("docker", "info").Run(); err != nil {
+		skipReason = fmt.Sprintf("docker is not available: %v", err)
+		os.Exit(m.Run())
+	}
+
+	image := os.Getenv("DTX_IT_MYSQL_IMAGE")
+	if image == "" {
+		image = defaultMySQLImage
+	}
+	if err := startMySQL(image); err != nil {
+		log.Printf("Failed to start MySQL container: %v", err)
+		removeContainer()
+		os.Exit(1)
+	}
+
+	code := m.Run()
+	removeContainer()
+	os.Exit(code)
+}
+
+// requireDocker 没有可用的docker时跳过测试
+func requireDocker(t *testing.T) {
+	t.Helper()
+	if skipReason != "" {
+		t.Skip(skipReason)
+	}
+}
+
+// startMySQL 启动MySQL容器并等待它可以连接，容器名带上进程ID，避免与并行的测试进程冲突
+func startMySQL(image string) error {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return err
+	}
+	containerPort = lis.Addr().(*net.TCPAddr).Port
+	lis.Close()
+
+	containerName = fmt.Sprintf("dtx-it-%d", os.Getpid())
+	out, err := exec.Command("docker", "run", "-d", "--name", containerName,
+		"-e", "MYSQL_ROOT_PASSWORD="+mysqlPassword,
+		"-p", fmt.Sprintf("127.0.0.1:%d:3306", containerPort),
+		image).CombinedOutput()
+	if err != nil {
+		containerName = ""
+		return fmt.Errorf("docker run: %v: %s", err, strings.TrimSpace(string(out)))
+	}
+
+	// 镜像初始化时会先启动一个不监听TCP的临时实例，能通过映射的端口连接时初始化已经完成
+	deadline := time.Now().Add(mysqlStartTimeout)
+	var lastErr error
+	for time.Now().Before(deadline) {
+		db, err := openMySQL()
+		if err == nil {
+			sqlDB, _ := db.DB()
+			ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+			err = sqlDB.PingContext(ctx)
+			cancel()
+			sqlDB.Close()
+			if err == nil {
+				log.Printf("MySQL container %s ready on port %d", containerName, containerPort)
+				return nil
+			}
+		}
+		lastErr = err
+		time.Sleep(time.Second)
+	}
+	return fmt.Errorf("mysql not ready after %v: %v", mysqlStartTimeout, lastErr)
+}
+
+// removeContainer 删除测试启动的容器
+func removeContainer() {
+	if containerName != "" {
+		exec.Command("docker", "rm", "-f", containerName).Run()
+	}
+}
+
+// openMySQL 连接容器中的MySQL（不选择数据库），不输出SQL日志
+func openMySQL() (*gorm.DB, error) {
+	dsn := fmt.Sprintf("root:%s@tcp(127.0.0.1:%d)/?charset=utf8mb4&parseTime=True&loc=Local", mysqlPassword, containerPort)
+	return gorm.Open(mysql.Open(dsn), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+}
+
+// createDatabase 为测试创建一个新的数据库，返回连接它的配置
+func createDatabase(t *testing.T) config.DBConfig {
+	t.Helper()
+	name := fmt.Sprintf("dtx_it_%d", databaseSeq.Add(1))
+	db, err := openMySQL()
+	if err != nil {
+		t.Fatalf("connect to %s: %v", containerName, err)
+	}
+	sqlDB, _ := db.DB()
+	defer sqlDB.Close()
+	if err := db.Exec("CREATE DATABASE " + name).Error; err != nil {
+		t.Fatalf("create database %s: %v", name, err)
+	}
+	return config.DBConfig{Host: "127.0.0.1", Port: containerPort, User: "root", Password: mysqlPassword, DBName: name}
+}
@@ -0,0 +1,286 @@
+//go:build integration
+
+package integration
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+
+	"distribute-tx/internal/config"
+	"distribute-tx/internal/coordinator"
+	"distribute-tx/internal/db"
+	"distribute-tx/internal/model"
+	"distribute-tx/internal/participant"
+)
+
+// 测试中协调者和参与者使用的服务名
+const (
+	coordinatorService = "coordinator"
+	accountService     = "account_service"
+)
+
+// 协调者租约，测试把原协调者的心跳改到租约之前来模拟它已经死亡
+const testLease = 10 * time.Second
+
+// node 一个协调者进程：自己的数据库连接，以及注册了自己的账户参与者实例的协调者
+type node struct {
+	dbManager   *db.DBConnectionManager
+	coordinator *coordinator.TransactionCoordinator
+}
+
+// cluster 一个协调者数据库和一个账户服务数据库，以及两个模拟的协调者进程：
+// 原协调者执行事务的前几个阶段后“死亡”，备用协调者接管并恢复它的事务。
+// 两个进程不共享连接和参与者对象，备用协调者只能通过资源数据库中已准备的XA分支完成事务
+type cluster struct {
+	old, standby *node
+	dbManager    *db.DBConnectionManager // 备用进程的连接，测试用它检查数据
+}
+
+// newCluster 创建测试数据库、业务表和两个协调者进程，账户服务中有一个余额为100的账户
+func newCluster(t *testing.T) *cluster {
+	t.Helper()
+	configs := map[string]config.DBConfig{
+		coordinatorService: createDatabase(t),
+		accountService:     createDatabase(t),
+	}
+	c := &cluster{old: newNode(t, configs), standby: newNode(t, configs)}
+	c.dbManager = c.standby.dbManager
+
+	if err := c.dbManager.InitTransactionTables(coordinatorService); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.dbManager.InitBusinessTables(); err != nil {
+		t.Fatal(err)
+	}
+	accountDB, _ := c.dbManager.GetDB(accountService)
+	if err := accountDB.Create(&model.Account{UserID: "user1", Balance: 100, Status: "active"}).Error; err != nil {
+		t.Fatal(err)
+	}
+	for _, n := range []*node{c.old, c.standby} {
+		if err := n.coordinator.RegisterNode(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return c
+}
+
+// newNode 创建一个协调者进程使用的连接、参与者和协调者
+func newNode(t *testing.T, configs map[string]config.DBConfig) *node {
+	t.Helper()
+	dbManager := db.NewDBConnectionManager()
+	t.Cleanup(func() { dbManager.Close() })
+	for service, cfg := range configs {
+		if err := dbManager.ConnectDB(service, cfg); err != nil {
+			t.Fatalf("connect %s: %v", service, err)
+		}
+	}
+
+	account := participant.NewParticipant("account", accountService, dbManager)
+	coord := coordinator.NewCoordinator(coordinatorService, dbManager, time.Minute)
+	coord.RegisterParticipant(account)
+	// 预提交后等待决定的时间比测试长，参与者不会在恢复前自行提交
+	coord.ThreePhase.DoCommit = time.Hour
+	return &node{dbManager: dbManager, coordinator: coord}
+}
+
+// crashOld 原协调者进程退出：关闭它的所有数据库连接，未准备的分支随连接断开被MySQL回滚
+func (c *cluster) crashOld(t *testing.T) {
+	t.Helper()
+	if err := c.old.dbManager.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// killOld 把原协调者的心跳改到租约之前，由备用协调者检测并接管，返回接管的事务
+func (c *cluster) killOld(t *testing.T) []string {
+	t.Helper()
+	coordDB, _ := c.dbManager.GetDB(coordinatorService)
+	if err := coordDB.Model(&model.CoordinatorNode{}).Where("node_id = ?", c.old.coordinator.NodeID).
+		Update("last_heartbeat", time.Now().Add(-2*testLease)).Error; err != nil {
+		t.Fatal(err)
+	}
+	dead, err := c.standby.coordinator.DetectDeadNodes(testLease)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(dead) != 1 || dead[0].NodeID != c.old.coordinator.NodeID {
+		t.Fatalf("detected dead nodes %+v, want only %s", dead, c.old.coordinator.NodeID)
+	}
+	xids, err := c.standby.coordinator.TakeOver(c.old.coordinator.NodeID, testLease)
+	if err != nil {
+		t.Fatalf("take over: %v", err)
+	}
+	return xids
+}
+
+// balance 账户服务中user1的余额
+func (c *cluster) balance(t *testing.T) float64 {
+	t.Helper()
+	accountDB, _ := c.dbManager.GetDB(accountService)
+	var account model.Account
+	if err := accountDB.Where("user_id = ?", "user1").First(&account).Error; err != nil {
+		t.Fatal(err)
+	}
+	return account.Balance
+}
+
+// debit 扣减user1的余额
+func debit(amount float64) func(*gorm.DB) error {
+	return func(tx *gorm.DB) error {
+		return tx.Model(&model.Account{}).Where("user_id = ?", "user1").
+			Update("balance", gorm.Expr("balance - ?", amount)).Error
+	}
+}
+
+// noCheck CanCommit阶段总是同意
+func noCheck(*gorm.DB) error { return nil }
+
+// requireTakenOver 接管的事务中包含xid，且xid已经改由备用协调者负责
+func (c *cluster) requireTakenOver(t *testing.T, xids []string, xid string) {
+	t.Helper()
+	found := false
+	for _, id := range xids {
+		found = found || id == xid
+	}
+	if !found {
+		t.Fatalf("taken over transactions %v do not include %s", xids, xid)
+	}
+	transaction, err := c.standby.coordinator.GetTransaction(context.Background(), xid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if transaction.CoordinatorID != c.standby.coordinator.NodeID {
+		t.Fatalf("transaction %s owned by %s after takeover, want %s", xid, transaction.CoordinatorID, c.standby.coordinator.NodeID)
+	}
+}
+
+// requireOutcome 恢复后事务的状态和账户余额
+func (c *cluster) requireOutcome(t *testing.T, xid string, status model.TransactionStatus, balance float64) {
+	t.Helper()
+	transaction, err := c.standby.coordinator.GetTransaction(context.Background(), xid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if transaction.Status != status {
+		t.Errorf("transaction status %s after recovery, want %s", transaction.Status, status)
+	}
+	if got := c.balance(t); got != balance {
+		t.Errorf("balance %v after recovery, want %v", got, balance)
+	}
+}
+
+func TestRecoverPreparedTwoPhaseTransaction(t *testing.T) {
+	requireDocker(t)
+	c := newCluster(t)
+	ctx := context.Background()
+	old := c.old.coordinator
+
+	xid, err := old.Begin(ctx, "2PC debit")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ok, err := old.Prepare(ctx, xid, map[string]func(*gorm.DB) error{"account": debit(30)}); !ok {
+		t.Fatalf("prepare: %v", err)
+	}
+
+	// 原协调者进程在提交之前退出，已准备的XA分支留在账户库中，由另一个进程中的参与者提交
+	c.crashOld(t)
+	c.requireTakenOver(t, c.killOld(t), xid)
+	committed, err := c.standby.coordinator.RecoverTransaction(ctx, xid)
+	if err != nil || !committed {
+		t.Fatalf("recover prepared transaction: committed=%v err=%v", committed, err)
+	}
+	c.requireOutcome(t, xid, model.StatusCommitted, 70)
+}
+
+func TestRecoverPreCommittedThreePhaseTransaction(t *testing.T) {
+	requireDocker(t)
+	c := newCluster(t)
+	ctx := context.Background()
+	old := c.old.coordinator
+
+	xid, err := old.Begin(ctx, "3PC debit")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ok, err := old.CanCommit(ctx, xid, map[string]func(*gorm.DB) error{"account": noCheck}); !ok {
+		t.Fatalf("can-commit: %v", err)
+	}
+	if ok, err := old.PreCommit(ctx, xid, map[string]func(*gorm.DB) error{"account": debit(30)}); !ok {
+		t.Fatalf("pre-commit: %v", err)
+	}
+
+	// 原协调者进程在发出DoCommit之前退出，它的超时自动提交定时器随之失效
+	c.crashOld(t)
+	c.requireTakenOver(t, c.killOld(t), xid)
+	committed, err := c.standby.coordinator.RecoverTransaction(ctx, xid)
+	if err != nil || !committed {
+		t.Fatalf("recover precommitted transaction: committed=%v err=%v", committed, err)
+	}
+	c.requireOutcome(t, xid, model.StatusCommitted, 70)
+}
+
+func TestRecoverCanCommitThreePhaseTransactionRollsBack(t *testing.T) {
+	requireDocker(t)
+	c := newCluster(t)
+	ctx := context.Background()
+	old := c.old.coordinator
+
+	xid, err := old.Begin(ctx, "3PC debit")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ok, err := old.CanCommit(ctx, xid, map[string]func(*gorm.DB) error{"account": noCheck}); !ok {
+		t.Fatalf("can-commit: %v", err)
+	}
+
+	// 原协调者在PreCommit之前退出：参与者还没有修改数据，恢复时中止
+	c.crashOld(t)
+	c.requireTakenOver(t, c.killOld(t), xid)
+	committed, err := c.standby.coordinator.RecoverTransaction(ctx, xid)
+	if err != nil || committed {
+		t.Fatalf("recover can-commit transaction: committed=%v err=%v", committed, err)
+	}
+	c.requireOutcome(t, xid, model.StatusRolledBack, 100)
+}
+
+func TestTakenOverCoordinatorIsFenced(t *testing.T) {
+	requireDocker(t)
+	c := newCluster(t)
+	ctx := context.Background()
+	old := c.old.coordinator
+
+	xid, err := old.Begin(ctx, "3PC debit")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ok, err := old.CanCommit(ctx, xid, map[string]func(*gorm.DB) error{"account": noCheck}); !ok {
+		t.Fatalf("can-commit: %v", err)
+	}
+	if ok, err := old.PreCommit(ctx, xid, map[string]func(*gorm.DB) error{"account": debit(30)}); !ok {
+		t.Fatalf("pre-commit: %v", err)
+	}
+
+	// 原协调者只是暂停，接管之后又恢复运行：它不能再推进已经被接管的事务
+	c.requireTakenOver(t, c.killOld(t), xid)
+	if _, err := old.DoCommit(ctx, xid); !errors.Is(err, coordinator.ErrNotOwner) {
+		t.Fatalf("old coordinator do-commit after takeover: err=%v, want ErrNotOwner", err)
+	}
+	if _, err := old.Abort(ctx, xid); !errors.Is(err, coordinator.ErrNotOwner) {
+		t.Fatalf("old coordinator abort after takeover: err=%v, want ErrNotOwner", err)
+	}
+	transaction, _ := c.standby.coordinator.GetTransaction(ctx, xid)
+	if transaction.Status != model.StatusPreCommit {
+		t.Fatalf("transaction status %s after fenced calls, want %s", transaction.Status, model.StatusPreCommit)
+	}
+
+	committed, err := c.standby.coordinator.RecoverTransaction(ctx, xid)
+	if err != nil || !committed {
+		t.Fatalf("recover precommitted transaction: committed=%v err=%v", committed, err)
+	}
+	c.requireOutcome(t, xid, model.StatusCommitted, 70)
+}
@@ -0,0 +1,187 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"distribute-tx/internal/coordinator"
+	"distribute-tx/internal/model"
+)
+
+// Server 提供分布式事务的运维查询与操作API
+type Server struct {
+	coordinator *coordinator.TransactionCoordinator
+	port        int
+}
+
+// resolveRequest 人工处理请求
+type resolveRequest struct {
+	Operator string `json:"operator"` // 处理人
+	Note     string `json:"note"`     // 处理说明
+}
+
+// NewServer 创建新的API服务器
+func NewServer(coord *coordinator.TransactionCoordinator, port int) *Server {
+	return &Server{
+		coordinator: coord,
+		port:        port,
+	}
+}
+
+// Routes 注册API路由
+func (s *Server) Routes() *http.ServeMux {
+	mux := http.NewServeMux()
+
+	// 补偿人工处理队列
+	mux.HandleFunc("/api/escalations", s.handleEscalations)
+	mux.HandleFunc("/api/escalations/", s.handleEscalationByID)
+
+	// 事务前后快照
+	mux.HandleFunc("/api/transactions/", s.handleTransactionSnapshot)
+
+	// 长事务报告
+	mux.HandleFunc("/api/transactions/stuck", s.handleStuckTransactions)
+
+	// 各服务连接池统计
+	mux.HandleFunc("/api/pools", s.handlePools)
+
+	return mux
+}
+
+// Start 启动HTTP服务器
+func (s *Server) Start() error {
+	addr := fmt.Sprintf(":%d", s.port)
+	log.Printf("Starting transaction API server at http://localhost%s", addr)
+	return http.ListenAndServe(addr, s.Routes())
+}
+
+// handlePools 返回各服务的连接池配置和使用统计
+func (s *Server) handlePools(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		respondWithError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+	respondWithJSON(w, http.StatusOK, s.coordinator.DBManager.PoolStats())
+}
+
+// handleStuckTransactions 返回停留在某个状态超过阈值的长事务及其参与者和持有的资源
+func (s *Server) handleStuckTransactions(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		respondWithError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	report, err := s.coordinator.FindStuckTransactions(r.Context())
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	respondWithJSON(w, http.StatusOK, report)
+}
+
+// handleEscalations 列出人工处理队列，支持 ?status=pending|resolved|retried
+func (s *Server) handleEscalations(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		respondWithError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	status := model.EscalationStatus(r.URL.Query().Get("status"))
+	escalations, err := s.coordinator.ListEscalations(status)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	respondWithJSON(w, http.StatusOK, escalations)
+}
+
+// handleEscalationByID 处理单个条目：GET /api/escalations/{id}，POST /api/escalations/{id}/resolve
+func (s *Server) handleEscalationByID(w http.ResponseWriter, r *http.Request) {
+	path := strings.TrimPrefix(r.URL.Path, "/api/escalations/")
+	parts := strings.Split(path, "/")
+
+	id, err := strconv.ParseUint(parts[0], 10, 32)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid escalation ID")
+		return
+	}
+
+	switch {
+	case len(parts) == 1 && r.Method == http.MethodGet:
+		escalation, err := s.coordinator.GetEscalation(uint(id))
+		if err != nil {
+			respondWithError(w, http.StatusNotFound, "Escalation not found")
+			return
+		}
+		respondWithJSON(w, http.StatusOK, escalation)
+
+	case len(parts) == 2 && parts[1] == "resolve" && r.Method == http.MethodPost:
+		var req resolveRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			respondWithError(w, http.StatusBadRequest, "Invalid request payload")
+			return
+		}
+		defer r.Body.Close()
+
+		if req.Operator == "" {
+			respondWithError(w, http.StatusBadRequest, "Operator is required")
+			return
+		}
+		if err := s.coordinator.ResolveEscalation(uint(id), req.Operator, req.Note); err != nil {
+			respondWithError(w, http.StatusConflict, err.Error())
+			return
+		}
+		respondWithJSON(w, http.StatusOK, map[string]string{"message": "Escalation resolved"})
+
+	default:
+		respondWithError(w, http.StatusMethodNotAllowed, "Method not allowed")
+	}
+}
+
+// handleTransactionSnapshot 获取全局事务的前后快照：GET /api/transactions/{xid}/snapshot
+func (s *Server) handleTransactionSnapshot(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		respondWithError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/api/transactions/"), "/")
+	if len(parts) != 2 || parts[0] == "" || parts[1] != "snapshot" {
+		respondWithError(w, http.StatusNotFound, "Not found")
+		return
+	}
+
+	report, err := s.coordinator.GetSnapshot(r.Context(), parts[0])
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if len(report.Rows) == 0 {
+		respondWithError(w, http.StatusNotFound, "No snapshot recorded for transaction")
+		return
+	}
+	respondWithJSON(w, http.StatusOK, report)
+}
+
+// respondWithError 返回错误响应
+func respondWithError(w http.ResponseWriter, code int, message string) {
+	respondWithJSON(w, code, map[string]string{"error": message})
+}
+
+// respondWithJSON 返回JSON响应
+func respondWithJSON(w http.ResponseWriter, code int, payload interface{}) {
+	response, err := json.Marshal(payload)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(fmt.Sprintf("JSON marshaling error: %v", err)))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	w.Write(response)
+}
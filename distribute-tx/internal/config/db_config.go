@@ -1,12 +1,23 @@
 package config
 
+import "time"
+
 // DBConfig 数据库连接配置
 type DBConfig struct {
-	Host     string // 数据库主机
-	Port     int    // 端口
-	User     string // 用户名
-	Password string // 密码
-	DBName   string // 数据库名
+	Host     string     // 数据库主机
+	Port     int        // 端口
+	User     string     // 用户名
+	Password string     // 密码
+	DBName   string     // 数据库名
+	Pool     PoolConfig // 连接池配置（可选），零值字段依次使用服务的推荐配置和默认值
+}
+
+// PoolConfig 连接池配置，字段为零值时表示未设置
+type PoolConfig struct {
+	MaxOpenConns    int           // 最大打开连接数
+	MaxIdleConns    int           // 最大空闲连接数
+	ConnMaxLifetime time.Duration // 连接最长存活时间
+	ConnMaxIdleTime time.Duration // 连接最长空闲时间
 }
 
 var DefaultDBConfig = DBConfig{
@@ -16,3 +27,48 @@ var DefaultDBConfig = DBConfig{
 	Password: "",
 	DBName:   "test_tx",
 }
+
+// DefaultPoolConfig 没有单独配置的服务使用的连接池配置
+var DefaultPoolConfig = PoolConfig{
+	MaxOpenConns:    100,
+	MaxIdleConns:    10,
+	ConnMaxLifetime: time.Hour,
+	ConnMaxIdleTime: 10 * time.Minute,
+}
+
+// ServicePoolConfigs 按服务名的推荐连接池配置
+// 协调者每个事务都要写事务和参与者记录，还有心跳和恢复扫描，需要较多常驻连接；
+// 库存服务在压测负载下争用同一行库存，连接数超过能并发拿到行锁的数量只会让请求在InnoDB锁等待中排队，
+// 因此限制打开连接数，让多出的请求在连接池中等待（等待次数见连接池统计），并保持连接常驻避免反复建连
+var ServicePoolConfigs = map[string]PoolConfig{
+	"coordinator": {
+		MaxOpenConns: 50,
+		MaxIdleConns: 25,
+	},
+	"inventory_service": {
+		MaxOpenConns: 16,
+		MaxIdleConns: 16,
+	},
+}
+
+// ResolvePoolConfig 计算服务生效的连接池配置：显式配置优先，其次是服务的推荐配置，最后是默认值
+func ResolvePoolConfig(serviceName string, pool PoolConfig) PoolConfig {
+	return pool.withDefaults(ServicePoolConfigs[serviceName]).withDefaults(DefaultPoolConfig)
+}
+
+// withDefaults 用fallback填充未设置的字段
+func (p PoolConfig) withDefaults(fallback PoolConfig) PoolConfig {
+	if p.MaxOpenConns == 0 {
+		p.MaxOpenConns = fallback.MaxOpenConns
+	}
+	if p.MaxIdleConns == 0 {
+		p.MaxIdleConns = fallback.MaxIdleConns
+	}
+	if p.ConnMaxLifetime == 0 {
+		p.ConnMaxLifetime = fallback.ConnMaxLifetime
+	}
+	if p.ConnMaxIdleTime == 0 {
+		p.ConnMaxIdleTime = fallback.ConnMaxIdleTime
+	}
+	return p
+}
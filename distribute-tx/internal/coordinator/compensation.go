@@ -0,0 +1,230 @@
+package coordinator
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"distribute-tx/internal/model"
+	"distribute-tx/internal/participant"
+)
+
+// ErrCompensationEscalated 补偿重试预算耗尽，分支已进入人工处理队列
+var ErrCompensationEscalated = errors.New("compensation escalated to manual queue")
+
+// CompensationPolicy 自动补偿的重试预算
+type CompensationPolicy struct {
+	MaxAttempts    int           // 最大尝试次数
+	InitialBackoff time.Duration // 首次重试前的等待时间
+	MaxBackoff     time.Duration // 最大等待时间
+}
+
+// DefaultCompensationPolicy 默认补偿策略：最多尝试3次，退避从200ms开始翻倍
+var DefaultCompensationPolicy = CompensationPolicy{
+	MaxAttempts:    3,
+	InitialBackoff: 200 * time.Millisecond,
+	MaxBackoff:     2 * time.Second,
+}
+
+// Compensate 对各分支执行补偿操作，每个分支在重试预算内自动重试，
+// 预算耗尽后写入人工处理队列并发出通知，返回进入队列的分支名称
+// ctx取消后不再发起新的重试，尚未成功的分支同样进入人工处理队列
+func (c *TransactionCoordinator) Compensate(ctx context.Context, xid string, compensations map[string]func(context.Context) error) ([]string, error) {
+	var escalated []string
+
+	for _, p := range c.Participants {
+		compensation, exists := compensations[p.Name]
+		if !exists {
+			continue
+		}
+
+		history, err := c.compensateBranch(ctx, xid, p, compensation)
+		if err == nil {
+			continue
+		}
+
+		if err := c.escalate(context.WithoutCancel(ctx), xid, p, history); err != nil {
+			return escalated, fmt.Errorf("failed to escalate branch %s: %w", p.Name, err)
+		}
+		escalated = append(escalated, p.Name)
+	}
+
+	if len(escalated) > 0 {
+		return escalated, fmt.Errorf("%w: %v", ErrCompensationEscalated, escalated)
+	}
+	return nil, nil
+}
+
+// compensateBranch 在重试预算内执行单个分支的补偿，返回每次失败的错误信息
+func (c *TransactionCoordinator) compensateBranch(ctx context.Context, xid string, p *participant.Participant, compensation func(context.Context) error) ([]string, error) {
+	policy := c.CompensationPolicy
+	if policy.MaxAttempts <= 0 {
+		policy.MaxAttempts = 1
+	}
+
+	var history []string
+	backoff := policy.InitialBackoff
+	for attempt := 1; attempt <= policy.MaxAttempts; attempt++ {
+		_, err := p.ExecuteCompensation(ctx, xid, compensation)
+		if err == nil {
+			return history, nil
+		}
+
+		history = append(history, fmt.Sprintf("attempt %d at %s: %v", attempt, time.Now().Format(time.RFC3339), err))
+		log.Printf("Compensation attempt %d/%d failed for %s in transaction %s: %v",
+			attempt, policy.MaxAttempts, p.Name, xid, err)
+
+		if ctx.Err() != nil {
+			return history, fmt.Errorf("compensation interrupted: %w", ctx.Err())
+		}
+		if attempt < policy.MaxAttempts && backoff > 0 {
+			select {
+			case <-ctx.Done():
+				return history, fmt.Errorf("compensation interrupted: %w", ctx.Err())
+			case <-time.After(backoff):
+			}
+			backoff *= 2
+			if policy.MaxBackoff > 0 && backoff > policy.MaxBackoff {
+				backoff = policy.MaxBackoff
+			}
+		}
+	}
+
+	return history, errors.New("compensation retry budget exhausted")
+}
+
+// escalate 将分支写入人工处理队列并发出通知
+func (c *TransactionCoordinator) escalate(ctx context.Context, xid string, p *participant.Participant, history []string) error {
+	txDB, err := c.DBManager.GetDB(c.ServiceName)
+	if err != nil {
+		return err
+	}
+
+	historyJSON, err := json.Marshal(history)
+	if err != nil {
+		return err
+	}
+
+	escalation := model.CompensationEscalation{
+		XID:          xid,
+		Branch:       p.Name,
+		ResourceID:   p.ResourceID,
+		Attempts:     len(history),
+		ErrorHistory: string(historyJSON),
+		Status:       model.EscalationPending,
+	}
+	if err := txDB.WithContext(ctx).Create(&escalation).Error; err != nil {
+		return err
+	}
+
+	c.notify(Notification{
+		Level:   LevelCritical,
+		Kind:    "compensation_escalated",
+		XID:     xid,
+		Message: fmt.Sprintf("compensation for branch %s failed %d times, operator action required", p.Name, len(history)),
+		Details: map[string]interface{}{
+			"escalation_id": escalation.ID,
+			"branch":        p.Name,
+			"resource_id":   p.ResourceID,
+			"errors":        history,
+		},
+	})
+
+	return nil
+}
+
+// ListEscalations 获取人工处理队列中的条目，status为空时返回全部
+func (c *TransactionCoordinator) ListEscalations(status model.EscalationStatus) ([]model.CompensationEscalation, error) {
+	txDB, err := c.DBManager.GetDB(c.ServiceName)
+	if err != nil {
+		return nil, err
+	}
+
+	query := txDB.Order("id")
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	var escalations []model.CompensationEscalation
+	if err := query.Find(&escalations).Error; err != nil {
+		return nil, err
+	}
+	return escalations, nil
+}
+
+// GetEscalation 根据ID获取人工处理队列条目
+func (c *TransactionCoordinator) GetEscalation(id uint) (*model.CompensationEscalation, error) {
+	txDB, err := c.DBManager.GetDB(c.ServiceName)
+	if err != nil {
+		return nil, err
+	}
+
+	var escalation model.CompensationEscalation
+	if err := txDB.First(&escalation, id).Error; err != nil {
+		return nil, err
+	}
+	return &escalation, nil
+}
+
+// ResolveEscalation 由操作员标记条目已人工处理
+func (c *TransactionCoordinator) ResolveEscalation(id uint, operator string, note string) error {
+	return c.closeEscalation(id, model.EscalationResolved, operator, note)
+}
+
+// RetryEscalation 由操作员触发一次补偿重试，成功后关闭条目，失败时追加错误历史
+func (c *TransactionCoordinator) RetryEscalation(ctx context.Context, id uint, operator string, compensation func(context.Context) error) error {
+	escalation, err := c.GetEscalation(id)
+	if err != nil {
+		return err
+	}
+	if escalation.Status != model.EscalationPending {
+		return fmt.Errorf("escalation %d is not pending, current status: %s", id, escalation.Status)
+	}
+
+	if err := compensation(ctx); err != nil {
+		var history []string
+		json.Unmarshal([]byte(escalation.ErrorHistory), &history)
+		history = append(history, fmt.Sprintf("manual retry by %s at %s: %v", operator, time.Now().Format(time.RFC3339), err))
+		historyJSON, _ := json.Marshal(history)
+
+		txDB, dbErr := c.DBManager.GetDB(c.ServiceName)
+		if dbErr != nil {
+			return dbErr
+		}
+		txDB.Model(escalation).Updates(map[string]interface{}{
+			"attempts":      escalation.Attempts + 1,
+			"error_history": string(historyJSON),
+		})
+		return fmt.Errorf("manual compensation retry failed: %w", err)
+	}
+
+	return c.closeEscalation(id, model.EscalationRetried, operator, "manual retry succeeded")
+}
+
+// closeEscalation 关闭人工处理队列条目
+func (c *TransactionCoordinator) closeEscalation(id uint, status model.EscalationStatus, operator string, note string) error {
+	txDB, err := c.DBManager.GetDB(c.ServiceName)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	result := txDB.Model(&model.CompensationEscalation{}).
+		Where("id = ? AND status = ?", id, model.EscalationPending).
+		Updates(map[string]interface{}{
+			"status":       status,
+			"resolved_by":  operator,
+			"resolve_note": note,
+			"resolved_at":  &now,
+		})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("escalation %d not found or already closed", id)
+	}
+	return nil
+}
@@ -1,6 +1,7 @@
 package coordinator
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"sync"
@@ -16,20 +17,46 @@ import (
 
 // TransactionCoordinator 协调分布式事务的中央组件
 type TransactionCoordinator struct {
-	ServiceName  string                     // 协调者服务名称
-	DBManager    *db.DBConnectionManager    // 数据库连接管理器
-	Participants []*participant.Participant // 事务参与者列表
-	Timeout      time.Duration              // 事务超时时间
-	mutex        sync.Mutex                 // 互斥锁，用于并发控制
+	ServiceName        string                             // 协调者服务名称
+	NodeID             string                             // 协调者实例ID
+	DBManager          *db.DBConnectionManager            // 数据库连接管理器
+	Participants       []*participant.Participant         // 事务参与者列表
+	Timeout            time.Duration                      // 默认事务超时，调用方上下文没有截止时间时使用
+	EnlistWindow       time.Duration                      // 事务开始后允许参与者登记的时长，0表示直到事务截止时间
+	CompensationPolicy CompensationPolicy                 // 自动补偿重试预算
+	Notifier           Notifier                           // 通知钩子
+	ThreePhase         ThreePhaseTimeouts                 // 三阶段提交各阶段超时
+	StuckThresholds    StuckThresholds                    // 长事务阈值，为nil时使用 DefaultStuckThresholds
+	mutex              sync.Mutex                         // 互斥锁，用于并发控制
+	heartbeatStop      chan struct{}                      // 心跳停止信号
+	stuckStop          chan struct{}                      // 长事务监控停止信号
+	stuckAlerted       map[string]model.TransactionStatus // 已告警的长事务及告警时的状态
 }
 
+var (
+	// ErrNotOwner 事务已被其他协调者实例接管，当前实例不能再推进它
+	ErrNotOwner = errors.New("transaction is not owned by this coordinator")
+	// ErrStatusChanged 事务状态已被改变（如并发的提交与回滚），不能按预期的状态推进
+	ErrStatusChanged = errors.New("transaction status changed concurrently")
+)
+
+// commitFrom 可以进入提交结果（已提交或失败）的事务状态：2PC已准备、3PC已预提交
+var commitFrom = []model.TransactionStatus{model.StatusPrepared, model.StatusPreCommit}
+
+// rollbackFrom 可以回滚的事务状态：尚未结束的事务，以及准备失败后由调用方回滚的事务
+var rollbackFrom = append(append([]model.TransactionStatus{}, activeStatuses...), model.StatusFailed)
+
 // NewCoordinator 创建新的事务协调者
 func NewCoordinator(serviceName string, dbManager *db.DBConnectionManager, timeout time.Duration) *TransactionCoordinator {
 	return &TransactionCoordinator{
-		ServiceName:  serviceName,
-		DBManager:    dbManager,
-		Participants: make([]*participant.Participant, 0),
-		Timeout:      timeout,
+		ServiceName:        serviceName,
+		NodeID:             uuid.New().String(),
+		DBManager:          dbManager,
+		Participants:       make([]*participant.Participant, 0),
+		Timeout:            timeout,
+		CompensationPolicy: DefaultCompensationPolicy,
+		Notifier:           LogNotifier{},
+		ThreePhase:         DefaultThreePhaseTimeouts,
 	}
 }
 
@@ -42,7 +69,10 @@ func (c *TransactionCoordinator) RegisterParticipant(participant *participant.Pa
 }
 
 // Begin 开始一个新的分布式事务
-func (c *TransactionCoordinator) Begin(description string) (string, error) {
+// 事务截止时间取自ctx的截止时间，ctx未设置截止时间时为当前时间加上Timeout；
+// 截止时间随事务记录持久化，后续的准备阶段都受其约束，超过截止时间的事务不能再提交。
+// 参与者登记截止时间为开始时间加上EnlistWindow（不晚于事务截止时间），见 Participant.Register
+func (c *TransactionCoordinator) Begin(ctx context.Context, description string) (string, error) {
 	c.mutex.Lock()
 	defer c.mutex.Unlock()
 
@@ -56,15 +86,27 @@ func (c *TransactionCoordinator) Begin(description string) (string, error) {
 	}
 
 	// 创建事务记录
+	now := time.Now()
+	deadline := now.Add(c.Timeout)
+	if d, ok := ctx.Deadline(); ok {
+		deadline = d
+	}
+	enlistBy := deadline
+	if c.EnlistWindow > 0 && now.Add(c.EnlistWindow).Before(deadline) {
+		enlistBy = now.Add(c.EnlistWindow)
+	}
 	tx := model.Transaction{
-		XID:         xid,
-		Status:      model.StatusCreated,
-		StartTime:   time.Now(),
-		Description: description,
+		XID:           xid,
+		Status:        model.StatusCreated,
+		StartTime:     now,
+		Deadline:      &deadline,
+		EnlistBy:      &enlistBy,
+		Description:   description,
+		CoordinatorID: c.NodeID,
 	}
 
 	// 保存事务记录到数据库
-	if err := txDB.Create(&tx).Error; err != nil {
+	if err := txDB.WithContext(ctx).Create(&tx).Error; err != nil {
 		return "", fmt.Errorf("failed to create transaction record: %w", err)
 	}
 
@@ -72,9 +114,16 @@ func (c *TransactionCoordinator) Begin(description string) (string, error) {
 }
 
 // Prepare 执行事务的准备阶段，所有参与者尝试准备但不提交
-func (c *TransactionCoordinator) Prepare(xid string, participantActions map[string]func(*gorm.DB) error) (bool, error) {
+// 各参与者的操作受ctx和事务截止时间约束，任一到期时尚未完成的参与者准备失败
+func (c *TransactionCoordinator) Prepare(ctx context.Context, xid string, participantActions map[string]func(*gorm.DB) error) (bool, error) {
+	ctx, cancel, err := c.transactionContext(ctx, xid)
+	if err != nil {
+		return false, err
+	}
+	defer cancel()
+
 	// 更新事务状态为准备中
-	if err := c.updateTransactionStatus(xid, model.StatusPreparing); err != nil {
+	if err := c.updateTransactionStatus(ctx, xid, model.StatusPreparing, model.StatusCreated); err != nil {
 		return false, err
 	}
 
@@ -90,7 +139,7 @@ func (c *TransactionCoordinator) Prepare(xid string, participantActions map[stri
 			defer wg.Done()
 
 			// 首先注册参与者
-			_, err := p.Register(c.ServiceName, xid)
+			_, err := p.Register(ctx, c.ServiceName, xid)
 			if err != nil {
 				resultMutex.Lock()
 				prepareResults[p.Name] = model.OperationResult{Success: false, Err: err}
@@ -111,7 +160,7 @@ func (c *TransactionCoordinator) Prepare(xid string, participantActions map[stri
 			}
 
 			// 执行准备操作
-			result, err := p.Prepare(xid, action)
+			result, err := p.Prepare(ctx, xid, action)
 
 			resultMutex.Lock()
 			prepareResults[p.Name] = result
@@ -136,30 +185,54 @@ func (c *TransactionCoordinator) Prepare(xid string, participantActions map[stri
 
 	// 如果所有参与者都准备成功，则更新事务状态为已准备
 	if allPrepared {
-		if err := c.updateTransactionStatus(xid, model.StatusPrepared); err != nil {
+		if err := c.updateTransactionStatus(ctx, xid, model.StatusPrepared, model.StatusPreparing); err != nil {
 			return false, err
 		}
 		return true, nil
 	}
 
-	// 否则，更新事务状态为失败
-	c.updateTransactionStatus(xid, model.StatusFailed)
+	// 否则，更新事务状态为失败（ctx可能已经到期，状态仍然需要记录）
+	c.updateTransactionStatus(context.WithoutCancel(ctx), xid, model.StatusFailed, model.StatusPreparing)
 
 	return false, firstError
 }
 
 // Commit 提交事务，通知所有参与者执行提交操作
-func (c *TransactionCoordinator) Commit(xid string) (bool, error) {
-	// 首先检查事务状态是否为已准备
-	status, err := c.getTransactionStatus(xid)
-	if err != nil {
+// ctx已取消、事务已超过截止时间或已被其他协调者实例接管时拒绝提交（调用方应回滚）；一旦开始通知参与者，
+// 提交阶段不再响应ctx的取消，避免只有部分参与者提交
+func (c *TransactionCoordinator) Commit(ctx context.Context, xid string) (bool, error) {
+	if err := c.checkCommittable(ctx, xid, model.StatusPrepared); err != nil {
 		return false, err
 	}
 
-	if status != model.StatusPrepared {
-		return false, fmt.Errorf("transaction not in prepared state, current status: %s", status)
+	return c.commitParticipants(context.WithoutCancel(ctx), xid, (*participant.Participant).Commit)
+}
+
+// checkCommittable 检查事务是否可以进入提交阶段
+func (c *TransactionCoordinator) checkCommittable(ctx context.Context, xid string, expected model.TransactionStatus) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	transaction, err := c.GetTransaction(ctx, xid)
+	if err != nil {
+		return err
+	}
+	if err := c.checkOwner(transaction); err != nil {
+		return err
+	}
+	if transaction.Status != expected {
+		return fmt.Errorf("transaction not in %s state, current status: %s", expected, transaction.Status)
+	}
+	if transaction.Deadline != nil && time.Now().After(*transaction.Deadline) {
+		return fmt.Errorf("transaction %s passed its deadline %s: %w",
+			xid, transaction.Deadline.Format(time.RFC3339), context.DeadlineExceeded)
 	}
+	return nil
+}
 
+// commitParticipants 通知所有参与者提交并根据结果更新事务状态
+func (c *TransactionCoordinator) commitParticipants(ctx context.Context, xid string, commit func(*participant.Participant, context.Context, string, string) (model.OperationResult, error)) (bool, error) {
 	// 通知所有参与者提交事务
 	var wg sync.WaitGroup
 	commitResults := make(map[string]model.OperationResult)
@@ -172,7 +245,7 @@ func (c *TransactionCoordinator) Commit(xid string) (bool, error) {
 			defer wg.Done()
 
 			// 执行提交
-			result, _ := p.Commit(c.ServiceName, xid)
+			result, _ := commit(p, ctx, c.ServiceName, xid)
 
 			resultMutex.Lock()
 			commitResults[p.Name] = result
@@ -197,35 +270,44 @@ func (c *TransactionCoordinator) Commit(xid string) (bool, error) {
 
 	// 更新事务状态
 	if allCommitted {
-		if err := c.updateTransactionStatus(xid, model.StatusCommitted); err != nil {
+		if err := c.updateTransactionStatus(ctx, xid, model.StatusCommitted, commitFrom...); err != nil {
 			return false, err
 		}
 
 		// 记录完成时间
-		if err := c.recordFinishTime(xid); err != nil {
+		if err := c.recordFinishTime(ctx, xid); err != nil {
 			// 只记录错误，不影响提交结果
 			fmt.Printf("Warning: Failed to record finish time for transaction %s: %v\n", xid, err)
 		}
 
+		// 采集执行后快照（事务调用过TrackSnapshot时）
+		c.captureAfterSnapshot(ctx, xid, model.StatusCommitted)
+
 		return true, nil
 	}
 
 	// 如果有失败，更新事务状态为失败
-	c.updateTransactionStatus(xid, model.StatusFailed)
+	c.updateTransactionStatus(ctx, xid, model.StatusFailed, commitFrom...)
 
 	return false, firstError
 }
 
 // Rollback 回滚事务，通知所有参与者执行回滚操作
-func (c *TransactionCoordinator) Rollback(xid string) (bool, error) {
+// 回滚通常发生在ctx到期或被取消之后，因此不响应ctx的取消，只使用其中的值
+func (c *TransactionCoordinator) Rollback(ctx context.Context, xid string) (bool, error) {
+	ctx = context.WithoutCancel(ctx)
+
 	// 首先获取事务当前状态
-	status, err := c.getTransactionStatus(xid)
+	transaction, err := c.GetTransaction(ctx, xid)
 	if err != nil {
 		return false, err
 	}
+	if err := c.checkOwner(transaction); err != nil {
+		return false, err
+	}
 
 	// 如果事务已经提交，则无法回滚
-	if status == model.StatusCommitted {
+	if transaction.Status == model.StatusCommitted {
 		return false, errors.New("cannot rollback an already committed transaction")
 	}
 
@@ -241,7 +323,7 @@ func (c *TransactionCoordinator) Rollback(xid string) (bool, error) {
 			defer wg.Done()
 
 			// 执行回滚
-			result, _ := p.Rollback(c.ServiceName, xid)
+			result, _ := p.Rollback(ctx, c.ServiceName, xid)
 
 			resultMutex.Lock()
 			rollbackResults[p.Name] = result
@@ -253,28 +335,31 @@ func (c *TransactionCoordinator) Rollback(xid string) (bool, error) {
 	wg.Wait()
 
 	// 更新事务状态为已回滚
-	if err := c.updateTransactionStatus(xid, model.StatusRolledBack); err != nil {
+	if err := c.updateTransactionStatus(ctx, xid, model.StatusRolledBack, rollbackFrom...); err != nil {
 		return false, err
 	}
 
 	// 记录完成时间
-	if err := c.recordFinishTime(xid); err != nil {
+	if err := c.recordFinishTime(ctx, xid); err != nil {
 		// 只记录错误，不影响回滚结果
 		fmt.Printf("Warning: Failed to record finish time for transaction %s: %v\n", xid, err)
 	}
 
+	// 采集执行后快照（事务调用过TrackSnapshot时）
+	c.captureAfterSnapshot(ctx, xid, model.StatusRolledBack)
+
 	return true, nil
 }
 
 // GetTransaction 根据事务ID获取事务详情
-func (c *TransactionCoordinator) GetTransaction(xid string) (*model.Transaction, error) {
+func (c *TransactionCoordinator) GetTransaction(ctx context.Context, xid string) (*model.Transaction, error) {
 	txDB, err := c.DBManager.GetDB(c.ServiceName)
 	if err != nil {
 		return nil, err
 	}
 
 	var transaction model.Transaction
-	if err := txDB.Where("xid = ?", xid).First(&transaction).Error; err != nil {
+	if err := txDB.WithContext(ctx).Where("xid = ?", xid).First(&transaction).Error; err != nil {
 		return nil, err
 	}
 
@@ -282,29 +367,44 @@ func (c *TransactionCoordinator) GetTransaction(xid string) (*model.Transaction,
 }
 
 // GetParticipants 获取事务的所有参与者
-func (c *TransactionCoordinator) GetParticipants(xid string) ([]model.TransactionParticipant, error) {
+func (c *TransactionCoordinator) GetParticipants(ctx context.Context, xid string) ([]model.TransactionParticipant, error) {
 	txDB, err := c.DBManager.GetDB(c.ServiceName)
 	if err != nil {
 		return nil, err
 	}
 
 	var participants []model.TransactionParticipant
-	if err := txDB.Where("xid = ?", xid).Find(&participants).Error; err != nil {
+	if err := txDB.WithContext(ctx).Where("xid = ?", xid).Find(&participants).Error; err != nil {
 		return nil, err
 	}
 
 	return participants, nil
 }
 
-// updateTransactionStatus 更新事务状态
-func (c *TransactionCoordinator) updateTransactionStatus(xid string, status model.TransactionStatus) error {
+// transactionContext 返回受事务截止时间约束的上下文
+func (c *TransactionCoordinator) transactionContext(ctx context.Context, xid string) (context.Context, context.CancelFunc, error) {
+	transaction, err := c.GetTransaction(ctx, xid)
+	if err != nil {
+		return nil, nil, err
+	}
+	if transaction.Deadline == nil {
+		ctx, cancel := context.WithCancel(ctx)
+		return ctx, cancel, nil
+	}
+	ctx, cancel := context.WithDeadline(ctx, *transaction.Deadline)
+	return ctx, cancel, nil
+}
+
+// updateTransactionStatus 以比较并交换的方式更新事务状态：只有事务仍由当前实例负责且当前状态为from之一时才更新。
+// 租约过期后事务被其他实例接管（见 TakeOver），暂停后恢复的原实例因此不能再把事务改为已提交或失败，返回 ErrNotOwner
+func (c *TransactionCoordinator) updateTransactionStatus(ctx context.Context, xid string, status model.TransactionStatus, from ...model.TransactionStatus) error {
 	txDB, err := c.DBManager.GetDB(c.ServiceName)
 	if err != nil {
 		return err
 	}
 
-	result := txDB.Model(&model.Transaction{}).
-		Where("xid = ?", xid).
+	result := txDB.WithContext(ctx).Model(&model.Transaction{}).
+		Where("xid = ? AND coordinator_id = ? AND status IN ?", xid, c.NodeID, from).
 		Update("status", status)
 
 	if result.Error != nil {
@@ -312,32 +412,38 @@ func (c *TransactionCoordinator) updateTransactionStatus(xid string, status mode
 	}
 
 	if result.RowsAffected == 0 {
-		return errors.New("transaction not found")
+		// 区分事务不存在、已被接管和状态已改变
+		transaction, err := c.GetTransaction(ctx, xid)
+		if err != nil {
+			return fmt.Errorf("transaction not found: %w", err)
+		}
+		if err := c.checkOwner(transaction); err != nil {
+			return err
+		}
+		return fmt.Errorf("%w: transaction %s is %s, cannot change to %s", ErrStatusChanged, xid, transaction.Status, status)
 	}
 
 	return nil
 }
 
-// getTransactionStatus 获取事务当前状态
-func (c *TransactionCoordinator) getTransactionStatus(xid string) (model.TransactionStatus, error) {
-	transaction, err := c.GetTransaction(xid)
-	if err != nil {
-		return "", err
+// checkOwner 事务是否仍由当前实例负责
+func (c *TransactionCoordinator) checkOwner(transaction *model.Transaction) error {
+	if transaction.CoordinatorID != c.NodeID {
+		return fmt.Errorf("%w: transaction %s is owned by coordinator %s", ErrNotOwner, transaction.XID, transaction.CoordinatorID)
 	}
-
-	return transaction.Status, nil
+	return nil
 }
 
 // recordFinishTime 记录事务完成时间
-func (c *TransactionCoordinator) recordFinishTime(xid string) error {
+func (c *TransactionCoordinator) recordFinishTime(ctx context.Context, xid string) error {
 	txDB, err := c.DBManager.GetDB(c.ServiceName)
 	if err != nil {
 		return err
 	}
 
 	now := time.Now()
-	result := txDB.Model(&model.Transaction{}).
-		Where("xid = ?", xid).
+	result := txDB.WithContext(ctx).Model(&model.Transaction{}).
+		Where("xid = ? AND coordinator_id = ?", xid, c.NodeID).
 		Update("finish_time", &now)
 
 	return result.Error
@@ -0,0 +1,223 @@
+package coordinator
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+
+	"distribute-tx/internal/model"
+	"distribute-tx/internal/participant"
+)
+
+// activeStatuses 仍需协调者推进的事务状态（包括三阶段提交的中间状态）
+var activeStatuses = []model.TransactionStatus{
+	model.StatusCreated,
+	model.StatusPreparing,
+	model.StatusPrepared,
+	model.StatusCanCommit,
+	model.StatusPreCommit,
+}
+
+// RegisterNode 在协调者数据库中登记当前协调者实例
+func (c *TransactionCoordinator) RegisterNode() error {
+	txDB, err := c.DBManager.GetDB(c.ServiceName)
+	if err != nil {
+		return fmt.Errorf("failed to get coordinator database: %w", err)
+	}
+
+	now := time.Now()
+	node := model.CoordinatorNode{
+		NodeID:        c.NodeID,
+		ServiceName:   c.ServiceName,
+		StartTime:     now,
+		LastHeartbeat: now,
+		Status:        model.CoordinatorAlive,
+	}
+	if err := txDB.Create(&node).Error; err != nil {
+		return fmt.Errorf("failed to register coordinator node: %w", err)
+	}
+
+	return nil
+}
+
+// Heartbeat 刷新当前协调者实例的心跳时间
+func (c *TransactionCoordinator) Heartbeat() error {
+	txDB, err := c.DBManager.GetDB(c.ServiceName)
+	if err != nil {
+		return err
+	}
+
+	result := txDB.Model(&model.CoordinatorNode{}).
+		Where("node_id = ? AND status = ?", c.NodeID, model.CoordinatorAlive).
+		Update("last_heartbeat", time.Now())
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("coordinator node %s is not registered or has been taken over", c.NodeID)
+	}
+
+	return nil
+}
+
+// StartHeartbeat 登记当前实例并在后台定期发送心跳
+func (c *TransactionCoordinator) StartHeartbeat(interval time.Duration) error {
+	if err := c.RegisterNode(); err != nil {
+		return err
+	}
+
+	c.mutex.Lock()
+	if c.heartbeatStop != nil {
+		c.mutex.Unlock()
+		return nil
+	}
+	stop := make(chan struct{})
+	c.heartbeatStop = stop
+	c.mutex.Unlock()
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				if err := c.Heartbeat(); err != nil {
+					log.Printf("Coordinator %s heartbeat failed: %v", c.NodeID, err)
+				}
+			}
+		}
+	}()
+
+	return nil
+}
+
+// StopHeartbeat 停止心跳并将当前实例标记为正常退出
+func (c *TransactionCoordinator) StopHeartbeat() error {
+	c.mutex.Lock()
+	if c.heartbeatStop != nil {
+		close(c.heartbeatStop)
+		c.heartbeatStop = nil
+	}
+	c.mutex.Unlock()
+
+	txDB, err := c.DBManager.GetDB(c.ServiceName)
+	if err != nil {
+		return err
+	}
+
+	return txDB.Model(&model.CoordinatorNode{}).
+		Where("node_id = ? AND status = ?", c.NodeID, model.CoordinatorAlive).
+		Update("status", model.CoordinatorStopped).Error
+}
+
+// ListNodes 获取所有已登记的协调者实例
+func (c *TransactionCoordinator) ListNodes() ([]model.CoordinatorNode, error) {
+	txDB, err := c.DBManager.GetDB(c.ServiceName)
+	if err != nil {
+		return nil, err
+	}
+
+	var nodes []model.CoordinatorNode
+	if err := txDB.Order("start_time").Find(&nodes).Error; err != nil {
+		return nil, err
+	}
+	return nodes, nil
+}
+
+// DetectDeadNodes 查找心跳超过租约时间的存活状态协调者实例
+func (c *TransactionCoordinator) DetectDeadNodes(lease time.Duration) ([]model.CoordinatorNode, error) {
+	txDB, err := c.DBManager.GetDB(c.ServiceName)
+	if err != nil {
+		return nil, err
+	}
+
+	var nodes []model.CoordinatorNode
+	err = txDB.Where("status = ? AND last_heartbeat < ? AND node_id <> ?",
+		model.CoordinatorAlive, time.Now().Add(-lease), c.NodeID).
+		Find(&nodes).Error
+	if err != nil {
+		return nil, err
+	}
+	return nodes, nil
+}
+
+// TakeOver 在租约过期后接管指定协调者实例的在途事务，返回被接管的事务ID
+// 先通过带条件的更新将目标实例标记为死亡，只有成功标记的实例才会被接管，
+// 从而保证多个备用协调者并发检测时只有一个能够接管
+func (c *TransactionCoordinator) TakeOver(deadNodeID string, lease time.Duration) ([]string, error) {
+	txDB, err := c.DBManager.GetDB(c.ServiceName)
+	if err != nil {
+		return nil, err
+	}
+
+	var xids []string
+	err = txDB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&model.CoordinatorNode{}).
+			Where("node_id = ? AND status = ? AND last_heartbeat < ?",
+				deadNodeID, model.CoordinatorAlive, time.Now().Add(-lease)).
+			Updates(map[string]interface{}{
+				"status":        model.CoordinatorDead,
+				"taken_over_by": c.NodeID,
+			})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return fmt.Errorf("coordinator node %s is alive or already taken over", deadNodeID)
+		}
+
+		if err := tx.Model(&model.Transaction{}).
+			Where("coordinator_id = ? AND status IN ?", deadNodeID, activeStatuses).
+			Pluck("xid", &xids).Error; err != nil {
+			return err
+		}
+
+		return tx.Model(&model.Transaction{}).
+			Where("coordinator_id = ? AND status IN ?", deadNodeID, activeStatuses).
+			Update("coordinator_id", c.NodeID).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	log.Printf("Coordinator %s took over %d in-flight transactions from %s", c.NodeID, len(xids), deadNodeID)
+	return xids, nil
+}
+
+// RecoverTransaction 将接管来的在途事务推进到终态，返回事务是否提交。
+// 参与者已准备的分支是资源数据库中的XA事务，原协调者进程退出后仍然保留，接管者通过自己进程中同名的参与者提交或回滚它们：
+//   - 3PC已预提交：所有参与者都已同意，继续通知参与者提交（原进程中的超时自动提交随进程退出而失效）
+//   - 2PC已准备：所有参与者都已投赞成票，原协调者可能已经开始通知提交，继续提交避免结果分裂
+//   - 其他尚未完成准备的状态（包括3PC的CanCommit）：还没有参与者提交，回滚（已准备的分支执行 XA ROLLBACK）
+func (c *TransactionCoordinator) RecoverTransaction(ctx context.Context, xid string) (bool, error) {
+	transaction, err := c.GetTransaction(ctx, xid)
+	if err != nil {
+		return false, err
+	}
+	if transaction.CoordinatorID != c.NodeID {
+		return false, fmt.Errorf("transaction %s is owned by coordinator %s, not %s", xid, transaction.CoordinatorID, c.NodeID)
+	}
+
+	ctx = context.WithoutCancel(ctx)
+	switch transaction.Status {
+	case model.StatusPreCommit:
+		log.Printf("Coordinator %s recovering precommitted transaction %s: committing", c.NodeID, xid)
+		return c.commitParticipants(ctx, xid, (*participant.Participant).DoCommit)
+	case model.StatusPrepared:
+		log.Printf("Coordinator %s recovering prepared transaction %s: committing", c.NodeID, xid)
+		return c.commitParticipants(ctx, xid, (*participant.Participant).Commit)
+	case model.StatusCreated, model.StatusPreparing, model.StatusCanCommit:
+		log.Printf("Coordinator %s recovering %s transaction %s: rolling back", c.NodeID, transaction.Status, xid)
+		if _, err := c.Rollback(ctx, xid); err != nil {
+			return false, err
+		}
+		return false, nil
+	}
+	return false, fmt.Errorf("transaction %s already finished with status %s", xid, transaction.Status)
+}
@@ -0,0 +1,101 @@
+package coordinator
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+)
+
+// NotificationLevel 通知级别
+type NotificationLevel string
+
+// 通知的不同级别
+const (
+	LevelInfo     NotificationLevel = "info"     // 普通信息
+	LevelWarning  NotificationLevel = "warning"  // 警告
+	LevelCritical NotificationLevel = "critical" // 需要人工介入
+)
+
+// Notification 协调者发出的通知事件
+type Notification struct {
+	Level   NotificationLevel      `json:"level"`   // 通知级别
+	Kind    string                 `json:"kind"`    // 事件类型，如 compensation_escalated
+	XID     string                 `json:"xid"`     // 关联的全局事务ID
+	Message string                 `json:"message"` // 描述信息
+	Details map[string]interface{} `json:"details"` // 附加信息
+	Time    time.Time              `json:"time"`    // 发生时间
+}
+
+// Notifier 通知钩子，用于对接告警、IM或工单系统
+type Notifier interface {
+	Notify(n Notification) error
+}
+
+// LogNotifier 将通知输出到日志
+type LogNotifier struct{}
+
+// Notify 实现Notifier接口
+func (LogNotifier) Notify(n Notification) error {
+	log.Printf("[%s] %s xid=%s: %s %v", n.Level, n.Kind, n.XID, n.Message, n.Details)
+	return nil
+}
+
+// WebhookNotifier 以JSON POST的方式将通知发送到指定URL
+type WebhookNotifier struct {
+	URL    string       // 接收通知的URL
+	Client *http.Client // HTTP客户端（为空时使用默认5秒超时的客户端）
+}
+
+// Notify 实现Notifier接口
+func (w *WebhookNotifier) Notify(n Notification) error {
+	data, err := json.Marshal(n)
+	if err != nil {
+		return fmt.Errorf("failed to marshal notification: %w", err)
+	}
+
+	client := w.Client
+	if client == nil {
+		client = &http.Client{Timeout: 5 * time.Second}
+	}
+
+	resp, err := client.Post(w.URL, "application/json", bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("failed to send notification: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("notification webhook returned status: %s", resp.Status)
+	}
+	return nil
+}
+
+// MultiNotifier 将通知分发给多个通知钩子
+type MultiNotifier []Notifier
+
+// Notify 实现Notifier接口，返回最后一个错误
+func (m MultiNotifier) Notify(n Notification) error {
+	var lastErr error
+	for _, notifier := range m {
+		if err := notifier.Notify(n); err != nil {
+			lastErr = err
+		}
+	}
+	return lastErr
+}
+
+// notify 发送通知，失败时只记录日志
+func (c *TransactionCoordinator) notify(n Notification) {
+	if c.Notifier == nil {
+		return
+	}
+	if n.Time.IsZero() {
+		n.Time = time.Now()
+	}
+	if err := c.Notifier.Notify(n); err != nil {
+		log.Printf("Failed to deliver %s notification for transaction %s: %v", n.Kind, n.XID, err)
+	}
+}
@@ -0,0 +1,213 @@
+package coordinator
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+
+	"distribute-tx/internal/model"
+)
+
+// snapshotSource 一张可采集快照的业务表
+type snapshotSource struct {
+	service string                                          // 业务服务名称（数据库连接名）
+	table   string                                          // 业务表名
+	keys    func(model.SnapshotScope) []string              // 从范围中取出采集条件的取值
+	load    func(tx *gorm.DB, key string) (any, int, error) // 读取匹配行，返回行和行数
+}
+
+// snapshotSources 支持快照的业务表
+var snapshotSources = []snapshotSource{
+	{
+		service: "order_service", table: "orders",
+		keys: func(s model.SnapshotScope) []string { return s.OrderNos },
+		load: loadRows[model.Order]("order_no"),
+	},
+	{
+		service: "inventory_service", table: "inventory",
+		keys: func(s model.SnapshotScope) []string { return s.ProductIDs },
+		load: loadRows[model.Inventory]("product_id"),
+	},
+	{
+		service: "payment_service", table: "payment_records",
+		keys: func(s model.SnapshotScope) []string { return s.OrderNos },
+		load: loadRows[model.PaymentRecord]("order_no"),
+	},
+	{
+		service: "account_service", table: "accounts",
+		keys: func(s model.SnapshotScope) []string { return s.UserIDs },
+		load: loadRows[model.Account]("user_id"),
+	},
+}
+
+// loadRows 返回按指定列读取某类业务行的函数，包含软删除的行
+func loadRows[T any](column string) func(tx *gorm.DB, key string) (any, int, error) {
+	return func(tx *gorm.DB, key string) (any, int, error) {
+		var rows []T
+		if err := tx.Unscoped().Where(column+" = ?", key).Order("id").Find(&rows).Error; err != nil {
+			return nil, 0, err
+		}
+		return rows, len(rows), nil
+	}
+}
+
+// SnapshotRow 一组业务行在事务前后的对比
+type SnapshotRow struct {
+	Service string          `json:"service"` // 业务服务名称
+	Table   string          `json:"table"`   // 业务表名
+	RowKey  string          `json:"row_key"` // 采集条件的取值
+	Before  json.RawMessage `json:"before"`  // 事务前的行（JSON数组）
+	After   json.RawMessage `json:"after"`   // 事务后的行（JSON数组），事务未结束时为空
+	Changed bool            `json:"changed"` // 前后是否不同
+}
+
+// SnapshotReport 全局事务的前后快照报告
+type SnapshotReport struct {
+	XID     string                  `json:"xid"`     // 全局事务ID
+	Outcome model.TransactionStatus `json:"outcome"` // 事务结果，事务未结束时为空
+	Rows    []SnapshotRow           `json:"rows"`    // 各业务行的前后对比
+}
+
+// TrackSnapshot 采集事务涉及业务行的执行前快照，应在Prepare之前调用
+// 事务提交或回滚后协调者会按同样的范围自动采集执行后快照
+func (c *TransactionCoordinator) TrackSnapshot(ctx context.Context, xid string, scope model.SnapshotScope) error {
+	targets := make(map[int][]string)
+	for i, src := range snapshotSources {
+		if keys := src.keys(scope); len(keys) > 0 {
+			targets[i] = keys
+		}
+	}
+	return c.captureSnapshot(ctx, xid, model.SnapshotBefore, "", targets)
+}
+
+// captureAfterSnapshot 按执行前快照的范围采集执行后快照，未跟踪的事务直接返回
+func (c *TransactionCoordinator) captureAfterSnapshot(ctx context.Context, xid string, outcome model.TransactionStatus) {
+	txDB, err := c.DBManager.GetDB(c.ServiceName)
+	if err != nil {
+		log.Printf("Failed to capture snapshot for transaction %s: %v", xid, err)
+		return
+	}
+
+	var before []model.TransactionSnapshot
+	if err := txDB.WithContext(ctx).Where("xid = ? AND stage = ?", xid, model.SnapshotBefore).Find(&before).Error; err != nil {
+		log.Printf("Failed to load snapshot scope for transaction %s: %v", xid, err)
+		return
+	}
+	if len(before) == 0 {
+		return
+	}
+
+	targets := make(map[int][]string)
+	for _, s := range before {
+		for i, src := range snapshotSources {
+			if src.service == s.Service && src.table == s.Table {
+				targets[i] = append(targets[i], s.RowKey)
+			}
+		}
+	}
+	if err := c.captureSnapshot(ctx, xid, model.SnapshotAfter, outcome, targets); err != nil {
+		log.Printf("Failed to capture after snapshot for transaction %s: %v", xid, err)
+	}
+}
+
+// captureSnapshot 读取各业务表的匹配行并写入快照表
+// 每个服务在一个 REPEATABLE READ 只读事务中读取，保证同一服务内各行来自同一个一致性视图
+func (c *TransactionCoordinator) captureSnapshot(ctx context.Context, xid string, stage model.SnapshotStage, outcome model.TransactionStatus, targets map[int][]string) error {
+	txDB, err := c.DBManager.GetDB(c.ServiceName)
+	if err != nil {
+		return fmt.Errorf("failed to get coordinator database: %w", err)
+	}
+
+	var snapshots []model.TransactionSnapshot
+	for i, keys := range targets {
+		src := snapshotSources[i]
+		serviceDB, err := c.DBManager.GetDB(src.service)
+		if err != nil {
+			// 示例中并非所有业务服务都会连接，跳过未连接的服务
+			continue
+		}
+
+		err = serviceDB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+			for _, key := range keys {
+				rows, count, err := src.load(tx, key)
+				if err != nil {
+					return err
+				}
+				data, err := json.Marshal(rows)
+				if err != nil {
+					return err
+				}
+				snapshots = append(snapshots, model.TransactionSnapshot{
+					XID:        xid,
+					Stage:      stage,
+					Outcome:    outcome,
+					Service:    src.service,
+					Table:      src.table,
+					RowKey:     key,
+					RowCount:   count,
+					Data:       string(data),
+					CapturedAt: time.Now(),
+				})
+			}
+			return nil
+		}, &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true})
+		if err != nil {
+			return fmt.Errorf("failed to read %s.%s: %w", src.service, src.table, err)
+		}
+	}
+
+	if len(snapshots) == 0 {
+		return nil
+	}
+	return txDB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// 重复采集（例如重试提交）时覆盖同一时机的旧快照
+		if err := tx.Where("xid = ? AND stage = ?", xid, stage).Delete(&model.TransactionSnapshot{}).Error; err != nil {
+			return err
+		}
+		return tx.Create(&snapshots).Error
+	})
+}
+
+// GetSnapshot 获取全局事务的前后快照对比
+func (c *TransactionCoordinator) GetSnapshot(ctx context.Context, xid string) (*SnapshotReport, error) {
+	txDB, err := c.DBManager.GetDB(c.ServiceName)
+	if err != nil {
+		return nil, err
+	}
+
+	var snapshots []model.TransactionSnapshot
+	if err := txDB.WithContext(ctx).Where("xid = ?", xid).Order("id").Find(&snapshots).Error; err != nil {
+		return nil, err
+	}
+
+	report := &SnapshotReport{XID: xid, Rows: []SnapshotRow{}}
+	index := make(map[string]int)
+	for _, s := range snapshots {
+		key := s.Service + "/" + s.Table + "/" + s.RowKey
+		i, ok := index[key]
+		if !ok {
+			report.Rows = append(report.Rows, SnapshotRow{Service: s.Service, Table: s.Table, RowKey: s.RowKey})
+			i = len(report.Rows) - 1
+			index[key] = i
+		}
+
+		switch s.Stage {
+		case model.SnapshotBefore:
+			report.Rows[i].Before = json.RawMessage(s.Data)
+		case model.SnapshotAfter:
+			report.Rows[i].After = json.RawMessage(s.Data)
+			report.Outcome = s.Outcome
+		}
+	}
+	for i := range report.Rows {
+		row := &report.Rows[i]
+		row.Changed = row.After != nil && string(row.Before) != string(row.After)
+	}
+
+	return report, nil
+}
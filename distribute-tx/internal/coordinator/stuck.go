@@ -0,0 +1,276 @@
+package coordinator
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"time"
+
+	"distribute-tx/internal/model"
+)
+
+// StuckThresholds 各状态下事务停留的最长时间，超过即视为卡住的长事务；未列出的状态不检查
+type StuckThresholds map[model.TransactionStatus]time.Duration
+
+// DefaultStuckThresholds 默认阈值：准备阶段应在秒级完成，已准备（持有参与者本地事务和行锁）的事务等待决定的时间更需要关注
+var DefaultStuckThresholds = StuckThresholds{
+	model.StatusCreated:   2 * time.Minute,
+	model.StatusPreparing: 30 * time.Second,
+	model.StatusPrepared:  time.Minute,
+	model.StatusCanCommit: 30 * time.Second,
+	model.StatusPreCommit: time.Minute,
+}
+
+// lockHoldingStatuses 参与者的本地事务已执行业务操作、仍持有行锁的事务状态
+var lockHoldingStatuses = map[model.TransactionStatus]bool{
+	model.StatusPreparing: true,
+	model.StatusPrepared:  true,
+	model.StatusPreCommit: true,
+}
+
+// StuckParticipant 卡住的事务中的一个参与者
+type StuckParticipant struct {
+	Name       string                  `json:"name"`        // 参与者名称
+	ResourceID string                  `json:"resource_id"` // 资源标识（数据库连接名）
+	Status     model.ParticipantStatus `json:"status"`      // 参与者状态
+	UpdatedAt  time.Time               `json:"updated_at"`  // 参与者状态最后变化时间
+}
+
+// StuckTransaction 超过阈值的长事务
+type StuckTransaction struct {
+	XID           string                  `json:"xid"`                // 全局事务ID
+	Status        model.TransactionStatus `json:"status"`             // 当前状态
+	Description   string                  `json:"description"`        // 事务描述
+	CoordinatorID string                  `json:"coordinator_id"`     // 负责的协调者实例
+	StartTime     time.Time               `json:"start_time"`         // 事务开始时间
+	InStatusSince time.Time               `json:"in_status_since"`    // 进入当前状态的时间
+	AgeSeconds    float64                 `json:"age_seconds"`        // 从开始到现在的时长
+	StuckSeconds  float64                 `json:"stuck_seconds"`      // 停留在当前状态的时长
+	Threshold     string                  `json:"threshold"`          // 当前状态的阈值
+	Deadline      *time.Time              `json:"deadline,omitempty"` // 事务截止时间
+	PastDeadline  bool                    `json:"past_deadline"`      // 是否已超过截止时间（不能再提交）
+	Participants  []StuckParticipant      `json:"participants"`       // 参与者
+	HeldResources []string                `json:"held_resources"`     // 可能仍被锁住的资源（资源库及快照范围内的业务行）
+	Alerted       bool                    `json:"alerted"`            // 监控是否已就当前状态发出告警
+	Error         string                  `json:"error,omitempty"`    // 组装报告时的错误（如读取参与者失败）
+}
+
+// StuckReport 长事务报告
+type StuckReport struct {
+	GeneratedAt  time.Time          `json:"generated_at"` // 生成时间
+	Thresholds   map[string]string  `json:"thresholds"`   // 生效的阈值
+	Transactions []StuckTransaction `json:"transactions"` // 卡住的事务，按停留时长从长到短排列
+}
+
+// stuckThresholds 返回生效的阈值
+func (c *TransactionCoordinator) stuckThresholds() StuckThresholds {
+	if c.StuckThresholds != nil {
+		return c.StuckThresholds
+	}
+	return DefaultStuckThresholds
+}
+
+// FindStuckTransactions 扫描协调者数据库中停留在某个状态超过阈值的事务，组装参与者和持有资源的报告
+// 停留时长按事务记录的最后更新时间计算（状态变化会刷新该时间）
+func (c *TransactionCoordinator) FindStuckTransactions(ctx context.Context) (*StuckReport, error) {
+	txDB, err := c.DBManager.GetDB(c.ServiceName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get coordinator database: %w", err)
+	}
+
+	now := time.Now()
+	thresholds := c.stuckThresholds()
+	report := &StuckReport{GeneratedAt: now, Thresholds: make(map[string]string), Transactions: []StuckTransaction{}}
+
+	for status, threshold := range thresholds {
+		report.Thresholds[string(status)] = threshold.String()
+
+		var transactions []model.Transaction
+		if err := txDB.WithContext(ctx).
+			Where("status = ? AND updated_at < ?", status, now.Add(-threshold)).
+			Find(&transactions).Error; err != nil {
+			return nil, fmt.Errorf("failed to scan %s transactions: %w", status, err)
+		}
+		for _, t := range transactions {
+			report.Transactions = append(report.Transactions, c.describeStuck(ctx, t, threshold, now))
+		}
+	}
+
+	c.mutex.Lock()
+	for i := range report.Transactions {
+		t := &report.Transactions[i]
+		t.Alerted = c.stuckAlerted[t.XID] == t.Status
+	}
+	c.mutex.Unlock()
+
+	sort.Slice(report.Transactions, func(i, j int) bool {
+		return report.Transactions[i].StuckSeconds > report.Transactions[j].StuckSeconds
+	})
+	return report, nil
+}
+
+// describeStuck 组装单个卡住事务的报告条目
+func (c *TransactionCoordinator) describeStuck(ctx context.Context, t model.Transaction, threshold time.Duration, now time.Time) StuckTransaction {
+	stuck := StuckTransaction{
+		XID:           t.XID,
+		Status:        t.Status,
+		Description:   t.Description,
+		CoordinatorID: t.CoordinatorID,
+		StartTime:     t.StartTime,
+		InStatusSince: t.UpdatedAt,
+		AgeSeconds:    now.Sub(t.StartTime).Seconds(),
+		StuckSeconds:  now.Sub(t.UpdatedAt).Seconds(),
+		Threshold:     threshold.String(),
+		Deadline:      t.Deadline,
+		PastDeadline:  t.Deadline != nil && now.After(*t.Deadline),
+		Participants:  []StuckParticipant{},
+		HeldResources: []string{},
+	}
+
+	participants, err := c.GetParticipants(ctx, t.XID)
+	if err != nil {
+		stuck.Error = fmt.Sprintf("failed to load participants: %v", err)
+		return stuck
+	}
+	for _, p := range participants {
+		stuck.Participants = append(stuck.Participants, StuckParticipant{
+			Name:       p.Name,
+			ResourceID: p.ResourceID,
+			Status:     p.Status,
+			UpdatedAt:  p.UpdatedAt,
+		})
+		if lockHoldingStatuses[t.Status] && isOpenBranch(p.Status) {
+			stuck.HeldResources = append(stuck.HeldResources, "database:"+p.ResourceID)
+		}
+	}
+
+	// 快照范围记录了事务涉及的业务行，持有锁的事务中这些行可能被锁住
+	if lockHoldingStatuses[t.Status] && len(stuck.HeldResources) > 0 {
+		rows, err := c.snapshotRows(ctx, t.XID)
+		if err != nil {
+			stuck.Error = fmt.Sprintf("failed to load snapshot scope: %v", err)
+			return stuck
+		}
+		stuck.HeldResources = append(stuck.HeldResources, rows...)
+	}
+	return stuck
+}
+
+// isOpenBranch 参与者的本地事务是否可能仍未结束
+func isOpenBranch(status model.ParticipantStatus) bool {
+	switch status {
+	case model.ParticipantRegistered, model.ParticipantPrepared, model.ParticipantCanCommit, model.ParticipantPreCommit:
+		return true
+	}
+	return false
+}
+
+// snapshotRows 返回事务前快照中记录的业务行，格式为 service/table:key
+func (c *TransactionCoordinator) snapshotRows(ctx context.Context, xid string) ([]string, error) {
+	txDB, err := c.DBManager.GetDB(c.ServiceName)
+	if err != nil {
+		return nil, err
+	}
+
+	var snapshots []model.TransactionSnapshot
+	if err := txDB.WithContext(ctx).
+		Where("xid = ? AND stage = ?", xid, model.SnapshotBefore).
+		Order("id").Find(&snapshots).Error; err != nil {
+		return nil, err
+	}
+
+	rows := make([]string, 0, len(snapshots))
+	for _, s := range snapshots {
+		rows = append(rows, fmt.Sprintf("%s/%s:%s", s.Service, s.Table, s.RowKey))
+	}
+	return rows, nil
+}
+
+// CheckStuckTransactions 扫描一次长事务，对新发现的（或进入新状态后再次卡住的）事务发出告警，返回报告
+// 持有行锁的状态以及已超过截止时间的事务发出 critical 级别告警，其余为 warning
+func (c *TransactionCoordinator) CheckStuckTransactions(ctx context.Context) (*StuckReport, error) {
+	report, err := c.FindStuckTransactions(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	current := make(map[string]model.TransactionStatus, len(report.Transactions))
+	var alerts []StuckTransaction
+
+	c.mutex.Lock()
+	for i := range report.Transactions {
+		t := &report.Transactions[i]
+		current[t.XID] = t.Status
+		if c.stuckAlerted[t.XID] != t.Status {
+			alerts = append(alerts, *t)
+			t.Alerted = true
+		}
+	}
+	// 已经结束或推进到下一状态的事务不再跟踪，之后再次卡住时重新告警
+	c.stuckAlerted = current
+	c.mutex.Unlock()
+
+	for _, t := range alerts {
+		level := LevelWarning
+		if lockHoldingStatuses[t.Status] || t.PastDeadline {
+			level = LevelCritical
+		}
+		c.notify(Notification{
+			Level: level,
+			Kind:  "transaction_stuck",
+			XID:   t.XID,
+			Message: fmt.Sprintf("transaction has been %s for %.0fs (threshold %s)",
+				t.Status, t.StuckSeconds, t.Threshold),
+			Details: map[string]interface{}{
+				"status":         t.Status,
+				"coordinator_id": t.CoordinatorID,
+				"age_seconds":    t.AgeSeconds,
+				"past_deadline":  t.PastDeadline,
+				"participants":   t.Participants,
+				"held_resources": t.HeldResources,
+			},
+		})
+	}
+	return report, nil
+}
+
+// StartStuckMonitor 在后台按间隔扫描长事务并发出告警
+func (c *TransactionCoordinator) StartStuckMonitor(interval time.Duration) {
+	c.mutex.Lock()
+	if c.stuckStop != nil {
+		c.mutex.Unlock()
+		return
+	}
+	stop := make(chan struct{})
+	c.stuckStop = stop
+	c.mutex.Unlock()
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				ctx, cancel := context.WithTimeout(context.Background(), interval)
+				if _, err := c.CheckStuckTransactions(ctx); err != nil {
+					log.Printf("Coordinator %s stuck transaction scan failed: %v", c.NodeID, err)
+				}
+				cancel()
+			}
+		}
+	}()
+}
+
+// StopStuckMonitor 停止长事务监控
+func (c *TransactionCoordinator) StopStuckMonitor() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.stuckStop != nil {
+		close(c.stuckStop)
+		c.stuckStop = nil
+	}
+}
@@ -0,0 +1,158 @@
+package coordinator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+
+	"distribute-tx/internal/model"
+	"distribute-tx/internal/participant"
+)
+
+// ThreePhaseTimeouts 三阶段提交各阶段的超时时间
+type ThreePhaseTimeouts struct {
+	CanCommit time.Duration // 等待参与者CanCommit投票的超时
+	PreCommit time.Duration // 等待参与者PreCommit确认的超时
+	DoCommit  time.Duration // 参与者预提交后等待最终决定的超时，超时后参与者默认提交
+}
+
+// DefaultThreePhaseTimeouts 默认的三阶段提交超时
+var DefaultThreePhaseTimeouts = ThreePhaseTimeouts{
+	CanCommit: 2 * time.Second,
+	PreCommit: 5 * time.Second,
+	DoCommit:  10 * time.Second,
+}
+
+// ErrPhaseTimeout 参与者未在阶段超时内响应
+var ErrPhaseTimeout = errors.New("participant did not respond within phase timeout")
+
+// CanCommit 3PC第一阶段：询问所有参与者能否提交，参与者只做检查，不持有锁
+// 任一参与者投反对票或超时未响应，事务直接中止（此时参与者没有任何需要回滚的修改）
+func (c *TransactionCoordinator) CanCommit(ctx context.Context, xid string, checks map[string]func(*gorm.DB) error) (bool, error) {
+	ctx, cancel, err := c.transactionContext(ctx, xid)
+	if err != nil {
+		return false, err
+	}
+	defer cancel()
+
+	if err := c.updateTransactionStatus(ctx, xid, model.StatusPreparing, model.StatusCreated); err != nil {
+		return false, err
+	}
+
+	err = c.runPhase(ctx, c.ThreePhase.CanCommit, func(ctx context.Context, p *participant.Participant) (model.OperationResult, error) {
+		if result, err := p.Register(ctx, c.ServiceName, xid); err != nil {
+			return result, err
+		}
+		check, exists := checks[p.Name]
+		if !exists {
+			return model.OperationResult{}, errors.New("no check defined for participant")
+		}
+		return p.CanCommit(ctx, c.ServiceName, xid, check)
+	})
+	if err != nil {
+		ctx := context.WithoutCancel(ctx)
+		c.updateTransactionStatus(ctx, xid, model.StatusRolledBack, model.StatusPreparing)
+		c.recordFinishTime(ctx, xid)
+		return false, fmt.Errorf("can-commit phase aborted: %w", err)
+	}
+
+	if err := c.updateTransactionStatus(ctx, xid, model.StatusCanCommit, model.StatusPreparing); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// PreCommit 3PC第二阶段：所有参与者执行操作但不提交，并各自启动超时自动提交定时器
+// 任一参与者失败或超时，协调者中止事务并通知所有参与者回滚
+func (c *TransactionCoordinator) PreCommit(ctx context.Context, xid string, actions map[string]func(*gorm.DB) error) (bool, error) {
+	if err := c.checkCommittable(ctx, xid, model.StatusCanCommit); err != nil {
+		return false, err
+	}
+	ctx, cancel, err := c.transactionContext(ctx, xid)
+	if err != nil {
+		return false, err
+	}
+	defer cancel()
+
+	err = c.runPhase(ctx, c.ThreePhase.PreCommit, func(ctx context.Context, p *participant.Participant) (model.OperationResult, error) {
+		action, exists := actions[p.Name]
+		if !exists {
+			return model.OperationResult{}, errors.New("no action defined for participant")
+		}
+		return p.PreCommit(ctx, c.ServiceName, xid, action, c.ThreePhase.DoCommit)
+	})
+	if err != nil {
+		c.Abort(ctx, xid)
+		return false, fmt.Errorf("pre-commit phase aborted: %w", err)
+	}
+
+	if err := c.updateTransactionStatus(ctx, xid, model.StatusPreCommit, model.StatusCanCommit); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// DoCommit 3PC第三阶段：通知所有参与者提交
+// 所有参与者都已预提交，即使超过截止时间参与者也会自行提交，因此这里不再检查截止时间；
+// 事务已被其他协调者实例接管时由接管者推进，返回 ErrNotOwner
+func (c *TransactionCoordinator) DoCommit(ctx context.Context, xid string) (bool, error) {
+	transaction, err := c.GetTransaction(ctx, xid)
+	if err != nil {
+		return false, err
+	}
+	if err := c.checkOwner(transaction); err != nil {
+		return false, err
+	}
+	if transaction.Status != model.StatusPreCommit {
+		return false, fmt.Errorf("transaction not in precommit state, current status: %s", transaction.Status)
+	}
+
+	return c.commitParticipants(context.WithoutCancel(ctx), xid, (*participant.Participant).DoCommit)
+}
+
+// Abort 中止三阶段提交事务，回滚已预提交的参与者
+func (c *TransactionCoordinator) Abort(ctx context.Context, xid string) (bool, error) {
+	return c.Rollback(ctx, xid)
+}
+
+// runPhase 并发执行一个阶段的参与者操作，任一参与者失败或超过阶段超时则返回错误
+// 阶段超时通过派生上下文传给参与者，参与者的语句会在超时后被取消；协调者仍等待全部返回，
+// 超时后才返回成功的结果也按失败处理，避免中止之后才完成预提交的参与者在定时器到期时自行提交
+func (c *TransactionCoordinator) runPhase(ctx context.Context, timeout time.Duration, op func(context.Context, *participant.Participant) (model.OperationResult, error)) error {
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	var firstErr error
+
+	phaseCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	for _, p := range c.Participants {
+		wg.Add(1)
+
+		go func(p *participant.Participant) {
+			defer wg.Done()
+
+			_, err := op(phaseCtx, p)
+			if err == nil && phaseCtx.Err() != nil {
+				err = ErrPhaseTimeout
+				if ctx.Err() != nil {
+					err = ctx.Err()
+				}
+			}
+			if err != nil {
+				mu.Lock()
+				if firstErr == nil {
+					firstErr = fmt.Errorf("participant %s: %w", p.Name, err)
+				}
+				mu.Unlock()
+			}
+		}(p)
+	}
+
+	wg.Wait()
+	return firstErr
+}
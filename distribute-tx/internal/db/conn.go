@@ -3,7 +3,7 @@ package db
 import (
 	"distribute-tx/internal/config"
 	"fmt"
-	"time"
+	"sync"
 
 	"gorm.io/driver/mysql"
 	"gorm.io/gorm"
@@ -14,21 +14,26 @@ import (
 
 // DBConnectionManager 管理分布式事务中的多个数据库连接
 type DBConnectionManager struct {
-	DBs map[string]*gorm.DB // 数据库连接映射，键为服务名称
+	DBs     map[string]*gorm.DB          // 数据库连接映射，键为服务名称
+	pools   map[string]config.PoolConfig // 各服务生效的连接池配置
+	monitor *poolMonitor                 // 连接池饱和监控（可选），见 StartPoolMonitor
+	mu      sync.RWMutex                 // 保护连接映射和连接池配置
 }
 
 // NewDBConnectionManager 创建新的数据库连接管理器
 func NewDBConnectionManager() *DBConnectionManager {
 	return &DBConnectionManager{
-		DBs: make(map[string]*gorm.DB),
+		DBs:   make(map[string]*gorm.DB),
+		pools: make(map[string]config.PoolConfig),
 	}
 }
 
 // ConnectDB 连接到指定的数据库并将其添加到管理器中
-func (m *DBConnectionManager) ConnectDB(serviceName string, config config.DBConfig) error {
+// 连接池按服务单独配置，见 config.ResolvePoolConfig
+func (m *DBConnectionManager) ConnectDB(serviceName string, dbConfig config.DBConfig) error {
 	// 构建DSN连接字符串
 	dsn := fmt.Sprintf("%s:%s@tcp(%s:%d)/%s?charset=utf8mb4&parseTime=True&loc=Local",
-		config.User, config.Password, config.Host, config.Port, config.DBName)
+		dbConfig.User, dbConfig.Password, dbConfig.Host, dbConfig.Port, dbConfig.DBName)
 
 	// 配置GORM
 	gormConfig := &gorm.Config{
@@ -47,19 +52,26 @@ func (m *DBConnectionManager) ConnectDB(serviceName string, config config.DBConf
 		return fmt.Errorf("failed to get database connection: %w", err)
 	}
 
-	sqlDB.SetMaxIdleConns(10)
-	sqlDB.SetMaxOpenConns(100)
-	sqlDB.SetConnMaxLifetime(time.Hour)
+	pool := config.ResolvePoolConfig(serviceName, dbConfig.Pool)
+	sqlDB.SetMaxIdleConns(pool.MaxIdleConns)
+	sqlDB.SetMaxOpenConns(pool.MaxOpenConns)
+	sqlDB.SetConnMaxLifetime(pool.ConnMaxLifetime)
+	sqlDB.SetConnMaxIdleTime(pool.ConnMaxIdleTime)
 
 	// 保存连接
+	m.mu.Lock()
 	m.DBs[serviceName] = db
+	m.pools[serviceName] = pool
+	m.mu.Unlock()
 
 	return nil
 }
 
 // GetDB 获取指定服务名称的数据库连接
 func (m *DBConnectionManager) GetDB(serviceName string) (*gorm.DB, error) {
+	m.mu.RLock()
 	db, exists := m.DBs[serviceName]
+	m.mu.RUnlock()
 	if !exists {
 		return nil, fmt.Errorf("no database connection found for service: %s", serviceName)
 	}
@@ -74,7 +86,7 @@ func (m *DBConnectionManager) InitTransactionTables(coordinatorService string) e
 	}
 
 	// 自动创建事务相关表
-	if err := db.AutoMigrate(&model.Transaction{}, &model.TransactionParticipant{}); err != nil {
+	if err := db.AutoMigrate(&model.Transaction{}, &model.TransactionParticipant{}, &model.CoordinatorNode{}, &model.CompensationEscalation{}, &model.TransactionSnapshot{}); err != nil {
 		return fmt.Errorf("failed to create transaction tables: %w", err)
 	}
 
@@ -130,6 +142,8 @@ func (m *DBConnectionManager) BeginTx(serviceName string) (*gorm.DB, error) {
 
 // Close 关闭所有数据库连接
 func (m *DBConnectionManager) Close() error {
+	m.StopPoolMonitor()
+
 	var lastErr error
 	for serviceName, db := range m.DBs {
 		sqlDB, err := db.DB()
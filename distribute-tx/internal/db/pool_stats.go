@@ -0,0 +1,142 @@
+package db
+
+import (
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"distribute-tx/internal/config"
+)
+
+// 连接池饱和告警参数
+const (
+	poolSaturationRatio    = 0.9         // 使用中的连接数达到最大打开连接数的该比例时视为饱和
+	poolSaturationCooldown = time.Minute // 同一服务两次告警的最小间隔
+)
+
+// PoolStats 单个服务的连接池使用统计
+type PoolStats struct {
+	Service           string            `json:"service"`
+	Config            config.PoolConfig `json:"config"`               // 生效的连接池配置
+	Open              int               `json:"open"`                 // 当前打开的连接数
+	InUse             int               `json:"in_use"`               // 使用中的连接数
+	Idle              int               `json:"idle"`                 // 空闲连接数
+	WaitCount         int64             `json:"wait_count"`           // 累计等待连接的次数
+	WaitDurationMs    float64           `json:"wait_duration_ms"`     // 累计等待连接的时间(毫秒)
+	MaxIdleClosed     int64             `json:"max_idle_closed"`      // 因超过最大空闲数关闭的连接数
+	MaxIdleTimeClosed int64             `json:"max_idle_time_closed"` // 因空闲超时关闭的连接数
+	MaxLifetimeClosed int64             `json:"max_lifetime_closed"`  // 因超过最长存活时间关闭的连接数
+	Utilization       float64           `json:"utilization"`          // 使用中的连接数占最大打开连接数的比例
+	Saturated         bool              `json:"saturated"`            // 是否达到饱和阈值
+}
+
+// PoolStats 获取所有服务的连接池统计（按服务名排序）
+func (m *DBConnectionManager) PoolStats() []PoolStats {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	stats := make([]PoolStats, 0, len(m.DBs))
+	for serviceName, db := range m.DBs {
+		sqlDB, err := db.DB()
+		if err != nil {
+			continue
+		}
+		s := sqlDB.Stats()
+		pool := m.pools[serviceName]
+		stat := PoolStats{
+			Service:           serviceName,
+			Config:            pool,
+			Open:              s.OpenConnections,
+			InUse:             s.InUse,
+			Idle:              s.Idle,
+			WaitCount:         s.WaitCount,
+			WaitDurationMs:    float64(s.WaitDuration.Microseconds()) / 1000,
+			MaxIdleClosed:     s.MaxIdleClosed,
+			MaxIdleTimeClosed: s.MaxIdleTimeClosed,
+			MaxLifetimeClosed: s.MaxLifetimeClosed,
+		}
+		if s.MaxOpenConnections > 0 {
+			stat.Utilization = float64(s.InUse) / float64(s.MaxOpenConnections)
+			stat.Saturated = stat.Utilization >= poolSaturationRatio
+		}
+		stats = append(stats, stat)
+	}
+	sort.Slice(stats, func(i, j int) bool { return stats[i].Service < stats[j].Service })
+	return stats
+}
+
+// poolMonitor 定期检查连接池，在连接池饱和或出现等待连接时输出告警
+type poolMonitor struct {
+	interval  time.Duration
+	lastWaits map[string]int64     // 上一次检查时各服务的累计等待次数
+	lastWarn  map[string]time.Time // 各服务最近一次告警时间
+	stopChan  chan struct{}
+	wg        sync.WaitGroup
+}
+
+// StartPoolMonitor 开始按interval检查各服务的连接池，饱和或有请求等待连接时输出告警
+// 重复调用时忽略，Close时自动停止
+func (m *DBConnectionManager) StartPoolMonitor(interval time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.monitor != nil {
+		return
+	}
+
+	monitor := &poolMonitor{
+		interval:  interval,
+		lastWaits: make(map[string]int64),
+		lastWarn:  make(map[string]time.Time),
+		stopChan:  make(chan struct{}),
+	}
+	m.monitor = monitor
+
+	monitor.wg.Add(1)
+	go func() {
+		defer monitor.wg.Done()
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-monitor.stopChan:
+				return
+			case <-ticker.C:
+				monitor.check(m.PoolStats())
+			}
+		}
+	}()
+}
+
+// StopPoolMonitor 停止连接池监控
+func (m *DBConnectionManager) StopPoolMonitor() {
+	m.mu.Lock()
+	monitor := m.monitor
+	m.monitor = nil
+	m.mu.Unlock()
+
+	if monitor != nil {
+		close(monitor.stopChan)
+		monitor.wg.Wait()
+	}
+}
+
+// check 根据一次统计输出饱和告警
+func (pm *poolMonitor) check(stats []PoolStats) {
+	now := time.Now()
+	for _, s := range stats {
+		waits := s.WaitCount - pm.lastWaits[s.Service]
+		pm.lastWaits[s.Service] = s.WaitCount
+
+		if !s.Saturated && waits <= 0 {
+			continue
+		}
+		if now.Sub(pm.lastWarn[s.Service]) < poolSaturationCooldown {
+			continue
+		}
+		pm.lastWarn[s.Service] = now
+		log.Printf("Warning: connection pool of %s saturated: %d/%d connections in use, %d requests waited for a connection in the last %v",
+			s.Service, s.InUse, s.Config.MaxOpenConns, waits, pm.interval)
+	}
+}
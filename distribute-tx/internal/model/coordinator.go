@@ -0,0 +1,33 @@
+package model
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// CoordinatorNodeStatus 表示协调者节点的状态
+type CoordinatorNodeStatus string
+
+// 协调者节点的不同状态
+const (
+	CoordinatorAlive   CoordinatorNodeStatus = "alive"   // 节点存活
+	CoordinatorStopped CoordinatorNodeStatus = "stopped" // 节点正常退出
+	CoordinatorDead    CoordinatorNodeStatus = "dead"    // 节点租约过期，已被判定死亡
+)
+
+// CoordinatorNode 表示一个协调者实例，用于故障检测和事务接管
+type CoordinatorNode struct {
+	gorm.Model
+	NodeID        string                `gorm:"column:node_id;type:varchar(64);uniqueIndex"` // 协调者实例ID
+	ServiceName   string                `gorm:"column:service_name;type:varchar(64)"`        // 协调者服务名称
+	StartTime     time.Time             `gorm:"column:start_time"`                           // 实例启动时间
+	LastHeartbeat time.Time             `gorm:"column:last_heartbeat;index"`                 // 最后一次心跳时间
+	Status        CoordinatorNodeStatus `gorm:"column:status;type:varchar(20)"`              // 节点状态
+	TakenOverBy   string                `gorm:"column:taken_over_by;type:varchar(64)"`       // 接管该节点事务的实例ID
+}
+
+// TableName 定义协调者节点表名
+func (CoordinatorNode) TableName() string {
+	return "coordinator_nodes"
+}
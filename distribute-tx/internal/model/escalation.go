@@ -0,0 +1,36 @@
+package model
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// EscalationStatus 表示人工升级条目的状态
+type EscalationStatus string
+
+// 人工升级条目的不同状态
+const (
+	EscalationPending  EscalationStatus = "pending"  // 等待人工处理
+	EscalationResolved EscalationStatus = "resolved" // 已人工处理
+	EscalationRetried  EscalationStatus = "retried"  // 人工重试后补偿成功
+)
+
+// CompensationEscalation 表示补偿多次失败后进入人工处理队列的分支
+type CompensationEscalation struct {
+	gorm.Model
+	XID          string           `gorm:"column:xid;type:varchar(64);index"`     // 关联的全局事务ID
+	Branch       string           `gorm:"column:branch;type:varchar(64)"`        // 分支（参与者）名称
+	ResourceID   string           `gorm:"column:resource_id;type:varchar(64)"`   // 资源标识
+	Attempts     int              `gorm:"column:attempts"`                       // 已尝试的补偿次数
+	ErrorHistory string           `gorm:"column:error_history;type:text"`        // 每次失败的错误信息(JSON数组)
+	Status       EscalationStatus `gorm:"column:status;type:varchar(20);index"`  // 当前状态
+	ResolvedBy   string           `gorm:"column:resolved_by;type:varchar(64)"`   // 处理人
+	ResolveNote  string           `gorm:"column:resolve_note;type:varchar(255)"` // 处理说明
+	ResolvedAt   *time.Time       `gorm:"column:resolved_at"`                    // 处理时间
+}
+
+// TableName 定义人工升级队列表名
+func (CompensationEscalation) TableName() string {
+	return "compensation_escalations"
+}
@@ -0,0 +1,42 @@
+package model
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// SnapshotStage 表示快照采集的时机
+type SnapshotStage string
+
+// 快照采集时机
+const (
+	SnapshotBefore SnapshotStage = "before" // 事务执行前
+	SnapshotAfter  SnapshotStage = "after"  // 事务提交或回滚后
+)
+
+// SnapshotScope 描述一个全局事务涉及的业务行，用于采集前后快照
+type SnapshotScope struct {
+	OrderNos   []string `json:"order_nos"`   // 订单号（订单表和支付记录表按订单号采集）
+	ProductIDs []string `json:"product_ids"` // 商品ID（库存表）
+	UserIDs    []string `json:"user_ids"`    // 用户ID（账户表）
+}
+
+// TransactionSnapshot 表示某个全局事务在某一时机下一组业务行的快照
+type TransactionSnapshot struct {
+	gorm.Model
+	XID        string            `gorm:"column:xid;type:varchar(64);index"`  // 关联的全局事务ID
+	Stage      SnapshotStage     `gorm:"column:stage;type:varchar(10)"`      // 采集时机
+	Outcome    TransactionStatus `gorm:"column:outcome;type:varchar(20)"`    // 事务结果（仅after快照）
+	Service    string            `gorm:"column:service;type:varchar(64)"`    // 业务服务名称
+	Table      string            `gorm:"column:table_name;type:varchar(64)"` // 业务表名
+	RowKey     string            `gorm:"column:row_key;type:varchar(64)"`    // 采集条件的取值（订单号、商品ID等）
+	RowCount   int               `gorm:"column:row_count"`                   // 匹配的行数，0表示行不存在
+	Data       string            `gorm:"column:data;type:text"`              // 匹配行的JSON数组（包含软删除的行）
+	CapturedAt time.Time         `gorm:"column:captured_at"`                 // 采集时间
+}
+
+// TableName 定义事务快照表名
+func (TransactionSnapshot) TableName() string {
+	return "transaction_snapshots"
+}
@@ -14,6 +14,8 @@ const (
 	StatusCreated    TransactionStatus = "created"    // 事务已创建
 	StatusPreparing  TransactionStatus = "preparing"  // 事务准备中
 	StatusPrepared   TransactionStatus = "prepared"   // 所有参与者已准备好
+	StatusCanCommit  TransactionStatus = "can_commit" // 3PC：所有参与者均表示可以提交
+	StatusPreCommit  TransactionStatus = "precommit"  // 3PC：所有参与者已预提交
 	StatusCommitted  TransactionStatus = "committed"  // 事务已提交
 	StatusRolledBack TransactionStatus = "rolledback" // 事务已回滚
 	StatusFailed     TransactionStatus = "failed"     // 事务失败
@@ -22,11 +24,14 @@ const (
 // Transaction 表示一个分布式事务
 type Transaction struct {
 	gorm.Model
-	XID         string            `gorm:"column:xid;type:varchar(64);uniqueIndex"` // 全局唯一事务ID
-	Status      TransactionStatus `gorm:"column:status;type:varchar(20)"`          // 事务当前状态
-	StartTime   time.Time         `gorm:"column:start_time"`                       // 事务开始时间
-	FinishTime  *time.Time        `gorm:"column:finish_time"`                      // 事务完成时间
-	Description string            `gorm:"column:description;type:varchar(255)"`    // 事务描述
+	XID           string            `gorm:"column:xid;type:varchar(64);uniqueIndex"`      // 全局唯一事务ID
+	Status        TransactionStatus `gorm:"column:status;type:varchar(20)"`               // 事务当前状态
+	StartTime     time.Time         `gorm:"column:start_time"`                            // 事务开始时间
+	FinishTime    *time.Time        `gorm:"column:finish_time"`                           // 事务完成时间
+	Deadline      *time.Time        `gorm:"column:deadline"`                              // 事务截止时间，超过后不能再提交
+	EnlistBy      *time.Time        `gorm:"column:enlist_by"`                             // 参与者登记截止时间，超过后不能再加入
+	Description   string            `gorm:"column:description;type:varchar(255)"`         // 事务描述
+	CoordinatorID string            `gorm:"column:coordinator_id;type:varchar(64);index"` // 负责该事务的协调者实例ID
 }
 
 // TableName 定义事务表名
@@ -41,6 +46,8 @@ type ParticipantStatus string
 const (
 	ParticipantRegistered ParticipantStatus = "registered" // 参与者已注册
 	ParticipantPrepared   ParticipantStatus = "prepared"   // 参与者已准备
+	ParticipantCanCommit  ParticipantStatus = "can_commit" // 3PC：参与者表示可以提交
+	ParticipantPreCommit  ParticipantStatus = "precommit"  // 3PC：参与者已预提交
 	ParticipantCommitted  ParticipantStatus = "committed"  // 参与者已提交
 	ParticipantRolledBack ParticipantStatus = "rolledback" // 参与者已回滚
 	ParticipantFailed     ParticipantStatus = "failed"     // 参与者操作失败
@@ -0,0 +1,45 @@
+package participant
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"distribute-tx/internal/model"
+)
+
+// ErrEnlistmentClosed 全局事务已停止接受参与者登记
+var ErrEnlistmentClosed = errors.New("enlistment closed")
+
+// EnlistmentClosedError 参与者登记被拒绝的原因：超过登记截止时间，或协调者已经开始做出决定
+type EnlistmentClosedError struct {
+	XID         string                  // 全局事务ID
+	Participant string                  // 被拒绝的参与者名称
+	Status      model.TransactionStatus // 登记时事务的状态
+	EnlistBy    *time.Time              // 登记截止时间
+}
+
+func (e *EnlistmentClosedError) Error() string {
+	if !enlistableStatus(e.Status) {
+		return fmt.Sprintf("participant %s cannot enlist in transaction %s: coordinator already deciding (status %s)", e.Participant, e.XID, e.Status)
+	}
+	return fmt.Sprintf("participant %s cannot enlist in transaction %s: enlistment closed at %s", e.Participant, e.XID, e.EnlistBy.Format(time.RFC3339Nano))
+}
+
+// Unwrap 使 errors.Is(err, ErrEnlistmentClosed) 成立
+func (e *EnlistmentClosedError) Unwrap() error {
+	return ErrEnlistmentClosed
+}
+
+// enlistableStatus 事务处于该状态时允许参与者登记：准备阶段结束后协调者即开始做出决定
+func enlistableStatus(status model.TransactionStatus) bool {
+	return status == model.StatusCreated || status == model.StatusPreparing
+}
+
+// checkEnlistment 检查参与者此时能否登记到事务中
+func checkEnlistment(tx model.Transaction, name string, now time.Time) error {
+	if !enlistableStatus(tx.Status) || tx.EnlistBy != nil && now.After(*tx.EnlistBy) {
+		return &EnlistmentClosedError{XID: tx.XID, Participant: name, Status: tx.Status, EnlistBy: tx.EnlistBy}
+	}
+	return nil
+}
@@ -1,21 +1,29 @@
 package participant
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"sync"
+	"time"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 
 	"distribute-tx/internal/db"
 	"distribute-tx/internal/model"
 )
 
 // Participant 表示分布式事务中的一个参与者
+// 已准备的分支是资源数据库中的XA事务（见 prepareBranch），参与者本身不保存分支状态，
+// 不同进程中名称和资源相同的参与者可以完成彼此准备的分支
 type Participant struct {
-	Name       string                  // 参与者名称
+	Name       string                  // 参与者名称，同时是XA分支标识中的bqual
 	ResourceID string                  // 资源标识
 	DBManager  *db.DBConnectionManager // 数据库连接管理器
-	LocalTx    *gorm.DB                // 本地事务对象
+
+	mu               sync.Mutex             // 串行化提交与回滚，3PC的超时提交与协调者指令可能并发
+	autoCommitTimers map[string]*time.Timer // 3PC预提交后的超时自动提交定时器，键为全局事务ID
 }
 
 // NewParticipant 创建新的事务参与者
@@ -28,7 +36,9 @@ func NewParticipant(name string, resourceID string, dbManager *db.DBConnectionMa
 }
 
 // Register 将参与者注册到指定的全局事务中
-func (p *Participant) Register(coordinatorService string, xid string) (model.OperationResult, error) {
+// 事务已超过登记截止时间，或协调者已开始做出决定（准备阶段结束）时拒绝登记，返回 *EnlistmentClosedError；
+// 检查与插入参与者记录在同一个事务中，并对事务记录加共享锁，协调者更新事务状态会等待进行中的登记完成
+func (p *Participant) Register(ctx context.Context, coordinatorService string, xid string) (model.OperationResult, error) {
 	// 获取协调者数据库连接
 	coordDB, err := p.DBManager.GetDB(coordinatorService)
 	if err != nil {
@@ -43,8 +53,18 @@ func (p *Participant) Register(coordinatorService string, xid string) (model.Ope
 		ResourceID: p.ResourceID,
 	}
 
-	// 将参与者记录保存到协调者数据库
-	if err := coordDB.Create(&participant).Error; err != nil {
+	// 检查登记窗口并将参与者记录保存到协调者数据库
+	err = coordDB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var transaction model.Transaction
+		if err := tx.Clauses(clause.Locking{Strength: "SHARE"}).Where("xid = ?", xid).First(&transaction).Error; err != nil {
+			return fmt.Errorf("failed to load transaction %s: %w", xid, err)
+		}
+		if err := checkEnlistment(transaction, p.Name, time.Now()); err != nil {
+			return err
+		}
+		return tx.Create(&participant).Error
+	})
+	if err != nil {
 		return model.OperationResult{
 			Success: false,
 			Err:     err,
@@ -58,22 +78,11 @@ func (p *Participant) Register(coordinatorService string, xid string) (model.Ope
 	}, nil
 }
 
-// Prepare 执行准备阶段操作，在本地资源上尝试事务操作但不提交
-func (p *Participant) Prepare(xid string, action func(*gorm.DB) error) (model.OperationResult, error) {
-	// 获取资源数据库连接
-	db, err := p.DBManager.GetDB(p.ResourceID)
-	if err != nil {
-		return model.OperationResult{Success: false, Err: err}, err
-	}
-
-	// 开始本地事务
-	tx := db.Begin()
-	p.LocalTx = tx
-
-	// 执行业务逻辑
-	if err := action(tx); err != nil {
-		// 发生错误，回滚本地事务
-		tx.Rollback()
+// Prepare 执行准备阶段操作：在资源数据库的XA分支中执行业务操作并 XA PREPARE，但不提交
+// ctx约束业务操作中的每条语句；准备成功后分支保存在资源数据库中，由协调者（或接管它的协调者）的提交/回滚决定，
+// 不随ctx取消或本进程退出而回滚
+func (p *Participant) Prepare(ctx context.Context, xid string, action func(*gorm.DB) error) (model.OperationResult, error) {
+	if err := p.prepareBranch(ctx, xid, action); err != nil {
 		return model.OperationResult{
 			Success: false,
 			Err:     err,
@@ -81,7 +90,7 @@ func (p *Participant) Prepare(xid string, action func(*gorm.DB) error) (model.Op
 		}, err
 	}
 
-	// 准备成功，但不提交(事务仍然保持打开状态)
+	// 准备成功，分支等待提交或回滚
 	return model.OperationResult{
 		Success: true,
 		Message: fmt.Sprintf("Prepare phase successful for participant %s in transaction %s", p.Name, xid),
@@ -89,14 +98,14 @@ func (p *Participant) Prepare(xid string, action func(*gorm.DB) error) (model.Op
 }
 
 // UpdateParticipantStatus 更新参与者状态
-func (p *Participant) UpdateParticipantStatus(coordinatorService string, xid string, status model.ParticipantStatus) error {
+func (p *Participant) UpdateParticipantStatus(ctx context.Context, coordinatorService string, xid string, status model.ParticipantStatus) error {
 	coordDB, err := p.DBManager.GetDB(coordinatorService)
 	if err != nil {
 		return err
 	}
 
 	// 更新参与者状态
-	result := coordDB.Model(&model.TransactionParticipant{}).
+	result := coordDB.WithContext(ctx).Model(&model.TransactionParticipant{}).
 		Where("xid = ? AND name = ?", xid, p.Name).
 		Update("status", status)
 
@@ -112,29 +121,46 @@ func (p *Participant) UpdateParticipantStatus(coordinatorService string, xid str
 }
 
 // Commit 提交准备好的事务
-func (p *Participant) Commit(coordinatorService string, xid string) (model.OperationResult, error) {
-	if p.LocalTx == nil {
-		return model.OperationResult{
-			Success: false,
-			Err:     errors.New("no active local transaction"),
-			Message: fmt.Sprintf("No active transaction found for participant %s", p.Name),
-		}, errors.New("no active local transaction")
-	}
+func (p *Participant) Commit(ctx context.Context, coordinatorService string, xid string) (model.OperationResult, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
 
-	// 提交本地事务
-	if err := p.LocalTx.Commit().Error; err != nil {
-		// 更新参与者状态为失败
-		p.UpdateParticipantStatus(coordinatorService, xid, model.ParticipantFailed)
+	p.stopAutoCommit(xid)
+	return p.commitLocked(ctx, coordinatorService, xid)
+}
 
+// commitLocked 在持有锁时提交已准备的XA分支
+// 分支已不存在且参与者记录显示已提交（超时自动提交，或被接管前已经提交）时视为成功，提交可以安全重试
+func (p *Participant) commitLocked(ctx context.Context, coordinatorService string, xid string) (model.OperationResult, error) {
+	if err := p.endBranch(ctx, "XA COMMIT", xid); err != nil {
+		if !isXANotFound(err) {
+			// 更新参与者状态为失败
+			p.UpdateParticipantStatus(ctx, coordinatorService, xid, model.ParticipantFailed)
+
+			return model.OperationResult{
+				Success: false,
+				Err:     err,
+				Message: fmt.Sprintf("Commit failed for participant %s in transaction %s", p.Name, xid),
+			}, err
+		}
+
+		status, _ := p.participantStatus(ctx, coordinatorService, xid)
+		if status != model.ParticipantCommitted {
+			err := fmt.Errorf("%w: participant %s in transaction %s, status %q", ErrBranchNotFound, p.Name, xid, status)
+			return model.OperationResult{
+				Success: false,
+				Err:     err,
+				Message: fmt.Sprintf("No prepared branch found for participant %s", p.Name),
+			}, err
+		}
 		return model.OperationResult{
-			Success: false,
-			Err:     err,
-			Message: fmt.Sprintf("Commit failed for participant %s in transaction %s", p.Name, xid),
-		}, err
+			Success: true,
+			Message: fmt.Sprintf("Participant %s already committed transaction %s", p.Name, xid),
+		}, nil
 	}
 
 	// 更新参与者状态为已提交
-	if err := p.UpdateParticipantStatus(coordinatorService, xid, model.ParticipantCommitted); err != nil {
+	if err := p.UpdateParticipantStatus(ctx, coordinatorService, xid, model.ParticipantCommitted); err != nil {
 		return model.OperationResult{
 			Success: false,
 			Err:     err,
@@ -142,9 +168,6 @@ func (p *Participant) Commit(coordinatorService string, xid string) (model.Opera
 		}, err
 	}
 
-	// 清空本地事务引用
-	p.LocalTx = nil
-
 	return model.OperationResult{
 		Success: true,
 		Message: fmt.Sprintf("Transaction committed successfully for participant %s in transaction %s", p.Name, xid),
@@ -152,29 +175,35 @@ func (p *Participant) Commit(coordinatorService string, xid string) (model.Opera
 }
 
 // Rollback 回滚准备好的事务
-func (p *Participant) Rollback(coordinatorService string, xid string) (model.OperationResult, error) {
-	if p.LocalTx == nil {
-		return model.OperationResult{
-			Success: false,
-			Err:     errors.New("no active local transaction"),
-			Message: fmt.Sprintf("No active transaction found for participant %s", p.Name),
-		}, errors.New("no active local transaction")
-	}
-
-	// 回滚本地事务
-	if err := p.LocalTx.Rollback().Error; err != nil {
-		// 更新参与者状态为失败
-		p.UpdateParticipantStatus(coordinatorService, xid, model.ParticipantFailed)
-
-		return model.OperationResult{
-			Success: false,
-			Err:     err,
-			Message: fmt.Sprintf("Rollback failed for participant %s in transaction %s", p.Name, xid),
-		}, err
+// 分支不存在（准备失败、尚未准备，或开启分支的连接断开时MySQL已经回滚）时只记录状态；已经提交的分支无法回滚
+func (p *Participant) Rollback(ctx context.Context, coordinatorService string, xid string) (model.OperationResult, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.stopAutoCommit(xid)
+	if err := p.endBranch(ctx, "XA ROLLBACK", xid); err != nil {
+		if !isXANotFound(err) {
+			// 更新参与者状态为失败
+			p.UpdateParticipantStatus(ctx, coordinatorService, xid, model.ParticipantFailed)
+
+			return model.OperationResult{
+				Success: false,
+				Err:     err,
+				Message: fmt.Sprintf("Rollback failed for participant %s in transaction %s", p.Name, xid),
+			}, err
+		}
+		if status, _ := p.participantStatus(ctx, coordinatorService, xid); status == model.ParticipantCommitted {
+			err := fmt.Errorf("participant %s already committed transaction %s", p.Name, xid)
+			return model.OperationResult{
+				Success: false,
+				Err:     err,
+				Message: fmt.Sprintf("Rollback failed for participant %s in transaction %s", p.Name, xid),
+			}, err
+		}
 	}
 
 	// 更新参与者状态为已回滚
-	if err := p.UpdateParticipantStatus(coordinatorService, xid, model.ParticipantRolledBack); err != nil {
+	if err := p.UpdateParticipantStatus(ctx, coordinatorService, xid, model.ParticipantRolledBack); err != nil {
 		return model.OperationResult{
 			Success: false,
 			Err:     err,
@@ -182,9 +211,6 @@ func (p *Participant) Rollback(coordinatorService string, xid string) (model.Ope
 		}, err
 	}
 
-	// 清空本地事务引用
-	p.LocalTx = nil
-
 	return model.OperationResult{
 		Success: true,
 		Message: fmt.Sprintf("Transaction rolled back successfully for participant %s in transaction %s", p.Name, xid),
@@ -192,9 +218,13 @@ func (p *Participant) Rollback(coordinatorService string, xid string) (model.Ope
 }
 
 // ExecuteCompensation 执行补偿操作（当事务失败需要额外补偿时）
-func (p *Participant) ExecuteCompensation(xid string, compensation func() error) (model.OperationResult, error) {
+func (p *Participant) ExecuteCompensation(ctx context.Context, xid string, compensation func(context.Context) error) (model.OperationResult, error) {
+	if err := ctx.Err(); err != nil {
+		return model.OperationResult{Success: false, Err: err}, err
+	}
+
 	// 执行补偿逻辑
-	if err := compensation(); err != nil {
+	if err := compensation(ctx); err != nil {
 		return model.OperationResult{
 			Success: false,
 			Err:     err,
@@ -0,0 +1,101 @@
+package participant
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+
+	"distribute-tx/internal/model"
+)
+
+// CanCommit 3PC第一阶段：检查本地资源是否能够完成事务，只做检查、不加锁也不修改数据
+func (p *Participant) CanCommit(ctx context.Context, coordinatorService string, xid string, check func(*gorm.DB) error) (model.OperationResult, error) {
+	db, err := p.DBManager.GetDB(p.ResourceID)
+	if err != nil {
+		return model.OperationResult{Success: false, Err: err}, err
+	}
+
+	if err := check(db.WithContext(ctx)); err != nil {
+		return model.OperationResult{
+			Success: false,
+			Err:     err,
+			Message: fmt.Sprintf("Participant %s voted no in CanCommit for transaction %s", p.Name, xid),
+		}, err
+	}
+
+	if err := p.UpdateParticipantStatus(ctx, coordinatorService, xid, model.ParticipantCanCommit); err != nil {
+		return model.OperationResult{Success: false, Err: err}, err
+	}
+
+	return model.OperationResult{
+		Success: true,
+		Message: fmt.Sprintf("Participant %s voted yes in CanCommit for transaction %s", p.Name, xid),
+	}, nil
+}
+
+// PreCommit 3PC第二阶段：在XA分支中执行操作并准备但不提交，并启动超时定时器
+// 参与者进入预提交状态说明所有参与者都已同意提交，因此若超时仍未收到协调者的DoCommit或Abort，
+// 参与者会自行提交，而不是像2PC那样持有锁无限期等待。定时器只存在于本进程中，进程退出后由接管的协调者提交分支
+func (p *Participant) PreCommit(ctx context.Context, coordinatorService string, xid string, action func(*gorm.DB) error, timeout time.Duration) (model.OperationResult, error) {
+	if err := p.prepareBranch(ctx, xid, action); err != nil {
+		return model.OperationResult{
+			Success: false,
+			Err:     err,
+			Message: fmt.Sprintf("PreCommit failed for participant %s in transaction %s", p.Name, xid),
+		}, err
+	}
+
+	if err := p.UpdateParticipantStatus(ctx, coordinatorService, xid, model.ParticipantPreCommit); err != nil {
+		log.Printf("Failed to record precommit status for participant %s: %v", p.Name, err)
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.stopAutoCommit(xid)
+	if p.autoCommitTimers == nil {
+		p.autoCommitTimers = make(map[string]*time.Timer)
+	}
+	var timer *time.Timer
+	timer = time.AfterFunc(timeout, func() {
+		p.mu.Lock()
+		defer p.mu.Unlock()
+
+		// 已收到协调者的决定
+		if p.autoCommitTimers[xid] != timer {
+			return
+		}
+		delete(p.autoCommitTimers, xid)
+		log.Printf("Participant %s received no decision for transaction %s within %v, committing by default",
+			p.Name, xid, timeout)
+		// 定时器在协调者调用返回之后触发，不能使用调用方的上下文
+		if _, err := p.commitLocked(context.Background(), coordinatorService, xid); err != nil {
+			log.Printf("Participant %s failed to auto-commit transaction %s: %v", p.Name, xid, err)
+		}
+	})
+	p.autoCommitTimers[xid] = timer
+
+	return model.OperationResult{
+		Success: true,
+		Message: fmt.Sprintf("PreCommit successful for participant %s in transaction %s", p.Name, xid),
+	}, nil
+}
+
+// DoCommit 3PC第三阶段：提交预提交的XA分支，已因超时自动提交的视为成功（见 commitLocked）
+func (p *Participant) DoCommit(ctx context.Context, coordinatorService string, xid string) (model.OperationResult, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.stopAutoCommit(xid)
+	return p.commitLocked(ctx, coordinatorService, xid)
+}
+
+// stopAutoCommit 停止xid的超时自动提交定时器（需持有锁）
+func (p *Participant) stopAutoCommit(xid string) {
+	if timer, ok := p.autoCommitTimers[xid]; ok {
+		timer.Stop()
+		delete(p.autoCommitTimers, xid)
+	}
+}
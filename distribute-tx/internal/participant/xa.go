@@ -0,0 +1,126 @@
+package participant
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/go-sql-driver/mysql"
+	"gorm.io/gorm"
+
+	"distribute-tx/internal/model"
+)
+
+// errXANotFound MySQL返回的 XAER_NOTA：资源数据库中没有该XA分支（已提交、已回滚或从未准备）
+const errXANotFound = 1397
+
+// ErrBranchNotFound 提交时资源数据库中没有已准备的XA分支，参与者记录也没有显示它已经提交
+var ErrBranchNotFound = errors.New("prepared xa branch not found")
+
+// xaID 全局事务在本参与者上的XA分支标识：gtrid为全局事务ID，bqual为参与者名称。
+// XA语句不支持占位符，两者都以十六进制字面量写入语句，不需要转义
+func (p *Participant) xaID(xid string) string {
+	return fmt.Sprintf("X'%s',X'%s'", hex.EncodeToString([]byte(xid)), hex.EncodeToString([]byte(p.Name)))
+}
+
+// prepareBranch 在资源数据库的一个专用连接上执行 XA START、业务操作、XA END 和 XA PREPARE。
+// 准备成功后断开这个连接：已准备的XA分支保存在资源数据库中，与开启它的会话和进程无关，
+// 任何进程中同名的参与者都可以用 XA COMMIT / XA ROLLBACK 完成它，协调者被接管后分支仍然可以恢复
+func (p *Participant) prepareBranch(ctx context.Context, xid string, action func(*gorm.DB) error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	db, err := p.DBManager.GetDB(p.ResourceID)
+	if err != nil {
+		return err
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		return err
+	}
+	conn, err := sqlDB.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	id := p.xaID(xid)
+	if _, err := conn.ExecContext(ctx, "XA START "+id); err != nil {
+		return fmt.Errorf("failed to start xa branch: %w", err)
+	}
+
+	// 分支中的语句都在这个连接上执行；XA分支内不能再开启事务，关闭GORM的默认事务
+	tx := db.Session(&gorm.Session{SkipDefaultTransaction: true, Context: ctx})
+	tx.Statement.ConnPool = conn
+	err = action(tx)
+	if err == nil {
+		_, err = conn.ExecContext(ctx, "XA END "+id)
+	}
+	if err == nil {
+		_, err = conn.ExecContext(ctx, "XA PREPARE "+id)
+	}
+	if err != nil {
+		p.abortBranch(conn, sqlDB, id)
+		return err
+	}
+
+	detach(conn)
+	return nil
+}
+
+// abortBranch 回滚准备失败的分支。连接仍可用时在原连接上结束并回滚；连接已断开时未准备的分支已被MySQL回滚，
+// 可能已经准备的分支（如 XA PREPARE 执行中ctx被取消）从其他连接回滚
+func (p *Participant) abortBranch(conn *sql.Conn, sqlDB *sql.DB, id string) {
+	ctx := context.Background()
+	conn.ExecContext(ctx, "XA END "+id)
+	_, err := conn.ExecContext(ctx, "XA ROLLBACK "+id)
+	if err == nil || isXANotFound(err) {
+		return
+	}
+	detach(conn)
+	if _, err := sqlDB.ExecContext(ctx, "XA ROLLBACK "+id); err != nil && !isXANotFound(err) {
+		log.Printf("Participant %s failed to roll back xa branch %s: %v", p.Name, id, err)
+	}
+}
+
+// detach 断开连接而不是放回连接池：已准备的分支在开启它的会话结束之后才能由其他会话提交或回滚
+func detach(conn *sql.Conn) {
+	conn.Raw(func(any) error { return driver.ErrBadConn })
+}
+
+// endBranch 在资源数据库上执行 XA COMMIT 或 XA ROLLBACK，分支不存在时返回的错误满足 isXANotFound
+func (p *Participant) endBranch(ctx context.Context, statement string, xid string) error {
+	db, err := p.DBManager.GetDB(p.ResourceID)
+	if err != nil {
+		return err
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		return err
+	}
+	_, err = sqlDB.ExecContext(ctx, statement+" "+p.xaID(xid))
+	return err
+}
+
+// isXANotFound 错误是否为 XAER_NOTA
+func isXANotFound(err error) bool {
+	var mysqlErr *mysql.MySQLError
+	return errors.As(err, &mysqlErr) && mysqlErr.Number == errXANotFound
+}
+
+// participantStatus 协调者数据库中本参与者在全局事务中的状态，用于判断找不到的分支是否已经完成
+func (p *Participant) participantStatus(ctx context.Context, coordinatorService string, xid string) (model.ParticipantStatus, error) {
+	coordDB, err := p.DBManager.GetDB(coordinatorService)
+	if err != nil {
+		return "", err
+	}
+	var record model.TransactionParticipant
+	if err := coordDB.WithContext(ctx).Where("xid = ? AND name = ?", xid, p.Name).First(&record).Error; err != nil {
+		return "", err
+	}
+	return record.Status, nil
+}
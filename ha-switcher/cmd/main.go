@@ -4,6 +4,7 @@ import (
 	"ha-switcher/internal/api"
 	"ha-switcher/internal/config"
 	"ha-switcher/internal/db"
+	"ha-switcher/internal/failback"
 	"ha-switcher/internal/monitor"
 	"ha-switcher/internal/switcher"
 	"log"
@@ -36,7 +37,15 @@ func main() {
 	sw := switcher.NewSwitcher(dbManager, cfg)
 	log.Println("Switcher initialized successfully")
 
+	// 创建健康检查器，诊断信息包需要其健康检查历史
+	healthChecker := monitor.NewHealthChecker(dbManager, cfg, sw)
+
+	// 创建回切控制器，原主库恢复并追平后切回原主库
+	failbackController := failback.NewController(dbManager, sw, cfg)
+
 	apiServer := api.NewServer(dbManager, sw, 8080)
+	apiServer.SetHealthChecker(healthChecker)
+	apiServer.SetFailback(failbackController)
 	go func() {
 		if err := apiServer.Start(); err != nil {
 			log.Printf("HTTP server error: %v", err)
@@ -44,14 +53,16 @@ func main() {
 	}()
 	log.Println("HTTP API server started on port 8080")
 
-	// 创建并启动健康检查器
-	healthChecker := monitor.NewHealthChecker(dbManager, cfg, sw)
+	// 启动健康检查器
 	err = healthChecker.Start()
 	if err != nil {
 		log.Fatalf("Failed to start health checker: %v", err)
 	}
 	log.Println("Health checker started successfully")
 
+	// 启动回切控制器
+	failbackController.Start()
+
 	// 设置信号处理，以便优雅关闭
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
@@ -64,6 +75,9 @@ func main() {
 	healthChecker.Stop()
 	log.Println("Health checker stopped")
 
+	// 停止回切控制器
+	failbackController.Stop()
+
 	// 关闭前等待一小段时间确保资源清理
 	time.Sleep(500 * time.Millisecond)
 	log.Println("MySQL HA Switcher shutdown complete")
@@ -0,0 +1,120 @@
+package api
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"runtime"
+	"runtime/pprof"
+	"time"
+
+	"ha-switcher/internal/config"
+	"ha-switcher/internal/db"
+	"ha-switcher/internal/events"
+	"ha-switcher/internal/failback"
+	"ha-switcher/internal/monitor"
+	"ha-switcher/internal/rebuild"
+	"ha-switcher/internal/switcher"
+)
+
+// startedAt 进程启动时间，用于计算运行时长
+var startedAt = time.Now()
+
+// Diagnostics 诊断信息包，一次请求收集排查故障所需的全部状态，可直接附在问题报告中
+type Diagnostics struct {
+	GeneratedAt   time.Time                         `json:"generated_at"`
+	Config        config.Config                     `json:"config"`               // 当前配置（已隐藏密码）
+	Topology      switcher.Topology                 `json:"topology"`             // 当前拓扑
+	SwitchCount   int                               `json:"switch_count"`         // 累计切换次数
+	LastSwitch    time.Time                         `json:"last_switch"`          // 最近一次切换时间
+	Flapping      switcher.FlappingStatus           `json:"flapping"`             // 防抖动保护状态
+	Rebuild       rebuild.Status                    `json:"rebuild"`              // 旧主库重建流程状态
+	Failback      *failback.Status                  `json:"failback,omitempty"`   // 回切控制器状态（未启用时为空）
+	HealthHistory map[string][]monitor.HealthSample `json:"health_history"`       // 各节点最近的健康检查记录
+	Events        []events.Event                    `json:"events"`               // 最近的系统事件
+	Replication   []db.ReplicationStatus            `json:"replication"`          // 各节点的复制状态
+	Pools         []db.PoolStats                    `json:"pools"`                // 各节点的连接池统计
+	Runtime       RuntimeStats                      `json:"runtime"`              // 进程运行时统计
+	Goroutines    string                            `json:"goroutines,omitempty"` // 所有goroutine的调用栈（?stacks=true时）
+}
+
+// RuntimeStats 进程运行时统计
+type RuntimeStats struct {
+	GoVersion     string  `json:"go_version"`
+	Uptime        string  `json:"uptime"`
+	NumGoroutine  int     `json:"num_goroutine"`
+	NumCPU        int     `json:"num_cpu"`
+	GOMAXPROCS    int     `json:"gomaxprocs"`
+	HeapAllocMB   float64 `json:"heap_alloc_mb"`
+	HeapSysMB     float64 `json:"heap_sys_mb"`
+	HeapObjects   uint64  `json:"heap_objects"`
+	NumGC         uint32  `json:"num_gc"`
+	LastGCPauseMs float64 `json:"last_gc_pause_ms"`
+}
+
+// SetHealthChecker 设置健康检查器，诊断信息包从中获取健康检查历史
+func (s *Server) SetHealthChecker(hc *monitor.HealthChecker) {
+	s.healthChecker = hc
+}
+
+// handleDiagnostics 返回诊断信息包
+// ?stacks=true 附带所有goroutine的调用栈，?download=true 以附件形式下载
+func (s *Server) handleDiagnostics(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "Method not allowed"})
+		return
+	}
+
+	count, lastSwitch := s.switcher.GetSwitchStats()
+	diag := Diagnostics{
+		GeneratedAt: time.Now(),
+		Config:      s.switcher.Config().Redacted(),
+		Topology:    s.switcher.Topology(),
+		SwitchCount: count,
+		LastSwitch:  lastSwitch,
+		Flapping:    s.switcher.FlappingStatus(),
+		Rebuild:     s.rebuild.Status(),
+		Events:      events.Recent(),
+		Replication: s.dbManager.ReplicationStatus(r.Context()),
+		Pools:       s.dbManager.PoolStats(),
+		Runtime:     runtimeStats(),
+	}
+	if s.healthChecker != nil {
+		diag.HealthHistory = s.healthChecker.History()
+	}
+	if s.failback != nil {
+		status := s.failback.Status()
+		diag.Failback = &status
+	}
+	if r.URL.Query().Get("stacks") == "true" {
+		var buf bytes.Buffer
+		if err := pprof.Lookup("goroutine").WriteTo(&buf, 2); err == nil {
+			diag.Goroutines = buf.String()
+		}
+	}
+
+	if r.URL.Query().Get("download") == "true" {
+		filename := fmt.Sprintf("ha-switcher-diagnostics-%s.json", diag.GeneratedAt.Format("20060102-150405"))
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	}
+	writeJSON(w, http.StatusOK, diag)
+}
+
+// runtimeStats 收集进程运行时统计
+func runtimeStats() RuntimeStats {
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+
+	return RuntimeStats{
+		GoVersion:     runtime.Version(),
+		Uptime:        time.Since(startedAt).Round(time.Second).String(),
+		NumGoroutine:  runtime.NumGoroutine(),
+		NumCPU:        runtime.NumCPU(),
+		GOMAXPROCS:    runtime.GOMAXPROCS(0),
+		HeapAllocMB:   float64(mem.HeapAlloc) / (1 << 20),
+		HeapSysMB:     float64(mem.HeapSys) / (1 << 20),
+		HeapObjects:   mem.HeapObjects,
+		NumGC:         mem.NumGC,
+		LastGCPauseMs: float64(mem.PauseNs[(mem.NumGC+255)%256]) / 1e6,
+	}
+}
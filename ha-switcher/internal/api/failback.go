@@ -0,0 +1,54 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"ha-switcher/internal/failback"
+)
+
+// SetFailback 设置回切控制器，未设置时回切API返回503
+func (s *Server) SetFailback(c *failback.Controller) {
+	s.failback = c
+}
+
+// handleFailbackStatus 返回回切控制器状态
+func (s *Server) handleFailbackStatus(w http.ResponseWriter, r *http.Request) {
+	if s.failback == nil {
+		writeJSON(w, http.StatusServiceUnavailable, map[string]string{"error": "failback controller not configured"})
+		return
+	}
+	writeJSON(w, http.StatusOK, s.failback.Status())
+}
+
+// handleFailbackStart 立即回切到原主库，原主库不满足回切条件时返回409
+func (s *Server) handleFailbackStart(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "Method not allowed"})
+		return
+	}
+	if s.failback == nil {
+		writeJSON(w, http.StatusServiceUnavailable, map[string]string{"error": "failback controller not configured"})
+		return
+	}
+
+	var opts failback.Options
+	if r.ContentLength > 0 {
+		if err := json.NewDecoder(r.Body).Decode(&opts); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid request payload"})
+			return
+		}
+		defer r.Body.Close()
+	}
+
+	err := s.failback.Failback(r.Context(), opts)
+	switch {
+	case errors.Is(err, failback.ErrNotFailedOver), errors.Is(err, failback.ErrNotReady), errors.Is(err, failback.ErrInProgress):
+		writeJSON(w, http.StatusConflict, map[string]string{"error": err.Error()})
+	case err != nil:
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	default:
+		writeJSON(w, http.StatusOK, s.failback.Status())
+	}
+}
@@ -0,0 +1,45 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"ha-switcher/internal/repair"
+)
+
+// repairRequest 执行修复的请求
+type repairRequest struct {
+	Token string `json:"token"` // /api/repair/diagnose 返回的确认令牌
+}
+
+// handleRepairApply 使用确认令牌执行复制修复
+func (s *Server) handleRepairApply(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "Method not allowed"})
+		return
+	}
+
+	var req repairRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Token == "" {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid request payload, token is required"})
+		return
+	}
+	defer r.Body.Close()
+
+	record, err := s.repair.Apply(r.Context(), req.Token)
+	switch {
+	case errors.Is(err, repair.ErrInvalidToken):
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": err.Error()})
+	case errors.Is(err, repair.ErrStateChanged):
+		writeJSON(w, http.StatusConflict, map[string]string{"error": err.Error()})
+	case err != nil && record.Action == "":
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	case err != nil:
+		writeJSON(w, http.StatusInternalServerError, record)
+	case record.Action == repair.ActionReclone:
+		writeJSON(w, http.StatusAccepted, record)
+	default:
+		writeJSON(w, http.StatusOK, record)
+	}
+}
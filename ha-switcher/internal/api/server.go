@@ -1,8 +1,13 @@
 package api
 
 import (
+	"encoding/json"
 	"fmt"
 	"ha-switcher/internal/db"
+	"ha-switcher/internal/failback"
+	"ha-switcher/internal/monitor"
+	"ha-switcher/internal/rebuild"
+	"ha-switcher/internal/repair"
 	"ha-switcher/internal/switcher"
 	"log"
 	"net/http"
@@ -12,7 +17,12 @@ import (
 type Server struct {
 	dbManager *db.DBManager
 	switcher  *switcher.Switcher
+	rebuild   *rebuild.Workflow
+	repair    *repair.Assistant
 	port      int
+
+	healthChecker *monitor.HealthChecker // 健康检查器（可选），诊断信息包使用
+	failback      *failback.Controller   // 回切控制器（可选）
 }
 
 // NewServer 创建一个新的API服务器
@@ -20,6 +30,8 @@ func NewServer(dbManager *db.DBManager, sw *switcher.Switcher, port int) *Server
 	return &Server{
 		dbManager: dbManager,
 		switcher:  sw,
+		rebuild:   rebuild.NewWorkflow(dbManager, sw.Config(), nil),
+		repair:    repair.NewAssistant(dbManager, sw.Config(), nil),
 		port:      port,
 	}
 }
@@ -44,6 +56,88 @@ func (s *Server) Start() error {
 	http.HandleFunc("/api/status", func(w http.ResponseWriter, r *http.Request) {
 		count, lastTime := s.switcher.GetSwitchStats()
 		fmt.Fprintf(w, "Switch count: %d\nLast switch: %v\n", count, lastTime)
+		fmt.Fprintf(w, "Failover candidate ready: %v\n", s.dbManager.IsFailoverCandidateReady())
+
+		flap := s.switcher.FlappingStatus()
+		fmt.Fprintf(w, "Manual mode: %v", flap.ManualMode)
+		if flap.ManualMode {
+			fmt.Fprintf(w, " (%s)", flap.ManualReason)
+		}
+		fmt.Fprintf(w, "\nSwitches in last hour: %d/%d\n", flap.SwitchesLastHour, flap.MaxSwitchesPerHour)
+		fmt.Fprintf(w, "Consecutive switches: %d\n", flap.Consecutive)
+		fmt.Fprintf(w, "Cooldown remaining: %s\n", flap.CooldownRemaining)
+		fmt.Fprintf(w, "Suppressed automatic switches: %d\n", flap.Suppressed)
+
+		if s.failback != nil {
+			fb := s.failback.Status()
+			fmt.Fprintf(w, "Failback ready: %v", fb.Ready)
+			if fb.Reason != "" {
+				fmt.Fprintf(w, " (%s)", fb.Reason)
+			}
+			fmt.Fprintf(w, "\nFailbacks: %d\n", fb.Failbacks)
+		}
+	})
+
+	// 防抖动保护API
+	http.HandleFunc("/api/flapping", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, s.switcher.FlappingStatus())
+	})
+	http.HandleFunc("/api/flapping/resume", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "Method not allowed"})
+			return
+		}
+		s.switcher.ResumeAutomatic()
+		writeJSON(w, http.StatusOK, s.switcher.FlappingStatus())
+	})
+	http.HandleFunc("/api/switch", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "Method not allowed"})
+			return
+		}
+		if err := s.switcher.ForceSwitchToSlave(); err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			return
+		}
+		writeJSON(w, http.StatusOK, s.switcher.Topology())
+	})
+
+	// 拓扑API（JSON），供读写分离代理等组件轮询
+	http.HandleFunc("/api/topology", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, s.switcher.Topology())
+	})
+
+	// 旧主库重建流程API
+	http.HandleFunc("/api/rebuild/start", s.handleRebuildStart)
+	http.HandleFunc("/api/rebuild/status", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, s.rebuild.Status())
+	})
+	http.HandleFunc("/api/rebuild/abort", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "Method not allowed"})
+			return
+		}
+		if err := s.rebuild.Abort(); err != nil {
+			writeJSON(w, http.StatusConflict, map[string]string{"error": err.Error()})
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]string{"message": "Rebuild abort requested"})
+	})
+
+	// 诊断信息包API
+	http.HandleFunc("/api/diagnostics", s.handleDiagnostics)
+
+	// 回切API
+	http.HandleFunc("/api/failback/status", s.handleFailbackStatus)
+	http.HandleFunc("/api/failback/start", s.handleFailbackStart)
+
+	// 复制修复助手API
+	http.HandleFunc("/api/repair/diagnose", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, s.repair.Diagnose(r.Context()))
+	})
+	http.HandleFunc("/api/repair/apply", s.handleRepairApply)
+	http.HandleFunc("/api/repair/actions", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, s.repair.Actions())
 	})
 
 	// 帮助API
@@ -52,9 +146,54 @@ func (s *Server) Start() error {
 		fmt.Fprintf(w, "Available endpoints:\n")
 		fmt.Fprintf(w, "  /api/simulate-failure?enable=true|false - Control failure simulation\n")
 		fmt.Fprintf(w, "  /api/status - Show switcher status\n")
+		fmt.Fprintf(w, "  /api/topology - Show current topology (JSON)\n")
+		fmt.Fprintf(w, "  /api/flapping - Show flapping protection status (JSON)\n")
+		fmt.Fprintf(w, "  /api/flapping/resume (POST) - Leave manual mode and resume automatic failover\n")
+		fmt.Fprintf(w, "  /api/switch (POST) - Manually switch to slave, bypassing flapping protection\n")
+		fmt.Fprintf(w, "  /api/rebuild/start (POST {\"fence\":true,\"simulate\":true}) - Rebuild failed master as replica\n")
+		fmt.Fprintf(w, "  /api/rebuild/status - Show rebuild workflow status\n")
+		fmt.Fprintf(w, "  /api/rebuild/abort (POST) - Abort rebuild workflow\n")
+		fmt.Fprintf(w, "  /api/failback/status - Show failback controller status (JSON)\n")
+		fmt.Fprintf(w, "  /api/failback/start (POST {\"simulate\":true}) - Fail back to the original master once it has caught up\n")
+		fmt.Fprintf(w, "  /api/diagnostics?stacks=true&download=true - Diagnostics bundle for issue reports (JSON)\n")
+		fmt.Fprintf(w, "  /api/repair/diagnose - Detect broken replication threads and offer fixes with confirmation tokens\n")
+		fmt.Fprintf(w, "  /api/repair/apply (POST {\"token\":\"...\"}) - Apply a fix offered by diagnose\n")
+		fmt.Fprintf(w, "  /api/repair/actions - Show repair action history\n")
 	})
 
 	addr := fmt.Sprintf(":%d", s.port)
 	log.Printf("Starting HTTP server at http://localhost%s", addr)
 	return http.ListenAndServe(addr, nil)
 }
+
+// handleRebuildStart 启动旧主库重建流程
+func (s *Server) handleRebuildStart(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "Method not allowed"})
+		return
+	}
+
+	var opts rebuild.Options
+	if r.ContentLength > 0 {
+		if err := json.NewDecoder(r.Body).Decode(&opts); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid request payload"})
+			return
+		}
+		defer r.Body.Close()
+	}
+
+	if err := s.rebuild.Start(opts); err != nil {
+		writeJSON(w, http.StatusConflict, map[string]string{"error": err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusAccepted, s.rebuild.Status())
+}
+
+// writeJSON 返回JSON响应
+func writeJSON(w http.ResponseWriter, code int, payload interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	if err := json.NewEncoder(w).Encode(payload); err != nil {
+		log.Printf("Failed to encode response: %v", err)
+	}
+}
@@ -1,6 +1,9 @@
 package config
 
-import "time"
+import (
+	"net/url"
+	"time"
+)
 
 // Config 保存MySQL高可用系统的配置信息
 type Config struct {
@@ -14,6 +17,36 @@ type Config struct {
 	HealthCheckTimeout time.Duration
 	// 连续失败次数阈值，超过这个值触发切换
 	FailThreshold int
+	// 拓扑变化时回调的URL列表（POST JSON）
+	TopologyWebhooks []string
+	// 防抖动（flapping）保护配置
+	Flapping FlappingConfig
+	// 故障切换后回切到原主库的配置
+	Failback FailbackConfig
+}
+
+// FailbackConfig 回切到原主库的配置
+type FailbackConfig struct {
+	// 原主库满足回切条件后是否自动回切，关闭时只能通过API触发
+	Auto bool
+	// 自动回切前原主库需要持续满足回切条件的时长，期间任何一次检查不满足都重新计时
+	StableFor time.Duration
+	// 回切时等待原主库应用完已隔离的从库上所有事务的超时时间
+	CatchUpTimeout time.Duration
+	// 是否只模拟复制检查和回切中的SQL（本地单实例演示）
+	Simulate bool
+}
+
+// FlappingConfig 自动切换的防抖动保护配置
+type FlappingConfig struct {
+	// 两次自动切换之间的最小间隔
+	MinSwitchInterval time.Duration
+	// 连续切换后的基础冷却时间，之后每次连续切换翻倍
+	BaseCooldown time.Duration
+	// 冷却时间上限
+	MaxCooldown time.Duration
+	// 每小时最多自动切换次数，超过后进入手动模式，0表示不限制
+	MaxSwitchesPerHour int
 }
 
 // DBConfig 保存数据库连接配置
@@ -56,5 +89,48 @@ func DefaultConfig() *Config {
 		HealthCheckInterval: 5 * time.Second,
 		HealthCheckTimeout:  2 * time.Second,
 		FailThreshold:       3,
+		Flapping: FlappingConfig{
+			MinSwitchInterval:  30 * time.Second,
+			BaseCooldown:       time.Minute,
+			MaxCooldown:        30 * time.Minute,
+			MaxSwitchesPerHour: 3,
+		},
+		Failback: FailbackConfig{
+			Auto:           true,
+			StableFor:      5 * time.Minute,
+			CatchUpTimeout: 30 * time.Second,
+		},
+	}
+}
+
+// Redacted 返回隐藏了敏感信息的配置副本（数据库密码、webhook URL中的认证信息和查询参数），用于诊断输出
+func (c *Config) Redacted() Config {
+	redacted := *c
+	redacted.MasterDB.Password = redactPassword(c.MasterDB.Password)
+	redacted.SlaveDB.Password = redactPassword(c.SlaveDB.Password)
+
+	redacted.TopologyWebhooks = make([]string, len(c.TopologyWebhooks))
+	for i, hook := range c.TopologyWebhooks {
+		u, err := url.Parse(hook)
+		if err != nil {
+			redacted.TopologyWebhooks[i] = "***"
+			continue
+		}
+		if u.User != nil {
+			u.User = url.User("***")
+		}
+		if u.RawQuery != "" {
+			u.RawQuery = "***"
+		}
+		redacted.TopologyWebhooks[i] = u.String()
+	}
+	return redacted
+}
+
+// redactPassword 非空密码替换为***，空密码保持为空以便看出未设置密码
+func redactPassword(password string) string {
+	if password == "" {
+		return ""
 	}
+	return "***"
 }
@@ -2,8 +2,10 @@ package db
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"ha-switcher/internal/config"
+	"ha-switcher/internal/events"
 	"log"
 	"sync"
 	"time"
@@ -20,6 +22,7 @@ type DBManager struct {
 	mu              sync.RWMutex   // 读写锁保护并发访问
 	isMasterActive  bool           // 主库是否活跃
 	simulateFailure bool           // 模拟故障切换
+	candidateReady  bool           // 非活跃节点是否可作为故障切换候选
 }
 
 // NewDBManager 创建一个新的数据库管理器
@@ -28,6 +31,7 @@ func NewDBManager(cfg *config.Config) (*DBManager, error) {
 	manager := &DBManager{
 		config:         cfg,
 		isMasterActive: true,
+		candidateReady: true,
 	}
 
 	// 初始化数据库连接
@@ -45,6 +49,7 @@ func (m *DBManager) SetSimulateFailure(simulate bool) {
 	defer m.mu.Unlock()
 
 	m.simulateFailure = simulate
+	events.Record("db", "master failure simulation set to %v", simulate)
 	if simulate {
 		log.Println("Failure simulation mode activated - master will be reported as unhealthy")
 	} else {
@@ -113,6 +118,13 @@ func (m *DBManager) GetDB() *gorm.DB {
 	return m.slaveDB
 }
 
+// IsMasterActive 主库是否仍为活跃连接
+func (m *DBManager) IsMasterActive() bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.isMasterActive
+}
+
 // SwitchToSlave 将活跃连接从主库切换到从库
 func (m *DBManager) SwitchToSlave() {
 	m.mu.Lock()
@@ -120,36 +132,94 @@ func (m *DBManager) SwitchToSlave() {
 
 	if m.isMasterActive {
 		log.Println("Switching from master to slave database")
+		events.Record("db", "active connection switched from master to slave")
 		m.isMasterActive = false
+		// 旧主库需要重建后才能重新作为候选
+		m.candidateReady = false
+	}
+}
+
+// SwitchToMaster 将活跃连接切回原主库（回切），standbyReady 表示从库是否已重新复制原主库、可以作为故障切换候选
+func (m *DBManager) SwitchToMaster(standbyReady bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if !m.isMasterActive {
+		log.Println("Switching from slave back to master database")
+		events.Record("db", "active connection switched from slave back to master")
+		m.isMasterActive = true
+		m.candidateReady = standbyReady
 	}
 }
 
+// GetMasterDB 获取原主库连接（无论当前是否活跃）
+func (m *DBManager) GetMasterDB() *gorm.DB {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.masterDB
+}
+
+// GetSlaveDB 获取从库连接（无论当前是否活跃）
+func (m *DBManager) GetSlaveDB() *gorm.DB {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.slaveDB
+}
+
+// SetFailoverCandidate 设置非活跃节点是否可作为故障切换候选
+func (m *DBManager) SetFailoverCandidate(ready bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.candidateReady = ready
+	log.Printf("Standby failover candidate ready: %v", ready)
+	events.Record("db", "standby failover candidate ready: %v", ready)
+}
+
+// IsFailoverCandidateReady 非活跃节点是否可作为故障切换候选
+func (m *DBManager) IsFailoverCandidateReady() bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.candidateReady
+}
+
 // CheckMasterHealth 检查主库健康状态
 func (m *DBManager) CheckMasterHealth() bool {
+	if err := m.ProbeMaster(); err != nil {
+		log.Printf("Master health check failed: %v", err)
+		return false
+	}
+	return true
+}
+
+// ProbeMaster 对原主库执行一次健康探测，故障模拟模式下直接返回错误
+func (m *DBManager) ProbeMaster() error {
 	m.mu.RLock()
 	if m.simulateFailure {
 		m.mu.RUnlock()
-		log.Println("Simulating master database failure")
-		return false
+		return errors.New("simulated master failure")
 	}
 	m.mu.RUnlock()
 
-	// 原有的健康检查逻辑继续保持不变
+	return m.probe(m.GetMasterDB())
+}
+
+// ProbeSlave 对从库执行一次健康探测
+func (m *DBManager) ProbeSlave() error {
+	return m.probe(m.GetSlaveDB())
+}
+
+// probe 在健康检查超时内执行 SELECT 1
+func (m *DBManager) probe(db *gorm.DB) error {
 	result := &struct{ Value int }{}
 
 	ctx, cancel := context.WithTimeout(context.Background(), m.config.HealthCheckTimeout)
 	defer cancel()
 
-	err := m.masterDB.WithContext(ctx).Raw("SELECT 1 as value").Scan(result).Error
-	if err != nil {
-		log.Printf("Master health check failed: %v", err)
-		return false
+	if err := db.WithContext(ctx).Raw("SELECT 1 as value").Scan(result).Error; err != nil {
+		return err
 	}
-
 	if result.Value != 1 {
-		log.Println("Master health check failed: unexpected result")
-		return false
+		return errors.New("unexpected result")
 	}
-
-	return true
+	return nil
 }
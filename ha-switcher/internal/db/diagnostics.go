@@ -0,0 +1,129 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"ha-switcher/internal/config"
+
+	"gorm.io/gorm"
+)
+
+// ReplicationStatus 单个节点的复制状态
+type ReplicationStatus struct {
+	Node                string `json:"node"`                            // master/slave（按配置中的角色）
+	Addr                string `json:"addr"`                            // 节点地址
+	Active              bool   `json:"active"`                          // 是否为当前活跃节点
+	ServerID            int64  `json:"server_id,omitempty"`             // @@server_id
+	ReadOnly            bool   `json:"read_only"`                       // @@read_only
+	IsReplica           bool   `json:"is_replica"`                      // 是否配置了复制关系
+	SourceHost          string `json:"source_host,omitempty"`           // 复制源地址
+	ReplicaIORunning    string `json:"replica_io_running,omitempty"`    // IO线程状态
+	ReplicaSQLRunning   string `json:"replica_sql_running,omitempty"`   // SQL线程状态
+	SecondsBehindSource *int64 `json:"seconds_behind_source,omitempty"` // 复制延迟（秒）
+	LastError           string `json:"last_error,omitempty"`            // 复制线程最近的错误
+	LastSQLErrno        int    `json:"last_sql_errno,omitempty"`        // SQL线程最近的错误码（如1062重复键、1032找不到行）
+	LastIOError         string `json:"last_io_error,omitempty"`         // IO线程最近的错误
+	Error               string `json:"error,omitempty"`                 // 查询状态失败时的错误
+}
+
+// PoolStats 单个节点的连接池统计
+type PoolStats struct {
+	Node  string      `json:"node"`
+	Stats sql.DBStats `json:"stats"`
+}
+
+// ReplicationStatus 查询主从两个节点的复制状态，单个节点查询失败时记录在其 Error 字段中
+func (m *DBManager) ReplicationStatus(ctx context.Context) []ReplicationStatus {
+	masterActive := m.IsMasterActive()
+	return []ReplicationStatus{
+		m.replicationStatus(ctx, "master", m.GetMasterDB(), m.config.MasterDB.Host, m.config.MasterDB.Port, masterActive),
+		m.replicationStatus(ctx, "slave", m.GetSlaveDB(), m.config.SlaveDB.Host, m.config.SlaveDB.Port, !masterActive),
+	}
+}
+
+// replicationStatus 查询单个节点的只读状态和 SHOW REPLICA STATUS
+func (m *DBManager) replicationStatus(ctx context.Context, node string, db *gorm.DB, host string, port int, active bool) ReplicationStatus {
+	status := ReplicationStatus{Node: node, Addr: fmt.Sprintf("%s:%d", host, port), Active: active}
+
+	ctx, cancel := context.WithTimeout(ctx, m.config.HealthCheckTimeout)
+	defer cancel()
+	db = db.WithContext(ctx)
+
+	var vars struct {
+		ServerID int64 `gorm:"column:server_id"`
+		ReadOnly bool  `gorm:"column:read_only"`
+	}
+	if err := db.Raw("SELECT @@server_id AS server_id, @@global.read_only AS read_only").Scan(&vars).Error; err != nil {
+		status.Error = err.Error()
+		return status
+	}
+	status.ServerID = vars.ServerID
+	status.ReadOnly = vars.ReadOnly
+
+	var replica struct {
+		SourceHost          string `gorm:"column:Source_Host"`
+		ReplicaIORunning    string `gorm:"column:Replica_IO_Running"`
+		ReplicaSQLRunning   string `gorm:"column:Replica_SQL_Running"`
+		SecondsBehindSource *int64 `gorm:"column:Seconds_Behind_Source"`
+		LastError           string `gorm:"column:Last_Error"`
+		LastSQLErrno        int    `gorm:"column:Last_SQL_Errno"`
+		LastIOError         string `gorm:"column:Last_IO_Error"`
+	}
+	result := db.Raw("SHOW REPLICA STATUS").Scan(&replica)
+	if result.Error != nil {
+		status.Error = fmt.Sprintf("show replica status: %v", result.Error)
+		return status
+	}
+	if result.RowsAffected == 0 {
+		return status
+	}
+
+	status.IsReplica = true
+	status.SourceHost = replica.SourceHost
+	status.ReplicaIORunning = replica.ReplicaIORunning
+	status.ReplicaSQLRunning = replica.ReplicaSQLRunning
+	status.SecondsBehindSource = replica.SecondsBehindSource
+	status.LastError = replica.LastError
+	status.LastSQLErrno = replica.LastSQLErrno
+	status.LastIOError = replica.LastIOError
+	return status
+}
+
+// NodeStatus 查询单个节点（master/slave，按配置中的角色）的复制状态
+func (m *DBManager) NodeStatus(ctx context.Context, node string) (ReplicationStatus, error) {
+	db, cfg, err := m.Node(node)
+	if err != nil {
+		return ReplicationStatus{}, err
+	}
+	active := m.IsMasterActive() == (node == "master")
+	return m.replicationStatus(ctx, node, db, cfg.Host, cfg.Port, active), nil
+}
+
+// Node 按配置中的角色（master/slave）获取节点连接及其配置
+func (m *DBManager) Node(node string) (*gorm.DB, config.DBConfig, error) {
+	switch node {
+	case "master":
+		return m.GetMasterDB(), m.config.MasterDB, nil
+	case "slave":
+		return m.GetSlaveDB(), m.config.SlaveDB, nil
+	}
+	return nil, config.DBConfig{}, fmt.Errorf("unknown node %q, expected master or slave", node)
+}
+
+// PoolStats 获取主从两个节点的连接池统计
+func (m *DBManager) PoolStats() []PoolStats {
+	stats := make([]PoolStats, 0, 2)
+	for _, n := range []struct {
+		name string
+		db   *gorm.DB
+	}{{"master", m.GetMasterDB()}, {"slave", m.GetSlaveDB()}} {
+		sqlDB, err := n.db.DB()
+		if err != nil {
+			continue
+		}
+		stats = append(stats, PoolStats{Node: n.name, Stats: sqlDB.Stats()})
+	}
+	return stats
+}
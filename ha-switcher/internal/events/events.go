@@ -0,0 +1,42 @@
+package events
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// maxEvents 保留的最近事件条数
+const maxEvents = 200
+
+// Event 一条系统事件（切换、健康状态变化、重建步骤等）
+type Event struct {
+	Time    time.Time `json:"time"`
+	Source  string    `json:"source"`  // 产生事件的组件（switcher/health/db/rebuild）
+	Message string    `json:"message"` // 事件描述
+}
+
+var (
+	mu     sync.Mutex
+	recent []Event
+)
+
+// Record 记录一条事件，只保留最近 maxEvents 条
+// 事件与日志互为补充：日志输出完整过程，事件只记录排查故障时需要回看的关键节点
+func Record(source string, format string, args ...interface{}) {
+	event := Event{Time: time.Now(), Source: source, Message: fmt.Sprintf(format, args...)}
+
+	mu.Lock()
+	defer mu.Unlock()
+	recent = append(recent, event)
+	if len(recent) > maxEvents {
+		recent = recent[len(recent)-maxEvents:]
+	}
+}
+
+// Recent 获取最近的事件（按时间先后）
+func Recent() []Event {
+	mu.Lock()
+	defer mu.Unlock()
+	return append([]Event(nil), recent...)
+}
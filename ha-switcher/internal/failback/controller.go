@@ -0,0 +1,329 @@
+package failback
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"ha-switcher/internal/config"
+	"ha-switcher/internal/db"
+	"ha-switcher/internal/events"
+	"ha-switcher/internal/switcher"
+)
+
+var (
+	// ErrNotFailedOver 原主库仍是活跃节点，不需要回切
+	ErrNotFailedOver = errors.New("original master is still active, nothing to fail back")
+	// ErrNotReady 原主库不满足回切条件
+	ErrNotReady = errors.New("original master is not ready for failback")
+	// ErrInProgress 已有回切在执行
+	ErrInProgress = errors.New("failback already in progress")
+)
+
+// Options 手动回切的参数
+type Options struct {
+	Simulate bool `json:"simulate"` // 是否只模拟复制检查和回切中的SQL（本地单实例演示）
+}
+
+// Status 回切控制器的当前状态
+type Status struct {
+	Auto         bool      `json:"auto"`                 // 是否自动回切
+	Simulate     bool      `json:"simulate"`             // 自动回切是否只模拟复制检查和SQL
+	StableFor    string    `json:"stable_for"`           // 自动回切前原主库需要持续就绪的时长
+	MasterActive bool      `json:"master_active"`        // 原主库是否为活跃节点（此时不需要回切）
+	Ready        bool      `json:"ready"`                // 最近一次检查时原主库是否满足回切条件
+	Reason       string    `json:"reason,omitempty"`     // 不满足回切条件的原因
+	ReadySince   time.Time `json:"ready_since"`          // 连续满足回切条件的开始时间
+	LastCheck    time.Time `json:"last_check"`           // 最近一次检查的时间
+	InProgress   bool      `json:"in_progress"`          // 是否正在回切
+	Failbacks    int       `json:"failbacks"`            // 已完成的回切次数
+	LastFailback time.Time `json:"last_failback"`        // 最近一次回切的时间
+	LastError    string    `json:"last_error,omitempty"` // 最近一次回切失败的原因
+}
+
+// Controller 故障切换后持续检查原主库，原主库恢复、重新作为从库追平并稳定一段时间后切回原主库
+type Controller struct {
+	dbManager *db.DBManager
+	switcher  *switcher.Switcher
+	config    *config.Config
+	status    Status
+	mu        sync.Mutex
+	stopChan  chan struct{}
+	wg        sync.WaitGroup
+	isRunning bool
+}
+
+// NewController 创建回切控制器
+func NewController(dbManager *db.DBManager, sw *switcher.Switcher, cfg *config.Config) *Controller {
+	return &Controller{
+		dbManager: dbManager,
+		switcher:  sw,
+		config:    cfg,
+		status: Status{
+			Auto:         cfg.Failback.Auto,
+			Simulate:     cfg.Failback.Simulate,
+			StableFor:    cfg.Failback.StableFor.String(),
+			MasterActive: dbManager.IsMasterActive(),
+		},
+		stopChan: make(chan struct{}),
+	}
+}
+
+// Start 开始按健康检查间隔检查原主库
+func (c *Controller) Start() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.isRunning {
+		return
+	}
+
+	c.isRunning = true
+	c.wg.Add(1)
+	go c.run()
+	log.Printf("Failback controller started (auto: %v, stable for: %v)", c.config.Failback.Auto, c.config.Failback.StableFor)
+}
+
+// Stop 停止检查，等待正在进行的回切结束
+func (c *Controller) Stop() {
+	c.mu.Lock()
+	if !c.isRunning {
+		c.mu.Unlock()
+		return
+	}
+	c.isRunning = false
+	c.mu.Unlock()
+
+	close(c.stopChan)
+	c.wg.Wait()
+	log.Println("Failback controller stopped")
+}
+
+// Status 获取回切控制器的当前状态
+func (c *Controller) Status() Status {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.status
+}
+
+// Failback 立即回切到原主库（由操作员触发）：不等待稳定时长、不受防抖动保护限制，但原主库仍需满足回切条件
+func (c *Controller) Failback(ctx context.Context, opts Options) error {
+	if c.dbManager.IsMasterActive() {
+		return ErrNotFailedOver
+	}
+	if reason := c.readiness(ctx, opts.Simulate); reason != "" {
+		return fmt.Errorf("%w: %s", ErrNotReady, reason)
+	}
+	log.Println("Manual failback requested by operator")
+	return c.failback(ctx, opts.Simulate, "manual")
+}
+
+// run 检查循环
+func (c *Controller) run() {
+	defer c.wg.Done()
+
+	ticker := time.NewTicker(c.config.HealthCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			c.check()
+		case <-c.stopChan:
+			return
+		}
+	}
+}
+
+// check 检查原主库是否满足回切条件：连续满足的时长达到 StableFor 且防抖动保护允许时自动回切，
+// 期间任何一次检查不满足都重新计时，避免原主库时好时坏时来回切换
+func (c *Controller) check() {
+	ctx := context.Background()
+	masterActive := c.dbManager.IsMasterActive()
+	reason := ""
+	if !masterActive {
+		reason = c.readiness(ctx, c.config.Failback.Simulate)
+	}
+	now := time.Now()
+
+	c.mu.Lock()
+	c.status.MasterActive = masterActive
+	c.status.LastCheck = now
+	c.status.Reason = reason
+	if masterActive || reason != "" {
+		if c.status.Ready && !masterActive {
+			log.Printf("Original master no longer ready for failback: %s", reason)
+		}
+		c.status.Ready = false
+		c.status.ReadySince = time.Time{}
+		c.mu.Unlock()
+		return
+	}
+	if !c.status.Ready {
+		c.status.Ready = true
+		c.status.ReadySince = now
+		log.Printf("Original master ready for failback, waiting %v before switching back", c.config.Failback.StableFor)
+		events.Record("failback", "original master ready for failback, stable period %v started", c.config.Failback.StableFor)
+	}
+	due := c.config.Failback.Auto && !c.status.InProgress && now.Sub(c.status.ReadySince) >= c.config.Failback.StableFor
+	c.mu.Unlock()
+
+	if !due {
+		return
+	}
+	if err := c.switcher.AllowAutomaticSwitch(); err != nil {
+		log.Printf("Automatic failback refused: %v", err)
+		return
+	}
+	if err := c.failback(ctx, c.config.Failback.Simulate, "automatic"); err != nil {
+		log.Printf("Automatic failback failed: %v", err)
+	}
+}
+
+// readiness 检查原主库是否满足回切条件，满足时返回空字符串，否则返回原因：
+// 原主库可以连接、已通过重建流程重新纳入故障切换候选，并且作为从库复制正常、没有延迟
+func (c *Controller) readiness(ctx context.Context, simulate bool) string {
+	if err := c.dbManager.ProbeMaster(); err != nil {
+		return fmt.Sprintf("original master unreachable: %v", err)
+	}
+	if !c.dbManager.IsFailoverCandidateReady() {
+		return "original master has not been readmitted as a replica of the active slave (see /api/rebuild/start)"
+	}
+	if simulate {
+		return ""
+	}
+
+	status, err := c.dbManager.NodeStatus(ctx, "master")
+	switch {
+	case err != nil:
+		return err.Error()
+	case status.Error != "":
+		return fmt.Sprintf("failed to query replication status of original master: %s", status.Error)
+	case !status.IsReplica:
+		return "original master is not replicating from the active slave"
+	case status.ReplicaIORunning != "Yes" || status.ReplicaSQLRunning != "Yes":
+		return fmt.Sprintf("replication threads on original master not running (io: %s, sql: %s)",
+			status.ReplicaIORunning, status.ReplicaSQLRunning)
+	case status.SecondsBehindSource == nil:
+		return "replication lag of original master unknown"
+	case *status.SecondsBehindSource > 0:
+		return fmt.Sprintf("original master is %ds behind the active slave", *status.SecondsBehindSource)
+	}
+	return ""
+}
+
+// failback 执行回切并记录结果
+func (c *Controller) failback(ctx context.Context, simulate bool, trigger string) error {
+	c.mu.Lock()
+	if c.status.InProgress {
+		c.mu.Unlock()
+		return ErrInProgress
+	}
+	c.status.InProgress = true
+	c.mu.Unlock()
+
+	events.Record("failback", "%s failback started (simulate: %v)", trigger, simulate)
+	standbyReady, err := c.switchBack(ctx, simulate)
+	if err == nil {
+		c.switcher.FailbackToMaster(standbyReady)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.status.InProgress = false
+	if err != nil {
+		c.status.LastError = err.Error()
+		events.Record("failback", "%s failback failed: %v", trigger, err)
+		return err
+	}
+	c.status.Failbacks++
+	c.status.LastFailback = time.Now()
+	c.status.LastError = ""
+	c.status.MasterActive = true
+	c.status.Ready = false
+	c.status.Reason = ""
+	c.status.ReadySince = time.Time{}
+	return nil
+}
+
+// switchBack 在数据库上完成回切：隔离从库，等待原主库应用完从库的所有事务，
+// 停止原主库的复制并开放写入，最后把从库配置为原主库的从库。
+// 返回从库是否已重新复制原主库；从库重新配置失败不影响回切，但它暂时不能作为故障切换候选
+func (c *Controller) switchBack(ctx context.Context, simulate bool) (bool, error) {
+	if simulate {
+		return true, nil
+	}
+
+	master := c.dbManager.GetMasterDB().WithContext(ctx)
+	slave := c.dbManager.GetSlaveDB().WithContext(ctx)
+
+	if err := slave.Exec("SET GLOBAL read_only = ON").Error; err != nil {
+		return false, fmt.Errorf("failed to fence active slave: %w", err)
+	}
+	unfence := func() {
+		if err := slave.Exec("SET GLOBAL read_only = OFF").Error; err != nil {
+			log.Printf("Failed to lift read_only on active slave after aborted failback: %v", err)
+		}
+	}
+
+	if err := c.waitApplied(ctx); err != nil {
+		unfence()
+		return false, err
+	}
+
+	for _, stmt := range []string{"STOP REPLICA", "RESET REPLICA ALL", "SET GLOBAL read_only = OFF"} {
+		if err := master.Exec(stmt).Error; err != nil {
+			// 原主库可能已停止复制，不能再作为故障切换候选，需要重新执行重建流程
+			c.dbManager.SetFailoverCandidate(false)
+			unfence()
+			return false, fmt.Errorf("failed to promote original master: %w", err)
+		}
+	}
+
+	source := c.config.MasterDB
+	statements := []string{
+		"STOP REPLICA",
+		fmt.Sprintf("CHANGE REPLICATION SOURCE TO SOURCE_HOST='%s', SOURCE_PORT=%d, SOURCE_USER='%s', SOURCE_PASSWORD='%s', SOURCE_AUTO_POSITION=1",
+			source.Host, source.Port, source.Username, source.Password),
+		"START REPLICA",
+	}
+	for _, stmt := range statements {
+		if err := slave.Exec(stmt).Error; err != nil {
+			log.Printf("Warning: failed to configure slave to replicate from original master: %v", err)
+			events.Record("failback", "slave not replicating from original master after failback: %v", err)
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// waitApplied 等待原主库应用完（已隔离的）从库上执行过的所有事务
+func (c *Controller) waitApplied(ctx context.Context) error {
+	var executed string
+	if err := c.dbManager.GetSlaveDB().WithContext(ctx).Raw("SELECT @@global.gtid_executed").Scan(&executed).Error; err != nil {
+		return fmt.Errorf("failed to read gtid_executed of active slave: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, c.config.Failback.CatchUpTimeout)
+	defer cancel()
+
+	ticker := time.NewTicker(500 * time.Millisecond)
+	defer ticker.Stop()
+
+	for {
+		var applied bool
+		err := c.dbManager.GetMasterDB().WithContext(ctx).Raw("SELECT GTID_SUBSET(?, @@global.gtid_executed)", executed).Scan(&applied).Error
+		if err == nil && applied {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("original master did not apply all transactions of active slave: %w", ctx.Err())
+		case <-ticker.C:
+		}
+	}
+}
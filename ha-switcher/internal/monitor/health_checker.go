@@ -8,18 +8,31 @@ import (
 
 	"ha-switcher/internal/config"
 	"ha-switcher/internal/db"
+	"ha-switcher/internal/events"
 )
 
+// maxHealthSamples 每个节点保留的健康检查记录条数
+const maxHealthSamples = 50
+
+// HealthSample 一次健康检查的结果
+type HealthSample struct {
+	Time      time.Time `json:"time"`
+	Healthy   bool      `json:"healthy"`
+	LatencyMs float64   `json:"latency_ms"`
+	Error     string    `json:"error,omitempty"`
+}
+
 // HealthChecker 负责监控主库健康状态并在必要时触发切换
 type HealthChecker struct {
-	dbManager *db.DBManager      // 数据库管理器
-	switcher  *switcher.Switcher // 切换器
-	config    *config.Config     // 配置信息
-	failCount int                // 连续失败计数
-	stopChan  chan struct{}      // 停止信号通道
-	wg        sync.WaitGroup     // 等待组，用于优雅关闭
-	mu        sync.Mutex         // 互斥锁，保护状态更改
-	isRunning bool               // 监控器是否正在运行
+	dbManager *db.DBManager             // 数据库管理器
+	switcher  *switcher.Switcher        // 切换器
+	config    *config.Config            // 配置信息
+	failCount int                       // 连续失败计数
+	stopChan  chan struct{}             // 停止信号通道
+	wg        sync.WaitGroup            // 等待组，用于优雅关闭
+	mu        sync.Mutex                // 互斥锁，保护状态更改
+	isRunning bool                      // 监控器是否正在运行
+	history   map[string][]HealthSample // 各节点最近的健康检查记录（master/slave）
 }
 
 // NewHealthChecker 创建一个新的健康监控器
@@ -30,6 +43,7 @@ func NewHealthChecker(dbManager *db.DBManager, cfg *config.Config, sw *switcher.
 		config:    cfg,
 		failCount: 0,
 		stopChan:  make(chan struct{}),
+		history:   make(map[string][]HealthSample),
 	}
 }
 
@@ -92,26 +106,72 @@ func (hc *HealthChecker) checkHealth() {
 	defer hc.mu.Unlock()
 
 	// 检查主库健康状态
-	isHealthy := hc.dbManager.CheckMasterHealth()
+	err := hc.record("master", hc.dbManager.ProbeMaster)
+	isHealthy := err == nil
+	if !isHealthy {
+		log.Printf("Master health check failed: %v", err)
+	}
+	// 从库只记录健康历史，供诊断使用
+	hc.record("slave", hc.dbManager.ProbeSlave)
 
 	if isHealthy {
 		// 主库正常，重置失败计数
 		if hc.failCount > 0 {
 			log.Println("Master database recovered after failures")
+			events.Record("health", "master recovered after %d failed checks", hc.failCount)
 			hc.failCount = 0
 		}
 	} else {
 		// 主库异常，增加失败计数
 		hc.failCount++
 		log.Printf("Master database health check failed (%d/%d)", hc.failCount, hc.config.FailThreshold)
+		if hc.failCount == 1 {
+			events.Record("health", "master health check failed")
+		}
 
 		// 如果连续失败次数达到阈值，触发切换
 		if hc.failCount >= hc.config.FailThreshold {
 			log.Printf("Failure threshold reached (%d). Triggering failover to slave", hc.config.FailThreshold)
-			hc.switcher.SwitchToSlave()
+			events.Record("health", "failure threshold reached (%d), triggering failover", hc.config.FailThreshold)
+			if err := hc.switcher.SwitchToSlave(); err != nil {
+				log.Printf("Failover not performed: %v", err)
+			}
 
-			// 切换后重置计数器
+			// 切换（或被防抖动保护拒绝）后重置计数器
 			hc.failCount = 0
 		}
 	}
 }
+
+// record 执行一次探测并记录到节点的健康历史中，返回探测结果
+func (hc *HealthChecker) record(node string, probe func() error) error {
+	start := time.Now()
+	err := probe()
+	sample := HealthSample{
+		Time:      start,
+		Healthy:   err == nil,
+		LatencyMs: float64(time.Since(start).Microseconds()) / 1000,
+	}
+	if err != nil {
+		sample.Error = err.Error()
+	}
+
+	samples := append(hc.history[node], sample)
+	if len(samples) > maxHealthSamples {
+		samples = samples[len(samples)-maxHealthSamples:]
+	}
+	hc.history[node] = samples
+	return err
+}
+
+// History 获取各节点最近的健康检查记录（按时间先后）
+func (hc *HealthChecker) History() map[string][]HealthSample {
+	hc.mu.Lock()
+	defer hc.mu.Unlock()
+
+	history := make(map[string][]HealthSample, len(hc.history))
+	for node, samples := range hc.history {
+		history[node] = append([]HealthSample(nil), samples...)
+	}
+	return history
+}
@@ -0,0 +1,67 @@
+package rebuild
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"strconv"
+
+	"ha-switcher/internal/config"
+)
+
+// Cloner 数据克隆步骤，将目标库的数据重置为源库（新主库）的快照
+type Cloner interface {
+	Name() string
+	Clone(ctx context.Context, source config.DBConfig, target config.DBConfig) error
+}
+
+// SimulatedCloner 模拟克隆，仅记录日志（用于本地演示）
+type SimulatedCloner struct{}
+
+// Name 克隆方式名称
+func (SimulatedCloner) Name() string { return "simulated" }
+
+// Clone 模拟克隆过程
+func (SimulatedCloner) Clone(ctx context.Context, source config.DBConfig, target config.DBConfig) error {
+	// 在实际环境中，这里会使用 CLONE INSTANCE、xtrabackup 或 mysqldump 等工具
+	log.Printf("Cloning %s:%d/%s onto %s:%d/%s (simulated)",
+		source.Host, source.Port, source.Database, target.Host, target.Port, target.Database)
+	return nil
+}
+
+// CommandCloner 调用外部命令完成克隆（如封装了xtrabackup或mysqldump的脚本）
+// 源库和目标库的连接信息通过环境变量 SOURCE_HOST/SOURCE_PORT/SOURCE_USER/SOURCE_PASSWORD/SOURCE_DB
+// 以及 TARGET_* 传递给命令
+type CommandCloner struct {
+	Command string   // 可执行文件路径
+	Args    []string // 命令参数
+}
+
+// Name 克隆方式名称
+func (c *CommandCloner) Name() string { return "command:" + c.Command }
+
+// Clone 执行外部克隆命令
+func (c *CommandCloner) Clone(ctx context.Context, source config.DBConfig, target config.DBConfig) error {
+	cmd := exec.CommandContext(ctx, c.Command, c.Args...)
+	cmd.Env = append(os.Environ(),
+		"SOURCE_HOST="+source.Host,
+		"SOURCE_PORT="+strconv.Itoa(source.Port),
+		"SOURCE_USER="+source.Username,
+		"SOURCE_PASSWORD="+source.Password,
+		"SOURCE_DB="+source.Database,
+		"TARGET_HOST="+target.Host,
+		"TARGET_PORT="+strconv.Itoa(target.Port),
+		"TARGET_USER="+target.Username,
+		"TARGET_PASSWORD="+target.Password,
+		"TARGET_DB="+target.Database,
+	)
+
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("clone command failed: %w, output: %s", err, string(output))
+	}
+	log.Printf("Clone command completed: %s", string(output))
+	return nil
+}
@@ -0,0 +1,295 @@
+package rebuild
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"ha-switcher/internal/config"
+	"ha-switcher/internal/db"
+	"ha-switcher/internal/events"
+)
+
+// State 重建流程的状态
+type State string
+
+// 重建流程的各个状态，按顺序推进
+const (
+	StateIdle        State = "idle"        // 未开始
+	StateFenceCheck  State = "fence_check" // 确认旧主库已隔离（只读且不再接收写入）
+	StateCloning     State = "cloning"     // 从新主库克隆数据
+	StateConfiguring State = "configuring" // 配置复制关系
+	StateCatchingUp  State = "catching_up" // 等待复制追平
+	StateReadmitted  State = "readmitted"  // 已重新成为故障切换候选
+	StateFailed      State = "failed"      // 流程失败
+	StateAborted     State = "aborted"     // 被操作员中止
+)
+
+// ErrAlreadyRunning 已有重建流程在执行
+var ErrAlreadyRunning = errors.New("rebuild workflow already running")
+
+// Options 重建流程的参数
+type Options struct {
+	Fence          bool `json:"fence"`                // 旧主库未只读时是否自动设置为只读
+	Simulate       bool `json:"simulate"`             // 是否只模拟复制配置和追平检查（本地单实例演示）
+	CatchUpTimeout int  `json:"catch_up_timeout_sec"` // 等待追平的超时时间（秒）
+}
+
+// Transition 一次状态变化记录
+type Transition struct {
+	From    State     `json:"from"`
+	To      State     `json:"to"`
+	Message string    `json:"message"`
+	Time    time.Time `json:"time"`
+}
+
+// Status 重建流程的当前状态
+type Status struct {
+	State     State        `json:"state"`
+	Cloner    string       `json:"cloner"`
+	Options   Options      `json:"options"`
+	StartedAt time.Time    `json:"started_at"`
+	LastError string       `json:"last_error,omitempty"`
+	History   []Transition `json:"history"`
+}
+
+// Workflow 将故障切换后的旧主库重建为新主库的从库，并重新纳入故障切换候选
+type Workflow struct {
+	dbManager *db.DBManager
+	config    *config.Config
+	cloner    Cloner
+	status    Status
+	cancel    context.CancelFunc
+	mu        sync.Mutex
+}
+
+// NewWorkflow 创建重建流程，cloner为空时使用模拟克隆
+func NewWorkflow(dbManager *db.DBManager, cfg *config.Config, cloner Cloner) *Workflow {
+	if cloner == nil {
+		cloner = SimulatedCloner{}
+	}
+	return &Workflow{
+		dbManager: dbManager,
+		config:    cfg,
+		cloner:    cloner,
+		status:    Status{State: StateIdle, Cloner: cloner.Name()},
+	}
+}
+
+// Start 开始重建流程（在后台执行）
+func (w *Workflow) Start(opts Options) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.cancel != nil {
+		return ErrAlreadyRunning
+	}
+	if w.dbManager.IsMasterActive() {
+		return errors.New("no failover has happened, the original master is still active")
+	}
+	if opts.CatchUpTimeout <= 0 {
+		opts.CatchUpTimeout = 300
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	w.cancel = cancel
+	w.status = Status{
+		State:     StateIdle,
+		Cloner:    w.cloner.Name(),
+		Options:   opts,
+		StartedAt: time.Now(),
+	}
+
+	go w.run(ctx, opts)
+	return nil
+}
+
+// Abort 中止正在执行的重建流程
+func (w *Workflow) Abort() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.cancel == nil {
+		return errors.New("no rebuild workflow is running")
+	}
+	w.cancel()
+	return nil
+}
+
+// Status 获取流程状态
+func (w *Workflow) Status() Status {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	status := w.status
+	status.History = append([]Transition(nil), w.status.History...)
+	return status
+}
+
+// run 按顺序执行各步骤
+func (w *Workflow) run(ctx context.Context, opts Options) {
+	defer func() {
+		w.mu.Lock()
+		w.cancel = nil
+		w.mu.Unlock()
+	}()
+
+	steps := []struct {
+		state State
+		run   func(context.Context, Options) (string, error)
+	}{
+		{StateFenceCheck, w.checkFenced},
+		{StateCloning, w.clone},
+		{StateConfiguring, w.configureReplication},
+		{StateCatchingUp, w.waitCaughtUp},
+	}
+
+	for _, step := range steps {
+		w.transition(step.state, "")
+		message, err := step.run(ctx, opts)
+		if err != nil {
+			if ctx.Err() != nil {
+				w.transition(StateAborted, "aborted by operator")
+			} else {
+				w.fail(err)
+			}
+			return
+		}
+		w.record(message)
+	}
+
+	w.dbManager.SetFailoverCandidate(true)
+	w.transition(StateReadmitted, "old master rebuilt as replica and re-admitted as failover candidate")
+}
+
+// checkFenced 确认旧主库不再接收写入
+func (w *Workflow) checkFenced(ctx context.Context, opts Options) (string, error) {
+	oldMaster := w.dbManager.GetMasterDB().WithContext(ctx)
+
+	var readOnly int
+	if err := oldMaster.Raw("SELECT @@global.read_only").Scan(&readOnly).Error; err != nil {
+		if opts.Simulate {
+			return fmt.Sprintf("old master unreachable (%v), treated as fenced in simulate mode", err), nil
+		}
+		return "", fmt.Errorf("failed to check read_only on old master: %w", err)
+	}
+	if readOnly == 1 {
+		return "old master is read-only", nil
+	}
+
+	if !opts.Fence {
+		if opts.Simulate {
+			return "old master is writable, ignored in simulate mode", nil
+		}
+		return "", errors.New("old master is still writable; fence it first or start with fence=true")
+	}
+	if err := oldMaster.Exec("SET GLOBAL read_only = ON").Error; err != nil {
+		return "", fmt.Errorf("failed to fence old master: %w", err)
+	}
+	return "old master fenced (read_only=ON)", nil
+}
+
+// clone 从新主库克隆数据到旧主库
+func (w *Workflow) clone(ctx context.Context, opts Options) (string, error) {
+	if err := w.cloner.Clone(ctx, w.config.SlaveDB, w.config.MasterDB); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("data cloned from new primary using %s cloner", w.cloner.Name()), nil
+}
+
+// configureReplication 将旧主库配置为新主库的从库
+func (w *Workflow) configureReplication(ctx context.Context, opts Options) (string, error) {
+	source := w.config.SlaveDB
+	if opts.Simulate {
+		return fmt.Sprintf("replication from %s:%d configured (simulated)", source.Host, source.Port), nil
+	}
+
+	oldMaster := w.dbManager.GetMasterDB().WithContext(ctx)
+	statements := []string{
+		"STOP REPLICA",
+		fmt.Sprintf("CHANGE REPLICATION SOURCE TO SOURCE_HOST='%s', SOURCE_PORT=%d, SOURCE_USER='%s', SOURCE_PASSWORD='%s', SOURCE_AUTO_POSITION=1",
+			source.Host, source.Port, source.Username, source.Password),
+		"START REPLICA",
+	}
+	for _, stmt := range statements {
+		if err := oldMaster.Exec(stmt).Error; err != nil {
+			return "", fmt.Errorf("failed to configure replication: %w", err)
+		}
+	}
+	return fmt.Sprintf("replication from %s:%d started", source.Host, source.Port), nil
+}
+
+// waitCaughtUp 等待复制追平
+func (w *Workflow) waitCaughtUp(ctx context.Context, opts Options) (string, error) {
+	if opts.Simulate {
+		return "replica caught up (simulated)", nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(opts.CatchUpTimeout)*time.Second)
+	defer cancel()
+
+	ticker := time.NewTicker(2 * time.Second)
+	defer ticker.Stop()
+
+	for {
+		var status struct {
+			ReplicaIORunning    string `gorm:"column:Replica_IO_Running"`
+			ReplicaSQLRunning   string `gorm:"column:Replica_SQL_Running"`
+			SecondsBehindSource *int64 `gorm:"column:Seconds_Behind_Source"`
+		}
+		err := w.dbManager.GetMasterDB().WithContext(ctx).Raw("SHOW REPLICA STATUS").Scan(&status).Error
+		if err == nil && status.ReplicaIORunning == "Yes" && status.ReplicaSQLRunning == "Yes" &&
+			status.SecondsBehindSource != nil && *status.SecondsBehindSource == 0 {
+			return "replica caught up with new primary", nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return "", fmt.Errorf("replica did not catch up: %w", ctx.Err())
+		case <-ticker.C:
+		}
+	}
+}
+
+// transition 切换状态并记录历史
+func (w *Workflow) transition(to State, message string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.status.History = append(w.status.History, Transition{
+		From:    w.status.State,
+		To:      to,
+		Message: message,
+		Time:    time.Now(),
+	})
+	w.status.State = to
+	log.Printf("Rebuild workflow: %s", to)
+	if message != "" {
+		events.Record("rebuild", "entered %s: %s", to, message)
+	} else {
+		events.Record("rebuild", "entered %s", to)
+	}
+}
+
+// record 为当前状态追加步骤结果说明
+func (w *Workflow) record(message string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if n := len(w.status.History); n > 0 {
+		w.status.History[n-1].Message = message
+	}
+	log.Printf("Rebuild workflow step %s: %s", w.status.State, message)
+}
+
+// fail 将流程标记为失败
+func (w *Workflow) fail(err error) {
+	w.mu.Lock()
+	w.status.LastError = err.Error()
+	w.mu.Unlock()
+
+	w.transition(StateFailed, err.Error())
+}
@@ -0,0 +1,434 @@
+package repair
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"ha-switcher/internal/config"
+	"ha-switcher/internal/db"
+	"ha-switcher/internal/events"
+	"ha-switcher/internal/rebuild"
+
+	"gorm.io/gorm"
+)
+
+// Problem 复制故障类型
+type Problem string
+
+// 可识别的复制故障
+const (
+	ProblemDuplicateKey     Problem = "duplicate_key"      // 回放时主键/唯一键冲突（1062），从库上已有这行
+	ProblemMissingRow       Problem = "missing_row"        // 回放UPDATE/DELETE时找不到行（1032），从库上缺少这行
+	ProblemSQLError         Problem = "sql_error"          // 其他导致SQL线程停止的错误
+	ProblemSQLThreadStopped Problem = "sql_thread_stopped" // SQL线程已停止但没有错误（如被手动停止）
+	ProblemIOThreadStopped  Problem = "io_thread_stopped"  // IO线程未运行（连不上源库、认证失败等）
+)
+
+// Action 修复动作
+type Action string
+
+// 可执行的修复动作
+const (
+	ActionSkipEvent      Action = "skip_event"      // 跳过导致错误的那一个事务
+	ActionRestartThreads Action = "restart_threads" // 重启复制线程（STOP REPLICA; START REPLICA）
+	ActionReclone        Action = "reclone"         // 从活跃节点重新克隆数据后重新开始复制
+)
+
+// 修复相关的MySQL错误码
+const (
+	errDuplicateKey = 1062 // ER_DUP_ENTRY
+	errKeyNotFound  = 1032 // ER_KEY_NOT_FOUND
+)
+
+// tokenTTL 确认令牌的有效期
+const tokenTTL = 5 * time.Minute
+
+// maxActions 保留的修复记录条数
+const maxActions = 100
+
+// 确认令牌相关错误
+var (
+	ErrInvalidToken = errors.New("invalid or expired confirmation token")
+	ErrStateChanged = errors.New("replication state changed since diagnosis, diagnose again")
+)
+
+// Fix 针对某个故障提供的修复方案，执行时需要带上确认令牌
+type Fix struct {
+	Action      Action    `json:"action"`
+	Description string    `json:"description"` // 修复方案说明（包括风险）
+	Token       string    `json:"token"`       // 确认令牌，在有效期内使用一次
+	ExpiresAt   time.Time `json:"expires_at"`
+}
+
+// Issue 一个节点上识别出的复制故障
+type Issue struct {
+	Node    string  `json:"node"`            // master/slave（按配置中的角色）
+	Problem Problem `json:"problem"`         // 故障类型
+	Errno   int     `json:"errno,omitempty"` // SQL线程错误码
+	Error   string  `json:"error,omitempty"` // 复制线程报告的错误
+	Advice  string  `json:"advice"`          // 排查建议
+	Fixes   []Fix   `json:"fixes"`           // 可选的修复方案
+}
+
+// Diagnosis 一次诊断的结果
+type Diagnosis struct {
+	Time   time.Time              `json:"time"`
+	Nodes  []db.ReplicationStatus `json:"nodes"`  // 各节点的复制状态
+	Issues []Issue                `json:"issues"` // 识别出的故障（没有故障时为空）
+}
+
+// ActionRecord 一次修复动作的记录
+type ActionRecord struct {
+	Time     time.Time `json:"time"`
+	Node     string    `json:"node"`
+	Problem  Problem   `json:"problem"`
+	Action   Action    `json:"action"`
+	Status   string    `json:"status"`           // running/succeeded/failed
+	Detail   string    `json:"detail,omitempty"` // 执行的操作或结果说明
+	Error    string    `json:"error,omitempty"`  // 失败原因
+	Finished time.Time `json:"finished"`
+}
+
+// pendingFix 已发放、尚未使用的确认令牌
+type pendingFix struct {
+	issue     Issue
+	action    Action
+	snapshot  string // 诊断时的复制状态摘要，执行前重新比对
+	expiresAt time.Time
+}
+
+// Assistant 复制修复助手：诊断复制线程故障，提供带确认令牌的修复方案并记录每次修复
+type Assistant struct {
+	dbManager *db.DBManager
+	config    *config.Config
+	cloner    rebuild.Cloner
+	pending   map[string]pendingFix
+	actions   []ActionRecord
+	mu        sync.Mutex
+}
+
+// NewAssistant 创建复制修复助手，cloner为空时使用模拟克隆
+func NewAssistant(dbManager *db.DBManager, cfg *config.Config, cloner rebuild.Cloner) *Assistant {
+	if cloner == nil {
+		cloner = rebuild.SimulatedCloner{}
+	}
+	return &Assistant{
+		dbManager: dbManager,
+		config:    cfg,
+		cloner:    cloner,
+		pending:   make(map[string]pendingFix),
+	}
+}
+
+// Diagnose 查询两个节点的复制状态，识别故障并为每个修复方案发放确认令牌
+func (a *Assistant) Diagnose(ctx context.Context) Diagnosis {
+	diagnosis := Diagnosis{Time: time.Now(), Nodes: a.dbManager.ReplicationStatus(ctx), Issues: []Issue{}}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.expireTokens()
+	for _, status := range diagnosis.Nodes {
+		issue, ok := classify(status)
+		if !ok {
+			continue
+		}
+		for _, action := range fixesFor(issue.Problem) {
+			token := newToken()
+			expiresAt := diagnosis.Time.Add(tokenTTL)
+			a.pending[token] = pendingFix{issue: issue, action: action, snapshot: snapshot(status), expiresAt: expiresAt}
+			issue.Fixes = append(issue.Fixes, Fix{
+				Action:      action,
+				Description: describe(action, issue.Problem),
+				Token:       token,
+				ExpiresAt:   expiresAt,
+			})
+		}
+		diagnosis.Issues = append(diagnosis.Issues, issue)
+	}
+	return diagnosis
+}
+
+// Apply 使用确认令牌执行修复
+// 执行前重新查询节点的复制状态，与诊断时不一致（如故障已变化或已被修复）时拒绝执行，需要重新诊断
+// 克隆在后台执行，其余动作同步执行；返回的记录也会出现在 Actions 中
+func (a *Assistant) Apply(ctx context.Context, token string) (ActionRecord, error) {
+	a.mu.Lock()
+	a.expireTokens()
+	fix, ok := a.pending[token]
+	if ok {
+		// 令牌只能使用一次；同一故障的其他方案也一并作废，避免对同一个错误重复修复
+		for t, p := range a.pending {
+			if p.issue.Node == fix.issue.Node {
+				delete(a.pending, t)
+			}
+		}
+	}
+	a.mu.Unlock()
+	if !ok {
+		return ActionRecord{}, ErrInvalidToken
+	}
+
+	status, err := a.dbManager.NodeStatus(ctx, fix.issue.Node)
+	if err != nil {
+		return ActionRecord{}, err
+	}
+	if snapshot(status) != fix.snapshot {
+		return ActionRecord{}, ErrStateChanged
+	}
+
+	record := ActionRecord{
+		Time:    time.Now(),
+		Node:    fix.issue.Node,
+		Problem: fix.issue.Problem,
+		Action:  fix.action,
+		Status:  "running",
+	}
+	events.Record("repair", "%s on %s (%s) confirmed", fix.action, fix.issue.Node, fix.issue.Problem)
+
+	if fix.action == ActionReclone {
+		index := a.addAction(record)
+		go func() {
+			detail, err := a.reclone(context.Background(), fix.issue.Node)
+			a.finishAction(index, detail, err)
+		}()
+		record.Detail = "clone started in background, see /api/repair/actions"
+		return record, nil
+	}
+
+	index := a.addAction(record)
+	var detail string
+	switch fix.action {
+	case ActionSkipEvent:
+		detail, err = a.skipEvent(ctx, fix.issue.Node)
+	case ActionRestartThreads:
+		detail, err = a.restartThreads(ctx, fix.issue.Node)
+	default:
+		err = fmt.Errorf("unsupported repair action %s", fix.action)
+	}
+	return a.finishAction(index, detail, err), err
+}
+
+// Actions 获取最近的修复记录（按时间先后）
+func (a *Assistant) Actions() []ActionRecord {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return append([]ActionRecord(nil), a.actions...)
+}
+
+// skipEvent 跳过SQL线程出错的那一个事务
+// 开启GTID时注入一个同GTID的空事务，否则使用 sql_replica_skip_counter；之后重新启动SQL线程
+func (a *Assistant) skipEvent(ctx context.Context, node string) (string, error) {
+	conn, _, err := a.dbManager.Node(node)
+	if err != nil {
+		return "", err
+	}
+
+	var detail string
+	// 设置GTID_NEXT是会话级的，所有语句必须在同一个连接上执行
+	err = conn.WithContext(ctx).Connection(func(tx *gorm.DB) error {
+		var gtidMode string
+		if err := tx.Raw("SELECT @@GLOBAL.gtid_mode").Scan(&gtidMode).Error; err != nil {
+			return fmt.Errorf("failed to read gtid_mode: %w", err)
+		}
+
+		if gtidMode != "ON" {
+			detail = "skipped one event with sql_replica_skip_counter"
+			return execAll(tx, "STOP REPLICA SQL_THREAD", "SET GLOBAL sql_replica_skip_counter = 1", "START REPLICA SQL_THREAD")
+		}
+
+		var gtid string
+		err := tx.Raw("SELECT APPLYING_TRANSACTION FROM performance_schema.replication_applier_status_by_worker " +
+			"WHERE LAST_ERROR_NUMBER <> 0 AND APPLYING_TRANSACTION <> '' LIMIT 1").Scan(&gtid).Error
+		if err != nil {
+			return fmt.Errorf("failed to find failing transaction: %w", err)
+		}
+		if gtid == "" {
+			return errors.New("failing transaction not found in performance_schema, cannot skip safely")
+		}
+		detail = fmt.Sprintf("skipped transaction %s by committing an empty transaction with the same GTID", gtid)
+		return execAll(tx,
+			"STOP REPLICA SQL_THREAD",
+			fmt.Sprintf("SET GTID_NEXT = '%s'", gtid),
+			"BEGIN", "COMMIT",
+			"SET GTID_NEXT = 'AUTOMATIC'",
+			"START REPLICA SQL_THREAD")
+	})
+	return detail, err
+}
+
+// restartThreads 重启复制IO和SQL线程
+func (a *Assistant) restartThreads(ctx context.Context, node string) (string, error) {
+	conn, _, err := a.dbManager.Node(node)
+	if err != nil {
+		return "", err
+	}
+	if err := execAll(conn.WithContext(ctx), "STOP REPLICA", "START REPLICA"); err != nil {
+		return "", err
+	}
+	return "replication threads restarted", nil
+}
+
+// reclone 从当前活跃节点重新克隆数据到出错的节点，然后重新开始复制
+func (a *Assistant) reclone(ctx context.Context, node string) (string, error) {
+	conn, target, err := a.dbManager.Node(node)
+	if err != nil {
+		return "", err
+	}
+	source := a.config.MasterDB
+	if !a.dbManager.IsMasterActive() {
+		source = a.config.SlaveDB
+	}
+	if source == target {
+		return "", errors.New("cannot reclone the active node onto itself")
+	}
+
+	if err := execAll(conn.WithContext(ctx), "STOP REPLICA"); err != nil {
+		return "", err
+	}
+	if err := a.cloner.Clone(ctx, source, target); err != nil {
+		return "", err
+	}
+	if err := execAll(conn.WithContext(ctx), "START REPLICA"); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("recloned from %s:%d using %s cloner and restarted replication", source.Host, source.Port, a.cloner.Name()), nil
+}
+
+// addAction 追加一条修复记录，返回其下标
+func (a *Assistant) addAction(record ActionRecord) int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.actions = append(a.actions, record)
+	if len(a.actions) > maxActions {
+		a.actions = a.actions[len(a.actions)-maxActions:]
+	}
+	return len(a.actions) - 1
+}
+
+// finishAction 记录修复结果并写入事件历史
+func (a *Assistant) finishAction(index int, detail string, err error) ActionRecord {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	// 后台克隆期间记录可能因超出上限被移出，此时只写事件
+	var record ActionRecord
+	if index < len(a.actions) {
+		record = a.actions[index]
+	}
+	record.Finished = time.Now()
+	record.Detail = detail
+	record.Status = "succeeded"
+	if err != nil {
+		record.Status = "failed"
+		record.Error = err.Error()
+		log.Printf("Repair %s on %s failed: %v", record.Action, record.Node, err)
+		events.Record("repair", "%s on %s failed: %v", record.Action, record.Node, err)
+	} else {
+		log.Printf("Repair %s on %s succeeded: %s", record.Action, record.Node, detail)
+		events.Record("repair", "%s on %s succeeded: %s", record.Action, record.Node, detail)
+	}
+	if index < len(a.actions) {
+		a.actions[index] = record
+	}
+	return record
+}
+
+// expireTokens 删除过期的确认令牌（调用方持有锁）
+func (a *Assistant) expireTokens() {
+	now := time.Now()
+	for token, fix := range a.pending {
+		if now.After(fix.expiresAt) {
+			delete(a.pending, token)
+		}
+	}
+}
+
+// classify 根据复制状态识别故障
+func classify(status db.ReplicationStatus) (Issue, bool) {
+	if !status.IsReplica {
+		return Issue{}, false
+	}
+	issue := Issue{Node: status.Node, Errno: status.LastSQLErrno, Error: status.LastError}
+
+	switch {
+	case status.ReplicaSQLRunning != "Yes" && status.LastSQLErrno == errDuplicateKey:
+		issue.Problem = ProblemDuplicateKey
+		issue.Advice = "the replica already has this row, usually after a write went to the replica directly; " +
+			"compare the row with the source before skipping"
+	case status.ReplicaSQLRunning != "Yes" && status.LastSQLErrno == errKeyNotFound:
+		issue.Problem = ProblemMissingRow
+		issue.Advice = "the replica is missing the row the source changed, its data has diverged; " +
+			"skipping keeps it diverged, recloning is the safe fix"
+	case status.ReplicaSQLRunning != "Yes" && status.LastSQLErrno != 0:
+		issue.Problem = ProblemSQLError
+		issue.Advice = "inspect the error and performance_schema.replication_applier_status_by_worker before choosing a fix"
+	case status.ReplicaSQLRunning != "Yes":
+		issue.Problem = ProblemSQLThreadStopped
+		issue.Advice = "the SQL thread was stopped without an error, probably by an operator"
+	case status.ReplicaIORunning != "Yes":
+		issue.Problem = ProblemIOThreadStopped
+		issue.Error = status.LastIOError
+		issue.Advice = "check network connectivity and replication credentials to the source"
+	default:
+		return Issue{}, false
+	}
+	return issue, true
+}
+
+// fixesFor 各类故障可选的修复方案
+func fixesFor(problem Problem) []Action {
+	switch problem {
+	case ProblemDuplicateKey, ProblemMissingRow, ProblemSQLError:
+		return []Action{ActionSkipEvent, ActionReclone}
+	case ProblemSQLThreadStopped, ProblemIOThreadStopped:
+		return []Action{ActionRestartThreads, ActionReclone}
+	}
+	return nil
+}
+
+// describe 修复方案说明
+func describe(action Action, problem Problem) string {
+	switch action {
+	case ActionSkipEvent:
+		if problem == ProblemMissingRow {
+			return "skip the failing transaction; the replica stays diverged from the source for this row"
+		}
+		return "skip the failing transaction and restart the SQL thread"
+	case ActionRestartThreads:
+		return "restart the replication IO and SQL threads"
+	case ActionReclone:
+		return "stop replication, reclone all data from the active node and restart replication (slow, replaces replica data)"
+	}
+	return string(action)
+}
+
+// snapshot 复制状态摘要，用于判断执行修复前状态是否已变化
+func snapshot(status db.ReplicationStatus) string {
+	return fmt.Sprintf("%s|%s|%d|%s|%s", status.ReplicaIORunning, status.ReplicaSQLRunning,
+		status.LastSQLErrno, status.LastError, status.LastIOError)
+}
+
+// execAll 依次执行语句，遇到错误停止
+func execAll(tx *gorm.DB, statements ...string) error {
+	for _, stmt := range statements {
+		if err := tx.Exec(stmt).Error; err != nil {
+			return fmt.Errorf("%s: %w", stmt, err)
+		}
+	}
+	return nil
+}
+
+// newToken 生成随机确认令牌
+func newToken() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return fmt.Sprintf("%x", time.Now().UnixNano())
+	}
+	return hex.EncodeToString(b)
+}
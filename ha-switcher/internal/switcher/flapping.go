@@ -0,0 +1,159 @@
+package switcher
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"ha-switcher/internal/config"
+	"ha-switcher/internal/events"
+)
+
+// flappingWindow 统计切换次数的滑动窗口
+const flappingWindow = time.Hour
+
+var (
+	// ErrSwitchSuppressed 自动切换被防抖动保护拒绝（最小间隔或冷却期内）
+	ErrSwitchSuppressed = errors.New("automatic switch suppressed")
+	// ErrManualMode 切换次数超过上限，已进入手动模式
+	ErrManualMode = errors.New("switcher is in manual mode")
+)
+
+// FlappingStatus 防抖动保护的当前状态
+type FlappingStatus struct {
+	ManualMode         bool      `json:"manual_mode"`           // 是否处于手动模式（自动切换被禁用）
+	ManualReason       string    `json:"manual_reason"`         // 进入手动模式的原因
+	SwitchesLastHour   int       `json:"switches_last_hour"`    // 最近一小时的切换次数
+	MaxSwitchesPerHour int       `json:"max_switches_per_hour"` // 每小时切换次数上限
+	Consecutive        int       `json:"consecutive"`           // 连续切换次数（相邻切换间隔不超过一小时）
+	CooldownUntil      time.Time `json:"cooldown_until"`        // 当前冷却期结束时间
+	CooldownRemaining  string    `json:"cooldown_remaining"`    // 剩余冷却时间
+	MinSwitchInterval  string    `json:"min_switch_interval"`   // 最小切换间隔
+	Suppressed         int       `json:"suppressed"`            // 被拒绝的自动切换次数
+	LastSuppressedAt   time.Time `json:"last_suppressed_at"`    // 最后一次拒绝时间
+}
+
+// flapGuard 防抖动保护，调用方负责加锁
+type flapGuard struct {
+	cfg           config.FlappingConfig
+	history       []time.Time // 最近一小时内的切换时间
+	consecutive   int         // 连续切换次数
+	cooldownUntil time.Time   // 冷却期结束时间
+	manual        bool        // 是否处于手动模式
+	manualReason  string      // 进入手动模式的原因
+	suppressed    int         // 被拒绝的自动切换次数
+	lastSuppress  time.Time   // 最后一次拒绝时间
+}
+
+// allow 判断当前是否允许自动切换，不允许时返回原因
+func (g *flapGuard) allow(now time.Time) error {
+	g.prune(now)
+
+	var err error
+	switch {
+	case g.manual:
+		err = fmt.Errorf("%w: %s", ErrManualMode, g.manualReason)
+	case len(g.history) > 0 && now.Sub(g.history[len(g.history)-1]) < g.cfg.MinSwitchInterval:
+		err = fmt.Errorf("%w: last switch was %v ago, minimum interval is %v",
+			ErrSwitchSuppressed, now.Sub(g.history[len(g.history)-1]).Round(time.Second), g.cfg.MinSwitchInterval)
+	case now.Before(g.cooldownUntil):
+		err = fmt.Errorf("%w: cooling down for another %v",
+			ErrSwitchSuppressed, g.cooldownUntil.Sub(now).Round(time.Second))
+	case g.cfg.MaxSwitchesPerHour > 0 && len(g.history) >= g.cfg.MaxSwitchesPerHour:
+		g.manual = true
+		g.manualReason = fmt.Sprintf("%d switches within the last hour (limit %d)", len(g.history), g.cfg.MaxSwitchesPerHour)
+		err = fmt.Errorf("%w: %s", ErrManualMode, g.manualReason)
+	}
+
+	if err != nil {
+		g.suppressed++
+		g.lastSuppress = now
+	}
+	return err
+}
+
+// record 记录一次切换并计算下一个冷却期
+// 距上一次切换不超过一小时视为连续切换，第n次连续切换后的冷却时间为 BaseCooldown * 2^(n-2)
+func (g *flapGuard) record(now time.Time) {
+	g.prune(now)
+
+	if len(g.history) == 0 {
+		g.consecutive = 0
+	}
+	g.consecutive++
+	g.history = append(g.history, now)
+
+	g.cooldownUntil = time.Time{}
+	if g.consecutive < 2 || g.cfg.BaseCooldown <= 0 {
+		return
+	}
+	cooldown := g.cfg.BaseCooldown
+	for i := 2; i < g.consecutive; i++ {
+		cooldown *= 2
+		if g.cfg.MaxCooldown > 0 && cooldown >= g.cfg.MaxCooldown {
+			break
+		}
+	}
+	if g.cfg.MaxCooldown > 0 && cooldown > g.cfg.MaxCooldown {
+		cooldown = g.cfg.MaxCooldown
+	}
+	g.cooldownUntil = now.Add(cooldown)
+}
+
+// resume 退出手动模式并清空连续切换计数
+func (g *flapGuard) resume() {
+	g.manual = false
+	g.manualReason = ""
+	g.consecutive = 0
+	g.cooldownUntil = time.Time{}
+	g.history = nil
+}
+
+// prune 丢弃滑动窗口之外的切换记录
+func (g *flapGuard) prune(now time.Time) {
+	i := 0
+	for i < len(g.history) && now.Sub(g.history[i]) > flappingWindow {
+		i++
+	}
+	g.history = g.history[i:]
+}
+
+// status 生成状态快照
+func (g *flapGuard) status(now time.Time) FlappingStatus {
+	g.prune(now)
+
+	status := FlappingStatus{
+		ManualMode:         g.manual,
+		ManualReason:       g.manualReason,
+		SwitchesLastHour:   len(g.history),
+		MaxSwitchesPerHour: g.cfg.MaxSwitchesPerHour,
+		Consecutive:        g.consecutive,
+		CooldownRemaining:  "0s",
+		MinSwitchInterval:  g.cfg.MinSwitchInterval.String(),
+		Suppressed:         g.suppressed,
+		LastSuppressedAt:   g.lastSuppress,
+	}
+	if now.Before(g.cooldownUntil) {
+		status.CooldownUntil = g.cooldownUntil
+		status.CooldownRemaining = g.cooldownUntil.Sub(now).Round(time.Second).String()
+	}
+	return status
+}
+
+// FlappingStatus 获取防抖动保护状态
+func (s *Switcher) FlappingStatus() FlappingStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.flap.status(time.Now())
+}
+
+// ResumeAutomatic 退出手动模式，恢复自动切换
+func (s *Switcher) ResumeAutomatic() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.flap.resume()
+	log.Println("Automatic failover resumed by operator")
+	events.Record("switcher", "automatic failover resumed by operator")
+}
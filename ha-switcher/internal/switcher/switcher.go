@@ -7,6 +7,7 @@ import (
 
 	"ha-switcher/internal/config"
 	"ha-switcher/internal/db"
+	"ha-switcher/internal/events"
 )
 
 // Switcher 负责处理主从切换的实际逻辑
@@ -16,6 +17,7 @@ type Switcher struct {
 	mu           sync.Mutex     // 互斥锁，确保切换操作不会并发执行
 	switchCount  int            // 记录切换次数
 	lastSwitchAt time.Time      // 记录最后一次切换时间
+	flap         flapGuard      // 自动切换的防抖动保护
 }
 
 // NewSwitcher 创建一个新的切换器实例
@@ -23,14 +25,38 @@ func NewSwitcher(dbManager *db.DBManager, cfg *config.Config) *Switcher {
 	return &Switcher{
 		dbManager: dbManager,
 		config:    cfg,
+		flap:      flapGuard{cfg: cfg.Flapping},
 	}
 }
 
-// SwitchToSlave 执行从主库到从库的切换操作
+// SwitchToSlave 执行从主库到从库的自动切换操作
+// 受防抖动保护约束：最小间隔或冷却期内返回ErrSwitchSuppressed，超过每小时上限后返回ErrManualMode
 func (s *Switcher) SwitchToSlave() error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
+	if err := s.flap.allow(time.Now()); err != nil {
+		log.Printf("Automatic failover refused: %v", err)
+		events.Record("switcher", "automatic failover refused: %v", err)
+		return err
+	}
+	s.switchLocked()
+	return nil
+}
+
+// ForceSwitchToSlave 由运维人员手动触发切换，不受防抖动保护限制（手动模式下也可执行）
+func (s *Switcher) ForceSwitchToSlave() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	log.Println("Manual failover requested by operator")
+	events.Record("switcher", "manual failover requested by operator")
+	s.switchLocked()
+	return nil
+}
+
+// switchLocked 执行切换，调用方需持有s.mu
+func (s *Switcher) switchLocked() {
 	log.Println("Starting failover process from master to slave database")
 
 	// 将状态切换到从库
@@ -39,9 +65,39 @@ func (s *Switcher) SwitchToSlave() error {
 	// 更新切换统计信息
 	s.switchCount++
 	s.lastSwitchAt = time.Now()
+	s.flap.record(s.lastSwitchAt)
 
 	log.Printf("Failover completed. Active database is now the slave. Switch count: %d", s.switchCount)
-	return nil
+	events.Record("switcher", "failover completed, slave is now active (switch #%d)", s.switchCount)
+
+	// 异步通知订阅者拓扑已变化
+	go s.publishTopology()
+}
+
+// AllowAutomaticSwitch 检查防抖动保护当前是否允许自动切换（自动回切同样受其约束），不允许时返回原因并计入被拒绝次数
+func (s *Switcher) AllowAutomaticSwitch() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.flap.allow(time.Now())
+}
+
+// FailbackToMaster 将活跃节点切回原主库，与故障切换一样计入切换次数和防抖动保护的切换历史；
+// standbyReady 表示从库是否已重新复制原主库、可以再次作为故障切换候选
+func (s *Switcher) FailbackToMaster(standbyReady bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	log.Println("Starting failback from slave to the original master")
+	s.dbManager.SwitchToMaster(standbyReady)
+
+	s.switchCount++
+	s.lastSwitchAt = time.Now()
+	s.flap.record(s.lastSwitchAt)
+
+	log.Printf("Failback completed. Active database is the original master again. Switch count: %d", s.switchCount)
+	events.Record("switcher", "failback completed, original master is active again (switch #%d)", s.switchCount)
+
+	go s.publishTopology()
 }
 
 // GetSwitchStats 获取切换相关统计信息
@@ -52,6 +108,11 @@ func (s *Switcher) GetSwitchStats() (count int, lastSwitchTime time.Time) {
 	return s.switchCount, s.lastSwitchAt
 }
 
+// Config 获取切换器使用的配置
+func (s *Switcher) Config() *config.Config {
+	return s.config
+}
+
 // PromoteSlave 提升从库为新主库（在实际环境中会执行相应的MySQL命令）
 func (s *Switcher) PromoteSlave() error {
 	// 这是一个模拟方法，在实际环境中，这里会执行类似以下操作：
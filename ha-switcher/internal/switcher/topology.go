@@ -0,0 +1,84 @@
+package switcher
+
+import (
+	"bytes"
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+
+	"ha-switcher/internal/config"
+)
+
+// 当前活跃节点的角色
+const (
+	ActiveMaster = "master" // 原主库
+	ActiveSlave  = "slave"  // 已切换到从库
+)
+
+// Endpoint 数据库节点地址（不包含凭据）
+type Endpoint struct {
+	Host     string `json:"host"`
+	Port     int    `json:"port"`
+	Database string `json:"database"`
+}
+
+// Topology 当前的主从拓扑，供代理等下游组件订阅
+type Topology struct {
+	Active      string    `json:"active"`       // 当前承担写入的节点角色
+	Writer      Endpoint  `json:"writer"`       // 当前写入节点地址
+	Master      Endpoint  `json:"master"`       // 原主库地址
+	Slave       Endpoint  `json:"slave"`        // 从库地址
+	SwitchCount int       `json:"switch_count"` // 切换次数
+	LastSwitch  time.Time `json:"last_switch"`  // 最后一次切换时间
+}
+
+// endpointOf 将数据库配置转换为不含凭据的地址
+func endpointOf(c config.DBConfig) Endpoint {
+	return Endpoint{Host: c.Host, Port: c.Port, Database: c.Database}
+}
+
+// Topology 获取当前拓扑
+func (s *Switcher) Topology() Topology {
+	count, last := s.GetSwitchStats()
+
+	topology := Topology{
+		Active:      ActiveMaster,
+		Writer:      endpointOf(s.config.MasterDB),
+		Master:      endpointOf(s.config.MasterDB),
+		Slave:       endpointOf(s.config.SlaveDB),
+		SwitchCount: count,
+		LastSwitch:  last,
+	}
+	if !s.dbManager.IsMasterActive() {
+		topology.Active = ActiveSlave
+		topology.Writer = topology.Slave
+	}
+	return topology
+}
+
+// publishTopology 将当前拓扑推送给所有配置的回调地址
+func (s *Switcher) publishTopology() {
+	if len(s.config.TopologyWebhooks) == 0 {
+		return
+	}
+
+	data, err := json.Marshal(s.Topology())
+	if err != nil {
+		log.Printf("Failed to marshal topology: %v", err)
+		return
+	}
+
+	client := &http.Client{Timeout: 5 * time.Second}
+	for _, url := range s.config.TopologyWebhooks {
+		go func(url string) {
+			resp, err := client.Post(url, "application/json", bytes.NewReader(data))
+			if err != nil {
+				log.Printf("Failed to publish topology to %s: %v", url, err)
+				return
+			}
+			resp.Body.Close()
+			log.Printf("Topology published to %s: %s", url, resp.Status)
+		}(url)
+	}
+}
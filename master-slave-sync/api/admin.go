@@ -0,0 +1,77 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"master-slave-sync/internal/replication"
+)
+
+// skipCounterRequest 设置跳过计数的请求
+type skipCounterRequest struct {
+	Count int `json:"count"` // 接下来不应用的条目数，0表示取消
+}
+
+// handleInject 手动向binlog注入一个合成的条目（POST，需要管理令牌），不修改主节点的数据
+func (h *MasterHandler) handleInject(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		respondWithError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+	if !requireAdmin(w, r, h.Master.AuthenticateAdmin) {
+		return
+	}
+
+	var req replication.InjectRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	defer r.Body.Close()
+
+	pos, err := h.Master.InjectEntry(req)
+	switch {
+	case errors.Is(err, replication.ErrInvalidInjection):
+		respondWithError(w, http.StatusBadRequest, err.Error())
+	case err != nil:
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+	default:
+		respondWithJSON(w, http.StatusOK, map[string]interface{}{
+			"message":  "Entry injected",
+			"position": pos,
+		})
+	}
+}
+
+// handleSkipCounter 查看（GET）或设置（POST）从节点的跳过计数，都需要管理令牌
+func (h *SlaveHandler) handleSkipCounter(w http.ResponseWriter, r *http.Request) {
+	if !requireAdmin(w, r, h.Slave.AuthenticateAdmin) {
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		respondWithJSON(w, http.StatusOK, map[string]int{"skip_counter": h.Slave.SkipCounter()})
+
+	case http.MethodPost:
+		var req skipCounterRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			respondWithError(w, http.StatusBadRequest, "Invalid request payload")
+			return
+		}
+		defer r.Body.Close()
+
+		previous, err := h.Slave.SetSkipCounter(req.Count)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		respondWithJSON(w, http.StatusOK, map[string]int{
+			"skip_counter": req.Count,
+			"previous":     previous,
+		})
+
+	default:
+		respondWithError(w, http.StatusMethodNotAllowed, "Method not allowed")
+	}
+}
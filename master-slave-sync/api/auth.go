@@ -0,0 +1,57 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"master-slave-sync/internal/replication"
+)
+
+// authSlaveKey 请求上下文中通过凭据校验的从节点ID
+type authSlaveKey struct{}
+
+// requireReplicationAuth 校验复制请求的从节点凭据（X-Slave-ID 与 Authorization: Bearer <token>），
+// 失败时返回401；未配置任何凭据时直接放行
+func requireReplicationAuth(auth *replication.ReplicationAuth, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !auth.Enabled() {
+			next(w, r)
+			return
+		}
+		slaveID, err := auth.AuthenticateRequest(r)
+		if err != nil {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="replication"`)
+			respondWithError(w, http.StatusUnauthorized, err.Error())
+			return
+		}
+		next(w, r.WithContext(context.WithValue(r.Context(), authSlaveKey{}, slaveID)))
+	}
+}
+
+// requireAdmin 用authenticate校验请求携带的管理令牌：未配置令牌（管理操作被禁用）时返回403，
+// 令牌错误时返回401，两种情况都返回false
+func requireAdmin(w http.ResponseWriter, r *http.Request, authenticate func(*http.Request) error) bool {
+	err := authenticate(r)
+	switch {
+	case err == nil:
+		return true
+	case errors.Is(err, replication.ErrAdminDisabled):
+		respondWithError(w, http.StatusForbidden, err.Error())
+	default:
+		w.Header().Set("WWW-Authenticate", `Bearer realm="admin"`)
+		respondWithError(w, http.StatusUnauthorized, err.Error())
+	}
+	return false
+}
+
+// checkSlaveID 请求中声明的从节点ID必须与通过校验的从节点一致，不一致时返回403并返回false
+// 防止持有一个从节点凭据的客户端替其他从节点确认或注册
+func checkSlaveID(w http.ResponseWriter, r *http.Request, claimed string) bool {
+	authed, ok := r.Context().Value(authSlaveKey{}).(string)
+	if !ok || claimed == "" || claimed == authed {
+		return true
+	}
+	respondWithError(w, http.StatusForbidden, replication.ErrSlaveIDMismatch.Error())
+	return false
+}
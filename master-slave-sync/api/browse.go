@@ -0,0 +1,83 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"master-slave-sync/internal/replication"
+)
+
+// handleBinlogBrowse 按时间范围、操作类型、表和记录ID分页浏览binlog条目，每个条目附带可读形式
+// 参数：after（分页游标）、from/to（RFC3339或Unix秒）、operation（逗号分隔）、table、record_id、limit
+func (h *MasterHandler) handleBinlogBrowse(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		respondWithError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	query, ok := parseBrowseQuery(w, r)
+	if !ok {
+		return
+	}
+	respondWithJSON(w, http.StatusOK, h.Master.BrowseBinlog(query))
+}
+
+// parseBrowseQuery 解析浏览binlog的参数，参数无效时返回400并返回false
+func parseBrowseQuery(w http.ResponseWriter, r *http.Request) (replication.BrowseQuery, bool) {
+	values := r.URL.Query()
+	query := replication.BrowseQuery{Table: values.Get("table")}
+
+	if value := values.Get("after"); value != "" {
+		after, err := strconv.ParseUint(value, 10, 64)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "Invalid after parameter")
+			return query, false
+		}
+		query.After = after
+	}
+	if value := values.Get("record_id"); value != "" {
+		id, err := strconv.ParseUint(value, 10, 64)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "Invalid record_id parameter")
+			return query, false
+		}
+		query.RecordID = uint(id)
+	}
+	if value := values.Get("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit < 0 {
+			respondWithError(w, http.StatusBadRequest, "Invalid limit parameter")
+			return query, false
+		}
+		query.Limit = limit
+	}
+	for name, target := range map[string]*time.Time{"from": &query.From, "to": &query.To} {
+		if value := values.Get(name); value != "" {
+			t, err := parseTimeParam(value)
+			if err != nil {
+				respondWithError(w, http.StatusBadRequest, fmt.Sprintf("Invalid %s parameter", name))
+				return query, false
+			}
+			*target = t
+		}
+	}
+	if value := values.Get("operation"); value != "" {
+		for _, op := range strings.Split(value, ",") {
+			if op = strings.TrimSpace(op); op != "" {
+				query.Operations = append(query.Operations, op)
+			}
+		}
+	}
+	return query, true
+}
+
+// parseTimeParam 解析RFC3339格式的时间或Unix时间戳（秒）
+func parseTimeParam(value string) (time.Time, error) {
+	if seconds, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return time.Unix(seconds, 0), nil
+	}
+	return time.Parse(time.RFC3339, value)
+}
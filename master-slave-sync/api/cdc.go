@@ -0,0 +1,71 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"master-slave-sync/internal/replication"
+)
+
+// handleSubscribers 列出变更订阅的Webhook订阅者（GET）或注册新的订阅者（POST）
+func (h *MasterHandler) handleSubscribers(w http.ResponseWriter, r *http.Request) {
+	// 订阅者的地址和推送内容包含全部变更，查看和注册都需要管理令牌
+	if !requireAdmin(w, r, h.Master.AuthenticateAdmin) {
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		respondWithJSON(w, http.StatusOK, h.Master.Subscribers())
+
+	case http.MethodPost:
+		var req replication.SubscriberRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			respondWithError(w, http.StatusBadRequest, "Invalid request payload")
+			return
+		}
+		defer r.Body.Close()
+
+		status, err := h.Master.AddSubscriber(req)
+		switch {
+		case errors.Is(err, replication.ErrSubscriberExists):
+			respondWithError(w, http.StatusConflict, err.Error())
+		case err != nil:
+			respondWithError(w, http.StatusBadRequest, err.Error())
+		default:
+			respondWithJSON(w, http.StatusCreated, status)
+		}
+
+	default:
+		respondWithError(w, http.StatusMethodNotAllowed, "Method not allowed")
+	}
+}
+
+// handleSubscriberByID 查看（GET）或删除（DELETE）一个Webhook订阅者
+func (h *MasterHandler) handleSubscriberByID(w http.ResponseWriter, r *http.Request) {
+	if !requireAdmin(w, r, h.Master.AuthenticateAdmin) {
+		return
+	}
+	id := r.URL.Path[len("/api/subscribers/"):]
+
+	switch r.Method {
+	case http.MethodGet:
+		status, err := h.Master.Subscriber(id)
+		if err != nil {
+			respondWithError(w, http.StatusNotFound, err.Error())
+			return
+		}
+		respondWithJSON(w, http.StatusOK, status)
+
+	case http.MethodDelete:
+		if err := h.Master.RemoveSubscriber(id); err != nil {
+			respondWithError(w, http.StatusNotFound, err.Error())
+			return
+		}
+		respondWithJSON(w, http.StatusOK, map[string]string{"status": "Subscriber removed"})
+
+	default:
+		respondWithError(w, http.StatusMethodNotAllowed, "Method not allowed")
+	}
+}
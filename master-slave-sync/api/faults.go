@@ -0,0 +1,73 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"master-slave-sync/internal/netfault"
+)
+
+// faultRuleRequest 设置网络故障规则的请求
+type faultRuleRequest struct {
+	Peer        string `json:"peer"`         // 对端标识，主节点上为从节点ID，从节点上为"master"，"*"匹配全部
+	DropPercent int    `json:"drop_percent"` // 丢弃请求的百分比
+	LatencyMs   int    `json:"latency_ms"`   // 额外延迟(毫秒)
+	Partitioned bool   `json:"partitioned"`  // 是否分区
+}
+
+// faultRuleResponse 网络故障规则响应
+type faultRuleResponse struct {
+	Peer        string `json:"peer"`
+	DropPercent int    `json:"drop_percent"`
+	LatencyMs   int64  `json:"latency_ms"`
+	Partitioned bool   `json:"partitioned"`
+}
+
+// faultsHandler 返回网络故障注入的管理接口
+// GET：列出规则，POST：设置规则，DELETE：清除规则（?peer=为空时清除全部）
+func faultsHandler(injector *netfault.Injector) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			rules := injector.Rules()
+			response := make([]faultRuleResponse, 0, len(rules))
+			for _, rule := range rules {
+				response = append(response, faultRuleResponse{
+					Peer:        rule.Peer,
+					DropPercent: rule.DropPercent,
+					LatencyMs:   rule.Latency.Milliseconds(),
+					Partitioned: rule.Partitioned,
+				})
+			}
+			respondWithJSON(w, http.StatusOK, response)
+
+		case http.MethodPost:
+			var req faultRuleRequest
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				respondWithError(w, http.StatusBadRequest, "Invalid request payload")
+				return
+			}
+			defer r.Body.Close()
+
+			rule := netfault.Rule{
+				Peer:        req.Peer,
+				DropPercent: req.DropPercent,
+				Latency:     time.Duration(req.LatencyMs) * time.Millisecond,
+				Partitioned: req.Partitioned,
+			}
+			if err := injector.SetRule(rule); err != nil {
+				respondWithError(w, http.StatusBadRequest, err.Error())
+				return
+			}
+			respondWithJSON(w, http.StatusOK, map[string]string{"message": "Fault rule applied"})
+
+		case http.MethodDelete:
+			injector.ClearRule(r.URL.Query().Get("peer"))
+			respondWithJSON(w, http.StatusOK, map[string]string{"message": "Fault rule cleared"})
+
+		default:
+			respondWithError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		}
+	}
+}
@@ -2,18 +2,28 @@ package api
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
 	"strconv"
+	"time"
 
+	"gorm.io/gorm"
+
+	"master-slave-sync/internal/config"
 	"master-slave-sync/internal/replication"
 	"master-slave-sync/internal/storage"
+	"master-slave-sync/internal/wsconn"
 )
 
+// ReplicationStatusHeader 写请求响应中的半同步确认结果（OK、TIMEOUT、SKIPPED等）
+const ReplicationStatusHeader = "X-Replication-Status"
+
 // MasterHandler 主节点API处理器
 type MasterHandler struct {
-	Master *replication.Master
+	Master      *replication.Master
+	idempotency *idempotencyStore // 写请求的幂等键缓存
 }
 
 // SlaveHandler 从节点API处理器
@@ -23,7 +33,8 @@ type SlaveHandler struct {
 
 // 请求和响应的结构体定义
 type createRecordRequest struct {
-	Content string `json:"content"`
+	Content    string `json:"content"`
+	TTLSeconds int    `json:"ttl_seconds"` // 有效期(秒)，0表示永不过期
 }
 
 type updateRecordRequest struct {
@@ -35,6 +46,13 @@ type recordResponse struct {
 	Content   string `json:"content"`
 	CreatedAt string `json:"created_at"`
 	UpdatedAt string `json:"updated_at"`
+	ExpiresAt string `json:"expires_at,omitempty"`
+}
+
+// ddlRequest 在主节点上执行并复制的表结构变更
+type ddlRequest struct {
+	Table string `json:"table"` // 变更的表（可选），必须与语句中的表一致；复制过滤按语句中的表匹配
+	SQL   string `json:"sql"`   // 单条DDL语句（CREATE/ALTER/DROP/RENAME/TRUNCATE）
 }
 
 type slaveAckRequest struct {
@@ -43,18 +61,57 @@ type slaveAckRequest struct {
 }
 
 type registerSlaveRequest struct {
-	SlaveID string `json:"slave_id"`
-	Host    string `json:"host"`
-	Port    int    `json:"port"`
+	SlaveID  string  `json:"slave_id"`
+	Host     string  `json:"host"`
+	Port     int     `json:"port"`
+	ServerID uint32  `json:"server_id"` // 从节点的服务器ID，0表示未配置
+	Position *uint64 `json:"position"`  // 从节点已应用到的位置，租约过期后重新注册时检查；旧版本从节点不发送
+	// 由主节点执行的过滤规则（可选）
+	Filter *config.ReplicationFilter `json:"filter,omitempty"`
 }
 
 type errorResponse struct {
 	Error string `json:"error"`
 }
 
+// notReplicatedResponse 持久化级别为 strict 的写入已在主节点提交但未得到从节点确认时的响应（504）
+type notReplicatedResponse struct {
+	Error    string          `json:"error"`
+	Position uint64          `json:"position"`         // 写入的binlog位置
+	Record   *recordResponse `json:"record,omitempty"` // 已创建的记录（仅创建请求）
+}
+
+// readOnlyResponse 从节点拒绝写请求时的响应（405）
+type readOnlyResponse struct {
+	Error     string `json:"error"`
+	Code      string `json:"code"`       // 固定为 "read_only"，便于客户端区分其他405
+	MasterURL string `json:"master_url"` // 当前主节点的地址，写请求应发往这里
+}
+
+// writeRedirectResponse 从节点以重定向模式拒绝写请求时的响应
+type writeRedirectResponse struct {
+	Error     string `json:"error"`
+	MasterURL string `json:"master_url"`
+	Location  string `json:"location"` // 主节点上对应的请求地址
+}
+
+// newRecordResponse 将记录转换为响应结构
+func newRecordResponse(record *storage.Record) recordResponse {
+	resp := recordResponse{
+		ID:        record.ID,
+		Content:   record.Content,
+		CreatedAt: record.CreatedAt.Format("2006-01-02 15:04:05"),
+		UpdatedAt: record.UpdatedAt.Format("2006-01-02 15:04:05"),
+	}
+	if record.ExpiresAt != nil {
+		resp.ExpiresAt = record.ExpiresAt.Format("2006-01-02 15:04:05")
+	}
+	return resp
+}
+
 // NewMasterHandler 创建主节点API处理器
 func NewMasterHandler(master *replication.Master) *MasterHandler {
-	return &MasterHandler{Master: master}
+	return &MasterHandler{Master: master, idempotency: newIdempotencyStore()}
 }
 
 // NewSlaveHandler 创建从节点API处理器
@@ -66,17 +123,49 @@ func NewSlaveHandler(slave *replication.Slave) *SlaveHandler {
 func (h *MasterHandler) SetupMasterRoutes() *http.ServeMux {
 	mux := http.NewServeMux()
 
-	// 记录处理路由
-	mux.HandleFunc("/api/records", h.handleRecords)
-	mux.HandleFunc("/api/records/", h.handleRecordByID)
-
-	// 复制相关路由
-	mux.HandleFunc("/api/binlog", h.handleBinlog)
-	mux.HandleFunc("/api/ack", h.handleAck)
-	mux.HandleFunc("/api/register_slave", h.handleRegisterSlave)
+	// 记录处理路由（写请求支持 Idempotency-Key 请求头和 ?durability= 参数）
+	mux.HandleFunc("/api/records", h.idempotent(h.handleRecords))
+	mux.HandleFunc("/api/records/", h.idempotent(h.handleRecordByID))
+
+	// 表结构变更（DDL）路由，支持 ?durability= 参数，需要管理令牌
+	mux.HandleFunc("/api/ddl", h.handleDDL)
+
+	// 复制相关路由（配置了从节点凭据时，获取、浏览binlog，确认、心跳和注册需要携带令牌）
+	auth := h.Master.ReplicationAuth()
+	mux.HandleFunc("/api/binlog", requireReplicationAuth(auth, h.handleBinlog))
+	mux.HandleFunc("/api/binlog/status", h.handleBinlogStatus)
+	mux.HandleFunc("/api/replication_status", h.handleReplicationStatus)
+	mux.HandleFunc("/api/binlog/encoding", h.handleBinlogEncoding)
+	mux.HandleFunc("/api/binlog/browse", requireReplicationAuth(auth, h.handleBinlogBrowse))
+	mux.HandleFunc("/api/binlog/stream", requireReplicationAuth(auth, h.handleBinlogStream))
+	mux.HandleFunc("/api/binlog/tail", requireReplicationAuth(auth, h.handleBinlogTail))
+	mux.HandleFunc("/api/ack", requireReplicationAuth(auth, h.handleAck))
+	mux.HandleFunc("/api/heartbeat", requireReplicationAuth(auth, h.handleHeartbeat))
+	mux.HandleFunc("/api/register_slave", requireReplicationAuth(auth, h.handleRegisterSlave))
+	mux.HandleFunc("/api/corruption", h.handleCorruption)
+	mux.HandleFunc("/api/snapshot", requireReplicationAuth(auth, h.handleSnapshot))
+
+	// 变更订阅（CDC）：Webhook订阅者按binlog顺序接收变更事件（管理订阅者需要管理令牌）
+	mux.HandleFunc("/api/subscribers", h.handleSubscribers)
+	mux.HandleFunc("/api/subscribers/", h.handleSubscriberByID)
+	mux.HandleFunc("/api/publisher", h.handlePublisher)
 
 	// 状态信息路由
 	mux.HandleFunc("/api/status", h.handleStatus)
+	mux.HandleFunc("/api/semi_sync", h.handleSemiSync)
+	mux.HandleFunc("/api/flow_control", h.handleFlowControl)
+
+	// 数据校验和（从节点一致性校验使用）
+	mux.HandleFunc("/api/checksum", h.handleChecksum)
+
+	// 分块一致性检查（主节点驱动，从节点提供分块校验和）
+	mux.HandleFunc("/api/consistency", h.handleConsistency)
+
+	// 网络故障注入管理路由
+	mux.HandleFunc("/api/admin/faults", faultsHandler(h.Master.GetFaultInjector()))
+
+	// 手动注入binlog条目（练习复制错误的恢复，需要管理令牌）
+	mux.HandleFunc("/api/admin/inject", h.handleInject)
 
 	return mux
 }
@@ -91,10 +180,44 @@ func (h *SlaveHandler) SetupSlaveRoutes() *http.ServeMux {
 
 	// 状态信息路由
 	mux.HandleFunc("/api/status", h.handleStatus)
+	mux.HandleFunc("/api/replication_status", h.handleReplicationStatus)
 
 	// 同步控制路由
 	mux.HandleFunc("/api/sync/start", h.handleStartSync)
 	mux.HandleFunc("/api/sync/stop", h.handleStopSync)
+	mux.HandleFunc("/api/sync/resync", h.handleResync)
+
+	// 时间点恢复：同步停止时回放binlog到指定位置或时间
+	mux.HandleFunc("/api/pitr", h.handlePointInTimeReplay)
+
+	// 一致性校验路由
+	mux.HandleFunc("/api/verify", h.handleVerify)
+	mux.HandleFunc("/api/chunks", h.handleChunks)
+	mux.HandleFunc("/api/chunks/ids", h.handleChunkIDs)
+
+	// 被拒绝的写请求审计
+	mux.HandleFunc("/api/rejected_writes", h.handleRejectedWrites)
+
+	// 复制热点统计
+	mux.HandleFunc("/api/stats/hot", h.handleHotStats)
+
+	// 级联复制：开启中继时下游从节点可以从本节点同步（凭据由 DownstreamTokens 配置）
+	auth := h.Slave.DownstreamAuth()
+	mux.HandleFunc("/api/binlog", requireReplicationAuth(auth, h.handleRelayBinlog))
+	mux.HandleFunc("/api/ack", requireReplicationAuth(auth, h.handleDownstreamAck))
+	mux.HandleFunc("/api/heartbeat", requireReplicationAuth(auth, h.handleDownstreamHeartbeat))
+	mux.HandleFunc("/api/register_slave", requireReplicationAuth(auth, h.handleDownstreamRegister))
+	mux.HandleFunc("/api/downstream", h.handleDownstream)
+
+	// 主节点故障切换：查询本节点的选举状态或手动发起选举
+	mux.HandleFunc("/api/election", h.handleElection)
+	mux.HandleFunc("/api/promote", h.handlePromote)
+
+	// 网络故障注入管理路由（作用于发往主节点的请求）
+	mux.HandleFunc("/api/admin/faults", faultsHandler(h.Slave.GetFaultInjector()))
+
+	// 跳过接下来的N个条目（sql_slave_skip_counter，需要管理令牌）
+	mux.HandleFunc("/api/admin/skip", h.handleSkipCounter)
 
 	return mux
 }
@@ -114,12 +237,7 @@ func (h *MasterHandler) handleRecords(w http.ResponseWriter, r *http.Request) {
 
 		var response []recordResponse
 		for _, record := range records {
-			response = append(response, recordResponse{
-				ID:        record.ID,
-				Content:   record.Content,
-				CreatedAt: record.CreatedAt.Format("2006-01-02 15:04:05"),
-				UpdatedAt: record.UpdatedAt.Format("2006-01-02 15:04:05"),
-			})
+			response = append(response, newRecordResponse(&record))
 		}
 		respondWithJSON(w, http.StatusOK, response)
 
@@ -132,18 +250,25 @@ func (h *MasterHandler) handleRecords(w http.ResponseWriter, r *http.Request) {
 		}
 		defer r.Body.Close()
 
-		record, err := h.Master.CreateRecord(req.Content)
+		opts, ok := writeOptions(w, r)
+		if !ok {
+			return
+		}
+		opts.TTL = time.Duration(req.TTLSeconds) * time.Second
+
+		record, status, err := h.Master.CreateRecordWithOptions(req.Content, opts)
+		w.Header().Set(ReplicationStatusHeader, string(status))
+		if record != nil && err != nil {
+			resp := newRecordResponse(record)
+			respondNotReplicated(w, err, &resp)
+			return
+		}
 		if err != nil {
 			respondWithError(w, http.StatusInternalServerError, err.Error())
 			return
 		}
 
-		resp := recordResponse{
-			ID:        record.ID,
-			Content:   record.Content,
-			CreatedAt: record.CreatedAt.Format("2006-01-02 15:04:05"),
-			UpdatedAt: record.UpdatedAt.Format("2006-01-02 15:04:05"),
-		}
+		resp := newRecordResponse(record)
 		respondWithJSON(w, http.StatusCreated, resp)
 
 	default:
@@ -170,12 +295,7 @@ func (h *MasterHandler) handleRecordByID(w http.ResponseWriter, r *http.Request)
 			return
 		}
 
-		resp := recordResponse{
-			ID:        record.ID,
-			Content:   record.Content,
-			CreatedAt: record.CreatedAt.Format("2006-01-02 15:04:05"),
-			UpdatedAt: record.UpdatedAt.Format("2006-01-02 15:04:05"),
-		}
+		resp := newRecordResponse(record)
 		respondWithJSON(w, http.StatusOK, resp)
 
 	case http.MethodPut:
@@ -187,8 +307,14 @@ func (h *MasterHandler) handleRecordByID(w http.ResponseWriter, r *http.Request)
 		}
 		defer r.Body.Close()
 
-		if err := h.Master.UpdateRecord(uint(id), req.Content); err != nil {
-			respondWithError(w, http.StatusInternalServerError, err.Error())
+		opts, ok := writeOptions(w, r)
+		if !ok {
+			return
+		}
+		status, err := h.Master.UpdateRecordWithOptions(uint(id), req.Content, opts)
+		w.Header().Set(ReplicationStatusHeader, string(status))
+		if err != nil {
+			respondWriteError(w, err)
 			return
 		}
 
@@ -196,8 +322,14 @@ func (h *MasterHandler) handleRecordByID(w http.ResponseWriter, r *http.Request)
 
 	case http.MethodDelete:
 		// 删除记录
-		if err := h.Master.DeleteRecord(uint(id)); err != nil {
-			respondWithError(w, http.StatusInternalServerError, err.Error())
+		opts, ok := writeOptions(w, r)
+		if !ok {
+			return
+		}
+		status, err := h.Master.DeleteRecordWithOptions(uint(id), opts)
+		w.Header().Set(ReplicationStatusHeader, string(status))
+		if err != nil {
+			respondWriteError(w, err)
 			return
 		}
 
@@ -208,20 +340,244 @@ func (h *MasterHandler) handleRecordByID(w http.ResponseWriter, r *http.Request)
 	}
 }
 
+// handleDDL 在主节点上执行DDL并写入binlog，从节点应用到该位置时执行同一语句
+func (h *MasterHandler) handleDDL(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		respondWithError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+	// DDL在主节点和所有从节点上执行，需要管理令牌
+	if !requireAdmin(w, r, h.Master.AuthenticateAdmin) {
+		return
+	}
+
+	var req ddlRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	defer r.Body.Close()
+
+	opts, ok := writeOptions(w, r)
+	if !ok {
+		return
+	}
+	pos, status, err := h.Master.ExecuteDDL(req.Table, req.SQL, opts)
+	w.Header().Set(ReplicationStatusHeader, string(status))
+	switch {
+	case errors.Is(err, replication.ErrNotDDL):
+		respondWithError(w, http.StatusBadRequest, err.Error())
+	case err != nil:
+		respondWriteError(w, err)
+	default:
+		respondWithJSON(w, http.StatusOK, map[string]interface{}{
+			"message":  "DDL executed successfully",
+			"position": pos,
+		})
+	}
+}
+
+// writeOptions 解析写请求的 ?durability= 参数，参数无效时返回400
+func writeOptions(w http.ResponseWriter, r *http.Request) (replication.WriteOptions, bool) {
+	durability, err := replication.ParseDurability(r.URL.Query().Get("durability"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return replication.WriteOptions{}, false
+	}
+	return replication.WriteOptions{Durability: durability}, true
+}
+
+// respondWriteError 返回写操作的错误：记录不存在返回404，已提交但未得到从节点确认返回504，其余返回500
+func respondWriteError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		respondWithError(w, http.StatusNotFound, "Record not found")
+	case errors.Is(err, replication.ErrNotReplicated):
+		respondNotReplicated(w, err, nil)
+	default:
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+	}
+}
+
+// respondNotReplicated 返回504：写入已在主节点提交，但在超时前没有得到从节点确认
+func respondNotReplicated(w http.ResponseWriter, err error, record *recordResponse) {
+	resp := notReplicatedResponse{Error: err.Error(), Record: record}
+	var notReplicated *replication.NotReplicatedError
+	if errors.As(err, &notReplicated) {
+		resp.Position = notReplicated.Position
+	}
+	respondWithJSON(w, http.StatusGatewayTimeout, resp)
+}
+
 // handleBinlog 提供binlog条目给从节点
+// 携带 wait=N（秒）时为长轮询：没有新条目时最多等待N秒（上限60秒），期间有条目追加立即返回
 func (h *MasterHandler) handleBinlog(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		respondWithError(w, http.StatusMethodNotAllowed, "Method not allowed")
 		return
 	}
 
-	// 获取请求参数
-	query := r.URL.Query()
+	query, ok := parseBinlogQuery(w, r)
+	if !ok || !checkSlaveID(w, r, query.slaveID) {
+		return
+	}
+	if err := h.Master.CheckDownstream(query.serverID); err != nil {
+		respondWithError(w, http.StatusConflict, err.Error())
+		return
+	}
+
+	// 记录从节点ID（可选），长轮询请求很频繁，不逐个记录
+	if query.slaveID != "" && query.wait == 0 {
+		log.Printf("Binlog requested by slave %s from position %d", query.slaveID, query.position)
+	}
+
+	// 获取binlog条目，所需的条目已被清理时返回410
+	var entries []replication.BinlogEntry
+	var err error
+	if query.wait > 0 {
+		entries, err = h.Master.WaitBinlogEntries(r.Context(), query.position, query.wait)
+	} else {
+		entries, err = h.Master.GetBinlogEntries(query.position)
+	}
+	entries = pageEntries(w, query, entries)
+	// 按从节点注册的过滤规则筛选，检查到的最后一个条目ID放在响应头中，从节点据此跳过被过滤的条目
+	if err == nil && len(entries) > 0 {
+		var scanned uint64
+		entries, scanned = h.Master.FilterBinlogEntries(query.slaveID, entries)
+		w.Header().Set(replication.ScannedHeader, strconv.FormatUint(scanned, 10))
+	}
+	w.Header().Set(replication.ChainHeader, replication.FormatChain(h.Master.ReplicationChain()))
+	respondWithEntries(w, query.encoding, entries, err)
+}
+
+// binlogQuery 获取binlog条目的请求参数
+type binlogQuery struct {
+	position uint64        // 返回该位置之后的条目
+	wait     time.Duration // 长轮询等待时间，0表示立即返回
+	limit    int           // 最多返回的条目数，0表示不限制
+	maxBytes int           // 返回的条目的最大总大小（字节），0表示不限制
+	slaveID  string        // 请求的从节点ID（可选）
+	serverID uint32        // 请求的从节点的服务器ID，0表示未提供
+
+	encoding replication.EntryEncoding // 响应中条目的编码，未指定时为JSON
+}
+
+// parseBinlogQuery 解析获取binlog条目的请求参数，参数无效时返回400并返回false
+func parseBinlogQuery(w http.ResponseWriter, r *http.Request) (binlogQuery, bool) {
+	values := r.URL.Query()
+	query := binlogQuery{slaveID: values.Get("slave_id")}
 
 	// 解析位置参数
-	posStr := query.Get("position")
+	if posStr := values.Get("position"); posStr != "" {
+		position, err := strconv.ParseUint(posStr, 10, 64)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "Invalid position parameter")
+			return query, false
+		}
+		query.position = position
+	}
+
+	// 解析长轮询等待时间
+	if waitStr := values.Get("wait"); waitStr != "" {
+		seconds, err := strconv.Atoi(waitStr)
+		if err != nil || seconds < 0 {
+			respondWithError(w, http.StatusBadRequest, "Invalid wait parameter")
+			return query, false
+		}
+		query.wait = time.Duration(seconds) * time.Second
+	}
+
+	// 解析分页参数
+	for name, target := range map[string]*int{"limit": &query.limit, "max_bytes": &query.maxBytes} {
+		if value := values.Get(name); value != "" {
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 0 {
+				respondWithError(w, http.StatusBadRequest, fmt.Sprintf("Invalid %s parameter", name))
+				return query, false
+			}
+			*target = n
+		}
+	}
+
+	encoding, ok := parseEncoding(w, values.Get("encoding"))
+	if !ok {
+		return query, false
+	}
+	query.encoding = encoding
+
+	serverID, ok := parseServerID(w, values.Get("server_id"))
+	query.serverID = serverID
+	return query, ok
+}
+
+// parseEncoding 解析条目编码参数，为空时返回JSON（便于直接查看），无效时返回400并返回false
+func parseEncoding(w http.ResponseWriter, value string) (replication.EntryEncoding, bool) {
+	if value == "" {
+		return replication.EncodingJSON, true
+	}
+	encoding, err := replication.ParseEntryEncoding(value)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid encoding parameter")
+		return "", false
+	}
+	return encoding, true
+}
+
+// parseServerID 解析服务器ID参数，为空时返回0，无效时返回400并返回false
+func parseServerID(w http.ResponseWriter, value string) (uint32, bool) {
+	if value == "" {
+		return 0, true
+	}
+	id, err := strconv.ParseUint(value, 10, 32)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid server_id parameter")
+		return 0, false
+	}
+	return uint32(id), true
+}
+
+// pageEntries 按请求的 limit 和 max_bytes 截取条目，被截断时在响应头中返回下一次请求的位置
+func pageEntries(w http.ResponseWriter, query binlogQuery, entries []replication.BinlogEntry) []replication.BinlogEntry {
+	page, truncated := replication.PageEntries(entries, query.limit, query.maxBytes, query.encoding)
+	if truncated {
+		w.Header().Set(replication.NextPositionHeader, strconv.FormatUint(page[len(page)-1].ID, 10))
+	}
+	return page
+}
+
+// respondWithEntries 按请求的编码返回binlog条目，所需的条目已被清理时返回410和最早可用的位置
+func respondWithEntries(w http.ResponseWriter, encoding replication.EntryEncoding, entries []replication.BinlogEntry, err error) {
+	if err != nil {
+		var purged *replication.PositionPurgedError
+		if errors.As(err, &purged) {
+			respondWithJSON(w, http.StatusGone, map[string]interface{}{
+				"error":           err.Error(),
+				"oldest_position": purged.Oldest,
+			})
+			return
+		}
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if encoding != replication.EncodingBinary {
+		respondWithJSON(w, http.StatusOK, entries)
+		return
+	}
+
+	data, err := replication.EncodeEntries(encoding, entries)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	w.Header().Set("Content-Type", replication.BinaryContentType)
+	w.WriteHeader(http.StatusOK)
+	w.Write(data)
+}
+
+// handleBinlogStream 将连接升级为WebSocket，向从节点推送position之后的binlog条目，之后每追加一条立即推送
+func (h *MasterHandler) handleBinlogStream(w http.ResponseWriter, r *http.Request) {
 	position := uint64(0)
-	if posStr != "" {
+	if posStr := r.URL.Query().Get("position"); posStr != "" {
 		var err error
 		position, err = strconv.ParseUint(posStr, 10, 64)
 		if err != nil {
@@ -229,16 +585,79 @@ func (h *MasterHandler) handleBinlog(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 	}
+	slaveID := r.URL.Query().Get("slave_id")
+	if !checkSlaveID(w, r, slaveID) {
+		return
+	}
+	serverID, ok := parseServerID(w, r.URL.Query().Get("server_id"))
+	if !ok {
+		return
+	}
+	encoding, ok := parseEncoding(w, r.URL.Query().Get("encoding"))
+	if !ok {
+		return
+	}
+	if err := h.Master.CheckDownstream(serverID); err != nil {
+		respondWithError(w, http.StatusConflict, err.Error())
+		return
+	}
+
+	conn, err := wsconn.Upgrade(w, r)
+	if err != nil {
+		log.Printf("Binlog stream upgrade failed for slave %s: %v", slaveID, err)
+		return
+	}
+	defer conn.Close()
+
+	err = h.Master.ServeBinlogStream(conn, slaveID, position, encoding)
+	if err != nil {
+		log.Printf("Binlog stream to slave %s ended: %v", slaveID, err)
+		return
+	}
+	log.Printf("Binlog stream to slave %s closed", slaveID)
+}
+
+// handleBinlogStatus 返回binlog最早可用的位置、当前位置和各分段文件
+func (h *MasterHandler) handleBinlogStatus(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		respondWithError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+	respondWithJSON(w, http.StatusOK, h.Master.BinlogStatus())
+}
+
+// handleReplicationStatus 以 SHOW MASTER STATUS 的形式返回binlog状态
+func (h *MasterHandler) handleReplicationStatus(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		respondWithError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+	respondWithJSON(w, http.StatusOK, h.Master.ReplicationStatus())
+}
 
-	// 记录从节点ID（可选）
-	slaveID := query.Get("slave_id")
-	if slaveID != "" {
-		log.Printf("Binlog requested by slave %s from position %d", slaveID, position)
+// handleBinlogEncoding 用最近的binlog条目对比JSON和二进制编码的大小和吞吐量（?sample=N，默认1000个条目）
+func (h *MasterHandler) handleBinlogEncoding(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		respondWithError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
 	}
 
-	// 获取binlog条目
-	entries := h.Master.GetBinlogEntries(position)
-	respondWithJSON(w, http.StatusOK, entries)
+	sample := 0
+	if value := r.URL.Query().Get("sample"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n <= 0 {
+			respondWithError(w, http.StatusBadRequest, "Invalid sample parameter")
+			return
+		}
+		sample = n
+	}
+
+	report, err := h.Master.EncodingReport(sample)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	respondWithJSON(w, http.StatusOK, report)
 }
 
 // handleAck 处理从节点的确认请求
@@ -254,14 +673,121 @@ func (h *MasterHandler) handleAck(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	defer r.Body.Close()
+	if !checkSlaveID(w, r, req.SlaveID) {
+		return
+	}
 
-	// 记录确认信息
-	h.Master.RecordSlaveACK(req.SlaveID, req.Position)
+	// 记录确认信息；没有注册租约的从节点的确认不计入半同步，要求它重新注册
+	if !h.Master.RecordSlaveACK(req.SlaveID, req.Position) {
+		respondWithError(w, http.StatusConflict, "slave is not registered or its lease expired, register again")
+		return
+	}
 	log.Printf("Received ACK from slave %s for position %d", req.SlaveID, req.Position)
 
 	respondWithJSON(w, http.StatusOK, map[string]string{"status": "ACK received"})
 }
 
+// handleHeartbeat 处理从节点心跳
+func (h *MasterHandler) handleHeartbeat(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		respondWithError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	var req replication.HeartbeatRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.SlaveID == "" {
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	defer r.Body.Close()
+	if !checkSlaveID(w, r, req.SlaveID) {
+		return
+	}
+
+	check, lease := h.Master.RecordHeartbeat(req)
+	respondWithJSON(w, http.StatusOK, replication.HeartbeatResponse{
+		Status:         "ok",
+		Slaves:         h.Master.Peers(),
+		PositionCheck:  check,
+		LeaseExpiresAt: lease,
+	})
+}
+
+// handleSemiSync 获取半同步状态及最近的状态切换
+func (h *MasterHandler) handleSemiSync(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		respondWithError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+	respondWithJSON(w, http.StatusOK, h.Master.SemiSyncReport())
+}
+
+// handleFlowControl 获取流量控制状态和各从节点的积压
+func (h *MasterHandler) handleFlowControl(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		respondWithError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+	respondWithJSON(w, http.StatusOK, h.Master.FlowControlReport())
+}
+
+// handleSnapshot 返回所有已注册表在当前binlog位置上的一致性快照，供从节点全量重新同步
+func (h *MasterHandler) handleSnapshot(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		respondWithError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+	if !checkSlaveID(w, r, r.URL.Query().Get("slave_id")) {
+		return
+	}
+
+	snapshot, err := h.Master.Snapshot()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	respondWithJSON(w, http.StatusOK, snapshot)
+}
+
+// handlePublisher 查看binlog发布任务（Kafka）的进度
+func (h *MasterHandler) handlePublisher(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		respondWithError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+	respondWithJSON(w, http.StatusOK, h.Master.PublisherStatus())
+}
+
+// handleBinlogTail 查询跟随MySQL binlog的状态（binlog_source: mysql）
+func (h *MasterHandler) handleBinlogTail(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		respondWithError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+	respondWithJSON(w, http.StatusOK, h.Master.BinlogTailStatus())
+}
+
+// handleCorruption 接收从节点上报的校验失败条目（POST），或列出最近的上报（GET）
+func (h *MasterHandler) handleCorruption(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		respondWithJSON(w, http.StatusOK, h.Master.CorruptionReports())
+
+	case http.MethodPost:
+		var report replication.CorruptionReport
+		if err := json.NewDecoder(r.Body).Decode(&report); err != nil {
+			respondWithError(w, http.StatusBadRequest, "Invalid request payload")
+			return
+		}
+		defer r.Body.Close()
+
+		respondWithJSON(w, http.StatusOK, h.Master.ReportCorruption(report))
+
+	default:
+		respondWithError(w, http.StatusMethodNotAllowed, "Method not allowed")
+	}
+}
+
 // handleRegisterSlave 处理从节点注册请求
 func (h *MasterHandler) handleRegisterSlave(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
@@ -275,14 +801,37 @@ func (h *MasterHandler) handleRegisterSlave(w http.ResponseWriter, r *http.Reque
 		return
 	}
 	defer r.Body.Close()
+	if !checkSlaveID(w, r, req.SlaveID) {
+		return
+	}
+
+	// 从节点的服务器ID已在复制链中时拒绝注册
+	if err := h.Master.CheckDownstream(req.ServerID); err != nil {
+		respondWithError(w, http.StatusConflict, err.Error())
+		return
+	}
 
-	// 注册从节点
-	h.Master.RegisterSlave(req.SlaveID, req.Host, req.Port)
+	// 注册从节点并授予租约
+	var position uint64
+	if req.Position != nil {
+		position = *req.Position
+	}
+	check := h.Master.RegisterSlave(req.SlaveID, req.Host, req.Port, position, req.Position != nil)
+	// 注册时声明的过滤规则替换之前的规则，不带规则的注册取消过滤
+	if err := h.Master.SetSlaveFilter(req.SlaveID, req.Filter); err != nil {
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	w.Header().Set(replication.ChainHeader, replication.FormatChain(h.Master.ReplicationChain()))
 
-	respondWithJSON(w, http.StatusOK, map[string]string{
+	response := map[string]string{
 		"status":   "Slave registered successfully",
 		"slave_id": req.SlaveID,
-	})
+	}
+	if check != "" {
+		response["position_check"] = string(check)
+	}
+	respondWithJSON(w, http.StatusOK, response)
 }
 
 // handleStatus 返回主节点状态信息
@@ -296,12 +845,27 @@ func (h *MasterHandler) handleStatus(w http.ResponseWriter, r *http.Request) {
 	respondWithJSON(w, http.StatusOK, stats)
 }
 
+// handleChecksum 返回当前数据的校验和及binlog位置
+func (h *MasterHandler) handleChecksum(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		respondWithError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	result, err := h.Master.Checksum()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	respondWithJSON(w, http.StatusOK, result)
+}
+
 // --- 从节点处理器 ---
 
 // handleRecords 处理只读记录请求
 func (h *SlaveHandler) handleRecords(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
-		respondWithError(w, http.StatusMethodNotAllowed, "Only read operations allowed on slave")
+		h.rejectWrite(w, r)
 		return
 	}
 
@@ -315,12 +879,7 @@ func (h *SlaveHandler) handleRecords(w http.ResponseWriter, r *http.Request) {
 
 	var response []recordResponse
 	for _, record := range records {
-		response = append(response, recordResponse{
-			ID:        record.ID,
-			Content:   record.Content,
-			CreatedAt: record.CreatedAt.Format("2006-01-02 15:04:05"),
-			UpdatedAt: record.UpdatedAt.Format("2006-01-02 15:04:05"),
-		})
+		response = append(response, newRecordResponse(&record))
 	}
 	respondWithJSON(w, http.StatusOK, response)
 }
@@ -328,7 +887,7 @@ func (h *SlaveHandler) handleRecords(w http.ResponseWriter, r *http.Request) {
 // handleRecordByID 处理只读单条记录请求
 func (h *SlaveHandler) handleRecordByID(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
-		respondWithError(w, http.StatusMethodNotAllowed, "Only read operations allowed on slave")
+		h.rejectWrite(w, r)
 		return
 	}
 
@@ -348,15 +907,74 @@ func (h *SlaveHandler) handleRecordByID(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	resp := recordResponse{
-		ID:        record.ID,
-		Content:   record.Content,
-		CreatedAt: record.CreatedAt.Format("2006-01-02 15:04:05"),
-		UpdatedAt: record.UpdatedAt.Format("2006-01-02 15:04:05"),
-	}
+	resp := newRecordResponse(record)
 	respondWithJSON(w, http.StatusOK, resp)
 }
 
+// rejectWrite 拒绝从节点上的非读请求：写请求记入审计，
+// 重定向模式下返回307和主节点上的相同地址，客户端可以按原方法和请求体重试
+func (h *SlaveHandler) rejectWrite(w http.ResponseWriter, r *http.Request) {
+	if !isWriteMethod(r.Method) {
+		respondWithError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+	h.Slave.RecordRejectedWrite(r.Method, r.URL.Path, r.RemoteAddr)
+
+	if h.Slave.WriteRejectMode() != replication.WriteRejectModeRedirect {
+		respondWithJSON(w, http.StatusMethodNotAllowed, readOnlyResponse{
+			Error:     "Only read operations allowed on slave",
+			Code:      "read_only",
+			MasterURL: h.Slave.MasterURL(),
+		})
+		return
+	}
+	location := h.Slave.MasterURL() + r.URL.RequestURI()
+	w.Header().Set("Location", location)
+	respondWithJSON(w, http.StatusTemporaryRedirect, writeRedirectResponse{
+		Error:     "Only read operations allowed on slave, retry on master",
+		MasterURL: h.Slave.MasterURL(),
+		Location:  location,
+	})
+}
+
+// isWriteMethod 判断是否为写请求方法
+func isWriteMethod(method string) bool {
+	switch method {
+	case http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+// handleRejectedWrites 返回最近被拒绝的写请求
+func (h *SlaveHandler) handleRejectedWrites(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		respondWithError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, h.Slave.RejectedWrites())
+}
+
+// handleHotStats 返回最近窗口内应用最频繁的表和记录，?limit=N 限制返回条数（默认10）
+func (h *SlaveHandler) handleHotStats(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		respondWithError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	limit := 0
+	if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
+		n, err := strconv.Atoi(limitStr)
+		if err != nil || n <= 0 {
+			respondWithError(w, http.StatusBadRequest, "Invalid limit parameter")
+			return
+		}
+		limit = n
+	}
+	respondWithJSON(w, http.StatusOK, h.Slave.HotStats(limit))
+}
+
 // handleStatus 返回从节点状态信息
 func (h *SlaveHandler) handleStatus(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
@@ -379,6 +997,52 @@ func (h *SlaveHandler) handleStartSync(w http.ResponseWriter, r *http.Request) {
 	respondWithJSON(w, http.StatusOK, map[string]string{"status": "Sync started"})
 }
 
+// handlePointInTimeReplay 回放binlog到请求中的位置或时间（{"position": N} 或 {"time": "RFC3339"}）
+func (h *SlaveHandler) handlePointInTimeReplay(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		respondWithError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	var target replication.RecoveryTarget
+	if err := json.NewDecoder(r.Body).Decode(&target); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	defer r.Body.Close()
+
+	result, err := h.Slave.ReplayUntil(target)
+	switch {
+	case errors.Is(err, replication.ErrNoTarget):
+		respondWithError(w, http.StatusBadRequest, err.Error())
+	case errors.Is(err, replication.ErrSyncRunning), errors.Is(err, replication.ErrReplayInFlight),
+		errors.Is(err, replication.ErrResyncInFlight), errors.Is(err, replication.ErrPastTarget):
+		respondWithError(w, http.StatusConflict, err.Error())
+	case err != nil:
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+	default:
+		respondWithJSON(w, http.StatusOK, result)
+	}
+}
+
+// handleResync 全量重新同步：从主节点加载快照替换本地数据，再从快照的位置恢复增量同步
+func (h *SlaveHandler) handleResync(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		respondWithError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	result, err := h.Slave.Resync()
+	switch {
+	case errors.Is(err, replication.ErrResyncInFlight), errors.Is(err, replication.ErrReplayInFlight):
+		respondWithError(w, http.StatusConflict, err.Error())
+	case err != nil:
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+	default:
+		respondWithJSON(w, http.StatusOK, result)
+	}
+}
+
 // handleStopSync 停止同步进程
 func (h *SlaveHandler) handleStopSync(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
@@ -390,6 +1054,18 @@ func (h *SlaveHandler) handleStopSync(w http.ResponseWriter, r *http.Request) {
 	respondWithJSON(w, http.StatusOK, map[string]string{"status": "Sync stopped"})
 }
 
+// handleVerify 查看一致性校验历史（GET）或立即执行一次校验（POST）
+func (h *SlaveHandler) handleVerify(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		respondWithJSON(w, http.StatusOK, h.Slave.VerifyHistory())
+	case http.MethodPost:
+		respondWithJSON(w, http.StatusOK, h.Slave.VerifyNow())
+	default:
+		respondWithError(w, http.StatusMethodNotAllowed, "Method not allowed")
+	}
+}
+
 // --- 工具函数 ---
 
 // respondWithError 返回错误响应
@@ -419,3 +1095,128 @@ func (h *MasterHandler) GetDB() *storage.DB {
 func (h *SlaveHandler) GetDB() *storage.DB {
 	return h.Slave.GetDB()
 }
+
+// handleConsistency 查看最近的分块一致性检查报告（GET）或立即检查一次（POST，?repair=true 修复不一致的区间）
+func (h *MasterHandler) handleConsistency(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		respondWithJSON(w, http.StatusOK, h.Master.ConsistencyReports())
+	case http.MethodPost:
+		repair := false
+		if value := r.URL.Query().Get("repair"); value != "" {
+			var err error
+			if repair, err = strconv.ParseBool(value); err != nil {
+				respondWithError(w, http.StatusBadRequest, "Invalid repair parameter")
+				return
+			}
+		}
+		respondWithJSON(w, http.StatusOK, h.Master.CheckConsistency(repair))
+	default:
+		respondWithError(w, http.StatusMethodNotAllowed, "Method not allowed")
+	}
+}
+
+// handleChunks 返回从节点应用到 position 后的分块校验和（主节点的一致性检查调用）
+func (h *SlaveHandler) handleChunks(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		respondWithError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	values := r.URL.Query()
+	size, err := strconv.ParseUint(values.Get("chunk_size"), 10, 32)
+	if err != nil || size == 0 {
+		respondWithError(w, http.StatusBadRequest, "Invalid chunk_size parameter")
+		return
+	}
+	position, err := strconv.ParseUint(values.Get("position"), 10, 64)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid position parameter")
+		return
+	}
+
+	report, err := h.Slave.ChunkChecksums(uint(size), position)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	respondWithJSON(w, http.StatusOK, report)
+}
+
+// handleChunkIDs 返回从库中ID在 [first_id, last_id] 区间内的记录ID（修复不一致区间时调用）
+func (h *SlaveHandler) handleChunkIDs(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		respondWithError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	values := r.URL.Query()
+	first, err1 := strconv.ParseUint(values.Get("first_id"), 10, 32)
+	last, err2 := strconv.ParseUint(values.Get("last_id"), 10, 32)
+	if err1 != nil || err2 != nil || first > last {
+		respondWithError(w, http.StatusBadRequest, "Invalid first_id or last_id parameter")
+		return
+	}
+
+	ids, err := h.Slave.RecordIDsInRange(uint(first), uint(last))
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	respondWithJSON(w, http.StatusOK, ids)
+}
+
+// handleReplicationStatus 以 SHOW SLAVE STATUS 的形式返回复制状态
+func (h *SlaveHandler) handleReplicationStatus(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		respondWithError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+	respondWithJSON(w, http.StatusOK, h.Slave.ReplicationStatus())
+}
+
+// handlePromote 手动提升为主节点（需要管理令牌），成功后返回本节点的选举状态
+func (h *SlaveHandler) handlePromote(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		respondWithError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+	if !requireAdmin(w, r, h.Slave.AuthenticateAdmin) {
+		return
+	}
+
+	err := h.Slave.Promote()
+	switch {
+	case errors.Is(err, replication.ErrElectionAborted),
+		errors.Is(err, replication.ErrResyncInFlight),
+		errors.Is(err, replication.ErrReplayInFlight):
+		respondWithError(w, http.StatusConflict, err.Error())
+	case err != nil:
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+	default:
+		respondWithJSON(w, http.StatusOK, h.Slave.ElectionVote())
+	}
+}
+
+// handleElection GET 返回本节点在选举中的状态（其他从节点选举时调用）；POST 手动发起一次选举（需要管理令牌）
+func (h *SlaveHandler) handleElection(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		respondWithJSON(w, http.StatusOK, h.Slave.ElectionVote())
+	case http.MethodPost:
+		if !requireAdmin(w, r, h.Slave.AuthenticateAdmin) {
+			return
+		}
+		result, err := h.Slave.RunElection()
+		switch {
+		case errors.Is(err, replication.ErrElectionAborted):
+			respondWithJSON(w, http.StatusConflict, result)
+		case err != nil:
+			respondWithJSON(w, http.StatusInternalServerError, result)
+		default:
+			respondWithJSON(w, http.StatusOK, result)
+		}
+	default:
+		respondWithError(w, http.StatusMethodNotAllowed, "Method not allowed")
+	}
+}
@@ -0,0 +1,160 @@
+package api
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// IdempotencyKeyHeader 写请求的幂等键请求头，同一个键的重复请求返回第一次的响应，不会重复写入
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+// 幂等键缓存参数
+const (
+	idempotencyTTL        = 24 * time.Hour // 幂等键的保留时间
+	idempotencyMaxEntries = 10000          // 最多保留的幂等键数量，超过时淘汰最早的
+)
+
+// idempotentResponse 幂等键对应的请求及其响应
+type idempotentResponse struct {
+	fingerprint [32]byte // 请求方法、地址和请求体的摘要
+	done        bool     // 第一次请求是否已完成
+	status      int      // 响应状态码
+	header      http.Header
+	body        []byte
+	createdAt   time.Time
+}
+
+// idempotencyStore 主节点上按幂等键缓存写请求的响应（仅保存在内存中，主节点重启后失效）
+type idempotencyStore struct {
+	entries map[string]*idempotentResponse
+	order   []string // 按写入顺序的键，用于过期和淘汰
+	mu      sync.Mutex
+}
+
+// newIdempotencyStore 创建幂等键缓存
+func newIdempotencyStore() *idempotencyStore {
+	return &idempotencyStore{entries: make(map[string]*idempotentResponse)}
+}
+
+// begin 登记一个幂等键：第一次出现时返回nil（调用方执行请求后调用finish），
+// 已完成时返回缓存的响应；同一个键的第一次请求还在执行，或请求内容与第一次不同时返回冲突原因
+func (s *idempotencyStore) begin(key string, fingerprint [32]byte) (*idempotentResponse, string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.expire(time.Now())
+
+	entry, ok := s.entries[key]
+	if !ok {
+		s.entries[key] = &idempotentResponse{fingerprint: fingerprint, createdAt: time.Now()}
+		s.order = append(s.order, key)
+		return nil, ""
+	}
+	if entry.fingerprint != fingerprint {
+		return nil, "Idempotency key reused with a different request"
+	}
+	if !entry.done {
+		return nil, "A request with this idempotency key is still in progress"
+	}
+	return entry, ""
+}
+
+// finish 保存幂等键第一次请求的响应；5xx响应不缓存（写入可能没有生效），客户端可以用同一个键重试
+// 写入已提交但未得到从节点确认（504）时缓存，重试不会重复写入
+func (s *idempotencyStore) finish(key string, rec *responseRecorder) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry, ok := s.entries[key]
+	if !ok {
+		return
+	}
+	if rec.status == 0 || rec.status >= http.StatusInternalServerError && rec.status != http.StatusGatewayTimeout {
+		delete(s.entries, key)
+		return
+	}
+	entry.done = true
+	entry.status = rec.status
+	entry.header = rec.Header().Clone()
+	entry.body = rec.body.Bytes()
+}
+
+// expire 删除过期的键并在超过数量上限时淘汰最早的键（调用方持有锁）
+func (s *idempotencyStore) expire(now time.Time) {
+	n := 0
+	for n < len(s.order) {
+		entry, ok := s.entries[s.order[n]]
+		if ok && now.Sub(entry.createdAt) < idempotencyTTL && len(s.order)-n <= idempotencyMaxEntries {
+			break
+		}
+		// 还在执行的请求被淘汰后，完成时不再缓存其响应
+		delete(s.entries, s.order[n])
+		n++
+	}
+	s.order = s.order[n:]
+}
+
+// responseRecorder 记录响应内容，同时写给客户端
+type responseRecorder struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+// WriteHeader 记录状态码
+func (r *responseRecorder) WriteHeader(code int) {
+	r.status = code
+	r.ResponseWriter.WriteHeader(code)
+}
+
+// Write 记录响应体
+func (r *responseRecorder) Write(b []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	r.body.Write(b)
+	return r.ResponseWriter.Write(b)
+}
+
+// idempotent 为带有 Idempotency-Key 请求头的写请求提供幂等保证，其他请求直接交给next处理
+func (h *MasterHandler) idempotent(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get(IdempotencyKeyHeader)
+		if key == "" || !isWriteMethod(r.Method) {
+			next(w, r)
+			return
+		}
+
+		body, err := io.ReadAll(r.Body)
+		r.Body.Close()
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "Failed to read request body")
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		fingerprint := sha256.Sum256(append([]byte(r.Method+" "+r.URL.RequestURI()+"\n"), body...))
+
+		cached, conflict := h.idempotency.begin(key, fingerprint)
+		if conflict != "" {
+			respondWithError(w, http.StatusConflict, conflict)
+			return
+		}
+		if cached != nil {
+			for name, values := range cached.header {
+				w.Header()[name] = values
+			}
+			w.Header().Set("Idempotent-Replayed", "true")
+			w.WriteHeader(cached.status)
+			w.Write(cached.body)
+			return
+		}
+
+		rec := &responseRecorder{ResponseWriter: w}
+		defer h.idempotency.finish(key, rec)
+		next(rec, r)
+	}
+}
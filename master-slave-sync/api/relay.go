@@ -0,0 +1,158 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"master-slave-sync/internal/replication"
+)
+
+// respondRelayError 中继相关请求失败：未开启中继时返回404，会形成复制环时返回409
+func respondRelayError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, replication.ErrRelayDisabled):
+		respondWithError(w, http.StatusNotFound, err.Error())
+	case errors.Is(err, replication.ErrReplicationLoop):
+		respondWithError(w, http.StatusConflict, err.Error())
+	default:
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+	}
+}
+
+// handleRelayBinlog 向下游从节点返回中继日志中的条目，参数与主节点的 /api/binlog 相同
+func (h *SlaveHandler) handleRelayBinlog(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		respondWithError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	query, ok := parseBinlogQuery(w, r)
+	if !ok || !checkSlaveID(w, r, query.slaveID) {
+		return
+	}
+	if err := h.Slave.CheckDownstream(query.serverID); err != nil {
+		respondRelayError(w, err)
+		return
+	}
+
+	var entries []replication.BinlogEntry
+	var err error
+	if query.wait > 0 {
+		entries, err = h.Slave.WaitRelayEntries(r.Context(), query.position, query.wait)
+	} else {
+		entries, err = h.Slave.RelayEntries(query.position)
+	}
+	entries = pageEntries(w, query, entries)
+	// 与主节点相同，按下游从节点注册的过滤规则筛选，检查到的最后一个条目ID放在响应头中
+	if err == nil && len(entries) > 0 {
+		var scanned uint64
+		entries, scanned = h.Slave.FilterRelayEntries(query.slaveID, entries)
+		w.Header().Set(replication.ScannedHeader, strconv.FormatUint(scanned, 10))
+	}
+	w.Header().Set(replication.RelayHeader, "true")
+	w.Header().Set(replication.ChainHeader, replication.FormatChain(h.Slave.ReplicationChain()))
+	respondWithEntries(w, query.encoding, entries, err)
+}
+
+// handleDownstreamAck 记录下游从节点的确认，中继不向上游转发
+func (h *SlaveHandler) handleDownstreamAck(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		respondWithError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	var req slaveAckRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.SlaveID == "" {
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	defer r.Body.Close()
+	if !checkSlaveID(w, r, req.SlaveID) {
+		return
+	}
+
+	if err := h.Slave.RecordDownstream(req.SlaveID, "", 0, req.Position); err != nil {
+		respondRelayError(w, err)
+		return
+	}
+	respondWithJSON(w, http.StatusOK, map[string]string{"status": "ACK received"})
+}
+
+// handleDownstreamHeartbeat 记录下游从节点的心跳
+func (h *SlaveHandler) handleDownstreamHeartbeat(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		respondWithError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	var req replication.HeartbeatRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.SlaveID == "" {
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	defer r.Body.Close()
+	if !checkSlaveID(w, r, req.SlaveID) {
+		return
+	}
+
+	if err := h.Slave.RecordDownstream(req.SlaveID, req.Host, req.Port, req.Position); err != nil {
+		respondRelayError(w, err)
+		return
+	}
+	// 心跳携带过滤规则时更新（中继重启后由心跳恢复规则）
+	if req.Filter != nil {
+		if err := h.Slave.SetDownstreamFilter(req.SlaveID, req.Filter); err != nil {
+			respondRelayError(w, err)
+			return
+		}
+	}
+	respondWithJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+// handleDownstreamRegister 注册下游从节点，服务器ID已在复制链中时返回409
+func (h *SlaveHandler) handleDownstreamRegister(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		respondWithError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	var req registerSlaveRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.SlaveID == "" {
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	defer r.Body.Close()
+	if !checkSlaveID(w, r, req.SlaveID) {
+		return
+	}
+
+	if err := h.Slave.CheckDownstream(req.ServerID); err != nil {
+		respondRelayError(w, err)
+		return
+	}
+	if err := h.Slave.RecordDownstream(req.SlaveID, req.Host, req.Port, 0); err != nil {
+		respondRelayError(w, err)
+		return
+	}
+	if err := h.Slave.SetDownstreamFilter(req.SlaveID, req.Filter); err != nil {
+		respondRelayError(w, err)
+		return
+	}
+
+	w.Header().Set(replication.ChainHeader, replication.FormatChain(h.Slave.ReplicationChain()))
+	respondWithJSON(w, http.StatusOK, map[string]string{
+		"status":   "Slave registered successfully",
+		"slave_id": req.SlaveID,
+	})
+}
+
+// handleDownstream 列出从本节点同步的下游从节点
+func (h *SlaveHandler) handleDownstream(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		respondWithError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+	respondWithJSON(w, http.StatusOK, h.Slave.DownstreamSlaves())
+}
@@ -0,0 +1,318 @@
+// Package client 主节点写入API的Go客户端，供其他模块（HA切换演练、校验工具、压测）调用，
+// 不需要各自拼装HTTP请求
+package client
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// 与主节点API约定的请求头
+const (
+	idempotencyKeyHeader    = "Idempotency-Key"
+	replicationStatusHeader = "X-Replication-Status"
+)
+
+// 客户端默认参数
+const (
+	defaultTimeout        = 10 * time.Second
+	defaultRetries        = 3
+	defaultInitialBackoff = 200 * time.Millisecond
+	maxBackoff            = 2 * time.Second
+)
+
+// Durability 写操作的持久化级别（与主节点的 ?durability= 参数对应）
+type Durability string
+
+// 支持的持久化级别
+const (
+	DurabilityLocal    Durability = "local"     // 主节点提交即返回
+	DurabilitySemiSync Durability = "semi_sync" // 等待从节点确认，超时后降级为异步（主节点默认）
+	DurabilityStrict   Durability = "strict"    // 等待从节点确认，超时返回 ErrNotReplicated
+)
+
+// Record 主节点上的一条记录
+type Record struct {
+	ID        uint   `json:"id"`
+	Content   string `json:"content"`
+	CreatedAt string `json:"created_at"`
+	UpdatedAt string `json:"updated_at"`
+	ExpiresAt string `json:"expires_at,omitempty"`
+}
+
+// SlaveInfo 主节点记录的从节点信息
+type SlaveInfo struct {
+	ID               string
+	Host             string
+	Port             int
+	LastSeen         time.Time
+	CurrentPosition  uint64
+	Status           string // active 或 stale
+	MissedHeartbeats int
+	LagSeconds       float64
+	BehindEntries    uint64
+}
+
+// Status 主节点状态（/api/status）
+type Status struct {
+	BinlogPosition    uint64
+	BinlogFormat      string // row 或 statement
+	SemiSyncWaitPoint string // after_commit 或 after_sync
+	ConnectedSlaves   int
+	StaleSlaves       int
+	MaxLagSeconds     float64
+	SemiSyncStatus    string
+	TotalWrites       int
+	ExpiredRecords    int
+	RecoveredWrites   int
+	CorruptEntries    int
+	UptimeSeconds     int64
+	SlaveInfos        []SlaveInfo
+}
+
+// WriteOptions 写操作选项，零值表示由客户端生成幂等键、使用客户端的默认持久化级别
+type WriteOptions struct {
+	IdempotencyKey string        // 幂等键，为空时每次调用生成一个新的键（同一次调用的重试共用）
+	Durability     Durability    // 持久化级别，为空时使用客户端的默认值
+	TTL            time.Duration // 记录有效期（仅 CreateRecord），0表示永不过期
+}
+
+// WriteResult 写操作结果
+type WriteResult struct {
+	ReplicationStatus string // 半同步确认结果（OK、TIMEOUT、SKIPPED等）
+	Replayed          bool   // 是否为同一幂等键的重复请求，主节点返回了第一次的结果
+}
+
+// Client 主节点API客户端，可以被多个goroutine同时使用
+type Client struct {
+	baseURL    string
+	http       *http.Client
+	retries    int           // 单次调用的最大尝试次数
+	backoff    time.Duration // 首次重试前的等待时间，之后指数增长
+	durability Durability    // 默认持久化级别
+}
+
+// Option 客户端选项
+type Option func(*Client)
+
+// WithHTTPClient 使用自定义的HTTP客户端（如带故障注入的Transport）
+func WithHTTPClient(hc *http.Client) Option {
+	return func(c *Client) { c.http = hc }
+}
+
+// WithRetries 设置单次调用的最大尝试次数和首次重试前的等待时间，attempts为1表示不重试
+func WithRetries(attempts int, backoff time.Duration) Option {
+	return func(c *Client) {
+		if attempts > 0 {
+			c.retries = attempts
+		}
+		if backoff > 0 {
+			c.backoff = backoff
+		}
+	}
+}
+
+// WithDurability 设置写操作的默认持久化级别
+func WithDurability(d Durability) Option {
+	return func(c *Client) { c.durability = d }
+}
+
+// New 创建主节点API客户端，baseURL如 http://localhost:8080
+func New(baseURL string, opts ...Option) *Client {
+	c := &Client{
+		baseURL: strings.TrimRight(baseURL, "/"),
+		http:    &http.Client{Timeout: defaultTimeout},
+		retries: defaultRetries,
+		backoff: defaultInitialBackoff,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// CreateRecord 创建记录
+// 持久化级别为 strict 且没有得到从节点确认时，记录已创建，返回记录和 ErrNotReplicated 错误
+func (c *Client) CreateRecord(ctx context.Context, content string, opts WriteOptions) (*Record, WriteResult, error) {
+	body := map[string]interface{}{"content": content}
+	if opts.TTL > 0 {
+		body["ttl_seconds"] = int(opts.TTL / time.Second)
+	}
+
+	var resp struct {
+		Record
+		NotReplicated *Record `json:"record"` // 504响应中的记录
+	}
+	result, err := c.write(ctx, "CreateRecord", http.MethodPost, "/api/records", body, opts, &resp)
+	if errors.Is(err, ErrNotReplicated) {
+		return resp.NotReplicated, result, err
+	}
+	if err != nil {
+		return nil, result, err
+	}
+	return &resp.Record, result, nil
+}
+
+// UpdateRecord 更新记录内容，记录不存在时返回 ErrNotFound
+func (c *Client) UpdateRecord(ctx context.Context, id uint, content string, opts WriteOptions) (WriteResult, error) {
+	path := fmt.Sprintf("/api/records/%d", id)
+	return c.write(ctx, "UpdateRecord", http.MethodPut, path, map[string]string{"content": content}, opts, nil)
+}
+
+// DeleteRecord 删除记录，记录不存在时返回 ErrNotFound
+func (c *Client) DeleteRecord(ctx context.Context, id uint, opts WriteOptions) (WriteResult, error) {
+	path := fmt.Sprintf("/api/records/%d", id)
+	return c.write(ctx, "DeleteRecord", http.MethodDelete, path, nil, opts, nil)
+}
+
+// GetRecord 从主节点读取记录，记录不存在时返回 ErrNotFound
+func (c *Client) GetRecord(ctx context.Context, id uint) (*Record, error) {
+	var record Record
+	if _, err := c.do(ctx, "GetRecord", http.MethodGet, fmt.Sprintf("/api/records/%d", id), nil, "", &record); err != nil {
+		return nil, err
+	}
+	return &record, nil
+}
+
+// Status 获取主节点状态
+func (c *Client) Status(ctx context.Context) (*Status, error) {
+	var status Status
+	if _, err := c.do(ctx, "Status", http.MethodGet, "/api/status", nil, "", &status); err != nil {
+		return nil, err
+	}
+	return &status, nil
+}
+
+// write 发送写请求：带上幂等键和持久化级别，失败时用同一个幂等键重试
+func (c *Client) write(ctx context.Context, op string, method string, path string, body interface{}, opts WriteOptions, out interface{}) (WriteResult, error) {
+	key := opts.IdempotencyKey
+	if key == "" {
+		key = newIdempotencyKey()
+	}
+	durability := opts.Durability
+	if durability == "" {
+		durability = c.durability
+	}
+	if durability != "" {
+		path += "?durability=" + url.QueryEscape(string(durability))
+	}
+
+	var data []byte
+	if body != nil {
+		var err error
+		if data, err = json.Marshal(body); err != nil {
+			return WriteResult{}, fmt.Errorf("%s: failed to encode request: %w", op, err)
+		}
+	}
+
+	header, err := c.do(ctx, op, method, path, data, key, out)
+	result := WriteResult{
+		ReplicationStatus: header.Get(replicationStatusHeader),
+		Replayed:          header.Get("Idempotent-Replayed") == "true",
+	}
+	return result, err
+}
+
+// do 发送请求并解码响应，网络错误和可重试的错误响应按指数退避重试
+// 写请求只有带幂等键时才会调用，因此重试不会重复写入
+func (c *Client) do(ctx context.Context, op string, method string, path string, body []byte, key string, out interface{}) (http.Header, error) {
+	var lastErr error
+	backoff := c.backoff
+	for attempt := 1; attempt <= c.retries; attempt++ {
+		header, err := c.send(ctx, op, method, path, body, key, out)
+		if err == nil {
+			return header, nil
+		}
+		lastErr = err
+
+		var apiErr *APIError
+		if errors.As(err, &apiErr) && !apiErr.retryable() {
+			return header, err
+		}
+		if attempt == c.retries {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("%s: %w (last error: %v)", op, ctx.Err(), lastErr)
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+		if backoff > maxBackoff {
+			backoff = maxBackoff
+		}
+	}
+	return nil, lastErr
+}
+
+// send 发送一次请求，2xx响应解码到out，其他状态码返回 APIError
+// 504响应的响应体也解码到out（strict级别未得到从节点确认时包含已创建的记录）
+func (c *Client) send(ctx context.Context, op string, method string, path string, body []byte, key string, out interface{}) (http.Header, error) {
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, reader)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if key != "" {
+		req.Header.Set(idempotencyKeyHeader, key)
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return resp.Header, fmt.Errorf("%s: failed to read response: %w", op, err)
+	}
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		if out != nil {
+			if err := json.Unmarshal(data, out); err != nil {
+				return resp.Header, fmt.Errorf("%s: failed to decode response: %w", op, err)
+			}
+		}
+		return resp.Header, nil
+	}
+
+	var errResp struct {
+		Error     string `json:"error"`
+		Position  uint64 `json:"position"`
+		MasterURL string `json:"master_url"`
+	}
+	json.Unmarshal(data, &errResp)
+	if errResp.Error == "" {
+		errResp.Error = strings.TrimSpace(string(data))
+	}
+	if resp.StatusCode == http.StatusGatewayTimeout && out != nil {
+		json.Unmarshal(data, out)
+	}
+	return resp.Header, &APIError{Op: op, StatusCode: resp.StatusCode, Message: errResp.Error, Position: errResp.Position, MasterURL: errResp.MasterURL}
+}
+
+// newIdempotencyKey 生成随机幂等键
+func newIdempotencyKey() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return fmt.Sprintf("%d", time.Now().UnixNano())
+	}
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,57 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// 主节点API返回的错误类别，可以用 errors.Is 判断
+var (
+	ErrBadRequest     = errors.New("bad request")                                              // 请求参数无效（400）
+	ErrNotFound       = errors.New("record not found")                                         // 记录不存在（404）
+	ErrNotMaster      = errors.New("node is read-only, send writes to the master")             // 写请求发到了从节点（405/307）
+	ErrConflict       = errors.New("idempotency key conflict")                                 // 幂等键正被另一个请求使用，或被用于不同的请求（409）
+	ErrNotReplicated  = errors.New("write committed on master but not acknowledged by slaves") // strict级别的写入未得到从节点确认（504），写入已生效
+	ErrServer         = errors.New("master internal error")                                    // 主节点内部错误（其他5xx）
+	ErrUnexpectedCode = errors.New("unexpected response status")                               // 其他状态码
+)
+
+// APIError 主节点API返回的错误响应
+type APIError struct {
+	Op         string // 调用的操作，如 CreateRecord
+	StatusCode int    // HTTP状态码
+	Message    string // 响应中的错误信息
+	Position   uint64 // 写入的binlog位置（仅 ErrNotReplicated）
+	MasterURL  string // 从节点返回的当前主节点地址（仅 ErrNotMaster）
+}
+
+// Error 实现error接口
+func (e *APIError) Error() string {
+	return fmt.Sprintf("%s: master returned %d: %s", e.Op, e.StatusCode, e.Message)
+}
+
+// Unwrap 按状态码返回错误类别，支持 errors.Is(err, ErrNotFound) 等判断
+func (e *APIError) Unwrap() error {
+	switch code := e.StatusCode; {
+	case code == http.StatusBadRequest:
+		return ErrBadRequest
+	case code == http.StatusNotFound:
+		return ErrNotFound
+	case code == http.StatusMethodNotAllowed, code == http.StatusTemporaryRedirect:
+		return ErrNotMaster
+	case code == http.StatusConflict:
+		return ErrConflict
+	case code == http.StatusGatewayTimeout:
+		return ErrNotReplicated
+	case code >= http.StatusInternalServerError:
+		return ErrServer
+	}
+	return ErrUnexpectedCode
+}
+
+// retryable 是否可以用同一个幂等键重试：主节点内部错误（此时写入没有被缓存为已完成）
+// 504表示写入已生效，重试只会得到同样的结果，不重试
+func (e *APIError) retryable() bool {
+	return e.StatusCode >= http.StatusInternalServerError && e.StatusCode != http.StatusGatewayTimeout
+}
@@ -3,8 +3,10 @@ package main
 import (
 	"context"
 	"errors"
+	"flag"
 	"fmt"
 	"log"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
@@ -13,13 +15,21 @@ import (
 
 	"master-slave-sync/api"
 	"master-slave-sync/internal/config"
+	"master-slave-sync/internal/kafka"
 	"master-slave-sync/internal/replication"
 )
 
 func main() {
+	// 解析命令行参数：配置文件、-set 覆盖的配置项及常用配置项的快捷参数
+	configFlags := config.BindFlags(flag.CommandLine)
+	flag.Parse()
+
 	log.Printf("Starting master node")
 
-	cfg := config.GetDefaultConfig()
+	cfg, err := configFlags.Load("")
+	if err != nil {
+		log.Fatalf("Failed to load config: %v", err)
+	}
 
 	// 创建主节点
 	master, err := replication.NewMaster(cfg)
@@ -32,15 +42,67 @@ func main() {
 		}
 	}()
 
+	// 启动过期记录清理（过期删除通过binlog复制到从节点）
+	master.StartExpiryReaper(time.Duration(cfg.Master.ExpiryIntervalMs) * time.Millisecond)
+
+	// 启动binlog清理（删除所有从节点都已确认的分段）
+	master.StartBinlogRetention(time.Duration(cfg.Master.BinlogRetentionIntervalMs) * time.Millisecond)
+
+	// 启动从节点心跳检查（失联的从节点不计入已连接数，半同步不再等待它们）
+	master.StartHeartbeatMonitor()
+
+	// 启动半同步恢复检查（降级后从节点追上时恢复半同步，并记录每次状态切换）
+	master.StartSemiSyncProber()
+
+	// 启动分块一致性检查（比较主节点与各从节点每个ID区间的校验和）
+	master.StartConsistencyChecker(time.Duration(cfg.Master.ConsistencyCheckIntervalMs) * time.Millisecond)
+
+	// 把binlog发布到Kafka（可选），进度保存在主库中，重启后继续
+	if cfg.Master.KafkaTopic != "" {
+		producer := kafka.NewProducer(kafka.Config{
+			Brokers:   cfg.Master.KafkaBrokers,
+			Topic:     cfg.Master.KafkaTopic,
+			Partition: int32(cfg.Master.KafkaPartition),
+			ClientID:  fmt.Sprintf("master-slave-sync-%d", cfg.Master.ServerID),
+		})
+		defer producer.Close()
+		if err := master.StartPublisher("kafka:"+cfg.Master.KafkaTopic, producer); err != nil {
+			log.Fatalf("Failed to start binlog publisher: %v", err)
+		}
+	}
+
 	// 创建API处理器
 	handler := api.NewMasterHandler(master)
 	mux := handler.SetupMasterRoutes()
 
-	// 创建HTTP服务器
+	// 创建HTTP服务器，配置了证书时通过https提供
+	useTLS, err := replication.ServerTLS(cfg.Master.TLSCertFile, cfg.Master.TLSKeyFile)
+	if err != nil {
+		log.Fatalf("Invalid TLS configuration: %v", err)
+	}
+	scheme := "http"
+	if useTLS {
+		scheme = "https"
+	}
 	port := cfg.Master.APIPort
 	server := &http.Server{
 		Addr:    fmt.Sprintf(":%d", port),
-		Handler: mux,
+		Handler: master.GetFaultInjector().Middleware(mux), // 按从节点注入网络故障
+	}
+
+	// 启动gRPC复制服务（与HTTP接口同时提供）
+	grpcServer := replication.NewGRPCServer(master)
+	if cfg.Master.GRPCPort > 0 {
+		lis, err := net.Listen("tcp", fmt.Sprintf(":%d", cfg.Master.GRPCPort))
+		if err != nil {
+			log.Fatalf("Failed to listen on grpc port %d: %v", cfg.Master.GRPCPort, err)
+		}
+		go func() {
+			if err := grpcServer.Serve(lis); err != nil {
+				log.Printf("gRPC server error: %v", err)
+			}
+		}()
+		log.Printf("gRPC replication service listening on port %d", cfg.Master.GRPCPort)
 	}
 
 	// 优雅关闭的通道
@@ -60,16 +122,26 @@ func main() {
 		if err := server.Shutdown(ctx); err != nil {
 			log.Printf("HTTP server shutdown error: %v", err)
 		}
+		// Dump流在binlog关闭前不会结束，直接断开而不等待
+		grpcServer.Stop()
 		close(idleConnsClosed)
 	}()
 
 	// 启动HTTP服务器
 	log.Printf("Master API server listening on port %d", port)
-	log.Printf("Binlog replication endpoint: http://localhost:%d/api/binlog", port)
+	log.Printf("Binlog replication endpoint: %s://localhost:%d/api/binlog", scheme, port)
+	if master.ReplicationAuth().Enabled() {
+		log.Printf("Replication authentication enabled for %d slaves", len(cfg.Master.SlaveTokens))
+	}
 	log.Printf("Semi-sync timeout: %dms, waiting for %d slaves",
 		cfg.SemiSync.TimeoutMs, cfg.SemiSync.MinSlaves)
 
-	if err := server.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+	if useTLS {
+		err = server.ListenAndServeTLS(cfg.Master.TLSCertFile, cfg.Master.TLSKeyFile)
+	} else {
+		err = server.ListenAndServe()
+	}
+	if !errors.Is(err, http.ErrServerClosed) {
 		log.Fatalf("HTTP server error: %v", err)
 	}
 
@@ -9,6 +9,7 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
+	"sync/atomic"
 	"syscall"
 	"time"
 
@@ -20,13 +21,33 @@ import (
 func main() {
 	// 解析命令行参数
 	var slaveID string
+	var untilPosition uint64
+	var untilTime string
 
 	flag.StringVar(&slaveID, "id", "slave1", "Unique slave identifier")
+	// 时间点恢复模式：启动后只回放到指定位置或时间，不开始持续同步
+	flag.Uint64Var(&untilPosition, "until-position", 0, "Replay the binlog up to this position instead of syncing continuously")
+	flag.StringVar(&untilTime, "until-time", "", "Replay the binlog up to this time (RFC3339) instead of syncing continuously")
+	// 配置文件、-set 覆盖的配置项及常用配置项的快捷参数
+	configFlags := config.BindFlags(flag.CommandLine)
 	flag.Parse()
 
+	target := replication.RecoveryTarget{Position: untilPosition}
+	if untilTime != "" {
+		t, err := time.Parse(time.RFC3339, untilTime)
+		if err != nil {
+			log.Fatalf("Invalid -until-time: %v", err)
+		}
+		target.Time = t
+	}
+
 	log.Printf("Starting slave node with ID: %s", slaveID)
 
-	cfg := config.GetDefaultConfig()
+	// 配置文件 slaves 列表中与本节点ID相同的一项覆盖 slave 部分
+	cfg, err := configFlags.Load(slaveID)
+	if err != nil {
+		log.Fatalf("Failed to load config: %v", err)
+	}
 
 	// 创建从节点
 	slave, err := replication.NewSlave(cfg, slaveID)
@@ -39,15 +60,47 @@ func main() {
 		}
 	}()
 
+	// 定期与主节点校验数据一致性，不一致时通过告警钩子通知
+	if cfg.Slave.AlertWebhook != "" {
+		slave.AddAlertHook(replication.WebhookAlertHook{URL: cfg.Slave.AlertWebhook})
+	}
+	if cfg.Slave.VerifySchedule != "" {
+		if err := slave.StartVerifier(cfg.Slave.VerifySchedule); err != nil {
+			log.Fatalf("Invalid verify schedule: %v", err)
+		}
+	}
+
 	// 创建API处理器
 	handler := api.NewSlaveHandler(slave)
 	mux := handler.SetupSlaveRoutes()
 
-	// 创建HTTP服务器
+	// 当前生效的路由，在选举中当选后切换为主节点的路由
+	var routes atomic.Value
+	routes.Store(http.Handler(mux))
+
+	// 当选后提供主节点API，并启动主节点的心跳检查和半同步恢复检查
+	slave.OnPromote(func(master *replication.Master) {
+		masterMux := http.NewServeMux()
+		masterMux.Handle("/", master.GetFaultInjector().Middleware(api.NewMasterHandler(master).SetupMasterRoutes()))
+		masterMux.Handle("/api/election", mux) // 其他从节点通过它发现新的主节点
+		routes.Store(http.Handler(masterMux))
+
+		master.StartHeartbeatMonitor()
+		master.StartSemiSyncProber()
+		log.Printf("Now serving master API on port %d", cfg.Slave.APIPort)
+	})
+
+	// 创建HTTP服务器，配置了证书时通过https提供
+	useTLS, err := replication.ServerTLS(cfg.Slave.TLSCertFile, cfg.Slave.TLSKeyFile)
+	if err != nil {
+		log.Fatalf("Invalid TLS configuration: %v", err)
+	}
 	port := cfg.Slave.APIPort
 	server := &http.Server{
-		Addr:    fmt.Sprintf(":%d", port),
-		Handler: mux,
+		Addr: fmt.Sprintf(":%d", port),
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			routes.Load().(http.Handler).ServeHTTP(w, r)
+		}),
 	}
 
 	// 优雅关闭的通道
@@ -73,13 +126,29 @@ func main() {
 		close(idleConnsClosed)
 	}()
 
-	// 启动同步
-	slave.StartSync()
-	log.Printf("Slave synchronization started")
+	if target.Position > 0 || !target.Time.IsZero() {
+		// 时间点恢复：回放完成后同步保持停止，可以通过API查看数据或继续回放
+		result, err := slave.ReplayUntil(target)
+		if err != nil {
+			log.Printf("Point-in-time replay failed at position %d: %v", result.StopPosition, err)
+		} else {
+			log.Printf("Point-in-time replay finished at position %d (%s), sync stays stopped",
+				result.StopPosition, result.StopReason)
+		}
+	} else {
+		// 启动同步
+		slave.StartSync()
+		log.Printf("Slave synchronization started")
+	}
 
 	// 启动HTTP服务器
-	log.Printf("Slave API server listening on port %d", port)
-	if err := server.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+	log.Printf("Slave API server listening on port %d (tls: %v)", port, useTLS)
+	if useTLS {
+		err = server.ListenAndServeTLS(cfg.Slave.TLSCertFile, cfg.Slave.TLSKeyFile)
+	} else {
+		err = server.ListenAndServe()
+	}
+	if !errors.Is(err, http.ErrServerClosed) {
 		log.Fatalf("HTTP server error: %v", err)
 	}
 
@@ -0,0 +1,107 @@
+//go:build integration
+
+package integration
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+
+	"master-slave-sync/client"
+	"master-slave-sync/internal/replication"
+)
+
+// 测试使用的管理令牌
+const testAdminToken = "it-admin-token"
+
+// postJSON 向url发送JSON请求体，token不为空时携带管理令牌，返回响应的状态码
+func postJSON(t *testing.T, url, token, body string) int {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodPost, url, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("POST %s: %v", url, err)
+	}
+	resp.Body.Close()
+	return resp.StatusCode
+}
+
+func TestDDLRequiresAdminToken(t *testing.T) {
+	requireDocker(t)
+	cfg := testConfig(t)
+	cfg.Master.AdminToken = testAdminToken
+	master := startMaster(t, cfg)
+	url := master.server.URL + "/api/ddl"
+	create := `{"sql": "CREATE TABLE IF NOT EXISTS ddl_probe (id INT PRIMARY KEY)"}`
+	start := master.GetCurrentBinlogPosition()
+
+	if code := postJSON(t, url, "", create); code != http.StatusUnauthorized {
+		t.Errorf("DDL without token: status %d, want %d", code, http.StatusUnauthorized)
+	}
+	if code := postJSON(t, url, "wrong", create); code != http.StatusUnauthorized {
+		t.Errorf("DDL with wrong token: status %d, want %d", code, http.StatusUnauthorized)
+	}
+	if pos := master.GetCurrentBinlogPosition(); pos != start {
+		t.Fatalf("rejected DDL reached the binlog at position %d", pos)
+	}
+
+	// 表名以语句为准：与请求中的表不一致、带库名或影响整个库的语句被拒绝
+	for _, body := range []string{
+		`{"table": "records", "sql": "DROP TABLE ddl_probe"}`,
+		`{"sql": "DROP DATABASE mysql"}`,
+		`{"sql": "DROP TABLE other_db.records"}`,
+		`{"sql": "DROP TABLE ddl_probe, records"}`,
+	} {
+		if code := postJSON(t, url, testAdminToken, body); code != http.StatusBadRequest {
+			t.Errorf("DDL %s: status %d, want %d", body, code, http.StatusBadRequest)
+		}
+	}
+
+	if code := postJSON(t, url, testAdminToken, create); code != http.StatusOK {
+		t.Fatalf("DDL with admin token: status %d, want %d", code, http.StatusOK)
+	}
+	entries, err := master.GetBinlogEntries(start)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].Operation != replication.OpDDL || entries[0].TableName != "ddl_probe" {
+		t.Errorf("binlog after DDL: %+v, want one DDL entry on ddl_probe", entries)
+	}
+}
+
+func TestManualElectionDoesNotPromoteWhileMasterReachable(t *testing.T) {
+	requireDocker(t)
+	cfg := testConfig(t)
+	cfg.Slave.AdminToken = testAdminToken
+	master := startMaster(t, cfg)
+	slave := startSlave(t, cfg, "it-slave")
+	writeRecords(t, master, "record", 3, client.DurabilityLocal)
+	waitCaughtUp(t, master, slave)
+	url := slave.server.URL + "/api/election"
+
+	if code := postJSON(t, url, "", ""); code != http.StatusUnauthorized {
+		t.Errorf("election without token: status %d, want %d", code, http.StatusUnauthorized)
+	}
+
+	// 单个从节点没有其他节点投票，主节点仍在响应时不能自己当选
+	if code := postJSON(t, url, testAdminToken, ""); code != http.StatusConflict {
+		t.Errorf("election while master is reachable: status %d, want %d", code, http.StatusConflict)
+	}
+	if slave.Promoted() != nil {
+		t.Fatal("slave promoted itself while the master is reachable")
+	}
+	if _, err := slave.RunElection(); !errors.Is(err, replication.ErrElectionAborted) {
+		t.Errorf("RunElection while master is reachable: err=%v, want ErrElectionAborted", err)
+	}
+	if slave.Promoted() != nil {
+		t.Fatal("slave promoted itself while the master is reachable")
+	}
+}
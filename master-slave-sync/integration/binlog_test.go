@@ -0,0 +1,88 @@
+//go:build integration
+
+package integration
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"master-slave-sync/internal/replication"
+	"master-slave-sync/internal/storage"
+)
+
+// 组提交的等待时间，Close在组长等待期间调用
+const closeGroupWindow = 300 * time.Millisecond
+
+func TestCloseFailsQueuedGroupCommitAppends(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "master.binlog")
+	binlog, err := replication.OpenBinlog(path, replication.BinlogFileOptions{
+		GroupCommit: true,
+		GroupWindow: closeGroupWindow,
+	})
+	if err != nil {
+		t.Fatalf("open binlog: %v", err)
+	}
+
+	const appenders = 8
+	ids := make([]uint64, appenders)
+	errs := make([]error, appenders)
+	var wg sync.WaitGroup
+	for i := 0; i < appenders; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			ids[i], errs[i] = binlog.AppendInsert(&storage.Record{ID: uint(i + 1), Content: fmt.Sprintf("queued-%d", i)})
+		}(i)
+	}
+	time.Sleep(closeGroupWindow / 3)
+	if err := binlog.Close(); err != nil {
+		t.Fatalf("close binlog: %v", err)
+	}
+	wg.Wait()
+
+	// 返回成功的追加已写入文件，其余的以 ErrBinlogClosed 失败，不能只出现在内存中
+	succeeded := 0
+	for i, err := range errs {
+		switch {
+		case err == nil:
+			succeeded++
+		case !errors.Is(err, replication.ErrBinlogClosed):
+			t.Errorf("append %d: %v, want ErrBinlogClosed", i, err)
+		}
+	}
+	if succeeded == appenders {
+		t.Fatalf("all appends committed before Close, the group window did not hold them")
+	}
+	if pos := binlog.GetCurrentPosition(); pos != uint64(succeeded) {
+		t.Errorf("closed binlog position %d, want %d (only committed appends)", pos, succeeded)
+	}
+	if _, err := binlog.AppendInsert(&storage.Record{ID: 100, Content: "after-close"}); !errors.Is(err, replication.ErrBinlogClosed) {
+		t.Errorf("append after Close: %v, want ErrBinlogClosed", err)
+	}
+
+	reopened, err := replication.OpenBinlog(path, replication.BinlogFileOptions{})
+	if err != nil {
+		t.Fatalf("reopen binlog: %v", err)
+	}
+	defer reopened.Close()
+	entries, err := reopened.EntriesAfter(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != succeeded {
+		t.Fatalf("binlog file has %d entries, want the %d committed appends", len(entries), succeeded)
+	}
+	persisted := make(map[uint64]bool, len(entries))
+	for _, entry := range entries {
+		persisted[entry.ID] = true
+	}
+	for i, err := range errs {
+		if err == nil && !persisted[ids[i]] {
+			t.Errorf("append %d returned position %d but the entry is not in the file", i, ids[i])
+		}
+	}
+}
@@ -0,0 +1,54 @@
+//go:build integration
+
+package integration
+
+import (
+	"net"
+	"testing"
+
+	"master-slave-sync/client"
+	"master-slave-sync/internal/replication"
+)
+
+// 中继过滤掉的表
+const relayExcludedTable = "cascade_skip"
+
+func TestDownstreamFollowsFilteringRelayWithoutGap(t *testing.T) {
+	requireDocker(t)
+	cfg := testConfig(t)
+	master := startMaster(t, cfg)
+
+	// 中继不复制 cascade_skip，这张表的条目不在中继日志中，下游收到的条目ID不连续
+	relayCfg := *cfg
+	relayCfg.Slave.RelayLogSize = 1000
+	relayCfg.Slave.Filter.ExcludeTables = []string{relayExcludedTable}
+	relay := startSlave(t, &relayCfg, "it-relay")
+
+	downCfg := *cfg
+	downCfg.Slave.DBName = slaveMySQL.createDatabase(t)
+	downCfg.Slave.MasterPort = relay.server.Listener.Addr().(*net.TCPAddr).Port
+	downCfg.Slave.ServerID = 3
+	downCfg.Slave.ReplicationMode = replication.ReplicationLongPoll
+	downstream := startSlave(t, &downCfg, "it-downstream")
+
+	writeRecords(t, master, "before", 3, client.DurabilityLocal)
+	if _, _, err := master.ExecuteDDL("", "CREATE TABLE "+relayExcludedTable+" (id INT PRIMARY KEY)",
+		replication.WriteOptions{Durability: replication.DurabilityLocal}); err != nil {
+		t.Fatalf("create %s: %v", relayExcludedTable, err)
+	}
+	writeRecords(t, master, "after", 3, client.DurabilityLocal)
+
+	assertConverged(t, master, relay)
+	assertConverged(t, master, downstream)
+
+	stats := downstream.GetStats()
+	if stats.AutoResyncs != 0 {
+		t.Errorf("downstream resynced %d times (%s), want incremental sync across the relay's filtered entry",
+			stats.AutoResyncs, stats.LastAutoResync)
+	}
+	for name, slave := range map[string]*testSlave{"relay": relay, "downstream": downstream} {
+		if slave.GetDB().GetConnection().Migrator().HasTable(relayExcludedTable) {
+			t.Errorf("%s created table %s excluded by the relay's filter", name, relayExcludedTable)
+		}
+	}
+}
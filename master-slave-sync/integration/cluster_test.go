@@ -0,0 +1,208 @@
+//go:build integration
+
+package integration
+
+import (
+	"context"
+	"net"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"master-slave-sync/api"
+	"master-slave-sync/client"
+	"master-slave-sync/internal/config"
+	"master-slave-sync/internal/replication"
+	"master-slave-sync/internal/storage"
+)
+
+// 测试中等待条件成立的默认超时
+const convergeTimeout = 30 * time.Second
+
+// testConfig 测试用的配置：主节点和从节点分别使用两个容器中新建的数据库，binlog写入测试的临时目录，
+// 心跳、探测和重连间隔缩短，使失联、降级和恢复在几秒内发生
+func testConfig(t *testing.T) *config.SyncConfig {
+	t.Helper()
+	cfg := config.GetDefaultConfig()
+
+	cfg.Master.Host = "127.0.0.1"
+	cfg.Master.Port = masterMySQL.port
+	cfg.Master.Password = mysqlPassword
+	cfg.Master.DBName = masterMySQL.createDatabase(t)
+	cfg.Master.BinlogPath = filepath.Join(t.TempDir(), "master.binlog")
+	cfg.Master.HeartbeatIntervalMs = 200
+	cfg.Master.HeartbeatMissLimit = 3
+	cfg.Master.SlaveLeaseMs = 10000
+
+	cfg.Slave.Host = "127.0.0.1"
+	cfg.Slave.Port = slaveMySQL.port
+	cfg.Slave.Password = mysqlPassword
+	cfg.Slave.DBName = slaveMySQL.createDatabase(t)
+	cfg.Slave.MasterHost = "127.0.0.1"
+	cfg.Slave.HeartbeatIntervalMs = 200
+	cfg.Slave.SyncIntervalMs = 200
+	cfg.Slave.VerifySchedule = ""
+
+	cfg.SemiSync.TimeoutMs = 500
+	cfg.SemiSync.ProbeIntervalMs = 100
+	return cfg
+}
+
+// testMaster 在测试HTTP服务器上运行的主节点
+type testMaster struct {
+	*replication.Master
+	server *httptest.Server
+	client *client.Client
+}
+
+// startMaster 启动主节点及其心跳检查和半同步恢复检查，并把主节点的API端口写入cfg供从节点连接
+func startMaster(t *testing.T, cfg *config.SyncConfig) *testMaster {
+	t.Helper()
+	master, err := replication.NewMaster(cfg)
+	if err != nil {
+		t.Fatalf("start master: %v", err)
+	}
+	t.Cleanup(func() { master.Close() })
+	master.StartHeartbeatMonitor()
+	master.StartSemiSyncProber()
+
+	server := httptest.NewServer(api.NewMasterHandler(master).SetupMasterRoutes())
+	t.Cleanup(server.Close)
+	cfg.Master.APIPort = server.Listener.Addr().(*net.TCPAddr).Port
+	cfg.Slave.MasterPort = cfg.Master.APIPort
+
+	return &testMaster{
+		Master: master,
+		server: server,
+		client: client.New(server.URL, client.WithRetries(1, 0)),
+	}
+}
+
+// testSlave 在测试HTTP服务器上运行的从节点
+type testSlave struct {
+	*replication.Slave
+	server *httptest.Server
+}
+
+// startSlave 启动从节点并开始同步；从节点的API地址在创建前确定，注册和心跳中报告给主节点
+func startSlave(t *testing.T, cfg *config.SyncConfig, slaveID string) *testSlave {
+	t.Helper()
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	slaveCfg := *cfg
+	slaveCfg.Slave.APIPort = lis.Addr().(*net.TCPAddr).Port
+
+	slave, err := replication.NewSlave(&slaveCfg, slaveID)
+	if err != nil {
+		lis.Close()
+		t.Fatalf("start slave %s: %v", slaveID, err)
+	}
+	server := httptest.NewUnstartedServer(api.NewSlaveHandler(slave).SetupSlaveRoutes())
+	server.Listener.Close()
+	server.Listener = lis
+	server.Start()
+
+	ts := &testSlave{Slave: slave, server: server}
+	t.Cleanup(ts.stop)
+	slave.StartSync()
+	return ts
+}
+
+// stop 停止同步并关闭从节点，可以重复调用
+func (s *testSlave) stop() {
+	if s.server == nil {
+		return
+	}
+	s.StopSync()
+	s.server.Close()
+	s.Close()
+	s.server = nil
+}
+
+// waitFor 等待cond成立，超时后以desc失败
+func waitFor(t *testing.T, timeout time.Duration, desc string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(timeout)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out after %v waiting for %s", timeout, desc)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+// waitCaughtUp 等待从节点应用到主节点当前的binlog位置
+func waitCaughtUp(t *testing.T, master *testMaster, slave *testSlave) {
+	t.Helper()
+	target := master.GetCurrentBinlogPosition()
+	waitFor(t, convergeTimeout, "slave to reach binlog position", func() bool {
+		return slave.GetStats().CurrentPosition >= target
+	})
+}
+
+// assertConverged 等待从节点追上主节点，然后比较两边记录表的校验和与内容
+func assertConverged(t *testing.T, master *testMaster, slave *testSlave) {
+	t.Helper()
+	waitCaughtUp(t, master, slave)
+
+	want, err := master.GetDB().Checksum()
+	if err != nil {
+		t.Fatalf("master checksum: %v", err)
+	}
+	got, err := slave.GetDB().Checksum()
+	if err != nil {
+		t.Fatalf("slave checksum: %v", err)
+	}
+	if got != want {
+		t.Errorf("slave checksum %+v, master %+v", got, want)
+		diffRecords(t, master.GetDB(), slave.GetDB())
+	}
+}
+
+// diffRecords 输出两边不一致的记录，帮助定位校验和不同的原因
+func diffRecords(t *testing.T, master, slave *storage.DB) {
+	t.Helper()
+	want, err := master.ListRecords()
+	if err != nil {
+		t.Fatalf("list master records: %v", err)
+	}
+	got, err := slave.ListRecords()
+	if err != nil {
+		t.Fatalf("list slave records: %v", err)
+	}
+	rows := make(map[uint]string, len(got))
+	for _, r := range got {
+		rows[r.ID] = r.Content
+	}
+	for _, r := range want {
+		content, ok := rows[r.ID]
+		switch {
+		case !ok:
+			t.Errorf("record %d missing on slave", r.ID)
+		case content != r.Content:
+			t.Errorf("record %d: slave has %q, master %q", r.ID, content, r.Content)
+		}
+		delete(rows, r.ID)
+	}
+	for id := range rows {
+		t.Errorf("record %d exists only on slave", id)
+	}
+}
+
+// slaveInfo 主节点 /api/status 中该从节点的信息
+func slaveInfo(t *testing.T, master *testMaster, slaveID string) (client.SlaveInfo, bool) {
+	t.Helper()
+	status, err := master.client.Status(context.Background())
+	if err != nil {
+		t.Fatalf("master status: %v", err)
+	}
+	for _, info := range status.SlaveInfos {
+		if info.ID == slaveID {
+			return info, true
+		}
+	}
+	return client.SlaveInfo{}, false
+}
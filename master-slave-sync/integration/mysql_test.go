@@ -0,0 +1,182 @@
+//go:build integration
+
+package integration
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"os/exec"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// 测试用MySQL容器的参数，镜像可以用 MSS_IT_MYSQL_IMAGE 覆盖
+const (
+	defaultMySQLImage = "mysql:8.0"
+	mysqlPassword     = "mss-it"
+	mysqlStartTimeout = 3 * time.Minute
+)
+
+// mysqlContainer 一个测试用的MySQL容器，映射到本机的固定端口，停止后重新启动端口不变
+type mysqlContainer struct {
+	name string // 容器名
+	port int    // 本机端口
+}
+
+// 主节点和从节点各使用一个容器，所有测试共用，每个测试创建自己的数据库
+var (
+	masterMySQL *mysqlContainer
+	slaveMySQL  *mysqlContainer
+	skipReason  string       // 无法启动容器时跳过所有测试的原因
+	databaseSeq atomic.Int64 // 测试数据库的序号
+)
+
+func TestMain(m *testing.M) {
+	if err := exec.Command("docker", "info").Run(); err != nil {
+		skipReason = fmt.Sprintf("docker is not available: %v", err)
+		os.Exit(m.Run())
+	}
+
+	image := os.Getenv("MSS_IT_MYSQL_IMAGE")
+	if image == "" {
+		image = defaultMySQLImage
+	}
+	var err error
+	masterMySQL, err = startMySQL("mss-it-master", image)
+	if err == nil {
+		slaveMySQL, err = startMySQL("mss-it-slave", image)
+	}
+	if err != nil {
+		log.Printf("Failed to start MySQL containers: %v", err)
+		removeContainers()
+		os.Exit(1)
+	}
+
+	code := m.Run()
+	removeContainers()
+	os.Exit(code)
+}
+
+// removeContainers 删除测试启动的容器
+func removeContainers() {
+	for _, c := range []*mysqlContainer{masterMySQL, slaveMySQL} {
+		if c != nil {
+			exec.Command("docker", "rm", "-f", c.name).Run()
+		}
+	}
+}
+
+// requireDocker 没有可用的docker时跳过测试
+func requireDocker(t *testing.T) {
+	t.Helper()
+	if skipReason != "" {
+		t.Skip(skipReason)
+	}
+}
+
+// startMySQL 启动一个MySQL容器并等待它可以连接，容器名带上进程ID，避免与并行的测试进程冲突
+func startMySQL(prefix, image string) (*mysqlContainer, error) {
+	port, err := freePort()
+	if err != nil {
+		return nil, err
+	}
+	c := &mysqlContainer{name: fmt.Sprintf("%s-%d", prefix, os.Getpid()), port: port}
+	out, err := exec.Command("docker", "run", "-d", "--name", c.name,
+		"-e", "MYSQL_ROOT_PASSWORD="+mysqlPassword,
+		"-p", fmt.Sprintf("127.0.0.1:%d:3306", port),
+		image).CombinedOutput()
+	if err != nil {
+		return nil, fmt.Errorf("docker run %s: %v: %s", c.name, err, strings.TrimSpace(string(out)))
+	}
+	if err := c.waitReady(mysqlStartTimeout); err != nil {
+		return c, err
+	}
+	log.Printf("MySQL container %s ready on port %d", c.name, port)
+	return c, nil
+}
+
+// freePort 本机当前空闲的TCP端口
+func freePort() (int, error) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return 0, err
+	}
+	defer lis.Close()
+	return lis.Addr().(*net.TCPAddr).Port, nil
+}
+
+// dsn 连接容器中database的DSN，database为空时不选择数据库
+func (c *mysqlContainer) dsn(database string) string {
+	return fmt.Sprintf("root:%s@tcp(127.0.0.1:%d)/%s?charset=utf8mb4&parseTime=True&loc=Local", mysqlPassword, c.port, database)
+}
+
+// open 连接容器中的MySQL（不选择数据库），不输出SQL日志
+func (c *mysqlContainer) open() (*gorm.DB, error) {
+	return gorm.Open(mysql.Open(c.dsn("")), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+}
+
+// waitReady 等待容器中的MySQL可以连接：镜像初始化时会先启动一个不监听TCP的临时实例，
+// 能通过映射的端口连接时初始化已经完成
+func (c *mysqlContainer) waitReady(timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	var lastErr error
+	for time.Now().Before(deadline) {
+		db, err := c.open()
+		if err == nil {
+			sqlDB, _ := db.DB()
+			ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+			err = sqlDB.PingContext(ctx)
+			cancel()
+			sqlDB.Close()
+			if err == nil {
+				return nil
+			}
+		}
+		lastErr = err
+		time.Sleep(time.Second)
+	}
+	return fmt.Errorf("mysql in %s not ready after %v: %v", c.name, timeout, lastErr)
+}
+
+// createDatabase 为测试创建一个新的数据库
+func (c *mysqlContainer) createDatabase(t *testing.T) string {
+	t.Helper()
+	name := fmt.Sprintf("mss_it_%d", databaseSeq.Add(1))
+	db, err := c.open()
+	if err != nil {
+		t.Fatalf("connect to %s: %v", c.name, err)
+	}
+	sqlDB, _ := db.DB()
+	defer sqlDB.Close()
+	if err := db.Exec("CREATE DATABASE " + name).Error; err != nil {
+		t.Fatalf("create database %s: %v", name, err)
+	}
+	return name
+}
+
+// stop 停止容器（模拟数据库宕机），start 重新启动并等待可以连接
+func (c *mysqlContainer) stop(t *testing.T) {
+	t.Helper()
+	if out, err := exec.Command("docker", "stop", c.name).CombinedOutput(); err != nil {
+		t.Fatalf("docker stop %s: %v: %s", c.name, err, strings.TrimSpace(string(out)))
+	}
+}
+
+func (c *mysqlContainer) start(t *testing.T) {
+	t.Helper()
+	if out, err := exec.Command("docker", "start", c.name).CombinedOutput(); err != nil {
+		t.Fatalf("docker start %s: %v: %s", c.name, err, strings.TrimSpace(string(out)))
+	}
+	if err := c.waitReady(mysqlStartTimeout); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,185 @@
+//go:build integration
+
+package integration
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"master-slave-sync/client"
+	"master-slave-sync/internal/replication"
+)
+
+// writeRecords 通过主节点API创建n条记录，返回它们的ID
+func writeRecords(t *testing.T, master *testMaster, prefix string, n int, durability client.Durability) []uint {
+	t.Helper()
+	ids := make([]uint, 0, n)
+	for i := 0; i < n; i++ {
+		record, _, err := master.client.CreateRecord(context.Background(), fmt.Sprintf("%s-%d", prefix, i),
+			client.WriteOptions{Durability: durability})
+		if err != nil {
+			t.Fatalf("create record %d: %v", i, err)
+		}
+		ids = append(ids, record.ID)
+	}
+	return ids
+}
+
+func TestWritesConvergeOnSlave(t *testing.T) {
+	requireDocker(t)
+	cfg := testConfig(t)
+	master := startMaster(t, cfg)
+	slave := startSlave(t, cfg, "it-slave")
+
+	ctx := context.Background()
+	ids := writeRecords(t, master, "record", 20, client.DurabilitySemiSync)
+	for _, id := range ids[:10] {
+		if _, err := master.client.UpdateRecord(ctx, id, fmt.Sprintf("updated-%d", id), client.WriteOptions{}); err != nil {
+			t.Fatalf("update record %d: %v", id, err)
+		}
+	}
+	for _, id := range ids[15:] {
+		if _, err := master.client.DeleteRecord(ctx, id, client.WriteOptions{}); err != nil {
+			t.Fatalf("delete record %d: %v", id, err)
+		}
+	}
+
+	assertConverged(t, master, slave)
+	record, err := slave.GetDB().GetRecord(ids[0])
+	if err != nil {
+		t.Fatalf("read record %d on slave: %v", ids[0], err)
+	}
+	if want := fmt.Sprintf("updated-%d", ids[0]); record.Content != want {
+		t.Errorf("record %d on slave is %q, want %q", ids[0], record.Content, want)
+	}
+	if _, err := slave.GetDB().GetRecord(ids[19]); err == nil {
+		t.Errorf("deleted record %d still exists on slave", ids[19])
+	}
+}
+
+func TestLagReportedWhileSlaveStoppedAndClearedAfterCatchUp(t *testing.T) {
+	requireDocker(t)
+	cfg := testConfig(t)
+	master := startMaster(t, cfg)
+	slave := startSlave(t, cfg, "it-slave")
+
+	writeRecords(t, master, "before", 3, client.DurabilitySemiSync)
+	waitCaughtUp(t, master, slave)
+
+	slave.StopSync()
+	writeRecords(t, master, "behind", 5, client.DurabilityLocal)
+	info, ok := slaveInfo(t, master, "it-slave")
+	if !ok {
+		t.Fatal("slave not listed in master status")
+	}
+	if info.BehindEntries < 5 {
+		t.Errorf("stopped slave is %d entries behind, want at least 5", info.BehindEntries)
+	}
+	waitFor(t, convergeTimeout, "stopped slave to report lag", func() bool {
+		info, _ := slaveInfo(t, master, "it-slave")
+		return info.LagSeconds > 0
+	})
+
+	slave.StartSync()
+	assertConverged(t, master, slave)
+	waitFor(t, convergeTimeout, "lag to clear after catch-up", func() bool {
+		info, _ := slaveInfo(t, master, "it-slave")
+		return info.BehindEntries == 0 && info.LagSeconds == 0
+	})
+}
+
+func TestSemiSyncDegradesWithoutSlaveAndRecovers(t *testing.T) {
+	requireDocker(t)
+	cfg := testConfig(t)
+	master := startMaster(t, cfg)
+	slave := startSlave(t, cfg, "it-slave")
+	ctx := context.Background()
+
+	writeRecords(t, master, "healthy", 1, client.DurabilitySemiSync)
+	waitCaughtUp(t, master, slave)
+	if status := master.GetStats().SemiSyncStatus; status != replication.StatusOK {
+		t.Fatalf("semi-sync status %s with a healthy slave, want OK", status)
+	}
+
+	// 从节点停止后写入等待确认超时（或恢复检查已发现从节点失联），半同步降级，strict级别的写入返回未复制
+	slave.StopSync()
+	_, result, err := master.client.CreateRecord(ctx, "unacknowledged", client.WriteOptions{Durability: client.DurabilitySemiSync})
+	if err != nil {
+		t.Fatalf("semi-sync write without slave: %v", err)
+	}
+	switch replication.SemiSyncStatus(result.ReplicationStatus) {
+	case replication.StatusTimeout, replication.StatusDegraded:
+	default:
+		t.Errorf("write without slave returned replication status %q, want TIMEOUT or DEGRADED", result.ReplicationStatus)
+	}
+	if status := master.GetStats().SemiSyncStatus; status != replication.StatusDegraded {
+		t.Fatalf("semi-sync status %s after ACK timeout, want DEGRADED", status)
+	}
+	_, _, err = master.client.CreateRecord(ctx, "strict", client.WriteOptions{Durability: client.DurabilityStrict})
+	if !errors.Is(err, client.ErrNotReplicated) {
+		t.Errorf("strict write while degraded returned %v, want ErrNotReplicated", err)
+	}
+
+	// 从节点追上后恢复检查把状态恢复为正常，之后的写入重新得到确认
+	slave.StartSync()
+	waitFor(t, convergeTimeout, "semi-sync to recover", func() bool {
+		return master.GetStats().SemiSyncStatus == replication.StatusOK
+	})
+	start := time.Now()
+	_, result, err = master.client.CreateRecord(ctx, "acknowledged", client.WriteOptions{Durability: client.DurabilitySemiSync})
+	if err != nil {
+		t.Fatalf("semi-sync write after recovery: %v", err)
+	}
+	if result.ReplicationStatus != string(replication.StatusOK) {
+		t.Errorf("write after recovery returned replication status %q (after %v), want OK", result.ReplicationStatus, time.Since(start))
+	}
+	assertConverged(t, master, slave)
+}
+
+func TestSlaveResumesFromSavedPositionAfterRestart(t *testing.T) {
+	requireDocker(t)
+	cfg := testConfig(t)
+	master := startMaster(t, cfg)
+	slave := startSlave(t, cfg, "it-slave")
+
+	writeRecords(t, master, "first", 10, client.DurabilitySemiSync)
+	waitCaughtUp(t, master, slave)
+	slave.stop()
+
+	writeRecords(t, master, "while-down", 7, client.DurabilityLocal)
+	restarted := startSlave(t, cfg, "it-slave")
+	assertConverged(t, master, restarted)
+	if applied := restarted.GetStats().AppliedCount; applied != 7 {
+		t.Errorf("restarted slave applied %d entries, want only the 7 written while it was down", applied)
+	}
+}
+
+func TestSlaveRecoversAfterDatabaseOutage(t *testing.T) {
+	requireDocker(t)
+	cfg := testConfig(t)
+	master := startMaster(t, cfg)
+	slave := startSlave(t, cfg, "it-slave")
+
+	writeRecords(t, master, "before-outage", 5, client.DurabilitySemiSync)
+	waitCaughtUp(t, master, slave)
+
+	// 从库宕机期间主节点继续写入，从节点应用失败后重试，从库恢复后追上
+	slaveMySQL.stop(t)
+	restarted := false
+	t.Cleanup(func() {
+		// 测试中途失败时同样重新启动，后续的测试还要使用这个容器
+		if !restarted {
+			slaveMySQL.start(t)
+		}
+	})
+	writeRecords(t, master, "during-outage", 10, client.DurabilityLocal)
+	time.Sleep(2 * time.Second)
+	slaveMySQL.start(t)
+	restarted = true
+
+	writeRecords(t, master, "after-outage", 5, client.DurabilitySemiSync)
+	assertConverged(t, master, slave)
+}
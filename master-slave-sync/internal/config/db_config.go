@@ -5,43 +5,203 @@ import "fmt"
 // MasterConfig 主节点配置
 type MasterConfig struct {
 	// 数据库连接信息
-	Host     string
-	Port     int
-	User     string
-	Password string
-	DBName   string
+	Host     string `yaml:"host"`
+	Port     int    `yaml:"port"`
+	User     string `yaml:"user"`
+	Password string `yaml:"password"`
+	DBName   string `yaml:"db_name"`
 	// API服务配置
-	APIPort int
+	APIPort int `yaml:"api_port"`
+	// 过期记录清理间隔(毫秒)，0表示不清理
+	ExpiryIntervalMs int `yaml:"expiry_interval_ms"`
+	// binlog持久化文件路径，为空表示只保存在内存中（重启后复制历史丢失）
+	BinlogPath string `yaml:"binlog_path"`
+	// binlog刷盘策略："always"（默认，每条都fsync）、"interval"（定期fsync）或 "none"（交给操作系统）
+	BinlogSync string `yaml:"binlog_sync"`
+	// 新的binlog分段文件中条目的编码："binary"（默认，紧凑的二进制编码）或 "json"（每行一个JSON条目，便于阅读）
+	BinlogEncoding string `yaml:"binlog_encoding"`
+	// 刷盘策略为interval时的刷盘间隔(毫秒)，0表示默认100毫秒
+	BinlogSyncIntervalMs int `yaml:"binlog_sync_interval_ms"`
+	// 是否开启组提交：并发的binlog追加合并成组，每组只写入并刷盘一次，分摊always策略下每条fsync的开销
+	BinlogGroupCommit bool `yaml:"binlog_group_commit"`
+	// 组提交时组长等待其他追加者加入的时间(微秒)，0表示不额外等待（刷盘期间到达的追加仍会成组）
+	BinlogGroupCommitWindowUs int `yaml:"binlog_group_commit_window_us"`
+	// binlog分段文件达到该大小(字节)后切换到新分段，0表示默认64MB
+	BinlogMaxSegmentBytes int64 `yaml:"binlog_max_segment_bytes"`
+	// binlog分段文件的第一个条目写入超过该时长(秒)后切换到新分段，0表示不按时间切换
+	BinlogMaxSegmentAgeSec int `yaml:"binlog_max_segment_age_sec"`
+	// 清理已被所有从节点确认的binlog分段的间隔(毫秒)，0表示不清理
+	BinlogRetentionIntervalMs int `yaml:"binlog_retention_interval_ms"`
+	// 从节点发送心跳的预期间隔(毫秒)，0表示默认2秒
+	HeartbeatIntervalMs int `yaml:"heartbeat_interval_ms"`
+	// 连续错过多少次心跳后将从节点标记为失联，0表示默认3次
+	HeartbeatMissLimit int `yaml:"heartbeat_miss_limit"`
+	// 从节点注册租约的时长(毫秒)，注册和每次心跳时续期；到期的从节点被移除（不再计入半同步、不再阻止binlog清理），
+	// 0表示使用 slave_evict_after_ms，两者都为0时默认30秒
+	SlaveLeaseMs int `yaml:"slave_lease_ms"`
+	// 旧的配置项，未配置 slave_lease_ms 时作为租约时长
+	SlaveEvictAfterMs int `yaml:"slave_evict_after_ms"`
+	// HTTP服务器的TLS证书和私钥文件（PEM），都配置时API和复制接口通过https提供；为空表示不启用TLS
+	TLSCertFile string `yaml:"tls_cert_file"`
+	TLSKeyFile  string `yaml:"tls_key_file"`
+	// 是否通过https访问从节点的API（分块一致性检查）
+	SlaveTLS bool `yaml:"slave_tls"`
+	// 校验从节点证书的CA证书文件（PEM），为空表示使用系统的根证书
+	SlaveCAFile string `yaml:"slave_ca_file"`
+	// gRPC复制服务端口，0表示不启动gRPC服务（从节点只能使用HTTP传输）
+	GRPCPort int `yaml:"grpc_port"`
+	// binlog格式："row"（默认，记录行数据）或 "statement"（记录执行的SQL语句和参数）
+	BinlogFormat string `yaml:"binlog_format"`
+	// 服务器ID，写入每个binlog条目，级联复制中用于发现复制环；同一复制拓扑中的节点不能重复，0表示不检查
+	ServerID uint32 `yaml:"server_id"`
+	// 主节点的纪元，写入每个binlog条目；0表示从binlog中最后一个条目的纪元继续（没有时为1），
+	// 只有在binlog丢失、需要手动保证新纪元高于之前所有主节点时才需要设置
+	Epoch uint64 `yaml:"epoch"`
+	// 分块一致性检查的间隔(毫秒)，0表示不自动检查
+	ConsistencyCheckIntervalMs int `yaml:"consistency_check_interval_ms"`
+	// 分块一致性检查每块覆盖的ID数，0表示默认1000
+	ConsistencyChunkSize int `yaml:"consistency_chunk_size"`
+	// 分块一致性检查发现不一致时，是否把不一致区间内的行重新写入binlog
+	ConsistencyRepair bool `yaml:"consistency_repair"`
+	// 复制凭据：从节点ID -> 令牌。配置后复制接口（binlog、推送流、确认、心跳、注册及gRPC复制服务）
+	// 只接受携带对应令牌的从节点；为空表示不校验
+	SlaveTokens map[string]string `yaml:"slave_tokens"`
+	// 管理操作（执行DDL、注入binlog条目、管理变更订阅）的令牌，请求通过 Authorization: Bearer 携带；为空表示禁用管理操作
+	AdminToken string `yaml:"admin_token"`
+	// 允许变更订阅的Webhook指向回环、链路本地等内部地址（本机调试时使用），默认拒绝
+	CDCAllowInternalTargets bool `yaml:"cdc_allow_internal_targets"`
+	// 流量控制：活跃的从节点落后超过该条目数时延迟写入的返回，0表示不限制
+	FlowControlMaxBehind int `yaml:"flow_control_max_behind"`
+	// 流量控制时每次写入的最大延迟(毫秒)，0表示默认500毫秒
+	FlowControlMaxDelayMs int `yaml:"flow_control_max_delay_ms"`
+	// 把每个binlog条目发布到的Kafka主题，为空表示不发布
+	KafkaTopic string `yaml:"kafka_topic"`
+	// Kafka的引导broker地址（host:port）
+	KafkaBrokers []string `yaml:"kafka_brokers"`
+	// 写入的分区，所有条目写入同一分区以保持binlog顺序
+	KafkaPartition int `yaml:"kafka_partition"`
+	// binlog条目的来源："internal"（默认，API写入提交时生成条目）或 "mysql"（以副本身份读取MySQL自身的binlog，
+	// 把已注册表的行事件转换为条目，绕过API直接写入数据库的变更同样会复制）
+	BinlogSource string `yaml:"binlog_source"`
+	// binlog_source 为 mysql 时注册为MySQL副本使用的服务器ID，不能与MySQL服务器及其他副本重复，0表示默认1001
+	TailServerID uint32 `yaml:"tail_server_id"`
 }
 
 // SlaveConfig 从节点配置
 type SlaveConfig struct {
 	// 数据库连接信息
-	Host     string
-	Port     int
-	User     string
-	Password string
-	DBName   string
+	Host     string `yaml:"host"`
+	Port     int    `yaml:"port"`
+	User     string `yaml:"user"`
+	Password string `yaml:"password"`
+	DBName   string `yaml:"db_name"`
 	// API服务配置
-	APIPort int
+	APIPort int `yaml:"api_port"`
 	// 主节点连接信息
-	MasterHost string
-	MasterPort int
+	MasterHost string `yaml:"master_host"`
+	MasterPort int    `yaml:"master_port"`
+	// 同步间隔(毫秒)，推送模式下为断开后重连的间隔，0表示默认5秒
+	SyncIntervalMs int `yaml:"sync_interval_ms"`
+	// 轮询间隔的下限(毫秒)，拉取到新条目后按它轮询，0表示默认200毫秒
+	SyncIntervalMinMs int `yaml:"sync_interval_min_ms"`
+	// 轮询间隔的上限(毫秒)，没有新条目时间隔逐次加倍直到它，0表示默认30秒
+	SyncIntervalMaxMs int `yaml:"sync_interval_max_ms"`
+	// 一致性校验时间表（如 "@every 5m" 或 "*/10 * * * *"），为空表示不自动校验
+	VerifySchedule string `yaml:"verify_schedule"`
+	// 发现数据不一致时通知的Webhook地址（可选）
+	AlertWebhook string `yaml:"alert_webhook"`
+	// 访问主节点的请求超时(毫秒)，0表示默认5秒
+	MasterTimeoutMs int `yaml:"master_timeout_ms"`
+	// 单次请求失败后的最大尝试次数（指数退避），0表示默认3次
+	MasterRetries int `yaml:"master_retries"`
+	// 连续失败多少次后熔断，0表示默认5次
+	BreakerThreshold int `yaml:"breaker_threshold"`
+	// 熔断后多久放行一次探测请求(毫秒)，0表示默认30秒
+	BreakerCooldownMs int `yaml:"breaker_cooldown_ms"`
+	// 收到写请求时的响应方式："reject"（默认，返回405）或 "redirect"（返回307并附带主节点地址）
+	WriteRejectMode string `yaml:"write_reject_mode"`
+	// 复制方式："push"（默认，通过WebSocket接收主节点推送，断开时回退到轮询）或 "poll"（定期轮询）
+	ReplicationMode string `yaml:"replication_mode"`
+	// 推送流的传输协议："http"（默认，WebSocket推送流和HTTP接口）或 "grpc"（主节点的gRPC复制服务）
+	Transport string `yaml:"transport"`
+	// HTTP传输时请求的binlog条目编码："binary"（默认，紧凑的二进制编码）或 "json"（便于抓包查看）
+	BinlogEncoding string `yaml:"binlog_encoding"`
+	// 主节点gRPC复制服务端口，Transport为grpc时使用
+	MasterGRPCPort int `yaml:"master_grpc_port"`
+	// 向主节点发送心跳的间隔(毫秒)，0表示默认2秒
+	HeartbeatIntervalMs int `yaml:"heartbeat_interval_ms"`
+	// 服务器ID，同一复制拓扑中的节点不能重复，0表示不检查复制环
+	ServerID uint32 `yaml:"server_id"`
+	// 作为中继时保留的最近已应用条目数，下游从节点可以通过本节点的 /api/binlog 同步；0表示不作为中继
+	RelayLogSize int `yaml:"relay_log_size"`
+	// 复制过滤规则，不匹配的条目不应用（只推进位置），零值表示复制全部条目
+	Filter ReplicationFilter `yaml:"filter"`
+	// 是否把过滤规则注册到主节点，由主节点在下发条目前过滤（节省传输），从节点仍会在本地再过滤一次
+	FilterOnMaster bool `yaml:"filter_on_master"`
+	// 每次从主节点获取的最大条目数，0表示默认500
+	FetchLimit int `yaml:"fetch_limit"`
+	// 每次从主节点获取的条目的最大总大小(字节)，0表示默认4MB
+	FetchMaxBytes int `yaml:"fetch_max_bytes"`
+	// 主节点故障时是否在已注册的从节点之间自动选举新的主节点
+	AutoFailover bool `yaml:"auto_failover"`
+	// 本节点是否为备用主节点：平时与其他从节点一样复制主节点，主节点故障时直接提升为主节点（不经过选举）
+	StandbyMaster bool `yaml:"standby_master"`
+	// 备用主节点的地址和API端口，主节点故障且备用主节点接管后切换过去；为空表示没有备用主节点
+	StandbyHost string `yaml:"standby_host"`
+	StandbyPort int    `yaml:"standby_port"`
+	// 应用带有前映像的UPDATE条目前，是否检查本地的行与前映像一致；不一致时停止应用并报告冲突
+	UpdateConflictCheck bool `yaml:"update_conflict_check"`
+	// 需要的binlog条目已被主节点清理或收到的条目不连续时，是否自动从主节点全量重新同步（会清空并重新加载本地的表）
+	AutoResync bool `yaml:"auto_resync"`
+	// 主节点不可达时连续多少次同步失败后认为主节点已故障，0表示默认5次
+	MasterFailureThreshold int `yaml:"master_failure_threshold"`
+	// 合并确认的间隔(毫秒)：大于0时已应用的位置每隔这么久确认一次（只发送最新的位置），
+	// 0表示每批条目应用后立即确认最后的位置；半同步复制的写入需要等待确认，间隔应远小于半同步超时
+	AckIntervalMs int `yaml:"ack_interval_ms"`
+	// 访问复制源时携带的令牌，对应主节点 SlaveTokens 中本节点ID的令牌；为空表示不携带
+	AuthToken string `yaml:"auth_token"`
+	// 作为中继时下游从节点的复制凭据（从节点ID -> 令牌），为空表示不校验
+	DownstreamTokens map[string]string `yaml:"downstream_tokens"`
+	// 管理操作（手动提升为主节点、手动选举、跳过条目）的令牌，请求通过 Authorization: Bearer 携带；为空表示禁用管理操作
+	AdminToken string `yaml:"admin_token"`
+	// 本节点HTTP服务器的TLS证书和私钥文件（PEM），都配置时通过https提供API（包括下游从节点的复制接口）
+	TLSCertFile string `yaml:"tls_cert_file"`
+	TLSKeyFile  string `yaml:"tls_key_file"`
+	// 是否通过https访问主节点（以及选举时访问其他从节点）
+	MasterTLS bool `yaml:"master_tls"`
+	// 校验主节点证书的CA证书文件（PEM），为空表示使用系统的根证书
+	MasterCAFile string `yaml:"master_ca_file"`
+}
+
+// ReplicationFilter 复制过滤规则，同时用作注册请求和管理接口中的JSON
+type ReplicationFilter struct {
+	// 只复制这些表，为空表示全部
+	IncludeTables []string `json:"include_tables,omitempty" yaml:"include_tables"`
+	// 不复制这些表（优先于 IncludeTables）
+	ExcludeTables []string `json:"exclude_tables,omitempty" yaml:"exclude_tables"`
+	// 不复制的操作类型，如 ["DELETE"]
+	SkipOperations []string `json:"skip_operations,omitempty" yaml:"skip_operations"`
 }
 
 // SemiSyncConfig 半同步复制配置
 type SemiSyncConfig struct {
 	// 等待从节点确认的超时时间(毫秒)
-	TimeoutMs int
+	TimeoutMs int `yaml:"timeout_ms"`
 	// 需要等待的从节点确认数
-	MinSlaves int
+	MinSlaves int `yaml:"min_slaves"`
+	// 等待确认的时机："after_commit"（默认，主节点先提交再等待）或 "after_sync"（先发送binlog并等待确认，再提交）
+	WaitPoint string `yaml:"wait_point"`
+	// 降级后检查从节点是否恢复的间隔(毫秒)，0表示使用默认值
+	ProbeIntervalMs int `yaml:"probe_interval_ms"`
 }
 
 // SyncConfig 整体配置结构
 type SyncConfig struct {
-	Master   MasterConfig
-	Slave    SlaveConfig
-	SemiSync SemiSyncConfig
+	Master   MasterConfig   `yaml:"master"`
+	Slave    SlaveConfig    `yaml:"slave"`
+	SemiSync SemiSyncConfig `yaml:"semi_sync"`
+	// 配置文件 slaves 列表中的从节点（从节点ID -> 在 slave 部分的基础上覆盖各自字段后的完整配置），由 Load 填充
+	Slaves map[string]SlaveConfig `yaml:"-"`
 }
 
 // GetDSN 生成数据库连接字符串
@@ -66,6 +226,31 @@ func GetDefaultConfig() *SyncConfig {
 			Password: "",
 			DBName:   "test_sync1",
 			APIPort:  8080,
+			// 每秒清理一次过期记录
+			ExpiryIntervalMs: 1000,
+			// binlog持久化到文件，每条条目都fsync
+			BinlogPath:     "data/master.binlog",
+			BinlogSync:     "always",
+			BinlogEncoding: "binary",
+			// 并发追加合并成组提交，组长不额外等待
+			BinlogGroupCommit: true,
+			// 每个分段最多16MB或1小时，每分钟清理一次所有从节点都已确认的分段
+			BinlogMaxSegmentBytes:     16 << 20,
+			BinlogMaxSegmentAgeSec:    3600,
+			BinlogRetentionIntervalMs: 60000,
+			// 从节点每2秒一次心跳，错过3次标记为失联，租约30秒没有续期后移除
+			HeartbeatIntervalMs: 2000,
+			HeartbeatMissLimit:  3,
+			SlaveLeaseMs:        30000,
+			// gRPC复制服务与HTTP接口同时提供，从节点按自己的配置选择
+			GRPCPort: 9090,
+			// 基于行复制，从节点的数据与主节点逐行一致
+			BinlogFormat: "row",
+			// 复制拓扑中的服务器ID
+			ServerID: 1,
+			// 每10分钟按1000个ID一块检查一次从节点，只报告不一致的区间
+			ConsistencyCheckIntervalMs: 600000,
+			ConsistencyChunkSize:       1000,
 		},
 		Slave: SlaveConfig{
 			Host:       "localhost",
@@ -76,10 +261,29 @@ func GetDefaultConfig() *SyncConfig {
 			APIPort:    8081,
 			MasterHost: "localhost",
 			MasterPort: 8080,
+			// 主节点通过WebSocket实时推送binlog
+			ReplicationMode: "push",
+			Transport:       "http",
+			MasterGRPCPort:  9090,
+			// HTTP传输使用二进制编码的条目
+			BinlogEncoding: "binary",
+			// 与主节点的 HeartbeatIntervalMs 保持一致
+			HeartbeatIntervalMs: 2000,
+			// 服务器ID与主节点不同；不作为下游从节点的中继
+			ServerID:     2,
+			RelayLogSize: 0,
+			// 每5分钟与主节点校验一次数据
+			VerifySchedule: "@every 5m",
+			// 增量同步无法继续时自动全量重新同步，不跳过缺失的条目
+			AutoResync: true,
 		},
 		SemiSync: SemiSyncConfig{
 			TimeoutMs: 1000, // 1秒超时
 			MinSlaves: 1,    // 至少等待一个从节点确认
+			// 与MySQL 5.7起的默认方式相同，从节点确认之前其他客户端读不到这次写入
+			WaitPoint: "after_sync",
+			// 每秒检查一次从节点是否已追上，追上后恢复半同步
+			ProbeIntervalMs: 1000,
 		},
 	}
 }
@@ -0,0 +1,399 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// 配置来源相关的名称
+const (
+	EnvPrefix  = "MSS_"       // 环境变量前缀，如 MSS_SLAVE_MASTER_HOST 对应 slave.master_host
+	EnvConfig  = "MSS_CONFIG" // 未指定 -config 时使用的配置文件路径
+	slavesKey  = "slaves"     // 配置文件中从节点列表的键
+	slaveIDKey = "id"         // 从节点列表中每一项的ID字段
+)
+
+// ErrUnknownKey 配置项不存在
+var ErrUnknownKey = errors.New("unknown config key")
+
+// LoadOptions 加载配置的来源，按 默认值 < 配置文件 < 环境变量 < Overrides 的顺序覆盖
+type LoadOptions struct {
+	File      string   // YAML配置文件路径，为空时不读取
+	SlaveID   string   // 从节点ID：配置文件 slaves 列表中的同名项覆盖 slave 部分，为空表示不选择（主节点）
+	Env       []string // 环境变量（KEY=VALUE），通常为 os.Environ()
+	Overrides []string // 命令行指定的配置项，如 "slave.sync_interval_ms=1000"
+}
+
+// Load 加载配置：
+//  1. GetDefaultConfig 的默认值
+//  2. 配置文件中的 master、slave、semi_sync 部分只覆盖出现的字段；slaves 列表中的每一项在 slave 部分的基础上覆盖各自的字段，
+//     结果放在 Slaves 中，SlaveID 对应的一项同时成为 Slave
+//  3. 环境变量 MSS_<部分>_<字段>，如 MSS_SEMI_SYNC_TIMEOUT_MS=2000
+//  4. Overrides（命令行的 -set 等参数）
+//
+// slaves 列表中配置了 auth_token 的从节点自动加入主节点的 slave_tokens（已显式配置的不覆盖）
+func Load(opts LoadOptions) (*SyncConfig, error) {
+	cfg := GetDefaultConfig()
+	var slaves []yaml.Node
+	if opts.File != "" {
+		var err error
+		if slaves, err = loadFile(cfg, opts.File); err != nil {
+			return nil, err
+		}
+	}
+
+	base := cfg.Slave
+	cfg.Slaves = make(map[string]SlaveConfig, len(slaves))
+	for i := range slaves {
+		var entry struct {
+			ID string `yaml:"id"`
+		}
+		if err := slaves[i].Decode(&entry); err != nil || entry.ID == "" {
+			return nil, fmt.Errorf("%s: entry %d of %s requires an %s", opts.File, i+1, slavesKey, slaveIDKey)
+		}
+		slave := base
+		// 覆盖时复制引用类型，避免各从节点共享 slave 部分的切片和映射
+		slave.Filter = cloneFilter(base.Filter)
+		slave.DownstreamTokens = nil
+		if err := slaves[i].Decode(&slave); err != nil {
+			return nil, fmt.Errorf("%s: slave %s: %w", opts.File, entry.ID, err)
+		}
+		cfg.Slaves[entry.ID] = slave
+		if opts.SlaveID == entry.ID {
+			cfg.Slave = slave
+		}
+	}
+	for id, slave := range cfg.Slaves {
+		if slave.AuthToken == "" {
+			continue
+		}
+		if cfg.Master.SlaveTokens == nil {
+			cfg.Master.SlaveTokens = make(map[string]string)
+		}
+		if _, ok := cfg.Master.SlaveTokens[id]; !ok {
+			cfg.Master.SlaveTokens[id] = slave.AuthToken
+		}
+	}
+
+	if err := applyEnv(cfg, opts.Env); err != nil {
+		return nil, err
+	}
+	for _, override := range opts.Overrides {
+		key, value, ok := strings.Cut(override, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid config override %q, expected key=value", override)
+		}
+		if err := Set(cfg, strings.TrimSpace(key), value); err != nil {
+			return nil, err
+		}
+	}
+	return cfg, nil
+}
+
+// loadFile 读取YAML配置文件覆盖cfg，返回 slaves 列表中的各项（由调用方按从节点ID展开）
+func loadFile(cfg *SyncConfig, path string) ([]yaml.Node, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("failed to parse config file %s: %w", path, err)
+	}
+	if len(doc.Content) == 0 {
+		return nil, nil
+	}
+	if err := checkKeys(doc.Content[0], reflect.TypeOf(*cfg), ""); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if err := doc.Decode(cfg); err != nil {
+		return nil, fmt.Errorf("failed to parse config file %s: %w", path, err)
+	}
+
+	var file struct {
+		Slaves []yaml.Node `yaml:"slaves"`
+	}
+	if err := doc.Decode(&file); err != nil {
+		return nil, fmt.Errorf("failed to parse %s in %s: %w", slavesKey, path, err)
+	}
+	for _, slave := range file.Slaves {
+		if err := checkKeys(&slave, reflect.TypeOf(SlaveConfig{}), slavesKey+"[]", slaveIDKey); err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+	}
+	return file.Slaves, nil
+}
+
+// checkKeys 检查YAML映射中的键都是配置项，拼错的键不会被静默忽略；extra为额外允许的键
+func checkKeys(node *yaml.Node, t reflect.Type, prefix string, extra ...string) error {
+	if node.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key := node.Content[i].Value
+		path := joinKey(prefix, key)
+		if t.Kind() == reflect.Struct && prefix == "" && key == slavesKey {
+			continue
+		}
+		if containsString(extra, key) {
+			continue
+		}
+		field, ok := fieldByKey(t, key)
+		if !ok {
+			return fmt.Errorf("%w: %s", ErrUnknownKey, path)
+		}
+		if field.Type.Kind() == reflect.Struct {
+			if err := checkKeys(node.Content[i+1], field.Type, path); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// applyEnv 按环境变量覆盖配置项：每个配置项的环境变量名为 MSS_ 加上大写的键，"." 替换为 "_"
+func applyEnv(cfg *SyncConfig, env []string) error {
+	values := make(map[string]string, len(env))
+	for _, kv := range env {
+		if key, value, ok := strings.Cut(kv, "="); ok && strings.HasPrefix(key, EnvPrefix) {
+			values[key] = value
+		}
+	}
+	if len(values) == 0 {
+		return nil
+	}
+	for _, key := range Keys() {
+		name := EnvName(key)
+		if value, ok := values[name]; ok {
+			if err := Set(cfg, key, value); err != nil {
+				return fmt.Errorf("%s: %w", name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// EnvName 配置项对应的环境变量名，如 semi_sync.timeout_ms -> MSS_SEMI_SYNC_TIMEOUT_MS
+func EnvName(key string) string {
+	return EnvPrefix + strings.ToUpper(strings.ReplaceAll(key, ".", "_"))
+}
+
+// Keys 所有可以通过环境变量和 -set 设置的配置项（如 master.api_port、slave.filter.include_tables）
+func Keys() []string {
+	var keys []string
+	var walk func(t reflect.Type, prefix string)
+	walk = func(t reflect.Type, prefix string) {
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			key := yamlKey(field)
+			if key == "" {
+				continue
+			}
+			if field.Type.Kind() == reflect.Struct {
+				walk(field.Type, joinKey(prefix, key))
+				continue
+			}
+			keys = append(keys, joinKey(prefix, key))
+		}
+	}
+	walk(reflect.TypeOf(SyncConfig{}), "")
+	return keys
+}
+
+// Set 按键设置一个配置项：数值和布尔值按字面解析，列表为逗号分隔的值，映射为逗号分隔的 key=value
+func Set(cfg *SyncConfig, key, value string) error {
+	v := reflect.ValueOf(cfg).Elem()
+	for _, part := range strings.Split(key, ".") {
+		if v.Kind() != reflect.Struct {
+			return fmt.Errorf("%w: %s", ErrUnknownKey, key)
+		}
+		field, ok := fieldByKey(v.Type(), part)
+		if !ok {
+			return fmt.Errorf("%w: %s", ErrUnknownKey, key)
+		}
+		v = v.FieldByIndex(field.Index)
+	}
+	if err := setValue(v, value); err != nil {
+		return fmt.Errorf("invalid value %q for %s: %w", value, key, err)
+	}
+	return nil
+}
+
+// setValue 把字符串解析为字段的类型
+func setValue(v reflect.Value, value string) error {
+	value = strings.TrimSpace(value)
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(value)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int64:
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return err
+		}
+		v.SetInt(n)
+	case reflect.Uint32:
+		n, err := strconv.ParseUint(value, 10, 32)
+		if err != nil {
+			return err
+		}
+		v.SetUint(n)
+	case reflect.Slice:
+		list := reflect.MakeSlice(v.Type(), 0, 0)
+		for _, item := range splitList(value) {
+			list = reflect.Append(list, reflect.ValueOf(item))
+		}
+		v.Set(list)
+	case reflect.Map:
+		m := reflect.MakeMap(v.Type())
+		for _, item := range splitList(value) {
+			k, val, ok := strings.Cut(item, "=")
+			if !ok {
+				return fmt.Errorf("expected key=value, got %q", item)
+			}
+			m.SetMapIndex(reflect.ValueOf(strings.TrimSpace(k)), reflect.ValueOf(strings.TrimSpace(val)))
+		}
+		v.Set(m)
+	default:
+		return fmt.Errorf("unsupported type %s", v.Type())
+	}
+	return nil
+}
+
+// Flags 两个可执行文件共用的配置参数
+type Flags struct {
+	File      string    // -config
+	Overrides overrides // -set 及快捷参数，按出现的顺序应用
+}
+
+// overrides 可重复的 key=value 参数
+type overrides []string
+
+// String 实现 flag.Value
+func (o *overrides) String() string {
+	return strings.Join(*o, ",")
+}
+
+// Set 实现 flag.Value
+func (o *overrides) Set(value string) error {
+	*o = append(*o, value)
+	return nil
+}
+
+// BindFlags 在fs上注册配置参数：-config、可重复的 -set key=value，以及常用配置项的快捷参数
+func BindFlags(fs *flag.FlagSet) *Flags {
+	f := &Flags{}
+	fs.StringVar(&f.File, "config", "", "Path to a YAML config file (default $"+EnvConfig+")")
+	fs.Var(&f.Overrides, "set", "Override a config key, e.g. -set semi_sync.timeout_ms=2000 (repeatable)")
+
+	// 快捷参数，时长参数使用Go的时长格式（如 500ms、2s）
+	shortcut := func(name, key, usage string, convert func(string) (string, error)) {
+		fs.Func(name, usage, func(value string) error {
+			if convert != nil {
+				var err error
+				if value, err = convert(value); err != nil {
+					return err
+				}
+			}
+			f.Overrides = append(f.Overrides, key+"="+value)
+			return nil
+		})
+	}
+	shortcut("master-api-port", "master.api_port", "Master API port", nil)
+	shortcut("slave-api-port", "slave.api_port", "Slave API port", nil)
+	shortcut("master-host", "slave.master_host", "Master host the slave replicates from", nil)
+	shortcut("master-port", "slave.master_port", "Master API port the slave replicates from", nil)
+	shortcut("sync-interval", "slave.sync_interval_ms", "Slave sync interval, e.g. 1s", durationMs)
+	shortcut("semi-sync-timeout", "semi_sync.timeout_ms", "Semi-sync ACK timeout, e.g. 500ms", durationMs)
+	shortcut("semi-sync-min-slaves", "semi_sync.min_slaves", "Number of slave ACKs a semi-sync write waits for", nil)
+	return f
+}
+
+// Load 按参数加载配置，未指定 -config 时使用 $MSS_CONFIG
+func (f *Flags) Load(slaveID string) (*SyncConfig, error) {
+	file := f.File
+	if file == "" {
+		file = os.Getenv(EnvConfig)
+	}
+	return Load(LoadOptions{File: file, SlaveID: slaveID, Env: os.Environ(), Overrides: f.Overrides})
+}
+
+// durationMs 把时长参数转换为毫秒
+func durationMs(value string) (string, error) {
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return "", err
+	}
+	return strconv.FormatInt(d.Milliseconds(), 10), nil
+}
+
+// yamlKey 字段在配置文件中的键，忽略的字段返回空字符串
+func yamlKey(field reflect.StructField) string {
+	key, _, _ := strings.Cut(field.Tag.Get("yaml"), ",")
+	if key == "-" {
+		return ""
+	}
+	return key
+}
+
+// fieldByKey 按配置文件中的键查找字段
+func fieldByKey(t reflect.Type, key string) (reflect.StructField, bool) {
+	for i := 0; i < t.NumField(); i++ {
+		if field := t.Field(i); yamlKey(field) == key && key != "" {
+			return field, true
+		}
+	}
+	return reflect.StructField{}, false
+}
+
+// joinKey 拼接配置项的键
+func joinKey(prefix, key string) string {
+	if prefix == "" {
+		return key
+	}
+	return prefix + "." + key
+}
+
+// splitList 按逗号拆分列表，忽略空项
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// containsString 列表中是否包含s
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+// cloneFilter 复制过滤规则中的切片
+func cloneFilter(f ReplicationFilter) ReplicationFilter {
+	return ReplicationFilter{
+		IncludeTables:  append([]string(nil), f.IncludeTables...),
+		ExcludeTables:  append([]string(nil), f.ExcludeTables...),
+		SkipOperations: append([]string(nil), f.SkipOperations...),
+	}
+}
@@ -0,0 +1,467 @@
+package kafka
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// 使用的API及版本
+const (
+	apiProduce      = 0
+	apiMetadata     = 3
+	produceVersion  = 3 // 支持 RecordBatch v2（Kafka 0.11+）
+	metadataVersion = 1
+)
+
+// 默认参数，对应配置项为零值时使用
+const (
+	defaultClientID    = "master-slave-sync"
+	defaultDialTimeout = 5 * time.Second
+	defaultAckTimeout  = 10 * time.Second
+	maxResponseBytes   = 16 << 20
+)
+
+// ErrNoLeader 找不到分区的leader（主题不存在或正在选举）
+var ErrNoLeader = errors.New("no leader for partition")
+
+// castagnoli RecordBatch 使用的CRC32C表
+var castagnoli = crc32.MakeTable(crc32.Castagnoli)
+
+// Message 一条待写入的消息
+type Message struct {
+	Key   []byte
+	Value []byte
+	Time  time.Time
+}
+
+// BrokerError broker返回的错误码
+type BrokerError struct {
+	Code int16
+}
+
+// Error 实现 error
+func (e *BrokerError) Error() string {
+	if name, ok := errorNames[e.Code]; ok {
+		return fmt.Sprintf("kafka error %d (%s)", e.Code, name)
+	}
+	return fmt.Sprintf("kafka error %d", e.Code)
+}
+
+// errorNames 常见错误码的名称
+var errorNames = map[int16]string{
+	1:  "OFFSET_OUT_OF_RANGE",
+	2:  "CORRUPT_MESSAGE",
+	3:  "UNKNOWN_TOPIC_OR_PARTITION",
+	5:  "LEADER_NOT_AVAILABLE",
+	6:  "NOT_LEADER_FOR_PARTITION",
+	7:  "REQUEST_TIMED_OUT",
+	10: "MESSAGE_TOO_LARGE",
+	19: "NOT_ENOUGH_REPLICAS",
+	20: "NOT_ENOUGH_REPLICAS_AFTER_APPEND",
+	29: "TOPIC_AUTHORIZATION_FAILED",
+}
+
+// Config 生产者配置
+type Config struct {
+	Brokers   []string      // 引导broker地址（host:port），用于查询分区的leader
+	Topic     string        // 主题
+	Partition int32         // 写入的分区，所有消息写入同一分区以保持顺序
+	ClientID  string        // 客户端标识，为空时使用 master-slave-sync
+	Timeout   time.Duration // 等待所有同步副本确认的超时，0表示默认10秒
+}
+
+// Producer Kafka生产者的最小实现：只支持向一个分区同步写入（acks=all），不支持压缩、幂等和事务
+// 通过Metadata请求找到分区的leader并保持一个连接，出错时断开，下次写入时重新查询leader
+type Producer struct {
+	cfg Config
+
+	mu          sync.Mutex
+	conn        net.Conn
+	rd          *bufio.Reader
+	correlation int32
+}
+
+// NewProducer 创建生产者，第一次写入时才连接broker
+func NewProducer(cfg Config) *Producer {
+	if cfg.ClientID == "" {
+		cfg.ClientID = defaultClientID
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = defaultAckTimeout
+	}
+	return &Producer{cfg: cfg}
+}
+
+// Produce 把一批消息作为一个RecordBatch写入分区，所有同步副本确认后返回nil
+// 返回错误时这批消息可能已部分或全部写入，调用方重试会产生重复消息（至少一次）
+func (p *Producer) Produce(ctx context.Context, messages []Message) error {
+	if len(messages) == 0 {
+		return nil
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.conn == nil {
+		if err := p.connectLeader(ctx); err != nil {
+			return err
+		}
+	}
+	err := p.produce(ctx, messages)
+	if err != nil {
+		// 连接可能已失效或leader已切换，下次重新查询
+		p.closeConn()
+	}
+	return err
+}
+
+// Close 关闭与broker的连接
+func (p *Producer) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.closeConn()
+	return nil
+}
+
+// closeConn 关闭当前连接（调用方持有p.mu）
+func (p *Producer) closeConn() {
+	if p.conn != nil {
+		p.conn.Close()
+		p.conn = nil
+		p.rd = nil
+	}
+}
+
+// connectLeader 依次通过引导broker查询分区的leader并连接（调用方持有p.mu）
+func (p *Producer) connectLeader(ctx context.Context) error {
+	if len(p.cfg.Brokers) == 0 {
+		return fmt.Errorf("no kafka brokers configured")
+	}
+	var lastErr error
+	for _, broker := range p.cfg.Brokers {
+		if err := p.dial(ctx, broker); err != nil {
+			lastErr = err
+			continue
+		}
+		leader, err := p.lookupLeader(ctx)
+		if err != nil {
+			p.closeConn()
+			lastErr = err
+			continue
+		}
+		if leader == broker {
+			return nil
+		}
+		p.closeConn()
+		if err := p.dial(ctx, leader); err != nil {
+			lastErr = err
+			continue
+		}
+		return nil
+	}
+	return lastErr
+}
+
+// dial 连接一个broker（调用方持有p.mu）
+func (p *Producer) dial(ctx context.Context, addr string) error {
+	dialer := net.Dialer{Timeout: defaultDialTimeout}
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return fmt.Errorf("failed to connect to kafka broker %s: %w", addr, err)
+	}
+	p.conn = conn
+	p.rd = bufio.NewReader(conn)
+	return nil
+}
+
+// lookupLeader 通过Metadata请求查询分区leader的地址
+func (p *Producer) lookupLeader(ctx context.Context) (string, error) {
+	var body encoder
+	body.int32(1) // 只查询一个主题
+	body.string(p.cfg.Topic)
+
+	resp, err := p.roundTrip(ctx, apiMetadata, metadataVersion, body.buf)
+	if err != nil {
+		return "", err
+	}
+	d := decoder{buf: resp}
+
+	brokers := make(map[int32]string)
+	for n := d.int32(); n > 0; n-- {
+		id := d.int32()
+		host := d.string()
+		port := d.int32()
+		d.string() // rack
+		brokers[id] = net.JoinHostPort(host, strconv.Itoa(int(port)))
+	}
+	d.int32() // controller_id
+
+	leader := int32(-1)
+	for n := d.int32(); n > 0; n-- {
+		topicErr := d.int16()
+		name := d.string()
+		d.int8() // is_internal
+		for m := d.int32(); m > 0; m-- {
+			partErr := d.int16()
+			partition := d.int32()
+			partLeader := d.int32()
+			d.skipInt32Array() // replicas
+			d.skipInt32Array() // isr
+			if name == p.cfg.Topic && partition == p.cfg.Partition {
+				if partErr != 0 {
+					return "", &BrokerError{Code: partErr}
+				}
+				leader = partLeader
+			}
+		}
+		if name == p.cfg.Topic && topicErr != 0 {
+			return "", &BrokerError{Code: topicErr}
+		}
+	}
+	if d.err != nil {
+		return "", fmt.Errorf("failed to decode metadata response: %w", d.err)
+	}
+	addr, ok := brokers[leader]
+	if !ok {
+		return "", fmt.Errorf("%w: %s/%d", ErrNoLeader, p.cfg.Topic, p.cfg.Partition)
+	}
+	return addr, nil
+}
+
+// produce 发送Produce请求并检查分区的错误码
+func (p *Producer) produce(ctx context.Context, messages []Message) error {
+	batch := encodeRecordBatch(messages)
+
+	var body encoder
+	body.int16(-1) // transactional_id: null
+	body.int16(-1) // acks: all
+	body.int32(int32(p.cfg.Timeout / time.Millisecond))
+	body.int32(1)
+	body.string(p.cfg.Topic)
+	body.int32(1)
+	body.int32(p.cfg.Partition)
+	body.bytes(batch)
+
+	resp, err := p.roundTrip(ctx, apiProduce, produceVersion, body.buf)
+	if err != nil {
+		return err
+	}
+	d := decoder{buf: resp}
+	for n := d.int32(); n > 0; n-- {
+		d.string() // topic
+		for m := d.int32(); m > 0; m-- {
+			d.int32() // partition
+			code := d.int16()
+			d.int64() // base_offset
+			d.int64() // log_append_time
+			if code != 0 && d.err == nil {
+				return &BrokerError{Code: code}
+			}
+		}
+	}
+	if d.err != nil {
+		return fmt.Errorf("failed to decode produce response: %w", d.err)
+	}
+	return nil
+}
+
+// roundTrip 发送一个请求并读取对应的响应体（不含关联ID）
+func (p *Producer) roundTrip(ctx context.Context, apiKey, version int16, body []byte) ([]byte, error) {
+	p.correlation++
+	correlation := p.correlation
+
+	var req encoder
+	req.int32(0) // 长度，稍后填写
+	req.int16(apiKey)
+	req.int16(version)
+	req.int32(correlation)
+	req.string(p.cfg.ClientID)
+	req.buf = append(req.buf, body...)
+	binary.BigEndian.PutUint32(req.buf, uint32(len(req.buf)-4))
+
+	deadline := time.Now().Add(p.cfg.Timeout + defaultDialTimeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	conn := p.conn
+	conn.SetDeadline(deadline)
+	stop := context.AfterFunc(ctx, func() { conn.SetDeadline(time.Now()) })
+	defer stop()
+
+	if _, err := conn.Write(req.buf); err != nil {
+		return nil, fmt.Errorf("failed to send kafka request: %w", err)
+	}
+	var header [8]byte
+	if _, err := io.ReadFull(p.rd, header[:]); err != nil {
+		return nil, fmt.Errorf("failed to read kafka response: %w", err)
+	}
+	size := int32(binary.BigEndian.Uint32(header[:4]))
+	if size < 4 || size > maxResponseBytes {
+		return nil, fmt.Errorf("invalid kafka response size %d", size)
+	}
+	if got := int32(binary.BigEndian.Uint32(header[4:])); got != correlation {
+		return nil, fmt.Errorf("kafka response correlation id %d, expected %d", got, correlation)
+	}
+	resp := make([]byte, size-4)
+	if _, err := io.ReadFull(p.rd, resp); err != nil {
+		return nil, fmt.Errorf("failed to read kafka response: %w", err)
+	}
+	return resp, nil
+}
+
+// encodeRecordBatch 把消息编码为一个未压缩的 RecordBatch（magic 2）
+func encodeRecordBatch(messages []Message) []byte {
+	first := messages[0].Time
+	if first.IsZero() {
+		first = time.Now()
+	}
+	maxTime := first
+
+	var records encoder
+	for i, msg := range messages {
+		ts := msg.Time
+		if ts.IsZero() {
+			ts = first
+		}
+		if ts.After(maxTime) {
+			maxTime = ts
+		}
+		var rec encoder
+		rec.int8(0) // attributes
+		rec.varint(ts.Sub(first).Milliseconds())
+		rec.varint(int64(i))
+		rec.varbytes(msg.Key)
+		rec.varbytes(msg.Value)
+		rec.varint(0) // headers
+		records.varint(int64(len(rec.buf)))
+		records.buf = append(records.buf, rec.buf...)
+	}
+
+	// CRC覆盖 attributes 到结尾
+	var tail encoder
+	tail.int16(0) // attributes：不压缩、非事务
+	tail.int32(int32(len(messages) - 1))
+	tail.int64(first.UnixMilli())
+	tail.int64(maxTime.UnixMilli())
+	tail.int64(-1) // producer_id
+	tail.int16(-1) // producer_epoch
+	tail.int32(-1) // base_sequence
+	tail.int32(int32(len(messages)))
+	tail.buf = append(tail.buf, records.buf...)
+
+	var batch encoder
+	batch.int64(0)                                // base_offset，由broker分配
+	batch.int32(int32(4 + 1 + 4 + len(tail.buf))) // batch_length：之后的字节数
+	batch.int32(-1)                               // partition_leader_epoch
+	batch.int8(2)                                 // magic
+	batch.int32(int32(crc32.Checksum(tail.buf, castagnoli)))
+	batch.buf = append(batch.buf, tail.buf...)
+	return batch.buf
+}
+
+// encoder Kafka协议的大端编码
+type encoder struct {
+	buf []byte
+}
+
+func (e *encoder) int8(v int8)   { e.buf = append(e.buf, byte(v)) }
+func (e *encoder) int16(v int16) { e.buf = binary.BigEndian.AppendUint16(e.buf, uint16(v)) }
+func (e *encoder) int32(v int32) { e.buf = binary.BigEndian.AppendUint32(e.buf, uint32(v)) }
+func (e *encoder) int64(v int64) { e.buf = binary.BigEndian.AppendUint64(e.buf, uint64(v)) }
+
+// string 长度（int16）加内容
+func (e *encoder) string(s string) {
+	e.int16(int16(len(s)))
+	e.buf = append(e.buf, s...)
+}
+
+// bytes 长度（int32）加内容
+func (e *encoder) bytes(b []byte) {
+	e.int32(int32(len(b)))
+	e.buf = append(e.buf, b...)
+}
+
+// varint zigzag编码的变长整数（记录内部使用）
+func (e *encoder) varint(v int64) { e.buf = binary.AppendVarint(e.buf, v) }
+
+// varbytes 变长长度加内容，nil编码为-1
+func (e *encoder) varbytes(b []byte) {
+	if b == nil {
+		e.varint(-1)
+		return
+	}
+	e.varint(int64(len(b)))
+	e.buf = append(e.buf, b...)
+}
+
+// decoder Kafka协议的大端解码，出错后的读取都返回零值，由调用方最后检查err
+type decoder struct {
+	buf []byte
+	err error
+}
+
+// take 读取n个字节
+func (d *decoder) take(n int) []byte {
+	if d.err != nil {
+		return nil
+	}
+	if n < 0 || n > len(d.buf) {
+		d.err = io.ErrUnexpectedEOF
+		return nil
+	}
+	b := d.buf[:n]
+	d.buf = d.buf[n:]
+	return b
+}
+
+func (d *decoder) int8() int8 {
+	if b := d.take(1); b != nil {
+		return int8(b[0])
+	}
+	return 0
+}
+
+func (d *decoder) int16() int16 {
+	if b := d.take(2); b != nil {
+		return int16(binary.BigEndian.Uint16(b))
+	}
+	return 0
+}
+
+func (d *decoder) int32() int32 {
+	if b := d.take(4); b != nil {
+		return int32(binary.BigEndian.Uint32(b))
+	}
+	return 0
+}
+
+func (d *decoder) int64() int64 {
+	if b := d.take(8); b != nil {
+		return int64(binary.BigEndian.Uint64(b))
+	}
+	return 0
+}
+
+// string 可以为null（长度-1）的字符串
+func (d *decoder) string() string {
+	n := d.int16()
+	if n < 0 {
+		return ""
+	}
+	return string(d.take(int(n)))
+}
+
+// skipInt32Array 跳过一个int32数组
+func (d *decoder) skipInt32Array() {
+	if n := d.int32(); n > 0 {
+		d.take(int(n) * 4)
+	}
+}
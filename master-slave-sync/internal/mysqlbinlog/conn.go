@@ -0,0 +1,428 @@
+package mysqlbinlog
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/binary"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"time"
+)
+
+// 客户端能力标志（只使用需要的部分）
+const (
+	clientLongPassword     = 0x00000001
+	clientLongFlag         = 0x00000004
+	clientProtocol41       = 0x00000200
+	clientTransactions     = 0x00002000
+	clientSecureConnection = 0x00008000
+	clientPluginAuth       = 0x00080000
+)
+
+// 命令
+const (
+	comQuery         = 0x03
+	comBinlogDump    = 0x12
+	comRegisterSlave = 0x15
+)
+
+// 响应包的首字节
+const (
+	packetOK  = 0x00
+	packetEOF = 0xfe
+	packetErr = 0xff
+)
+
+// 认证插件
+const (
+	nativePassword     = "mysql_native_password"
+	cachingSHA2        = "caching_sha2_password"
+	maxPacketSize      = 1<<24 - 1
+	defaultDialTimeout = 5 * time.Second
+	charsetUTF8MB4     = 45 // utf8mb4_general_ci
+)
+
+// ErrMalformedPacket 服务器返回的数据包格式不正确
+var ErrMalformedPacket = errors.New("malformed mysql packet")
+
+// ServerError 服务器返回的错误包
+type ServerError struct {
+	Code    uint16
+	State   string
+	Message string
+}
+
+// Error 实现 error
+func (e *ServerError) Error() string {
+	return fmt.Sprintf("mysql error %d (%s): %s", e.Code, e.State, e.Message)
+}
+
+// Config 连接配置
+type Config struct {
+	Addr     string        // MySQL服务器地址（host:port）
+	User     string        // 需要 REPLICATION SLAVE 和 REPLICATION CLIENT 权限
+	Password string        // 密码
+	ServerID uint32        // 以副本身份注册时使用的服务器ID，不能与MySQL服务器及其他副本重复
+	Timeout  time.Duration // 连接、握手和查询的超时，0表示默认5秒
+}
+
+// Conn 一个MySQL协议连接，只支持读取binlog需要的命令
+type Conn struct {
+	conn    net.Conn
+	br      *bufio.Reader
+	seq     byte
+	timeout time.Duration
+}
+
+// Dial 连接MySQL服务器并完成认证（支持 mysql_native_password 和 caching_sha2_password）
+func Dial(cfg Config) (*Conn, error) {
+	timeout := cfg.Timeout
+	if timeout <= 0 {
+		timeout = defaultDialTimeout
+	}
+	nc, err := net.DialTimeout("tcp", cfg.Addr, timeout)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to mysql at %s: %w", cfg.Addr, err)
+	}
+	c := &Conn{conn: nc, br: bufio.NewReaderSize(nc, 64<<10), timeout: timeout}
+	nc.SetDeadline(time.Now().Add(timeout))
+	if err := c.handshake(cfg); err != nil {
+		nc.Close()
+		return nil, err
+	}
+	nc.SetDeadline(time.Time{})
+	return c, nil
+}
+
+// Close 关闭连接
+func (c *Conn) Close() error {
+	return c.conn.Close()
+}
+
+// readPacket 读取一个完整的数据包（合并超过16MB被拆分的包）
+func (c *Conn) readPacket() ([]byte, error) {
+	var payload []byte
+	for {
+		var header [4]byte
+		if _, err := io.ReadFull(c.br, header[:]); err != nil {
+			return nil, err
+		}
+		length := int(uint32(header[0]) | uint32(header[1])<<8 | uint32(header[2])<<16)
+		c.seq = header[3] + 1
+		chunk := make([]byte, length)
+		if _, err := io.ReadFull(c.br, chunk); err != nil {
+			return nil, err
+		}
+		if payload == nil && length < maxPacketSize {
+			return chunk, nil
+		}
+		payload = append(payload, chunk...)
+		if length < maxPacketSize {
+			return payload, nil
+		}
+	}
+}
+
+// writePacket 发送一个数据包
+func (c *Conn) writePacket(payload []byte) error {
+	for {
+		n := min(len(payload), maxPacketSize)
+		buf := make([]byte, 4+n)
+		buf[0], buf[1], buf[2] = byte(n), byte(n>>8), byte(n>>16)
+		buf[3] = c.seq
+		copy(buf[4:], payload[:n])
+		if _, err := c.conn.Write(buf); err != nil {
+			return err
+		}
+		c.seq++
+		payload = payload[n:]
+		if n < maxPacketSize {
+			return nil
+		}
+	}
+}
+
+// writeCommand 发送一个命令（每个命令的序号从0开始）
+func (c *Conn) writeCommand(payload []byte) error {
+	c.seq = 0
+	return c.writePacket(payload)
+}
+
+// handshake 读取服务器的握手包，发送认证信息并处理认证插件切换
+func (c *Conn) handshake(cfg Config) error {
+	data, err := c.readPacket()
+	if err != nil {
+		return fmt.Errorf("failed to read mysql handshake: %w", err)
+	}
+	if len(data) > 0 && data[0] == packetErr {
+		return parseError(data)
+	}
+	if len(data) < 1 || data[0] != 10 {
+		return fmt.Errorf("%w: unsupported handshake protocol", ErrMalformedPacket)
+	}
+	r := &reader{data: data[1:]}
+	r.nullString() // 服务器版本
+	r.skip(4)      // 连接ID
+	scramble := append([]byte(nil), r.bytes(8)...)
+	r.skip(1)
+	capabilities := uint32(r.uint16())
+	plugin := nativePassword
+	if r.remaining() > 0 {
+		r.skip(1 + 2) // 字符集和状态
+		capabilities |= uint32(r.uint16()) << 16
+		authLen := int(r.uint8())
+		r.skip(10)
+		if capabilities&clientSecureConnection != 0 {
+			n := max(13, authLen-8)
+			part := r.bytes(n)
+			scramble = append(scramble, bytes.TrimRight(part, "\x00")...)
+		}
+		if capabilities&clientPluginAuth != 0 && r.remaining() > 0 {
+			plugin = r.nullString()
+		}
+	}
+	if r.err != nil {
+		return fmt.Errorf("%w: handshake: %v", ErrMalformedPacket, r.err)
+	}
+	if capabilities&clientProtocol41 == 0 {
+		return fmt.Errorf("mysql server does not support protocol 4.1")
+	}
+
+	auth, err := scrambleFor(plugin, cfg.Password, scramble)
+	if err != nil {
+		return err
+	}
+	flags := uint32(clientLongPassword | clientLongFlag | clientProtocol41 | clientTransactions | clientSecureConnection | clientPluginAuth)
+	var resp []byte
+	resp = binary.LittleEndian.AppendUint32(resp, flags)
+	resp = binary.LittleEndian.AppendUint32(resp, maxPacketSize)
+	resp = append(resp, charsetUTF8MB4)
+	resp = append(resp, make([]byte, 23)...)
+	resp = append(resp, cfg.User...)
+	resp = append(resp, 0, byte(len(auth)))
+	resp = append(resp, auth...)
+	resp = append(resp, plugin...)
+	resp = append(resp, 0)
+	if err := c.writePacket(resp); err != nil {
+		return fmt.Errorf("failed to send mysql handshake response: %w", err)
+	}
+	return c.authResult(cfg.Password, plugin, scramble)
+}
+
+// authResult 处理认证结果：成功、失败、切换认证插件或 caching_sha2_password 的后续交互
+func (c *Conn) authResult(password, plugin string, scramble []byte) error {
+	for {
+		data, err := c.readPacket()
+		if err != nil {
+			return fmt.Errorf("failed to read mysql auth result: %w", err)
+		}
+		if len(data) == 0 {
+			return ErrMalformedPacket
+		}
+		switch data[0] {
+		case packetOK:
+			return nil
+		case packetErr:
+			return parseError(data)
+		case packetEOF:
+			// 切换认证插件：插件名 + 新的随机数
+			r := &reader{data: data[1:]}
+			plugin = r.nullString()
+			scramble = bytes.TrimRight(r.rest(), "\x00")
+			auth, err := scrambleFor(plugin, password, scramble)
+			if err != nil {
+				return err
+			}
+			if err := c.writePacket(auth); err != nil {
+				return err
+			}
+		case 0x01:
+			if plugin != cachingSHA2 || len(data) < 2 {
+				return fmt.Errorf("%w: unexpected auth data for %s", ErrMalformedPacket, plugin)
+			}
+			switch data[1] {
+			case 3: // 快速认证成功，之后是OK包
+				continue
+			case 4: // 需要完整认证：没有TLS时先请求服务器的RSA公钥，再发送加密的密码
+				if err := c.writePacket([]byte{2}); err != nil {
+					return err
+				}
+				key, err := c.readPacket()
+				if err != nil {
+					return fmt.Errorf("failed to read mysql public key: %w", err)
+				}
+				if len(key) == 0 || key[0] != 0x01 {
+					return fmt.Errorf("%w: expected public key", ErrMalformedPacket)
+				}
+				enc, err := encryptPassword(password, scramble, key[1:])
+				if err != nil {
+					return err
+				}
+				if err := c.writePacket(enc); err != nil {
+					return err
+				}
+			default:
+				return fmt.Errorf("%w: unknown caching_sha2_password state %d", ErrMalformedPacket, data[1])
+			}
+		default:
+			return fmt.Errorf("%w: unexpected auth response 0x%02x", ErrMalformedPacket, data[0])
+		}
+	}
+}
+
+// scrambleFor 按认证插件计算认证数据，密码为空时为空
+func scrambleFor(plugin, password string, scramble []byte) ([]byte, error) {
+	if password == "" {
+		return nil, nil
+	}
+	switch plugin {
+	case nativePassword:
+		// SHA1(password) XOR SHA1(scramble + SHA1(SHA1(password)))
+		h1 := sha1.Sum([]byte(password))
+		h2 := sha1.Sum(h1[:])
+		h := sha1.New()
+		h.Write(scramble[:min(len(scramble), 20)])
+		h.Write(h2[:])
+		out := h.Sum(nil)
+		for i := range out {
+			out[i] ^= h1[i]
+		}
+		return out, nil
+	case cachingSHA2:
+		// SHA256(password) XOR SHA256(SHA256(SHA256(password)) + scramble)
+		h1 := sha256.Sum256([]byte(password))
+		h2 := sha256.Sum256(h1[:])
+		h := sha256.New()
+		h.Write(h2[:])
+		h.Write(scramble)
+		out := h.Sum(nil)
+		for i := range out {
+			out[i] ^= h1[i]
+		}
+		return out, nil
+	default:
+		return nil, fmt.Errorf("unsupported mysql auth plugin %q", plugin)
+	}
+}
+
+// encryptPassword 用服务器的RSA公钥加密密码（caching_sha2_password 在非TLS连接上的完整认证）
+func encryptPassword(password string, scramble, pemKey []byte) ([]byte, error) {
+	block, _ := pem.Decode(pemKey)
+	if block == nil {
+		return nil, fmt.Errorf("%w: invalid public key", ErrMalformedPacket)
+	}
+	parsed, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse mysql public key: %w", err)
+	}
+	pub, ok := parsed.(*rsa.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("mysql public key is not an RSA key")
+	}
+	plain := append([]byte(password), 0)
+	for i := range plain {
+		plain[i] ^= scramble[i%len(scramble)]
+	}
+	return rsa.EncryptOAEP(sha1.New(), rand.Reader, pub, plain, nil)
+}
+
+// Exec 执行一条不返回结果集的语句
+func (c *Conn) Exec(query string) error {
+	rows, err := c.Query(query)
+	if err != nil {
+		return err
+	}
+	if rows != nil {
+		return fmt.Errorf("statement %q returned a result set", query)
+	}
+	return nil
+}
+
+// QueryRow 执行查询并返回第一行（文本协议，NULL为空字符串），没有结果行时返回nil
+func (c *Conn) QueryRow(query string) ([]string, error) {
+	rows, err := c.Query(query)
+	if err != nil || len(rows) == 0 {
+		return nil, err
+	}
+	return rows[0], nil
+}
+
+// Query 执行查询并返回所有结果行，语句不返回结果集时返回nil
+func (c *Conn) Query(query string) ([][]string, error) {
+	c.conn.SetDeadline(time.Now().Add(c.timeout))
+	defer c.conn.SetDeadline(time.Time{})
+
+	if err := c.writeCommand(append([]byte{comQuery}, query...)); err != nil {
+		return nil, err
+	}
+	data, err := c.readPacket()
+	if err != nil {
+		return nil, err
+	}
+	if len(data) == 0 {
+		return nil, ErrMalformedPacket
+	}
+	switch data[0] {
+	case packetOK:
+		return nil, nil
+	case packetErr:
+		return nil, parseError(data)
+	}
+
+	r := &reader{data: data}
+	columns := int(r.lenenc())
+	for i := 0; i < columns; i++ {
+		if _, err := c.readPacket(); err != nil {
+			return nil, err
+		}
+	}
+	if _, err := c.readPacket(); err != nil { // 列定义之后的EOF
+		return nil, err
+	}
+	rows := [][]string{}
+	for {
+		data, err := c.readPacket()
+		if err != nil {
+			return nil, err
+		}
+		if len(data) > 0 && data[0] == packetErr {
+			return nil, parseError(data)
+		}
+		if len(data) < 9 && len(data) > 0 && data[0] == packetEOF {
+			return rows, nil
+		}
+		r := &reader{data: data}
+		row := make([]string, columns)
+		for i := range row {
+			if r.remaining() > 0 && r.data[r.pos] == 0xfb {
+				r.skip(1)
+				continue
+			}
+			row[i] = string(r.bytes(int(r.lenenc())))
+		}
+		if r.err != nil {
+			return nil, fmt.Errorf("%w: result row: %v", ErrMalformedPacket, r.err)
+		}
+		rows = append(rows, row)
+	}
+}
+
+// parseError 解析错误包
+func parseError(data []byte) error {
+	r := &reader{data: data[1:]}
+	e := &ServerError{Code: r.uint16()}
+	if r.remaining() > 0 && r.data[r.pos] == '#' {
+		r.skip(1)
+		e.State = string(r.bytes(5))
+	}
+	e.Message = string(r.rest())
+	return e
+}
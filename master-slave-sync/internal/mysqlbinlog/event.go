@@ -0,0 +1,301 @@
+package mysqlbinlog
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"strings"
+	"time"
+)
+
+// 处理的事件类型，其余事件（GTID、心跳等）只用于推进位置
+const (
+	queryEvent             = 2
+	rotateEvent            = 4
+	formatDescriptionEvent = 15
+	xidEvent               = 16
+	tableMapEvent          = 19
+	writeRowsEventV1       = 23
+	updateRowsEventV1      = 24
+	deleteRowsEventV1      = 25
+	heartbeatEvent         = 27
+	writeRowsEventV2       = 30
+	updateRowsEventV2      = 31
+	deleteRowsEventV2      = 32
+)
+
+// 事件头长度和校验和长度
+const (
+	eventHeaderSize = 19
+	checksumSize    = 4
+)
+
+// 行事件的类型
+const (
+	RowsInsert = "INSERT"
+	RowsUpdate = "UPDATE"
+	RowsDelete = "DELETE"
+)
+
+// ErrChecksum 事件的CRC32校验和不匹配
+var ErrChecksum = errors.New("binlog event checksum mismatch")
+
+// Position binlog文件中的位置
+type Position struct {
+	File string `json:"file"`
+	Pos  uint32 `json:"pos"`
+}
+
+// String 格式化为 file:pos
+func (p Position) String() string {
+	return fmt.Sprintf("%s:%d", p.File, p.Pos)
+}
+
+// Compare 比较两个位置：文件名按序号比较（mysql-bin.000010 在 mysql-bin.000009 之后），同一文件按偏移比较
+func (p Position) Compare(o Position) int {
+	if p.File != o.File {
+		if len(p.File) != len(o.File) {
+			if len(p.File) < len(o.File) {
+				return -1
+			}
+			return 1
+		}
+		return strings.Compare(p.File, o.File)
+	}
+	switch {
+	case p.Pos < o.Pos:
+		return -1
+	case p.Pos > o.Pos:
+		return 1
+	}
+	return 0
+}
+
+// Event 一个解码后的binlog事件，按类型只设置一个字段；不关心的事件所有字段都为空
+type Event struct {
+	Type      byte      // 事件类型
+	ServerID  uint32    // 产生事件的服务器ID
+	Timestamp time.Time // 事件的时间
+	Position  Position  // 事件之后的位置，从这里重新开始读取不会重复该事件
+	Query     *QueryEvent
+	Rows      *RowsEvent
+	XID       uint64 // 事务提交（XID事件）时非0
+}
+
+// QueryEvent 语句事件：事务的 BEGIN/COMMIT 和DDL
+type QueryEvent struct {
+	Schema string // 执行语句时的默认库
+	SQL    string
+}
+
+// RowsEvent 行事件：一个表在一条语句中插入、更新或删除的行
+type RowsEvent struct {
+	Table  *TableMap
+	Action string // RowsInsert、RowsUpdate 或 RowsDelete
+	// Rows 每行按列的顺序排列的值，NULL 和没有记录的列为nil；更新时每两行为一组（更新前、更新后）
+	Rows [][]interface{}
+}
+
+// Streamer 读取 COM_BINLOG_DUMP 返回的事件流
+type Streamer struct {
+	conn     *Conn
+	checksum bool                 // 事件末尾是否带CRC32校验和
+	tables   map[uint64]*TableMap // 表ID -> 最近的表映射
+	position Position
+	timeout  time.Duration // 读取一个事件的超时，0表示不超时
+}
+
+// Dump 以副本身份注册并从指定位置开始读取binlog
+// 读取前把连接的 @master_binlog_checksum 设置为服务器的设置，事件按服务器写入时的校验和算法发送；
+// heartbeat 大于0时要求服务器空闲时按该间隔发送心跳，读取超过3个心跳间隔没有事件时返回超时错误
+func (c *Conn) Dump(serverID uint32, from Position, heartbeat time.Duration) (*Streamer, error) {
+	checksum := false
+	if row, err := c.QueryRow("SELECT @@global.binlog_checksum"); err == nil && len(row) > 0 {
+		checksum = strings.EqualFold(row[0], "CRC32")
+		if err := c.Exec("SET @master_binlog_checksum = @@global.binlog_checksum"); err != nil {
+			return nil, fmt.Errorf("failed to set binlog checksum: %w", err)
+		}
+	}
+	if heartbeat > 0 {
+		if err := c.Exec(fmt.Sprintf("SET @master_heartbeat_period = %d", heartbeat.Nanoseconds())); err != nil {
+			return nil, fmt.Errorf("failed to set heartbeat period: %w", err)
+		}
+	}
+
+	// COM_REGISTER_SLAVE：服务器ID、主机名、用户、密码（均为空）、端口、复制等级、主节点ID
+	register := []byte{comRegisterSlave}
+	register = binary.LittleEndian.AppendUint32(register, serverID)
+	register = append(register, 0, 0, 0)
+	register = binary.LittleEndian.AppendUint16(register, 0)
+	register = binary.LittleEndian.AppendUint32(register, 0)
+	register = binary.LittleEndian.AppendUint32(register, 0)
+	if err := c.simpleCommand(register); err != nil {
+		return nil, fmt.Errorf("failed to register as replica: %w", err)
+	}
+
+	// COM_BINLOG_DUMP：位置、标志、服务器ID、文件名
+	dump := []byte{comBinlogDump}
+	dump = binary.LittleEndian.AppendUint32(dump, from.Pos)
+	dump = binary.LittleEndian.AppendUint16(dump, 0)
+	dump = binary.LittleEndian.AppendUint32(dump, serverID)
+	dump = append(dump, from.File...)
+	if err := c.writeCommand(dump); err != nil {
+		return nil, fmt.Errorf("failed to request binlog dump: %w", err)
+	}
+	return &Streamer{
+		conn:     c,
+		checksum: checksum,
+		tables:   make(map[uint64]*TableMap),
+		position: from,
+		timeout:  3 * heartbeat,
+	}, nil
+}
+
+// simpleCommand 发送命令并等待OK包
+func (c *Conn) simpleCommand(payload []byte) error {
+	c.conn.SetDeadline(time.Now().Add(c.timeout))
+	defer c.conn.SetDeadline(time.Time{})
+	if err := c.writeCommand(payload); err != nil {
+		return err
+	}
+	data, err := c.readPacket()
+	if err != nil {
+		return err
+	}
+	if len(data) > 0 && data[0] == packetErr {
+		return parseError(data)
+	}
+	return nil
+}
+
+// Position 已读取的最后一个事件之后的位置
+func (s *Streamer) Position() Position {
+	return s.position
+}
+
+// Next 读取下一个事件；表映射事件只用于解码之后的行事件，不返回
+func (s *Streamer) Next() (*Event, error) {
+	for {
+		if s.timeout > 0 {
+			s.conn.conn.SetReadDeadline(time.Now().Add(s.timeout))
+		}
+		data, err := s.conn.readPacket()
+		if err != nil {
+			return nil, err
+		}
+		if len(data) == 0 {
+			return nil, ErrMalformedPacket
+		}
+		switch data[0] {
+		case packetErr:
+			return nil, parseError(data)
+		case packetEOF:
+			if len(data) < 9 {
+				return nil, io.EOF
+			}
+		}
+		event, err := s.decode(data[1:])
+		if err != nil {
+			return nil, err
+		}
+		if event != nil {
+			return event, nil
+		}
+	}
+}
+
+// decode 解码一个事件，不需要返回的事件返回nil
+func (s *Streamer) decode(data []byte) (*Event, error) {
+	if len(data) < eventHeaderSize {
+		return nil, fmt.Errorf("%w: event shorter than its header", ErrMalformedPacket)
+	}
+	r := &reader{data: data}
+	timestamp := r.uint32()
+	eventType := r.uint8()
+	serverID := r.uint32()
+	size := r.uint32()
+	logPos := r.uint32()
+	r.skip(2) // 标志
+	if int(size) != len(data) {
+		return nil, fmt.Errorf("%w: event size %d, got %d bytes", ErrMalformedPacket, size, len(data))
+	}
+
+	body := data[eventHeaderSize:]
+	// 开启校验和时每个事件（包括服务器开始时发送的伪造事件）末尾都有CRC32校验和
+	if s.checksum {
+		if len(body) < checksumSize {
+			return nil, fmt.Errorf("%w: event shorter than its checksum", ErrMalformedPacket)
+		}
+		n := len(data) - checksumSize
+		if crc32.ChecksumIEEE(data[:n]) != binary.LittleEndian.Uint32(data[n:]) {
+			return nil, fmt.Errorf("%w at %s", ErrChecksum, s.position)
+		}
+		body = body[:len(body)-checksumSize]
+	}
+
+	// 服务器在开始时发送的伪造事件（如初始的ROTATE）位置为0，不推进位置
+	if logPos > 0 && eventType != heartbeatEvent {
+		s.position.Pos = logPos
+	}
+	event := &Event{
+		Type:      eventType,
+		ServerID:  serverID,
+		Timestamp: time.Unix(int64(timestamp), 0),
+	}
+
+	var err error
+	switch eventType {
+	case rotateEvent:
+		b := &reader{data: body}
+		pos := b.uint64()
+		s.position = Position{File: string(b.rest()), Pos: uint32(pos)}
+		err = b.err
+	case queryEvent:
+		event.Query, err = decodeQuery(body)
+	case xidEvent:
+		b := &reader{data: body}
+		event.XID = b.uint64()
+		if event.XID == 0 {
+			event.XID = 1 // 只用于标记事务提交
+		}
+		err = b.err
+	case tableMapEvent:
+		var table *TableMap
+		if table, err = decodeTableMap(body); err == nil {
+			s.tables[table.ID] = table
+		}
+		return nil, wrapDecode(eventType, err)
+	case writeRowsEventV1, updateRowsEventV1, deleteRowsEventV1,
+		writeRowsEventV2, updateRowsEventV2, deleteRowsEventV2:
+		event.Rows, err = s.decodeRows(eventType, body)
+	}
+	event.Position = s.position
+	return event, wrapDecode(eventType, err)
+}
+
+// wrapDecode 为解码错误加上事件类型
+func wrapDecode(eventType byte, err error) error {
+	if err == nil {
+		return nil
+	}
+	return fmt.Errorf("failed to decode binlog event type %d: %w", eventType, err)
+}
+
+// decodeQuery 解码语句事件
+func decodeQuery(body []byte) (*QueryEvent, error) {
+	r := &reader{data: body}
+	r.skip(4 + 4) // 线程ID、执行时间
+	schemaLen := int(r.uint8())
+	r.skip(2) // 错误码
+	statusLen := int(r.uint16())
+	r.skip(statusLen)
+	schema := string(r.bytes(schemaLen))
+	r.skip(1)
+	sql := string(r.rest())
+	if r.err != nil {
+		return nil, r.err
+	}
+	return &QueryEvent{Schema: schema, SQL: sql}, nil
+}
@@ -0,0 +1,136 @@
+package mysqlbinlog
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+)
+
+// errShort 数据不足
+var errShort = errors.New("unexpected end of data")
+
+// reader 按小端序读取数据包和事件的内容，越界后所有读取返回零值并记录错误
+type reader struct {
+	data []byte
+	pos  int
+	err  error
+}
+
+// remaining 剩余的字节数
+func (r *reader) remaining() int {
+	return len(r.data) - r.pos
+}
+
+// bytes 读取n个字节（返回的切片引用原数据）
+func (r *reader) bytes(n int) []byte {
+	if r.err != nil || n < 0 || r.remaining() < n {
+		r.err = errShort
+		return nil
+	}
+	b := r.data[r.pos : r.pos+n]
+	r.pos += n
+	return b
+}
+
+// skip 跳过n个字节
+func (r *reader) skip(n int) {
+	r.bytes(n)
+}
+
+// rest 读取剩余的所有字节
+func (r *reader) rest() []byte {
+	return r.bytes(r.remaining())
+}
+
+// uint8 读取1字节
+func (r *reader) uint8() uint8 {
+	b := r.bytes(1)
+	if b == nil {
+		return 0
+	}
+	return b[0]
+}
+
+// uint16 读取2字节小端整数
+func (r *reader) uint16() uint16 {
+	b := r.bytes(2)
+	if b == nil {
+		return 0
+	}
+	return binary.LittleEndian.Uint16(b)
+}
+
+// uint32 读取4字节小端整数
+func (r *reader) uint32() uint32 {
+	b := r.bytes(4)
+	if b == nil {
+		return 0
+	}
+	return binary.LittleEndian.Uint32(b)
+}
+
+// uint64 读取8字节小端整数
+func (r *reader) uint64() uint64 {
+	b := r.bytes(8)
+	if b == nil {
+		return 0
+	}
+	return binary.LittleEndian.Uint64(b)
+}
+
+// uintN 读取n字节（最多8字节）小端整数
+func (r *reader) uintN(n int) uint64 {
+	return littleEndian(r.bytes(n))
+}
+
+// lenenc 读取长度编码的整数
+func (r *reader) lenenc() uint64 {
+	switch first := r.uint8(); first {
+	case 0xfc:
+		return r.uintN(2)
+	case 0xfd:
+		return r.uintN(3)
+	case 0xfe:
+		return r.uint64()
+	default:
+		return uint64(first)
+	}
+}
+
+// lenencString 读取长度编码的字符串
+func (r *reader) lenencString() string {
+	return string(r.bytes(int(r.lenenc())))
+}
+
+// nullString 读取以0结尾的字符串
+func (r *reader) nullString() string {
+	if r.err != nil {
+		return ""
+	}
+	end := bytes.IndexByte(r.data[r.pos:], 0)
+	if end < 0 {
+		r.err = errShort
+		return ""
+	}
+	s := string(r.data[r.pos : r.pos+end])
+	r.pos += end + 1
+	return s
+}
+
+// littleEndian 小端序的无符号整数
+func littleEndian(b []byte) uint64 {
+	var v uint64
+	for i := len(b) - 1; i >= 0; i-- {
+		v = v<<8 | uint64(b[i])
+	}
+	return v
+}
+
+// bigEndian 大端序的无符号整数
+func bigEndian(b []byte) uint64 {
+	var v uint64
+	for _, c := range b {
+		v = v<<8 | uint64(c)
+	}
+	return v
+}
@@ -0,0 +1,459 @@
+package mysqlbinlog
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 列类型
+const (
+	typeDecimal    = 0
+	typeTiny       = 1
+	typeShort      = 2
+	typeLong       = 3
+	typeFloat      = 4
+	typeDouble     = 5
+	typeNull       = 6
+	typeTimestamp  = 7
+	typeLongLong   = 8
+	typeInt24      = 9
+	typeDate       = 10
+	typeTime       = 11
+	typeDateTime   = 12
+	typeYear       = 13
+	typeVarchar    = 15
+	typeBit        = 16
+	typeTimestamp2 = 17
+	typeDateTime2  = 18
+	typeTime2      = 19
+	typeJSON       = 245
+	typeNewDecimal = 246
+	typeEnum       = 247
+	typeSet        = 248
+	typeTinyBlob   = 249
+	typeMediumBlob = 250
+	typeLongBlob   = 251
+	typeBlob       = 252
+	typeVarString  = 253
+	typeString     = 254
+	typeGeometry   = 255
+)
+
+// 表映射事件中可选元数据的类型（MySQL 8.0 binlog_row_metadata）
+const (
+	metaSignedness = 1
+	metaColumnName = 4
+)
+
+// TableMap 表映射事件：之后的行事件按表ID引用它来解码列值
+type TableMap struct {
+	ID       uint64
+	Schema   string
+	Table    string
+	Types    []byte
+	Meta     []uint16
+	Unsigned []bool   // 数值列是否无符号，服务器没有发送可选元数据时全部按有符号处理
+	Columns  []string // 列名，只在 binlog_row_metadata=FULL 时由服务器发送，否则为nil
+}
+
+// decodeTableMap 解码表映射事件
+func decodeTableMap(body []byte) (*TableMap, error) {
+	r := &reader{data: body}
+	t := &TableMap{ID: r.uintN(6)}
+	r.skip(2) // 标志
+	t.Schema = string(r.bytes(int(r.uint8())))
+	r.skip(1)
+	t.Table = string(r.bytes(int(r.uint8())))
+	r.skip(1)
+	count := int(r.lenenc())
+	t.Types = append([]byte(nil), r.bytes(count)...)
+	meta := &reader{data: r.bytes(int(r.lenenc()))}
+	r.skip((count + 7) / 8) // 可为NULL的列
+	if r.err != nil {
+		return nil, r.err
+	}
+
+	t.Meta = make([]uint16, count)
+	for i, tp := range t.Types {
+		switch tp {
+		case typeFloat, typeDouble, typeBlob, typeGeometry, typeJSON,
+			typeTimestamp2, typeDateTime2, typeTime2:
+			t.Meta[i] = uint16(meta.uint8())
+		case typeVarchar, typeVarString, typeBit:
+			t.Meta[i] = meta.uint16()
+		case typeNewDecimal, typeString, typeEnum, typeSet:
+			// 精度和小数位（或实际类型和长度），按大端序存放
+			b := meta.bytes(2)
+			if b != nil {
+				t.Meta[i] = uint16(b[0])<<8 | uint16(b[1])
+			}
+		}
+	}
+	if meta.err != nil {
+		return nil, fmt.Errorf("table map metadata: %w", meta.err)
+	}
+
+	t.Unsigned = make([]bool, count)
+	for r.remaining() > 0 {
+		kind := r.uint8()
+		value := &reader{data: r.bytes(int(r.lenenc()))}
+		switch kind {
+		case metaSignedness:
+			// 按顺序为每个数值列占一位，最高位在前
+			bits := value.rest()
+			n := 0
+			for i, tp := range t.Types {
+				if !isNumeric(tp) {
+					continue
+				}
+				if n/8 < len(bits) && bits[n/8]&(0x80>>(n%8)) != 0 {
+					t.Unsigned[i] = true
+				}
+				n++
+			}
+		case metaColumnName:
+			for value.remaining() > 0 {
+				t.Columns = append(t.Columns, value.lenencString())
+			}
+		}
+	}
+	if r.err != nil {
+		return nil, fmt.Errorf("table map optional metadata: %w", r.err)
+	}
+	return t, nil
+}
+
+// isNumeric 可选元数据的符号位覆盖的列类型
+func isNumeric(tp byte) bool {
+	switch tp {
+	case typeTiny, typeShort, typeInt24, typeLong, typeLongLong,
+		typeFloat, typeDouble, typeNewDecimal, typeDecimal:
+		return true
+	}
+	return false
+}
+
+// decodeRows 解码行事件
+func (s *Streamer) decodeRows(eventType byte, body []byte) (*RowsEvent, error) {
+	r := &reader{data: body}
+	tableID := r.uintN(6)
+	r.skip(2) // 标志
+	if eventType >= writeRowsEventV2 {
+		extra := int(r.uint16())
+		r.skip(extra - 2)
+	}
+	table, ok := s.tables[tableID]
+	if !ok {
+		return nil, fmt.Errorf("rows event for unknown table id %d", tableID)
+	}
+	count := int(r.lenenc())
+	if count != len(table.Types) {
+		return nil, fmt.Errorf("rows event has %d columns, table map for %s.%s has %d", count, table.Schema, table.Table, len(table.Types))
+	}
+
+	event := &RowsEvent{Table: table}
+	present := r.bytes((count + 7) / 8)
+	presentAfter := present
+	switch eventType {
+	case writeRowsEventV1, writeRowsEventV2:
+		event.Action = RowsInsert
+	case deleteRowsEventV1, deleteRowsEventV2:
+		event.Action = RowsDelete
+	default:
+		event.Action = RowsUpdate
+		presentAfter = r.bytes((count + 7) / 8)
+	}
+	if r.err != nil {
+		return nil, r.err
+	}
+
+	for r.remaining() > 0 {
+		row, err := decodeRow(r, table, present)
+		if err != nil {
+			return nil, err
+		}
+		event.Rows = append(event.Rows, row)
+		if event.Action == RowsUpdate {
+			if row, err = decodeRow(r, table, presentAfter); err != nil {
+				return nil, err
+			}
+			event.Rows = append(event.Rows, row)
+		}
+	}
+	return event, nil
+}
+
+// bitSet 位图中第i位是否为1（最低位在前）
+func bitSet(bitmap []byte, i int) bool {
+	return bitmap[i/8]&(1<<(i%8)) != 0
+}
+
+// decodeRow 解码一行：先是出现的列的NULL位图，然后是出现且不为NULL的列的值
+func decodeRow(r *reader, table *TableMap, present []byte) ([]interface{}, error) {
+	columns := 0
+	for i := range table.Types {
+		if bitSet(present, i) {
+			columns++
+		}
+	}
+	nulls := r.bytes((columns + 7) / 8)
+	if r.err != nil {
+		return nil, r.err
+	}
+
+	row := make([]interface{}, len(table.Types))
+	n := 0
+	for i, tp := range table.Types {
+		if !bitSet(present, i) {
+			continue
+		}
+		isNull := bitSet(nulls, n)
+		n++
+		if isNull {
+			continue
+		}
+		value, err := decodeValue(r, tp, table.Meta[i], table.Unsigned[i])
+		if err != nil {
+			return nil, fmt.Errorf("column %d of %s.%s: %w", i, table.Schema, table.Table, err)
+		}
+		if r.err != nil {
+			return nil, fmt.Errorf("column %d of %s.%s: %w", i, table.Schema, table.Table, r.err)
+		}
+		row[i] = value
+	}
+	return row, nil
+}
+
+// decodeValue 按列类型解码一个值
+// 整数为 int64（无符号列为 uint64），浮点数为 float64，DECIMAL 为字符串，
+// 字符串和BLOB为 []byte，日期时间为 time.Time（本地时区），TIME 为 "hh:mm:ss" 形式的字符串；
+// JSON 和空间类型以服务器的二进制格式原样返回
+func decodeValue(r *reader, tp byte, meta uint16, unsigned bool) (interface{}, error) {
+	// CHAR、ENUM、SET在行事件中都以STRING类型出现，实际类型在元数据中
+	length := 0
+	if tp == typeString && meta >= 256 {
+		b0, b1 := byte(meta>>8), byte(meta)
+		if b0&0x30 != 0x30 {
+			length = int(uint16(b1) | uint16((b0&0x30)^0x30)<<4)
+			tp = b0 | 0x30
+		} else {
+			length = int(b1)
+			tp = b0
+		}
+	} else if tp == typeString {
+		length = int(meta)
+	}
+
+	switch tp {
+	case typeTiny:
+		return integer(r.uintN(1), 1, unsigned), nil
+	case typeShort:
+		return integer(r.uintN(2), 2, unsigned), nil
+	case typeInt24:
+		return integer(r.uintN(3), 3, unsigned), nil
+	case typeLong:
+		return integer(r.uintN(4), 4, unsigned), nil
+	case typeLongLong:
+		return integer(r.uint64(), 8, unsigned), nil
+	case typeYear:
+		if year := r.uint8(); year != 0 {
+			return int64(year) + 1900, nil
+		}
+		return int64(0), nil
+	case typeFloat:
+		return float64(math.Float32frombits(r.uint32())), nil
+	case typeDouble:
+		return math.Float64frombits(r.uint64()), nil
+	case typeNewDecimal:
+		return decodeDecimal(r, int(meta>>8), int(meta&0xff))
+	case typeVarchar, typeVarString:
+		n := 1
+		if meta > 255 {
+			n = 2
+		}
+		return copyBytes(r.bytes(int(r.uintN(n)))), nil
+	case typeString:
+		n := 1
+		if length > 255 {
+			n = 2
+		}
+		return copyBytes(r.bytes(int(r.uintN(n)))), nil
+	case typeEnum:
+		return int64(r.uintN(int(meta & 0xff))), nil
+	case typeSet:
+		return int64(r.uintN(int(meta & 0xff))), nil
+	case typeBlob, typeTinyBlob, typeMediumBlob, typeLongBlob, typeJSON, typeGeometry:
+		return copyBytes(r.bytes(int(r.uintN(int(meta))))), nil
+	case typeBit:
+		bits := int(meta>>8)*8 + int(meta&0xff)
+		return bigEndian(r.bytes((bits + 7) / 8)), nil
+	case typeDate:
+		v := r.uintN(3)
+		if v == 0 {
+			return nil, nil
+		}
+		return time.Date(int(v>>9), time.Month((v>>5)&15), int(v&31), 0, 0, 0, 0, time.Local), nil
+	case typeTimestamp:
+		return time.Unix(int64(r.uint32()), 0), nil
+	case typeTimestamp2:
+		sec := int64(bigEndian(r.bytes(4)))
+		usec := fraction(r, int(meta))
+		return time.Unix(sec, usec*1000), nil
+	case typeDateTime:
+		v := r.uint64()
+		if v == 0 {
+			return nil, nil
+		}
+		d, t := v/1000000, v%1000000
+		return time.Date(int(d/10000), time.Month(d/100%100), int(d%100),
+			int(t/10000), int(t/100%100), int(t%100), 0, time.Local), nil
+	case typeDateTime2:
+		packed := int64(bigEndian(r.bytes(5))) - 0x8000000000
+		usec := fraction(r, int(meta))
+		if packed == 0 {
+			return nil, nil
+		}
+		ymd := packed >> 17
+		ym := ymd >> 5
+		hms := packed % (1 << 17)
+		return time.Date(int(ym/13), time.Month(ym%13), int(ymd%(1<<5)),
+			int(hms>>12), int((hms>>6)%(1<<6)), int(hms%(1<<6)), int(usec*1000), time.Local), nil
+	case typeTime:
+		v := r.uintN(3)
+		return fmt.Sprintf("%02d:%02d:%02d", v/10000, v/100%100, v%100), nil
+	case typeTime2:
+		return decodeTime2(r, int(meta)), nil
+	case typeNull:
+		return nil, nil
+	}
+	return nil, fmt.Errorf("unsupported column type %d", tp)
+}
+
+// integer 按位宽把整数解释为有符号或无符号
+func integer(v uint64, size int, unsigned bool) interface{} {
+	if unsigned {
+		return v
+	}
+	shift := 64 - size*8
+	return int64(v<<shift) >> shift
+}
+
+// copyBytes 复制字节，避免引用事件的缓冲区
+func copyBytes(b []byte) []byte {
+	return append([]byte{}, b...)
+}
+
+// fraction 读取小数秒，返回微秒；fsp 为小数位数
+func fraction(r *reader, fsp int) int64 {
+	n := (fsp + 1) / 2
+	if n == 0 {
+		return 0
+	}
+	v := int64(bigEndian(r.bytes(n)))
+	switch n {
+	case 1:
+		return v * 10000
+	case 2:
+		return v * 100
+	}
+	return v
+}
+
+// decodeTime2 解码 TIME(fsp) 列
+func decodeTime2(r *reader, fsp int) string {
+	var intPart, frac int64
+	switch (fsp + 1) / 2 {
+	case 1:
+		intPart = int64(bigEndian(r.bytes(3))) - 0x800000
+		frac = int64(r.uint8())
+		if intPart < 0 && frac != 0 {
+			intPart++
+			frac -= 0x100
+		}
+		frac *= 10000
+	case 2:
+		intPart = int64(bigEndian(r.bytes(3))) - 0x800000
+		frac = int64(bigEndian(r.bytes(2)))
+		if intPart < 0 && frac != 0 {
+			intPart++
+			frac -= 0x10000
+		}
+		frac *= 100
+	case 3:
+		packed := int64(bigEndian(r.bytes(6))) - 0x800000000000
+		intPart, frac = packed>>24, packed%(1<<24)
+	default:
+		intPart = int64(bigEndian(r.bytes(3))) - 0x800000
+	}
+
+	sign := ""
+	if intPart < 0 || frac < 0 {
+		sign = "-"
+		intPart, frac = -intPart, -frac
+	}
+	s := fmt.Sprintf("%s%02d:%02d:%02d", sign, (intPart>>12)%(1<<10), (intPart>>6)%(1<<6), intPart%(1<<6))
+	if fsp > 0 {
+		s += "." + fmt.Sprintf("%06d", frac)[:fsp]
+	}
+	return s
+}
+
+// decimalBytes DECIMAL不足9位的部分占用的字节数
+var decimalBytes = [10]int{0, 1, 1, 2, 2, 3, 3, 4, 4, 4}
+
+// decodeDecimal 解码 DECIMAL(precision, scale) 列：整数和小数部分每9位十进制数存为4字节大端整数，
+// 不足9位的部分按位数占用1到4字节；最高位为符号位（1为正），负数所有字节取反
+func decodeDecimal(r *reader, precision, scale int) (string, error) {
+	integral := precision - scale
+	fullInt, restInt := integral/9, integral%9
+	fullFrac, restFrac := scale/9, scale%9
+	size := fullInt*4 + decimalBytes[restInt] + fullFrac*4 + decimalBytes[restFrac]
+	raw := r.bytes(size)
+	if raw == nil {
+		return "", r.err
+	}
+	buf := copyBytes(raw)
+	negative := buf[0]&0x80 == 0
+	buf[0] ^= 0x80
+	if negative {
+		for i := range buf {
+			buf[i] = ^buf[i]
+		}
+	}
+
+	var b strings.Builder
+	pos := 0
+	next := func(n int) uint64 {
+		v := bigEndian(buf[pos : pos+n])
+		pos += n
+		return v
+	}
+	if n := decimalBytes[restInt]; n > 0 {
+		b.WriteString(strconv.FormatUint(next(n), 10))
+	}
+	for i := 0; i < fullInt; i++ {
+		fmt.Fprintf(&b, "%09d", next(4))
+	}
+	digits := strings.TrimLeft(b.String(), "0")
+	if digits == "" {
+		digits = "0"
+	}
+	if negative {
+		digits = "-" + digits
+	}
+	if scale == 0 {
+		return digits, nil
+	}
+
+	b.Reset()
+	for i := 0; i < fullFrac; i++ {
+		fmt.Fprintf(&b, "%09d", next(4))
+	}
+	if n := decimalBytes[restFrac]; n > 0 {
+		fmt.Fprintf(&b, "%0*d", restFrac, next(n))
+	}
+	return digits + "." + b.String(), nil
+}
@@ -0,0 +1,161 @@
+package netfault
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"math/rand"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// AnyPeer 匹配所有对端的规则键
+const AnyPeer = "*"
+
+// PeerHeader 标识请求发起方的HTTP头，从节点在每个请求中携带自己的ID
+const PeerHeader = "X-Slave-ID"
+
+// ErrInjectedDrop 故障注入导致的请求丢弃
+var ErrInjectedDrop = errors.New("injected network fault: request dropped")
+
+// Rule 针对单个对端的网络故障规则
+type Rule struct {
+	Peer        string        `json:"peer"`         // 对端标识（从节点ID、"master"或"*"）
+	DropPercent int           `json:"drop_percent"` // 丢弃请求的百分比（0~100）
+	Latency     time.Duration `json:"latency"`      // 额外增加的延迟
+	Partitioned bool          `json:"partitioned"`  // 是否处于网络分区（全部丢弃）
+}
+
+// Injector 网络故障注入器，可同时用于HTTP服务端和客户端
+type Injector struct {
+	rules map[string]Rule // 对端 -> 规则
+	mu    sync.RWMutex    // 并发控制锁
+}
+
+// NewInjector 创建新的网络故障注入器
+func NewInjector() *Injector {
+	return &Injector{
+		rules: make(map[string]Rule),
+	}
+}
+
+// SetRule 设置某个对端的故障规则
+func (i *Injector) SetRule(rule Rule) error {
+	if rule.Peer == "" {
+		rule.Peer = AnyPeer
+	}
+	if rule.DropPercent < 0 || rule.DropPercent > 100 {
+		return fmt.Errorf("drop percent must be between 0 and 100, got %d", rule.DropPercent)
+	}
+	if rule.Latency < 0 {
+		return fmt.Errorf("latency must not be negative")
+	}
+
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.rules[rule.Peer] = rule
+
+	log.Printf("Network fault rule set for %s: drop=%d%%, latency=%v, partitioned=%v",
+		rule.Peer, rule.DropPercent, rule.Latency, rule.Partitioned)
+	return nil
+}
+
+// ClearRule 清除某个对端的故障规则，peer为空时清除全部规则
+func (i *Injector) ClearRule(peer string) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	if peer == "" {
+		i.rules = make(map[string]Rule)
+		log.Printf("All network fault rules cleared")
+		return
+	}
+	delete(i.rules, peer)
+	log.Printf("Network fault rule cleared for %s", peer)
+}
+
+// Rules 获取当前所有故障规则
+func (i *Injector) Rules() []Rule {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+
+	rules := make([]Rule, 0, len(i.rules))
+	for _, rule := range i.rules {
+		rules = append(rules, rule)
+	}
+	return rules
+}
+
+// ruleFor 获取对端适用的规则，精确匹配优先于通配规则
+func (i *Injector) ruleFor(peer string) (Rule, bool) {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+
+	if rule, ok := i.rules[peer]; ok {
+		return rule, true
+	}
+	rule, ok := i.rules[AnyPeer]
+	return rule, ok
+}
+
+// apply 对一次请求应用故障规则，返回是否应当丢弃
+func (i *Injector) apply(peer string) bool {
+	rule, ok := i.ruleFor(peer)
+	if !ok {
+		return false
+	}
+
+	if rule.Partitioned {
+		return true
+	}
+	if rule.Latency > 0 {
+		time.Sleep(rule.Latency)
+	}
+	return rule.DropPercent > 0 && rand.Intn(100) < rule.DropPercent
+}
+
+// Middleware 服务端中间件，按请求头中的对端标识注入故障
+func (i *Injector) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		peer := r.Header.Get(PeerHeader)
+		if peer == "" {
+			peer = r.URL.Query().Get("slave_id")
+		}
+		if peer != "" && i.apply(peer) {
+			// 尽量模拟网络中断：直接断开连接，无法劫持时返回503
+			if hj, ok := w.(http.Hijacker); ok {
+				if conn, _, err := hj.Hijack(); err == nil {
+					conn.Close()
+					return
+				}
+			}
+			http.Error(w, ErrInjectedDrop.Error(), http.StatusServiceUnavailable)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Transport 客户端传输层包装，对发往指定对端的请求注入故障
+func (i *Injector) Transport(peer string, base http.RoundTripper) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &faultTransport{injector: i, peer: peer, base: base}
+}
+
+// faultTransport 带故障注入的http.RoundTripper
+type faultTransport struct {
+	injector *Injector
+	peer     string
+	base     http.RoundTripper
+}
+
+// RoundTrip 实现http.RoundTripper接口
+func (t *faultTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if t.injector.apply(t.peer) {
+		return nil, ErrInjectedDrop
+	}
+	return t.base.RoundTrip(req)
+}
@@ -0,0 +1,52 @@
+package replication
+
+import (
+	"log"
+	"time"
+)
+
+// ackApplied 确认已应用到position（调用方持有syncMutex）：一批条目只确认最后的位置，主节点把它视为对之前所有位置的确认；
+// 配置了 AckIntervalMs 时只记录位置，由 ackLoop 每隔这段时间发送一次最新的位置
+func (s *Slave) ackApplied(position uint64) {
+	if s.config.AckIntervalMs > 0 {
+		s.pendingAck = max(s.pendingAck, position)
+		return
+	}
+	if err := s.ackWithLease(position); err != nil {
+		log.Printf("Warning: Failed to send ACK for position %d: %v", position, err)
+	}
+}
+
+// ackLoop 合并确认时定期发送最新的已应用位置，同步停止时发送最后一次
+func (s *Slave) ackLoop(stop <-chan struct{}) {
+	ticker := time.NewTicker(time.Duration(s.config.AckIntervalMs) * time.Millisecond)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			s.flushAck()
+			return
+		case <-ticker.C:
+			s.flushAck()
+		}
+	}
+}
+
+// flushAck 发送尚未确认的最新位置，失败时保留该位置，下一次连同之后应用的位置一起确认
+func (s *Slave) flushAck() {
+	s.syncMutex.Lock()
+	position := s.pendingAck
+	s.pendingAck = 0
+	s.syncMutex.Unlock()
+	if position == 0 {
+		return
+	}
+
+	if err := s.ackWithLease(position); err != nil {
+		log.Printf("Warning: Failed to send ACK for position %d: %v", position, err)
+		s.syncMutex.Lock()
+		s.pendingAck = max(s.pendingAck, position)
+		s.syncMutex.Unlock()
+	}
+}
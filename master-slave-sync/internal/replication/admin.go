@@ -0,0 +1,90 @@
+package replication
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+)
+
+// ErrInvalidInjection 注入的条目不合法
+var ErrInvalidInjection = errors.New("invalid injected binlog entry")
+
+// SetSkipCounter 设置跳过计数（MySQL的 sql_slave_skip_counter）：接下来的n个尚未应用的条目不应用，只推进位置，
+// 用于跳过使复制停止的条目；n为0时取消尚未使用的跳过。返回之前尚未使用的计数
+func (s *Slave) SetSkipCounter(n int) (int, error) {
+	if n < 0 {
+		return 0, fmt.Errorf("skip counter must not be negative: %d", n)
+	}
+	s.syncMutex.Lock()
+	defer s.syncMutex.Unlock()
+	previous := s.skipCounter
+	s.skipCounter = n
+	log.Printf("Slave %s skip counter set to %d (was %d)", s.slaveID, n, previous)
+	return previous, nil
+}
+
+// SkipCounter 尚未使用的跳过计数
+func (s *Slave) SkipCounter() int {
+	s.syncMutex.Lock()
+	defer s.syncMutex.Unlock()
+	return s.skipCounter
+}
+
+// useSkipCounter 一批条目提交后扣除其中按跳过计数跳过的条目（调用方持有syncMutex）；
+// 事务回滚时不调用，被跳过的条目会在重试时再次跳过
+func (s *Slave) useSkipCounter(ids []uint64) {
+	if len(ids) == 0 {
+		return
+	}
+	s.skipCounter -= len(ids)
+	s.skippedByCounter += len(ids)
+	log.Printf("Warning: skipped binlog entries %v by the skip counter (%d remaining), slave data may now differ from the master",
+		ids, s.skipCounter)
+}
+
+// InjectRequest 手动注入binlog的条目：不修改主节点的数据，只把条目追加到binlog，
+// 用于制造从节点应用失败（如更新不存在的行、写入不存在的表）以练习复制错误的排查与恢复。
+// 只能注入基于行的INSERT、UPDATE、DELETE：从节点按语句或DDL执行的条目相当于在所有从节点上执行任意SQL
+type InjectRequest struct {
+	Operation string          `json:"operation"`        // INSERT、UPDATE 或 DELETE
+	Format    string          `json:"format,omitempty"` // 只能为空（基于行），不接受statement
+	TableName string          `json:"table_name"`       // 表名
+	RecordID  uint            `json:"record_id"`        // 记录ID
+	Data      json.RawMessage `json:"data"`             // 行数据，原样写入
+	Before    json.RawMessage `json:"before,omitempty"` // UPDATE的前映像（可选）
+}
+
+// InjectEntry 把一个合成的条目追加到binlog，返回条目的位置；条目与正常写入一样带有本节点的服务器ID、纪元和校验和，
+// 从节点无法区分。注入的条目不等待从节点确认
+func (m *Master) InjectEntry(req InjectRequest) (uint64, error) {
+	switch req.Operation {
+	case OpInsert, OpUpdate, OpDelete:
+	case OpDDL:
+		return 0, fmt.Errorf("%w: DDL entries cannot be injected, use /api/ddl", ErrInvalidInjection)
+	default:
+		return 0, fmt.Errorf("%w: unknown operation %q", ErrInvalidInjection, req.Operation)
+	}
+	if req.Format != "" {
+		return 0, fmt.Errorf("%w: only row-based entries can be injected, got format %q", ErrInvalidInjection, req.Format)
+	}
+	if req.TableName == "" {
+		return 0, fmt.Errorf("%w: table_name is required", ErrInvalidInjection)
+	}
+
+	m.ddlMu.RLock()
+	defer m.ddlMu.RUnlock()
+	pos, err := m.binlog.appendWrite(BinlogEntry{
+		Operation: req.Operation,
+		ServerID:  m.config.ServerID,
+		TableName: req.TableName,
+		RecordID:  req.RecordID,
+		Data:      req.Data,
+		Before:    req.Before,
+	})
+	if err != nil {
+		return 0, err
+	}
+	log.Printf("Injected synthetic %s entry on %s (record %d) at binlog position %d", req.Operation, req.TableName, req.RecordID, pos)
+	return pos, nil
+}
@@ -0,0 +1,141 @@
+package replication
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"net/http"
+	"strings"
+
+	"google.golang.org/grpc/metadata"
+
+	"master-slave-sync/internal/netfault"
+)
+
+// bearerPrefix 复制凭据在 Authorization 请求头（gRPC为 authorization 元数据）中的前缀
+const bearerPrefix = "Bearer "
+
+// 复制凭据校验失败的原因
+var (
+	ErrUnauthorized    = errors.New("missing or invalid replication credentials")
+	ErrSlaveIDMismatch = errors.New("slave id does not match replication credentials")
+	ErrAdminDisabled   = errors.New("admin operations are disabled: no admin token configured")
+	ErrAdminAuth       = errors.New("missing or invalid admin token")
+)
+
+// ReplicationAuth 按从节点ID校验复制请求携带的令牌，未配置任何令牌时不校验（任何客户端都可以复制）
+type ReplicationAuth struct {
+	tokens map[string]string // 从节点ID -> 令牌
+}
+
+// NewReplicationAuth 根据配置的从节点令牌创建校验器，忽略令牌为空的从节点
+func NewReplicationAuth(tokens map[string]string) *ReplicationAuth {
+	auth := &ReplicationAuth{tokens: make(map[string]string, len(tokens))}
+	for id, token := range tokens {
+		if token != "" {
+			auth.tokens[id] = token
+		}
+	}
+	return auth
+}
+
+// Enabled 是否校验复制请求
+func (a *ReplicationAuth) Enabled() bool {
+	return a != nil && len(a.tokens) > 0
+}
+
+// Authenticate 校验从节点slaveID的令牌，未开启校验时总是通过
+func (a *ReplicationAuth) Authenticate(slaveID, token string) error {
+	if !a.Enabled() {
+		return nil
+	}
+	expected, ok := a.tokens[slaveID]
+	if !ok || subtle.ConstantTimeCompare([]byte(expected), []byte(token)) != 1 {
+		return ErrUnauthorized
+	}
+	return nil
+}
+
+// AuthenticateRequest 校验HTTP请求：从节点ID来自 X-Slave-ID 请求头，令牌来自 Authorization 请求头，
+// 返回通过校验的从节点ID（未开启校验时为请求头中的ID，可能为空）
+func (a *ReplicationAuth) AuthenticateRequest(r *http.Request) (string, error) {
+	slaveID := r.Header.Get(netfault.PeerHeader)
+	if err := a.Authenticate(slaveID, bearerToken(r.Header.Get("Authorization"))); err != nil {
+		return "", err
+	}
+	return slaveID, nil
+}
+
+// AuthenticateContext 校验gRPC调用：令牌来自 authorization 元数据，从节点ID来自请求消息
+func (a *ReplicationAuth) AuthenticateContext(ctx context.Context, slaveID string) error {
+	if !a.Enabled() {
+		return nil
+	}
+	var token string
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if values := md.Get("authorization"); len(values) > 0 {
+			token = bearerToken(values[0])
+		}
+	}
+	return a.Authenticate(slaveID, token)
+}
+
+// AuthenticateAdmin 校验从节点管理操作（如手动提升为主节点、设置跳过计数）携带的令牌，未配置 AdminToken 时拒绝所有管理操作
+func (s *Slave) AuthenticateAdmin(r *http.Request) error {
+	return authenticateAdmin(s.config.AdminToken, r)
+}
+
+// AuthenticateAdmin 校验主节点管理操作（如注入binlog条目）携带的令牌，未配置 AdminToken 时拒绝所有管理操作
+func (m *Master) AuthenticateAdmin(r *http.Request) error {
+	return authenticateAdmin(m.config.AdminToken, r)
+}
+
+// authenticateAdmin 校验 Authorization 请求头中的令牌与adminToken一致，adminToken为空时总是拒绝
+func authenticateAdmin(adminToken string, r *http.Request) error {
+	if adminToken == "" {
+		return ErrAdminDisabled
+	}
+	token := bearerToken(r.Header.Get("Authorization"))
+	if subtle.ConstantTimeCompare([]byte(adminToken), []byte(token)) != 1 {
+		return ErrAdminAuth
+	}
+	return nil
+}
+
+// bearerToken 从 "Bearer <token>" 中取出令牌，格式不符时返回空字符串
+func bearerToken(value string) string {
+	if len(value) < len(bearerPrefix) || !strings.EqualFold(value[:len(bearerPrefix)], bearerPrefix) {
+		return ""
+	}
+	return strings.TrimSpace(value[len(bearerPrefix):])
+}
+
+// setAuthHeader 在发往复制源的请求中携带令牌，没有配置令牌时不设置
+func setAuthHeader(header http.Header, token string) {
+	if token != "" {
+		header.Set("Authorization", bearerPrefix+token)
+	}
+}
+
+// tokenCredentials gRPC调用携带的复制令牌
+type tokenCredentials string
+
+// GetRequestMetadata 在每次调用的元数据中放入令牌
+func (t tokenCredentials) GetRequestMetadata(ctx context.Context, uri ...string) (map[string]string, error) {
+	return map[string]string{"authorization": bearerPrefix + string(t)}, nil
+}
+
+// RequireTransportSecurity 复制服务目前使用明文连接，令牌可以在不加密的连接上发送
+func (t tokenCredentials) RequireTransportSecurity() bool {
+	return false
+}
+
+// ReplicationAuth 主节点的复制凭据校验器
+func (m *Master) ReplicationAuth() *ReplicationAuth {
+	return m.auth
+}
+
+// DownstreamAuth 作为中继时校验下游从节点的复制凭据
+func (s *Slave) DownstreamAuth() *ReplicationAuth {
+	return s.downstreamAuth
+}
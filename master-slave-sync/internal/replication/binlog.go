@@ -18,26 +18,44 @@ const (
 
 // BinlogEntry 表示一个简化的binlog条目
 type BinlogEntry struct {
-	ID        uint64    `json:"id"`         // binlog唯一标识符
-	Operation string    `json:"operation"`  // 操作类型：INSERT, UPDATE, DELETE
-	TableName string    `json:"table_name"` // 表名
-	RecordID  uint      `json:"record_id"`  // 被操作记录的ID
-	Data      []byte    `json:"data"`       // 序列化后的记录数据
-	Timestamp time.Time `json:"timestamp"`  // 操作时间
+	ID        uint64    `json:"id"`                  // binlog唯一标识符
+	Operation string    `json:"operation"`           // 操作类型：INSERT, UPDATE, DELETE, DDL
+	Format    string    `json:"format,omitempty"`    // binlog格式，为空表示基于行（Data为行数据），statement表示Data为执行的语句
+	ServerID  uint32    `json:"server_id,omitempty"` // 产生该条目的节点的服务器ID，级联复制中据此发现复制环
+	Epoch     uint64    `json:"epoch,omitempty"`     // 产生该条目时主节点的纪元，每次故障切换后递增，从节点拒绝比已知纪元旧的条目
+	TableName string    `json:"table_name"`          // 表名
+	RecordID  uint      `json:"record_id"`           // 被操作记录的ID
+	Data      []byte    `json:"data"`                // 序列化后的记录数据
+	Before    []byte    `json:"before,omitempty"`    // UPDATE之前的行（前映像），为空表示未记录，从节点此时按Data写入所有列
+	Timestamp time.Time `json:"timestamp"`           // 操作时间
+	WriteID   uint64    `json:"write_id,omitempty"`  // 对应的复制日志ID，崩溃恢复时据此避免重复补发
+	Checksum  string    `json:"checksum,omitempty"`  // 以上字段的CRC32校验和，追加时计算，应用前校验
 }
 
 // Binlog 简化的binlog管理器
 type Binlog struct {
-	entries  []BinlogEntry // binlog条目集合
+	entries  []BinlogEntry // binlog条目集合（持久化时作为文件的读取缓存）
 	position uint64        // 当前位置
+	epoch    uint64        // 追加的条目携带的纪元（主节点的纪元），中继日志为0
+	file     *binlogFile   // 持久化的分段文件（可选），见 OpenBinlog
+	group    *groupCommit  // 组提交（可选，只用于持久化的binlog），见 BinlogFileOptions.GroupCommit
 	mu       sync.RWMutex  // 并发控制锁
+
+	purgedSegments int        // 启动以来清理的分段数
+	lastPurgeAt    *time.Time // 最近一次清理时间
+
+	changed   chan struct{} // 追加条目时关闭并替换，用于唤醒等待新条目的推送流
+	closed    chan struct{} // binlog关闭时关闭
+	closeOnce sync.Once     // 保证closed只关闭一次
 }
 
-// NewBinlog 创建一个新的binlog管理器
+// NewBinlog 创建一个只保存在内存中的binlog管理器，进程重启后复制历史丢失
 func NewBinlog() *Binlog {
 	return &Binlog{
 		entries:  make([]BinlogEntry, 0),
 		position: 0,
+		changed:  make(chan struct{}),
+		closed:   make(chan struct{}),
 	}
 }
 
@@ -64,24 +82,71 @@ func (b *Binlog) append(operation string, record *storage.Record) (uint64, error
 	if err != nil {
 		return 0, fmt.Errorf("failed to serialize record: %w", err)
 	}
+	return b.appendWrite(BinlogEntry{Operation: operation, TableName: RecordsTable, RecordID: record.ID, Data: data})
+}
+
+// appendWrite 添加一条已序列化的binlog条目，由binlog分配ID、时间戳和校验和，
+// WriteID为对应的复制日志ID（0表示无）
+// 持久化时先写入文件，写入失败时条目不会加入binlog，位置也不会前进
+func (b *Binlog) appendWrite(entry BinlogEntry) (uint64, error) {
+	if b.group != nil {
+		return b.appendGrouped(entry)
+	}
 
 	b.mu.Lock()
 	defer b.mu.Unlock()
+	if b.isClosed() {
+		return 0, ErrBinlogClosed
+	}
 
-	b.position++
-	entry := BinlogEntry{
-		ID:        b.position,
-		Operation: operation,
-		TableName: "records", // 我们只有一个表
-		RecordID:  record.ID,
-		Data:      data,
-		Timestamp: time.Now(),
+	entry.ID = b.position + 1
+	entry.Timestamp = time.Now()
+	entry.Epoch = b.epoch
+	entry.Checksum = entry.computeChecksum()
+	if b.file != nil {
+		if err := b.file.append(entry); err != nil {
+			return 0, err
+		}
 	}
 
-	b.entries = append(b.entries, entry)
+	b.publish(entry)
 	return b.position, nil
 }
 
+// isClosed binlog是否已经关闭
+func (b *Binlog) isClosed() bool {
+	select {
+	case <-b.closed:
+		return true
+	default:
+		return false
+	}
+}
+
+// publish 把已写入的条目加入内存并推进位置，唤醒所有等待新条目的推送流（调用方持有锁）
+func (b *Binlog) publish(entries ...BinlogEntry) {
+	b.position = entries[len(entries)-1].ID
+	b.entries = append(b.entries, entries...)
+
+	close(b.changed)
+	b.changed = make(chan struct{})
+}
+
+// loggedWrites 获取binlog中已包含的复制日志ID
+// 并发写入追加binlog的顺序不一定与复制日志ID的顺序一致，因此需要扫描全部条目（只在启动恢复时调用）
+func (b *Binlog) loggedWrites() map[uint64]bool {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	written := make(map[uint64]bool)
+	for _, entry := range b.entries {
+		if entry.WriteID != 0 {
+			written[entry.WriteID] = true
+		}
+	}
+	return written
+}
+
 // GetEntries 获取指定位置之后的所有binlog条目
 func (b *Binlog) GetEntries(fromPosition uint64) []BinlogEntry {
 	b.mu.RLock()
@@ -96,6 +161,14 @@ func (b *Binlog) GetEntries(fromPosition uint64) []BinlogEntry {
 	return result
 }
 
+// notifications 返回下一次追加条目时关闭的通道和binlog关闭时关闭的通道
+// 调用方应先获取通道再读取条目，避免错过两者之间追加的条目
+func (b *Binlog) notifications() (changed, closed <-chan struct{}) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return b.changed, b.closed
+}
+
 // GetCurrentPosition 获取当前binlog位置
 func (b *Binlog) GetCurrentPosition() uint64 {
 	b.mu.RLock()
@@ -103,43 +176,25 @@ func (b *Binlog) GetCurrentPosition() uint64 {
 	return b.position
 }
 
-// ApplyEntry 应用binlog条目到从库
+// ApplyEntry 应用binlog条目到从库，按条目的表名交给已注册的表处理
+// 基于行的条目可以重复应用：插入时主键已存在则覆盖，删除不存在的行不报错；
+// 基于语句的条目重复应用可能失败，由从节点按已应用位置跳过重复条目（见 applyBatch）
 func ApplyEntry(db *storage.DB, entry BinlogEntry) error {
-	switch entry.Operation {
-	case OpInsert:
-		var record storage.Record
-		if err := json.Unmarshal(entry.Data, &record); err != nil {
-			return fmt.Errorf("failed to deserialize record: %w", err)
-		}
-		// 我们需要绕过普通的创建方法，因为它有主节点检查
-		result := db.GetConnection().Create(&record)
-		if result.Error != nil {
-			return fmt.Errorf("failed to apply INSERT: %w", result.Error)
-		}
-
-	case OpUpdate:
-		var record storage.Record
-		if err := json.Unmarshal(entry.Data, &record); err != nil {
-			return fmt.Errorf("failed to deserialize record: %w", err)
-		}
-		// 直接更新记录的内容
-		result := db.GetConnection().Model(&storage.Record{}).
-			Where("id = ?", record.ID).
-			Update("content", record.Content)
-		if result.Error != nil {
-			return fmt.Errorf("failed to apply UPDATE: %w", result.Error)
-		}
-
-	case OpDelete:
-		// 直接删除指定ID的记录
-		result := db.GetConnection().Delete(&storage.Record{}, entry.RecordID)
-		if result.Error != nil {
-			return fmt.Errorf("failed to apply DELETE: %w", result.Error)
-		}
+	// 先校验条目，损坏的条目不应用
+	if err := entry.Verify(); err != nil {
+		return err
+	}
 
-	default:
-		return fmt.Errorf("unknown operation: %s", entry.Operation)
+	if entry.Operation == OpDDL {
+		return applyDDL(db, entry)
+	}
+	if entry.Format == BinlogFormatStatement {
+		return applyStatements(db, entry)
 	}
 
-	return nil
+	table, err := LookupTable(entry.TableName)
+	if err != nil {
+		return err
+	}
+	return table.Apply(db.GetConnection(), entry)
 }
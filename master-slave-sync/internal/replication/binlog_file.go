@@ -0,0 +1,622 @@
+package replication
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// SyncPolicy binlog文件的刷盘策略
+type SyncPolicy string
+
+// 支持的刷盘策略
+const (
+	SyncAlways   SyncPolicy = "always"   // 每条条目写入后立即fsync，主机崩溃也不丢失（默认）
+	SyncInterval SyncPolicy = "interval" // 后台定期fsync，主机崩溃时可能丢失最近一个间隔内的条目
+	SyncNone     SyncPolicy = "none"     // 不主动fsync，交给操作系统，只保证进程崩溃不丢失
+)
+
+// binlog文件的默认参数
+const (
+	defaultBinlogSyncInterval = 100 * time.Millisecond
+	defaultMaxSegmentBytes    = 64 << 20 // 单个分段文件的默认大小上限(64MB)
+)
+
+// ErrBinlogFailed 写入binlog文件失败且无法撤销已写入的部分，文件末尾可能有不完整的条目，
+// 为避免在其后继续写入（重启时无法加载），拒绝之后的所有追加，需要重启后由加载时的截断恢复
+var ErrBinlogFailed = errors.New("binlog file failed, refusing further appends")
+
+// ErrBinlogClosed binlog已关闭，条目没有写入文件也没有加入binlog（写入仍可由复制日志在重启后补发）
+var ErrBinlogClosed = errors.New("binlog is closed")
+
+// ParseSyncPolicy 解析刷盘策略名称，空字符串表示默认的 always
+func ParseSyncPolicy(name string) (SyncPolicy, error) {
+	switch p := SyncPolicy(name); p {
+	case "":
+		return SyncAlways, nil
+	case SyncAlways, SyncInterval, SyncNone:
+		return p, nil
+	}
+	return "", fmt.Errorf("unknown binlog sync policy: %s", name)
+}
+
+// BinlogFileOptions binlog文件的刷盘与分段参数，零值表示使用默认值
+type BinlogFileOptions struct {
+	Sync            SyncPolicy    // 刷盘策略
+	SyncInterval    time.Duration // interval策略的刷盘间隔，0表示100毫秒
+	MaxSegmentBytes int64         // 分段文件达到该大小后切换到新分段，0表示64MB
+	MaxSegmentAge   time.Duration // 分段文件的第一个条目写入超过该时长后切换到新分段，0表示不按时间切换
+	Encoding        EntryEncoding // 新分段中条目的编码，为空表示二进制；已有分段保持原来的编码
+	GroupCommit     bool          // 把并发的追加合并成组，每组只写入并刷盘一次
+	GroupWindow     time.Duration // 组提交时组长等待其他追加者加入的时间，0表示不额外等待
+}
+
+// SegmentInfo 一个binlog分段文件的信息
+type SegmentInfo struct {
+	Name      string    `json:"name"`       // 文件名，如 master.binlog.000003
+	FirstID   uint64    `json:"first_id"`   // 第一个条目ID，空分段为0
+	LastID    uint64    `json:"last_id"`    // 最后一个条目ID，空分段为0
+	Entries   int       `json:"entries"`    // 条目数
+	SizeBytes int64     `json:"size_bytes"` // 文件大小
+	CreatedAt time.Time `json:"created_at"` // 第一个条目的写入时间（空分段为打开时间）
+	Active    bool      `json:"active"`     // 是否为正在写入的分段
+	Encoding  string    `json:"encoding"`   // 条目的编码：binary 或 json
+}
+
+// binlogSegment 一个分段文件，文件名为 <BinlogPath>.<6位序号>，与MySQL的 mysql-bin.000001 类似
+type binlogSegment struct {
+	seq       int
+	path      string
+	firstID   uint64
+	lastID    uint64
+	entries   int
+	size      int64
+	createdAt time.Time
+	encoding  EntryEncoding
+}
+
+// info 转换为对外的分段信息
+func (s *binlogSegment) info(active bool) SegmentInfo {
+	return SegmentInfo{
+		Name:      filepath.Base(s.path),
+		FirstID:   s.firstID,
+		LastID:    s.lastID,
+		Entries:   s.entries,
+		SizeBytes: s.size,
+		CreatedAt: s.createdAt,
+		Active:    active,
+		Encoding:  string(s.encoding),
+	}
+}
+
+// encode 按分段的编码序列化条目：二进制为带长度前缀的条目，JSON为一行
+func (s *binlogSegment) encode(entry BinlogEntry) ([]byte, error) {
+	if s.encoding == EncodingBinary {
+		return appendBinaryEntry(nil, entry), nil
+	}
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return nil, err
+	}
+	return append(line, '\n'), nil
+}
+
+// binlogFile 按分段追加写入的binlog文件，最后一个分段为正在写入的分段
+// 二进制编码的分段以 binarySegmentMagic 开头，之后是连续的带长度前缀的条目；JSON编码的分段每行一个条目
+type binlogFile struct {
+	base     string           // 分段文件名前缀（即配置的 BinlogPath）
+	segments []*binlogSegment // 按序号排列的分段
+	f        *os.File         // 正在写入的分段
+	opts     BinlogFileOptions
+	dirty    atomic.Bool // 是否有尚未fsync的写入（interval策略）
+	fileMu   sync.Mutex  // 保护f，后台刷盘与切换分段可能并发
+	failed   error       // 写入失败且无法撤销的原因，非空时拒绝追加
+	stopChan chan struct{}
+	wg       sync.WaitGroup
+}
+
+// OpenBinlog 打开（不存在时创建）binlog分段文件并加载其中的条目，内存中的条目作为读取缓存
+// 最后一个分段末尾不完整的条目（写入过程中崩溃）会被截断；其他位置损坏或条目ID不连续递增时返回错误，
+// 避免从损坏的历史继续复制。旧版本的单个binlog文件（path本身）会被改名为第一个分段
+func OpenBinlog(path string, opts BinlogFileOptions) (*Binlog, error) {
+	if opts.Sync == "" {
+		opts.Sync = SyncAlways
+	}
+	if opts.SyncInterval <= 0 {
+		opts.SyncInterval = defaultBinlogSyncInterval
+	}
+	if opts.MaxSegmentBytes <= 0 {
+		opts.MaxSegmentBytes = defaultMaxSegmentBytes
+	}
+	if opts.Encoding == "" {
+		opts.Encoding = EncodingBinary
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create binlog directory: %w", err)
+	}
+
+	bf := &binlogFile{base: path, opts: opts, stopChan: make(chan struct{})}
+	entries, err := bf.load()
+	if err != nil {
+		return nil, err
+	}
+	if bf.opts.Sync == SyncInterval {
+		bf.wg.Add(1)
+		go bf.syncLoop()
+	}
+
+	b := NewBinlog()
+	b.entries = entries
+	if len(entries) > 0 {
+		b.position = entries[len(entries)-1].ID
+	}
+	b.file = bf
+	if opts.GroupCommit {
+		b.group = &groupCommit{window: opts.GroupWindow}
+	}
+
+	log.Printf("Binlog loaded from %s: %d segments, %d entries, position %d, sync policy %s, encoding %s, group commit %v",
+		path, len(bf.segments), len(entries), b.position, opts.Sync, bf.active().encoding, opts.GroupCommit)
+	return b, nil
+}
+
+// segmentPath 返回指定序号的分段文件路径
+func (bf *binlogFile) segmentPath(seq int) string {
+	return fmt.Sprintf("%s.%06d", bf.base, seq)
+}
+
+// listSegments 返回已存在的分段序号（升序），并把旧版本的单个binlog文件改名为第一个分段
+func (bf *binlogFile) listSegments() ([]int, error) {
+	matches, err := filepath.Glob(bf.base + ".*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list binlog segments: %w", err)
+	}
+	var seqs []int
+	for _, m := range matches {
+		suffix := strings.TrimPrefix(m, bf.base+".")
+		if len(suffix) != 6 {
+			continue
+		}
+		seq, err := strconv.Atoi(suffix)
+		if err != nil {
+			continue
+		}
+		seqs = append(seqs, seq)
+	}
+	sort.Ints(seqs)
+
+	if len(seqs) == 0 {
+		if _, err := os.Stat(bf.base); err == nil {
+			if err := os.Rename(bf.base, bf.segmentPath(1)); err != nil {
+				return nil, fmt.Errorf("failed to convert binlog file to segment: %w", err)
+			}
+			log.Printf("Converted binlog file %s to segment %s", bf.base, bf.segmentPath(1))
+			seqs = []int{1}
+		}
+	}
+	return seqs, nil
+}
+
+// load 加载全部分段，打开最后一个分段用于追加（没有分段时创建第一个）
+func (bf *binlogFile) load() ([]BinlogEntry, error) {
+	seqs, err := bf.listSegments()
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []BinlogEntry
+	for i, seq := range seqs {
+		last := i == len(seqs)-1
+		f, err := os.OpenFile(bf.segmentPath(seq), os.O_RDWR, 0o644)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open binlog segment: %w", err)
+		}
+		segEntries, size, encoding, err := loadSegment(f, bf.segmentPath(seq), last, bf.opts.Encoding)
+		if err != nil {
+			f.Close()
+			return nil, err
+		}
+		if len(segEntries) > 0 && len(entries) > 0 && segEntries[0].ID != entries[len(entries)-1].ID+1 {
+			f.Close()
+			return nil, fmt.Errorf("binlog segment %s out of sequence: entry %d follows %d",
+				bf.segmentPath(seq), segEntries[0].ID, entries[len(entries)-1].ID)
+		}
+		entries = append(entries, segEntries...)
+
+		seg := &binlogSegment{seq: seq, path: bf.segmentPath(seq), entries: len(segEntries), size: size,
+			createdAt: time.Now(), encoding: encoding}
+		if len(segEntries) > 0 {
+			seg.firstID = segEntries[0].ID
+			seg.lastID = segEntries[len(segEntries)-1].ID
+			seg.createdAt = segEntries[0].Timestamp
+		}
+		bf.segments = append(bf.segments, seg)
+
+		if last {
+			bf.f = f
+		} else {
+			f.Close()
+		}
+	}
+
+	if bf.f == nil {
+		if err := bf.openSegment(1); err != nil {
+			return nil, err
+		}
+	}
+	return entries, nil
+}
+
+// loadSegment 读取一个分段中的全部条目，返回条目、有效内容的大小和分段的编码
+// 只有最后一个分段允许末尾不完整（截断后继续写入），并将写入位置移到有效内容末尾；
+// 最后一个分段为空时按fallback编码写入
+func loadSegment(f *os.File, path string, last bool, fallback EntryEncoding) ([]BinlogEntry, int64, EntryEncoding, error) {
+	reader := bufio.NewReader(f)
+	encoding, read := EncodingJSON, readJSONEntry
+	var offset int64
+	header, _ := reader.Peek(len(binarySegmentMagic))
+	switch {
+	case bytes.Equal(header, binarySegmentMagic):
+		reader.Discard(len(header))
+		encoding, read, offset = EncodingBinary, readBinaryEntry, int64(len(header))
+	case last && len(header) < len(binarySegmentMagic) && bytes.HasPrefix(binarySegmentMagic, header):
+		// 空分段，或写入文件头时崩溃
+		encoding = fallback
+		reader.Discard(len(header))
+	}
+
+	var entries []BinlogEntry
+	for {
+		entry, n, err := read(reader)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if errors.Is(err, io.ErrUnexpectedEOF) {
+			if !last {
+				return nil, 0, "", fmt.Errorf("incomplete binlog entry at offset %d of %s", offset, path)
+			}
+			log.Printf("Warning: truncating incomplete binlog entry at offset %d of %s (%d bytes)", offset, path, n)
+			break
+		}
+		if errors.Is(err, ErrMalformedEntry) {
+			// 只有最后一个分段的最后一个条目可能因崩溃而不完整，其他条目损坏说明文件被破坏
+			if _, peekErr := reader.Peek(1); peekErr == nil || !last {
+				return nil, 0, "", fmt.Errorf("corrupt binlog entry at offset %d of %s: %w", offset, path, err)
+			}
+			log.Printf("Warning: truncating unreadable binlog entry at offset %d of %s: %v", offset, path, err)
+			break
+		}
+		if err != nil {
+			return nil, 0, "", fmt.Errorf("failed to read binlog file: %w", err)
+		}
+
+		// 旧版本写入的条目没有校验和，加载时补算
+		if entry.Checksum == "" {
+			entry.Checksum = entry.computeChecksum()
+		} else if err := entry.Verify(); err != nil {
+			return nil, 0, "", fmt.Errorf("corrupt binlog entry at offset %d of %s: %w", offset, path, err)
+		}
+		if len(entries) > 0 && entry.ID != entries[len(entries)-1].ID+1 {
+			return nil, 0, "", fmt.Errorf("binlog %s out of sequence at offset %d: entry %d follows %d",
+				path, offset, entry.ID, entries[len(entries)-1].ID)
+		}
+		entries = append(entries, entry)
+		offset += int64(n)
+	}
+
+	if last {
+		if err := f.Truncate(offset); err != nil {
+			return nil, 0, "", fmt.Errorf("failed to truncate binlog file: %w", err)
+		}
+		if _, err := f.Seek(offset, io.SeekStart); err != nil {
+			return nil, 0, "", fmt.Errorf("failed to seek binlog file: %w", err)
+		}
+		if offset == 0 && encoding == EncodingBinary {
+			if _, err := f.Write(binarySegmentMagic); err != nil {
+				return nil, 0, "", fmt.Errorf("failed to write binlog segment header: %w", err)
+			}
+			offset = int64(len(binarySegmentMagic))
+		}
+	}
+	return entries, offset, encoding, nil
+}
+
+// readJSONEntry 从r读取下一行JSON编码的条目，返回条目和读取的字节数（包括换行符）
+// 没有更多数据时返回 io.EOF，最后一行没有换行符时返回 io.ErrUnexpectedEOF
+func readJSONEntry(r *bufio.Reader) (BinlogEntry, int, error) {
+	line, err := r.ReadBytes('\n')
+	if errors.Is(err, io.EOF) {
+		if len(line) > 0 {
+			return BinlogEntry{}, len(line), io.ErrUnexpectedEOF
+		}
+		return BinlogEntry{}, 0, io.EOF
+	}
+	if err != nil {
+		return BinlogEntry{}, 0, err
+	}
+	var entry BinlogEntry
+	if err := json.Unmarshal(line, &entry); err != nil {
+		return BinlogEntry{}, len(line), fmt.Errorf("%w: %v", ErrMalformedEntry, err)
+	}
+	return entry, len(line), nil
+}
+
+// openSegment 创建并打开新的分段作为正在写入的分段
+func (bf *binlogFile) openSegment(seq int) error {
+	path := bf.segmentPath(seq)
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_EXCL, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to create binlog segment: %w", err)
+	}
+	seg := &binlogSegment{seq: seq, path: path, createdAt: time.Now(), encoding: bf.opts.Encoding}
+	if seg.encoding == EncodingBinary {
+		if _, err := f.Write(binarySegmentMagic); err != nil {
+			f.Close()
+			return fmt.Errorf("failed to write binlog segment header: %w", err)
+		}
+		seg.size = int64(len(binarySegmentMagic))
+	}
+	bf.fileMu.Lock()
+	bf.f = f
+	bf.fileMu.Unlock()
+	bf.segments = append(bf.segments, seg)
+	return nil
+}
+
+// active 返回正在写入的分段
+func (bf *binlogFile) active() *binlogSegment {
+	return bf.segments[len(bf.segments)-1]
+}
+
+// shouldRotate 正在写入的分段是否已达到大小或时间上限（空分段不切换）
+func (bf *binlogFile) shouldRotate(now time.Time) bool {
+	seg := bf.active()
+	if seg.entries == 0 {
+		return false
+	}
+	if seg.size >= bf.opts.MaxSegmentBytes {
+		return true
+	}
+	return bf.opts.MaxSegmentAge > 0 && now.Sub(seg.createdAt) >= bf.opts.MaxSegmentAge
+}
+
+// rotate fsync并关闭正在写入的分段，切换到下一个序号的新分段；关闭后创建新分段失败时f为nil，
+// 由追加失败后的回滚重新打开原来的分段
+func (bf *binlogFile) rotate() error {
+	bf.fileMu.Lock()
+	err := bf.f.Sync()
+	if err == nil {
+		err = bf.f.Close()
+		bf.f = nil
+	}
+	bf.fileMu.Unlock()
+	if err != nil {
+		return fmt.Errorf("failed to close binlog segment: %w", err)
+	}
+
+	prev := bf.active()
+	if err := bf.openSegment(prev.seq + 1); err != nil {
+		return err
+	}
+	log.Printf("Binlog rotated: %s closed at entry %d (%d bytes), now writing %s",
+		filepath.Base(prev.path), prev.lastID, prev.size, filepath.Base(bf.active().path))
+	return nil
+}
+
+// binlogMark 追加一批条目前binlog文件的状态，写入失败时据此撤销整批
+type binlogMark struct {
+	segments int           // 分段数
+	active   binlogSegment // 正在写入的分段
+}
+
+// append 写入一个条目，必要时先切换分段，并按刷盘策略fsync（调用方持有Binlog的锁，保证条目按位置顺序写入）
+func (bf *binlogFile) append(entry BinlogEntry) error {
+	return bf.appendBatch([]BinlogEntry{entry})
+}
+
+// appendBatch 依次写入一组条目（必要时切换分段），全部写入后按刷盘策略只刷盘一次（调用方持有Binlog的锁）
+// 任何一个条目写入失败或fsync失败时撤销整批：删除批内切换出的新分段，把原来的分段截断回写入前的大小，
+// 这批条目都不追加，之后的追加从同一位置重新写入
+func (bf *binlogFile) appendBatch(entries []BinlogEntry) error {
+	if bf.failed != nil {
+		return fmt.Errorf("%w: %v", ErrBinlogFailed, bf.failed)
+	}
+
+	mark := binlogMark{segments: len(bf.segments), active: *bf.active()}
+	var err error
+	for _, entry := range entries {
+		if err = bf.write(entry); err != nil {
+			break
+		}
+	}
+	if err == nil {
+		err = bf.sync()
+	}
+	if err != nil {
+		return bf.rollback(mark, err)
+	}
+	return nil
+}
+
+// sync 按刷盘策略刷盘：always立即fsync，interval标记为待刷盘
+func (bf *binlogFile) sync() error {
+	bf.fileMu.Lock()
+	defer bf.fileMu.Unlock()
+	switch bf.opts.Sync {
+	case SyncAlways:
+		if err := bf.f.Sync(); err != nil {
+			return fmt.Errorf("failed to sync binlog file: %w", err)
+		}
+	case SyncInterval:
+		bf.dirty.Store(true)
+	}
+	return nil
+}
+
+// rollback 写入失败后把binlog文件恢复到mark的状态并返回cause：删除之后切换出的分段，重新打开mark时正在写入的分段，
+// 截断回当时的大小；无法恢复时文件末尾可能留有不完整的条目，标记binlog失败并拒绝之后的追加
+func (bf *binlogFile) rollback(mark binlogMark, cause error) error {
+	saved := mark.active
+	bf.fileMu.Lock()
+	err := bf.reopen(mark)
+	if err == nil {
+		err = bf.f.Truncate(saved.size)
+	}
+	if err == nil {
+		_, err = bf.f.Seek(saved.size, io.SeekStart)
+	}
+	bf.fileMu.Unlock()
+	if err != nil {
+		bf.failed = fmt.Errorf("%v (rollback failed: %v)", cause, err)
+		log.Printf("Error: failed to roll back binlog segment %s to %d bytes after %v: %v, refusing further appends",
+			filepath.Base(saved.path), saved.size, cause, err)
+		return fmt.Errorf("%w: %v", ErrBinlogFailed, bf.failed)
+	}
+	*bf.active() = saved
+	log.Printf("Warning: binlog append failed, segment %s rolled back to %d bytes: %v", filepath.Base(saved.path), saved.size, cause)
+	return cause
+}
+
+// reopen 删除mark之后切换出的分段，重新打开mark时正在写入的分段（调用方持有fileMu）；没有切换过分段时不做任何事
+func (bf *binlogFile) reopen(mark binlogMark) error {
+	if len(bf.segments) == mark.segments && bf.f != nil {
+		return nil
+	}
+	if bf.f != nil {
+		bf.f.Close()
+		bf.f = nil
+	}
+	for _, seg := range bf.segments[mark.segments:] {
+		if err := os.Remove(seg.path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("failed to remove binlog segment: %w", err)
+		}
+	}
+	bf.segments = bf.segments[:mark.segments]
+	f, err := os.OpenFile(mark.active.path, os.O_RDWR, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to reopen binlog segment: %w", err)
+	}
+	bf.f = f
+	return nil
+}
+
+// write 把条目写入正在写入的分段，不刷盘
+func (bf *binlogFile) write(entry BinlogEntry) error {
+	if bf.shouldRotate(entry.Timestamp) {
+		if err := bf.rotate(); err != nil {
+			return err
+		}
+	}
+	data, err := bf.active().encode(entry)
+	if err != nil {
+		return fmt.Errorf("failed to serialize binlog entry: %w", err)
+	}
+
+	bf.fileMu.Lock()
+	defer bf.fileMu.Unlock()
+	if _, err := bf.f.Write(data); err != nil {
+		return fmt.Errorf("failed to write binlog file: %w", err)
+	}
+
+	seg := bf.active()
+	if seg.entries == 0 {
+		seg.firstID = entry.ID
+		seg.createdAt = entry.Timestamp
+	}
+	seg.lastID = entry.ID
+	seg.entries++
+	seg.size += int64(len(data))
+	return nil
+}
+
+// purge 删除最后一个条目不超过position的分段（正在写入的分段除外），返回被删除的分段及其中最大的条目ID
+func (bf *binlogFile) purge(position uint64) ([]SegmentInfo, uint64, error) {
+	var purged []SegmentInfo
+	var purgedTo uint64
+	for len(bf.segments) > 1 && bf.segments[0].lastID <= position {
+		seg := bf.segments[0]
+		if err := os.Remove(seg.path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return purged, purgedTo, fmt.Errorf("failed to remove binlog segment: %w", err)
+		}
+		purged = append(purged, seg.info(false))
+		if seg.lastID > purgedTo {
+			purgedTo = seg.lastID
+		}
+		bf.segments = bf.segments[1:]
+	}
+	return purged, purgedTo, nil
+}
+
+// segmentInfos 返回所有分段的信息
+func (bf *binlogFile) segmentInfos() []SegmentInfo {
+	infos := make([]SegmentInfo, 0, len(bf.segments))
+	for i, seg := range bf.segments {
+		infos = append(infos, seg.info(i == len(bf.segments)-1))
+	}
+	return infos
+}
+
+// syncLoop interval策略下定期fsync
+func (bf *binlogFile) syncLoop() {
+	defer bf.wg.Done()
+
+	ticker := time.NewTicker(bf.opts.SyncInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-bf.stopChan:
+			return
+		case <-ticker.C:
+			if bf.dirty.Swap(false) {
+				bf.fileMu.Lock()
+				err := bf.f.Sync()
+				bf.fileMu.Unlock()
+				if err != nil {
+					log.Printf("Warning: failed to sync binlog file: %v", err)
+					bf.dirty.Store(true)
+				}
+			}
+		}
+	}
+}
+
+// close 停止后台刷盘，fsync后关闭正在写入的分段
+func (bf *binlogFile) close() error {
+	close(bf.stopChan)
+	bf.wg.Wait()
+
+	bf.fileMu.Lock()
+	defer bf.fileMu.Unlock()
+	if err := bf.f.Sync(); err != nil {
+		bf.f.Close()
+		return fmt.Errorf("failed to sync binlog file: %w", err)
+	}
+	return bf.f.Close()
+}
+
+// Close 关闭binlog文件并结束所有推送流（只在内存中的binlog没有文件需要关闭）
+// 之后的追加返回 ErrBinlogClosed；已排队等待组提交、尚未写入的条目同样以 ErrBinlogClosed 失败，不会只加入内存
+func (b *Binlog) Close() error {
+	b.closeOnce.Do(func() { close(b.closed) })
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.file == nil {
+		return nil
+	}
+	err := b.file.close()
+	b.file = nil
+	return err
+}
@@ -0,0 +1,556 @@
+package replication
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"master-slave-sync/internal/mysqlbinlog"
+	"master-slave-sync/internal/storage"
+)
+
+// binlog条目的来源
+const (
+	BinlogSourceInternal = "internal" // API写入提交时生成条目（默认）
+	BinlogSourceMySQL    = "mysql"    // 以副本身份读取MySQL自身的binlog，把已注册表的行事件转换为条目
+)
+
+// 跟随MySQL binlog相关参数
+const (
+	tailStateName       = "mysql"         // 跟随位置的记录名
+	defaultTailServerID = 1001            // 注册为MySQL副本时的默认服务器ID
+	tailHeartbeat       = 5 * time.Second // 要求MySQL空闲时发送心跳的间隔
+	tailRetryInterval   = 5 * time.Second // 连接断开或出错后重新连接的间隔
+	tailColumnsQuery    = "SELECT COLUMN_NAME FROM information_schema.COLUMNS WHERE TABLE_SCHEMA = ? AND TABLE_NAME = ? ORDER BY ORDINAL_POSITION"
+)
+
+// ddlTablePattern 从DDL语句中取出表名（可以带库名），支持 CREATE/ALTER/DROP/TRUNCATE/RENAME TABLE 和 CREATE/DROP INDEX ... ON
+var ddlTablePattern = regexp.MustCompile("(?is)^\\s*(?:CREATE|ALTER|DROP|TRUNCATE|RENAME)\\s+" +
+	"(?:TEMPORARY\\s+)?(?:TABLE|(?:UNIQUE\\s+|FULLTEXT\\s+|SPATIAL\\s+)?INDEX\\s+`?\\w+`?\\s+ON)\\s+" +
+	"(?:IF\\s+(?:NOT\\s+)?EXISTS\\s+)?(?:`?(\\w+)`?\\s*\\.\\s*)?`?(\\w+)`?")
+
+// ParseBinlogSource 解析binlog条目的来源，空字符串表示默认的 internal
+func ParseBinlogSource(name string) (string, error) {
+	switch strings.ToLower(name) {
+	case "", BinlogSourceInternal:
+		return BinlogSourceInternal, nil
+	case BinlogSourceMySQL:
+		return BinlogSourceMySQL, nil
+	default:
+		return "", fmt.Errorf("unknown binlog source %q (expected %q or %q)", name, BinlogSourceInternal, BinlogSourceMySQL)
+	}
+}
+
+// TailStatus 跟随MySQL binlog的状态（GET /api/binlog/tail）
+type TailStatus struct {
+	Enabled        bool                  `json:"enabled"`
+	Connected      bool                  `json:"connected"`                 // 是否正在读取MySQL的binlog
+	ServerID       uint32                `json:"server_id,omitempty"`       // 注册为MySQL副本时使用的服务器ID
+	Position       mysqlbinlog.Position  `json:"position"`                  // 已转换并追加到binlog的MySQL binlog位置
+	BinlogPosition uint64                `json:"binlog_position"`           // 最近转换的条目在本节点binlog中的位置
+	Transactions   int64                 `json:"transactions"`              // 启动以来转换的事务数（包括没有产生条目的事务）
+	Entries        int64                 `json:"entries"`                   // 启动以来转换得到的条目数
+	Reconnects     int64                 `json:"reconnects"`                // 出错后重新连接的次数
+	LastError      string                `json:"last_error,omitempty"`      // 最近一次出错的原因，之后重新连接成功时清除
+	LastEventAt    *time.Time            `json:"last_event_at,omitempty"`   // 最近一个已转换事务在MySQL上提交的时间
+	Source         *mysqlbinlog.Position `json:"source_head,omitempty"`     // 查询状态时MySQL当前的binlog位置
+	BehindBytes    int64                 `json:"behind_bytes,omitempty"`    // 同一文件内落后MySQL当前位置的字节数
+	SourceError    string                `json:"source_error,omitempty"`    // 查询MySQL当前位置失败的原因
+	UnsupportedDDL int64                 `json:"unsupported_ddl,omitempty"` // 涉及已注册表但无法解析表名而忽略的DDL数
+}
+
+// binlogTailer 以副本身份读取MySQL binlog的后台任务
+type binlogTailer struct {
+	cfg    mysqlbinlog.Config
+	schema string // 只转换该库中已注册表的事件
+	cancel context.CancelFunc
+	done   chan struct{}
+
+	mu       sync.Mutex // 保护以下字段
+	status   TailStatus
+	conn     *mysqlbinlog.Conn   // 当前连接，停止时关闭以打断阻塞的读取
+	advanced chan struct{}       // 跟随位置前进时关闭并替换，唤醒等待者
+	columns  map[string][]string // 表名 -> 列名（MySQL没有在表映射事件中发送列名时从information_schema读取）
+}
+
+// startTail 启动跟随MySQL binlog的后台任务：从上次保存的位置（没有时从MySQL当前位置）开始读取，
+// 每个事务中已注册表的行变更转换为条目，整个事务追加到binlog后保存位置；出错时重新连接并从保存的位置继续，
+// 追加后、保存位置前中断的事务会再次追加（至少一次），从节点应用条目是幂等的
+func (m *Master) startTail() error {
+	if m.binlogFormat != BinlogFormatRow {
+		return fmt.Errorf("binlog_source %s requires binlog_format %s", BinlogSourceMySQL, BinlogFormatRow)
+	}
+	serverID := m.config.TailServerID
+	if serverID == 0 {
+		serverID = defaultTailServerID
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	t := &binlogTailer{
+		cfg: mysqlbinlog.Config{
+			Addr:     fmt.Sprintf("%s:%d", m.config.Host, m.config.Port),
+			User:     m.config.User,
+			Password: m.config.Password,
+			ServerID: serverID,
+		},
+		schema:   m.config.DBName,
+		cancel:   cancel,
+		done:     make(chan struct{}),
+		status:   TailStatus{Enabled: true, ServerID: serverID},
+		advanced: make(chan struct{}),
+		columns:  make(map[string][]string),
+	}
+	m.mu.Lock()
+	m.tail = t
+	m.mu.Unlock()
+
+	go m.runTail(ctx, t)
+	log.Printf("Tailing MySQL binlog of %s as replica server %d", t.cfg.Addr, serverID)
+	return nil
+}
+
+// stopTail 停止跟随MySQL binlog，等待正在转换的事务结束
+func (m *Master) stopTail() {
+	m.mu.Lock()
+	t := m.tail
+	m.mu.Unlock()
+	if t == nil {
+		return
+	}
+	t.cancel()
+	t.mu.Lock()
+	if t.conn != nil {
+		t.conn.Close()
+	}
+	t.mu.Unlock()
+	<-t.done
+}
+
+// binlogSource binlog条目的来源（调用方持有m.mu）
+func (m *Master) binlogSource() string {
+	if m.tail != nil {
+		return BinlogSourceMySQL
+	}
+	return BinlogSourceInternal
+}
+
+// BinlogTailStatus 跟随MySQL binlog的状态，未启用时 Enabled 为false
+func (m *Master) BinlogTailStatus() TailStatus {
+	m.mu.RLock()
+	t := m.tail
+	m.mu.RUnlock()
+	if t == nil {
+		return TailStatus{}
+	}
+	status := t.snapshot()
+	file, pos, err := m.db.BinlogCoordinates()
+	if err != nil {
+		status.SourceError = err.Error()
+		return status
+	}
+	status.Source = &mysqlbinlog.Position{File: file, Pos: pos}
+	if file == status.Position.File && pos > status.Position.Pos {
+		status.BehindBytes = int64(pos - status.Position.Pos)
+	}
+	return status
+}
+
+// runTail 读取循环，出错后按固定间隔重新连接，停止时结束
+func (m *Master) runTail(ctx context.Context, t *binlogTailer) {
+	defer close(t.done)
+	for {
+		err := m.tailOnce(ctx, t)
+		if ctx.Err() != nil {
+			return
+		}
+		t.failed(err)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(tailRetryInterval):
+		}
+	}
+}
+
+// tailOnce 连接MySQL并读取binlog，直到出错
+func (m *Master) tailOnce(ctx context.Context, t *binlogTailer) error {
+	conn, err := mysqlbinlog.Dial(t.cfg)
+	if err != nil {
+		return err
+	}
+	t.mu.Lock()
+	t.conn = conn
+	t.mu.Unlock()
+	defer conn.Close()
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+
+	if row, err := conn.QueryRow("SELECT @@global.binlog_format"); err != nil {
+		return fmt.Errorf("failed to read mysql binlog_format: %w", err)
+	} else if len(row) == 0 || !strings.EqualFold(row[0], "ROW") {
+		return fmt.Errorf("mysql binlog_format must be ROW to tail row changes, got %v", row)
+	}
+
+	from, err := m.tailStart()
+	if err != nil {
+		return err
+	}
+	streamer, err := conn.Dump(t.cfg.ServerID, from, tailHeartbeat)
+	if err != nil {
+		return err
+	}
+	t.connected(from)
+
+	var pending []BinlogEntry
+	inTx := false
+	for {
+		event, err := streamer.Next()
+		if err != nil {
+			return fmt.Errorf("failed to read mysql binlog at %s: %w", streamer.Position(), err)
+		}
+		switch {
+		case event.Rows != nil:
+			entries, err := m.rowEntries(t, event.Rows)
+			if err != nil {
+				return err
+			}
+			pending = append(pending, entries...)
+
+		case event.XID != 0:
+			if err := m.commitTail(t, pending, event); err != nil {
+				return err
+			}
+			pending, inTx = nil, false
+
+		case event.Query != nil:
+			sql := strings.TrimSpace(event.Query.SQL)
+			switch {
+			case strings.EqualFold(sql, "BEGIN"):
+				pending, inTx = nil, true
+			case strings.EqualFold(sql, "COMMIT"):
+				// 非事务引擎的事务以COMMIT语句而不是XID事件结束
+				if err := m.commitTail(t, pending, event); err != nil {
+					return err
+				}
+				pending, inTx = nil, false
+			case strings.EqualFold(sql, "ROLLBACK"):
+				pending, inTx = nil, false
+			default:
+				// DDL在MySQL中隐式提交，单独成为一个事务
+				entries := m.ddlEntries(t, event.Query)
+				if err := m.commitTail(t, entries, event); err != nil {
+					return err
+				}
+			}
+
+		default:
+			// 事务之外的其他事件（轮换、GTID等）不产生条目，只推进位置
+			if !inTx {
+				t.advance(event.Position, 0, 0)
+			}
+		}
+	}
+}
+
+// tailStart 读取开始位置：上次保存的位置，没有时为MySQL当前的位置（之前的历史不转换）
+func (m *Master) tailStart() (mysqlbinlog.Position, error) {
+	file, pos, err := m.db.LoadTailState(tailStateName)
+	if err != nil {
+		return mysqlbinlog.Position{}, err
+	}
+	if file != "" {
+		return mysqlbinlog.Position{File: file, Pos: pos}, nil
+	}
+	file, pos, err = m.db.BinlogCoordinates()
+	if err != nil {
+		return mysqlbinlog.Position{}, err
+	}
+	log.Printf("No saved binlog tail position, starting from current mysql position %s:%d", file, pos)
+	return mysqlbinlog.Position{File: file, Pos: pos}, nil
+}
+
+// commitTail 把一个事务转换得到的条目追加到binlog并保存跟随位置
+func (m *Master) commitTail(t *binlogTailer, entries []BinlogEntry, event *mysqlbinlog.Event) error {
+	var last uint64
+	for _, entry := range entries {
+		pos, err := m.binlog.appendWrite(entry)
+		if err != nil {
+			return fmt.Errorf("failed to append binlog: %w", err)
+		}
+		last = pos
+	}
+	if err := m.db.SaveTailState(tailStateName, event.Position.File, event.Position.Pos); err != nil {
+		return err
+	}
+	t.committed(event, last, len(entries))
+	return nil
+}
+
+// rowEntries 把一个行事件转换为条目：只转换本节点数据库中已注册的表，
+// 插入和更新记录变更后的行，删除记录删除前的行；更新改变了主键时转换为删除原行和插入新行
+func (m *Master) rowEntries(t *binlogTailer, event *mysqlbinlog.RowsEvent) ([]BinlogEntry, error) {
+	if event.Table.Schema != t.schema {
+		return nil, nil
+	}
+	table, err := LookupTable(event.Table.Table)
+	if err != nil {
+		return nil, nil
+	}
+	ct, ok := table.(ColumnTable)
+	if !ok {
+		return nil, fmt.Errorf("table %s cannot be built from binlog rows, it must implement ColumnTable", table.Name())
+	}
+	columns, err := m.tailColumns(t, event.Table)
+	if err != nil {
+		return nil, err
+	}
+
+	encode := func(operation string, values []interface{}) (BinlogEntry, error) {
+		named := make(map[string]interface{}, len(columns))
+		for i, value := range values {
+			if value != nil {
+				named[columns[i]] = value
+			}
+		}
+		row, err := ct.FromColumns(named)
+		if err != nil {
+			return BinlogEntry{}, err
+		}
+		id, data, err := table.Encode(row)
+		if err != nil {
+			return BinlogEntry{}, err
+		}
+		return BinlogEntry{
+			Operation: operation,
+			ServerID:  m.config.ServerID,
+			TableName: table.Name(),
+			RecordID:  id,
+			Data:      data,
+		}, nil
+	}
+
+	var entries []BinlogEntry
+	add := func(operation string, values []interface{}) error {
+		entry, err := encode(operation, values)
+		if err == nil {
+			entries = append(entries, entry)
+		}
+		return err
+	}
+	switch event.Action {
+	case mysqlbinlog.RowsInsert:
+		for _, values := range event.Rows {
+			if err := add(OpInsert, values); err != nil {
+				return nil, err
+			}
+		}
+	case mysqlbinlog.RowsDelete:
+		for _, values := range event.Rows {
+			if err := add(OpDelete, values); err != nil {
+				return nil, err
+			}
+		}
+	case mysqlbinlog.RowsUpdate:
+		for i := 0; i+1 < len(event.Rows); i += 2 {
+			before, err := encode(OpDelete, event.Rows[i])
+			if err != nil {
+				return nil, err
+			}
+			after, err := encode(OpUpdate, event.Rows[i+1])
+			if err != nil {
+				return nil, err
+			}
+			if before.RecordID != after.RecordID {
+				after.Operation = OpInsert
+				entries = append(entries, before)
+			} else {
+				after.Before = before.Data
+			}
+			entries = append(entries, after)
+		}
+	}
+	return entries, nil
+}
+
+// tailColumns 表的列名：优先使用表映射事件中的列名（binlog_row_metadata=FULL），
+// 否则从information_schema读取并缓存；列数与事件不一致（表结构已变更）时重新读取一次
+func (m *Master) tailColumns(t *binlogTailer, table *mysqlbinlog.TableMap) ([]string, error) {
+	if len(table.Columns) == len(table.Types) {
+		return table.Columns, nil
+	}
+	t.mu.Lock()
+	columns := t.columns[table.Table]
+	t.mu.Unlock()
+	if len(columns) == len(table.Types) {
+		return columns, nil
+	}
+
+	columns = nil
+	err := m.db.GetConnection().Raw(tailColumnsQuery, table.Schema, table.Table).Scan(&columns).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to read columns of %s: %w", table.Table, err)
+	}
+	if len(columns) != len(table.Types) {
+		return nil, fmt.Errorf("table %s has %d columns but the binlog event has %d, the table changed after the event was written; "+
+			"set binlog_row_metadata=FULL on mysql to include column names in the binlog", table.Table, len(columns), len(table.Types))
+	}
+	t.mu.Lock()
+	t.columns[table.Table] = columns
+	t.mu.Unlock()
+	return columns, nil
+}
+
+// ddlEntries 把涉及本节点数据库中已注册表的DDL语句转换为DDL条目，其他语句忽略
+func (m *Master) ddlEntries(t *binlogTailer, query *mysqlbinlog.QueryEvent) []BinlogEntry {
+	stmt, err := normalizeDDL(query.SQL)
+	if err != nil {
+		return nil
+	}
+	match := ddlTablePattern.FindStringSubmatch(stmt)
+	if match == nil {
+		t.mu.Lock()
+		t.status.UnsupportedDDL++
+		t.mu.Unlock()
+		log.Printf("Warning: ignoring DDL from mysql binlog, table name not recognized: %s", stmt)
+		return nil
+	}
+	schema, name := match[1], match[2]
+	if schema == "" {
+		schema = query.Schema
+	}
+	if schema != t.schema {
+		return nil
+	}
+	if _, err := LookupTable(name); err != nil {
+		return nil
+	}
+	// 表结构变更后重新读取列名
+	t.mu.Lock()
+	delete(t.columns, name)
+	t.mu.Unlock()
+
+	data, err := json.Marshal(DDLEvent{SQL: stmt})
+	if err != nil {
+		return nil
+	}
+	log.Printf("DDL on %s from mysql binlog: %s", name, stmt)
+	return []BinlogEntry{{
+		Operation: OpDDL,
+		ServerID:  m.config.ServerID,
+		TableName: name,
+		Data:      data,
+	}}
+}
+
+// commitTailed 跟随MySQL binlog时的写入：直接在数据库中提交，条目由跟随任务从MySQL的binlog中得到；
+// 需要等待从节点确认时先等跟随任务转换到这次提交在MySQL binlog中的位置，再由 finishWrite 等待对应条目的确认
+// （相当于 after_commit，after_sync 无法在这种模式下实现）
+func (m *Master) commitTailed(durability Durability, write func(tx *storage.DB) (interface{}, error)) (interface{}, uint64, *ackWait, error) {
+	var row interface{}
+	err := m.db.Transaction(func(tx *storage.DB) error {
+		var err error
+		row, err = write(tx)
+		return err
+	})
+	if err != nil {
+		return nil, 0, nil, err
+	}
+	// 前映像由MySQL的行事件提供
+	if update, ok := row.(RowUpdate); ok {
+		row = update.After
+	}
+	if durability == DurabilityLocal {
+		return row, m.binlog.GetCurrentPosition(), nil, nil
+	}
+	pos, err := m.waitTailed()
+	if err != nil {
+		return row, pos, &ackWait{status: StatusTimeout, err: err}, nil
+	}
+	return row, pos, nil, nil
+}
+
+// waitTailed 等待跟随任务转换到MySQL当前的binlog位置（在写入提交之后调用），返回此时binlog的位置；
+// 最多等待半同步超时的时长
+func (m *Master) waitTailed() (uint64, error) {
+	file, pos, err := m.db.BinlogCoordinates()
+	if err != nil {
+		return m.binlog.GetCurrentPosition(), err
+	}
+	target := mysqlbinlog.Position{File: file, Pos: pos}
+	timeout := time.NewTimer(time.Duration(m.semiSync.config.TimeoutMs) * time.Millisecond)
+	defer timeout.Stop()
+	for {
+		m.tail.mu.Lock()
+		reached := m.tail.status.Position.Compare(target) >= 0
+		advanced := m.tail.advanced
+		m.tail.mu.Unlock()
+		if reached {
+			return m.binlog.GetCurrentPosition(), nil
+		}
+		select {
+		case <-advanced:
+		case <-timeout.C:
+			return m.binlog.GetCurrentPosition(), fmt.Errorf("binlog tailer did not reach mysql position %s within %d ms", target, m.semiSync.config.TimeoutMs)
+		}
+	}
+}
+
+// snapshot 跟随状态的副本
+func (t *binlogTailer) snapshot() TailStatus {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.status
+}
+
+// connected 记录开始读取
+func (t *binlogTailer) connected(from mysqlbinlog.Position) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.status.LastError != "" {
+		log.Printf("Binlog tailer reconnected to mysql at %s", from)
+	}
+	t.status.Connected = true
+	t.status.LastError = ""
+	t.status.Position = from
+}
+
+// committed 记录一个已转换的事务
+func (t *binlogTailer) committed(event *mysqlbinlog.Event, pos uint64, entries int) {
+	at := event.Timestamp
+	t.mu.Lock()
+	t.status.Transactions++
+	t.status.Entries += int64(entries)
+	t.status.LastEventAt = &at
+	t.mu.Unlock()
+	t.advance(event.Position, pos, entries)
+}
+
+// advance 推进跟随位置并唤醒等待者
+func (t *binlogTailer) advance(position mysqlbinlog.Position, pos uint64, entries int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.status.Position = position
+	if entries > 0 {
+		t.status.BinlogPosition = pos
+	}
+	close(t.advanced)
+	t.advanced = make(chan struct{})
+}
+
+// failed 记录一次出错，只在连续出错的第一次输出日志
+func (t *binlogTailer) failed(err error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.status.LastError == "" {
+		log.Printf("Binlog tailer: %v, reconnecting every %v", err, tailRetryInterval)
+	}
+	t.status.Connected = false
+	t.status.Reconnects++
+	t.status.LastError = err.Error()
+	t.conn = nil
+}
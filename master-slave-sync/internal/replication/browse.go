@@ -0,0 +1,280 @@
+package replication
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"master-slave-sync/internal/storage"
+)
+
+// 浏览binlog的分页参数
+const (
+	DefaultBrowseLimit = 100  // 未指定条数时每页返回的条目数
+	MaxBrowseLimit     = 1000 // 每页最多返回的条目数
+	browseValueWidth   = 64   // 可读形式中字符串值的最大长度，超出部分省略
+)
+
+// BrowseQuery 浏览binlog的筛选条件，零值字段表示不按该条件筛选
+type BrowseQuery struct {
+	After      uint64    // 只返回ID大于该位置的条目（分页游标）
+	From       time.Time // 写入时间不早于From
+	To         time.Time // 写入时间不晚于To
+	Operations []string  // 操作类型（INSERT、UPDATE、DELETE、DDL），不区分大小写
+	Table      string    // 表名
+	RecordID   uint      // 被操作记录的ID
+	Limit      int       // 每页的条目数，0表示 DefaultBrowseLimit，超过 MaxBrowseLimit 时按 MaxBrowseLimit
+}
+
+// BrowsedEntry 浏览结果中的一个条目：元数据、可读形式和原始内容
+type BrowsedEntry struct {
+	ID        uint64          `json:"id"`
+	Timestamp time.Time       `json:"timestamp"`
+	Operation string          `json:"operation"`
+	Format    string          `json:"format"` // row、statement 或 ddl
+	ServerID  uint32          `json:"server_id,omitempty"`
+	Epoch     uint64          `json:"epoch,omitempty"`
+	TableName string          `json:"table_name"`
+	RecordID  uint            `json:"record_id,omitempty"`
+	SizeBytes int             `json:"size_bytes"`       // 条目内容（data）的大小
+	Summary   string          `json:"summary"`          // 类似 mysqlbinlog -v 的可读形式
+	Data      json.RawMessage `json:"data,omitempty"`   // 条目内容（行数据、语句或DDL）
+	Before    json.RawMessage `json:"before,omitempty"` // UPDATE之前的行（前映像）
+}
+
+// BrowseResult 一页浏览结果
+type BrowseResult struct {
+	Entries         []BrowsedEntry `json:"entries"`
+	Scanned         int            `json:"scanned"`              // 本页检查的条目数
+	NextAfter       uint64         `json:"next_after,omitempty"` // 达到每页条数时下一页的 after 参数，为0表示没有更多条目
+	OldestPosition  uint64         `json:"oldest_position"`      // 最早可用的条目ID，更早的条目已被清理
+	CurrentPosition uint64         `json:"current_position"`
+}
+
+// BrowseBinlog 按时间范围、操作类型、表和记录ID筛选binlog条目，按ID从小到大分页返回
+func (m *Master) BrowseBinlog(query BrowseQuery) BrowseResult {
+	return m.binlog.browse(query)
+}
+
+// browse 在可用的条目中按条件筛选
+func (b *Binlog) browse(query BrowseQuery) BrowseResult {
+	limit := query.Limit
+	if limit <= 0 {
+		limit = DefaultBrowseLimit
+	}
+	limit = min(limit, MaxBrowseLimit)
+
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	result := BrowseResult{
+		Entries:         []BrowsedEntry{},
+		OldestPosition:  b.oldestPosition(),
+		CurrentPosition: b.position,
+	}
+	// 条目ID连续，直接定位到游标之后的第一个条目
+	start := 0
+	if len(b.entries) > 0 && query.After >= b.entries[0].ID {
+		start = int(min(query.After+1-b.entries[0].ID, uint64(len(b.entries))))
+	}
+	for _, entry := range b.entries[start:] {
+		result.Scanned++
+		if !query.matches(entry) {
+			continue
+		}
+		result.Entries = append(result.Entries, browseEntry(entry))
+		if len(result.Entries) == limit {
+			if entry.ID < b.position {
+				result.NextAfter = entry.ID
+			}
+			break
+		}
+	}
+	return result
+}
+
+// matches 条目是否满足筛选条件
+func (q BrowseQuery) matches(entry BinlogEntry) bool {
+	if !q.From.IsZero() && entry.Timestamp.Before(q.From) {
+		return false
+	}
+	if !q.To.IsZero() && entry.Timestamp.After(q.To) {
+		return false
+	}
+	if q.Table != "" && entry.TableName != q.Table {
+		return false
+	}
+	if q.RecordID != 0 && entry.RecordID != q.RecordID {
+		return false
+	}
+	if len(q.Operations) == 0 {
+		return true
+	}
+	for _, op := range q.Operations {
+		if strings.EqualFold(op, entry.Operation) {
+			return true
+		}
+	}
+	return false
+}
+
+// browseEntry 转换为浏览结果中的条目
+func browseEntry(entry BinlogEntry) BrowsedEntry {
+	browsed := BrowsedEntry{
+		ID:        entry.ID,
+		Timestamp: entry.Timestamp,
+		Operation: entry.Operation,
+		Format:    entryFormat(entry),
+		ServerID:  entry.ServerID,
+		Epoch:     entry.Epoch,
+		TableName: entry.TableName,
+		RecordID:  entry.RecordID,
+		SizeBytes: len(entry.Data),
+		Summary:   RenderEntry(entry),
+	}
+	if json.Valid(entry.Data) {
+		browsed.Data = json.RawMessage(entry.Data)
+	}
+	if len(entry.Before) > 0 && json.Valid(entry.Before) {
+		browsed.Before = json.RawMessage(entry.Before)
+	}
+	return browsed
+}
+
+// entryFormat 条目内容的格式
+func entryFormat(entry BinlogEntry) string {
+	switch {
+	case entry.Operation == OpDDL:
+		return "ddl"
+	case entry.Format == BinlogFormatStatement:
+		return BinlogFormatStatement
+	default:
+		return BinlogFormatRow
+	}
+}
+
+// RenderEntry 把条目渲染为可读的伪SQL（类似 mysqlbinlog -v 对行事件的输出）：
+// 行条目渲染为 INSERT/UPDATE/DELETE 语句，基于语句的条目为执行的语句及参数，DDL条目为原语句
+func RenderEntry(entry BinlogEntry) string {
+	switch {
+	case entry.Operation == OpDDL:
+		var event DDLEvent
+		if err := json.Unmarshal(entry.Data, &event); err != nil {
+			return undecodable(entry)
+		}
+		return event.SQL
+	case entry.Format == BinlogFormatStatement:
+		statements, err := storage.DecodeStatements(entry.Data)
+		if err != nil {
+			return undecodable(entry)
+		}
+		parts := make([]string, 0, len(statements))
+		for _, stmt := range statements {
+			parts = append(parts, renderStatement(stmt))
+		}
+		return strings.Join(parts, "; ")
+	}
+
+	row, err := decodeRow(entry.Data)
+	if err != nil {
+		return undecodable(entry)
+	}
+	table := "`" + entry.TableName + "`"
+	switch entry.Operation {
+	case OpInsert:
+		return fmt.Sprintf("INSERT INTO %s SET %s", table, renderAssignments(row))
+	case OpUpdate:
+		// 有前映像时只列出变化的列
+		if before, err := decodeRow(entry.Before); err == nil {
+			for column, value := range before {
+				if fmt.Sprint(row[column]) == fmt.Sprint(value) {
+					delete(row, column)
+				}
+			}
+		}
+		return fmt.Sprintf("UPDATE %s SET %s WHERE id=%d", table, renderAssignments(row), entry.RecordID)
+	case OpDelete:
+		return fmt.Sprintf("DELETE FROM %s WHERE id=%d", table, entry.RecordID)
+	default:
+		return fmt.Sprintf("%s %s id=%d", entry.Operation, table, entry.RecordID)
+	}
+}
+
+// undecodable 条目内容无法解析时的可读形式
+func undecodable(entry BinlogEntry) string {
+	return fmt.Sprintf("%s `%s` id=%d (%d bytes, undecodable)", entry.Operation, entry.TableName, entry.RecordID, len(entry.Data))
+}
+
+// decodeRow 解析行数据，数值保留原样
+func decodeRow(data []byte) (map[string]interface{}, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var row map[string]interface{}
+	if err := decoder.Decode(&row); err != nil {
+		return nil, err
+	}
+	return row, nil
+}
+
+// renderAssignments 按列名排序渲染 `col`=value 列表
+func renderAssignments(row map[string]interface{}) string {
+	columns := make([]string, 0, len(row))
+	for column := range row {
+		columns = append(columns, column)
+	}
+	sort.Strings(columns)
+
+	parts := make([]string, 0, len(columns))
+	for _, column := range columns {
+		parts = append(parts, fmt.Sprintf("`%s`=%s", column, renderValue(row[column])))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// renderStatement 渲染一条语句及其参数
+func renderStatement(stmt storage.Statement) string {
+	if len(stmt.Args) == 0 {
+		return stmt.SQL
+	}
+	args := make([]string, 0, len(stmt.Args))
+	for _, arg := range stmt.Args {
+		switch {
+		case arg.Time != nil:
+			args = append(args, renderValue(arg.Time.Format(time.RFC3339Nano)))
+		case arg.Bytes != nil:
+			args = append(args, fmt.Sprintf("<%d bytes>", len(arg.Bytes)))
+		default:
+			args = append(args, renderValue(arg.Value))
+		}
+	}
+	return fmt.Sprintf("%s /* args: %s */", stmt.SQL, strings.Join(args, ", "))
+}
+
+// renderValue 以SQL字面量的形式渲染值，过长的字符串省略中间部分
+func renderValue(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return "NULL"
+	case bool:
+		if v {
+			return "TRUE"
+		}
+		return "FALSE"
+	case json.Number:
+		return v.String()
+	case string:
+		if runes := []rune(v); len(runes) > browseValueWidth {
+			v = string(runes[:browseValueWidth]) + "..."
+		}
+		return "'" + strings.ReplaceAll(v, "'", "''") + "'"
+	default:
+		data, err := json.Marshal(v)
+		if err != nil {
+			return fmt.Sprintf("'%v'", v)
+		}
+		return renderValue(string(data))
+	}
+}
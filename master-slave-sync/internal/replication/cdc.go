@@ -0,0 +1,477 @@
+package replication
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"master-slave-sync/internal/config"
+)
+
+// 变更订阅（CDC）相关参数
+const (
+	cdcBatchSize      = 100              // 每次推送给Webhook订阅者的最大事件数
+	cdcTimeout        = 5 * time.Second  // Webhook请求超时
+	cdcInitialBackoff = time.Second      // 推送失败后的首次重试间隔
+	cdcMaxBackoff     = 30 * time.Second // 推送失败后的最大重试间隔
+	SignatureHeader   = "X-CDC-Signature"
+)
+
+// 变更订阅相关的错误
+var (
+	ErrSubscriberExists   = errors.New("subscriber already exists")
+	ErrSubscriberNotFound = errors.New("subscriber not found")
+)
+
+// Subscribe 订阅之后追加的binlog条目：fn在单独的goroutine中按ID顺序逐个调用，不阻塞写入，
+// 调用较慢时条目在binlog中等待，不会丢失（除非期间被清理）。返回的函数取消订阅，binlog关闭时订阅自动结束
+func (b *Binlog) Subscribe(fn func(BinlogEntry)) (cancel func()) {
+	return b.SubscribeFrom(b.GetCurrentPosition(), fn)
+}
+
+// SubscribeFrom 从position之后的条目开始订阅，见 Subscribe
+func (b *Binlog) SubscribeFrom(position uint64, fn func(BinlogEntry)) (cancel func()) {
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		err := b.follow(ctx, position, func(entries []BinlogEntry) (uint64, error) {
+			for _, entry := range entries {
+				if ctx.Err() != nil {
+					break
+				}
+				fn(entry)
+			}
+			return entries[len(entries)-1].ID, nil
+		})
+		if err != nil {
+			log.Printf("Binlog subscription from position %d ended: %v", position, err)
+		}
+	}()
+	return cancel
+}
+
+// follow 依次把position之后的条目交给deliver，deliver返回已处理到的位置；没有新条目时等待追加
+// ctx取消或binlog关闭时返回nil，position之后的条目已被清理时返回 *PositionPurgedError，deliver出错时返回其错误
+func (b *Binlog) follow(ctx context.Context, position uint64, deliver func([]BinlogEntry) (uint64, error)) error {
+	for {
+		changed, closed := b.notifications()
+		entries, err := b.EntriesAfter(position)
+		if err != nil {
+			return err
+		}
+		if len(entries) > 0 {
+			if position, err = deliver(entries); err != nil {
+				return err
+			}
+			continue
+		}
+
+		select {
+		case <-changed:
+		case <-closed:
+			return nil
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+// Subscribe 订阅主节点之后写入的变更，见 Binlog.Subscribe
+func (m *Master) Subscribe(fn func(BinlogEntry)) (cancel func()) {
+	return m.binlog.Subscribe(fn)
+}
+
+// ChangeEvent 推送给Webhook订阅者的变更事件
+type ChangeEvent struct {
+	Position  uint64          `json:"position"` // binlog条目ID，订阅者可以据此去重
+	Timestamp time.Time       `json:"timestamp"`
+	Operation string          `json:"operation"` // INSERT、UPDATE、DELETE 或 DDL
+	Format    string          `json:"format"`    // row、statement 或 ddl
+	ServerID  uint32          `json:"server_id,omitempty"`
+	Epoch     uint64          `json:"epoch,omitempty"` // 主节点的纪元，故障切换后递增
+	Table     string          `json:"table"`
+	RecordID  uint            `json:"record_id,omitempty"`
+	Data      json.RawMessage `json:"data,omitempty"`   // 行数据（基于行）、语句（基于语句）或DDL
+	Before    json.RawMessage `json:"before,omitempty"` // UPDATE之前的行（前映像），未记录时为空
+	Summary   string          `json:"summary"`          // 可读形式，见 RenderEntry
+}
+
+// ChangeBatch 一次Webhook请求的请求体
+type ChangeBatch struct {
+	SubscriberID string        `json:"subscriber_id"`
+	Events       []ChangeEvent `json:"events"`
+}
+
+// SubscriberRequest 注册Webhook订阅者的请求
+type SubscriberRequest struct {
+	ID     string                    `json:"id"`
+	URL    string                    `json:"url"`
+	Filter *config.ReplicationFilter `json:"filter,omitempty"` // 只推送匹配的条目，与从节点的复制过滤规则相同
+	// 从该位置之后开始推送，0表示从注册时的binlog位置开始（只推送之后的变更）
+	FromPosition uint64 `json:"from_position,omitempty"`
+	// 签名密钥，设置后每个请求带有 X-CDC-Signature: sha256=<请求体的HMAC-SHA256>
+	Secret string `json:"secret,omitempty"`
+}
+
+// SubscriberStatus Webhook订阅者的状态
+type SubscriberStatus struct {
+	ID                  string                    `json:"id"`
+	URL                 string                    `json:"url"`
+	Filter              *config.ReplicationFilter `json:"filter,omitempty"`
+	Signed              bool                      `json:"signed"`
+	Position            uint64                    `json:"position"`             // 已推送（或按过滤规则跳过）到的位置
+	BehindEntries       uint64                    `json:"behind_entries"`       // 尚未推送的条目数
+	Delivered           int64                     `json:"delivered"`            // 已推送的事件数
+	Failures            int64                     `json:"failures"`             // 推送失败的请求数
+	ConsecutiveFailures int                       `json:"consecutive_failures"` // 连续失败的请求数，成功后清零
+	LostEntries         uint64                    `json:"lost_entries"`         // 推送前已被清理而跳过的条目数
+	LastError           string                    `json:"last_error,omitempty"`
+	LastDeliveryAt      *time.Time                `json:"last_delivery_at,omitempty"`
+	CreatedAt           time.Time                 `json:"created_at"`
+}
+
+// webhookSubscriber 一个Webhook订阅者：独立的goroutine按binlog顺序批量推送，失败时指数退避重试同一批
+type webhookSubscriber struct {
+	status SubscriberStatus
+	secret string
+	cancel context.CancelFunc
+	mu     sync.Mutex // 保护status
+}
+
+// subscriberRegistry 主节点上的Webhook订阅者
+type subscriberRegistry struct {
+	subscribers map[string]*webhookSubscriber
+	client      *http.Client
+	mu          sync.Mutex
+}
+
+// AddSubscriber 注册一个Webhook订阅者并开始推送
+func (m *Master) AddSubscriber(req SubscriberRequest) (SubscriberStatus, error) {
+	if req.ID == "" {
+		return SubscriberStatus{}, fmt.Errorf("subscriber id is required")
+	}
+	allowInternal := m.config.CDCAllowInternalTargets
+	if err := validateWebhookURL(req.URL, allowInternal); err != nil {
+		return SubscriberStatus{}, err
+	}
+	if req.Filter != nil && filterIsEmpty(req.Filter) {
+		req.Filter = nil
+	}
+
+	reg := &m.subscribers
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+	if _, exists := reg.subscribers[req.ID]; exists {
+		return SubscriberStatus{}, fmt.Errorf("%w: %s", ErrSubscriberExists, req.ID)
+	}
+	if reg.subscribers == nil {
+		reg.subscribers = make(map[string]*webhookSubscriber)
+		reg.client = newWebhookClient(allowInternal)
+	}
+
+	position := req.FromPosition
+	if position == 0 {
+		position = m.binlog.GetCurrentPosition()
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	sub := &webhookSubscriber{
+		status: SubscriberStatus{
+			ID:        req.ID,
+			URL:       req.URL,
+			Filter:    req.Filter,
+			Signed:    req.Secret != "",
+			Position:  position,
+			CreatedAt: time.Now(),
+		},
+		secret: req.Secret,
+		cancel: cancel,
+	}
+	reg.subscribers[req.ID] = sub
+	go m.runSubscriber(ctx, sub, reg.client)
+
+	log.Printf("CDC subscriber %s registered, delivering to %s from position %d", req.ID, req.URL, position)
+	return sub.snapshot(m.binlog.GetCurrentPosition()), nil
+}
+
+// validateWebhookURL 检查订阅者地址：只接受不带用户信息的http(s)地址；不允许内部地址时拒绝指向
+// localhost和回环、链路本地、未指定、组播IP的地址（域名解析后的地址在连接时由 newWebhookClient 检查）
+func validateWebhookURL(raw string, allowInternal bool) error {
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || u.Hostname() == "" {
+		return fmt.Errorf("invalid subscriber url: %q", raw)
+	}
+	if u.User != nil {
+		return fmt.Errorf("invalid subscriber url: %q: credentials in url are not allowed, use secret to sign requests", raw)
+	}
+	if allowInternal {
+		return nil
+	}
+	host := strings.ToLower(u.Hostname())
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return fmt.Errorf("invalid subscriber url: %q: internal address not allowed", raw)
+	}
+	if ip := net.ParseIP(host); ip != nil && isInternalIP(ip) {
+		return fmt.Errorf("invalid subscriber url: %q: internal address not allowed", raw)
+	}
+	return nil
+}
+
+// isInternalIP 回环、链路本地（包括云主机的元数据地址169.254.169.254）、未指定和组播地址
+func isInternalIP(ip net.IP) bool {
+	return ip.IsLoopback() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsUnspecified() || ip.IsMulticast()
+}
+
+// newWebhookClient 推送Webhook使用的HTTP客户端。不允许内部地址时在建立连接前检查解析出的IP，
+// 域名解析到内部地址或重定向到内部地址的请求同样被拒绝；不使用环境变量中的代理，保证检查的是真正的目标
+func newWebhookClient(allowInternal bool) *http.Client {
+	if allowInternal {
+		return &http.Client{Timeout: cdcTimeout}
+	}
+	dialer := &net.Dialer{
+		Timeout: cdcTimeout,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if ip := net.ParseIP(host); ip == nil || isInternalIP(ip) {
+				return fmt.Errorf("webhook target %s is an internal address", address)
+			}
+			return nil
+		},
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+	return &http.Client{Timeout: cdcTimeout, Transport: transport}
+}
+
+// RemoveSubscriber 删除Webhook订阅者并停止推送
+func (m *Master) RemoveSubscriber(id string) error {
+	reg := &m.subscribers
+	reg.mu.Lock()
+	sub, ok := reg.subscribers[id]
+	delete(reg.subscribers, id)
+	reg.mu.Unlock()
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrSubscriberNotFound, id)
+	}
+	sub.cancel()
+	log.Printf("CDC subscriber %s removed at position %d", id, sub.snapshot(0).Position)
+	return nil
+}
+
+// Subscriber 获取一个Webhook订阅者的状态
+func (m *Master) Subscriber(id string) (SubscriberStatus, error) {
+	reg := &m.subscribers
+	reg.mu.Lock()
+	sub, ok := reg.subscribers[id]
+	reg.mu.Unlock()
+	if !ok {
+		return SubscriberStatus{}, fmt.Errorf("%w: %s", ErrSubscriberNotFound, id)
+	}
+	return sub.snapshot(m.binlog.GetCurrentPosition()), nil
+}
+
+// Subscribers 所有Webhook订阅者的状态（按ID排序）
+func (m *Master) Subscribers() []SubscriberStatus {
+	reg := &m.subscribers
+	reg.mu.Lock()
+	subs := make([]*webhookSubscriber, 0, len(reg.subscribers))
+	for _, sub := range reg.subscribers {
+		subs = append(subs, sub)
+	}
+	reg.mu.Unlock()
+
+	current := m.binlog.GetCurrentPosition()
+	result := make([]SubscriberStatus, 0, len(subs))
+	for _, sub := range subs {
+		result = append(result, sub.snapshot(current))
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].ID < result[j].ID })
+	return result
+}
+
+// stopSubscribers 停止所有Webhook订阅者的推送（主节点关闭时）
+func (m *Master) stopSubscribers() {
+	reg := &m.subscribers
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+	for _, sub := range reg.subscribers {
+		sub.cancel()
+	}
+}
+
+// runSubscriber 推送循环：推送失败时按指数退避重试同一批，成功后才推进位置，保证至少推送一次且按顺序
+// 订阅者的位置已被清理时跳过被清理的条目（记入LostEntries）并继续
+func (m *Master) runSubscriber(ctx context.Context, sub *webhookSubscriber, client *http.Client) {
+	backoff := cdcInitialBackoff
+	for {
+		err := m.binlog.follow(ctx, sub.snapshot(0).Position, func(entries []BinlogEntry) (uint64, error) {
+			for len(entries) > 0 {
+				n := min(len(entries), cdcBatchSize)
+				if err := sub.deliver(ctx, client, entries[:n]); err != nil {
+					return 0, err
+				}
+				backoff = cdcInitialBackoff
+				entries = entries[n:]
+			}
+			return sub.snapshot(0).Position, nil
+		})
+
+		var purged *PositionPurgedError
+		switch {
+		case err == nil:
+			return
+		case errors.As(err, &purged):
+			sub.skipTo(purged.Oldest - 1)
+			log.Printf("Warning: CDC subscriber %s fell behind binlog retention, skipped to position %d", sub.status.ID, purged.Oldest-1)
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		backoff = min(backoff*2, cdcMaxBackoff)
+	}
+}
+
+// deliver 把一批条目中匹配过滤规则的条目推送给订阅者，成功后推进位置；整批被过滤时只推进位置
+func (sub *webhookSubscriber) deliver(ctx context.Context, client *http.Client, entries []BinlogEntry) error {
+	last := entries[len(entries)-1].ID
+	batch := ChangeBatch{SubscriberID: sub.status.ID, Events: make([]ChangeEvent, 0, len(entries))}
+	for _, entry := range entries {
+		if sub.status.Filter == nil || filterMatches(sub.status.Filter, entry) {
+			batch.Events = append(batch.Events, changeEvent(entry))
+		}
+	}
+	if len(batch.Events) == 0 {
+		sub.delivered(last, 0)
+		return nil
+	}
+
+	err := sub.post(ctx, client, batch)
+	if err != nil {
+		sub.failed(err)
+		if ctx.Err() == nil && sub.snapshot(0).ConsecutiveFailures == 1 {
+			log.Printf("CDC subscriber %s: delivery failed, retrying with backoff: %v", sub.status.ID, err)
+		}
+		return err
+	}
+	sub.delivered(last, len(batch.Events))
+	return nil
+}
+
+// post 发送一批事件，2xx视为成功
+func (sub *webhookSubscriber) post(ctx context.Context, client *http.Client, batch ChangeBatch) error {
+	body, err := json.Marshal(batch)
+	if err != nil {
+		return fmt.Errorf("failed to marshal change events: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, sub.status.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if sub.secret != "" {
+		mac := hmac.New(sha256.New, []byte(sub.secret))
+		mac.Write(body)
+		req.Header.Set(SignatureHeader, "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("subscriber returned status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// changeEvent 把binlog条目转换为变更事件
+func changeEvent(entry BinlogEntry) ChangeEvent {
+	event := ChangeEvent{
+		Position:  entry.ID,
+		Timestamp: entry.Timestamp,
+		Operation: entry.Operation,
+		Format:    entryFormat(entry),
+		ServerID:  entry.ServerID,
+		Epoch:     entry.Epoch,
+		Table:     entry.TableName,
+		RecordID:  entry.RecordID,
+		Summary:   RenderEntry(entry),
+	}
+	if json.Valid(entry.Data) {
+		event.Data = json.RawMessage(entry.Data)
+	}
+	if len(entry.Before) > 0 && json.Valid(entry.Before) {
+		event.Before = json.RawMessage(entry.Before)
+	}
+	return event
+}
+
+// snapshot 订阅者的状态副本，current为当前binlog位置（为0时不计算BehindEntries）
+func (sub *webhookSubscriber) snapshot(current uint64) SubscriberStatus {
+	sub.mu.Lock()
+	defer sub.mu.Unlock()
+	status := sub.status
+	if current > status.Position {
+		status.BehindEntries = current - status.Position
+	}
+	return status
+}
+
+// delivered 记录一次成功的推送（或整批被过滤）
+func (sub *webhookSubscriber) delivered(position uint64, events int) {
+	sub.mu.Lock()
+	defer sub.mu.Unlock()
+	sub.status.Position = position
+	if events == 0 {
+		return
+	}
+	if sub.status.ConsecutiveFailures > 0 {
+		log.Printf("CDC subscriber %s recovered after %d failed deliveries", sub.status.ID, sub.status.ConsecutiveFailures)
+	}
+	now := time.Now()
+	sub.status.Delivered += int64(events)
+	sub.status.ConsecutiveFailures = 0
+	sub.status.LastError = ""
+	sub.status.LastDeliveryAt = &now
+}
+
+// failed 记录一次失败的推送
+func (sub *webhookSubscriber) failed(err error) {
+	sub.mu.Lock()
+	defer sub.mu.Unlock()
+	sub.status.Failures++
+	sub.status.ConsecutiveFailures++
+	sub.status.LastError = err.Error()
+}
+
+// skipTo 跳过已被清理的条目
+func (sub *webhookSubscriber) skipTo(position uint64) {
+	sub.mu.Lock()
+	defer sub.mu.Unlock()
+	if position > sub.status.Position {
+		sub.status.LostEntries += position - sub.status.Position
+		sub.status.Position = position
+	}
+}
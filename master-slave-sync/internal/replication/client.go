@@ -0,0 +1,295 @@
+package replication
+
+import (
+	"bytes"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math/rand"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"master-slave-sync/internal/config"
+	"master-slave-sync/internal/netfault"
+)
+
+// ErrCircuitOpen 到主节点的熔断器处于打开状态，请求未发出
+var ErrCircuitOpen = errors.New("master circuit breaker is open")
+
+// 熔断器状态
+const (
+	circuitClosed   = "closed"    // 正常放行
+	circuitOpen     = "open"      // 拒绝请求，等待冷却
+	circuitHalfOpen = "half_open" // 冷却结束，放行一个探测请求
+)
+
+// 客户端默认参数，对应配置项为0时使用
+const (
+	defaultMasterTimeout    = 5 * time.Second
+	defaultMasterRetries    = 3
+	defaultBreakerThreshold = 5
+	defaultBreakerCooldown  = 30 * time.Second
+	retryInitialBackoff     = 200 * time.Millisecond
+	retryMaxBackoff         = 2 * time.Second
+)
+
+// masterClient 访问主节点的HTTP客户端：复用连接、请求超时、指数退避重试（带抖动）以及熔断
+// 所有请求共享同一个熔断器，并记录主节点从何时开始不可达，只在状态变化时输出日志
+type masterClient struct {
+	http      *http.Client  // 底层HTTP客户端（连接池 + 故障注入）
+	stream    *http.Client  // 建立推送流的HTTP客户端（不设整体超时，升级后的连接长期保持）
+	long      *http.Client  // 长轮询的HTTP客户端（超时时间加上长轮询的等待时间）
+	peerID    string        // 本节点标识，放在请求头中
+	token     string        // 复制令牌，为空表示不携带
+	retries   int           // 单次请求的最大尝试次数
+	threshold int           // 连续失败多少次后打开熔断器
+	cooldown  time.Duration // 熔断器打开后的冷却时间
+
+	mu               sync.Mutex // 保护以下字段
+	state            string     // 熔断器状态
+	failures         int        // 连续失败次数
+	openedAt         time.Time  // 熔断器打开时间
+	probing          bool       // 半开状态下是否已有探测请求在途
+	unreachableSince time.Time  // 主节点开始不可达的时间，可达时为零值
+	lastError        string     // 最近一次失败原因
+}
+
+// newMasterClient 根据从节点配置创建访问主节点的客户端，tlsConfig不为nil时通过TLS访问
+func newMasterClient(cfg *config.SlaveConfig, peerID string, faults *netfault.Injector, tlsConfig *tls.Config) *masterClient {
+	timeout := durationOrDefault(cfg.MasterTimeoutMs, defaultMasterTimeout)
+	retries := cfg.MasterRetries
+	if retries <= 0 {
+		retries = defaultMasterRetries
+	}
+	threshold := cfg.BreakerThreshold
+	if threshold <= 0 {
+		threshold = defaultBreakerThreshold
+	}
+
+	// 从节点只访问一个主节点，保留少量空闲长连接即可避免每次同步都重新建连
+	transport := &http.Transport{
+		Proxy: http.ProxyFromEnvironment,
+		DialContext: (&net.Dialer{
+			Timeout:   timeout,
+			KeepAlive: 30 * time.Second,
+		}).DialContext,
+		MaxIdleConns:          10,
+		MaxIdleConnsPerHost:   10,
+		IdleConnTimeout:       90 * time.Second,
+		ResponseHeaderTimeout: timeout,
+		// 设置了TLSClientConfig的Transport不会协商HTTP/2，推送流的WebSocket升级需要HTTP/1.1
+		TLSClientConfig: tlsConfig,
+	}
+
+	// 长轮询时主节点在有新条目或等待超时后才返回响应头
+	longTransport := transport.Clone()
+	longTransport.ResponseHeaderTimeout = timeout + longPollWait
+
+	return &masterClient{
+		http: &http.Client{
+			Timeout:   timeout,
+			Transport: faults.Transport("master", transport),
+		},
+		stream: &http.Client{
+			Transport: faults.Transport("master", transport),
+		},
+		long: &http.Client{
+			Timeout:   timeout + longPollWait,
+			Transport: faults.Transport("master", longTransport),
+		},
+		peerID:    peerID,
+		token:     cfg.AuthToken,
+		retries:   retries,
+		threshold: threshold,
+		cooldown:  durationOrDefault(cfg.BreakerCooldownMs, defaultBreakerCooldown),
+		state:     circuitClosed,
+	}
+}
+
+// durationOrDefault 将毫秒配置转换为时长，非正数时使用默认值
+func durationOrDefault(ms int, def time.Duration) time.Duration {
+	if ms <= 0 {
+		return def
+	}
+	return time.Duration(ms) * time.Millisecond
+}
+
+// Do 向主节点发送请求：网络错误和5xx响应按指数退避重试，全部失败后计入熔断器
+// 返回的响应状态码可能不是200（如4xx），由调用方判断
+func (c *masterClient) Do(method string, url string, body []byte) (*http.Response, error) {
+	return c.do(c.http, method, url, body)
+}
+
+// DoLongPoll 发送一个长轮询GET请求，重试和熔断与 Do 相同，超时时间包含长轮询的等待时间
+func (c *masterClient) DoLongPoll(url string) (*http.Response, error) {
+	return c.do(c.long, http.MethodGet, url, nil)
+}
+
+// DoBulk 发送一个不设整体超时的GET请求（如获取全量快照，响应可能很大），重试和熔断与 Do 相同
+func (c *masterClient) DoBulk(url string) (*http.Response, error) {
+	return c.do(c.stream, http.MethodGet, url, nil)
+}
+
+// do 使用指定的HTTP客户端发送请求（带重试和熔断）
+func (c *masterClient) do(client *http.Client, method string, url string, body []byte) (*http.Response, error) {
+	if err := c.acquire(); err != nil {
+		return nil, err
+	}
+
+	var lastErr error
+	backoff := retryInitialBackoff
+	for attempt := 1; attempt <= c.retries; attempt++ {
+		resp, err := c.send(client, method, url, body)
+		if err == nil && resp.StatusCode < http.StatusInternalServerError {
+			c.recordSuccess()
+			return resp, nil
+		}
+		if err == nil {
+			// 5xx：丢弃响应体以便复用连接
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+			err = fmt.Errorf("master returned error status: %s", resp.Status)
+		}
+		lastErr = err
+
+		if attempt < c.retries {
+			time.Sleep(jitter(backoff))
+			backoff *= 2
+			if backoff > retryMaxBackoff {
+				backoff = retryMaxBackoff
+			}
+		}
+	}
+
+	c.recordFailure(lastErr)
+	return nil, fmt.Errorf("master request failed after %d attempts: %w", c.retries, lastErr)
+}
+
+// probe 直接向主节点发送一次GET请求（不经过熔断器、不重试，仍经过故障注入），
+// 收到非5xx响应时认为主节点可以访问
+func (c *masterClient) probe(url string) error {
+	resp, err := c.send(c.http, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode >= http.StatusInternalServerError {
+		return fmt.Errorf("master returned error status: %s", resp.Status)
+	}
+	return nil
+}
+
+// send 发送一次请求，并携带从节点标识和复制令牌
+func (c *masterClient) send(client *http.Client, method string, url string, body []byte) (*http.Response, error) {
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+
+	req, err := http.NewRequest(method, url, reader)
+	if err != nil {
+		return nil, err
+	}
+	c.setHeaders(req.Header)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	return client.Do(req)
+}
+
+// setHeaders 设置从节点标识和复制令牌
+func (c *masterClient) setHeaders(header http.Header) {
+	header.Set(netfault.PeerHeader, c.peerID)
+	setAuthHeader(header, c.token)
+}
+
+// jitter 在退避时间的50%~100%之间随机取值，避免多个从节点同时重试
+func jitter(d time.Duration) time.Duration {
+	half := d / 2
+	return half + time.Duration(rand.Int63n(int64(half)+1))
+}
+
+// acquire 检查熔断器是否放行请求，冷却结束后只放行一个探测请求
+func (c *masterClient) acquire() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	switch c.state {
+	case circuitOpen:
+		if time.Since(c.openedAt) < c.cooldown {
+			return ErrCircuitOpen
+		}
+		c.state = circuitHalfOpen
+		c.probing = true
+		return nil
+	case circuitHalfOpen:
+		if c.probing {
+			return ErrCircuitOpen
+		}
+		c.probing = true
+	}
+	return nil
+}
+
+// recordSuccess 记录一次成功请求，关闭熔断器并清除不可达状态
+func (c *masterClient) recordSuccess() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !c.unreachableSince.IsZero() {
+		log.Printf("Master reachable again after %v", time.Since(c.unreachableSince).Round(time.Second))
+	}
+	c.state = circuitClosed
+	c.failures = 0
+	c.probing = false
+	c.unreachableSince = time.Time{}
+	c.lastError = ""
+}
+
+// reset 复制源切换到新的主节点后清除熔断和不可达状态
+func (c *masterClient) reset() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.state = circuitClosed
+	c.failures = 0
+	c.probing = false
+	c.unreachableSince = time.Time{}
+	c.lastError = ""
+}
+
+// recordFailure 记录一次失败请求（重试耗尽），连续失败达到阈值或探测失败时打开熔断器
+func (c *masterClient) recordFailure(err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	if c.unreachableSince.IsZero() {
+		c.unreachableSince = now
+		log.Printf("Master unreachable: %v", err)
+	}
+	c.lastError = err.Error()
+	c.failures++
+	c.probing = false
+
+	if c.state == circuitHalfOpen || c.failures >= c.threshold {
+		if c.state != circuitOpen {
+			log.Printf("Master circuit breaker opened after %d consecutive failures, retrying in %v", c.failures, c.cooldown)
+		}
+		c.state = circuitOpen
+		c.openedAt = now
+	}
+}
+
+// status 获取熔断器状态、主节点不可达开始时间及最近失败原因
+func (c *masterClient) status() (state string, unreachableSince time.Time, lastError string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.state, c.unreachableSince, c.lastError
+}
@@ -0,0 +1,386 @@
+package replication
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+// EntryEncoding binlog条目在传输和分段文件中的序列化格式
+type EntryEncoding string
+
+// 支持的序列化格式
+const (
+	EncodingBinary EntryEncoding = "binary" // 紧凑的二进制编码（默认）：长度前缀加varint字段
+	EncodingJSON   EntryEncoding = "json"   // JSON，便于阅读和调试
+)
+
+// BinaryContentType 以二进制编码返回binlog条目时响应的Content-Type
+const BinaryContentType = "application/x-binlog"
+
+// 二进制编码参数
+const (
+	binaryEntryVersion   = 3        // 编码版本，写在每个条目的开头，字段变化时递增（版本2增加了纪元，版本3增加了前映像）
+	binaryEntryV2        = 2        // 没有前映像的旧版本
+	binaryEntryV1        = 1        // 没有纪元的旧版本，引入纪元之前写入的分段文件仍可读取
+	binaryMessageVersion = 1        // 推送流消息头的版本
+	maxBinaryEntryBytes  = 64 << 20 // 单个条目的大小上限，超过时视为长度前缀已损坏
+)
+
+// binarySegmentMagic 二进制编码的分段文件的文件头，JSON编码的分段（每行一个条目）没有文件头
+var binarySegmentMagic = []byte("MSBINLG1")
+
+// ErrMalformedEntry 条目无法解析（内容损坏或不是支持的编码）
+var ErrMalformedEntry = errors.New("malformed binlog entry")
+
+// ParseEntryEncoding 解析序列化格式名称，空字符串表示默认的 binary
+func ParseEntryEncoding(name string) (EntryEncoding, error) {
+	switch e := EntryEncoding(name); e {
+	case "":
+		return EncodingBinary, nil
+	case EncodingBinary, EncodingJSON:
+		return e, nil
+	}
+	return "", fmt.Errorf("unknown binlog encoding: %s", name)
+}
+
+// appendBinaryEntry 把条目按二进制编码追加到buf：uvarint长度前缀加条目内容
+// 条目内容依次为版本、ID、操作类型、格式、服务器ID、纪元、表名、记录ID、数据、前映像、时间戳（纳秒）、复制日志ID和校验和，
+// 整数使用varint，字符串和数据使用uvarint长度前缀
+func appendBinaryEntry(buf []byte, e BinlogEntry) []byte {
+	body := make([]byte, 0, 48+len(e.Operation)+len(e.Format)+len(e.TableName)+len(e.Data)+len(e.Before)+len(e.Checksum))
+	body = append(body, binaryEntryVersion)
+	body = binary.AppendUvarint(body, e.ID)
+	body = appendBytes(body, []byte(e.Operation))
+	body = appendBytes(body, []byte(e.Format))
+	body = binary.AppendUvarint(body, uint64(e.ServerID))
+	body = binary.AppendUvarint(body, e.Epoch)
+	body = appendBytes(body, []byte(e.TableName))
+	body = binary.AppendUvarint(body, uint64(e.RecordID))
+	body = appendBytes(body, e.Data)
+	body = appendBytes(body, e.Before)
+	var nanos int64
+	if !e.Timestamp.IsZero() {
+		nanos = e.Timestamp.UnixNano()
+	}
+	body = binary.AppendVarint(body, nanos)
+	body = binary.AppendUvarint(body, e.WriteID)
+	body = appendBytes(body, []byte(e.Checksum))
+
+	buf = binary.AppendUvarint(buf, uint64(len(body)))
+	return append(buf, body...)
+}
+
+// appendBytes 追加uvarint长度前缀和内容
+func appendBytes(buf, data []byte) []byte {
+	buf = binary.AppendUvarint(buf, uint64(len(data)))
+	return append(buf, data...)
+}
+
+// binaryDecoder 按顺序读取二进制编码的字段，第一次失败后的读取都返回零值
+type binaryDecoder struct {
+	buf []byte
+	err error
+}
+
+// uvarint 读取一个uvarint
+func (d *binaryDecoder) uvarint() uint64 {
+	if d.err != nil {
+		return 0
+	}
+	v, n := binary.Uvarint(d.buf)
+	if n <= 0 {
+		d.err = ErrMalformedEntry
+		return 0
+	}
+	d.buf = d.buf[n:]
+	return v
+}
+
+// varint 读取一个varint
+func (d *binaryDecoder) varint() int64 {
+	if d.err != nil {
+		return 0
+	}
+	v, n := binary.Varint(d.buf)
+	if n <= 0 {
+		d.err = ErrMalformedEntry
+		return 0
+	}
+	d.buf = d.buf[n:]
+	return v
+}
+
+// bytes 读取带长度前缀的内容（复制一份，不引用原缓冲区）
+func (d *binaryDecoder) bytes() []byte {
+	n := d.uvarint()
+	if d.err != nil {
+		return nil
+	}
+	if n > uint64(len(d.buf)) {
+		d.err = ErrMalformedEntry
+		return nil
+	}
+	data := bytes.Clone(d.buf[:n])
+	d.buf = d.buf[n:]
+	return data
+}
+
+// string 读取带长度前缀的字符串
+func (d *binaryDecoder) string() string {
+	return string(d.bytes())
+}
+
+// decodeBinaryEntry 解析一个条目的内容（不含长度前缀）
+func decodeBinaryEntry(body []byte) (BinlogEntry, error) {
+	if len(body) == 0 || body[0] < binaryEntryV1 || body[0] > binaryEntryVersion {
+		return BinlogEntry{}, fmt.Errorf("%w: unsupported version", ErrMalformedEntry)
+	}
+	d := &binaryDecoder{buf: body[1:]}
+	e := BinlogEntry{
+		ID:        d.uvarint(),
+		Operation: d.string(),
+		Format:    d.string(),
+		ServerID:  uint32(d.uvarint()),
+	}
+	if body[0] >= binaryEntryV2 {
+		e.Epoch = d.uvarint()
+	}
+	e.TableName = d.string()
+	e.RecordID = uint(d.uvarint())
+	e.Data = d.bytes()
+	if body[0] >= binaryEntryVersion {
+		if before := d.bytes(); len(before) > 0 {
+			e.Before = before
+		}
+	}
+	if nanos := d.varint(); nanos != 0 {
+		e.Timestamp = time.Unix(0, nanos)
+	}
+	e.WriteID = d.uvarint()
+	e.Checksum = d.string()
+	if d.err != nil {
+		return BinlogEntry{}, d.err
+	}
+	if len(d.buf) != 0 {
+		return BinlogEntry{}, fmt.Errorf("%w: %d trailing bytes", ErrMalformedEntry, len(d.buf))
+	}
+	return e, nil
+}
+
+// readBinaryEntry 从r读取下一个带长度前缀的条目，返回条目和读取的字节数
+// 没有更多数据时返回 io.EOF，条目不完整时返回 io.ErrUnexpectedEOF
+func readBinaryEntry(r *bufio.Reader) (BinlogEntry, int, error) {
+	length, err := binary.ReadUvarint(r)
+	if err != nil {
+		return BinlogEntry{}, 0, err
+	}
+	n := len(binary.AppendUvarint(nil, length))
+	if length > maxBinaryEntryBytes {
+		return BinlogEntry{}, n, fmt.Errorf("%w: entry length %d too large", ErrMalformedEntry, length)
+	}
+	body := make([]byte, length)
+	read, err := io.ReadFull(r, body)
+	n += read
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			err = io.ErrUnexpectedEOF
+		}
+		return BinlogEntry{}, n, err
+	}
+	entry, err := decodeBinaryEntry(body)
+	return entry, n, err
+}
+
+// decodeBinaryEntries 解析连续的带长度前缀的条目
+func decodeBinaryEntries(data []byte) ([]BinlogEntry, error) {
+	var entries []BinlogEntry
+	for len(data) > 0 {
+		length, n := binary.Uvarint(data)
+		if n <= 0 || length > uint64(len(data)-n) {
+			return nil, fmt.Errorf("%w: truncated entry after %d entries", ErrMalformedEntry, len(entries))
+		}
+		entry, err := decodeBinaryEntry(data[n : n+int(length)])
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+		data = data[n+int(length):]
+	}
+	return entries, nil
+}
+
+// EncodeEntries 按指定格式序列化一组条目：JSON为数组，二进制为连续的带长度前缀的条目
+func EncodeEntries(enc EntryEncoding, entries []BinlogEntry) ([]byte, error) {
+	if enc == EncodingJSON {
+		if entries == nil {
+			entries = []BinlogEntry{}
+		}
+		return json.Marshal(entries)
+	}
+	var buf []byte
+	for _, entry := range entries {
+		buf = appendBinaryEntry(buf, entry)
+	}
+	return buf, nil
+}
+
+// DecodeEntries 按指定格式解析 EncodeEntries 的结果
+func DecodeEntries(enc EntryEncoding, data []byte) ([]BinlogEntry, error) {
+	if enc == EncodingJSON {
+		var entries []BinlogEntry
+		if err := json.Unmarshal(data, &entries); err != nil {
+			return nil, err
+		}
+		return entries, nil
+	}
+	return decodeBinaryEntries(data)
+}
+
+// EncodedSize 条目按指定格式序列化后的大小（JSON包括数组中的逗号）
+func EncodedSize(enc EntryEncoding, entry BinlogEntry) int {
+	if enc == EncodingJSON {
+		data, err := json.Marshal(entry)
+		if err != nil {
+			return len(entry.Data)
+		}
+		return len(data) + 1
+	}
+	return len(appendBinaryEntry(nil, entry))
+}
+
+// encodeStreamMessage 按指定格式序列化推送流消息
+// 二进制消息依次为版本、位置、检查到的位置、最早可用的位置、错误信息，之后是连续的带长度前缀的条目
+func encodeStreamMessage(enc EntryEncoding, msg StreamMessage) ([]byte, error) {
+	if enc == EncodingJSON {
+		return json.Marshal(msg)
+	}
+	buf := []byte{binaryMessageVersion}
+	buf = binary.AppendUvarint(buf, msg.Position)
+	buf = binary.AppendUvarint(buf, msg.Scanned)
+	buf = binary.AppendUvarint(buf, msg.OldestPosition)
+	buf = appendBytes(buf, []byte(msg.Error))
+	for _, entry := range msg.Entries {
+		buf = appendBinaryEntry(buf, entry)
+	}
+	return buf, nil
+}
+
+// decodeStreamMessage 解析推送流消息，按第一个字节区分编码（JSON消息以 { 开头，二进制消息以版本号开头），
+// 不支持二进制编码的主节点返回的JSON消息同样可以解析
+func decodeStreamMessage(data []byte) (StreamMessage, error) {
+	var msg StreamMessage
+	if len(data) > 0 && data[0] == '{' {
+		err := json.Unmarshal(data, &msg)
+		return msg, err
+	}
+	if len(data) == 0 || data[0] != binaryMessageVersion {
+		return msg, fmt.Errorf("%w: unsupported stream message version", ErrMalformedEntry)
+	}
+	d := &binaryDecoder{buf: data[1:]}
+	msg.Position = d.uvarint()
+	msg.Scanned = d.uvarint()
+	msg.OldestPosition = d.uvarint()
+	msg.Error = d.string()
+	if d.err != nil {
+		return msg, d.err
+	}
+	entries, err := decodeBinaryEntries(d.buf)
+	msg.Entries = entries
+	return msg, err
+}
+
+// encodingBenchDuration 测量一种编码时每个方向（编码、解码）至少持续的时间
+const encodingBenchDuration = 100 * time.Millisecond
+
+// EncodingStats 一种编码的大小和吞吐量
+type EncodingStats struct {
+	Encoding            EntryEncoding `json:"encoding"`
+	Bytes               int           `json:"bytes"`                  // 样本条目编码后的总大小
+	BytesPerEntry       float64       `json:"bytes_per_entry"`        // 平均每个条目的大小
+	EncodeEntriesPerSec float64       `json:"encode_entries_per_sec"` // 编码吞吐量（条目/秒）
+	DecodeEntriesPerSec float64       `json:"decode_entries_per_sec"` // 解码吞吐量（条目/秒）
+	EncodeMBPerSec      float64       `json:"encode_mb_per_sec"`      // 编码吞吐量（按编码后的大小，MB/秒）
+	DecodeMBPerSec      float64       `json:"decode_mb_per_sec"`      // 解码吞吐量（按编码后的大小，MB/秒）
+}
+
+// EncodingReport 同一组条目按JSON和二进制编码的对比（GET /api/binlog/encoding）
+type EncodingReport struct {
+	Entries   int           `json:"entries"`    // 样本条目数
+	JSON      EncodingStats `json:"json"`       // JSON编码
+	Binary    EncodingStats `json:"binary"`     // 二进制编码
+	SizeRatio float64       `json:"size_ratio"` // 二进制与JSON的大小之比
+}
+
+// MeasureEncodings 分别用JSON和二进制编码反复序列化、解析entries，测量大小和吞吐量
+func MeasureEncodings(entries []BinlogEntry) (EncodingReport, error) {
+	report := EncodingReport{Entries: len(entries)}
+	if len(entries) == 0 {
+		return report, nil
+	}
+	var err error
+	if report.JSON, err = measureEncoding(EncodingJSON, entries); err != nil {
+		return report, err
+	}
+	if report.Binary, err = measureEncoding(EncodingBinary, entries); err != nil {
+		return report, err
+	}
+	report.SizeRatio = float64(report.Binary.Bytes) / float64(report.JSON.Bytes)
+	return report, nil
+}
+
+// measureEncoding 测量一种编码，编码和解码各至少持续 encodingBenchDuration
+func measureEncoding(enc EntryEncoding, entries []BinlogEntry) (EncodingStats, error) {
+	data, err := EncodeEntries(enc, entries)
+	if err != nil {
+		return EncodingStats{}, err
+	}
+	stats := EncodingStats{
+		Encoding:      enc,
+		Bytes:         len(data),
+		BytesPerEntry: float64(len(data)) / float64(len(entries)),
+	}
+
+	rounds, elapsed := 0, time.Duration(0)
+	for start := time.Now(); elapsed < encodingBenchDuration; elapsed = time.Since(start) {
+		if _, err := EncodeEntries(enc, entries); err != nil {
+			return stats, err
+		}
+		rounds++
+	}
+	stats.EncodeEntriesPerSec, stats.EncodeMBPerSec = throughput(rounds, len(entries), len(data), elapsed)
+
+	rounds, elapsed = 0, 0
+	for start := time.Now(); elapsed < encodingBenchDuration; elapsed = time.Since(start) {
+		if _, err := DecodeEntries(enc, data); err != nil {
+			return stats, err
+		}
+		rounds++
+	}
+	stats.DecodeEntriesPerSec, stats.DecodeMBPerSec = throughput(rounds, len(entries), len(data), elapsed)
+	return stats, nil
+}
+
+// throughput 把rounds次处理换算为每秒的条目数和MB数
+func throughput(rounds, entries, size int, elapsed time.Duration) (entriesPerSec, mbPerSec float64) {
+	seconds := elapsed.Seconds()
+	return float64(rounds*entries) / seconds, float64(rounds*size) / seconds / (1 << 20)
+}
+
+// defaultEncodingSample 对比编码时默认使用的最近条目数
+const defaultEncodingSample = 1000
+
+// EncodingReport 用binlog中最近的sample个条目（不大于0时为1000个）对比JSON和二进制编码
+func (m *Master) EncodingReport(sample int) (EncodingReport, error) {
+	if sample <= 0 {
+		sample = defaultEncodingSample
+	}
+	position := m.binlog.GetCurrentPosition()
+	from := position - min(position, uint64(sample))
+	return MeasureEncodings(m.binlog.GetEntries(from))
+}
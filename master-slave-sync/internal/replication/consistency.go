@@ -0,0 +1,381 @@
+package replication
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"sort"
+	"time"
+
+	"master-slave-sync/internal/storage"
+)
+
+// 分块一致性检查相关参数
+const (
+	defaultChunkSize        = 1000 // 每块覆盖的ID数，对应配置项为0时使用
+	consistencyHistorySize  = 20   // 保留的检查报告数
+	consistencyFetchTimeout = verifyCatchUpWait + 10*time.Second
+)
+
+// ChunkReport 节点在某个binlog位置上的分块校验和
+type ChunkReport struct {
+	Position  uint64                  `json:"position"`   // 计算时已应用到的binlog位置
+	ChunkSize uint                    `json:"chunk_size"` // 每块覆盖的ID数
+	Chunks    []storage.ChunkChecksum `json:"chunks"`     // 有记录的块（按ID顺序）
+}
+
+// DivergentRange 主从不一致的ID区间（一个块）
+type DivergentRange struct {
+	FirstID     uint  `json:"first_id"`     // 区间的第一个ID（含）
+	LastID      uint  `json:"last_id"`      // 区间的最后一个ID（含）
+	MasterCount int64 `json:"master_count"` // 主节点上该区间的记录数
+	SlaveCount  int64 `json:"slave_count"`  // 从节点上该区间的记录数
+}
+
+// SlaveConsistency 一个从节点的分块检查结果
+type SlaveConsistency struct {
+	SlaveID       string           `json:"slave_id"`
+	Status        string           `json:"status"`                  // consistent、diverged 或 error
+	Position      uint64           `json:"position"`                // 从节点计算时的binlog位置
+	Divergent     []DivergentRange `json:"divergent,omitempty"`     // 不一致的区间
+	SkippedChunks int              `json:"skipped_chunks"`          // 检查期间有写入、没有比较的块数
+	RepairedRows  int              `json:"repaired_rows,omitempty"` // 为修复重新复制的行数
+	Message       string           `json:"message,omitempty"`
+}
+
+// ConsistencyReport 一次分块一致性检查的报告
+type ConsistencyReport struct {
+	Time      time.Time          `json:"time"`
+	Position  uint64             `json:"position"`   // 主节点计算完成时的binlog位置
+	ChunkSize uint               `json:"chunk_size"` // 每块覆盖的ID数
+	Chunks    int                `json:"chunks"`     // 主节点上有记录的块数
+	Repair    bool               `json:"repair"`     // 是否修复不一致的区间
+	Slaves    []SlaveConsistency `json:"slaves"`
+}
+
+// chunkSize 一致性检查每块覆盖的ID数
+func (m *Master) chunkSize() uint {
+	if m.config.ConsistencyChunkSize <= 0 {
+		return defaultChunkSize
+	}
+	return uint(m.config.ConsistencyChunkSize)
+}
+
+// CheckConsistency 计算主节点的分块校验和，与每个按时发送心跳的从节点在同一位置上的分块校验和比较，
+// repair为true时把不一致区间内的行重新写入binlog，只修复这些行
+func (m *Master) CheckConsistency(repair bool) ConsistencyReport {
+	size := m.chunkSize()
+	report := ConsistencyReport{Time: time.Now(), ChunkSize: size, Repair: repair}
+
+	// 计算期间可能有写入，记录计算前后的位置，这期间写入的块不参与比较
+	before := m.binlog.GetCurrentPosition()
+	chunks, err := m.db.ChunkChecksums(size)
+	report.Position = m.binlog.GetCurrentPosition()
+	if err != nil {
+		log.Printf("Consistency check failed: %v", err)
+		report.Slaves = []SlaveConsistency{{Status: verifyStatusError, Message: err.Error()}}
+		m.recordConsistency(report)
+		return report
+	}
+	report.Chunks = len(chunks)
+	master := ChunkReport{Position: report.Position, ChunkSize: size, Chunks: chunks}
+
+	now := time.Now()
+	m.mu.RLock()
+	var slaves []SlaveInfo
+	for _, info := range m.slaveInfos {
+		if m.describeSlave(info, now).Status == SlaveActive {
+			slaves = append(slaves, info)
+		}
+	}
+	m.mu.RUnlock()
+	sort.Slice(slaves, func(i, j int) bool { return slaves[i].ID < slaves[j].ID })
+
+	for _, info := range slaves {
+		result := m.checkSlaveConsistency(info, master, before, repair)
+		log.Printf("Consistency check of slave %s at position %d: %s, %d divergent ranges %s",
+			info.ID, result.Position, result.Status, len(result.Divergent), result.Message)
+		report.Slaves = append(report.Slaves, result)
+	}
+	m.recordConsistency(report)
+	return report
+}
+
+// checkSlaveConsistency 比较一个从节点与主节点的分块校验和，before为主节点开始计算前的binlog位置
+func (m *Master) checkSlaveConsistency(info SlaveInfo, master ChunkReport, before uint64, repair bool) SlaveConsistency {
+	result := SlaveConsistency{SlaveID: info.ID}
+	fail := func(err error) SlaveConsistency {
+		result.Status = verifyStatusError
+		result.Message = err.Error()
+		return result
+	}
+
+	slave, err := m.fetchSlaveChunks(info, master.ChunkSize, master.Position)
+	if err != nil {
+		return fail(err)
+	}
+	result.Position = slave.Position
+
+	// 两边计算时的位置之间写入的块可能只在一边生效，不参与比较
+	touched, err := m.touchedChunks(min(before, slave.Position), max(master.Position, slave.Position), master.ChunkSize)
+	if err != nil {
+		return fail(err)
+	}
+
+	masterChunks := make(map[uint]storage.ChunkChecksum, len(master.Chunks))
+	for _, c := range master.Chunks {
+		masterChunks[c.FirstID] = c
+	}
+	slaveChunks := make(map[uint]storage.ChunkChecksum, len(slave.Chunks))
+	for _, c := range slave.Chunks {
+		slaveChunks[c.FirstID] = c
+	}
+	firsts := make(map[uint]uint, len(masterChunks)+len(slaveChunks))
+	for first, c := range masterChunks {
+		firsts[first] = c.LastID
+	}
+	for first, c := range slaveChunks {
+		firsts[first] = c.LastID
+	}
+
+	for first, last := range firsts {
+		if touched[first] {
+			result.SkippedChunks++
+			continue
+		}
+		mc, sc := masterChunks[first], slaveChunks[first]
+		if mc.Count != sc.Count || mc.Checksum != sc.Checksum {
+			result.Divergent = append(result.Divergent, DivergentRange{
+				FirstID: first, LastID: last, MasterCount: mc.Count, SlaveCount: sc.Count,
+			})
+		}
+	}
+	sort.Slice(result.Divergent, func(i, j int) bool { return result.Divergent[i].FirstID < result.Divergent[j].FirstID })
+
+	if len(result.Divergent) == 0 {
+		result.Status = verifyStatusOK
+		return result
+	}
+	result.Status = verifyStatusDiverge
+	if !repair {
+		return result
+	}
+	for _, r := range result.Divergent {
+		repaired, err := m.repairRange(info, r)
+		result.RepairedRows += repaired
+		if err != nil {
+			result.Message = fmt.Sprintf("repair of ids %d-%d failed: %v", r.FirstID, r.LastID, err)
+			break
+		}
+	}
+	return result
+}
+
+// touchedChunks binlog中 (from, to] 之间写入 records 表的条目所在的块
+func (m *Master) touchedChunks(from, to uint64, size uint) (map[uint]bool, error) {
+	touched := make(map[uint]bool)
+	if from >= to {
+		return touched, nil
+	}
+	entries, err := m.binlog.EntriesAfter(from)
+	if err != nil {
+		return nil, fmt.Errorf("cannot tell which chunks changed during the check: %w", err)
+	}
+	for _, entry := range entries {
+		if entry.ID > to {
+			break
+		}
+		if entry.TableName == RecordsTable && entry.RecordID != 0 {
+			first, _ := storage.ChunkOf(entry.RecordID, size)
+			touched[first] = true
+		}
+	}
+	return touched, nil
+}
+
+// repairRange 把主节点上区间内的行作为INSERT条目（从节点按主键覆盖）重新写入binlog，
+// 只存在于从节点的行写入DELETE条目，返回写入的条目数
+// 条目进入binlog后复制到所有从节点，已经一致的从节点应用后结果不变
+func (m *Master) repairRange(info SlaveInfo, r DivergentRange) (int, error) {
+	records, err := m.db.ListRecordsInRange(r.FirstID, r.LastID)
+	if err != nil {
+		return 0, err
+	}
+	slaveIDs, err := m.fetchSlaveRecordIDs(info, r.FirstID, r.LastID)
+	if err != nil {
+		return 0, err
+	}
+
+	repaired := 0
+	onMaster := make(map[uint]bool, len(records))
+	for i := range records {
+		onMaster[records[i].ID] = true
+		if err := m.emitRepair(OpInsert, &records[i]); err != nil {
+			return repaired, err
+		}
+		repaired++
+	}
+	for _, id := range slaveIDs {
+		if onMaster[id] {
+			continue
+		}
+		if err := m.emitRepair(OpDelete, &storage.Record{ID: id}); err != nil {
+			return repaired, err
+		}
+		repaired++
+	}
+	log.Printf("Re-replicated %d rows in ids %d-%d for slave %s", repaired, r.FirstID, r.LastID, info.ID)
+	return repaired, nil
+}
+
+// emitRepair 把一行作为基于行的条目追加到binlog（不修改主节点的数据）
+func (m *Master) emitRepair(operation string, record *storage.Record) error {
+	id, data, err := recordsTable{}.Encode(record)
+	if err != nil {
+		return err
+	}
+	m.ddlMu.RLock()
+	defer m.ddlMu.RUnlock()
+	_, err = m.binlog.appendWrite(BinlogEntry{
+		Operation: operation,
+		ServerID:  m.config.ServerID,
+		TableName: RecordsTable,
+		RecordID:  id,
+		Data:      data,
+	})
+	return err
+}
+
+// recordConsistency 保存检查报告
+func (m *Master) recordConsistency(report ConsistencyReport) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.consistency = append(m.consistency, report)
+	if len(m.consistency) > consistencyHistorySize {
+		m.consistency = m.consistency[len(m.consistency)-consistencyHistorySize:]
+	}
+}
+
+// ConsistencyReports 获取最近的分块一致性检查报告（最早的在前）
+func (m *Master) ConsistencyReports() []ConsistencyReport {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return append([]ConsistencyReport(nil), m.consistency...)
+}
+
+// StartConsistencyChecker 启动分块一致性检查任务，每隔interval检查一次，interval不大于0时不启动
+// 是否修复由 MasterConfig.ConsistencyRepair 决定
+func (m *Master) StartConsistencyChecker(interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+
+	m.mu.Lock()
+	if m.consistencyStop != nil {
+		m.mu.Unlock()
+		return
+	}
+	stop := make(chan struct{})
+	m.consistencyStop = stop
+	m.mu.Unlock()
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				m.CheckConsistency(m.config.ConsistencyRepair)
+			}
+		}
+	}()
+}
+
+// StopConsistencyChecker 停止分块一致性检查任务
+func (m *Master) StopConsistencyChecker() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.consistencyStop != nil {
+		close(m.consistencyStop)
+		m.consistencyStop = nil
+	}
+}
+
+// getSlaveJSON 请求从节点的API并解码JSON响应
+func (m *Master) getSlaveJSON(info SlaveInfo, path string, query url.Values, out interface{}) error {
+	if info.Host == "" || info.Port == 0 {
+		return fmt.Errorf("slave %s did not register its API address", info.ID)
+	}
+	resp, err := m.slaveClient(consistencyFetchTimeout).Get(m.slaveURL(info, path, query))
+	if err != nil {
+		return fmt.Errorf("failed to reach slave %s: %w", info.ID, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("slave %s returned status %s for %s", info.ID, resp.Status, path)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode response of slave %s: %w", info.ID, err)
+	}
+	return nil
+}
+
+// fetchSlaveChunks 获取从节点追上position后的分块校验和
+func (m *Master) fetchSlaveChunks(info SlaveInfo, size uint, position uint64) (ChunkReport, error) {
+	var report ChunkReport
+	err := m.getSlaveJSON(info, "/api/chunks", url.Values{
+		"chunk_size": {fmt.Sprint(size)},
+		"position":   {fmt.Sprint(position)},
+	}, &report)
+	return report, err
+}
+
+// fetchSlaveRecordIDs 获取从节点上ID在 [first, last] 区间内的记录ID
+func (m *Master) fetchSlaveRecordIDs(info SlaveInfo, first, last uint) ([]uint, error) {
+	var ids []uint
+	err := m.getSlaveJSON(info, "/api/chunks/ids", url.Values{
+		"first_id": {fmt.Sprint(first)},
+		"last_id":  {fmt.Sprint(last)},
+	}, &ids)
+	return ids, err
+}
+
+// ChunkChecksums 等待从节点应用到position（最多等待 verifyCatchUpWait）后计算分块校验和，
+// 计算期间持有同步锁，返回的位置与数据一致；未能追上时返回当时的位置，由主节点跳过期间写入的块
+func (s *Slave) ChunkChecksums(size uint, position uint64) (ChunkReport, error) {
+	if size == 0 {
+		size = defaultChunkSize
+	}
+	deadline := time.Now().Add(verifyCatchUpWait)
+	for s.GetCurrentPosition() < position && time.Now().Before(deadline) {
+		time.Sleep(verifyCatchUpPoll)
+	}
+
+	s.syncMutex.Lock()
+	defer s.syncMutex.Unlock()
+	chunks, err := s.db.ChunkChecksums(size)
+	if err != nil {
+		return ChunkReport{}, err
+	}
+	return ChunkReport{Position: s.currentPosition, ChunkSize: size, Chunks: chunks}, nil
+}
+
+// RecordIDsInRange 获取从库中ID在 [first, last] 区间内的记录ID
+func (s *Slave) RecordIDsInRange(first, last uint) ([]uint, error) {
+	records, err := s.db.ListRecordsInRange(first, last)
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]uint, 0, len(records))
+	for _, r := range records {
+		ids = append(ids, r.ID)
+	}
+	return ids, nil
+}
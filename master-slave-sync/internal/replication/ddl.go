@@ -0,0 +1,148 @@
+package replication
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+
+	"master-slave-sync/internal/storage"
+)
+
+// OpDDL 表结构变更的操作类型：条目的Data为 DDLEvent，TableName为变更的表，RecordID为0
+const OpDDL = "DDL"
+
+// ddlKeywords 支持复制的DDL语句的第一个关键字
+var ddlKeywords = []string{"CREATE", "ALTER", "DROP", "RENAME", "TRUNCATE"}
+
+// ErrNotDDL 提交的语句不是支持复制的DDL（或包含多条语句）
+var ErrNotDDL = errors.New("statement is not a single supported DDL statement")
+
+// DDLEvent DDL条目的内容
+type DDLEvent struct {
+	SQL string `json:"sql"` // 在主节点上执行的语句，从节点原样执行
+}
+
+// normalizeDDL 去掉语句首尾的空白和结尾的分号，只接受以 ddlKeywords 开头的单条语句
+func normalizeDDL(statement string) (string, error) {
+	stmt := strings.TrimSpace(statement)
+	stmt = strings.TrimSpace(strings.TrimSuffix(stmt, ";"))
+	if stmt == "" || strings.Contains(stmt, ";") {
+		return "", ErrNotDDL
+	}
+	keyword := strings.Fields(stmt)[0]
+	for _, k := range ddlKeywords {
+		if strings.EqualFold(keyword, k) {
+			return stmt, nil
+		}
+	}
+	return "", fmt.Errorf("%w: %s", ErrNotDDL, keyword)
+}
+
+// ddlTable 从DDL语句中取出变更的表，条目的表名（复制过滤按它匹配）以语句为准。
+// 只接受不带库名、只变更一张表的 CREATE/ALTER/DROP/TRUNCATE/RENAME TABLE 和 CREATE/DROP INDEX ... ON，
+// 其他语句（如 DROP DATABASE）的影响范围无法按表过滤，拒绝复制
+func ddlTable(stmt string) (string, error) {
+	match := ddlTablePattern.FindStringSubmatch(stmt)
+	if match == nil {
+		return "", fmt.Errorf("%w: table name not recognized", ErrNotDDL)
+	}
+	if match[1] != "" {
+		return "", fmt.Errorf("%w: statement must not name a database: %s", ErrNotDDL, match[1])
+	}
+	keyword := strings.ToUpper(strings.Fields(stmt)[0])
+	rest := stmt[len(match[0]):]
+	if (keyword == "DROP" || keyword == "RENAME") && strings.ContainsAny(rest, ",.") {
+		return "", fmt.Errorf("%w: statement must change a single table in this database", ErrNotDDL)
+	}
+	return match[2], nil
+}
+
+// ExecuteDDL 在主节点上执行DDL并作为一个条目写入binlog，再按写入选项等待从节点确认，返回条目的位置。
+// 条目的表名从语句中取出（见 ddlTable），table不为空时必须与之一致
+// 执行期间阻塞其他写入：之前开始的写入都已提交并追加binlog之后才执行DDL，之后的写入等DDL追加binlog后才开始，
+// 因此DDL条目在binlog中的位置与它在主节点上生效的时刻一致，从节点按顺序应用时前后的行事件看到的表结构与主节点相同
+func (m *Master) ExecuteDDL(table, statement string, opts WriteOptions) (uint64, SemiSyncStatus, error) {
+	stmt, err := normalizeDDL(statement)
+	if err != nil {
+		return 0, "", err
+	}
+	target, err := ddlTable(stmt)
+	if err != nil {
+		return 0, "", err
+	}
+	if table != "" && !strings.EqualFold(table, target) {
+		return 0, "", fmt.Errorf("%w: statement changes table %s, not %s", ErrNotDDL, target, table)
+	}
+	table = target
+	if m.tail != nil {
+		return m.executeTailedDDL(stmt, opts)
+	}
+	data, err := json.Marshal(DDLEvent{SQL: stmt})
+	if err != nil {
+		return 0, "", fmt.Errorf("failed to serialize DDL event: %w", err)
+	}
+
+	m.ddlMu.Lock()
+	// MySQL在DDL前后隐式提交，DDL无法与复制日志放在同一个事务中：执行后、追加binlog前崩溃时这次变更不会复制
+	if err := m.db.ExecDDL(stmt); err != nil {
+		m.ddlMu.Unlock()
+		return 0, "", fmt.Errorf("failed to execute DDL: %w", err)
+	}
+	pos, err := m.binlog.appendWrite(BinlogEntry{
+		Operation: OpDDL,
+		ServerID:  m.config.ServerID,
+		TableName: table,
+		Data:      data,
+	})
+	m.ddlMu.Unlock()
+	if err != nil {
+		return 0, "", fmt.Errorf("DDL executed but binlog append failed, slaves will not see it: %w", err)
+	}
+	log.Printf("DDL on %s executed at binlog position %d: %s", table, pos, stmt)
+
+	status, err := m.finishWrite(pos, opts.Durability, nil)
+	return pos, status, err
+}
+
+// executeTailedDDL 跟随MySQL binlog时执行DDL：DDL条目由跟随任务从MySQL的binlog中得到，这里只执行语句并等待转换
+func (m *Master) executeTailedDDL(stmt string, opts WriteOptions) (uint64, SemiSyncStatus, error) {
+	if err := m.db.ExecDDL(stmt); err != nil {
+		return 0, "", fmt.Errorf("failed to execute DDL: %w", err)
+	}
+	pos, err := m.waitTailed()
+	var waited *ackWait
+	if err != nil {
+		waited = &ackWait{status: StatusTimeout, err: err}
+	}
+	status, err := m.finishWrite(pos, opts.Durability, waited)
+	return pos, status, err
+}
+
+// applyDDL 在从库上执行DDL条目中的语句
+func applyDDL(db *storage.DB, entry BinlogEntry) error {
+	var event DDLEvent
+	if err := json.Unmarshal(entry.Data, &event); err != nil {
+		return fmt.Errorf("failed to deserialize DDL event: %w", err)
+	}
+	if err := db.ExecDDL(event.SQL); err != nil {
+		return fmt.Errorf("failed to apply DDL to %s: %w", entry.TableName, err)
+	}
+	log.Printf("Applied DDL on %s at binlog position %d: %s", entry.TableName, entry.ID, event.SQL)
+	return nil
+}
+
+// splitAtDDL 在第一个DDL条目处把一批条目分成DDL之前的条目、DDL条目本身和之后的条目
+// 只有一个条目或没有DDL条目时返回false
+func splitAtDDL(entries []BinlogEntry) (before, ddl, after []BinlogEntry, ok bool) {
+	if len(entries) < 2 {
+		return nil, nil, nil, false
+	}
+	for i, entry := range entries {
+		if entry.Operation == OpDDL {
+			return entries[:i], entries[i : i+1], entries[i+1:], true
+		}
+	}
+	return nil, nil, nil, false
+}
@@ -0,0 +1,112 @@
+package replication
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"time"
+)
+
+// Durability 写操作的持久化级别，决定返回前是否等待从节点确认
+type Durability string
+
+// 支持的持久化级别
+const (
+	DurabilityLocal    Durability = "local"     // 主节点提交即返回，不等待从节点确认
+	DurabilitySemiSync Durability = "semi_sync" // 等待从节点确认，超时后降级为异步并正常返回（默认）
+	DurabilityStrict   Durability = "strict"    // 等待从节点确认，超时返回 NotReplicatedError（写入已在主节点提交）
+)
+
+// StatusSkipped 持久化级别为 local 时没有等待从节点确认
+const StatusSkipped SemiSyncStatus = "SKIPPED"
+
+// ErrNotReplicated 写入已在主节点提交，但在超时前没有得到足够的从节点确认
+var ErrNotReplicated = errors.New("write committed on master but not acknowledged by slaves")
+
+// NotReplicatedError 持久化级别为 strict 时没有得到从节点确认的详细错误
+type NotReplicatedError struct {
+	Position uint64         // 写入的binlog位置
+	Status   SemiSyncStatus // 半同步确认结果
+	Cause    error          // 等待确认的错误
+}
+
+// Error 实现error接口
+func (e *NotReplicatedError) Error() string {
+	return fmt.Sprintf("write at binlog position %d committed on master but not acknowledged by slaves (%s): %v",
+		e.Position, e.Status, e.Cause)
+}
+
+// Unwrap 支持errors.Is(err, ErrNotReplicated)
+func (e *NotReplicatedError) Unwrap() error {
+	return ErrNotReplicated
+}
+
+// WriteOptions 写操作选项，零值表示永不过期、semi_sync持久化级别
+type WriteOptions struct {
+	TTL        time.Duration // 记录有效期（仅创建时有效），0表示永不过期
+	Durability Durability    // 持久化级别，为空表示 semi_sync
+}
+
+// ParseDurability 解析持久化级别名称，空字符串表示默认的 semi_sync
+func ParseDurability(name string) (Durability, error) {
+	switch d := Durability(name); d {
+	case "":
+		return DurabilitySemiSync, nil
+	case DurabilityLocal, DurabilitySemiSync, DurabilityStrict:
+		return d, nil
+	}
+	return "", fmt.Errorf("unknown durability level: %s", name)
+}
+
+// ackWait 等待从节点确认的结果
+type ackWait struct {
+	status SemiSyncStatus // 半同步确认结果
+	err    error          // 等待确认的错误（超时或活跃从节点不足）
+}
+
+// waitsBeforeCommit 写入是否在主节点提交之前等待从节点确认（after_sync）
+func (m *Master) waitsBeforeCommit(durability Durability) bool {
+	return m.waitPoint == WaitAfterSync && durability != DurabilityLocal
+}
+
+// awaitACK 等待binlog位置pos的从节点确认（如果失败，降级为异步）；按时发送心跳的从节点不够时不必等待，
+// 已降级时也不等待，直到恢复检查（见 ProbeSemiSync）发现从节点已追上
+func (m *Master) awaitACK(pos uint64) *ackWait {
+	if m.semiSync.GetStatus() == StatusDegraded {
+		return &ackWait{status: StatusDegraded, err: fmt.Errorf("semi-sync is degraded, binlog position %d replicated asynchronously", pos)}
+	}
+	if active := m.activeSlaveCount(); active < m.semiSync.config.MinSlaves {
+		status, err := m.semiSync.degrade(fmt.Errorf("only %d active slaves, semi-sync requires %d", active, m.semiSync.config.MinSlaves))
+		return &ackWait{status: status, err: err}
+	}
+	status, err := m.semiSync.WaitForACK(pos)
+	return &ackWait{status: status, err: err}
+}
+
+// finishWrite 写入提交后按持久化级别处理从节点确认，并计入写入次数；有从节点落后过多时按流量控制延迟返回
+// waited 为提交之前（after_sync）已经等待的结果，为nil时在此等待（after_commit）
+func (m *Master) finishWrite(pos uint64, durability Durability, waited *ackWait) (SemiSyncStatus, error) {
+	m.mu.Lock()
+	m.totalWrites++
+	m.mu.Unlock()
+	m.throttleWrite()
+
+	if durability == DurabilityLocal {
+		return StatusSkipped, nil
+	}
+
+	if waited == nil {
+		waited = m.awaitACK(pos)
+	}
+	if waited.err == nil {
+		return waited.status, nil
+	}
+	if durability == DurabilityStrict {
+		return waited.status, &NotReplicatedError{Position: pos, Status: waited.status, Cause: waited.err}
+	}
+	if waited.status != StatusDegraded {
+		// 已降级时不逐条告警，降级本身已由状态切换记录
+		log.Printf("Semi-sync replication warning: %v, status: %s", waited.err, waited.status)
+	}
+	return waited.status, nil
+}
@@ -0,0 +1,340 @@
+package replication
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"time"
+)
+
+// 故障切换相关参数
+const (
+	defaultMasterFailureThreshold = 5               // 连续多少次同步失败后认为主节点已故障，对应配置项为0时使用
+	electionRequestTimeout        = 3 * time.Second // 向其他从节点查询选举状态的超时时间
+)
+
+// ErrElectionAborted 选举条件不满足（其他从节点仍能访问主节点或响应的从节点不足），本次不切换
+var ErrElectionAborted = errors.New("election aborted")
+
+// PeerInfo 注册在同一主节点下的从节点
+type PeerInfo struct {
+	ID   string `json:"id"`   // 从节点ID
+	Host string `json:"host"` // 地址
+	Port int    `json:"port"` // API端口
+}
+
+// ElectionVote 从节点在选举中报告的状态（GET /api/election）
+type ElectionVote struct {
+	SlaveID         string `json:"slave_id"`
+	Host            string `json:"host"`
+	Port            int    `json:"port"`
+	Position        uint64 `json:"position"`         // 已应用到的binlog位置
+	MasterReachable bool   `json:"master_reachable"` // 最近一次访问主节点是否成功
+	Promoted        bool   `json:"promoted"`         // 是否已提升为新的主节点
+}
+
+// ElectionResult 一次选举的结果
+type ElectionResult struct {
+	Time     time.Time      `json:"time"`
+	Winner   string         `json:"winner,omitempty"`   // 当选的从节点ID
+	Position uint64         `json:"position,omitempty"` // 当选者已应用到的位置
+	Votes    []ElectionVote `json:"votes"`              // 参与选举的从节点（包括自己）
+	Promoted bool           `json:"promoted"`           // 本节点是否当选并提升为主节点
+	Message  string         `json:"message,omitempty"`
+}
+
+// Peers 已注册且登记了API地址的从节点（按ID排序），随心跳响应发给从节点
+func (m *Master) Peers() []PeerInfo {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	peers := make([]PeerInfo, 0, len(m.slaveInfos))
+	for _, info := range m.slaveInfos {
+		if info.Host != "" && info.Port != 0 {
+			peers = append(peers, PeerInfo{ID: info.ID, Host: info.Host, Port: info.Port})
+		}
+	}
+	sort.Slice(peers, func(i, j int) bool { return peers[i].ID < peers[j].ID })
+	return peers
+}
+
+// setPeers 记录主节点返回的从节点列表（不包括自己）
+func (s *Slave) setPeers(peers []PeerInfo) {
+	others := make([]PeerInfo, 0, len(peers))
+	for _, p := range peers {
+		if p.ID != s.slaveID {
+			others = append(others, p)
+		}
+	}
+	s.electionMu.Lock()
+	defer s.electionMu.Unlock()
+	s.peers = others
+}
+
+// OnPromote 设置本节点在选举中当选并提升为主节点后的回调，由调用方切换API路由并启动主节点的后台任务
+func (s *Slave) OnPromote(fn func(*Master)) {
+	s.electionMu.Lock()
+	defer s.electionMu.Unlock()
+	s.onPromote = fn
+}
+
+// Promoted 本节点在选举中当选后提升成的主节点，未提升时返回nil
+func (s *Slave) Promoted() *Master {
+	s.electionMu.Lock()
+	defer s.electionMu.Unlock()
+	return s.promoted
+}
+
+// ElectionVote 本节点在选举中的状态
+func (s *Slave) ElectionVote() ElectionVote {
+	s.electionMu.Lock()
+	promoted := s.promoted != nil
+	reachable := s.masterFailures == 0
+	s.electionMu.Unlock()
+
+	return ElectionVote{
+		SlaveID:         s.slaveID,
+		Host:            s.config.Host,
+		Port:            s.config.APIPort,
+		Position:        s.GetCurrentPosition(),
+		MasterReachable: reachable && !promoted,
+		Promoted:        promoted,
+	}
+}
+
+// noteSyncResult 记录一次同步的结果：主节点不可达时累计失败次数，达到阈值后
+// 备用主节点接管，配置了备用主节点的从节点切换过去，开启了自动故障切换的从节点发起选举
+func (s *Slave) noteSyncResult(err error) {
+	_, unreachableSince, _ := s.client.status()
+
+	s.electionMu.Lock()
+	if err == nil || unreachableSince.IsZero() {
+		s.masterFailures = 0
+		s.electionMu.Unlock()
+		return
+	}
+	s.masterFailures++
+	failures := s.masterFailures
+	s.electionMu.Unlock()
+
+	threshold := s.config.MasterFailureThreshold
+	if threshold <= 0 {
+		threshold = defaultMasterFailureThreshold
+	}
+	if failures < threshold {
+		return
+	}
+	switch {
+	case s.config.StandbyMaster:
+		log.Printf("Master unreachable for %d consecutive syncs, standby taking over", failures)
+		if err := s.TakeOver(); err != nil {
+			log.Printf("Standby takeover failed: %v", err)
+		}
+		return
+	case s.standbyAddress() != "":
+		if err := s.followStandby(); err != nil {
+			log.Printf("Failover to standby master failed: %v", err)
+		}
+		return
+	case !s.config.AutoFailover:
+		return
+	}
+
+	log.Printf("Master unreachable for %d consecutive syncs (since %s), starting election",
+		failures, unreachableSince.Format(time.RFC3339))
+	if _, err := s.RunElection(); err != nil {
+		log.Printf("Election failed: %v", err)
+	}
+}
+
+// RunElection 在已注册的从节点之间选举新的主节点：
+//  1. 本节点先直接访问一次主节点，再向主节点返回的每个从节点查询选举状态，无法访问的从节点不参与
+//  2. 有从节点（包括自己）仍能访问主节点时放弃（可能只是本节点与主节点之间的网络故障，或手动发起时主节点仍在运行）
+//  3. 已有从节点提升为主节点时直接切换到它；否则需要超过半数的从节点（包括自己）响应
+//  4. 已应用位置最高的从节点当选（位置相同时ID较小的当选），当选者提升为主节点，其余切换到当选者
+func (s *Slave) RunElection() (ElectionResult, error) {
+	result := ElectionResult{Time: time.Now()}
+	finish := func(err error) (ElectionResult, error) {
+		if err != nil {
+			result.Message = err.Error()
+		}
+		s.electionMu.Lock()
+		s.lastElection = &result
+		s.electionMu.Unlock()
+		return result, err
+	}
+
+	s.electionMu.Lock()
+	if s.promoted != nil {
+		s.electionMu.Unlock()
+		return finish(fmt.Errorf("%w: this node is already the master", ErrElectionAborted))
+	}
+	peers := append([]PeerInfo(nil), s.peers...)
+	s.electionMu.Unlock()
+
+	// 不沿用最近一次同步的结果：手动发起时同步可能正常或已停止，主节点仍在运行时提升会出现两个主节点
+	self := s.ElectionVote()
+	self.MasterReachable = s.client.probe(s.MasterURL()+"/api/binlog/status") == nil
+	result.Votes = append(result.Votes, self)
+	if self.MasterReachable {
+		return finish(fmt.Errorf("%w: this node can still reach the master", ErrElectionAborted))
+	}
+	for _, peer := range peers {
+		vote, err := s.fetchElectionVote(peer)
+		if err != nil {
+			log.Printf("Election: slave %s unavailable: %v", peer.ID, err)
+			continue
+		}
+		result.Votes = append(result.Votes, vote)
+	}
+
+	for _, vote := range result.Votes {
+		if vote.Promoted {
+			result.Winner, result.Position = vote.SlaveID, vote.Position
+			log.Printf("Election: slave %s has already been promoted, following it", vote.SlaveID)
+			return finish(s.repoint(vote.Host, vote.Port))
+		}
+	}
+	for _, vote := range result.Votes {
+		if vote.MasterReachable {
+			return finish(fmt.Errorf("%w: slave %s can still reach the master", ErrElectionAborted, vote.SlaveID))
+		}
+	}
+	if total := len(peers) + 1; len(result.Votes)*2 <= total {
+		return finish(fmt.Errorf("%w: only %d of %d slaves responded", ErrElectionAborted, len(result.Votes), total))
+	}
+
+	winner := result.Votes[0]
+	for _, vote := range result.Votes[1:] {
+		if vote.Position > winner.Position || vote.Position == winner.Position && vote.SlaveID < winner.SlaveID {
+			winner = vote
+		}
+	}
+	result.Winner, result.Position = winner.SlaveID, winner.Position
+	log.Printf("Election: slave %s wins with position %d (%d votes)", winner.SlaveID, winner.Position, len(result.Votes))
+
+	if winner.SlaveID != s.slaveID {
+		return finish(s.repoint(winner.Host, winner.Port))
+	}
+	if err := s.promote(); err != nil {
+		return finish(err)
+	}
+	result.Promoted = true
+	return finish(nil)
+}
+
+// fetchElectionVote 查询另一个从节点的选举状态
+func (s *Slave) fetchElectionVote(peer PeerInfo) (ElectionVote, error) {
+	resp, err := s.peerClient(electionRequestTimeout).Get(s.nodeURL(peer.Host, peer.Port) + "/api/election")
+	if err != nil {
+		return ElectionVote{}, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return ElectionVote{}, fmt.Errorf("status %s", resp.Status)
+	}
+	var vote ElectionVote
+	if err := json.NewDecoder(resp.Body).Decode(&vote); err != nil {
+		return ElectionVote{}, fmt.Errorf("failed to decode vote: %w", err)
+	}
+	return vote, nil
+}
+
+// promote 停止同步并把本节点提升为主节点：从库连接改为主库连接，binlog从已应用的位置继续编号
+// 开启中继时中继日志中最近的条目成为新主节点binlog的开头，落后的从节点可以从新主节点补齐
+func (s *Slave) promote() error {
+	cfg := *s.syncConfig
+	cfg.Master.APIPort = s.config.APIPort
+	cfg.Master.ServerID = s.config.ServerID
+	// 新主节点的纪元高于本节点见过的所有纪元，原主节点重新上线后写入的条目会被从节点拒绝
+	cfg.Master.Epoch = max(s.Epoch(), initialEpoch) + 1
+	cfg.Master.BinlogPath = "" // 新主节点的binlog只保存在内存中
+	cfg.Master.GRPCPort = 0
+	format, err := parseBinlogFormat(cfg.Master.BinlogFormat)
+	if err != nil {
+		return err
+	}
+	waitPoint, err := ParseWaitPoint(cfg.SemiSync.WaitPoint)
+	if err != nil {
+		return err
+	}
+
+	s.StopSync()
+	// 同步停止后才解除只读，提升完成后连接成为主库连接，恢复函数不再生效
+	restore := s.db.OverrideReadOnly(fmt.Sprintf("promoting slave %s to master", s.slaveID))
+	defer restore()
+	if err := s.db.PromoteToMaster(); err != nil {
+		return fmt.Errorf("failed to promote database: %w", err)
+	}
+	if err := migrateTables(s.db); err != nil {
+		return err
+	}
+
+	s.syncMutex.Lock()
+	binlog := s.relay
+	if binlog == nil {
+		binlog = newRelayLog(s.currentPosition)
+	}
+	position := s.currentPosition
+	s.syncMutex.Unlock()
+
+	// 所有节点使用同一个CA签发的证书，新主节点与本节点访问主节点时一样通过TLS访问其他从节点
+	master := newMaster(&cfg, s.db, binlog, format, waitPoint, s.tlsConfig)
+
+	s.electionMu.Lock()
+	s.promoted = master
+	onPromote := s.onPromote
+	s.electionMu.Unlock()
+
+	log.Printf("Slave %s promoted to master at binlog position %d", s.slaveID, position)
+	if onPromote != nil {
+		onPromote(master)
+	}
+	return nil
+}
+
+// repoint 把复制源切换到当选的从节点（新的主节点），之后通过HTTP传输同步并重新注册
+func (s *Slave) repoint(host string, port int) error {
+	if host == "" || port == 0 {
+		return fmt.Errorf("new master did not register its API address")
+	}
+
+	s.syncMutex.Lock()
+	s.masterURL = s.nodeURL(host, port)
+	s.config.MasterHost, s.config.MasterPort = host, port
+	s.db.SetMasterURL(s.masterURL)
+	if s.grpc != nil {
+		// 新主节点不提供gRPC复制服务
+		if err := s.grpc.close(); err != nil {
+			log.Printf("Error closing grpc connection: %v", err)
+		}
+		s.grpc = nil
+	}
+	if s.stream != nil {
+		s.stream.Close()
+	}
+	s.syncMutex.Unlock()
+
+	s.client.reset()
+	s.electionMu.Lock()
+	s.masterFailures = 0
+	s.electionMu.Unlock()
+
+	log.Printf("Slave %s now replicating from new master at %s", s.slaveID, s.MasterURL())
+	if err := s.registerWithMaster(); err != nil {
+		// 当选者可能还未完成提升，之后的心跳会重新登记
+		log.Printf("Warning: Failed to register with new master: %v", err)
+	}
+	return nil
+}
+
+// failoverStats 故障切换相关的统计信息
+func (s *Slave) failoverStats() (failures, peers int, promoted bool, last *ElectionResult) {
+	s.electionMu.Lock()
+	defer s.electionMu.Unlock()
+	return s.masterFailures, len(s.peers), s.promoted != nil, s.lastElection
+}
@@ -0,0 +1,66 @@
+package replication
+
+import (
+	"errors"
+	"fmt"
+)
+
+// initialEpoch 没有配置纪元、binlog中也没有带纪元的条目时主节点使用的纪元
+const initialEpoch = 1
+
+// ErrStaleEpoch 条目或快照来自比本节点已知纪元更旧的主节点（如故障切换后重新上线的原主节点），拒绝应用
+var ErrStaleEpoch = errors.New("data from a stale master epoch")
+
+// setEpoch 设置之后追加的条目携带的纪元（主节点启动或提升时调用）
+func (b *Binlog) setEpoch(epoch uint64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.epoch = epoch
+}
+
+// Epoch 当前追加条目时使用的纪元，中继日志为0
+func (b *Binlog) Epoch() uint64 {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return b.epoch
+}
+
+// lastEpoch binlog中最后一个条目的纪元，没有条目时为0
+func (b *Binlog) lastEpoch() uint64 {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	if len(b.entries) == 0 {
+		return 0
+	}
+	return b.entries[len(b.entries)-1].Epoch
+}
+
+// masterEpoch 主节点的纪元：取配置的纪元、binlog中最后一个条目的纪元和初始纪元中的最大值，
+// 重启后不会低于已写入binlog的纪元；提升时配置的纪元为从节点已知纪元加一（见 promote）
+func masterEpoch(cfg uint64, binlog *Binlog) uint64 {
+	return max(cfg, binlog.lastEpoch(), initialEpoch)
+}
+
+// Epoch 主节点的纪元
+func (m *Master) Epoch() uint64 {
+	return m.binlog.Epoch()
+}
+
+// Epoch 本节点已应用的条目中最大的纪元（与位置一起持久化），没有带纪元的条目时为0
+func (s *Slave) Epoch() uint64 {
+	s.syncMutex.Lock()
+	defer s.syncMutex.Unlock()
+	return s.epoch
+}
+
+// checkEpoch 检查条目的纪元不低于已知的纪元，返回应用后的纪元；未携带纪元（升级前写入）的条目不检查
+func checkEpoch(entry BinlogEntry, known uint64) (uint64, error) {
+	if entry.Epoch == 0 {
+		return known, nil
+	}
+	if entry.Epoch < known {
+		return known, fmt.Errorf("%w: entry %d has epoch %d, already applied epoch %d",
+			ErrStaleEpoch, entry.ID, entry.Epoch, known)
+	}
+	return entry.Epoch, nil
+}
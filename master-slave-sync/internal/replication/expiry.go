@@ -0,0 +1,99 @@
+package replication
+
+import (
+	"log"
+	"time"
+
+	"master-slave-sync/internal/storage"
+)
+
+// expiryBatchSize 每轮清理的最大记录数
+const expiryBatchSize = 100
+
+// StartExpiryReaper 启动过期记录清理任务
+// 清理只在主节点执行，并像普通删除一样写入binlog DELETE条目，从节点通过复制流得到相同的结果；
+// 如果每个节点各自按本地时钟清理，时钟偏差和复制延迟会导致主从数据在一段时间内不一致
+func (m *Master) StartExpiryReaper(interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+
+	m.mu.Lock()
+	if m.reaperStop != nil {
+		m.mu.Unlock()
+		return
+	}
+	stop := make(chan struct{})
+	m.reaperStop = stop
+	m.mu.Unlock()
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				if n, err := m.ReapExpired(); err != nil {
+					log.Printf("Expiry reaper error: %v", err)
+				} else if n > 0 {
+					log.Printf("Expiry reaper removed %d expired records", n)
+				}
+			}
+		}
+	}()
+
+	log.Printf("Expiry reaper started with interval %v", interval)
+}
+
+// StopExpiryReaper 停止过期记录清理任务
+func (m *Master) StopExpiryReaper() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.reaperStop != nil {
+		close(m.reaperStop)
+		m.reaperStop = nil
+	}
+}
+
+// ReapExpired 删除一批已过期的记录并写入binlog，返回删除的记录数
+func (m *Master) ReapExpired() (int, error) {
+	records, err := m.db.ListExpiredRecords(time.Now(), expiryBatchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	var lastPos uint64
+	removed := 0
+	for _, record := range records {
+		id := record.ID
+		// 逐条写入时不等待确认（即使是 after_sync），整批在最后统一等待
+		_, pos, _, err := m.commitWrite(OpDelete, DurabilityLocal, func(tx *storage.DB) (*storage.Record, error) {
+			return &storage.Record{ID: id}, tx.DeleteRecord(id)
+		})
+		if err != nil {
+			// 记录可能已被并发删除，跳过即可
+			log.Printf("Failed to delete expired record %d: %v", record.ID, err)
+			continue
+		}
+		lastPos = pos
+		removed++
+	}
+
+	if lastPos > 0 {
+		// 整批只等待最后一个位置的确认，避免每条记录都等待一次超时
+		if waited := m.awaitACK(lastPos); waited.err != nil && waited.status != StatusDegraded {
+			log.Printf("Semi-sync replication warning: %v, status: %s", waited.err, waited.status)
+		}
+	}
+
+	m.mu.Lock()
+	m.totalWrites += removed
+	m.expired += removed
+	m.mu.Unlock()
+
+	return removed, nil
+}
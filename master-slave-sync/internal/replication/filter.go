@@ -0,0 +1,147 @@
+package replication
+
+import (
+	"fmt"
+	"log"
+	"slices"
+	"strconv"
+
+	"master-slave-sync/internal/config"
+	"master-slave-sync/internal/replpb"
+)
+
+// ScannedHeader 主节点按从节点的过滤规则返回条目时，检查到的最后一个条目ID
+// 被过滤的条目不在响应中，从节点据此推进位置
+const ScannedHeader = "X-Binlog-Scanned"
+
+// filterMatches 条目是否通过过滤规则
+func filterMatches(f *config.ReplicationFilter, entry BinlogEntry) bool {
+	if f == nil {
+		return true
+	}
+	if slices.Contains(f.SkipOperations, entry.Operation) {
+		return false
+	}
+	if slices.Contains(f.ExcludeTables, entry.TableName) {
+		return false
+	}
+	return len(f.IncludeTables) == 0 || slices.Contains(f.IncludeTables, entry.TableName)
+}
+
+// filterIsEmpty 过滤规则是否为空（复制全部条目）
+func filterIsEmpty(f *config.ReplicationFilter) bool {
+	return f == nil || len(f.IncludeTables) == 0 && len(f.ExcludeTables) == 0 && len(f.SkipOperations) == 0
+}
+
+// filterEntries 按过滤规则筛选条目，返回通过的条目和检查到的最后一个条目ID
+func filterEntries(f *config.ReplicationFilter, entries []BinlogEntry) ([]BinlogEntry, uint64) {
+	if len(entries) == 0 {
+		return entries, 0
+	}
+	scanned := entries[len(entries)-1].ID
+	if filterIsEmpty(f) {
+		return entries, scanned
+	}
+	kept := make([]BinlogEntry, 0, len(entries))
+	for _, entry := range entries {
+		if filterMatches(f, entry) {
+			kept = append(kept, entry)
+		}
+	}
+	return kept, scanned
+}
+
+// filterToProto 将过滤规则转换为gRPC消息，空规则返回nil
+func filterToProto(f *config.ReplicationFilter) *replpb.ReplicationFilter {
+	if filterIsEmpty(f) {
+		return nil
+	}
+	return &replpb.ReplicationFilter{
+		IncludeTables:  f.IncludeTables,
+		ExcludeTables:  f.ExcludeTables,
+		SkipOperations: f.SkipOperations,
+	}
+}
+
+// filterFromProto 将gRPC消息转换为过滤规则
+func filterFromProto(p *replpb.ReplicationFilter) *config.ReplicationFilter {
+	if p == nil {
+		return nil
+	}
+	return &config.ReplicationFilter{
+		IncludeTables:  p.IncludeTables,
+		ExcludeTables:  p.ExcludeTables,
+		SkipOperations: p.SkipOperations,
+	}
+}
+
+// SetSlaveFilter 设置主节点为从节点执行的过滤规则，空规则表示不过滤；从节点未注册时返回错误
+func (m *Master) SetSlaveFilter(slaveID string, f *config.ReplicationFilter) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	info, exists := m.slaveInfos[slaveID]
+	if !exists {
+		return fmt.Errorf("slave %s is not registered", slaveID)
+	}
+	if filterIsEmpty(f) {
+		f = nil
+	}
+	info.Filter = f
+	m.slaveInfos[slaveID] = info
+	if f != nil {
+		log.Printf("Filtering binlog for slave %s: %+v", slaveID, *f)
+	}
+	return nil
+}
+
+// slaveFilter 主节点为从节点执行的过滤规则，没有时返回nil
+func (m *Master) slaveFilter(slaveID string) *config.ReplicationFilter {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.slaveInfos[slaveID].Filter
+}
+
+// FilterBinlogEntries 按从节点注册的过滤规则筛选条目，返回通过的条目和检查到的最后一个条目ID
+func (m *Master) FilterBinlogEntries(slaveID string, entries []BinlogEntry) ([]BinlogEntry, uint64) {
+	return filterEntries(m.slaveFilter(slaveID), entries)
+}
+
+// masterFilter 注册到主节点的过滤规则，不由主节点过滤时返回nil
+func (s *Slave) masterFilter() *config.ReplicationFilter {
+	if !s.config.FilterOnMaster || filterIsEmpty(&s.config.Filter) {
+		return nil
+	}
+	return &s.config.Filter
+}
+
+// advancePast 跳过主节点已按过滤规则过滤掉的条目：保存位置、写入中继日志并确认（调用方持有syncMutex）
+func (s *Slave) advancePast(position uint64) error {
+	if position <= s.currentPosition {
+		return nil
+	}
+	if err := s.db.SavePosition(s.slaveID, position); err != nil {
+		return err
+	}
+	s.currentPosition = position
+	if position > s.masterPosition {
+		s.masterPosition = position
+	}
+	if s.relay != nil {
+		s.relay.advanceTo(position)
+	}
+	s.ackApplied(position)
+	return nil
+}
+
+// parseScanned 解析 ScannedHeader，为空时返回0
+func parseScanned(value string) (uint64, error) {
+	if value == "" {
+		return 0, nil
+	}
+	scanned, err := strconv.ParseUint(value, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s header %q: %w", ScannedHeader, value, err)
+	}
+	return scanned, nil
+}
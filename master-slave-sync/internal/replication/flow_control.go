@@ -0,0 +1,124 @@
+package replication
+
+import (
+	"log"
+	"sort"
+	"time"
+)
+
+// defaultFlowControlMaxDelay 流量控制时每次写入的最大延迟，对应配置项为0时使用
+const defaultFlowControlMaxDelay = 500 * time.Millisecond
+
+// SlaveBacklog 从节点的积压情况（GET /api/flow_control）
+type SlaveBacklog struct {
+	SlaveID       string  `json:"slave_id"`
+	Status        string  `json:"status"`         // active 或 stale，失联的从节点不参与流量控制
+	Position      uint64  `json:"position"`       // 已确认的位置
+	BehindEntries uint64  `json:"behind_entries"` // 尚未确认的条目数
+	LagSeconds    float64 `json:"lag_seconds"`    // 复制延迟（秒）
+	Throttling    bool    `json:"throttling"`     // 是否因该从节点落后过多而延迟写入
+}
+
+// FlowControlReport 流量控制的配置、当前延迟和各从节点的积压
+type FlowControlReport struct {
+	Enabled         bool           `json:"enabled"`
+	MaxBehind       int            `json:"max_behind"`        // 活跃的从节点落后超过该条目数时开始延迟写入
+	MaxDelayMs      int64          `json:"max_delay_ms"`      // 每次写入的最大延迟
+	CurrentDelayMs  int64          `json:"current_delay_ms"`  // 按当前积压计算的每次写入的延迟
+	ThrottledWrites int64          `json:"throttled_writes"`  // 启动以来被延迟的写入数
+	TotalDelayMs    int64          `json:"total_delay_ms"`    // 启动以来写入被延迟的总时长
+	LastThrottledAt *time.Time     `json:"last_throttled_at"` // 最近一次延迟写入的时间
+	Slaves          []SlaveBacklog `json:"slaves"`            // 按落后的条目数从多到少排列
+}
+
+// flowControlMaxDelay 每次写入的最大延迟（调用方确认已开启流量控制）
+func (m *Master) flowControlMaxDelay() time.Duration {
+	return durationOrDefault(m.config.FlowControlMaxDelayMs, defaultFlowControlMaxDelay)
+}
+
+// backlogs 各从节点的积压及按其中落后最多的活跃从节点计算的写入延迟（调用方持有m.mu）
+// 落后不超过 FlowControlMaxBehind 时不延迟；超过后延迟随超出的条目数线性增长，落后达到两倍阈值时为最大延迟
+func (m *Master) backlogs(now time.Time) ([]SlaveBacklog, time.Duration) {
+	limit := uint64(m.config.FlowControlMaxBehind)
+	slaves := make([]SlaveBacklog, 0, len(m.slaveInfos))
+	var worst uint64
+	for _, info := range m.slaveInfos {
+		info = m.describeSlave(info, now)
+		lag, behind := m.slaveLag(info.CurrentPosition, now)
+		backlog := SlaveBacklog{
+			SlaveID:       info.ID,
+			Status:        info.Status,
+			Position:      info.CurrentPosition,
+			BehindEntries: behind,
+			LagSeconds:    lag.Seconds(),
+		}
+		if limit > 0 && info.Status == SlaveActive && behind > limit {
+			backlog.Throttling = true
+			worst = max(worst, behind)
+		}
+		slaves = append(slaves, backlog)
+	}
+	sort.Slice(slaves, func(i, j int) bool {
+		if slaves[i].BehindEntries != slaves[j].BehindEntries {
+			return slaves[i].BehindEntries > slaves[j].BehindEntries
+		}
+		return slaves[i].SlaveID < slaves[j].SlaveID
+	})
+
+	if worst == 0 {
+		return slaves, 0
+	}
+	maxDelay := m.flowControlMaxDelay()
+	excess := min(worst-limit, limit)
+	return slaves, time.Duration(float64(maxDelay) * float64(excess) / float64(limit))
+}
+
+// throttleWrite 有活跃的从节点落后过多时延迟写入的返回，让写入方放慢速度、给从节点追赶的时间
+// 写入已经提交，延迟的只是对写入方的确认；失联的从节点不参与计算，不会让主节点无限期变慢
+func (m *Master) throttleWrite() {
+	if m.config.FlowControlMaxBehind <= 0 {
+		return
+	}
+	m.mu.RLock()
+	_, delay := m.backlogs(time.Now())
+	m.mu.RUnlock()
+	if delay <= 0 {
+		return
+	}
+
+	m.mu.Lock()
+	m.throttledWrites++
+	m.throttleTotal += delay
+	first := m.lastThrottledAt.IsZero() || time.Since(m.lastThrottledAt) > time.Minute
+	m.lastThrottledAt = time.Now()
+	m.mu.Unlock()
+	if first {
+		log.Printf("Flow control: slaves are more than %d entries behind, delaying writes by up to %v",
+			m.config.FlowControlMaxBehind, m.flowControlMaxDelay())
+	}
+	time.Sleep(delay)
+}
+
+// FlowControlReport 获取流量控制的状态和各从节点的积压
+func (m *Master) FlowControlReport() FlowControlReport {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	slaves, delay := m.backlogs(time.Now())
+	report := FlowControlReport{
+		Enabled:         m.config.FlowControlMaxBehind > 0,
+		MaxBehind:       m.config.FlowControlMaxBehind,
+		CurrentDelayMs:  delay.Milliseconds(),
+		ThrottledWrites: m.throttledWrites,
+		TotalDelayMs:    m.throttleTotal.Milliseconds(),
+		Slaves:          slaves,
+	}
+	if report.Enabled {
+		report.MaxDelayMs = m.flowControlMaxDelay().Milliseconds()
+	}
+	if !m.lastThrottledAt.IsZero() {
+		last := m.lastThrottledAt
+		report.LastThrottledAt = &last
+	}
+	return report
+}
@@ -0,0 +1,83 @@
+package replication
+
+import (
+	"errors"
+	"fmt"
+	"log"
+)
+
+// ErrBinlogGap 收到的条目ID不连续，复制源缺少了部分条目，继续应用会静默丢失这些条目的数据
+var ErrBinlogGap = errors.New("binlog entries are not contiguous")
+
+// BinlogGapError 收到的条目与已应用的位置之间有缺口
+type BinlogGapError struct {
+	Position uint64 // 缺口之前的位置（已应用或同一批中上一个条目的ID）
+	Next     uint64 // 收到的下一个条目的ID
+}
+
+// Error 实现error接口
+func (e *BinlogGapError) Error() string {
+	return fmt.Sprintf("binlog gap after position %d: next entry received is %d", e.Position, e.Next)
+}
+
+// Unwrap 支持 errors.Is(err, ErrBinlogGap)
+func (e *BinlogGapError) Unwrap() error {
+	return ErrBinlogGap
+}
+
+// checkContiguous 检查条目从position之后连续：不超过position的条目（重发的已应用条目）跳过，
+// 其余条目的ID必须依次加一
+func checkContiguous(position uint64, entries []BinlogEntry) error {
+	for _, entry := range entries {
+		if entry.ID <= position {
+			continue
+		}
+		if entry.ID != position+1 {
+			return &BinlogGapError{Position: position, Next: entry.ID}
+		}
+		position = entry.ID
+	}
+	return nil
+}
+
+// expectsContiguous 复制源返回的条目是否应当连续：主节点按过滤规则筛选时被过滤的条目不在结果中，
+// 中继日志只保留中继实际应用的条目，这两种情况下的缺口是正常的
+func (s *Slave) expectsContiguous(fromMaster bool) bool {
+	return fromMaster && s.masterFilter() == nil
+}
+
+// requiresResync 同步错误是否只能通过全量重新同步恢复：需要的条目已被清理，或收到的条目有缺口
+func requiresResync(err error) bool {
+	return errors.Is(err, ErrPositionPurged) || errors.Is(err, ErrBinlogGap)
+}
+
+// autoResync 同步因需要的条目缺失而无法继续时，在后台开始全量重新同步（Resync 会停止并重新启动同步），
+// 返回true表示已开始，调用方的同步循环应当退出；未开启 AutoResync 或错误不需要重新同步时返回false
+func (s *Slave) autoResync(cause error) bool {
+	if !s.config.AutoResync || !requiresResync(cause) {
+		return false
+	}
+	s.syncMutex.Lock()
+	if s.resyncing {
+		s.syncMutex.Unlock()
+		return false
+	}
+	s.autoResyncs++
+	s.lastAutoResync = cause.Error()
+	s.syncMutex.Unlock()
+
+	log.Printf("Slave %s cannot continue incremental sync (%v), falling back to a full resync", s.slaveID, cause)
+	go func() {
+		result, err := s.Resync()
+		if err == nil {
+			log.Printf("Automatic resync of slave %s finished at position %d (was %d)", s.slaveID, result.Position, result.PreviousPosition)
+			return
+		}
+		// 失败时同步保持停止，重新启动后再次遇到同样的错误时重试；正在进行的重新同步结束后自己会重新启动同步
+		log.Printf("Automatic resync of slave %s failed: %v", s.slaveID, err)
+		if !errors.Is(err, ErrResyncInFlight) {
+			s.StartSync()
+		}
+	}()
+	return true
+}
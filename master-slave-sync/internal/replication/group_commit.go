@@ -0,0 +1,160 @@
+package replication
+
+import (
+	"runtime"
+	"time"
+)
+
+// groupCommitBuckets 批大小直方图各桶的上界（条目数），最后一个桶之后为溢出桶
+var groupCommitBuckets = [...]int{1, 2, 4, 8, 16, 32, 64, 128, 256}
+
+// GroupCommitStats 组提交的统计：每一组条目只写入并fsync一次，平均批大小越大，每个条目分摊的刷盘开销越小
+type GroupCommitStats struct {
+	WindowUs      int64             `json:"window_us"`       // 组长等待其他追加者加入的时间(微秒)
+	Groups        int64             `json:"groups"`          // 提交的组数（always策略下即fsync次数）
+	Entries       int64             `json:"entries"`         // 通过组提交写入的条目数
+	Failures      int64             `json:"failures"`        // 写入或fsync失败的组数，组内的所有追加都返回错误
+	AvgBatchSize  float64           `json:"avg_batch_size"`  // 平均每组的条目数
+	MaxBatchSize  int               `json:"max_batch_size"`  // 最大的一组的条目数
+	LastBatchSize int               `json:"last_batch_size"` // 最近一组的条目数
+	BatchSizes    []BatchSizeBucket `json:"batch_sizes"`     // 批大小分布，只包含有组的桶
+}
+
+// BatchSizeBucket 批大小直方图的一个桶
+type BatchSizeBucket struct {
+	Le    int   `json:"le"`    // 桶的上界（条目数），溢出桶为-1
+	Count int64 `json:"count"` // 组数
+}
+
+// pendingAppend 已分配ID、等待组提交的条目
+type pendingAppend struct {
+	entry BinlogEntry
+	done  chan error
+}
+
+// groupCommit 组提交的状态（window之外的字段由Binlog.mu保护）
+// 追加者把分配了ID的条目放入队列，队列空闲时第一个追加者成为组长：等待window让其他追加者加入，
+// 然后在锁内一次写入队列中的所有条目并只刷盘一次，发布这些条目后唤醒组内的所有追加者。
+// 组长写入和fsync期间到达的追加者阻塞在锁上，锁释放后组成下一组，因此window为0时并发追加也会成组
+type groupCommit struct {
+	window  time.Duration
+	queue   []pendingAppend
+	leading bool // 是否有组长正在等待或提交
+
+	groups    int64
+	entries   int64
+	failures  int64
+	maxBatch  int
+	lastBatch int
+	sizes     [len(groupCommitBuckets) + 1]int64
+}
+
+// record 记录一组的提交结果
+func (g *groupCommit) record(size int, err error) {
+	if err != nil {
+		g.failures++
+		return
+	}
+	g.groups++
+	g.entries += int64(size)
+	g.lastBatch = size
+	if size > g.maxBatch {
+		g.maxBatch = size
+	}
+	i := 0
+	for i < len(groupCommitBuckets) && size > groupCommitBuckets[i] {
+		i++
+	}
+	g.sizes[i]++
+}
+
+// stats 组提交的统计
+func (g *groupCommit) stats() *GroupCommitStats {
+	stats := &GroupCommitStats{
+		WindowUs:      g.window.Microseconds(),
+		Groups:        g.groups,
+		Entries:       g.entries,
+		Failures:      g.failures,
+		MaxBatchSize:  g.maxBatch,
+		LastBatchSize: g.lastBatch,
+		BatchSizes:    []BatchSizeBucket{},
+	}
+	if g.groups > 0 {
+		stats.AvgBatchSize = float64(g.entries) / float64(g.groups)
+	}
+	for i, c := range g.sizes {
+		if c == 0 {
+			continue
+		}
+		le := -1
+		if i < len(groupCommitBuckets) {
+			le = groupCommitBuckets[i]
+		}
+		stats.BatchSizes = append(stats.BatchSizes, BatchSizeBucket{Le: le, Count: c})
+	}
+	return stats
+}
+
+// appendGrouped 通过组提交追加条目：ID在入队时按顺序分配，条目在所在的组写入并刷盘之后才对从节点可见，
+// 返回时条目已按刷盘策略持久化；组写入失败时组内的所有条目都不追加，文件中已写入的部分也已撤销，
+// 下一组重新使用这些ID
+func (b *Binlog) appendGrouped(entry BinlogEntry) (uint64, error) {
+	b.mu.Lock()
+	if b.isClosed() {
+		b.mu.Unlock()
+		return 0, ErrBinlogClosed
+	}
+	entry.ID = b.position + uint64(len(b.group.queue)) + 1
+	entry.Timestamp = time.Now()
+	entry.Epoch = b.epoch
+	entry.Checksum = entry.computeChecksum()
+	done := make(chan error, 1)
+	b.group.queue = append(b.group.queue, pendingAppend{entry: entry, done: done})
+	lead := !b.group.leading
+	b.group.leading = true
+	b.mu.Unlock()
+
+	if lead {
+		b.commitGroup()
+	}
+	if err := <-done; err != nil {
+		return 0, err
+	}
+	return entry.ID, nil
+}
+
+// commitGroup 组长等待window后提交队列中的所有条目；写入失败时appendBatch已把文件恢复到这一组之前的状态
+// （无法恢复时binlog进入失败状态，拒绝之后的追加），之后才唤醒组内的追加者返回错误。
+// 等待期间binlog被关闭时整组以 ErrBinlogClosed 失败，不写入文件也不发布
+func (b *Binlog) commitGroup() {
+	if b.group.window > 0 {
+		time.Sleep(b.group.window)
+	} else {
+		// 让出处理器，使上一组刷盘期间阻塞在锁上的追加者先入队
+		runtime.Gosched()
+	}
+
+	b.mu.Lock()
+	batch := b.group.queue
+	b.group.queue = nil
+	entries := make([]BinlogEntry, len(batch))
+	for i, p := range batch {
+		entries[i] = p.entry
+	}
+	var err error
+	if b.isClosed() || b.file == nil {
+		err = ErrBinlogClosed
+	} else {
+		err = b.file.appendBatch(entries)
+	}
+	if err == nil {
+		b.publish(entries...)
+	}
+	b.group.record(len(batch), err)
+	b.group.leading = false
+	b.mu.Unlock()
+
+	for _, p := range batch {
+		p.done <- err
+	}
+}
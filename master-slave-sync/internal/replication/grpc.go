@@ -0,0 +1,275 @@
+package replication
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/keepalive"
+	"google.golang.org/grpc/status"
+
+	"master-slave-sync/internal/config"
+	"master-slave-sync/internal/replpb"
+)
+
+// 推送流的传输协议
+const (
+	TransportHTTP = "http" // WebSocket推送流和HTTP接口（默认）
+	TransportGRPC = "grpc" // gRPC的 Dump 流以及 Ack、Register 调用
+)
+
+// grpcService 主节点的gRPC复制服务
+type grpcService struct {
+	replpb.UnimplementedReplicationServer
+	master *Master
+}
+
+// NewGRPCServer 创建提供复制服务（Dump、Ack、Register）的gRPC服务器，由调用方监听端口并启动
+func NewGRPCServer(m *Master) *grpc.Server {
+	server := grpc.NewServer(
+		// 允许从节点在没有新条目时发送keepalive探测
+		grpc.KeepaliveEnforcementPolicy(keepalive.EnforcementPolicy{
+			MinTime:             streamHeartbeatInterval,
+			PermitWithoutStream: true,
+		}),
+	)
+	replpb.RegisterReplicationServer(server, &grpcService{master: m})
+	return server
+}
+
+// Dump 从请求的位置开始推送binlog，位置已被清理时返回 OUT_OF_RANGE
+func (g *grpcService) Dump(req *replpb.DumpRequest, stream replpb.Replication_DumpServer) error {
+	if err := g.authenticate(stream.Context(), req.SlaveId); err != nil {
+		return err
+	}
+	if err := g.master.CheckDownstream(req.ServerId); err != nil {
+		return status.Error(codes.FailedPrecondition, err.Error())
+	}
+
+	err := g.master.StreamBinlog(stream.Context(), req.SlaveId, req.Position, func(msg StreamMessage) error {
+		resp := &replpb.DumpResponse{Position: msg.Position, Scanned: msg.Scanned}
+		for _, entry := range msg.Entries {
+			resp.Entries = append(resp.Entries, entryToProto(entry))
+		}
+		return stream.Send(resp)
+	})
+
+	var purged *PositionPurgedError
+	if errors.As(err, &purged) {
+		return status.Error(codes.OutOfRange, err.Error())
+	}
+	return err
+}
+
+// Ack 记录从节点确认
+func (g *grpcService) Ack(ctx context.Context, req *replpb.AckRequest) (*replpb.AckResponse, error) {
+	if req.SlaveId == "" {
+		return nil, status.Error(codes.InvalidArgument, "slave_id is required")
+	}
+	if err := g.authenticate(ctx, req.SlaveId); err != nil {
+		return nil, err
+	}
+	if !g.master.RecordSlaveACK(req.SlaveId, req.Position) {
+		return nil, status.Error(codes.FailedPrecondition, ErrLeaseExpired.Error())
+	}
+	log.Printf("Received ACK from slave %s for position %d (grpc)", req.SlaveId, req.Position)
+	return &replpb.AckResponse{}, nil
+}
+
+// Register 注册从节点
+func (g *grpcService) Register(ctx context.Context, req *replpb.RegisterRequest) (*replpb.RegisterResponse, error) {
+	if req.SlaveId == "" {
+		return nil, status.Error(codes.InvalidArgument, "slave_id is required")
+	}
+	if err := g.authenticate(ctx, req.SlaveId); err != nil {
+		return nil, err
+	}
+	if err := g.master.CheckDownstream(req.ServerId); err != nil {
+		return nil, status.Error(codes.FailedPrecondition, err.Error())
+	}
+	// 注册请求不带位置，租约过期后重新注册时在下一次心跳检查位置
+	g.master.RegisterSlave(req.SlaveId, req.Host, int(req.Port), 0, false)
+	if err := g.master.SetSlaveFilter(req.SlaveId, filterFromProto(req.Filter)); err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	return &replpb.RegisterResponse{Chain: g.master.ReplicationChain()}, nil
+}
+
+// authenticate 校验调用携带的复制令牌，失败时返回 UNAUTHENTICATED
+func (g *grpcService) authenticate(ctx context.Context, slaveID string) error {
+	if err := g.master.auth.AuthenticateContext(ctx, slaveID); err != nil {
+		return status.Error(codes.Unauthenticated, err.Error())
+	}
+	return nil
+}
+
+// entryToProto 将binlog条目转换为gRPC消息
+func entryToProto(e BinlogEntry) *replpb.BinlogEntry {
+	return &replpb.BinlogEntry{
+		Id:                e.ID,
+		Operation:         e.Operation,
+		Format:            e.Format,
+		ServerId:          e.ServerID,
+		Epoch:             e.Epoch,
+		TableName:         e.TableName,
+		RecordId:          uint64(e.RecordID),
+		Data:              e.Data,
+		Before:            e.Before,
+		TimestampUnixNano: e.Timestamp.UnixNano(),
+		WriteId:           e.WriteID,
+		Checksum:          e.Checksum,
+	}
+}
+
+// entryFromProto 将gRPC消息转换为binlog条目，校验和覆盖的字段都能原样还原
+func entryFromProto(p *replpb.BinlogEntry) BinlogEntry {
+	return BinlogEntry{
+		ID:        p.Id,
+		Operation: p.Operation,
+		Format:    p.Format,
+		ServerID:  p.ServerId,
+		Epoch:     p.Epoch,
+		TableName: p.TableName,
+		RecordID:  uint(p.RecordId),
+		Data:      p.Data,
+		Before:    p.Before,
+		Timestamp: time.Unix(0, p.TimestampUnixNano),
+		WriteID:   p.WriteId,
+		Checksum:  p.Checksum,
+	}
+}
+
+// grpcMasterClient 从节点通过gRPC访问主节点的客户端
+type grpcMasterClient struct {
+	conn    *grpc.ClientConn         // 到主节点的连接（按需建立，断开后自动重连）
+	client  replpb.ReplicationClient // 复制服务客户端
+	timeout time.Duration            // 单次调用（Ack、Register）的超时
+}
+
+// newGRPCMasterClient 根据从节点配置创建gRPC客户端，此时不会建立连接
+func newGRPCMasterClient(cfg *config.SlaveConfig) (*grpcMasterClient, error) {
+	addr := fmt.Sprintf("%s:%d", cfg.MasterHost, cfg.MasterGRPCPort)
+	opts := []grpc.DialOption{
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithKeepaliveParams(keepalive.ClientParameters{
+			Time:    2 * streamHeartbeatInterval,
+			Timeout: streamHeartbeatInterval,
+		}),
+	}
+	if cfg.AuthToken != "" {
+		opts = append(opts, grpc.WithPerRPCCredentials(tokenCredentials(cfg.AuthToken)))
+	}
+	conn, err := grpc.NewClient(addr, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create grpc client for %s: %w", addr, err)
+	}
+	return &grpcMasterClient{
+		conn:    conn,
+		client:  replpb.NewReplicationClient(conn),
+		timeout: durationOrDefault(cfg.MasterTimeoutMs, defaultMasterTimeout),
+	}, nil
+}
+
+// ack 发送确认
+func (c *grpcMasterClient) ack(slaveID string, position uint64) error {
+	ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
+	defer cancel()
+	_, err := c.client.Ack(ctx, &replpb.AckRequest{SlaveId: slaveID, Position: position})
+	if status.Code(err) == codes.FailedPrecondition {
+		return ErrLeaseExpired
+	}
+	return err
+}
+
+// register 注册从节点，返回主节点的复制链
+func (c *grpcMasterClient) register(slaveID, host string, port int, serverID uint32, filter *config.ReplicationFilter) ([]uint32, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
+	defer cancel()
+	resp, err := c.client.Register(ctx, &replpb.RegisterRequest{
+		SlaveId:  slaveID,
+		Host:     host,
+		Port:     int32(port),
+		ServerId: serverID,
+		Filter:   filterToProto(filter),
+	})
+	if status.Code(err) == codes.FailedPrecondition {
+		return nil, fmt.Errorf("%w: %s", ErrReplicationLoop, status.Convert(err).Message())
+	}
+	if err != nil {
+		return nil, err
+	}
+	return resp.Chain, nil
+}
+
+// close 关闭连接
+func (c *grpcMasterClient) close() error {
+	return c.conn.Close()
+}
+
+// cancelCloser 把取消函数包装为io.Closer，用于停止gRPC推送流
+type cancelCloser context.CancelFunc
+
+// Close 取消推送流
+func (c cancelCloser) Close() error {
+	c()
+	return nil
+}
+
+// grpcStreamOnce 通过gRPC的 Dump 流接收并应用条目，直到流结束或同步停止
+func (s *Slave) grpcStreamOnce() error {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	stream, err := s.grpc.client.Dump(ctx, &replpb.DumpRequest{SlaveId: s.slaveID, Position: s.GetCurrentPosition(), ServerId: s.config.ServerID})
+	if err != nil {
+		return fmt.Errorf("failed to open grpc dump stream: %w", err)
+	}
+	if !s.setStream(cancelCloser(cancel)) {
+		return nil
+	}
+	defer s.setStream(nil)
+
+	// 与WebSocket推送流相同，长时间收不到任何消息（包括心跳）时断开
+	watchdog := time.AfterFunc(streamIdleTimeout, cancel)
+	defer watchdog.Stop()
+
+	// 主节点订阅成功后立即发送一次心跳
+	if _, err := stream.Recv(); err != nil {
+		if status.Code(err) == codes.FailedPrecondition {
+			return fmt.Errorf("%w: %s", ErrReplicationLoop, status.Convert(err).Message())
+		}
+		return fmt.Errorf("failed to open grpc dump stream: %w", err)
+	}
+	log.Printf("Slave %s connected to master grpc dump stream", s.slaveID)
+
+	for {
+		resp, err := stream.Recv()
+		if err != nil {
+			if !s.isRunning {
+				return nil
+			}
+			if status.Code(err) == codes.OutOfRange {
+				return fmt.Errorf("%w: %s, a full resync is required", ErrPositionPurged, status.Convert(err).Message())
+			}
+			return fmt.Errorf("grpc dump stream ended: %w", err)
+		}
+		watchdog.Reset(streamIdleTimeout)
+
+		s.observeMasterPosition(resp.Position)
+		if len(resp.Entries) == 0 && resp.Scanned == 0 {
+			continue
+		}
+		entries := make([]BinlogEntry, 0, len(resp.Entries))
+		for _, p := range resp.Entries {
+			entries = append(entries, entryFromProto(p))
+		}
+		if err := s.applyPushed(entries, resp.Scanned); err != nil {
+			return err
+		}
+	}
+}
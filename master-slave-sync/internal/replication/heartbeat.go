@@ -0,0 +1,230 @@
+package replication
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"master-slave-sync/internal/config"
+)
+
+// 从节点状态
+const (
+	SlaveActive = "active" // 按时发送心跳
+	SlaveStale  = "stale"  // 连续错过多次心跳，不计入已连接从节点，半同步不等待它
+)
+
+// 心跳默认参数，对应配置项为0时使用
+const (
+	defaultHeartbeatInterval  = 2 * time.Second
+	defaultHeartbeatMissLimit = 3
+)
+
+// HeartbeatRequest 从节点心跳，同时携带注册信息，主节点重启后据此重新登记从节点
+type HeartbeatRequest struct {
+	SlaveID  string `json:"slave_id"` // 从节点ID
+	Host     string `json:"host"`     // 从节点地址
+	Port     int    `json:"port"`     // 从节点API端口
+	Position uint64 `json:"position"` // 从节点已应用到的位置
+	// 由主节点执行的过滤规则（可选），主节点重启后据此恢复
+	Filter *config.ReplicationFilter `json:"filter,omitempty"`
+}
+
+// HeartbeatResponse 主节点对心跳的响应，附带已注册的从节点，从节点据此在主节点故障时发起选举
+type HeartbeatResponse struct {
+	Status string     `json:"status"`
+	Slaves []PeerInfo `json:"slaves,omitempty"`
+	// 租约过期后由这次心跳重新注册时的位置检查结果
+	PositionCheck PositionCheck `json:"position_check,omitempty"`
+	// 租约到期时间，从节点需要在此之前发送下一次心跳
+	LeaseExpiresAt time.Time `json:"lease_expires_at"`
+}
+
+// heartbeatInterval 从节点发送心跳的预期间隔
+func (m *Master) heartbeatInterval() time.Duration {
+	return durationOrDefault(m.config.HeartbeatIntervalMs, defaultHeartbeatInterval)
+}
+
+// staleAfter 超过该时长没有收到从节点的任何请求即视为失联
+func (m *Master) staleAfter() time.Duration {
+	limit := m.config.HeartbeatMissLimit
+	if limit <= 0 {
+		limit = defaultHeartbeatMissLimit
+	}
+	return time.Duration(limit) * m.heartbeatInterval()
+}
+
+// describeSlave 按最后一次请求的时间计算从节点的状态和错过的心跳数（调用方持有锁），
+// 从登记表恢复、尚未重新报告位置的从节点视为失联
+func (m *Master) describeSlave(info SlaveInfo, now time.Time) SlaveInfo {
+	silence := now.Sub(info.LastSeen)
+	info.MissedHeartbeats = int(silence / m.heartbeatInterval())
+	info.Status = SlaveActive
+	if silence > m.staleAfter() || info.Restored {
+		info.Status = SlaveStale
+	}
+	return info
+}
+
+// RecordHeartbeat 记录从节点心跳并续期租约；从节点未注册（如主节点重启后或租约已过期）时按心跳中的信息重新登记，
+// 租约过期后重新登记时检查心跳中的位置，返回检查结果和新的租约到期时间
+func (m *Master) RecordHeartbeat(hb HeartbeatRequest) (PositionCheck, time.Time) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	info, exists := m.slaveInfos[hb.SlaveID]
+	if !exists {
+		info = SlaveInfo{ID: hb.SlaveID}
+		log.Printf("Slave %s registered by heartbeat (%s:%d)", hb.SlaveID, hb.Host, hb.Port)
+	} else if m.describeSlave(info, time.Now()).Status == SlaveStale {
+		log.Printf("Slave %s is active again after %v without heartbeats", hb.SlaveID, time.Since(info.LastSeen).Round(time.Second))
+	}
+	if hb.Host != "" {
+		info.Host = hb.Host
+		info.Port = hb.Port
+	}
+	if hb.Filter != nil {
+		info.Filter = hb.Filter
+	}
+	info.LastSeen = time.Now()
+	check := m.renewLease(&info, exists, hb.Position, true)
+	if info.Restored {
+		check = m.reconcileRestored(&info, hb.Position)
+	}
+	if hb.Position > info.CurrentPosition {
+		info.CurrentPosition = hb.Position
+	}
+	m.slaveInfos[hb.SlaveID] = info
+	return check, info.LeaseExpiresAt
+}
+
+// activeSlaveCount 按时发送心跳的从节点数
+func (m *Master) activeSlaveCount() int {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	now := time.Now()
+	count := 0
+	for _, info := range m.slaveInfos {
+		if m.describeSlave(info, now).Status == SlaveActive {
+			count++
+		}
+	}
+	return count
+}
+
+// CheckSlaveHeartbeats 检查所有从节点的心跳：新失联的从节点输出告警日志，
+// 租约到期的从节点被移除（不再计入半同步、不再阻止binlog清理），返回新失联和被移除的从节点
+func (m *Master) CheckSlaveHeartbeats() (stale []string, evicted []string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	for id, info := range m.slaveInfos {
+		current := m.describeSlave(info, now)
+		if now.After(info.LeaseExpiresAt) {
+			m.expireLease(info, now)
+			evicted = append(evicted, id)
+			continue
+		}
+		if current.Status == SlaveStale && info.Status != SlaveStale {
+			stale = append(stale, id)
+			log.Printf("Warning: slave %s missed %d heartbeats, marked stale", id, current.MissedHeartbeats)
+		}
+		m.slaveInfos[id] = current
+	}
+	return stale, evicted
+}
+
+// StartHeartbeatMonitor 启动从节点心跳检查任务，每个心跳间隔检查一次，并把从节点信息表写入登记表
+func (m *Master) StartHeartbeatMonitor() {
+	m.mu.Lock()
+	if m.heartbeatStop != nil {
+		m.mu.Unlock()
+		return
+	}
+	stop := make(chan struct{})
+	m.heartbeatStop = stop
+	m.mu.Unlock()
+
+	go func() {
+		ticker := time.NewTicker(m.heartbeatInterval())
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				m.CheckSlaveHeartbeats()
+				m.saveSlavesOrLog()
+			}
+		}
+	}()
+}
+
+// StopHeartbeatMonitor 停止从节点心跳检查任务
+func (m *Master) StopHeartbeatMonitor() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.heartbeatStop != nil {
+		close(m.heartbeatStop)
+		m.heartbeatStop = nil
+	}
+}
+
+// heartbeatLoop 从节点定期向主节点发送心跳，直到stop被关闭
+func (s *Slave) heartbeatLoop(stop <-chan struct{}) {
+	ticker := time.NewTicker(durationOrDefault(s.config.HeartbeatIntervalMs, defaultHeartbeatInterval))
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			// 熔断期间每次都会被拒绝，不可达和恢复由客户端在状态变化时记录
+			if err := s.sendHeartbeat(); err != nil && !errors.Is(err, ErrCircuitOpen) {
+				log.Printf("Warning: Failed to send heartbeat: %v", err)
+			}
+		}
+	}
+}
+
+// sendHeartbeat 向主节点发送一次心跳
+func (s *Slave) sendHeartbeat() error {
+	jsonData, err := json.Marshal(HeartbeatRequest{
+		SlaveID:  s.slaveID,
+		Host:     s.config.Host,
+		Port:     s.config.APIPort,
+		Position: s.GetCurrentPosition(),
+		Filter:   s.masterFilter(),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to marshal heartbeat: %w", err)
+	}
+
+	resp, err := s.doRequest(http.MethodPost, fmt.Sprintf("%s/api/heartbeat", s.masterURL), jsonData)
+	if err != nil {
+		return fmt.Errorf("failed to send heartbeat: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("master returned error status for heartbeat: %s", resp.Status)
+	}
+
+	// 记录同一主节点下的其他从节点（旧版本主节点不返回时保留之前的列表）
+	var hb HeartbeatResponse
+	if err := json.NewDecoder(resp.Body).Decode(&hb); err == nil {
+		if hb.Slaves != nil {
+			s.setPeers(hb.Slaves)
+		}
+		logPositionCheck(hb.PositionCheck)
+	}
+	return nil
+}
@@ -0,0 +1,278 @@
+package replication
+
+import (
+	"log"
+	"sort"
+	"sync"
+	"time"
+)
+
+// 热点统计参数
+const (
+	hotStatsWindow      = 5 * time.Minute // 滑动窗口长度
+	hotRowShare         = 0.5             // 单行占窗口内应用耗时的比例超过该值时告警
+	hotRowMinOps        = 20              // 窗口内至少应用这么多条目才判断热点，避免刚启动时误报
+	hotRowWarnCooldown  = time.Minute     // 同一行两次告警的最小间隔
+	defaultHotStatLimit = 10              // 默认返回的热点行/表数量
+)
+
+// applySample 一次binlog条目应用的采样
+type applySample struct {
+	at        time.Time
+	table     string
+	recordID  uint
+	operation string
+	duration  time.Duration
+}
+
+// rowKey 热点行的标识
+type rowKey struct {
+	table    string
+	recordID uint
+}
+
+// applyCounter 窗口内的累计应用次数与耗时
+type applyCounter struct {
+	ops         int64
+	duration    time.Duration
+	byOperation map[string]int64
+	last        time.Time
+}
+
+// add 计入一次采样
+func (c *applyCounter) add(s applySample) {
+	if c.byOperation == nil {
+		c.byOperation = make(map[string]int64)
+	}
+	c.ops++
+	c.duration += s.duration
+	c.byOperation[s.operation]++
+	if s.at.After(c.last) {
+		c.last = s.at
+	}
+}
+
+// remove 扣除一次移出窗口的采样
+func (c *applyCounter) remove(s applySample) {
+	c.ops--
+	c.duration -= s.duration
+	c.byOperation[s.operation]--
+}
+
+// hotStats 从节点按表和记录统计滑动窗口内的应用情况
+// 采样按时间顺序追加，过期的采样从头部移出并从聚合中扣除，查询时无需重新扫描窗口
+type hotStats struct {
+	samples  []applySample
+	tables   map[string]*applyCounter
+	rows     map[rowKey]*applyCounter
+	total    time.Duration
+	lastWarn map[rowKey]time.Time
+	mu       sync.Mutex
+}
+
+// HotTable 窗口内单个表的应用统计
+type HotTable struct {
+	Table       string           `json:"table"`
+	Ops         int64            `json:"ops"`          // 应用次数
+	ByOperation map[string]int64 `json:"by_operation"` // 按操作类型的应用次数
+	ApplyMs     float64          `json:"apply_ms"`     // 应用总耗时(毫秒)
+	Share       float64          `json:"share"`        // 占窗口内应用总耗时的比例
+	LastApplied time.Time        `json:"last_applied"` // 最近一次应用时间
+}
+
+// HotRow 窗口内单条记录的应用统计
+type HotRow struct {
+	Table       string           `json:"table"`
+	RecordID    uint             `json:"record_id"`
+	Ops         int64            `json:"ops"`
+	ByOperation map[string]int64 `json:"by_operation"`
+	ApplyMs     float64          `json:"apply_ms"`
+	Share       float64          `json:"share"`
+	LastApplied time.Time        `json:"last_applied"`
+}
+
+// HotStats 从节点的复制热点报告
+type HotStats struct {
+	WindowSeconds int64      `json:"window_seconds"` // 统计窗口(秒)
+	TotalOps      int64      `json:"total_ops"`      // 窗口内应用的条目数
+	TotalApplyMs  float64    `json:"total_apply_ms"` // 窗口内应用总耗时(毫秒)
+	Tables        []HotTable `json:"tables"`         // 按应用耗时降序的表
+	Rows          []HotRow   `json:"rows"`           // 按应用耗时降序的记录
+	DominantRow   *HotRow    `json:"dominant_row"`   // 占应用耗时超过阈值的记录，没有时为null
+}
+
+// record 记录一次条目应用，单行占比超过阈值时输出告警
+func (h *hotStats) record(s applySample) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.tables == nil {
+		h.tables = make(map[string]*applyCounter)
+		h.rows = make(map[rowKey]*applyCounter)
+		h.lastWarn = make(map[rowKey]time.Time)
+	}
+	h.prune(s.at)
+
+	key := rowKey{table: s.table, recordID: s.recordID}
+	table := h.tables[s.table]
+	if table == nil {
+		table = &applyCounter{}
+		h.tables[s.table] = table
+	}
+	row := h.rows[key]
+	if row == nil {
+		row = &applyCounter{}
+		h.rows[key] = row
+	}
+	table.add(s)
+	row.add(s)
+	h.total += s.duration
+	h.samples = append(h.samples, s)
+
+	if int64(len(h.samples)) < hotRowMinOps || h.total <= 0 {
+		return
+	}
+	share := float64(row.duration) / float64(h.total)
+	if share <= hotRowShare || s.at.Sub(h.lastWarn[key]) < hotRowWarnCooldown {
+		return
+	}
+	h.lastWarn[key] = s.at
+	log.Printf("Warning: replication hotspot on %s#%d: %d of %d applies, %.0f%% of apply time in the last %v",
+		s.table, s.recordID, row.ops, len(h.samples), share*100, hotStatsWindow)
+}
+
+// prune 移出窗口外的采样（调用方持有锁）
+func (h *hotStats) prune(now time.Time) {
+	cutoff := now.Add(-hotStatsWindow)
+	n := 0
+	for n < len(h.samples) && h.samples[n].at.Before(cutoff) {
+		s := h.samples[n]
+		key := rowKey{table: s.table, recordID: s.recordID}
+		h.total -= s.duration
+		if c := h.tables[s.table]; c != nil {
+			c.remove(s)
+			if c.ops == 0 {
+				delete(h.tables, s.table)
+			}
+		}
+		if c := h.rows[key]; c != nil {
+			c.remove(s)
+			if c.ops == 0 {
+				delete(h.rows, key)
+			}
+		}
+		n++
+	}
+	h.samples = h.samples[n:]
+	for key, at := range h.lastWarn {
+		if at.Before(cutoff) {
+			delete(h.lastWarn, key)
+		}
+	}
+}
+
+// report 生成窗口内的热点报告，limit限制返回的表和记录数
+func (h *hotStats) report(limit int) HotStats {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.tables != nil {
+		h.prune(time.Now())
+	}
+	stats := HotStats{
+		WindowSeconds: int64(hotStatsWindow.Seconds()),
+		TotalOps:      int64(len(h.samples)),
+		TotalApplyMs:  durationMs(h.total),
+		Tables:        []HotTable{},
+		Rows:          []HotRow{},
+	}
+
+	for name, c := range h.tables {
+		stats.Tables = append(stats.Tables, HotTable{
+			Table:       name,
+			Ops:         c.ops,
+			ByOperation: c.operations(),
+			ApplyMs:     durationMs(c.duration),
+			Share:       h.share(c.duration),
+			LastApplied: c.last,
+		})
+	}
+	for key, c := range h.rows {
+		stats.Rows = append(stats.Rows, HotRow{
+			Table:       key.table,
+			RecordID:    key.recordID,
+			Ops:         c.ops,
+			ByOperation: c.operations(),
+			ApplyMs:     durationMs(c.duration),
+			Share:       h.share(c.duration),
+			LastApplied: c.last,
+		})
+	}
+	sort.Slice(stats.Tables, func(i, j int) bool {
+		if stats.Tables[i].ApplyMs != stats.Tables[j].ApplyMs {
+			return stats.Tables[i].ApplyMs > stats.Tables[j].ApplyMs
+		}
+		return stats.Tables[i].Table < stats.Tables[j].Table
+	})
+	sort.Slice(stats.Rows, func(i, j int) bool {
+		if stats.Rows[i].ApplyMs != stats.Rows[j].ApplyMs {
+			return stats.Rows[i].ApplyMs > stats.Rows[j].ApplyMs
+		}
+		return stats.Rows[i].Ops > stats.Rows[j].Ops
+	})
+
+	if len(stats.Rows) > 0 && stats.TotalOps >= hotRowMinOps && stats.Rows[0].Share > hotRowShare {
+		dominant := stats.Rows[0]
+		stats.DominantRow = &dominant
+	}
+	if len(stats.Tables) > limit {
+		stats.Tables = stats.Tables[:limit]
+	}
+	if len(stats.Rows) > limit {
+		stats.Rows = stats.Rows[:limit]
+	}
+	return stats
+}
+
+// share 计算耗时占窗口内应用总耗时的比例（调用方持有锁）
+func (h *hotStats) share(d time.Duration) float64 {
+	if h.total <= 0 {
+		return 0
+	}
+	return float64(d) / float64(h.total)
+}
+
+// operations 复制按操作类型的计数，去掉已经移出窗口的类型
+func (c *applyCounter) operations() map[string]int64 {
+	ops := make(map[string]int64, len(c.byOperation))
+	for op, n := range c.byOperation {
+		if n > 0 {
+			ops[op] = n
+		}
+	}
+	return ops
+}
+
+// durationMs 将时长转换为毫秒
+func durationMs(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
+
+// recordApply 记录一次条目应用的耗时
+func (s *Slave) recordApply(entry BinlogEntry, at time.Time, duration time.Duration) {
+	s.hot.record(applySample{
+		at:        at,
+		table:     entry.TableName,
+		recordID:  entry.RecordID,
+		operation: entry.Operation,
+		duration:  duration,
+	})
+}
+
+// HotStats 获取最近窗口内应用最频繁、耗时最多的表和记录，limit<=0时使用默认值10
+func (s *Slave) HotStats(limit int) HotStats {
+	if limit <= 0 {
+		limit = defaultHotStatLimit
+	}
+	return s.hot.report(limit)
+}
@@ -0,0 +1,189 @@
+package replication
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+
+	"master-slave-sync/internal/storage"
+)
+
+// commitWrite 在同一个事务中写入 records 表并记录复制日志，见 commitRow
+func (m *Master) commitWrite(operation string, durability Durability, write func(tx *storage.DB) (*storage.Record, error)) (*storage.Record, uint64, *ackWait, error) {
+	var record *storage.Record
+	_, pos, waited, err := m.commitRow(RecordsTable, operation, durability, func(tx *storage.DB) (interface{}, error) {
+		var err error
+		record, err = write(tx)
+		return record, err
+	})
+	if err != nil {
+		return nil, 0, nil, err
+	}
+	return record, pos, waited, nil
+}
+
+// commitRow 在同一个事务中执行数据写入并记录复制日志，提交后追加binlog并删除复制日志
+// 数据写入与binlog追加之间崩溃时，复制日志保留在表中，重启后由 RecoverJournal 补发，
+// 从节点不会永久丢失已提交的写入
+// 半同步等待点为 after_sync 且需要等待确认时，在事务提交之前追加binlog并等待从节点确认（或超时），
+// 返回等待的结果；其余情况返回nil，由 finishWrite 在提交后等待
+func (m *Master) commitRow(tableName, operation string, durability Durability, write func(tx *storage.DB) (interface{}, error)) (interface{}, uint64, *ackWait, error) {
+	table, err := LookupTable(tableName)
+	if err != nil {
+		return nil, 0, nil, err
+	}
+	if m.tail != nil {
+		return m.commitTailed(durability, write)
+	}
+	// 执行DDL期间不开始新的写入，见 ExecuteDDL
+	m.ddlMu.RLock()
+	defer m.ddlMu.RUnlock()
+
+	afterSync := m.waitsBeforeCommit(durability)
+	var row interface{}
+	var entry BinlogEntry
+	var pos uint64
+	var waited *ackWait
+
+	err = m.db.Transaction(func(tx *storage.DB) error {
+		var err error
+		var statements []storage.Statement
+		target := tx
+		if m.binlogFormat == BinlogFormatStatement {
+			target = tx.WithStatementLog(&statements)
+		}
+		if row, err = write(target); err != nil {
+			return err
+		}
+		// 更新时返回 RowUpdate 的写入同时提供更新前的行，作为条目的前映像
+		var beforeRow interface{}
+		if update, ok := row.(RowUpdate); ok {
+			row, beforeRow = update.After, update.Before
+		}
+		id, data, err := table.Encode(row)
+		if err != nil {
+			return err
+		}
+		var format string
+		var before []byte
+		if m.binlogFormat == BinlogFormatStatement {
+			// 基于语句的条目只记录执行的语句，行数据只用于获取行ID
+			format = BinlogFormatStatement
+			if data, err = json.Marshal(statements); err != nil {
+				return fmt.Errorf("failed to serialize statements: %w", err)
+			}
+		} else if operation == OpUpdate && beforeRow != nil {
+			if _, before, err = table.Encode(beforeRow); err != nil {
+				return err
+			}
+		}
+		writeID, err := tx.AppendJournal(&storage.JournalEntry{Operation: operation, Table: tableName, Format: format, RecordID: id, Data: data, Before: before})
+		if err != nil {
+			return err
+		}
+		entry = BinlogEntry{
+			Operation: operation,
+			Format:    format,
+			ServerID:  m.config.ServerID,
+			TableName: tableName,
+			RecordID:  id,
+			Data:      data,
+			Before:    before,
+			WriteID:   writeID,
+		}
+		if !afterSync {
+			return nil
+		}
+
+		// after_sync：条目先发给从节点并等待确认，确认（或超时降级）之后才提交，
+		// 在此之前其他客户端读不到这次写入；等待期间事务持有的行锁不会释放
+		if pos, err = m.binlog.appendWrite(entry); err != nil {
+			return fmt.Errorf("failed to append binlog: %w", err)
+		}
+		waited = m.awaitACK(pos)
+		return nil
+	})
+	if err != nil && pos != 0 {
+		// 条目已在binlog中（从节点可能已经应用），按binlog条目补上主节点的写入，与MySQL崩溃恢复时
+		// 提交binlog中已有的事务相同，避免从节点有而主节点没有这次写入
+		if rfErr := m.rollForward(pos); rfErr != nil {
+			return nil, 0, nil, fmt.Errorf("commit failed after binlog entry %d was shipped, master and slaves may diverge: %w (roll forward: %v)", pos, err, rfErr)
+		}
+		log.Printf("Warning: commit failed after binlog entry %d was shipped (%v), rolled forward from binlog", pos, err)
+		return row, pos, waited, nil
+	}
+	if err != nil {
+		return nil, 0, nil, err
+	}
+
+	if !afterSync {
+		if pos, err = m.binlog.appendWrite(entry); err != nil {
+			// 写入已提交，复制日志保留在表中，下次启动时由 RecoverJournal 补发到binlog
+			return nil, 0, nil, fmt.Errorf("write committed but binlog append failed, will be re-emitted on restart: %w", err)
+		}
+	}
+	if err := m.db.RemoveJournal(entry.WriteID); err != nil {
+		// 残留的复制日志在下次启动时会因binlog中已有对应条目而被跳过
+		log.Printf("Warning: %v", err)
+	}
+	return row, pos, waited, nil
+}
+
+// rollForward 在主节点上应用binlog中位置为pos的条目（after_sync 追加binlog后事务提交失败时）
+func (m *Master) rollForward(pos uint64) error {
+	for _, entry := range m.binlog.GetEntries(pos - 1) {
+		if entry.ID == pos {
+			return ApplyEntry(m.db, entry)
+		}
+	}
+	return fmt.Errorf("binlog entry %d not found", pos)
+}
+
+// RecoverJournal 补发已提交但未写入binlog的写入，返回补发的条数
+// binlog中已包含的写入（追加binlog后、删除复制日志前崩溃）只删除复制日志，不会重复补发；
+// binlog持久化时启动后加载的条目带有复制日志ID，因此跨重启也能据此去重
+func (m *Master) RecoverJournal() (int, error) {
+	pending, err := m.db.PendingJournal()
+	if err != nil {
+		return 0, err
+	}
+	if len(pending) == 0 {
+		return 0, nil
+	}
+
+	logged := m.binlog.loggedWrites()
+	recovered := 0
+	for _, entry := range pending {
+		if !logged[entry.ID] {
+			table := entry.Table
+			if table == "" {
+				table = RecordsTable
+			}
+			pos, err := m.binlog.appendWrite(BinlogEntry{
+				Operation: entry.Operation,
+				Format:    entry.Format,
+				ServerID:  m.config.ServerID,
+				TableName: table,
+				RecordID:  entry.RecordID,
+				Data:      entry.Data,
+				Before:    entry.Before,
+				WriteID:   entry.ID,
+			})
+			if err != nil {
+				return recovered, err
+			}
+			log.Printf("Recovered %s of %s row %d from journal at binlog position %d", entry.Operation, table, entry.RecordID, pos)
+			recovered++
+		}
+		if err := m.db.RemoveJournal(entry.ID); err != nil {
+			return recovered, err
+		}
+	}
+
+	m.mu.Lock()
+	m.recovered += recovered
+	m.mu.Unlock()
+
+	log.Printf("Journal recovery finished: %d pending, %d re-emitted to binlog", len(pending), recovered)
+	return recovered, nil
+}
@@ -0,0 +1,78 @@
+package replication
+
+import "time"
+
+// firstEntryAfter 获取指定位置之后的第一个条目，位置之后的条目已被清理时返回最早可用的条目
+func (b *Binlog) firstEntryAfter(position uint64) (BinlogEntry, bool) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	if len(b.entries) == 0 || position >= b.position {
+		return BinlogEntry{}, false
+	}
+	if position < b.entries[0].ID {
+		return b.entries[0], true
+	}
+	i := position + 1 - b.entries[0].ID
+	if i >= uint64(len(b.entries)) {
+		return BinlogEntry{}, false
+	}
+	return b.entries[i], true
+}
+
+// slaveLag 计算从节点的复制延迟：从节点已确认的位置之后最早的条目在主节点上写入了多久，
+// 已追上时为0。与MySQL的 Seconds_Behind_Master 相同，从节点停止确认时延迟持续增长
+func (m *Master) slaveLag(ackedPosition uint64, now time.Time) (lag time.Duration, behind uint64) {
+	current := m.binlog.GetCurrentPosition()
+	if ackedPosition >= current {
+		return 0, 0
+	}
+	behind = current - ackedPosition
+	if entry, ok := m.binlog.firstEntryAfter(ackedPosition); ok && now.After(entry.Timestamp) {
+		lag = now.Sub(entry.Timestamp)
+	}
+	return lag, behind
+}
+
+// beginApply 收到一批待应用的条目时记录其中最早条目的写入时间、已知的主节点位置和已收到的位置（调用方持有syncMutex）
+func (s *Slave) beginApply(entries []BinlogEntry) {
+	s.pendingSince = entries[0].Timestamp
+	last := entries[len(entries)-1].ID
+	if last > s.masterPosition {
+		s.masterPosition = last
+	}
+	if last > s.receivedPosition {
+		s.receivedPosition = last
+	}
+}
+
+// finishApply 一批条目应用成功后记录最后一个条目的写入时间（调用方持有syncMutex）
+func (s *Slave) finishApply(entries []BinlogEntry) {
+	s.lastAppliedTime = entries[len(entries)-1].Timestamp
+	s.pendingSince = time.Time{}
+}
+
+// observeMasterPosition 记录从主节点得知的最新binlog位置（推送流消息或空的拉取结果）
+func (s *Slave) observeMasterPosition(position uint64) {
+	s.syncMutex.Lock()
+	defer s.syncMutex.Unlock()
+	s.masterPosition = position
+}
+
+// lag 计算从节点的复制延迟（调用方持有syncMutex）：已应用到已知的主节点位置时为0；
+// 否则为当前时间与最早未应用条目在主节点上的写入时间之差（应用失败时该条目保持不变，延迟持续增长），
+// 不知道未应用的条目时退化为与最后应用的条目比较
+// 主节点不可达时已知的主节点位置不再更新，延迟可能被低估
+func (s *Slave) lag(now time.Time) time.Duration {
+	if s.currentPosition >= s.masterPosition {
+		return 0
+	}
+	since := s.pendingSince
+	if since.IsZero() {
+		since = s.lastAppliedTime
+	}
+	if since.IsZero() || now.Before(since) {
+		return 0
+	}
+	return now.Sub(since)
+}
@@ -0,0 +1,205 @@
+package replication
+
+import (
+	"sync"
+	"time"
+)
+
+// 复制延迟直方图相关参数
+const (
+	latencyWindow           = time.Minute // 分位数覆盖最近一到两个窗口内的确认
+	maxLatencySamplesPerACK = 1000        // 一次确认最多计入的条目数，确认大量条目时只计入最后的部分
+	latencyBucketCount      = 18          // 桶数（不含最后的溢出桶），上界从1毫秒起每桶翻倍，最大约131秒
+)
+
+// latencyBuckets 直方图各桶的上界
+var latencyBuckets = func() []time.Duration {
+	buckets := make([]time.Duration, latencyBucketCount)
+	for i := range buckets {
+		buckets[i] = time.Millisecond << i
+	}
+	return buckets
+}()
+
+// ReplicationLatency 端到端复制延迟：从主节点追加binlog条目到收到从节点应用该条目的确认，
+// 每个从节点确认的每个条目计为一个样本，覆盖最近1到2分钟内的确认
+type ReplicationLatency struct {
+	Samples int64           // 计入的样本数
+	P50Ms   float64         // 中位数(毫秒)
+	P95Ms   float64         // 95分位(毫秒)
+	P99Ms   float64         // 99分位(毫秒)
+	MaxMs   float64         // 最大值(毫秒)
+	Buckets []LatencyBucket // 直方图，只包含有样本的桶
+}
+
+// LatencyBucket 直方图的一个桶
+type LatencyBucket struct {
+	LeMs  float64 // 桶的上界(毫秒)，溢出桶为-1
+	Count int64   // 样本数
+}
+
+// latencyHistogram 一个时间窗口内的样本计数
+type latencyHistogram struct {
+	counts [latencyBucketCount + 1]int64
+	total  int64
+	max    time.Duration
+}
+
+// add 计入一个样本
+func (h *latencyHistogram) add(d time.Duration) {
+	i := 0
+	for i < latencyBucketCount && d > latencyBuckets[i] {
+		i++
+	}
+	h.counts[i]++
+	h.total++
+	if d > h.max {
+		h.max = d
+	}
+}
+
+// merge 合并另一个窗口的样本
+func (h *latencyHistogram) merge(o *latencyHistogram) {
+	for i, c := range o.counts {
+		h.counts[i] += c
+	}
+	h.total += o.total
+	if o.max > h.max {
+		h.max = o.max
+	}
+}
+
+// quantile 分位数：在样本所在的桶内按线性插值估计，溢出桶取最大值
+func (h *latencyHistogram) quantile(q float64) time.Duration {
+	if h.total == 0 {
+		return 0
+	}
+	rank := q * float64(h.total)
+	var seen int64
+	for i, c := range h.counts {
+		if c == 0 || float64(seen+c) < rank {
+			seen += c
+			continue
+		}
+		if i == latencyBucketCount {
+			return h.max
+		}
+		var lower time.Duration
+		if i > 0 {
+			lower = latencyBuckets[i-1]
+		}
+		upper := min(latencyBuckets[i], h.max)
+		if upper < lower {
+			return upper
+		}
+		frac := (rank - float64(seen)) / float64(c)
+		return lower + time.Duration(frac*float64(upper-lower))
+	}
+	return h.max
+}
+
+// latencyRecorder 按时间窗口轮换的延迟直方图：统计最近两个窗口，旧样本随窗口轮换淘汰，
+// 调整同步间隔或传输方式后一到两分钟内分位数即可反映变化
+type latencyRecorder struct {
+	mu          sync.Mutex
+	current     latencyHistogram
+	previous    latencyHistogram
+	windowStart time.Time
+}
+
+// rotate 当前窗口已结束时轮换（调用方持有锁）
+func (r *latencyRecorder) rotate(now time.Time) {
+	elapsed := now.Sub(r.windowStart)
+	if elapsed < latencyWindow {
+		return
+	}
+	if elapsed < 2*latencyWindow {
+		r.previous = r.current
+	} else {
+		r.previous = latencyHistogram{}
+	}
+	r.current = latencyHistogram{}
+	r.windowStart = now
+}
+
+// record 计入一次确认覆盖的条目的追加时间
+func (r *latencyRecorder) record(appended []time.Time, now time.Time) {
+	if len(appended) == 0 {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.rotate(now)
+	for _, at := range appended {
+		if d := now.Sub(at); d >= 0 {
+			r.current.add(d)
+		}
+	}
+}
+
+// snapshot 最近两个窗口的分位数和直方图
+func (r *latencyRecorder) snapshot(now time.Time) ReplicationLatency {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.rotate(now)
+
+	var h latencyHistogram
+	h.merge(&r.current)
+	h.merge(&r.previous)
+	result := ReplicationLatency{
+		Samples: h.total,
+		P50Ms:   durationMs(h.quantile(0.50)),
+		P95Ms:   durationMs(h.quantile(0.95)),
+		P99Ms:   durationMs(h.quantile(0.99)),
+		MaxMs:   durationMs(h.max),
+	}
+	for i, c := range h.counts {
+		if c == 0 {
+			continue
+		}
+		le := -1.0
+		if i < latencyBucketCount {
+			le = durationMs(latencyBuckets[i])
+		}
+		result.Buckets = append(result.Buckets, LatencyBucket{LeMs: le, Count: c})
+	}
+	return result
+}
+
+// appendTimes 位置在 (after, upto] 之间的条目的追加时间，最多返回最后limit个，已被清理的条目跳过
+func (b *Binlog) appendTimes(after, upto uint64, limit int) []time.Time {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	if len(b.entries) == 0 {
+		return nil
+	}
+	first := b.entries[0].ID
+	upto = min(upto, b.entries[len(b.entries)-1].ID)
+	if upto <= after || upto < first {
+		return nil
+	}
+	start := max(after+1, first)
+	if upto-start+1 > uint64(limit) {
+		start = upto - uint64(limit) + 1
+	}
+	times := make([]time.Time, 0, upto-start+1)
+	for _, entry := range b.entries[start-first : upto-first+1] {
+		times = append(times, entry.Timestamp)
+	}
+	return times
+}
+
+// recordAckLatency 记录一次确认覆盖的条目（上次确认的位置之后到本次确认的位置）的端到端延迟（调用方持有m.mu）
+// 从节点注册后的第一次确认不计入：它可能覆盖从节点连接之前很久写入的条目
+func (m *Master) recordAckLatency(previous, position uint64) {
+	if previous == 0 || position <= previous {
+		return
+	}
+	m.latency.record(m.binlog.appendTimes(previous, position, maxLatencySamplesPerACK), time.Now())
+}
+
+// ReplicationLatency 最近的端到端复制延迟分位数和直方图
+func (m *Master) ReplicationLatency() ReplicationLatency {
+	return m.latency.snapshot(time.Now())
+}
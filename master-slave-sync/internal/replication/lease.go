@@ -0,0 +1,128 @@
+package replication
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"time"
+)
+
+// defaultSlaveLease 注册租约的默认时长，对应配置项为0时使用
+const defaultSlaveLease = 30 * time.Second
+
+// maxExpiredLeases 保留的已过期租约记录数，超过时丢弃最早过期的
+const maxExpiredLeases = 256
+
+// ErrLeaseExpired 从节点没有有效的注册租约（未注册或租约已过期），主节点不接受它的确认，需要重新注册
+var ErrLeaseExpired = errors.New("slave registration lease expired")
+
+// PositionCheck 租约过期的从节点重新注册时对它报告的位置的检查结果
+type PositionCheck string
+
+const (
+	PositionCheckOK        PositionCheck = "ok"              // 位置在主节点binlog的可用范围内，可以继续增量复制
+	PositionCheckAhead     PositionCheck = "ahead_of_master" // 位置超过主节点的binlog位置，从节点可能有主节点没有的数据
+	PositionCheckPurged    PositionCheck = "purged"          // 位置之后的条目已被清理，从节点需要全量重新同步
+	PositionCheckRegressed PositionCheck = "regressed"       // 位置比过期前确认的位置更早（如从备份恢复），会重新应用之间的条目
+)
+
+// expiredLease 已过期被移除的从节点，重新注册时据此检查位置
+type expiredLease struct {
+	position  uint64    // 过期前确认的位置
+	expiredAt time.Time // 过期时间
+}
+
+// slaveLease 注册租约的时长：优先使用 SlaveLeaseMs，其次是兼容的 SlaveEvictAfterMs，都未配置时为默认30秒
+func (m *Master) slaveLease() time.Duration {
+	if m.config.SlaveLeaseMs > 0 {
+		return time.Duration(m.config.SlaveLeaseMs) * time.Millisecond
+	}
+	return durationOrDefault(m.config.SlaveEvictAfterMs, defaultSlaveLease)
+}
+
+// renewLease 授予或续期从节点的租约（调用方持有m.mu）；从节点未注册且之前的租约已过期时检查它报告的位置，
+// position 未知（如通过gRPC注册）时推迟到下一次心跳检查，返回检查结果，不需要检查时为空
+func (m *Master) renewLease(info *SlaveInfo, exists bool, position uint64, known bool) PositionCheck {
+	now := time.Now()
+	info.LeaseExpiresAt = now.Add(m.slaveLease())
+	if !exists {
+		if _, expired := m.expiredLeases[info.ID]; expired {
+			info.rejoining = true
+		}
+	}
+	if !info.rejoining || !known {
+		return ""
+	}
+
+	info.rejoining = false
+	lease := m.expiredLeases[info.ID]
+	delete(m.expiredLeases, info.ID)
+	check := m.checkRejoinPosition(position, lease.position)
+	info.PositionCheck = check
+	if check == PositionCheckOK {
+		log.Printf("Slave %s rejoined %v after its lease expired at position %d", info.ID, now.Sub(lease.expiredAt).Round(time.Second), position)
+	} else {
+		log.Printf("Warning: slave %s rejoined after its lease expired with position %d: %s", info.ID, position, check)
+	}
+	return check
+}
+
+// checkRejoinPosition 检查重新注册的从节点报告的位置：不能超过主节点的binlog位置，
+// 之后的条目不能已被清理，不应早于过期前确认的位置
+func (m *Master) checkRejoinPosition(position, confirmed uint64) PositionCheck {
+	status := m.binlog.Status()
+	if position > status.CurrentPosition {
+		return PositionCheckAhead
+	}
+	if position+1 < status.OldestPosition {
+		return PositionCheckPurged
+	}
+	if position < confirmed {
+		return PositionCheckRegressed
+	}
+	return PositionCheckOK
+}
+
+// expireLease 移除租约已过期的从节点并记录过期前的位置（调用方持有m.mu）
+func (m *Master) expireLease(info SlaveInfo, now time.Time) {
+	delete(m.slaveInfos, info.ID)
+	if len(m.expiredLeases) >= maxExpiredLeases {
+		oldestID, oldest := "", now
+		for id, lease := range m.expiredLeases {
+			if lease.expiredAt.Before(oldest) {
+				oldestID, oldest = id, lease.expiredAt
+			}
+		}
+		delete(m.expiredLeases, oldestID)
+	}
+	m.expiredLeases[info.ID] = expiredLease{position: info.CurrentPosition, expiredAt: now}
+	log.Printf("Lease of slave %s expired (no heartbeat for %v), removed at position %d",
+		info.ID, now.Sub(info.LastSeen).Round(time.Second), info.CurrentPosition)
+}
+
+// ackWithLease 发送确认；主节点因租约过期拒绝时立即发送心跳重新注册，再重试一次
+func (s *Slave) ackWithLease(position uint64) error {
+	s.acksSent.Add(1)
+	err := s.sendACKToMaster(position)
+	if !errors.Is(err, ErrLeaseExpired) {
+		return err
+	}
+	log.Printf("Master rejected ACK for position %d: %v, registering again", position, err)
+	if hbErr := s.sendHeartbeat(); hbErr != nil {
+		return fmt.Errorf("%w (re-register: %v)", err, hbErr)
+	}
+	return s.sendACKToMaster(position)
+}
+
+// logPositionCheck 记录主节点在重新注册时对本节点位置的检查结果
+func logPositionCheck(check PositionCheck) {
+	switch check {
+	case "", PositionCheckOK:
+	case PositionCheckPurged:
+		log.Printf("Warning: master reports entries after this slave's position were purged, a full resync is required")
+	case PositionCheckAhead:
+		log.Printf("Warning: master reports this slave is ahead of its binlog, the slave may have diverged and should be resynced")
+	default:
+		log.Printf("Warning: master position check after re-registration: %s", check)
+	}
+}
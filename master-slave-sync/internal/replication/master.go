@@ -1,214 +1,380 @@
 package replication
 
 import (
+	"crypto/tls"
 	"fmt"
 	"log"
 	"sync"
 	"time"
 
 	"master-slave-sync/internal/config"
+	"master-slave-sync/internal/netfault"
 	"master-slave-sync/internal/storage"
 )
 
 // Master 主节点管理器，负责处理写操作并维护binlog
 type Master struct {
-	db          *storage.DB          // 数据库连接
-	binlog      *Binlog              // binlog管理器
-	semiSync    *SemiSync            // 半同步复制器
-	config      *config.MasterConfig // 主节点配置
-	slaveInfos  map[string]SlaveInfo // 从节点信息表
-	startTime   time.Time            // 启动时间
-	totalWrites int                  // 总写入次数
-	mu          sync.RWMutex         // 并发控制锁
+	db              *storage.DB             // 数据库连接
+	binlog          *Binlog                 // binlog管理器
+	semiSync        *SemiSync               // 半同步复制器
+	config          *config.MasterConfig    // 主节点配置
+	binlogFormat    string                  // binlog格式：row 或 statement
+	waitPoint       string                  // 半同步等待确认的时机：after_commit 或 after_sync
+	slaveInfos      map[string]SlaveInfo    // 从节点信息表
+	expiredLeases   map[string]expiredLease // 租约已过期被移除的从节点，重新注册时检查位置
+	startTime       time.Time               // 启动时间
+	totalWrites     int                     // 总写入次数
+	faults          *netfault.Injector      // 网络故障注入器
+	auth            *ReplicationAuth        // 复制接口的从节点凭据校验
+	slaveTLS        *tls.Config             // 访问从节点API的TLS配置，未开启 SlaveTLS 时为nil
+	expired         int                     // 已过期删除的记录数
+	recovered       int                     // 启动时从复制日志补发的写入数
+	reaperStop      chan struct{}           // 停止过期清理的信号
+	retentionStop   chan struct{}           // 停止binlog清理的信号
+	heartbeatStop   chan struct{}           // 停止心跳检查的信号
+	probeStop       chan struct{}           // 停止半同步恢复检查的信号
+	consistencyStop chan struct{}           // 停止分块一致性检查的信号
+	consistency     []ConsistencyReport     // 最近的分块一致性检查报告
+	corruptions     []CorruptionReport      // 最近的损坏条目上报
+	corruptionCount int                     // 收到的损坏条目上报总数
+	streamingSlaves int                     // 当前连接推送流的从节点数
+	throttledWrites int64                   // 因从节点落后过多而被延迟的写入数
+	throttleTotal   time.Duration           // 写入被延迟的总时长
+	lastThrottledAt time.Time               // 最近一次延迟写入的时间
+	subscribers     subscriberRegistry      // 变更订阅（CDC）的Webhook订阅者
+	publisher       *binlogPublisher        // 把binlog发布到消息队列的后台任务，未启动时为nil
+	tail            *binlogTailer           // 跟随MySQL binlog的后台任务，binlog来源为 internal 时为nil
+	latency         latencyRecorder         // 从追加binlog到收到从节点确认的端到端延迟直方图
+	registryMu      sync.Mutex              // 串行化从节点登记表的写入
+	registrySaved   bool                    // 登记表中是否有从节点，为false且没有从节点时不再写入
+	mu              sync.RWMutex            // 并发控制锁
+	ddlMu           sync.RWMutex            // 写入持有读锁直到追加binlog，DDL持有写锁，保证DDL与前后的写入在binlog中的顺序
 }
 
 // SlaveInfo 存储从节点信息
 type SlaveInfo struct {
-	ID              string    // 从节点ID
-	Host            string    // 主机地址
-	Port            int       // 端口号
-	LastSeen        time.Time // 最后一次心跳时间
-	CurrentPosition uint64    // 当前同步位置
+	ID               string                    // 从节点ID
+	Host             string                    // 主机地址
+	Port             int                       // 端口号
+	LastSeen         time.Time                 // 最后一次心跳时间（确认和注册同样会刷新）
+	CurrentPosition  uint64                    // 当前同步位置
+	Status           string                    // 状态：active 或 stale（连续错过多次心跳）
+	MissedHeartbeats int                       // 距上次心跳已错过的心跳数
+	LagSeconds       float64                   // 复制延迟（秒）：已确认位置之后最早的条目写入了多久，已追上时为0
+	BehindEntries    uint64                    // 落后的条目数
+	Filter           *config.ReplicationFilter // 主节点为该从节点执行的过滤规则，为空表示不过滤
+	LeaseExpiresAt   time.Time                 // 注册租约的到期时间，注册和心跳时续期，到期后从节点被移除
+	PositionCheck    PositionCheck             // 租约过期后重新注册时的位置检查结果，没有过期过时为空
+	Restored         bool                      // 主节点重启后从登记表恢复、尚未重新报告位置
+	rejoining        bool                      // 租约过期后重新注册、尚未检查位置（注册时位置未知）
 }
 
 // MasterStats 主节点统计信息
 type MasterStats struct {
-	BinlogPosition  uint64         // 当前binlog位置
-	ConnectedSlaves int            // 已连接从节点数量
-	SemiSyncStatus  SemiSyncStatus // 半同步状态
-	TotalWrites     int            // 总写入次数
-	UptimeSeconds   int64          // 运行时间(秒)
-	SlaveInfos      []SlaveInfo    // 从节点详细信息
+	BinlogPosition     uint64             // 当前binlog位置
+	Epoch              uint64             // 主节点的纪元，写入每个binlog条目
+	BinlogFormat       string             // binlog格式：row 或 statement
+	BinlogSource       string             // binlog条目的来源：internal 或 mysql
+	SemiSyncWaitPoint  string             // 半同步等待确认的时机：after_commit 或 after_sync
+	ConnectedSlaves    int                // 已连接（按时发送心跳）的从节点数量
+	StaleSlaves        int                // 失联但尚未被移除的从节点数量
+	MaxLagSeconds      float64            // 所有从节点中最大的复制延迟（秒）
+	SemiSyncStatus     SemiSyncStatus     // 半同步状态
+	TotalWrites        int                // 总写入次数
+	ExpiredRecords     int                // 已过期删除的记录数
+	RecoveredWrites    int                // 启动时从复制日志补发的写入数
+	CorruptEntries     int                // 从节点上报的校验失败条目数
+	StreamingSlaves    int                // 当前连接推送流的从节点数
+	ThrottledWrites    int64              // 流量控制延迟的写入数
+	ReplicationLatency ReplicationLatency // 最近1到2分钟内从追加binlog到收到从节点确认的延迟分位数
+	UptimeSeconds      int64              // 运行时间(秒)
+	SlaveInfos         []SlaveInfo        // 从节点详细信息
 }
 
 // NewMaster 创建并初始化主节点
 func NewMaster(cfg *config.SyncConfig) (*Master, error) {
+	format, err := parseBinlogFormat(cfg.Master.BinlogFormat)
+	if err != nil {
+		return nil, err
+	}
+	waitPoint, err := ParseWaitPoint(cfg.SemiSync.WaitPoint)
+	if err != nil {
+		return nil, err
+	}
+	source, err := ParseBinlogSource(cfg.Master.BinlogSource)
+	if err != nil {
+		return nil, err
+	}
+	var slaveTLS *tls.Config
+	if cfg.Master.SlaveTLS {
+		if slaveTLS, err = newClientTLSConfig(cfg.Master.SlaveCAFile); err != nil {
+			return nil, err
+		}
+	}
+
 	// 连接数据库
 	db, err := storage.NewDB(cfg.Master.GetDSN(), "master")
 	if err != nil {
 		return nil, fmt.Errorf("failed to connect to master database: %w", err)
 	}
+	if err := migrateTables(db); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	// 创建binlog管理器（配置了文件路径时从文件加载复制历史）
+	binlog, err := openMasterBinlog(&cfg.Master)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	master := newMaster(cfg, db, binlog, format, waitPoint, slaveTLS)
+
+	// 恢复上次运行时注册的从节点，重启期间它们需要的binlog条目不会被清理
+	if err := master.restoreSlaves(); err != nil {
+		binlog.Close()
+		db.Close()
+		return nil, err
+	}
+
+	// 跟随MySQL binlog时写入不经过复制日志，条目都来自MySQL的binlog
+	if source == BinlogSourceMySQL {
+		if err := master.startTail(); err != nil {
+			binlog.Close()
+			db.Close()
+			return nil, err
+		}
+		return master, nil
+	}
+
+	// 补发上次退出前已提交但未写入binlog的写入
+	if _, err := master.RecoverJournal(); err != nil {
+		binlog.Close()
+		db.Close()
+		return nil, fmt.Errorf("failed to recover replication journal: %w", err)
+	}
 
-	// 创建binlog管理器
-	binlog := NewBinlog()
+	return master, nil
+}
 
-	// 创建半同步复制器
-	semiSync := NewSemiSync(&cfg.SemiSync)
+// newMaster 用已打开的数据库和binlog创建主节点（启动时或从节点提升为主节点时），slaveTLS为访问从节点API的TLS配置
+func newMaster(cfg *config.SyncConfig, db *storage.DB, binlog *Binlog, format, waitPoint string, slaveTLS *tls.Config) *Master {
+	epoch := masterEpoch(cfg.Master.Epoch, binlog)
+	binlog.setEpoch(epoch)
+	log.Printf("Master epoch is %d", epoch)
 
 	return &Master{
-		db:          db,
-		binlog:      binlog,
-		semiSync:    semiSync,
-		config:      &cfg.Master,
-		slaveInfos:  make(map[string]SlaveInfo),
-		startTime:   time.Now(),
-		totalWrites: 0,
-		mu:          sync.RWMutex{},
-	}, nil
+		db:            db,
+		binlog:        binlog,
+		semiSync:      NewSemiSync(&cfg.SemiSync),
+		config:        &cfg.Master,
+		binlogFormat:  format,
+		waitPoint:     waitPoint,
+		slaveInfos:    make(map[string]SlaveInfo),
+		expiredLeases: make(map[string]expiredLease),
+		startTime:     time.Now(),
+		totalWrites:   0,
+		faults:        netfault.NewInjector(),
+		auth:          NewReplicationAuth(cfg.Master.SlaveTokens),
+		slaveTLS:      slaveTLS,
+		mu:            sync.RWMutex{},
+	}
 }
 
-// CreateRecord 创建记录并写入binlog
-func (m *Master) CreateRecord(content string) (*storage.Record, error) {
-	// 创建记录
-	record, err := m.db.CreateRecord(content)
+// openMasterBinlog 根据配置创建binlog：配置了文件路径时持久化到文件，否则只保存在内存中
+func openMasterBinlog(cfg *config.MasterConfig) (*Binlog, error) {
+	if cfg.BinlogPath == "" {
+		log.Printf("Warning: binlog is kept in memory only, replication history is lost on restart")
+		return NewBinlog(), nil
+	}
+	policy, err := ParseSyncPolicy(cfg.BinlogSync)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create record: %w", err)
+		return nil, err
 	}
-
-	// 添加到binlog
-	pos, err := m.binlog.AppendInsert(record)
+	encoding, err := ParseEntryEncoding(cfg.BinlogEncoding)
 	if err != nil {
-		log.Printf("Warning: Failed to write to binlog: %v", err)
-		// 虽然binlog失败，但数据已写入，所以继续执行
+		return nil, err
 	}
-
-	// 等待半同步确认（如果失败，降级为异步）
-	status, err := m.semiSync.WaitForACK(pos)
+	binlog, err := OpenBinlog(cfg.BinlogPath, BinlogFileOptions{
+		Encoding:        encoding,
+		Sync:            policy,
+		SyncInterval:    time.Duration(cfg.BinlogSyncIntervalMs) * time.Millisecond,
+		MaxSegmentBytes: cfg.BinlogMaxSegmentBytes,
+		MaxSegmentAge:   time.Duration(cfg.BinlogMaxSegmentAgeSec) * time.Second,
+		GroupCommit:     cfg.BinlogGroupCommit,
+		GroupWindow:     time.Duration(cfg.BinlogGroupCommitWindowUs) * time.Microsecond,
+	})
 	if err != nil {
-		log.Printf("Semi-sync replication warning: %v, status: %s", err, status)
+		return nil, fmt.Errorf("failed to open binlog: %w", err)
 	}
+	return binlog, nil
+}
 
-	m.mu.Lock()
-	m.totalWrites++
-	m.mu.Unlock()
+// CreateRecord 创建记录并写入binlog
+func (m *Master) CreateRecord(content string) (*storage.Record, error) {
+	return m.CreateRecordWithTTL(content, 0)
+}
 
-	return record, nil
+// CreateRecordWithTTL 创建带有效期的记录并写入binlog，ttl为0表示永不过期
+// 过期时间随INSERT条目一起复制，但过期删除只由主节点执行（见StartExpiryReaper）
+func (m *Master) CreateRecordWithTTL(content string, ttl time.Duration) (*storage.Record, error) {
+	record, _, err := m.CreateRecordWithOptions(content, WriteOptions{TTL: ttl})
+	return record, err
 }
 
-// UpdateRecord 更新记录并写入binlog
-func (m *Master) UpdateRecord(id uint, content string) error {
-	// 先读取记录，确保存在
-	record, err := m.db.GetRecord(id)
+// CreateRecordWithOptions 按写入选项创建记录并写入binlog，返回记录和半同步确认结果
+// 持久化级别为 strict 且没有得到从节点确认时，记录已在主节点提交，同时返回记录和 NotReplicatedError
+func (m *Master) CreateRecordWithOptions(content string, opts WriteOptions) (*storage.Record, SemiSyncStatus, error) {
+	// 创建记录并添加到binlog
+	record, pos, waited, err := m.commitWrite(OpInsert, opts.Durability, func(tx *storage.DB) (*storage.Record, error) {
+		return tx.CreateRecordWithTTL(content, opts.TTL)
+	})
 	if err != nil {
-		return fmt.Errorf("record not found: %w", err)
+		return nil, "", fmt.Errorf("failed to create record: %w", err)
 	}
 
-	// 更新记录
-	err = m.db.UpdateRecord(id, content)
-	if err != nil {
-		return fmt.Errorf("failed to update record: %w", err)
-	}
+	status, err := m.finishWrite(pos, opts.Durability, waited)
+	return record, status, err
+}
 
-	// 更新record对象的内容（用于binlog）
-	record.Content = content
+// UpdateRecord 更新记录并写入binlog
+func (m *Master) UpdateRecord(id uint, content string) error {
+	_, err := m.UpdateRecordWithOptions(id, content, WriteOptions{})
+	return err
+}
 
-	// 添加到binlog
-	pos, err := m.binlog.AppendUpdate(record)
-	if err != nil {
-		log.Printf("Warning: Failed to write to binlog: %v", err)
-		// 虽然binlog失败，但数据已更新，所以继续执行
+// UpdateRecordWithOptions 按写入选项更新记录并写入binlog，返回半同步确认结果
+func (m *Master) UpdateRecordWithOptions(id uint, content string, opts WriteOptions) (SemiSyncStatus, error) {
+	// 先读取记录，确保存在
+	if _, err := m.db.GetRecord(id); err != nil {
+		return "", fmt.Errorf("record not found: %w", err)
 	}
 
-	// 等待半同步确认（如果失败，降级为异步）
-	status, err := m.semiSync.WaitForACK(pos)
+	// 更新记录并添加到binlog，更新前后的记录分别作为条目的前映像和后映像
+	_, pos, waited, err := m.commitRow(RecordsTable, OpUpdate, opts.Durability, func(tx *storage.DB) (interface{}, error) {
+		before, err := tx.GetRecord(id)
+		if err != nil {
+			return nil, err
+		}
+		if err := tx.UpdateRecord(id, content); err != nil {
+			return nil, err
+		}
+		// 重新读取，后映像包含数据库设置的更新时间
+		after, err := tx.GetRecord(id)
+		if err != nil {
+			return nil, err
+		}
+		return RowUpdate{Before: before, After: after}, nil
+	})
 	if err != nil {
-		log.Printf("Semi-sync replication warning: %v, status: %s", err, status)
+		return "", fmt.Errorf("failed to update record: %w", err)
 	}
 
-	m.mu.Lock()
-	m.totalWrites++
-	m.mu.Unlock()
-
-	return nil
+	return m.finishWrite(pos, opts.Durability, waited)
 }
 
 // DeleteRecord 删除记录并写入binlog
 func (m *Master) DeleteRecord(id uint) error {
+	_, err := m.DeleteRecordWithOptions(id, WriteOptions{})
+	return err
+}
+
+// DeleteRecordWithOptions 按写入选项删除记录并写入binlog，返回半同步确认结果
+func (m *Master) DeleteRecordWithOptions(id uint, opts WriteOptions) (SemiSyncStatus, error) {
 	// 先检查记录是否存在
 	_, err := m.db.GetRecord(id)
 	if err != nil {
-		return fmt.Errorf("record not found: %w", err)
-	}
-
-	// 删除记录
-	err = m.db.DeleteRecord(id)
-	if err != nil {
-		return fmt.Errorf("failed to delete record: %w", err)
+		return "", fmt.Errorf("record not found: %w", err)
 	}
 
-	// 添加到binlog
-	pos, err := m.binlog.AppendDelete(id)
+	// 删除记录并添加到binlog，删除条目只需要记录ID
+	_, pos, waited, err := m.commitWrite(OpDelete, opts.Durability, func(tx *storage.DB) (*storage.Record, error) {
+		return &storage.Record{ID: id}, tx.DeleteRecord(id)
+	})
 	if err != nil {
-		log.Printf("Warning: Failed to write to binlog: %v", err)
-		// 虽然binlog失败，但数据已删除，所以继续执行
+		return "", fmt.Errorf("failed to delete record: %w", err)
 	}
 
-	// 等待半同步确认（如果失败，降级为异步）
-	status, err := m.semiSync.WaitForACK(pos)
-	if err != nil {
-		log.Printf("Semi-sync replication warning: %v, status: %s", err, status)
-	}
-
-	m.mu.Lock()
-	m.totalWrites++
-	m.mu.Unlock()
-
-	return nil
+	return m.finishWrite(pos, opts.Durability, waited)
 }
 
 // GetBinlogEntries 获取指定位置之后的binlog条目（供从节点调用）
-func (m *Master) GetBinlogEntries(fromPosition uint64) []BinlogEntry {
-	return m.binlog.GetEntries(fromPosition)
+// 该位置之后的部分条目已被清理时返回 *PositionPurgedError，从节点需要重新全量同步
+func (m *Master) GetBinlogEntries(fromPosition uint64) ([]BinlogEntry, error) {
+	return m.binlog.EntriesAfter(fromPosition)
 }
 
-// RecordSlaveACK 记录从节点确认信息
-func (m *Master) RecordSlaveACK(slaveID string, position uint64) {
+// RecordSlaveACK 记录从节点确认信息；没有注册租约（未注册或租约已过期）的从节点的确认不计入半同步，
+// 返回false，从节点需要重新注册（心跳会自动重新注册）
+func (m *Master) RecordSlaveACK(slaveID string, position uint64) bool {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
 	// 更新从节点信息
 	info, exists := m.slaveInfos[slaveID]
 	if !exists {
-		info = SlaveInfo{
-			ID:              slaveID,
-			CurrentPosition: 0,
-		}
+		log.Printf("Ignoring ACK for position %d from slave %s without a registration lease", position, slaveID)
+		return false
 	}
 
 	info.LastSeen = time.Now()
 	if position > info.CurrentPosition {
+		m.recordAckLatency(info.CurrentPosition, position)
 		info.CurrentPosition = position
 	}
 	m.slaveInfos[slaveID] = info
 
 	// 记录确认到半同步复制器
 	m.semiSync.RecordACK(slaveID, position)
+	return true
 }
 
-// RegisterSlave 注册新的从节点
-func (m *Master) RegisterSlave(slaveID string, host string, port int) {
+// RegisterSlave 注册新的从节点并授予租约，position 为从节点已应用到的位置（known 为false表示未知）；
+// 租约过期后重新注册时检查该位置，返回检查结果，不是重新注册时为空
+func (m *Master) RegisterSlave(slaveID string, host string, port int, position uint64, known bool) PositionCheck {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
-	m.slaveInfos[slaveID] = SlaveInfo{
+	previous, exists := m.slaveInfos[slaveID]
+	info := SlaveInfo{
 		ID:              slaveID,
 		Host:            host,
 		Port:            port,
 		LastSeen:        time.Now(),
 		CurrentPosition: 0,
 	}
+	check := m.renewLease(&info, exists, position, known)
+	// 从登记表恢复的从节点保留最后确认的位置，注册时位置未知时在下一次心跳检查
+	if previous.Restored {
+		info.CurrentPosition = previous.CurrentPosition
+		info.Restored = true
+		if known {
+			check = m.reconcileRestored(&info, position)
+		}
+	}
+	m.slaveInfos[slaveID] = info
 
 	log.Printf("New slave registered: %s (%s:%d)", slaveID, host, port)
+	return check
+}
+
+// ChecksumResult 某个binlog位置上的数据校验和
+type ChecksumResult struct {
+	Position uint64 `json:"position"` // 计算时的binlog位置
+	storage.TableChecksum
+}
+
+// Checksum 计算当前数据的校验和及对应的binlog位置
+// 写入和binlog追加不是原子的，计算期间发生的写入可能导致位置与数据不完全对应，
+// 从节点校验时会在位置一致的前提下复核后再告警
+func (m *Master) Checksum() (ChecksumResult, error) {
+	position := m.binlog.GetCurrentPosition()
+	checksum, err := m.db.Checksum()
+	if err != nil {
+		return ChecksumResult{}, err
+	}
+	return ChecksumResult{Position: position, TableChecksum: checksum}, nil
 }
 
 // GetCurrentBinlogPosition 获取当前binlog位置
@@ -221,24 +387,69 @@ func (m *Master) GetStats() MasterStats {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
 
+	now := time.Now()
 	slaves := make([]SlaveInfo, 0, len(m.slaveInfos))
+	active := 0
+	maxLag := 0.0
 	for _, info := range m.slaveInfos {
+		info = m.describeSlave(info, now)
+		if info.Status == SlaveActive {
+			active++
+		}
+		lag, behind := m.slaveLag(info.CurrentPosition, now)
+		info.LagSeconds = lag.Seconds()
+		info.BehindEntries = behind
+		if info.LagSeconds > maxLag {
+			maxLag = info.LagSeconds
+		}
 		slaves = append(slaves, info)
 	}
 
 	return MasterStats{
-		BinlogPosition:  m.binlog.GetCurrentPosition(),
-		ConnectedSlaves: len(m.slaveInfos),
-		SemiSyncStatus:  m.semiSync.GetStatus(),
-		TotalWrites:     m.totalWrites,
-		UptimeSeconds:   int64(time.Since(m.startTime).Seconds()),
-		SlaveInfos:      slaves,
+		BinlogPosition:     m.binlog.GetCurrentPosition(),
+		Epoch:              m.binlog.Epoch(),
+		BinlogFormat:       m.binlogFormat,
+		BinlogSource:       m.binlogSource(),
+		SemiSyncWaitPoint:  m.waitPoint,
+		ConnectedSlaves:    active,
+		StaleSlaves:        len(slaves) - active,
+		MaxLagSeconds:      maxLag,
+		SemiSyncStatus:     m.semiSync.GetStatus(),
+		TotalWrites:        m.totalWrites,
+		ExpiredRecords:     m.expired,
+		RecoveredWrites:    m.recovered,
+		CorruptEntries:     m.corruptionCount,
+		StreamingSlaves:    m.streamingSlaves,
+		ThrottledWrites:    m.throttledWrites,
+		ReplicationLatency: m.ReplicationLatency(),
+		UptimeSeconds:      int64(time.Since(m.startTime).Seconds()),
+		SlaveInfos:         slaves,
 	}
 }
 
+// stopBackground 停止主节点的所有后台任务
+func (m *Master) stopBackground() {
+	m.StopExpiryReaper()
+	m.StopBinlogRetention()
+	m.StopHeartbeatMonitor()
+	m.StopSemiSyncProber()
+	m.StopConsistencyChecker()
+	m.stopSubscribers()
+	m.StopPublisher()
+	m.stopTail()
+}
+
 // Close 关闭主节点连接
 func (m *Master) Close() error {
+	m.stopBackground()
+
+	// 保存从节点登记表，重启后恢复
+	m.saveSlavesOrLog()
+
 	// 清理所有资源
+	if err := m.binlog.Close(); err != nil {
+		log.Printf("Error closing binlog: %v", err)
+	}
 	err := m.db.Close()
 	if err != nil {
 		return fmt.Errorf("failed to close database connection: %w", err)
@@ -252,3 +463,8 @@ func (m *Master) Close() error {
 func (m *Master) GetDB() *storage.DB {
 	return m.db
 }
+
+// GetFaultInjector 获取网络故障注入器
+func (m *Master) GetFaultInjector() *netfault.Injector {
+	return m.faults
+}
@@ -0,0 +1,61 @@
+package replication
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// NextPositionHeader 一次获取的条目超过 limit 或 max_bytes 被截断时，下一次请求应使用的位置
+// 没有该响应头表示已返回全部条目
+const NextPositionHeader = "X-Binlog-Next-Position"
+
+// 从节点分页获取binlog的默认参数，对应配置项为0时使用
+const (
+	defaultFetchLimit    = 500             // 每次最多获取的条目数
+	defaultFetchMaxBytes = 4 * 1024 * 1024 // 每次获取的条目的最大总大小
+)
+
+// PageEntries 按条数和按响应编码序列化后的总大小截取条目，limit或maxBytes不大于0表示不限制
+// 单个条目超过maxBytes时仍会返回，保证每次至少前进一个条目；被截断时返回true
+func PageEntries(entries []BinlogEntry, limit, maxBytes int, enc EntryEncoding) ([]BinlogEntry, bool) {
+	truncated := false
+	if limit > 0 && len(entries) > limit {
+		entries, truncated = entries[:limit], true
+	}
+	if maxBytes <= 0 {
+		return entries, truncated
+	}
+
+	size := 0
+	for i, entry := range entries {
+		size += EncodedSize(enc, entry)
+		if size > maxBytes && i > 0 {
+			return entries[:i], true
+		}
+	}
+	return entries, truncated
+}
+
+// parseNextPosition 解析 NextPositionHeader，为空时返回0
+func parseNextPosition(value string) (uint64, error) {
+	if value == "" {
+		return 0, nil
+	}
+	next, err := strconv.ParseUint(value, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s header %q: %w", NextPositionHeader, value, err)
+	}
+	return next, nil
+}
+
+// fetchLimits 从节点分页获取binlog时的条数和大小限制
+func (s *Slave) fetchLimits() (limit, maxBytes int) {
+	limit, maxBytes = s.config.FetchLimit, s.config.FetchMaxBytes
+	if limit <= 0 {
+		limit = defaultFetchLimit
+	}
+	if maxBytes <= 0 {
+		maxBytes = defaultFetchMaxBytes
+	}
+	return limit, maxBytes
+}
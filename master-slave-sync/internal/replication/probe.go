@@ -0,0 +1,114 @@
+package replication
+
+import (
+	"fmt"
+	"time"
+)
+
+// defaultProbeInterval 半同步恢复检查的默认间隔，对应配置项为0时使用
+const defaultProbeInterval = time.Second
+
+// SemiSyncReport 半同步状态及最近的状态切换
+type SemiSyncReport struct {
+	Status      SemiSyncStatus       `json:"status"`      // 当前状态
+	WaitPoint   string               `json:"wait_point"`  // 等待确认的时机
+	Since       time.Time            `json:"since"`       // 进入当前状态的时间
+	Transitions []SemiSyncTransition `json:"transitions"` // 最近的状态切换（最早的在前）
+}
+
+// slaveReadiness 按时发送心跳的从节点数，以及其中已确认到position的从节点数
+func (m *Master) slaveReadiness(position uint64) (active, caughtUp int) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	now := time.Now()
+	for _, info := range m.slaveInfos {
+		if m.describeSlave(info, now).Status != SlaveActive {
+			continue
+		}
+		active++
+		if info.CurrentPosition >= position {
+			caughtUp++
+		}
+	}
+	return active, caughtUp
+}
+
+// ProbeSemiSync 检查从节点的响应情况并推进半同步状态机，返回检查后的状态：
+//   - OK：按时发送心跳的从节点不足时提前降级，不必等到下一次写入超时
+//   - DEGRADED：足够的从节点按时发送心跳且已确认到当前binlog位置时进入恢复中，写入重新等待确认
+//   - RECOVERING：从节点再次不足时退回降级；仍然追上时恢复正常（写入按时得到确认同样会恢复正常）
+func (m *Master) ProbeSemiSync() SemiSyncStatus {
+	required := m.semiSync.config.MinSlaves
+	position := m.binlog.GetCurrentPosition()
+	active, caughtUp := m.slaveReadiness(position)
+
+	s := m.semiSync
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	switch s.status {
+	case StatusOK:
+		if active < required {
+			s.transition(StatusDegraded, fmt.Sprintf("only %d active slaves, semi-sync requires %d", active, required))
+		}
+	case StatusDegraded:
+		if caughtUp >= required {
+			s.transition(StatusRecovering, fmt.Sprintf("%d active slaves caught up to binlog position %d", caughtUp, position))
+		}
+	case StatusRecovering:
+		if active < required {
+			s.transition(StatusDegraded, fmt.Sprintf("only %d active slaves while recovering, semi-sync requires %d", active, required))
+		} else if caughtUp >= required {
+			s.transition(StatusOK, fmt.Sprintf("%d active slaves still caught up to binlog position %d", caughtUp, position))
+		}
+	}
+	return s.status
+}
+
+// SemiSyncReport 获取半同步状态及最近的状态切换
+func (m *Master) SemiSyncReport() SemiSyncReport {
+	return SemiSyncReport{
+		Status:      m.semiSync.GetStatus(),
+		WaitPoint:   m.waitPoint,
+		Since:       m.semiSync.Since(),
+		Transitions: m.semiSync.Transitions(),
+	}
+}
+
+// StartSemiSyncProber 启动半同步恢复检查任务，按 SemiSyncConfig.ProbeIntervalMs 定期调用 ProbeSemiSync
+func (m *Master) StartSemiSyncProber() {
+	m.mu.Lock()
+	if m.probeStop != nil {
+		m.mu.Unlock()
+		return
+	}
+	stop := make(chan struct{})
+	m.probeStop = stop
+	m.mu.Unlock()
+
+	go func() {
+		ticker := time.NewTicker(durationOrDefault(m.semiSync.config.ProbeIntervalMs, defaultProbeInterval))
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				m.ProbeSemiSync()
+			}
+		}
+	}()
+}
+
+// StopSemiSyncProber 停止半同步恢复检查任务
+func (m *Master) StopSemiSyncProber() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.probeStop != nil {
+		close(m.probeStop)
+		m.probeStop = nil
+	}
+}
@@ -0,0 +1,229 @@
+package replication
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"master-slave-sync/internal/kafka"
+)
+
+// 发布binlog相关参数
+const (
+	publishBatchSize      = 500              // 每次写入的最大消息数
+	publishInitialBackoff = time.Second      // 写入失败后的首次重试间隔
+	publishMaxBackoff     = 30 * time.Second // 写入失败后的最大重试间隔
+)
+
+// ErrPublisherRunning 已有发布任务在运行
+var ErrPublisherRunning = errors.New("binlog publisher is already running")
+
+// MessageProducer 把消息写入消息队列的生产者，Produce 返回nil表示整批消息都已被确认
+// kafka.Producer 实现了它；测试中可以替换为内存实现，不需要Kafka
+type MessageProducer interface {
+	Produce(ctx context.Context, messages []kafka.Message) error
+}
+
+// PublisherStatus binlog发布任务的状态（GET /api/publisher）
+type PublisherStatus struct {
+	Enabled       bool       `json:"enabled"`
+	Name          string     `json:"name,omitempty"`            // 发布目标，同时是进度检查点的名称
+	Position      uint64     `json:"position"`                  // 已发布并被确认、已保存检查点的位置
+	BehindEntries uint64     `json:"behind_entries"`            // 尚未发布的条目数
+	Published     int64      `json:"published"`                 // 启动以来发布的消息数
+	Failures      int64      `json:"failures"`                  // 启动以来失败的写入次数
+	LostEntries   uint64     `json:"lost_entries"`              // 发布前已被清理而跳过的条目数
+	LastError     string     `json:"last_error,omitempty"`      // 最近一次失败的原因，之后成功时清除
+	LastPublishAt *time.Time `json:"last_publish_at,omitempty"` // 最近一次发布成功的时间
+}
+
+// binlogPublisher 把binlog条目按顺序发布到消息队列的后台任务
+type binlogPublisher struct {
+	name     string
+	producer MessageProducer
+	cancel   context.CancelFunc
+	done     chan struct{}
+
+	mu     sync.Mutex // 保护status
+	status PublisherStatus
+}
+
+// StartPublisher 启动binlog发布任务：从名为name的检查点之后（没有检查点时从最早可用的条目）开始，
+// 把每个条目按顺序写入producer，整批被确认后保存检查点，失败时按指数退避重试同一批（至少一次）
+// 发布任务运行期间binlog清理不会越过尚未发布的位置
+func (m *Master) StartPublisher(name string, producer MessageProducer) error {
+	position, err := m.db.LoadCheckpoint(name)
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	if m.publisher != nil {
+		m.mu.Unlock()
+		return ErrPublisherRunning
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	p := &binlogPublisher{
+		name:     name,
+		producer: producer,
+		cancel:   cancel,
+		done:     make(chan struct{}),
+		status:   PublisherStatus{Enabled: true, Name: name, Position: position},
+	}
+	m.publisher = p
+	m.mu.Unlock()
+
+	go m.runPublisher(ctx, p)
+	log.Printf("Binlog publisher %s started from position %d", name, position)
+	return nil
+}
+
+// StopPublisher 停止binlog发布任务，等待正在进行的写入结束
+func (m *Master) StopPublisher() {
+	m.mu.Lock()
+	p := m.publisher
+	m.publisher = nil
+	m.mu.Unlock()
+	if p == nil {
+		return
+	}
+	p.cancel()
+	<-p.done
+	log.Printf("Binlog publisher %s stopped at position %d", p.name, p.snapshot().Position)
+}
+
+// PublisherStatus 获取binlog发布任务的状态
+func (m *Master) PublisherStatus() PublisherStatus {
+	m.mu.RLock()
+	p := m.publisher
+	m.mu.RUnlock()
+	if p == nil {
+		return PublisherStatus{}
+	}
+	status := p.snapshot()
+	if current := m.binlog.GetCurrentPosition(); current > status.Position {
+		status.BehindEntries = current - status.Position
+	}
+	return status
+}
+
+// publisherPosition 发布任务已发布到的位置，没有发布任务时返回false（调用方持有m.mu）
+func (m *Master) publisherPosition() (uint64, bool) {
+	if m.publisher == nil {
+		return 0, false
+	}
+	return m.publisher.snapshot().Position, true
+}
+
+// runPublisher 发布循环，ctx取消或binlog关闭时结束
+func (m *Master) runPublisher(ctx context.Context, p *binlogPublisher) {
+	defer close(p.done)
+	backoff := publishInitialBackoff
+	for {
+		err := m.binlog.follow(ctx, p.snapshot().Position, func(entries []BinlogEntry) (uint64, error) {
+			for len(entries) > 0 {
+				n := min(len(entries), publishBatchSize)
+				if err := m.publishBatch(ctx, p, entries[:n]); err != nil {
+					return 0, err
+				}
+				backoff = publishInitialBackoff
+				entries = entries[n:]
+			}
+			return p.snapshot().Position, nil
+		})
+
+		var purged *PositionPurgedError
+		switch {
+		case err == nil:
+			return
+		case errors.As(err, &purged):
+			p.skipTo(purged.Oldest - 1)
+			log.Printf("Warning: binlog publisher %s: entries up to %d were purged before being published", p.name, purged.Oldest-1)
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		backoff = min(backoff*2, publishMaxBackoff)
+	}
+}
+
+// publishBatch 写入一批条目并保存检查点
+// 写入成功但保存检查点失败时返回错误，重试会再次写入这批条目（至少一次）
+func (m *Master) publishBatch(ctx context.Context, p *binlogPublisher, entries []BinlogEntry) error {
+	messages := make([]kafka.Message, 0, len(entries))
+	for _, entry := range entries {
+		value, err := json.Marshal(changeEvent(entry))
+		if err != nil {
+			return p.failed(fmt.Errorf("failed to marshal binlog entry %d: %w", entry.ID, err))
+		}
+		messages = append(messages, kafka.Message{
+			Key:   []byte(fmt.Sprintf("%s:%d", entry.TableName, entry.RecordID)),
+			Value: value,
+			Time:  entry.Timestamp,
+		})
+	}
+
+	if err := p.producer.Produce(ctx, messages); err != nil {
+		if ctx.Err() != nil {
+			return err
+		}
+		return p.failed(fmt.Errorf("failed to publish entries %d-%d: %w", entries[0].ID, entries[len(entries)-1].ID, err))
+	}
+	last := entries[len(entries)-1].ID
+	if err := m.db.SaveCheckpoint(p.name, last); err != nil {
+		return p.failed(err)
+	}
+	p.published(last, len(messages))
+	return nil
+}
+
+// snapshot 发布任务的状态副本
+func (p *binlogPublisher) snapshot() PublisherStatus {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.status
+}
+
+// published 记录一次成功的写入
+func (p *binlogPublisher) published(position uint64, messages int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.status.LastError != "" {
+		log.Printf("Binlog publisher %s recovered at position %d", p.name, position)
+	}
+	now := time.Now()
+	p.status.Position = position
+	p.status.Published += int64(messages)
+	p.status.LastError = ""
+	p.status.LastPublishAt = &now
+}
+
+// failed 记录一次失败的写入，只在连续失败的第一次输出日志
+func (p *binlogPublisher) failed(err error) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.status.LastError == "" {
+		log.Printf("Binlog publisher %s: %v, retrying with backoff", p.name, err)
+	}
+	p.status.Failures++
+	p.status.LastError = err.Error()
+	return err
+}
+
+// skipTo 跳过已被清理的条目
+func (p *binlogPublisher) skipTo(position uint64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if position > p.status.Position {
+		p.status.LostEntries += position - p.status.Position
+		p.status.Position = position
+	}
+}
@@ -0,0 +1,115 @@
+package replication
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+
+	"master-slave-sync/internal/config"
+	"master-slave-sync/internal/storage"
+)
+
+// restoreSlaves 启动时从登记表恢复上次运行时注册的从节点：位置为它们最后确认的位置（超过binlog位置时截断），
+// 重新授予一个租约等待它们重新连接，租约到期仍未连接的从节点照常被移除
+func (m *Master) restoreSlaves() error {
+	saved, err := m.db.LoadRegisteredSlaves()
+	if err != nil {
+		return err
+	}
+	if len(saved) == 0 {
+		return nil
+	}
+
+	current := m.binlog.GetCurrentPosition()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, slave := range saved {
+		info := SlaveInfo{
+			ID:              slave.SlaveID,
+			Host:            slave.Host,
+			Port:            slave.Port,
+			LastSeen:        slave.LastSeen,
+			CurrentPosition: slave.Position,
+			Restored:        true,
+		}
+		if slave.Position > current {
+			log.Printf("Warning: restored slave %s confirmed position %d beyond binlog position %d", slave.SlaveID, slave.Position, current)
+			info.CurrentPosition = current
+		}
+		if slave.Filter != "" {
+			var f config.ReplicationFilter
+			if err := json.Unmarshal([]byte(slave.Filter), &f); err != nil {
+				return fmt.Errorf("invalid filter of registered slave %s: %w", slave.SlaveID, err)
+			}
+			info.Filter = &f
+		}
+		m.renewLease(&info, true, 0, false)
+		m.slaveInfos[slave.SlaveID] = info
+	}
+	m.registrySaved = true
+	log.Printf("Restored %d registered slaves, waiting %v for them to reconnect", len(saved), m.slaveLease())
+	return nil
+}
+
+// reconcileRestored 从登记表恢复的从节点重新连接并报告位置时（调用方持有m.mu），按它最后确认的位置检查报告的位置，
+// 报告的位置更早（如从节点从备份恢复）时以报告的位置为准，返回检查结果
+func (m *Master) reconcileRestored(info *SlaveInfo, position uint64) PositionCheck {
+	info.Restored = false
+	check := m.checkRejoinPosition(position, info.CurrentPosition)
+	info.PositionCheck = check
+	if position < info.CurrentPosition {
+		info.CurrentPosition = position
+	}
+	if check == PositionCheckOK {
+		log.Printf("Restored slave %s reconnected at position %d", info.ID, position)
+	} else {
+		log.Printf("Warning: restored slave %s reconnected with position %d: %s", info.ID, position, check)
+	}
+	return check
+}
+
+// saveSlaves 把从节点信息表写入登记表，登记表已为空且没有从节点时跳过
+func (m *Master) saveSlaves() error {
+	m.registryMu.Lock()
+	defer m.registryMu.Unlock()
+
+	m.mu.RLock()
+	slaves := make([]storage.RegisteredSlave, 0, len(m.slaveInfos))
+	for _, info := range m.slaveInfos {
+		slave := storage.RegisteredSlave{
+			SlaveID:  info.ID,
+			Host:     info.Host,
+			Port:     info.Port,
+			Position: info.CurrentPosition,
+			LastSeen: info.LastSeen,
+		}
+		if info.Filter != nil {
+			data, err := json.Marshal(info.Filter)
+			if err != nil {
+				m.mu.RUnlock()
+				return fmt.Errorf("failed to serialize filter of slave %s: %w", info.ID, err)
+			}
+			slave.Filter = string(data)
+		}
+		slaves = append(slaves, slave)
+	}
+	m.mu.RUnlock()
+
+	if len(slaves) == 0 && !m.registrySaved {
+		return nil
+	}
+	sort.Slice(slaves, func(i, j int) bool { return slaves[i].SlaveID < slaves[j].SlaveID })
+	if err := m.db.SaveRegisteredSlaves(slaves); err != nil {
+		return err
+	}
+	m.registrySaved = len(slaves) > 0
+	return nil
+}
+
+// saveSlavesOrLog 保存登记表，失败时只输出日志：下一次检查时重试
+func (m *Master) saveSlavesOrLog() {
+	if err := m.saveSlaves(); err != nil {
+		log.Printf("Failed to save slave registry: %v", err)
+	}
+}
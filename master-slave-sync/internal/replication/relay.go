@@ -0,0 +1,281 @@
+package replication
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"master-slave-sync/internal/config"
+)
+
+// ChainHeader 复制源在binlog和注册响应中返回的复制链（从源头主节点到该节点的服务器ID，逗号分隔）
+const ChainHeader = "X-Replication-Chain"
+
+// RelayHeader 中继从节点在binlog响应中设置为"true"：中继日志只包含中继自己应用的条目，ID本来就不连续
+const RelayHeader = "X-Binlog-Relay"
+
+var (
+	// ErrReplicationLoop 从节点的服务器ID已出现在复制源的复制链中，继续同步会形成复制环
+	ErrReplicationLoop = errors.New("replication loop detected")
+	// ErrRelayDisabled 从节点没有开启中继，不能作为下游从节点的复制源
+	ErrRelayDisabled = errors.New("relay log is disabled on this slave")
+)
+
+// DownstreamInfo 从中继从节点同步的下游从节点
+type DownstreamInfo struct {
+	ID       string    `json:"id"`        // 下游从节点ID
+	Host     string    `json:"host"`      // 地址
+	Port     int       `json:"port"`      // API端口
+	Position uint64    `json:"position"`  // 已确认的位置
+	LastSeen time.Time `json:"last_seen"` // 最后一次确认、心跳或注册的时间
+	// 中继为该下游从节点执行的过滤规则，为空表示不过滤
+	Filter *config.ReplicationFilter `json:"filter,omitempty"`
+}
+
+// FormatChain 把复制链编码为 ChainHeader 的值
+func FormatChain(chain []uint32) string {
+	parts := make([]string, len(chain))
+	for i, id := range chain {
+		parts[i] = strconv.FormatUint(uint64(id), 10)
+	}
+	return strings.Join(parts, ",")
+}
+
+// ParseChain 解析 ChainHeader 的值，为空时返回nil
+func ParseChain(value string) ([]uint32, error) {
+	if value == "" {
+		return nil, nil
+	}
+	var chain []uint32
+	for _, part := range strings.Split(value, ",") {
+		id, err := strconv.ParseUint(strings.TrimSpace(part), 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid replication chain %q: %w", value, err)
+		}
+		chain = append(chain, uint32(id))
+	}
+	return chain, nil
+}
+
+// checkChain 下游的服务器ID出现在复制链中时返回 ErrReplicationLoop，服务器ID为0（未配置）时不检查
+func checkChain(chain []uint32, serverID uint32) error {
+	if serverID != 0 && slices.Contains(chain, serverID) {
+		return fmt.Errorf("%w: server id %d is already in chain %s", ErrReplicationLoop, serverID, FormatChain(chain))
+	}
+	return nil
+}
+
+// ReplicationChain 主节点的复制链，只包含自己
+func (m *Master) ReplicationChain() []uint32 {
+	if m.config.ServerID == 0 {
+		return nil
+	}
+	return []uint32{m.config.ServerID}
+}
+
+// CheckDownstream 检查服务器ID为serverID的从节点从主节点同步是否会形成复制环
+func (m *Master) CheckDownstream(serverID uint32) error {
+	return checkChain(m.ReplicationChain(), serverID)
+}
+
+// appendRelayed 按原样（ID、时间戳、校验和不变）追加从上游收到并已应用的条目，只保留最近limit个条目
+// 已包含的条目被忽略；条目ID可以不连续（跳过的条目不会追加）
+func (b *Binlog) appendRelayed(entries []BinlogEntry, limit int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	appended := false
+	for _, entry := range entries {
+		if entry.ID <= b.position {
+			continue
+		}
+		b.entries = append(b.entries, entry)
+		b.position = entry.ID
+		appended = true
+	}
+	if !appended {
+		return
+	}
+	if over := len(b.entries) - limit; over > 0 {
+		b.entries = append([]BinlogEntry(nil), b.entries[over:]...)
+	}
+
+	close(b.changed)
+	b.changed = make(chan struct{})
+}
+
+// advanceTo 没有条目可追加时把位置推进到position（跳过的条目），之前的条目仍可读取
+func (b *Binlog) advanceTo(position uint64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if position > b.position {
+		b.position = position
+	}
+}
+
+// resetRelay 全量重新同步后清空中继日志并把位置设为position，更早的条目不再可用
+func (b *Binlog) resetRelay(position uint64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.entries = make([]BinlogEntry, 0)
+	b.position = position
+	close(b.changed)
+	b.changed = make(chan struct{})
+}
+
+// newRelayLog 创建从节点的中继日志，从节点已应用到position，更早的条目不可用
+func newRelayLog(position uint64) *Binlog {
+	relay := NewBinlog()
+	relay.position = position
+	return relay
+}
+
+// relayEnabled 从节点是否开启了中继
+func (s *Slave) relayEnabled() bool {
+	return s.relay != nil
+}
+
+// relayApplied 把一批已应用的条目写入中继日志，lastID为这批条目（包括跳过的）最后的ID
+func (s *Slave) relayApplied(applied []BinlogEntry, lastID uint64) {
+	if s.relay == nil {
+		return
+	}
+	s.relay.appendRelayed(applied, s.config.RelayLogSize)
+	s.relay.advanceTo(lastID)
+}
+
+// RelayEntries 获取中继日志中指定位置之后的条目，所需条目已不在中继日志中时返回 *PositionPurgedError
+func (s *Slave) RelayEntries(fromPosition uint64) ([]BinlogEntry, error) {
+	if s.relay == nil {
+		return nil, ErrRelayDisabled
+	}
+	return s.relay.EntriesAfter(fromPosition)
+}
+
+// WaitRelayEntries 与 RelayEntries 相同，没有新条目时最多等待wait（长轮询）
+func (s *Slave) WaitRelayEntries(ctx context.Context, fromPosition uint64, wait time.Duration) ([]BinlogEntry, error) {
+	if s.relay == nil {
+		return nil, ErrRelayDisabled
+	}
+	return s.relay.waitEntriesAfter(ctx, fromPosition, wait)
+}
+
+// ReplicationChain 从节点的复制链：从上游得知的复制链加上自己的服务器ID
+func (s *Slave) ReplicationChain() []uint32 {
+	s.relayMu.Lock()
+	defer s.relayMu.Unlock()
+
+	chain := slices.Clone(s.upstreamChain)
+	if s.config.ServerID != 0 {
+		chain = append(chain, s.config.ServerID)
+	}
+	return chain
+}
+
+// CheckDownstream 检查服务器ID为serverID的下游从节点从本节点同步是否会形成复制环
+func (s *Slave) CheckDownstream(serverID uint32) error {
+	if s.relay == nil {
+		return ErrRelayDisabled
+	}
+	return checkChain(s.ReplicationChain(), serverID)
+}
+
+// RecordDownstream 记录下游从节点的注册、心跳或确认，host为空时保留之前的地址
+func (s *Slave) RecordDownstream(slaveID, host string, port int, position uint64) error {
+	if s.relay == nil {
+		return ErrRelayDisabled
+	}
+	s.relayMu.Lock()
+	defer s.relayMu.Unlock()
+
+	info, exists := s.downstream[slaveID]
+	if !exists {
+		info.ID = slaveID
+		log.Printf("Downstream slave %s registered", slaveID)
+	}
+	if host != "" {
+		info.Host = host
+		info.Port = port
+	}
+	if position > info.Position {
+		info.Position = position
+	}
+	info.LastSeen = time.Now()
+	s.downstream[slaveID] = info
+	return nil
+}
+
+// SetDownstreamFilter 设置中继为下游从节点执行的过滤规则，空规则表示不过滤
+func (s *Slave) SetDownstreamFilter(slaveID string, f *config.ReplicationFilter) error {
+	if s.relay == nil {
+		return ErrRelayDisabled
+	}
+	s.relayMu.Lock()
+	defer s.relayMu.Unlock()
+
+	info, exists := s.downstream[slaveID]
+	if !exists {
+		return fmt.Errorf("downstream slave %s is not registered", slaveID)
+	}
+	if filterIsEmpty(f) {
+		f = nil
+	}
+	info.Filter = f
+	s.downstream[slaveID] = info
+	if f != nil {
+		log.Printf("Filtering relay log for downstream slave %s: %+v", slaveID, *f)
+	}
+	return nil
+}
+
+// FilterRelayEntries 按下游从节点注册的过滤规则筛选中继日志中的条目，同时返回检查到的最后一个条目ID
+func (s *Slave) FilterRelayEntries(slaveID string, entries []BinlogEntry) ([]BinlogEntry, uint64) {
+	s.relayMu.Lock()
+	f := s.downstream[slaveID].Filter
+	s.relayMu.Unlock()
+	return filterEntries(f, entries)
+}
+
+// DownstreamSlaves 从本节点同步的下游从节点（按ID排序）
+func (s *Slave) DownstreamSlaves() []DownstreamInfo {
+	s.relayMu.Lock()
+	defer s.relayMu.Unlock()
+
+	slaves := make([]DownstreamInfo, 0, len(s.downstream))
+	for _, info := range s.downstream {
+		slaves = append(slaves, info)
+	}
+	sort.Slice(slaves, func(i, j int) bool { return slaves[i].ID < slaves[j].ID })
+	return slaves
+}
+
+// observeUpstreamChain 记录复制源返回的复制链，自己的服务器ID已在链中时返回 ErrReplicationLoop
+func (s *Slave) observeUpstreamChain(value string) error {
+	chain, err := ParseChain(value)
+	if err != nil {
+		return err
+	}
+	return s.setUpstreamChain(chain)
+}
+
+// setUpstreamChain 记录复制源的复制链，自己的服务器ID已在链中时返回 ErrReplicationLoop
+func (s *Slave) setUpstreamChain(chain []uint32) error {
+	if err := checkChain(chain, s.config.ServerID); err != nil {
+		return err
+	}
+	s.relayMu.Lock()
+	defer s.relayMu.Unlock()
+	s.upstreamChain = chain
+	return nil
+}
+
+// isOwnEntry 条目是否由本节点产生（经过复制环回到了本节点），这样的条目不再应用
+func (s *Slave) isOwnEntry(entry BinlogEntry) bool {
+	return s.config.ServerID != 0 && entry.ServerID == s.config.ServerID
+}
@@ -0,0 +1,118 @@
+package replication
+
+import (
+	"strings"
+	"time"
+)
+
+// mysqlTimestampLayout SHOW SLAVE STATUS 中错误时间的格式（如 240501 10:00:00）
+const mysqlTimestampLayout = "060102 15:04:05"
+
+// SlaveReplicationStatus 从节点的复制状态，字段与MySQL的 SHOW SLAVE STATUS 对应（GET /api/replication_status）
+// 位置是全局的binlog条目ID，没有binlog文件名，因此不包含 Master_Log_File 等文件相关的字段
+type SlaveReplicationStatus struct {
+	SlaveIOState          string `json:"Slave_IO_State"`          // 获取条目的状态，同步停止时为空
+	MasterHost            string `json:"Master_Host"`             // 主节点地址
+	MasterPort            int    `json:"Master_Port"`             // 主节点API端口
+	ConnectRetry          int    `json:"Connect_Retry"`           // 重新连接的间隔(秒)
+	ReadMasterLogPos      uint64 `json:"Read_Master_Log_Pos"`     // 已从主节点收到的最大条目ID
+	ExecMasterLogPos      uint64 `json:"Exec_Master_Log_Pos"`     // 已应用到的位置
+	SlaveIORunning        string `json:"Slave_IO_Running"`        // Yes、No 或 Connecting（主节点不可达）
+	SlaveSQLRunning       string `json:"Slave_SQL_Running"`       // Yes 或 No
+	ReplicateDoTable      string `json:"Replicate_Do_Table"`      // 只复制的表（逗号分隔）
+	ReplicateIgnoreTable  string `json:"Replicate_Ignore_Table"`  // 不复制的表（逗号分隔）
+	LastError             string `json:"Last_Error"`              // 与 Last_SQL_Error 相同
+	SecondsBehindMaster   *int64 `json:"Seconds_Behind_Master"`   // 复制延迟(秒)，同步停止或主节点不可达时为null
+	LastIOError           string `json:"Last_IO_Error"`           // 最近一次访问主节点失败的原因
+	LastIOErrorTimestamp  string `json:"Last_IO_Error_Timestamp"` // 主节点开始不可达的时间
+	LastSQLError          string `json:"Last_SQL_Error"`          // 最近一次应用条目失败的原因，之后应用成功时清除
+	LastSQLErrorTimestamp string `json:"Last_SQL_Error_Timestamp"`
+	MasterServerID        uint32 `json:"Master_Server_Id"` // 复制源的服务器ID，未知时为0
+	SkipCounter           int    `json:"Skip_Counter"`     // 尚未使用的跳过计数（sql_slave_skip_counter）
+}
+
+// MasterReplicationStatus 主节点的binlog状态，字段与MySQL的 SHOW MASTER STATUS 对应（GET /api/replication_status）
+type MasterReplicationStatus struct {
+	File           string `json:"File"`             // 正在写入的分段文件名，binlog只在内存中时为空
+	Position       uint64 `json:"Position"`         // 当前binlog位置（最后一个条目ID）
+	BinlogDoDB     string `json:"Binlog_Do_DB"`     // 主节点不按库过滤，始终为空
+	BinlogIgnoreDB string `json:"Binlog_Ignore_DB"` // 同上
+}
+
+// ReplicationStatus 获取 SHOW MASTER STATUS 形式的binlog状态
+func (m *Master) ReplicationStatus() MasterReplicationStatus {
+	status := m.binlog.Status()
+	var file string
+	if n := len(status.Segments); n > 0 {
+		file = status.Segments[n-1].Name
+	}
+	return MasterReplicationStatus{File: file, Position: status.CurrentPosition}
+}
+
+// ReplicationStatus 获取 SHOW SLAVE STATUS 形式的复制状态
+func (s *Slave) ReplicationStatus() SlaveReplicationStatus {
+	_, unreachableSince, lastIOError := s.client.status()
+
+	s.syncMutex.Lock()
+	defer s.syncMutex.Unlock()
+
+	status := SlaveReplicationStatus{
+		MasterHost:           s.config.MasterHost,
+		MasterPort:           s.config.MasterPort,
+		ConnectRetry:         int(s.syncInterval.Seconds()),
+		ReadMasterLogPos:     max(s.receivedPosition, s.currentPosition),
+		ExecMasterLogPos:     s.currentPosition,
+		SlaveIORunning:       "No",
+		SlaveSQLRunning:      "No",
+		ReplicateDoTable:     strings.Join(s.config.Filter.IncludeTables, ","),
+		ReplicateIgnoreTable: strings.Join(s.config.Filter.ExcludeTables, ","),
+		LastError:            s.lastApplyError,
+		LastIOError:          lastIOError,
+		LastSQLError:         s.lastApplyError,
+		MasterServerID:       s.masterServerID(),
+		SkipCounter:          s.skipCounter,
+	}
+	if !unreachableSince.IsZero() {
+		status.LastIOErrorTimestamp = unreachableSince.Format(mysqlTimestampLayout)
+	}
+	if !s.lastApplyErrorAt.IsZero() {
+		status.LastSQLErrorTimestamp = s.lastApplyErrorAt.Format(mysqlTimestampLayout)
+	}
+	if !s.isRunning {
+		return status
+	}
+
+	status.SlaveSQLRunning = "Yes"
+	switch {
+	case !unreachableSince.IsZero():
+		status.SlaveIORunning = "Connecting"
+		status.SlaveIOState = "Reconnecting after a failed master event read"
+	default:
+		status.SlaveIORunning = "Yes"
+		status.SlaveIOState = "Waiting for master to send event"
+		lag := int64(s.lag(time.Now()).Seconds())
+		status.SecondsBehindMaster = &lag
+	}
+	return status
+}
+
+// masterServerID 复制源的服务器ID：复制源返回的复制链中的最后一个节点
+func (s *Slave) masterServerID() uint32 {
+	s.relayMu.Lock()
+	defer s.relayMu.Unlock()
+	if len(s.upstreamChain) == 0 {
+		return 0
+	}
+	return s.upstreamChain[len(s.upstreamChain)-1]
+}
+
+// recordApplyError 记录一批条目应用失败的原因，成功应用时传入nil清除（调用方持有syncMutex）
+func (s *Slave) recordApplyError(err error) {
+	if err == nil {
+		s.lastApplyError = ""
+		s.lastApplyErrorAt = time.Time{}
+		return
+	}
+	s.lastApplyError = err.Error()
+	s.lastApplyErrorAt = time.Now()
+}
@@ -0,0 +1,252 @@
+package replication
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"master-slave-sync/internal/storage"
+)
+
+// snapshotBatchSize 生成快照时每次从表中读取的行数
+const snapshotBatchSize = 1000
+
+// 全量重新同步相关的错误
+var (
+	ErrResyncInFlight   = errors.New("a full resync is already in progress")
+	ErrSnapshotRequired = errors.New("table does not support snapshots")
+)
+
+// Snapshot 主节点数据在某个binlog位置上的全量快照
+type Snapshot struct {
+	Position uint64          `json:"position"` // 快照对应的binlog位置：不超过它的条目都已包含在快照中
+	ServerID uint32          `json:"server_id,omitempty"`
+	Epoch    uint64          `json:"epoch,omitempty"` // 主节点的纪元，从节点拒绝加载比已知纪元旧的快照
+	Time     time.Time       `json:"time"`            // 生成快照的时间
+	Tables   []TableSnapshot `json:"tables"`
+}
+
+// TableSnapshot 一个表的全部行
+type TableSnapshot struct {
+	Name string            `json:"name"`
+	Rows []json.RawMessage `json:"rows"` // 按主键顺序，由表的 Encode 序列化（与INSERT条目的Data相同）
+}
+
+// ResyncResult 一次全量重新同步的结果
+type ResyncResult struct {
+	PreviousPosition uint64         `json:"previous_position"` // 重新同步前已应用到的位置
+	Position         uint64         `json:"position"`          // 快照的位置，之后从这里继续增量同步
+	Tables           map[string]int `json:"tables"`            // 各表加载的行数
+	SkippedTables    []string       `json:"skipped_tables,omitempty"`
+	SnapshotBytes    int            `json:"snapshot_bytes"`
+	DurationMs       int64          `json:"duration_ms"`
+}
+
+// Snapshot 生成所有已注册表的一致性快照
+// 短暂阻塞写入（与DDL相同，等之前开始的写入都提交并追加binlog）期间开启一致性快照事务并记录binlog位置，
+// 之后写入照常进行，快照中的数据恰好是该位置上的状态，从节点加载后从该位置继续增量同步不会丢失或重复条目
+func (m *Master) Snapshot() (*Snapshot, error) {
+	snapshot := &Snapshot{ServerID: m.config.ServerID, Epoch: m.binlog.Epoch(), Time: time.Now()}
+
+	m.ddlMu.Lock()
+	locked := true
+	unlock := func() {
+		if locked {
+			m.ddlMu.Unlock()
+			locked = false
+		}
+	}
+	defer unlock()
+
+	err := m.db.ReadSnapshot(func(tx *storage.DB) error {
+		// 读视图已建立，之后的写入不会出现在快照中
+		snapshot.Position = m.binlog.GetCurrentPosition()
+		unlock()
+
+		for _, name := range RegisteredTables() {
+			table, err := snapshotTable(name)
+			if err != nil {
+				return err
+			}
+			rows, err := dumpTable(tx, table)
+			if err != nil {
+				return err
+			}
+			snapshot.Tables = append(snapshot.Tables, TableSnapshot{Name: name, Rows: rows})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create snapshot: %w", err)
+	}
+	log.Printf("Created snapshot of %d tables at binlog position %d", len(snapshot.Tables), snapshot.Position)
+	return snapshot, nil
+}
+
+// snapshotTable 按表名查找支持快照的表
+func snapshotTable(name string) (SnapshotTable, error) {
+	table, err := LookupTable(name)
+	if err != nil {
+		return nil, err
+	}
+	st, ok := table.(SnapshotTable)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrSnapshotRequired, name)
+	}
+	return st, nil
+}
+
+// dumpTable 按主键顺序分批读出表中的所有行
+func dumpTable(tx *storage.DB, table SnapshotTable) ([]json.RawMessage, error) {
+	rows := []json.RawMessage{}
+	after := uint(0)
+	for {
+		batch, err := table.Rows(tx.GetConnection(), after, snapshotBatchSize)
+		if err != nil {
+			return nil, err
+		}
+		for _, row := range batch {
+			id, data, err := table.Encode(row)
+			if err != nil {
+				return nil, err
+			}
+			rows = append(rows, data)
+			after = id
+		}
+		if len(batch) < snapshotBatchSize {
+			return rows, nil
+		}
+	}
+}
+
+// Resync 全量重新同步：停止同步，从主节点获取快照，在一个事务中清空本地的表、加载快照中的行并把位置重置为快照的位置，
+// 再从该位置恢复增量同步。用于从节点的数据已经偏离主节点（一致性校验报告不一致）或需要的binlog已被清理的情况
+// 不匹配本地过滤规则的表不清空也不加载；中继日志重置到快照的位置，下游从节点需要各自重新同步
+func (s *Slave) Resync() (ResyncResult, error) {
+	s.syncMutex.Lock()
+	switch {
+	case s.resyncing:
+		s.syncMutex.Unlock()
+		return ResyncResult{}, ErrResyncInFlight
+	case s.replaying:
+		s.syncMutex.Unlock()
+		return ResyncResult{}, ErrReplayInFlight
+	}
+	s.resyncing = true
+	previous := s.currentPosition
+	s.syncMutex.Unlock()
+	defer func() {
+		s.syncMutex.Lock()
+		s.resyncing = false
+		s.syncMutex.Unlock()
+	}()
+
+	began := time.Now()
+	result := ResyncResult{PreviousPosition: previous, Tables: make(map[string]int)}
+	log.Printf("Slave %s starting full resync from master at %s (was at position %d)", s.slaveID, s.masterURL, previous)
+
+	// 加载快照期间不应用增量条目，加载完成后从快照的位置重新开始
+	s.StopSync()
+	snapshot, size, err := s.fetchSnapshot()
+	if err != nil {
+		return result, err
+	}
+	result.Position = snapshot.Position
+	result.SnapshotBytes = size
+
+	// 修改数据之前检查快照来自当前纪元的主节点，且快照中的表都可以加载
+	epoch, err := checkEpoch(BinlogEntry{ID: snapshot.Position, Epoch: snapshot.Epoch}, s.Epoch())
+	if err != nil {
+		return result, err
+	}
+	tables := make([]SnapshotTable, len(snapshot.Tables))
+	for i, ts := range snapshot.Tables {
+		if tables[i], err = snapshotTable(ts.Name); err != nil {
+			return result, err
+		}
+	}
+
+	s.syncMutex.Lock()
+	err = s.db.Transaction(func(tx *storage.DB) error {
+		// 锁定位置行，与 applyBatch 串行执行
+		if _, err := tx.LockPosition(s.slaveID); err != nil {
+			return err
+		}
+		for i, ts := range snapshot.Tables {
+			if !filterMatches(&s.config.Filter, BinlogEntry{Operation: OpInsert, TableName: ts.Name}) {
+				result.SkippedTables = append(result.SkippedTables, ts.Name)
+				continue
+			}
+			if err := tables[i].Truncate(tx.GetConnection()); err != nil {
+				return err
+			}
+			for _, row := range ts.Rows {
+				entry := BinlogEntry{Operation: OpInsert, TableName: ts.Name, Data: row}
+				if err := tables[i].Apply(tx.GetConnection(), entry); err != nil {
+					return fmt.Errorf("failed to load %s row: %w", ts.Name, err)
+				}
+			}
+			result.Tables[ts.Name] = len(ts.Rows)
+		}
+		if err := tx.SavePosition(s.slaveID, snapshot.Position); err != nil {
+			return err
+		}
+		return tx.SaveEpoch(s.slaveID, epoch)
+	})
+	if err != nil {
+		s.syncMutex.Unlock()
+		return result, fmt.Errorf("failed to load snapshot: %w", err)
+	}
+	s.currentPosition = snapshot.Position
+	s.epoch = epoch
+	s.receivedPosition = snapshot.Position
+	s.masterPosition = max(s.masterPosition, snapshot.Position)
+	s.lastAppliedTime = snapshot.Time
+	s.pendingSince = time.Time{}
+	s.recordApplyError(nil)
+	if s.relay != nil {
+		s.relay.resetRelay(snapshot.Position)
+	}
+	s.syncMutex.Unlock()
+
+	if err := s.ackWithLease(snapshot.Position); err != nil {
+		log.Printf("Warning: Failed to send ACK for position %d: %v", snapshot.Position, err)
+	}
+	result.DurationMs = time.Since(began).Milliseconds()
+	log.Printf("Slave %s loaded snapshot at position %d in %dms, resuming incremental sync",
+		s.slaveID, snapshot.Position, result.DurationMs)
+
+	s.StartSync()
+	return result, nil
+}
+
+// fetchSnapshot 从主节点获取全量快照，返回快照和响应的大小
+func (s *Slave) fetchSnapshot() (*Snapshot, int, error) {
+	url := fmt.Sprintf("%s/api/snapshot?slave_id=%s", s.masterURL, s.slaveID)
+	resp, err := s.client.DoBulk(url)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to connect to master: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden {
+		return nil, 0, fmt.Errorf("%w: master rejected slave %s (%s)", ErrUnauthorized, s.slaveID, resp.Status)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, 0, fmt.Errorf("master returned error status for snapshot: %s", resp.Status)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to read snapshot: %w", err)
+	}
+	var snapshot Snapshot
+	if err := json.Unmarshal(body, &snapshot); err != nil {
+		return nil, 0, fmt.Errorf("failed to decode snapshot: %w", err)
+	}
+	return &snapshot, len(body), nil
+}
@@ -0,0 +1,192 @@
+package replication
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"time"
+)
+
+// ErrPositionPurged 请求的位置之后的条目已被清理，从节点无法再增量同步
+var ErrPositionPurged = errors.New("binlog position has been purged")
+
+// PositionPurgedError 请求的位置早于最早可用的位置
+type PositionPurgedError struct {
+	Requested uint64 // 请求的位置（返回该位置之后的条目）
+	Oldest    uint64 // 最早可用的条目ID
+}
+
+// Error 实现error接口
+func (e *PositionPurgedError) Error() string {
+	return fmt.Sprintf("binlog entries after position %d have been purged, oldest available entry is %d", e.Requested, e.Oldest)
+}
+
+// Unwrap 支持 errors.Is(err, ErrPositionPurged)
+func (e *PositionPurgedError) Unwrap() error {
+	return ErrPositionPurged
+}
+
+// BinlogStatus binlog的可用范围与分段信息
+type BinlogStatus struct {
+	OldestPosition  uint64            `json:"oldest_position"`  // 最早可用的条目ID，binlog为空时为下一个条目ID
+	CurrentPosition uint64            `json:"current_position"` // 当前位置
+	Persistent      bool              `json:"persistent"`       // 是否持久化到分段文件
+	Segments        []SegmentInfo     `json:"segments"`         // 分段文件（只在内存中时为空）
+	PurgedSegments  int               `json:"purged_segments"`  // 启动以来清理的分段数
+	LastPurgeAt     *time.Time        `json:"last_purge_at,omitempty"`
+	GroupCommit     *GroupCommitStats `json:"group_commit,omitempty"` // 组提交的批大小统计，未开启时为空
+}
+
+// oldestPosition 最早可用的条目ID（调用方持有锁）
+func (b *Binlog) oldestPosition() uint64 {
+	if len(b.entries) > 0 {
+		return b.entries[0].ID
+	}
+	return b.position + 1
+}
+
+// EntriesAfter 获取指定位置之后的所有binlog条目，其中一部分已被清理时返回 *PositionPurgedError
+func (b *Binlog) EntriesAfter(fromPosition uint64) ([]BinlogEntry, error) {
+	b.mu.RLock()
+	oldest := b.oldestPosition()
+	b.mu.RUnlock()
+
+	if fromPosition+1 < oldest {
+		return nil, &PositionPurgedError{Requested: fromPosition, Oldest: oldest}
+	}
+	return b.GetEntries(fromPosition), nil
+}
+
+// Purge 删除最后一个条目不超过position的分段文件及其在内存中的条目，正在写入的分段总是保留
+// 只在内存中的binlog不清理
+func (b *Binlog) Purge(position uint64) ([]SegmentInfo, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.file == nil {
+		return nil, nil
+	}
+
+	purged, purgedTo, err := b.file.purge(position)
+	if len(purged) > 0 {
+		n := 0
+		for n < len(b.entries) && b.entries[n].ID <= purgedTo {
+			n++
+		}
+		b.entries = append([]BinlogEntry(nil), b.entries[n:]...)
+
+		now := time.Now()
+		b.purgedSegments += len(purged)
+		b.lastPurgeAt = &now
+	}
+	return purged, err
+}
+
+// Status 获取binlog的可用范围与分段信息
+func (b *Binlog) Status() BinlogStatus {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	status := BinlogStatus{
+		OldestPosition:  b.oldestPosition(),
+		CurrentPosition: b.position,
+		Persistent:      b.file != nil,
+		Segments:        []SegmentInfo{},
+		PurgedSegments:  b.purgedSegments,
+		LastPurgeAt:     b.lastPurgeAt,
+	}
+	if b.file != nil {
+		status.Segments = b.file.segmentInfos()
+	}
+	if b.group != nil {
+		status.GroupCommit = b.group.stats()
+	}
+	return status
+}
+
+// retentionPosition 所有已注册从节点都已确认的位置，没有从节点时返回false（不清理）
+// binlog发布任务运行时同样不越过它尚未发布的位置
+func (m *Master) retentionPosition() (uint64, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if len(m.slaveInfos) == 0 {
+		return 0, false
+	}
+	var min uint64
+	first := true
+	for _, info := range m.slaveInfos {
+		if first || info.CurrentPosition < min {
+			min = info.CurrentPosition
+			first = false
+		}
+	}
+	if published, ok := m.publisherPosition(); ok && published < min {
+		min = published
+	}
+	return min, true
+}
+
+// PurgeBinlog 清理所有已注册从节点都已确认的分段，返回被清理的分段
+func (m *Master) PurgeBinlog() ([]SegmentInfo, error) {
+	position, ok := m.retentionPosition()
+	if !ok || position == 0 {
+		return nil, nil
+	}
+	purged, err := m.binlog.Purge(position)
+	for _, seg := range purged {
+		log.Printf("Purged binlog segment %s (entries %d-%d), all slaves acknowledged position %d",
+			seg.Name, seg.FirstID, seg.LastID, position)
+	}
+	return purged, err
+}
+
+// BinlogStatus 获取binlog的可用范围与分段信息
+func (m *Master) BinlogStatus() BinlogStatus {
+	return m.binlog.Status()
+}
+
+// StartBinlogRetention 启动binlog清理任务，按间隔清理所有已注册从节点都已确认的分段
+// interval为0时不启动（分段一直保留）
+func (m *Master) StartBinlogRetention(interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+
+	m.mu.Lock()
+	if m.retentionStop != nil {
+		m.mu.Unlock()
+		return
+	}
+	stop := make(chan struct{})
+	m.retentionStop = stop
+	m.mu.Unlock()
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				if _, err := m.PurgeBinlog(); err != nil {
+					log.Printf("Binlog retention error: %v", err)
+				}
+			}
+		}
+	}()
+
+	log.Printf("Binlog retention started with interval %v", interval)
+}
+
+// StopBinlogRetention 停止binlog清理任务
+func (m *Master) StopBinlogRetention() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.retentionStop != nil {
+		close(m.retentionStop)
+		m.retentionStop = nil
+	}
+}
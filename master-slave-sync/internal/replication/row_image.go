@@ -0,0 +1,203 @@
+package replication
+
+import (
+	"context"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+
+	"master-slave-sync/internal/storage"
+)
+
+// ErrUpdateConflict 从节点上被更新的行与条目的前映像不一致（从节点的数据已经偏离主节点）
+var ErrUpdateConflict = errors.New("row does not match the update's before image")
+
+// UpdateConflictError 开启前映像检查时，从节点上的行与UPDATE条目的前映像不一致
+type UpdateConflictError struct {
+	Table    string   // 表名
+	RecordID uint     // 行的主键
+	Missing  bool     // 从节点上没有这一行
+	Columns  []string // 与前映像不一致的列
+}
+
+// Error 实现error接口
+func (e *UpdateConflictError) Error() string {
+	if e.Missing {
+		return fmt.Sprintf("update conflict on %s row %d: row does not exist on this slave", e.Table, e.RecordID)
+	}
+	return fmt.Sprintf("update conflict on %s row %d: columns %s differ from the before image",
+		e.Table, e.RecordID, strings.Join(e.Columns, ", "))
+}
+
+// Unwrap 支持 errors.Is(err, ErrUpdateConflict)
+func (e *UpdateConflictError) Unwrap() error {
+	return ErrUpdateConflict
+}
+
+// RowUpdate 更新写入（WriteRow 的 write）可以返回它，同时提供更新前后的行：
+// Before 序列化后作为条目的前映像，从节点只写入前后不同的列，并可以据此检查冲突；After 为写入后的行
+type RowUpdate struct {
+	Before interface{} // 更新前的行
+	After  interface{} // 更新后的行
+}
+
+// ImageTable 按前后映像应用UPDATE的表，内置的 records 表和 ModelTable 都实现了它；
+// 没有实现的表由 Apply 处理UPDATE，不做前映像检查
+type ImageTable interface {
+	Table
+	// ApplyUpdate 应用UPDATE条目，checkBefore为true时先检查本地的行与前映像一致，不一致时返回 *UpdateConflictError
+	ApplyUpdate(db *gorm.DB, entry BinlogEntry, checkBefore bool) error
+}
+
+// ApplyOptions 从节点应用条目的选项
+type ApplyOptions struct {
+	CheckBeforeImage bool // UPDATE条目带有前映像时，检查本地的行与前映像一致
+}
+
+// ApplyEntryWithOptions 与 ApplyEntry 相同，按选项应用UPDATE条目
+func ApplyEntryWithOptions(db *storage.DB, entry BinlogEntry, opts ApplyOptions) error {
+	if entry.Operation != OpUpdate || entry.Format != "" {
+		return ApplyEntry(db, entry)
+	}
+	if err := entry.Verify(); err != nil {
+		return err
+	}
+	table, err := LookupTable(entry.TableName)
+	if err != nil {
+		return err
+	}
+	if t, ok := table.(ImageTable); ok {
+		return t.ApplyUpdate(db.GetConnection(), entry, opts.CheckBeforeImage)
+	}
+	return table.Apply(db.GetConnection(), entry)
+}
+
+// applyRowUpdate 按前后映像更新一行：after和before指向同一模型的新值，before为nil表示条目没有前映像。
+// 有前映像时只写入前后不同的列，没有时写入主键以外的所有列；行不存在时按后映像插入。
+// checkBefore为true且有前映像时，先读取并锁定本地的行，与前映像逐列比较
+func applyRowUpdate(db *gorm.DB, table string, entry BinlogEntry, after, before interface{}, checkBefore bool) error {
+	s, err := schema.Parse(after, &modelSchemas, schema.NamingStrategy{})
+	if err != nil {
+		return fmt.Errorf("failed to parse %s model: %w", table, err)
+	}
+	afterValue := reflect.ValueOf(after).Elem()
+
+	if before != nil && checkBefore {
+		current := reflect.New(afterValue.Type())
+		result := db.Clauses(clause.Locking{Strength: "UPDATE"}).Limit(1).Find(current.Interface(), entry.RecordID)
+		if result.Error != nil {
+			return fmt.Errorf("failed to read %s row %d: %w", table, entry.RecordID, result.Error)
+		}
+		if result.RowsAffected == 0 {
+			return &UpdateConflictError{Table: table, RecordID: entry.RecordID, Missing: true}
+		}
+		if differ := diffColumns(s, reflect.ValueOf(before).Elem(), current.Elem()); len(differ) > 0 {
+			return &UpdateConflictError{Table: table, RecordID: entry.RecordID, Columns: differ}
+		}
+	}
+
+	var columns []string
+	if before != nil {
+		columns = diffColumns(s, reflect.ValueOf(before).Elem(), afterValue)
+		if len(columns) == 0 {
+			return nil
+		}
+	} else {
+		for _, field := range s.Fields {
+			if field.DBName != "" && !field.PrimaryKey {
+				columns = append(columns, field.DBName)
+			}
+		}
+	}
+
+	values := make(map[string]interface{}, len(columns))
+	for _, column := range columns {
+		value, _ := s.FieldsByDBName[column].ValueOf(context.Background(), afterValue)
+		values[column] = value
+	}
+	// UpdateColumns 原样写入后映像中的值，不会把 autoUpdateTime 的列改为从节点的当前时间
+	result := db.Model(after).UpdateColumns(values)
+	if result.Error != nil {
+		return fmt.Errorf("failed to apply UPDATE to %s: %w", table, result.Error)
+	}
+	if result.RowsAffected > 0 {
+		return nil
+	}
+	// 没有行被修改：行不存在时按后映像插入；本地的值已经与后映像相同（重复应用）时保持不变
+	if err := db.Clauses(clause.OnConflict{DoNothing: true}).Create(after).Error; err != nil {
+		return fmt.Errorf("failed to apply UPDATE to %s: %w", table, err)
+	}
+	return nil
+}
+
+// decodeRowImages 反序列化条目的后映像和前映像（没有前映像时before为nil）到newRow创建的模型中
+func decodeRowImages(table string, entry BinlogEntry, newRow func() interface{}) (after, before interface{}, err error) {
+	after = newRow()
+	if err := json.Unmarshal(entry.Data, after); err != nil {
+		return nil, nil, fmt.Errorf("failed to deserialize %s row: %w", table, err)
+	}
+	if len(entry.Before) == 0 {
+		return after, nil, nil
+	}
+	before = newRow()
+	if err := json.Unmarshal(entry.Before, before); err != nil {
+		return nil, nil, fmt.Errorf("failed to deserialize %s before image: %w", table, err)
+	}
+	return after, before, nil
+}
+
+// diffColumns 两行中值不同的列（主键除外），按模型中字段的顺序
+func diffColumns(s *schema.Schema, a, b reflect.Value) []string {
+	ctx := context.Background()
+	var columns []string
+	for _, field := range s.Fields {
+		if field.DBName == "" || field.PrimaryKey {
+			continue
+		}
+		va, _ := field.ValueOf(ctx, a)
+		vb, _ := field.ValueOf(ctx, b)
+		if !sameColumnValue(va, vb) {
+			columns = append(columns, field.DBName)
+		}
+	}
+	return columns
+}
+
+// sameColumnValue 比较两个列值：时间按时刻比较（忽略时区和单调时钟），实现了 driver.Valuer 的类型按写入数据库的值比较
+func sameColumnValue(a, b interface{}) bool {
+	a, b = columnValue(a), columnValue(b)
+	if ta, ok := a.(time.Time); ok {
+		tb, ok := b.(time.Time)
+		return ok && ta.Equal(tb)
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+// columnValue 去掉指针并转换为写入数据库的值，空指针为nil
+func columnValue(v interface{}) interface{} {
+	rv := reflect.ValueOf(v)
+	for rv.IsValid() && rv.Kind() == reflect.Ptr {
+		if rv.IsNil() {
+			return nil
+		}
+		rv = rv.Elem()
+	}
+	if !rv.IsValid() {
+		return nil
+	}
+	v = rv.Interface()
+	if valuer, ok := v.(driver.Valuer); ok {
+		if value, err := valuer.Value(); err == nil {
+			return value
+		}
+	}
+	return v
+}
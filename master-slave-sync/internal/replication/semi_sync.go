@@ -2,6 +2,8 @@ package replication
 
 import (
 	"fmt"
+	"log"
+	"sort"
 	"sync"
 	"time"
 
@@ -12,12 +14,40 @@ import (
 type SemiSyncStatus string
 
 const (
-	StatusOK        SemiSyncStatus = "OK"        // 正常状态
-	StatusTimeout   SemiSyncStatus = "TIMEOUT"   // 等待超时
-	StatusDegraded  SemiSyncStatus = "DEGRADED"  // 降级模式（异步）
-	StatusRecovered SemiSyncStatus = "RECOVERED" // 恢复半同步模式
+	StatusOK         SemiSyncStatus = "OK"         // 正常状态
+	StatusTimeout    SemiSyncStatus = "TIMEOUT"    // 等待超时
+	StatusDegraded   SemiSyncStatus = "DEGRADED"   // 降级模式（异步），写入不再等待确认
+	StatusRecovering SemiSyncStatus = "RECOVERING" // 从节点已追上，写入重新等待确认，按时确认后恢复正常
 )
 
+// maxSemiSyncTransitions 保留的最近状态切换数
+const maxSemiSyncTransitions = 50
+
+// SemiSyncTransition 一次半同步状态切换
+type SemiSyncTransition struct {
+	From   SemiSyncStatus `json:"from"`   // 切换前的状态
+	To     SemiSyncStatus `json:"to"`     // 切换后的状态
+	Reason string         `json:"reason"` // 切换原因
+	At     time.Time      `json:"at"`     // 切换时间
+}
+
+// 半同步等待确认的时机
+const (
+	WaitAfterCommit = "after_commit" // 主节点先提交再等待确认，提交后其他客户端即可读到尚未复制的写入
+	WaitAfterSync   = "after_sync"   // 先追加binlog并等待确认，再在主节点提交，确认之前其他客户端读不到这次写入
+)
+
+// ParseWaitPoint 解析等待确认的时机，空字符串表示默认的 after_commit
+func ParseWaitPoint(name string) (string, error) {
+	switch name {
+	case "", WaitAfterCommit:
+		return WaitAfterCommit, nil
+	case WaitAfterSync:
+		return WaitAfterSync, nil
+	}
+	return "", fmt.Errorf("unknown semi-sync wait point: %s", name)
+}
+
 // ACKResult 表示从节点确认结果
 type ACKResult struct {
 	SlaveID   string         // 从节点标识
@@ -27,121 +57,182 @@ type ACKResult struct {
 }
 
 // SemiSync 半同步复制管理器
+// 从节点按顺序应用条目，确认一个位置即确认了之前的所有位置，因此只记录每个从节点确认到的最高位置，
+// 从节点可以每批条目（或每隔一段时间）只确认一次
 type SemiSync struct {
-	config      *config.SemiSyncConfig    // 半同步配置
-	acks        map[uint64][]ACKResult    // 每个binlog位置的确认记录
-	waitCh      map[uint64]chan ACKResult // 等待确认的通道
-	status      SemiSyncStatus            // 当前状态
-	failureTime time.Time                 // 最后一次失败时间
-	mu          sync.RWMutex              // 并发控制锁
+	config      *config.SemiSyncConfig // 半同步配置
+	acks        map[string]ACKResult   // 每个从节点确认到的最高位置
+	waiters     []*ackWaiter           // 等待确认的写入
+	status      SemiSyncStatus         // 当前状态
+	failureTime time.Time              // 最后一次失败时间
+	since       time.Time              // 进入当前状态的时间
+	transitions []SemiSyncTransition   // 最近的状态切换（最早的在前）
+	mu          sync.RWMutex           // 并发控制锁
+}
+
+// ackWaiter 一个等待确认的写入
+type ackWaiter struct {
+	position uint64
+	done     chan struct{} // 足够多的从节点确认到position后关闭
 }
 
 // NewSemiSync 创建一个新的半同步复制管理器
 func NewSemiSync(cfg *config.SemiSyncConfig) *SemiSync {
 	return &SemiSync{
 		config:      cfg,
-		acks:        make(map[uint64][]ACKResult),
-		waitCh:      make(map[uint64]chan ACKResult),
+		acks:        make(map[string]ACKResult),
 		status:      StatusOK,
 		failureTime: time.Time{},
+		since:       time.Now(),
+	}
+}
+
+// transition 切换到状态to并记录切换事件（调用方持有锁），状态不变时什么也不做
+func (s *SemiSync) transition(to SemiSyncStatus, reason string) {
+	if s.status == to {
+		return
 	}
+	event := SemiSyncTransition{From: s.status, To: to, Reason: reason, At: time.Now()}
+	log.Printf("Semi-sync status %s -> %s: %s", event.From, event.To, reason)
+
+	if to == StatusDegraded {
+		s.failureTime = event.At
+	}
+	s.status = to
+	s.since = event.At
+	s.transitions = append(s.transitions, event)
+	if len(s.transitions) > maxSemiSyncTransitions {
+		s.transitions = s.transitions[len(s.transitions)-maxSemiSyncTransitions:]
+	}
+}
+
+// Transitions 获取最近的状态切换（最早的在前）
+func (s *SemiSync) Transitions() []SemiSyncTransition {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return append([]SemiSyncTransition(nil), s.transitions...)
+}
+
+// Since 进入当前状态的时间
+func (s *SemiSync) Since() time.Time {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.since
 }
 
 // WaitForACK 等待从节点确认
 // 返回确认状态和错误信息
 func (s *SemiSync) WaitForACK(position uint64) (SemiSyncStatus, error) {
-	// 创建等待通道
+	// 从节点可能在写入方开始等待前就已确认到这个位置或之后的位置
 	s.mu.Lock()
-	ch := make(chan ACKResult, s.config.MinSlaves)
-	s.waitCh[position] = ch
+	if s.ackedCount(position) >= s.config.MinSlaves {
+		s.transition(StatusOK, fmt.Sprintf("slaves acknowledged binlog position %d in time", position))
+		s.mu.Unlock()
+		return StatusOK, nil
+	}
+	waiter := &ackWaiter{position: position, done: make(chan struct{})}
+	s.waiters = append(s.waiters, waiter)
 	s.mu.Unlock()
 
 	// 设置超时时间
 	timeout := time.NewTimer(time.Duration(s.config.TimeoutMs) * time.Millisecond)
 	defer timeout.Stop()
 
-	// 计数器：已收到的确认数
-	received := 0
-
 	// 等待确认或超时
-	for {
-		select {
-		case ack := <-ch:
-			received++
-			// 记录确认
-			s.mu.Lock()
-			if _, ok := s.acks[position]; !ok {
-				s.acks[position] = make([]ACKResult, 0)
-			}
-			s.acks[position] = append(s.acks[position], ack)
+	select {
+	case <-waiter.done:
+	case <-timeout.C:
+		s.mu.Lock()
+		// 超时的同时收到了足够的确认时按成功处理
+		if s.removeWaiter(waiter) {
+			err := fmt.Errorf("waiting for slave ACK timed out after %d ms", s.config.TimeoutMs)
+			s.transition(StatusDegraded, fmt.Sprintf("binlog position %d: %v", position, err))
 			s.mu.Unlock()
+			return StatusTimeout, err
+		}
+		s.mu.Unlock()
+	}
 
-			// 如果收到足够数量的确认，返回成功
-			if received >= s.config.MinSlaves {
-				return StatusOK, nil
-			}
-
-		case <-timeout.C:
-			// 超时处理
-			s.mu.Lock()
-			s.status = StatusDegraded
-			s.failureTime = time.Now()
-			delete(s.waitCh, position)
-			s.mu.Unlock()
+	// 收到足够数量的确认，恢复中的状态随之恢复正常
+	s.mu.Lock()
+	s.transition(StatusOK, fmt.Sprintf("slaves acknowledged binlog position %d in time", position))
+	s.mu.Unlock()
+	return StatusOK, nil
+}
 
-			return StatusTimeout, fmt.Errorf("waiting for slave ACK timed out after %d ms", s.config.TimeoutMs)
+// ackedCount 确认到position或之后位置的从节点数（调用方持有锁）
+func (s *SemiSync) ackedCount(position uint64) int {
+	n := 0
+	for _, ack := range s.acks {
+		if ack.Position >= position {
+			n++
 		}
 	}
+	return n
 }
 
-// RecordACK 记录从节点的确认
-func (s *SemiSync) RecordACK(slaveID string, position uint64) {
-	ack := ACKResult{
-		SlaveID:   slaveID,
-		Position:  position,
-		Timestamp: time.Now(),
-		Status:    StatusOK,
+// removeWaiter 移除尚未满足的等待，已被确认唤醒（不在列表中）时返回false（调用方持有锁）
+func (s *SemiSync) removeWaiter(waiter *ackWaiter) bool {
+	for i, w := range s.waiters {
+		if w == waiter {
+			s.waiters = append(s.waiters[:i], s.waiters[i+1:]...)
+			return true
+		}
 	}
+	return false
+}
 
+// degrade 不等待确认直接降级为异步模式（如活跃的从节点不足），返回与等待超时相同的状态
+func (s *SemiSync) degrade(reason error) (SemiSyncStatus, error) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
+	s.transition(StatusDegraded, reason.Error())
+	return StatusTimeout, reason
+}
 
-	// 如果有等待此位置的通道，发送确认
-	if ch, ok := s.waitCh[position]; ok {
-		select {
-		case ch <- ack:
-			// 确认已发送
-		default:
-			// 通道已满或关闭，忽略
-		}
-	}
+// RecordACK 记录从节点的确认：确认position同时确认了该从节点之前的所有位置，
+// 唤醒所有等待的位置不超过position且已有足够从节点确认的写入；比已记录的位置旧的确认（乱序到达）忽略
+func (s *SemiSync) RecordACK(slaveID string, position uint64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 
-	// 存储确认记录
-	if _, ok := s.acks[position]; !ok {
-		s.acks[position] = make([]ACKResult, 0)
+	if prev, ok := s.acks[slaveID]; ok && prev.Position >= position {
+		return
+	}
+	s.acks[slaveID] = ACKResult{
+		SlaveID:   slaveID,
+		Position:  position,
+		Timestamp: time.Now(),
+		Status:    StatusOK,
 	}
-	s.acks[position] = append(s.acks[position], ack)
 
-	// 如果当前是降级状态，检查是否可以恢复
-	if s.status == StatusDegraded {
-		// 如果最后一次失败已经超过了超时时间的2倍，尝试恢复
-		if time.Since(s.failureTime) > time.Duration(s.config.TimeoutMs*2)*time.Millisecond {
-			s.status = StatusRecovered
+	pending := s.waiters[:0]
+	for _, w := range s.waiters {
+		if w.position <= position && s.ackedCount(w.position) >= s.config.MinSlaves {
+			close(w.done)
+			continue
 		}
+		pending = append(pending, w)
 	}
+	for i := len(pending); i < len(s.waiters); i++ {
+		s.waiters[i] = nil
+	}
+	s.waiters = pending
 }
 
-// GetACKs 获取指定位置的确认记录
+// GetACKs 获取已确认到指定位置（或之后位置）的从节点的最新确认，按从节点ID排序
 func (s *SemiSync) GetACKs(position uint64) []ACKResult {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
-	if acks, ok := s.acks[position]; ok {
-		result := make([]ACKResult, len(acks))
-		copy(result, acks)
-		return result
+	var result []ACKResult
+	for _, ack := range s.acks {
+		if ack.Position >= position {
+			result = append(result, ack)
+		}
 	}
-	return nil
+	sort.Slice(result, func(i, j int) bool { return result[i].SlaveID < result[j].SlaveID })
+	return result
 }
 
 // GetStatus 获取当前半同步状态
@@ -151,22 +242,14 @@ func (s *SemiSync) GetStatus() SemiSyncStatus {
 	return s.status
 }
 
-// CleanupOldACKs 清理旧的确认记录（可定期调用）
+// CleanupOldACKs 清理确认位置早于beforePosition的从节点的记录（如已经离开的从节点），可定期调用
 func (s *SemiSync) CleanupOldACKs(beforePosition uint64) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	for pos := range s.acks {
-		if pos < beforePosition {
-			delete(s.acks, pos)
-		}
-	}
-
-	// 清理等待通道
-	for pos := range s.waitCh {
-		if pos < beforePosition {
-			close(s.waitCh[pos])
-			delete(s.waitCh, pos)
+	for slaveID, ack := range s.acks {
+		if ack.Position < beforePosition {
+			delete(s.acks, slaveID)
 		}
 	}
 }
@@ -1,32 +1,91 @@
 package replication
 
 import (
-	"bytes"
+	"crypto/tls"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"log"
 	"net/http"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"master-slave-sync/internal/config"
+	"master-slave-sync/internal/netfault"
 	"master-slave-sync/internal/storage"
+	"master-slave-sync/internal/wsconn"
+)
+
+// 同步间隔的默认值，对应配置项为0时使用
+const (
+	defaultSyncInterval    = 5 * time.Second        // 同步间隔（推送模式下为断开后重连的间隔）
+	defaultMinSyncInterval = 200 * time.Millisecond // 有新条目时缩短到的最小轮询间隔
+	defaultMaxSyncInterval = 30 * time.Second       // 没有新条目时退避到的最大轮询间隔
 )
 
 // Slave 从节点管理器，负责同步主节点的binlog并应用
 type Slave struct {
-	db              *storage.DB         // 数据库连接
-	config          *config.SlaveConfig // 从节点配置
-	slaveID         string              // 从节点唯一ID
-	currentPosition uint64              // 当前同步到的位置
-	syncInterval    time.Duration       // 同步间隔
-	masterURL       string              // 主节点URL
-	lastSyncTime    time.Time           // 上次同步时间
-	syncCount       int                 // 同步次数统计
-	appliedCount    int                 // 应用条目数统计
-	isRunning       bool                // 同步是否在运行
-	syncMutex       sync.Mutex          // 同步锁
-	startTime       time.Time           // 启动时间
+	db               *storage.DB         // 数据库连接
+	config           *config.SlaveConfig // 从节点配置
+	syncConfig       *config.SyncConfig  // 完整配置，提升为主节点时使用
+	slaveID          string              // 从节点唯一ID
+	currentPosition  uint64              // 当前同步到的位置（与应用的数据一起持久化在 replication_state 表中）
+	epoch            uint64              // 已应用的条目中最大的主节点纪元（同样持久化），更旧纪元的条目被拒绝
+	syncInterval     time.Duration       // 同步间隔（推送模式下为断开后重连的间隔），也是轮询间隔的初始值
+	minSyncInterval  time.Duration       // 轮询间隔的下限
+	maxSyncInterval  time.Duration       // 轮询间隔的上限
+	pollInterval     time.Duration       // 当前的轮询间隔，随同步结果自适应调整（见 adjustPollInterval）
+	mode             string              // 复制方式：push、longpoll 或 poll
+	encoding         EntryEncoding       // HTTP传输时请求的条目编码
+	grpc             *grpcMasterClient   // gRPC传输时访问主节点的客户端，HTTP传输时为nil
+	stream           io.Closer           // 当前的推送流（关闭即断开），未连接时为nil
+	heartbeatStop    chan struct{}       // 停止发送心跳的信号
+	masterPosition   uint64              // 已知的主节点binlog位置
+	receivedPosition uint64              // 已从主节点收到的最大条目ID
+	lastAppliedTime  time.Time           // 最后应用的条目在主节点上的写入时间
+	pendingSince     time.Time           // 最早的未应用条目在主节点上的写入时间，没有时为零值
+	masterURL        string              // 主节点URL
+	lastSyncTime     time.Time           // 上次同步时间
+	syncCount        int                 // 同步次数统计
+	appliedCount     int                 // 应用条目数统计
+	isRunning        bool                // 同步是否在运行
+	replaying        bool                // 是否正在进行时间点回放（见 ReplayUntil）
+	resyncing        bool                // 是否正在进行全量重新同步（见 Resync）
+	syncMutex        sync.Mutex          // 同步锁
+	startTime        time.Time           // 启动时间
+	client           *masterClient       // 访问主节点的HTTP客户端（重试与熔断）
+	faults           *netfault.Injector  // 网络故障注入器
+	verifier         verifier            // 定期一致性校验
+	writes           writeAudit          // 被拒绝的写请求审计
+	hot              hotStats            // 按表和记录的应用热点统计
+	corruptEntries   int                 // 校验失败被拒绝的条目数
+	skippedOwn       int                 // 因服务器ID与本节点相同而跳过的条目数
+	filteredCount    int                 // 被本地过滤规则过滤的条目数
+	duplicateCount   int                 // 已应用过而被跳过的重复条目数
+	lastApplyError   string              // 最近一次应用条目失败的原因，之后应用成功时清除
+	lastApplyErrorAt time.Time           // 最近一次应用条目失败的时间
+	autoResyncs      int                 // 因需要的条目缺失而自动开始的全量重新同步次数
+	lastAutoResync   string              // 最近一次自动重新同步的原因
+	skipCounter      int                 // 尚未使用的跳过计数，见 SetSkipCounter
+	skippedByCounter int                 // 按跳过计数跳过的条目数
+	pendingAck       uint64              // 合并确认时尚未发送的最新已应用位置
+	tlsConfig        *tls.Config         // 访问主节点和其他从节点的TLS配置，未开启TLS时为nil
+	acksSent         atomic.Int64        // 发送给主节点的确认数
+
+	relay          *Binlog                   // 中继日志（最近已应用的条目），未开启中继时为nil
+	upstreamChain  []uint32                  // 复制源返回的复制链
+	downstream     map[string]DownstreamInfo // 从本节点同步的下游从节点
+	relayMu        sync.Mutex                // 保护upstreamChain和downstream
+	downstreamAuth *ReplicationAuth          // 下游从节点的复制凭据校验
+
+	peers          []PeerInfo      // 同一主节点下的其他从节点（来自心跳响应）
+	masterFailures int             // 主节点不可达时连续失败的同步次数
+	onPromote      func(*Master)   // 提升为主节点后的回调
+	promoted       *Master         // 提升后的主节点，未提升时为nil
+	lastElection   *ElectionResult // 最近一次选举的结果
+	electionMu     sync.Mutex      // 保护以上故障切换相关字段
 }
 
 // SlaveStats 从节点统计信息
@@ -38,6 +97,42 @@ type SlaveStats struct {
 	AppliedCount    int       // 应用条目数量
 	IsRunning       bool      // 是否正在运行
 	UptimeSeconds   int64     // 运行时间(秒)
+	// 主节点从何时开始不可达（重试耗尽仍失败），可达时为零值
+	MasterUnreachableSince time.Time
+	MasterCircuit          string // 到主节点的熔断器状态（closed/open/half_open）
+	MasterLastError        string // 最近一次访问主节点失败的原因
+	RejectedWrites         int64  // 被拒绝的写请求数（从节点只读）
+	CorruptEntries         int    // 校验失败被拒绝的binlog条目数
+	ReplicationMode        string // 复制方式（push/longpoll/poll）
+	BinlogEncoding         string // HTTP传输时请求的条目编码（binary/json）
+	// 复制延迟（秒）：最早的未应用条目在主节点上写入了多久，已追上时为0
+	LagSeconds      float64
+	MasterPosition  uint64    // 已知的主节点binlog位置
+	LastAppliedTime time.Time // 最后应用的条目在主节点上的写入时间
+	StreamConnected bool      // 推送模式下是否已连接主节点的推送流
+	ReadOnly        bool      // 数据库连接是否只读（提升为主节点期间和之后为false）
+	PollIntervalMs  int64     // 当前的轮询间隔(毫秒)
+	// 级联复制
+	ServerID          uint32   // 服务器ID
+	Epoch             uint64   // 已应用的条目中最大的主节点纪元
+	ReplicationChain  []uint32 // 复制链：从源头主节点到本节点的服务器ID
+	SkippedOwnEntries int      // 因服务器ID与本节点相同（经过复制环回到本节点）而跳过的条目数
+	RelayEnabled      bool     // 是否作为下游从节点的中继
+	DownstreamSlaves  int      // 从本节点同步的下游从节点数
+	FilteredEntries   int      // 被本地过滤规则过滤（未应用）的条目数
+	DuplicateEntries  int      // 已应用过（不超过已保存的位置）而被跳过的重复条目数
+	AutoResyncs       int      // 需要的条目已被清理或收到的条目不连续时自动开始的全量重新同步次数
+	LastAutoResync    string   // 最近一次自动重新同步的原因
+	SkipCounter       int      // 尚未使用的跳过计数
+	SkippedByCounter  int      // 按跳过计数（手动）跳过、未应用的条目数
+	AcksSent          int64    // 发送给主节点的确认数，每批条目（或每个合并间隔）只确认一次
+	// 故障切换
+	MasterFailures int             // 主节点不可达时连续失败的同步次数
+	KnownPeers     int             // 已知的同一主节点下的其他从节点数
+	Promoted       bool            // 是否已在选举中当选并提升为主节点
+	LastElection   *ElectionResult // 最近一次选举（或备用主节点接管、切换到备用主节点）的结果
+	StandbyMaster  bool            // 本节点是否为备用主节点
+	Standby        string          // 主节点故障时切换到的备用主节点地址，未配置或已切换时为空
 }
 
 // NewSlave 创建并初始化从节点
@@ -47,21 +142,114 @@ func NewSlave(cfg *config.SyncConfig, slaveID string) (*Slave, error) {
 	if err != nil {
 		return nil, fmt.Errorf("failed to connect to slave database: %w", err)
 	}
+	if err := migrateTables(db); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	// 从上次退出前已应用到的位置继续同步
+	position, err := db.LoadPosition(slaveID)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	epoch, err := db.LoadEpoch(slaveID)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	if position > 0 {
+		log.Printf("Slave %s resuming replication from position %d (epoch %d)", slaveID, position, epoch)
+	}
+
+	// 开启TLS时通过https访问主节点（推送流同样建立在TLS连接上），按配置的CA校验主节点的证书
+	scheme := "http"
+	var tlsConfig *tls.Config
+	if cfg.Slave.MasterTLS {
+		if tlsConfig, err = newClientTLSConfig(cfg.Slave.MasterCAFile); err != nil {
+			db.Close()
+			return nil, err
+		}
+		scheme = "https"
+	}
+	masterURL := fmt.Sprintf("%s://%s:%d", scheme, cfg.Slave.MasterHost, cfg.Slave.MasterPort)
+
+	mode := cfg.Slave.ReplicationMode
+	switch mode {
+	case "":
+		mode = ReplicationPush
+	case ReplicationPush, ReplicationLongPoll, ReplicationPoll:
+	default:
+		db.Close()
+		return nil, fmt.Errorf("unknown replication mode: %s", mode)
+	}
+
+	encoding, err := ParseEntryEncoding(cfg.Slave.BinlogEncoding)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	var grpcClient *grpcMasterClient
+	switch cfg.Slave.Transport {
+	case "", TransportHTTP:
+	case TransportGRPC:
+		if mode != ReplicationPush {
+			db.Close()
+			return nil, fmt.Errorf("grpc transport requires push replication mode, got %s", mode)
+		}
+		grpcClient, err = newGRPCMasterClient(&cfg.Slave)
+		if err != nil {
+			db.Close()
+			return nil, err
+		}
+	default:
+		db.Close()
+		return nil, fmt.Errorf("unknown replication transport: %s", cfg.Slave.Transport)
+	}
 
-	masterURL := fmt.Sprintf("http://%s:%d", cfg.Slave.MasterHost, cfg.Slave.MasterPort)
+	// 所有发往主节点的请求都经过故障注入层
+	faults := netfault.NewInjector()
+
+	// 作为中继时保存已应用的条目，供下游从节点同步
+	var relay *Binlog
+	if cfg.Slave.RelayLogSize > 0 {
+		relay = newRelayLog(position)
+	}
+
+	syncInterval := durationOrDefault(cfg.Slave.SyncIntervalMs, defaultSyncInterval)
+	minInterval := durationOrDefault(cfg.Slave.SyncIntervalMinMs, defaultMinSyncInterval)
+	maxInterval := max(durationOrDefault(cfg.Slave.SyncIntervalMaxMs, defaultMaxSyncInterval), minInterval)
+
+	db.SetMasterURL(masterURL)
 
 	return &Slave{
 		db:              db,
 		config:          &cfg.Slave,
+		syncConfig:      cfg,
 		slaveID:         slaveID,
-		currentPosition: 0,
-		syncInterval:    5 * time.Second, // 默认5秒同步一次
+		currentPosition: position,
+		epoch:           epoch,
+		syncInterval:    syncInterval,
+		minSyncInterval: minInterval,
+		maxSyncInterval: maxInterval,
+		pollInterval:    min(max(syncInterval, minInterval), maxInterval),
+		mode:            mode,
+		encoding:        encoding,
+		grpc:            grpcClient,
 		masterURL:       masterURL,
 		lastSyncTime:    time.Time{},
 		syncCount:       0,
 		appliedCount:    0,
 		isRunning:       false,
 		startTime:       time.Now(),
+		client:          newMasterClient(&cfg.Slave, slaveID, faults, tlsConfig),
+		tlsConfig:       tlsConfig,
+		faults:          faults,
+		verifier:        verifier{hooks: []AlertHook{LogAlertHook{}}},
+		relay:           relay,
+		downstream:      make(map[string]DownstreamInfo),
+		downstreamAuth:  NewReplicationAuth(cfg.Slave.DownstreamTokens),
 	}, nil
 }
 
@@ -75,6 +263,15 @@ func (s *Slave) StartSync() {
 	s.isRunning = true
 	go s.syncLoop()
 
+	// 定期发送心跳，主节点据此判断从节点是否存活
+	s.syncMutex.Lock()
+	s.heartbeatStop = make(chan struct{})
+	go s.heartbeatLoop(s.heartbeatStop)
+	if s.config.AckIntervalMs > 0 {
+		go s.ackLoop(s.heartbeatStop)
+	}
+	s.syncMutex.Unlock()
+
 	// 注册到主节点
 	err := s.registerWithMaster()
 	if err != nil {
@@ -82,7 +279,7 @@ func (s *Slave) StartSync() {
 		// 继续运行，后续同步时会自动注册
 	}
 
-	log.Printf("Slave %s started syncing from master at %s", s.slaveID, s.masterURL)
+	log.Printf("Slave %s started syncing from master at %s (%s mode)", s.slaveID, s.masterURL, s.mode)
 }
 
 // StopSync 停止同步进程
@@ -90,62 +287,265 @@ func (s *Slave) StopSync() {
 	s.syncMutex.Lock()
 	defer s.syncMutex.Unlock()
 	s.isRunning = false
+	if s.stream != nil {
+		s.stream.Close()
+	}
+	if s.heartbeatStop != nil {
+		close(s.heartbeatStop)
+		s.heartbeatStop = nil
+	}
 	log.Printf("Slave %s stopped syncing", s.slaveID)
 }
 
-// syncLoop 同步循环，定期从主节点获取binlog并应用
+// syncLoop 同步循环：
+//   - push：保持与主节点推送流的连接，断开后改用长轮询，一个同步周期后重新订阅
+//     （主节点或中间代理拒绝WebSocket升级时一分钟后再尝试）
+//   - longpoll：连续发送长轮询请求，请求在主节点上等待新条目
+//   - poll：定期从主节点获取binlog并应用，间隔随同步结果在上下限之间自适应调整
 func (s *Slave) syncLoop() {
-	ticker := time.NewTicker(s.syncInterval)
-	defer ticker.Stop()
-
+	var retryStreamAt time.Time
 	for {
 		if !s.isRunning {
 			return
 		}
 
-		err := s.syncOnce()
-		if err != nil {
+		if s.mode == ReplicationPush && !time.Now().Before(retryStreamAt) {
+			err := s.streamOnce()
+			if !s.isRunning {
+				return
+			}
+			retryStreamAt = time.Now().Add(s.syncInterval)
+			if err != nil {
+				if s.autoResync(err) {
+					return
+				}
+				var handshakeErr *wsconn.HandshakeError
+				if errors.As(err, &handshakeErr) {
+					retryStreamAt = time.Now().Add(streamRejectedRetry)
+				}
+				log.Printf("Binlog stream unavailable, falling back to long polling: %v", err)
+			}
+		}
+
+		wait := time.Duration(0)
+		if s.mode != ReplicationPoll {
+			wait = longPollWait
+		}
+		before := s.GetCurrentPosition()
+		err := s.syncOnce(wait)
+		interval := s.adjustPollInterval(s.GetCurrentPosition() > before, err)
+		s.noteSyncResult(err)
+		if !s.isRunning {
+			return
+		}
+		if err != nil && s.autoResync(err) {
+			return
+		}
+		// 熔断期间每个周期都会被拒绝，不可达和恢复由客户端在状态变化时记录
+		if err != nil && !errors.Is(err, ErrCircuitOpen) {
 			log.Printf("Error during sync: %v", err)
 			// 继续尝试，不要中断循环
 		}
 
-		<-ticker.C // 等待下一个同步周期
+		// 长轮询请求本身会在主节点上等待，成功后立即发起下一次
+		if wait > 0 && err == nil {
+			continue
+		}
+		time.Sleep(interval) // 等待下一个同步周期
 	}
 }
 
-// syncOnce 执行一次同步
-func (s *Slave) syncOnce() error {
+// adjustPollInterval 根据一次同步的结果调整并返回下一次轮询前的等待时间：
+// 拉取到新条目时缩短到下限，尽快获取后续的条目；没有新条目时加倍，直到上限，空闲时减少对主节点的请求；
+// 同步失败时回到配置的同步间隔，不因退避推迟主节点故障的检测
+func (s *Slave) adjustPollInterval(progressed bool, err error) time.Duration {
 	s.syncMutex.Lock()
 	defer s.syncMutex.Unlock()
+	switch {
+	case err != nil:
+		s.pollInterval = min(max(s.syncInterval, s.minSyncInterval), s.maxSyncInterval)
+	case progressed:
+		s.pollInterval = s.minSyncInterval
+	default:
+		s.pollInterval = min(s.pollInterval*2, s.maxSyncInterval)
+	}
+	return s.pollInterval
+}
 
+// syncOnce 执行一次同步，wait大于0时为长轮询
+// 主节点分页返回条目，应用一页后立即获取下一页，直到追上主节点（只有第一次请求长轮询）
+// 拉取期间不持有同步锁，长轮询等待时不阻塞状态查询
+func (s *Slave) syncOnce(wait time.Duration) error {
+	for {
+		more, err := s.syncPage(wait)
+		if err != nil || !more || !s.isRunning {
+			return err
+		}
+		wait = 0
+	}
+}
+
+// syncPage 获取并应用一页binlog条目，还有更多条目时返回true
+func (s *Slave) syncPage(wait time.Duration) (bool, error) {
 	// 从主节点获取最新binlog条目
-	entries, err := s.fetchBinlogEntries()
+	position := s.GetCurrentPosition()
+	page, err := s.fetchBinlogEntries(position, wait)
 	if err != nil {
-		return fmt.Errorf("failed to fetch binlog entries: %w", err)
+		return false, fmt.Errorf("failed to fetch binlog entries: %w", err)
 	}
 
-	if len(entries) == 0 {
-		// 没有新条目，跳过
-		return nil
+	if len(page.entries) == 0 && page.scanned <= position {
+		// 没有新条目，说明已追上主节点
+		s.observeMasterPosition(position)
+		return false, nil
 	}
 
-	// 应用每个条目
-	for _, entry := range entries {
-		err := ApplyEntry(s.db, entry)
-		if err != nil {
-			return fmt.Errorf("failed to apply binlog entry %d: %w", entry.ID, err)
+	s.syncMutex.Lock()
+	defer s.syncMutex.Unlock()
+	if s.currentPosition != position {
+		// 获取期间位置被重置（全量重新同步），这一页已经过时
+		return false, nil
+	}
+	// 中继日志中被中继过滤、跳过的条目不存在，只检查来自主节点的条目
+	if s.expectsContiguous(!page.relay) {
+		if err := checkContiguous(position, page.entries); err != nil {
+			return false, err
+		}
+	}
+	if len(page.entries) > 0 {
+		if err := s.applyBatch(page.entries); err != nil {
+			return false, err
 		}
+	}
+	// 主节点按过滤规则过滤掉的条目不在结果中，跳过它们
+	if err := s.advancePast(page.scanned); err != nil {
+		return false, err
+	}
+	return page.next > 0, nil
+}
 
-		// 更新位置并发送确认
-		s.currentPosition = entry.ID
-		s.appliedCount++
+// applyBatch 应用一批从主节点收到的条目并确认（调用方持有syncMutex）
+func (s *Slave) applyBatch(entries []BinlogEntry) error {
+	// MySQL在DDL前后隐式提交，DDL条目单独应用，之前和之后的条目分别在自己的事务中按顺序应用
+	if before, ddl, after, ok := splitAtDDL(entries); ok {
+		for _, part := range [][]BinlogEntry{before, ddl, after} {
+			if len(part) == 0 {
+				continue
+			}
+			if err := s.applyBatch(part); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	s.beginApply(entries)
 
-		// 向主节点发送ACK
-		err = s.sendACKToMaster(entry.ID)
+	// 在一个事务中应用整批条目并保存位置，崩溃后不会出现数据已应用而位置未推进（重复应用）的情况
+	type applied struct {
+		entry    BinlogEntry
+		start    time.Time
+		duration time.Duration
+	}
+	samples := make([]applied, 0, len(entries))
+	skipped, filtered, duplicates := 0, 0, 0
+	var skippedByCounter []uint64
+	epoch := s.epoch
+	err := s.db.Transaction(func(tx *storage.DB) error {
+		// 已保存的位置是已应用条目ID的高水位（条目按ID顺序应用，位置与数据在同一事务中保存），
+		// 不超过它的条目已经应用过（如部分失败后重新拉取、推送流重连后重发），直接跳过。
+		// 读取时锁定位置行，直到事务提交：数据和位置要么一起生效，要么一起回滚
+		watermark, err := tx.LockPosition(s.slaveID)
 		if err != nil {
-			log.Printf("Warning: Failed to send ACK for position %d: %v", entry.ID, err)
-			// 继续处理，不中断应用流程
+			return err
+		}
+		for _, entry := range entries {
+			if entry.ID <= watermark {
+				duplicates++
+				continue
+			}
+			// 来自更旧纪元的条目由故障切换前的主节点产生，整批拒绝
+			if epoch, err = checkEpoch(entry, epoch); err != nil {
+				return err
+			}
+			// 管理员设置了跳过计数时不应用，只推进位置（事务回滚时重试会再次跳过）
+			if len(skippedByCounter) < s.skipCounter {
+				skippedByCounter = append(skippedByCounter, entry.ID)
+				continue
+			}
+			// 本节点产生的条目经过复制环回到了本节点，已经应用过，只推进位置
+			if s.isOwnEntry(entry) {
+				skipped++
+				continue
+			}
+			// 不匹配过滤规则的条目不应用，同样只推进位置
+			if !filterMatches(&s.config.Filter, entry) {
+				filtered++
+				continue
+			}
+			start := time.Now()
+			if err := ApplyEntryWithOptions(tx, entry, ApplyOptions{CheckBeforeImage: s.config.UpdateConflictCheck}); err != nil {
+				return fmt.Errorf("failed to apply binlog entry %d: %w", entry.ID, err)
+			}
+			samples = append(samples, applied{entry: entry, start: start, duration: time.Since(start)})
+		}
+		if err := tx.SavePosition(s.slaveID, max(watermark, entries[len(entries)-1].ID)); err != nil {
+			return err
+		}
+		if epoch > s.epoch {
+			return tx.SaveEpoch(s.slaveID, epoch)
 		}
+		return nil
+	})
+	if err != nil {
+		// 损坏的条目整批回滚并上报主节点，下个同步周期重新拉取
+		var checksumErr *ChecksumError
+		if errors.As(err, &checksumErr) {
+			s.corruptEntries++
+			if reportErr := s.reportCorruption(checksumErr); reportErr != nil {
+				log.Printf("Warning: Failed to report corrupted entry %d: %v", checksumErr.Position, reportErr)
+			}
+		}
+		s.recordApplyError(err)
+		return err
+	}
+
+	s.recordApplyError(nil)
+	s.useSkipCounter(skippedByCounter)
+	if epoch > s.epoch {
+		log.Printf("Slave %s now following master epoch %d", s.slaveID, epoch)
+		s.epoch = epoch
+	}
+	s.finishApply(entries)
+	last := entries[len(entries)-1].ID
+	appliedEntries := make([]BinlogEntry, 0, len(samples))
+	for _, a := range samples {
+		appliedEntries = append(appliedEntries, a.entry)
+	}
+	s.relayApplied(appliedEntries, last)
+	s.filteredCount += filtered
+	if duplicates > 0 {
+		s.duplicateCount += duplicates
+		log.Printf("Skipped %d already applied binlog entries", duplicates)
+	}
+	if skipped > 0 {
+		s.skippedOwn += skipped
+		log.Printf("Warning: skipped %d binlog entries with this node's server id %d (replication loop)", skipped, s.config.ServerID)
+	}
+
+	previous := s.currentPosition
+	for _, a := range samples {
+		s.recordApply(a.entry, a.start, a.duration)
+		s.currentPosition = a.entry.ID
+		s.appliedCount++
+	}
+	// 最后的条目被跳过时同样推进位置
+	if s.currentPosition < last {
+		s.currentPosition = last
+	}
+	// 整批只确认最后的位置，确认失败不中断应用流程
+	if s.currentPosition > previous {
+		s.ackApplied(s.currentPosition)
 	}
 
 	s.syncCount++
@@ -155,32 +555,90 @@ func (s *Slave) syncOnce() error {
 	return nil
 }
 
-// fetchBinlogEntries 从主节点获取binlog条目
-func (s *Slave) fetchBinlogEntries() ([]BinlogEntry, error) {
-	url := fmt.Sprintf("%s/api/binlog?position=%d&slave_id=%s",
-		s.masterURL, s.currentPosition, s.slaveID)
+// binlogPage 一次从主节点获取的条目
+type binlogPage struct {
+	entries []BinlogEntry // 条目
+	scanned uint64        // 主节点检查到的最后一个条目ID（主节点按过滤规则筛选时被过滤的条目不在结果中），未返回时为0
+	next    uint64        // 条目被截断时下一次请求的位置，已返回全部条目时为0
+	relay   bool          // 条目来自中继从节点的中继日志
+}
 
-	resp, err := http.Get(url)
+// fetchBinlogEntries 从主节点获取position之后的一页binlog条目，wait大于0时主节点最多等待wait才返回
+func (s *Slave) fetchBinlogEntries(position uint64, wait time.Duration) (binlogPage, error) {
+	limit, maxBytes := s.fetchLimits()
+	url := fmt.Sprintf("%s/api/binlog?position=%d&slave_id=%s&server_id=%d&limit=%d&max_bytes=%d&encoding=%s",
+		s.masterURL, position, s.slaveID, s.config.ServerID, limit, maxBytes, s.encoding)
+
+	var resp *http.Response
+	var err error
+	if wait > 0 {
+		resp, err = s.client.DoLongPoll(fmt.Sprintf("%s&wait=%d", url, int(wait.Seconds())))
+	} else {
+		resp, err = s.doRequest(http.MethodGet, url, nil)
+	}
 	if err != nil {
-		return nil, fmt.Errorf("failed to connect to master: %w", err)
+		return binlogPage{}, fmt.Errorf("failed to connect to master: %w", err)
 	}
 	defer resp.Body.Close()
 
+	if resp.StatusCode == http.StatusGone {
+		// 主节点已清理了当前位置之后的条目，增量同步无法继续
+		var body struct {
+			OldestPosition uint64 `json:"oldest_position"`
+		}
+		json.NewDecoder(resp.Body).Decode(&body)
+		return binlogPage{}, &PositionPurgedError{Requested: position, Oldest: body.OldestPosition}
+	}
+	if resp.StatusCode == http.StatusConflict {
+		return binlogPage{}, fmt.Errorf("%w: master rejected server id %d", ErrReplicationLoop, s.config.ServerID)
+	}
+	if resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden {
+		return binlogPage{}, fmt.Errorf("%w: master rejected slave %s (%s)", ErrUnauthorized, s.slaveID, resp.Status)
+	}
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("master returned error status: %s", resp.Status)
+		return binlogPage{}, fmt.Errorf("master returned error status: %s", resp.Status)
+	}
+	if err := s.observeUpstreamChain(resp.Header.Get(ChainHeader)); err != nil {
+		return binlogPage{}, err
 	}
 
-	var entries []BinlogEntry
-	err = json.NewDecoder(resp.Body).Decode(&entries)
+	scanned, err := parseScanned(resp.Header.Get(ScannedHeader))
 	if err != nil {
-		return nil, fmt.Errorf("failed to decode response: %w", err)
+		return binlogPage{}, err
 	}
 
-	return entries, nil
+	next, err := parseNextPosition(resp.Header.Get(NextPositionHeader))
+	if err != nil {
+		return binlogPage{}, err
+	}
+
+	// 按响应的Content-Type解析，不支持二进制编码的主节点仍返回JSON
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return binlogPage{}, fmt.Errorf("failed to read response: %w", err)
+	}
+	encoding := EncodingJSON
+	if resp.Header.Get("Content-Type") == BinaryContentType {
+		encoding = EncodingBinary
+	}
+	entries, err := DecodeEntries(encoding, body)
+	if err != nil {
+		return binlogPage{}, fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	relay := resp.Header.Get(RelayHeader) == "true"
+	return binlogPage{entries: entries, scanned: scanned, next: next, relay: relay}, nil
 }
 
 // sendACKToMaster 向主节点发送确认
 func (s *Slave) sendACKToMaster(position uint64) error {
+	if s.grpc != nil {
+		if err := s.grpc.ack(s.slaveID, position); err != nil {
+			return fmt.Errorf("failed to send ACK: %w", err)
+		}
+		return nil
+	}
+
 	url := fmt.Sprintf("%s/api/ack", s.masterURL)
 
 	data := map[string]interface{}{
@@ -193,12 +651,15 @@ func (s *Slave) sendACKToMaster(position uint64) error {
 		return fmt.Errorf("failed to marshal ACK data: %w", err)
 	}
 
-	resp, err := http.Post(url, "application/json", bytes.NewBuffer(jsonData))
+	resp, err := s.doRequest(http.MethodPost, url, jsonData)
 	if err != nil {
 		return fmt.Errorf("failed to send ACK: %w", err)
 	}
 	defer resp.Body.Close()
 
+	if resp.StatusCode == http.StatusConflict {
+		return ErrLeaseExpired
+	}
 	if resp.StatusCode != http.StatusOK {
 		return fmt.Errorf("master returned error status for ACK: %s", resp.Status)
 	}
@@ -206,14 +667,57 @@ func (s *Slave) sendACKToMaster(position uint64) error {
 	return nil
 }
 
+// reportCorruption 向主节点上报校验失败的条目
+func (s *Slave) reportCorruption(checksumErr *ChecksumError) error {
+	url := fmt.Sprintf("%s/api/corruption", s.masterURL)
+
+	jsonData, err := json.Marshal(CorruptionReport{
+		SlaveID:  s.slaveID,
+		Position: checksumErr.Position,
+		Expected: checksumErr.Expected,
+		Actual:   checksumErr.Actual,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to marshal corruption report: %w", err)
+	}
+
+	resp, err := s.doRequest(http.MethodPost, url, jsonData)
+	if err != nil {
+		return fmt.Errorf("failed to send corruption report: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("master returned error status for corruption report: %s", resp.Status)
+	}
+	return nil
+}
+
 // registerWithMaster 向主节点注册从节点
 func (s *Slave) registerWithMaster() error {
+	if s.grpc != nil {
+		chain, err := s.grpc.register(s.slaveID, s.config.Host, s.config.APIPort, s.config.ServerID, s.masterFilter())
+		if err != nil {
+			return fmt.Errorf("failed to register with master: %w", err)
+		}
+		if err := s.setUpstreamChain(chain); err != nil {
+			return err
+		}
+		log.Printf("Successfully registered with master")
+		return nil
+	}
+
 	url := fmt.Sprintf("%s/api/register_slave", s.masterURL)
 
 	data := map[string]interface{}{
-		"slave_id": s.slaveID,
-		"host":     s.config.Host,
-		"port":     s.config.APIPort,
+		"slave_id":  s.slaveID,
+		"host":      s.config.Host,
+		"port":      s.config.APIPort,
+		"server_id": s.config.ServerID,
+		"position":  s.GetCurrentPosition(),
+	}
+	if filter := s.masterFilter(); filter != nil {
+		data["filter"] = filter
 	}
 
 	jsonData, err := json.Marshal(data)
@@ -221,33 +725,87 @@ func (s *Slave) registerWithMaster() error {
 		return fmt.Errorf("failed to marshal registration data: %w", err)
 	}
 
-	resp, err := http.Post(url, "application/json", bytes.NewBuffer(jsonData))
+	resp, err := s.doRequest(http.MethodPost, url, jsonData)
 	if err != nil {
 		return fmt.Errorf("failed to register with master: %w", err)
 	}
 	defer resp.Body.Close()
 
+	if resp.StatusCode == http.StatusConflict {
+		return fmt.Errorf("%w: master rejected server id %d", ErrReplicationLoop, s.config.ServerID)
+	}
+	if resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden {
+		return fmt.Errorf("%w: master rejected slave %s (%s)", ErrUnauthorized, s.slaveID, resp.Status)
+	}
 	if resp.StatusCode != http.StatusOK {
 		return fmt.Errorf("master returned error status for registration: %s", resp.Status)
 	}
+	if err := s.observeUpstreamChain(resp.Header.Get(ChainHeader)); err != nil {
+		return err
+	}
+	var registered struct {
+		PositionCheck PositionCheck `json:"position_check"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&registered); err == nil {
+		logPositionCheck(registered.PositionCheck)
+	}
 
 	log.Printf("Successfully registered with master")
 	return nil
 }
 
+// doRequest 向主节点发送请求（带重试和熔断），并携带从节点标识
+func (s *Slave) doRequest(method string, url string, body []byte) (*http.Response, error) {
+	return s.client.Do(method, url, body)
+}
+
 // GetStats 获取从节点统计信息
 func (s *Slave) GetStats() SlaveStats {
 	s.syncMutex.Lock()
 	defer s.syncMutex.Unlock()
 
+	circuit, unreachableSince, lastError := s.client.status()
+	failures, peers, promoted, lastElection := s.failoverStats()
 	return SlaveStats{
-		SlaveID:         s.slaveID,
-		CurrentPosition: s.currentPosition,
-		LastSyncTime:    s.lastSyncTime,
-		SyncCount:       s.syncCount,
-		AppliedCount:    s.appliedCount,
-		IsRunning:       s.isRunning,
-		UptimeSeconds:   int64(time.Since(s.startTime).Seconds()),
+		SlaveID:                s.slaveID,
+		CurrentPosition:        s.currentPosition,
+		LastSyncTime:           s.lastSyncTime,
+		SyncCount:              s.syncCount,
+		AppliedCount:           s.appliedCount,
+		IsRunning:              s.isRunning,
+		UptimeSeconds:          int64(time.Since(s.startTime).Seconds()),
+		MasterUnreachableSince: unreachableSince,
+		MasterCircuit:          circuit,
+		MasterLastError:        lastError,
+		RejectedWrites:         s.rejectedWriteCount(),
+		CorruptEntries:         s.corruptEntries,
+		ReplicationMode:        s.mode,
+		BinlogEncoding:         string(s.encoding),
+		StreamConnected:        s.stream != nil,
+		ReadOnly:               s.db.ReadOnly(),
+		PollIntervalMs:         s.pollInterval.Milliseconds(),
+		LagSeconds:             s.lag(time.Now()).Seconds(),
+		MasterPosition:         s.masterPosition,
+		LastAppliedTime:        s.lastAppliedTime,
+		ServerID:               s.config.ServerID,
+		Epoch:                  s.epoch,
+		ReplicationChain:       s.ReplicationChain(),
+		SkippedOwnEntries:      s.skippedOwn,
+		RelayEnabled:           s.relayEnabled(),
+		DownstreamSlaves:       len(s.DownstreamSlaves()),
+		FilteredEntries:        s.filteredCount,
+		DuplicateEntries:       s.duplicateCount,
+		AutoResyncs:            s.autoResyncs,
+		SkipCounter:            s.skipCounter,
+		SkippedByCounter:       s.skippedByCounter,
+		AcksSent:               s.acksSent.Load(),
+		LastAutoResync:         s.lastAutoResync,
+		MasterFailures:         failures,
+		KnownPeers:             peers,
+		Promoted:               promoted,
+		LastElection:           lastElection,
+		StandbyMaster:          s.config.StandbyMaster,
+		Standby:                s.standbyAddress(),
 	}
 }
 
@@ -258,17 +816,28 @@ func (s *Slave) GetCurrentPosition() uint64 {
 	return s.currentPosition
 }
 
-// SetSyncInterval 设置同步间隔
+// SetSyncInterval 设置同步间隔，轮询间隔从它重新开始自适应调整
 func (s *Slave) SetSyncInterval(interval time.Duration) {
 	s.syncMutex.Lock()
 	defer s.syncMutex.Unlock()
 	s.syncInterval = interval
+	s.pollInterval = min(max(interval, s.minSyncInterval), s.maxSyncInterval)
 	log.Printf("Sync interval set to %v", interval)
 }
 
 // Close 关闭从节点连接
 func (s *Slave) Close() error {
+	s.StopVerifier()
 	s.StopSync()
+	if master := s.Promoted(); master != nil {
+		// 提升后的主节点与从节点共用数据库连接，只停止它的后台任务
+		master.stopBackground()
+	}
+	if s.grpc != nil {
+		if err := s.grpc.close(); err != nil {
+			log.Printf("Error closing grpc connection: %v", err)
+		}
+	}
 	err := s.db.Close()
 	if err != nil {
 		return fmt.Errorf("failed to close database connection: %w", err)
@@ -282,3 +851,8 @@ func (s *Slave) Close() error {
 func (s *Slave) GetDB() *storage.DB {
 	return s.db
 }
+
+// GetFaultInjector 获取网络故障注入器
+func (s *Slave) GetFaultInjector() *netfault.Injector {
+	return s.faults
+}
@@ -0,0 +1,98 @@
+package replication
+
+import (
+	"fmt"
+	"log"
+	"time"
+)
+
+// standbyAddress 配置的备用主节点地址（host:port），未配置时为空
+func (s *Slave) standbyAddress() string {
+	if s.config.StandbyHost == "" || s.config.StandbyPort == 0 {
+		return ""
+	}
+	return fmt.Sprintf("%s:%d", s.config.StandbyHost, s.config.StandbyPort)
+}
+
+// recordFailover 记录一次接管或切换的结果，与选举结果一样通过 LastElection 查看
+func (s *Slave) recordFailover(result ElectionResult, err error) error {
+	if err != nil {
+		result.Message = err.Error()
+	}
+	s.electionMu.Lock()
+	s.lastElection = &result
+	s.electionMu.Unlock()
+	return err
+}
+
+// TakeOver 备用主节点在主节点故障时直接提升为主节点，不经过选举：
+// 其他从节点仍能访问主节点时放弃（可能只是本节点与主节点之间的网络故障，接管会出现两个主节点）；
+// 已有从节点提升为主节点时改为跟随它
+func (s *Slave) TakeOver() error {
+	result := ElectionResult{Time: time.Now()}
+
+	s.electionMu.Lock()
+	if s.promoted != nil {
+		s.electionMu.Unlock()
+		return fmt.Errorf("%w: this node is already the master", ErrElectionAborted)
+	}
+	peers := append([]PeerInfo(nil), s.peers...)
+	s.electionMu.Unlock()
+
+	self := s.ElectionVote()
+	self.MasterReachable = false
+	result.Votes = append(result.Votes, self)
+	for _, peer := range peers {
+		vote, err := s.fetchElectionVote(peer)
+		if err != nil {
+			log.Printf("Standby takeover: slave %s unavailable: %v", peer.ID, err)
+			continue
+		}
+		result.Votes = append(result.Votes, vote)
+		if vote.Promoted {
+			result.Winner, result.Position = vote.SlaveID, vote.Position
+			log.Printf("Standby takeover: slave %s has already been promoted, following it", vote.SlaveID)
+			return s.recordFailover(result, s.repoint(vote.Host, vote.Port))
+		}
+		if vote.MasterReachable {
+			return s.recordFailover(result, fmt.Errorf("%w: slave %s can still reach the master", ErrElectionAborted, vote.SlaveID))
+		}
+	}
+
+	result.Winner, result.Position = s.slaveID, self.Position
+	log.Printf("Standby master %s taking over at position %d", s.slaveID, self.Position)
+	if err := s.promote(); err != nil {
+		return s.recordFailover(result, err)
+	}
+	result.Promoted = true
+	result.Message = "standby master took over"
+	return s.recordFailover(result, nil)
+}
+
+// followStandby 主节点故障时切换到配置的备用主节点：备用主节点接管（提升为主节点）之后才切换，
+// 此前返回错误，下一次同步失败时再检查
+func (s *Slave) followStandby() error {
+	host, port := s.config.StandbyHost, s.config.StandbyPort
+	result := ElectionResult{Time: time.Now()}
+
+	vote, err := s.fetchElectionVote(PeerInfo{Host: host, Port: port})
+	if err != nil {
+		return s.recordFailover(result, fmt.Errorf("standby master %s unavailable: %w", s.standbyAddress(), err))
+	}
+	result.Votes = []ElectionVote{vote}
+	if !vote.Promoted {
+		return s.recordFailover(result, fmt.Errorf("%w: standby master %s has not taken over yet", ErrElectionAborted, s.standbyAddress()))
+	}
+
+	result.Winner, result.Position = vote.SlaveID, vote.Position
+	result.Message = "switched to the standby master"
+	log.Printf("Master unreachable, switching to standby master %s (position %d)", s.standbyAddress(), vote.Position)
+	if err := s.repoint(host, port); err != nil {
+		return s.recordFailover(result, err)
+	}
+	// 备用主节点已成为复制源，之后不再有备用主节点
+	s.syncMutex.Lock()
+	s.config.StandbyHost, s.config.StandbyPort = "", 0
+	s.syncMutex.Unlock()
+	return s.recordFailover(result, nil)
+}
@@ -0,0 +1,42 @@
+package replication
+
+import (
+	"fmt"
+
+	"master-slave-sync/internal/storage"
+)
+
+// binlog格式
+const (
+	BinlogFormatRow       = "row"       // 基于行（默认）：记录写入后的行数据，从节点按表写入行
+	BinlogFormatStatement = "statement" // 基于语句：记录执行的SQL和参数，从节点重放语句
+)
+
+// parseBinlogFormat 校验主节点配置的binlog格式，为空时使用基于行的格式
+func parseBinlogFormat(format string) (string, error) {
+	switch format {
+	case "", BinlogFormatRow:
+		return BinlogFormatRow, nil
+	case BinlogFormatStatement:
+		return BinlogFormatStatement, nil
+	default:
+		return "", fmt.Errorf("unknown binlog format: %s", format)
+	}
+}
+
+// applyStatements 在从库上重放基于语句的条目，插入时使用主节点分配的自增ID
+func applyStatements(db *storage.DB, entry BinlogEntry) error {
+	statements, err := storage.DecodeStatements(entry.Data)
+	if err != nil {
+		return err
+	}
+
+	var insertID uint
+	if entry.Operation == OpInsert {
+		insertID = entry.RecordID
+	}
+	if err := db.ReplayStatements(statements, insertID); err != nil {
+		return fmt.Errorf("failed to apply %s statement to %s: %w", entry.Operation, entry.TableName, err)
+	}
+	return nil
+}
@@ -0,0 +1,258 @@
+package replication
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"master-slave-sync/internal/wsconn"
+)
+
+// 复制方式
+const (
+	ReplicationPush     = "push"     // 通过WebSocket接收主节点推送（默认），推送流不可用时回退到长轮询
+	ReplicationLongPoll = "longpoll" // 长轮询：请求在主节点上等待新条目，适用于不支持WebSocket的网络环境
+	ReplicationPoll     = "poll"     // 每个同步周期轮询一次主节点
+)
+
+// 推送流参数
+const (
+	streamHeartbeatInterval = 5 * time.Second             // 没有新条目时主节点发送心跳的间隔
+	streamIdleTimeout       = 3 * streamHeartbeatInterval // 从节点超过该时长没有收到任何消息即认为连接已失效
+	maxStreamBatch          = 500                         // 每条推送消息最多包含的条目数
+	streamRejectedRetry     = time.Minute                 // 主节点或中间代理拒绝WebSocket升级后，多久再尝试推送流
+	longPollWait            = 30 * time.Second            // 从节点长轮询时请求主节点等待的时长
+)
+
+// MaxBinlogWait 长轮询请求在主节点上等待新条目的最长时间
+const MaxBinlogWait = 60 * time.Second
+
+// StreamMessage 推送流上的一条消息，没有条目的消息为心跳
+type StreamMessage struct {
+	Entries        []BinlogEntry `json:"entries,omitempty"`         // 新条目（按ID递增）
+	Position       uint64        `json:"position"`                  // 发送时主节点的binlog位置
+	Error          string        `json:"error,omitempty"`           // 推送无法继续的原因，发送后主节点关闭连接
+	OldestPosition uint64        `json:"oldest_position,omitempty"` // 请求的位置已被清理时，最早可用的条目ID
+	Scanned        uint64        `json:"scanned,omitempty"`         // 按从节点的过滤规则检查到的最后一个条目ID，被过滤的条目不在Entries中
+}
+
+// ServeBinlogStream 通过已升级的WebSocket连接向从节点推送binlog，从节点断开、binlog关闭或写入失败时返回
+// 从节点仍通过 /api/ack 确认已应用的位置，推送流只负责下发条目；二进制编码的消息以二进制帧发送
+func (m *Master) ServeBinlogStream(conn *wsconn.Conn, slaveID string, fromPosition uint64, enc EntryEncoding) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// 读取端只用于处理控制帧和发现从节点断开
+	go func() {
+		defer cancel()
+		for {
+			if _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	send := func(msg StreamMessage) error {
+		data, err := encodeStreamMessage(enc, msg)
+		if err != nil {
+			return fmt.Errorf("failed to encode stream message: %w", err)
+		}
+		if enc == EncodingBinary {
+			return conn.WriteBinaryMessage(data)
+		}
+		return conn.WriteMessage(data)
+	}
+
+	err := m.StreamBinlog(ctx, slaveID, fromPosition, send)
+	var purged *PositionPurgedError
+	if errors.As(err, &purged) {
+		send(StreamMessage{Position: m.binlog.GetCurrentPosition(), Error: err.Error(), OldestPosition: purged.Oldest})
+	}
+	return err
+}
+
+// StreamBinlog 推送流的通用实现（WebSocket和gRPC共用）：先通过send推送fromPosition之后的已有条目，
+// 之后每追加新条目立即推送，空闲时定期发送不含条目的心跳
+// ctx取消或binlog关闭时返回nil；位置已被清理时返回 *PositionPurgedError；send失败时返回其错误
+func (m *Master) StreamBinlog(ctx context.Context, slaveID string, fromPosition uint64, send func(StreamMessage) error) error {
+	m.mu.Lock()
+	m.streamingSlaves++
+	m.mu.Unlock()
+	defer func() {
+		m.mu.Lock()
+		m.streamingSlaves--
+		m.mu.Unlock()
+	}()
+	log.Printf("Slave %s subscribed to binlog stream from position %d", slaveID, fromPosition)
+
+	// 订阅后立即发送一次心跳，从节点据此确认推送流已建立
+	if err := send(StreamMessage{Position: m.binlog.GetCurrentPosition()}); err != nil {
+		return err
+	}
+
+	heartbeat := time.NewTicker(streamHeartbeatInterval)
+	defer heartbeat.Stop()
+
+	position := fromPosition
+	for {
+		changed, closed := m.binlog.notifications()
+		entries, err := m.binlog.EntriesAfter(position)
+		if err != nil {
+			return err
+		}
+		for len(entries) > 0 {
+			n := min(len(entries), maxStreamBatch)
+			// 按从节点注册的过滤规则筛选，整批被过滤时只发送检查到的位置
+			batch, scanned := m.FilterBinlogEntries(slaveID, entries[:n])
+			if err := send(StreamMessage{Entries: batch, Position: m.binlog.GetCurrentPosition(), Scanned: scanned}); err != nil {
+				return err
+			}
+			position = entries[n-1].ID
+			entries = entries[n:]
+		}
+
+		select {
+		case <-changed:
+		case <-heartbeat.C:
+			if err := send(StreamMessage{Position: m.binlog.GetCurrentPosition()}); err != nil {
+				return err
+			}
+		case <-closed:
+			return nil
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+// WaitBinlogEntries 获取指定位置之后的binlog条目，没有新条目时最多等待wait，期间有条目追加立即返回
+// 等待超时、ctx取消或binlog关闭时返回空结果；位置已被清理时返回 *PositionPurgedError
+func (m *Master) WaitBinlogEntries(ctx context.Context, fromPosition uint64, wait time.Duration) ([]BinlogEntry, error) {
+	return m.binlog.waitEntriesAfter(ctx, fromPosition, wait)
+}
+
+// waitEntriesAfter 长轮询的通用实现（主节点的binlog和从节点的中继日志共用）
+func (b *Binlog) waitEntriesAfter(ctx context.Context, fromPosition uint64, wait time.Duration) ([]BinlogEntry, error) {
+	if wait > MaxBinlogWait {
+		wait = MaxBinlogWait
+	}
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+
+	for {
+		changed, closed := b.notifications()
+		entries, err := b.EntriesAfter(fromPosition)
+		if err != nil || len(entries) > 0 || wait <= 0 {
+			return entries, err
+		}
+
+		select {
+		case <-changed:
+		case <-timer.C:
+			return nil, nil
+		case <-closed:
+			return nil, nil
+		case <-ctx.Done():
+			return nil, nil
+		}
+	}
+}
+
+// streamOnce 按配置的传输协议连接主节点的推送流并应用收到的条目，直到连接断开或同步停止
+// 连接时携带当前位置，主节点先补发断开期间的条目
+func (s *Slave) streamOnce() error {
+	if s.grpc != nil {
+		return s.grpcStreamOnce()
+	}
+
+	url := fmt.Sprintf("%s/api/binlog/stream?position=%d&slave_id=%s&server_id=%d&encoding=%s",
+		s.masterURL, s.GetCurrentPosition(), s.slaveID, s.config.ServerID, s.encoding)
+	header := http.Header{}
+	s.client.setHeaders(header)
+
+	conn, err := wsconn.Dial(s.client.stream, url, header)
+	if err != nil {
+		var handshake *wsconn.HandshakeError
+		if errors.As(err, &handshake) && handshake.StatusCode == http.StatusConflict {
+			return fmt.Errorf("%w: %s", ErrReplicationLoop, handshake.Body)
+		}
+		if errors.As(err, &handshake) && (handshake.StatusCode == http.StatusUnauthorized || handshake.StatusCode == http.StatusForbidden) {
+			return fmt.Errorf("%w: %s", ErrUnauthorized, handshake.Body)
+		}
+		return fmt.Errorf("failed to connect to master stream: %w", err)
+	}
+	defer conn.Close()
+	if !s.setStream(conn) {
+		// 连接建立期间同步已被停止
+		return nil
+	}
+	defer s.setStream(nil)
+	log.Printf("Slave %s connected to master binlog stream", s.slaveID)
+
+	// 主节点空闲时也会定期发送心跳，长时间收不到任何消息说明连接已失效（如网络中断而没有收到FIN）
+	watchdog := time.AfterFunc(streamIdleTimeout, func() { conn.Close() })
+	defer watchdog.Stop()
+
+	for {
+		data, err := conn.ReadMessage()
+		if err != nil {
+			if !s.isRunning {
+				return nil
+			}
+			return err
+		}
+		watchdog.Reset(streamIdleTimeout)
+
+		msg, err := decodeStreamMessage(data)
+		if err != nil {
+			return fmt.Errorf("failed to decode stream message: %w", err)
+		}
+		if msg.Error != "" {
+			if msg.OldestPosition > 0 {
+				return &PositionPurgedError{Requested: s.GetCurrentPosition(), Oldest: msg.OldestPosition}
+			}
+			return fmt.Errorf("master closed stream: %s", msg.Error)
+		}
+		s.observeMasterPosition(msg.Position)
+		if len(msg.Entries) == 0 && msg.Scanned == 0 {
+			continue
+		}
+
+		if err := s.applyPushed(msg.Entries, msg.Scanned); err != nil {
+			// 应用失败时断开，回退到轮询并在下个同步周期从已应用的位置重新订阅
+			return err
+		}
+	}
+}
+
+// applyPushed 应用推送流收到的一批条目，并跳过主节点过滤掉的条目（scanned为检查到的最后一个条目ID）
+func (s *Slave) applyPushed(entries []BinlogEntry, scanned uint64) error {
+	s.syncMutex.Lock()
+	defer s.syncMutex.Unlock()
+	if s.expectsContiguous(true) {
+		if err := checkContiguous(s.currentPosition, entries); err != nil {
+			return err
+		}
+	}
+	if len(entries) > 0 {
+		if err := s.applyBatch(entries); err != nil {
+			return err
+		}
+	}
+	return s.advancePast(scanned)
+}
+
+// setStream 记录当前的推送流，同步已停止时返回false
+func (s *Slave) setStream(conn io.Closer) bool {
+	s.syncMutex.Lock()
+	defer s.syncMutex.Unlock()
+	if conn != nil && !s.isRunning {
+		return false
+	}
+	s.stream = conn
+	return true
+}
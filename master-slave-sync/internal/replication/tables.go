@@ -0,0 +1,351 @@
+package replication
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"sync"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+
+	"master-slave-sync/internal/storage"
+)
+
+// RecordsTable 内置的示例表，对应 storage.Record
+const RecordsTable = "records"
+
+// ErrUnknownTable 条目所属的表没有注册
+var ErrUnknownTable = errors.New("table is not registered for replication")
+
+// Table 可复制的表：主节点用它序列化写入的行，从节点按binlog条目的表名找到它并应用变更
+// 主从两端必须注册相同的表，且应在创建 Master、Slave 之前注册
+type Table interface {
+	// Name 表名，写入binlog条目的 TableName
+	Name() string
+	// Migrate 在本节点的数据库上创建或更新表结构，创建 Master、Slave 时调用
+	Migrate(db *gorm.DB) error
+	// Encode 序列化主节点写入的行，返回行ID和写入binlog的数据
+	Encode(row interface{}) (id uint, data []byte, err error)
+	// Apply 在从库上应用一个条目
+	Apply(db *gorm.DB, entry BinlogEntry) error
+}
+
+// SnapshotTable 支持全量快照的表：主节点按主键顺序分批读出所有行，从节点清空本地的表后按INSERT条目逐行加载
+// 内置的 records 表和 ModelTable 都实现了它；没有实现的表无法全量重新同步，见 Slave.Resync
+type SnapshotTable interface {
+	Table
+	// Rows 按主键顺序读取主键大于after的最多limit行，返回的行可以由 Encode 序列化
+	Rows(db *gorm.DB, after uint, limit int) ([]interface{}, error)
+	// Truncate 删除本节点表中的所有行（在事务中执行，不使用会隐式提交的 TRUNCATE）
+	Truncate(db *gorm.DB) error
+}
+
+// ColumnTable 可以由列值构造行的表：主节点跟随MySQL自身的binlog时（binlog_source: mysql）用它把行事件转换为条目
+// 内置的 records 表和 ModelTable 都实现了它
+type ColumnTable interface {
+	Table
+	// FromColumns 由列名到列值的映射构造一行，返回的行可以由 Encode 序列化
+	FromColumns(values map[string]interface{}) (interface{}, error)
+}
+
+// 已注册的表
+var (
+	tablesMu sync.RWMutex
+	tables   = map[string]Table{RecordsTable: recordsTable{}}
+)
+
+// RegisterTable 注册一个可复制的表，表名重复时返回错误
+func RegisterTable(t Table) error {
+	tablesMu.Lock()
+	defer tablesMu.Unlock()
+
+	if _, exists := tables[t.Name()]; exists {
+		return fmt.Errorf("table %s is already registered", t.Name())
+	}
+	tables[t.Name()] = t
+	return nil
+}
+
+// LookupTable 按表名查找已注册的表
+func LookupTable(name string) (Table, error) {
+	tablesMu.RLock()
+	defer tablesMu.RUnlock()
+
+	t, ok := tables[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownTable, name)
+	}
+	return t, nil
+}
+
+// RegisteredTables 已注册的表名（按名称排序）
+func RegisteredTables() []string {
+	tablesMu.RLock()
+	defer tablesMu.RUnlock()
+
+	names := make([]string, 0, len(tables))
+	for name := range tables {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// migrateTables 在本节点的数据库上迁移所有已注册的表
+func migrateTables(db *storage.DB) error {
+	tablesMu.RLock()
+	defer tablesMu.RUnlock()
+
+	for name, t := range tables {
+		if err := t.Migrate(db.GetConnection()); err != nil {
+			return fmt.Errorf("failed to migrate table %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// WriteRow 在主节点上写入一个已注册的表并复制到从节点：write在事务中执行写入并返回写入的行
+// （删除时返回只包含主键的行即可），行由表序列化后与复制日志一起提交，再按写入选项等待从节点确认
+func (m *Master) WriteRow(table, operation string, write func(tx *storage.DB) (interface{}, error), opts WriteOptions) (interface{}, SemiSyncStatus, error) {
+	switch operation {
+	case OpInsert, OpUpdate, OpDelete:
+	default:
+		return nil, "", fmt.Errorf("unknown operation: %s", operation)
+	}
+
+	row, pos, waited, err := m.commitRow(table, operation, opts.Durability, write)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to write %s: %w", table, err)
+	}
+
+	status, err := m.finishWrite(pos, opts.Durability, waited)
+	return row, status, err
+}
+
+// ModelTable 基于GORM模型的通用表：插入时创建行，更新时按主键写入前后映像中不同的列（没有前映像时写入整行），删除时按主键删除
+type ModelTable[T any] struct {
+	name string        // 表名
+	id   func(*T) uint // 获取行的主键
+}
+
+// NewModelTable 为GORM模型T创建可复制的表，id返回行的主键
+func NewModelTable[T any](name string, id func(*T) uint) *ModelTable[T] {
+	return &ModelTable[T]{name: name, id: id}
+}
+
+// Name 表名
+func (t *ModelTable[T]) Name() string {
+	return t.name
+}
+
+// Migrate 自动迁移模型
+func (t *ModelTable[T]) Migrate(db *gorm.DB) error {
+	return db.AutoMigrate(new(T))
+}
+
+// Encode 将 *T 序列化为JSON
+func (t *ModelTable[T]) Encode(row interface{}) (uint, []byte, error) {
+	r, ok := row.(*T)
+	if !ok {
+		return 0, nil, fmt.Errorf("table %s: unexpected row type %T", t.name, row)
+	}
+	data, err := json.Marshal(r)
+	if err != nil {
+		return 0, nil, fmt.Errorf("failed to serialize %s row: %w", t.name, err)
+	}
+	return t.id(r), data, nil
+}
+
+// Apply 应用一个条目
+func (t *ModelTable[T]) Apply(db *gorm.DB, entry BinlogEntry) error {
+	switch entry.Operation {
+	case OpInsert:
+		row := new(T)
+		if err := json.Unmarshal(entry.Data, row); err != nil {
+			return fmt.Errorf("failed to deserialize %s row: %w", t.name, err)
+		}
+		// 主键已存在（重复应用）则覆盖整行
+		if err := db.Clauses(clause.OnConflict{UpdateAll: true}).Create(row).Error; err != nil {
+			return fmt.Errorf("failed to apply INSERT to %s: %w", t.name, err)
+		}
+
+	case OpUpdate:
+		return t.ApplyUpdate(db, entry, false)
+
+	case OpDelete:
+		if err := db.Delete(new(T), entry.RecordID).Error; err != nil {
+			return fmt.Errorf("failed to apply DELETE to %s: %w", t.name, err)
+		}
+
+	default:
+		return fmt.Errorf("unknown operation: %s", entry.Operation)
+	}
+	return nil
+}
+
+// ApplyUpdate 按前后映像应用UPDATE条目
+func (t *ModelTable[T]) ApplyUpdate(db *gorm.DB, entry BinlogEntry, checkBefore bool) error {
+	after, before, err := decodeRowImages(t.name, entry, func() interface{} { return new(T) })
+	if err != nil {
+		return err
+	}
+	return applyRowUpdate(db, t.name, entry, after, before, checkBefore)
+}
+
+// FromColumns 按gorm的列名设置 *T 的字段
+func (t *ModelTable[T]) FromColumns(values map[string]interface{}) (interface{}, error) {
+	row := new(T)
+	if err := rowFromColumns(row, values); err != nil {
+		return nil, fmt.Errorf("failed to build %s row: %w", t.name, err)
+	}
+	return row, nil
+}
+
+// Rows 按主键顺序读取一批行
+func (t *ModelTable[T]) Rows(db *gorm.DB, after uint, limit int) ([]interface{}, error) {
+	var rows []T
+	err := db.Where(clause.Gt{Column: clause.PrimaryColumn, Value: after}).
+		Order(clause.OrderByColumn{Column: clause.PrimaryColumn}).
+		Limit(limit).
+		Find(&rows).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s rows: %w", t.name, err)
+	}
+	result := make([]interface{}, 0, len(rows))
+	for i := range rows {
+		result = append(result, &rows[i])
+	}
+	return result, nil
+}
+
+// Truncate 删除所有行
+func (t *ModelTable[T]) Truncate(db *gorm.DB) error {
+	if err := db.Session(&gorm.Session{AllowGlobalUpdate: true}).Delete(new(T)).Error; err != nil {
+		return fmt.Errorf("failed to truncate %s: %w", t.name, err)
+	}
+	return nil
+}
+
+// recordsTable 内置的 records 表
+type recordsTable struct{}
+
+// Name 表名
+func (recordsTable) Name() string {
+	return RecordsTable
+}
+
+// Migrate records 表由 storage.NewDB 迁移
+func (recordsTable) Migrate(db *gorm.DB) error {
+	return nil
+}
+
+// Encode 将 *storage.Record 序列化为JSON
+func (recordsTable) Encode(row interface{}) (uint, []byte, error) {
+	record, ok := row.(*storage.Record)
+	if !ok {
+		return 0, nil, fmt.Errorf("table %s: unexpected row type %T", RecordsTable, row)
+	}
+	data, err := json.Marshal(record)
+	if err != nil {
+		return 0, nil, fmt.Errorf("failed to serialize record: %w", err)
+	}
+	return record.ID, data, nil
+}
+
+// Apply 应用一个条目
+func (recordsTable) Apply(db *gorm.DB, entry BinlogEntry) error {
+	switch entry.Operation {
+	case OpInsert:
+		var record storage.Record
+		if err := json.Unmarshal(entry.Data, &record); err != nil {
+			return fmt.Errorf("failed to deserialize record: %w", err)
+		}
+		// 我们需要绕过普通的创建方法，因为它有主节点检查
+		// 主键已存在（重复应用）时覆盖为条目中的行，再次应用同一条目结果不变
+		result := db.Clauses(clause.OnConflict{UpdateAll: true}).Create(&record)
+		if result.Error != nil {
+			return fmt.Errorf("failed to apply INSERT: %w", result.Error)
+		}
+
+	case OpUpdate:
+		return recordsTable{}.ApplyUpdate(db, entry, false)
+
+	case OpDelete:
+		// 直接删除指定ID的记录
+		result := db.Delete(&storage.Record{}, entry.RecordID)
+		if result.Error != nil {
+			return fmt.Errorf("failed to apply DELETE: %w", result.Error)
+		}
+
+	default:
+		return fmt.Errorf("unknown operation: %s", entry.Operation)
+	}
+
+	return nil
+}
+
+// ApplyUpdate 按前后映像更新记录的所有变化的列
+func (recordsTable) ApplyUpdate(db *gorm.DB, entry BinlogEntry, checkBefore bool) error {
+	after, before, err := decodeRowImages(RecordsTable, entry, func() interface{} { return &storage.Record{} })
+	if err != nil {
+		return err
+	}
+	return applyRowUpdate(db, RecordsTable, entry, after, before, checkBefore)
+}
+
+// FromColumns 由列值构造 *storage.Record
+func (recordsTable) FromColumns(values map[string]interface{}) (interface{}, error) {
+	record := &storage.Record{}
+	if err := rowFromColumns(record, values); err != nil {
+		return nil, fmt.Errorf("failed to build record: %w", err)
+	}
+	return record, nil
+}
+
+// Rows 按ID顺序读取一批记录
+func (recordsTable) Rows(db *gorm.DB, after uint, limit int) ([]interface{}, error) {
+	var records []storage.Record
+	if err := db.Where("id > ?", after).Order("id").Limit(limit).Find(&records).Error; err != nil {
+		return nil, fmt.Errorf("failed to read records: %w", err)
+	}
+	rows := make([]interface{}, 0, len(records))
+	for i := range records {
+		rows = append(rows, &records[i])
+	}
+	return rows, nil
+}
+
+// Truncate 删除所有记录
+func (recordsTable) Truncate(db *gorm.DB) error {
+	if err := db.Session(&gorm.Session{AllowGlobalUpdate: true}).Delete(&storage.Record{}).Error; err != nil {
+		return fmt.Errorf("failed to truncate records: %w", err)
+	}
+	return nil
+}
+
+// modelSchemas 由列值构造行时解析的模型结构
+var modelSchemas sync.Map
+
+// rowFromColumns 把列值设置到dst（指向模型的指针）中按gorm默认命名规则对应的字段，没有对应字段的列被忽略
+func rowFromColumns(dst interface{}, values map[string]interface{}) error {
+	s, err := schema.Parse(dst, &modelSchemas, schema.NamingStrategy{})
+	if err != nil {
+		return err
+	}
+	rv := reflect.ValueOf(dst).Elem()
+	for name, value := range values {
+		field := s.LookUpField(name)
+		if field == nil || field.DBName == "" {
+			continue
+		}
+		if err := field.Set(context.Background(), rv, value); err != nil {
+			return fmt.Errorf("column %s: %w", name, err)
+		}
+	}
+	return nil
+}
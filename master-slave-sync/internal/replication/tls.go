@@ -0,0 +1,81 @@
+package replication
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+)
+
+// ServerTLS 检查HTTP服务器的证书配置，返回是否启用TLS：证书和私钥都为空表示不启用，只配置了其中一个时返回错误
+func ServerTLS(certFile, keyFile string) (bool, error) {
+	switch {
+	case certFile == "" && keyFile == "":
+		return false, nil
+	case certFile == "" || keyFile == "":
+		return false, fmt.Errorf("tls_cert_file and tls_key_file must be set together")
+	}
+	if _, err := tls.LoadX509KeyPair(certFile, keyFile); err != nil {
+		return false, fmt.Errorf("failed to load TLS certificate: %w", err)
+	}
+	return true, nil
+}
+
+// newClientTLSConfig 访问其他节点时校验服务端证书的TLS配置，caFile为空时使用系统的根证书
+func newClientTLSConfig(caFile string) (*tls.Config, error) {
+	cfg := &tls.Config{MinVersion: tls.VersionTLS12}
+	if caFile == "" {
+		return cfg, nil
+	}
+	pem, err := os.ReadFile(caFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CA file: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("no certificates found in CA file %s", caFile)
+	}
+	cfg.RootCAs = pool
+	return cfg, nil
+}
+
+// nodeURL 复制拓扑中一个节点的API地址，访问主节点开启TLS时其他节点同样通过https访问
+func (s *Slave) nodeURL(host string, port int) string {
+	return nodeURL(s.tlsConfig, host, port)
+}
+
+// peerClient 访问其他从节点API的HTTP客户端，开启TLS时与访问主节点使用相同的证书校验
+func (s *Slave) peerClient(timeout time.Duration) *http.Client {
+	return nodeClient(s.tlsConfig, timeout)
+}
+
+// slaveURL 从节点API的地址，开启 SlaveTLS 时通过https访问
+func (m *Master) slaveURL(info SlaveInfo, path string, query url.Values) string {
+	return fmt.Sprintf("%s%s?%s", nodeURL(m.slaveTLS, info.Host, info.Port), path, query.Encode())
+}
+
+// slaveClient 访问从节点API的HTTP客户端，开启 SlaveTLS 时按配置的CA校验从节点的证书
+func (m *Master) slaveClient(timeout time.Duration) *http.Client {
+	return nodeClient(m.slaveTLS, timeout)
+}
+
+// nodeURL tlsConfig不为nil时使用https
+func nodeURL(tlsConfig *tls.Config, host string, port int) string {
+	scheme := "http"
+	if tlsConfig != nil {
+		scheme = "https"
+	}
+	return fmt.Sprintf("%s://%s:%d", scheme, host, port)
+}
+
+// nodeClient tlsConfig不为nil时通过TLS连接并校验服务端证书
+func nodeClient(tlsConfig *tls.Config, timeout time.Duration) *http.Client {
+	client := &http.Client{Timeout: timeout}
+	if tlsConfig != nil {
+		client.Transport = &http.Transport{TLSClientConfig: tlsConfig, DisableKeepAlives: true}
+	}
+	return client
+}
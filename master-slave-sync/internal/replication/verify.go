@@ -0,0 +1,247 @@
+package replication
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"master-slave-sync/internal/schedule"
+)
+
+// 一致性校验相关参数
+const (
+	verifyHistorySize   = 100              // 保留的校验历史条数
+	verifyCatchUpWait   = 10 * time.Second // 等待从节点追上主节点校验位置的最长时间
+	verifyCatchUpPoll   = 200 * time.Millisecond
+	verifyAlertTimeout  = 5 * time.Second
+	verifyStatusOK      = "consistent" // 数据一致
+	verifyStatusDiverge = "diverged"   // 数据不一致
+	verifyStatusSkipped = "skipped"    // 位置无法对齐，本次跳过
+	verifyStatusError   = "error"      // 校验过程出错
+)
+
+// VerifyResult 一次一致性校验的结果
+type VerifyResult struct {
+	Time           time.Time `json:"time"`
+	Status         string    `json:"status"`
+	Position       uint64    `json:"position"`
+	MasterCount    int64     `json:"master_count"`
+	SlaveCount     int64     `json:"slave_count"`
+	MasterChecksum string    `json:"master_checksum"`
+	SlaveChecksum  string    `json:"slave_checksum"`
+	Message        string    `json:"message,omitempty"`
+}
+
+// AlertHook 数据不一致时的通知钩子
+type AlertHook interface {
+	Alert(slaveID string, result VerifyResult) error
+}
+
+// LogAlertHook 将告警输出到日志
+type LogAlertHook struct{}
+
+// Alert 输出告警日志
+func (LogAlertHook) Alert(slaveID string, result VerifyResult) error {
+	log.Printf("ALERT: slave %s diverged from master at position %d (master %s/%d rows, slave %s/%d rows)",
+		slaveID, result.Position, result.MasterChecksum, result.MasterCount, result.SlaveChecksum, result.SlaveCount)
+	return nil
+}
+
+// WebhookAlertHook 将告警以JSON形式POST到指定地址
+type WebhookAlertHook struct {
+	URL    string
+	Client *http.Client
+}
+
+// Alert 发送告警
+func (h WebhookAlertHook) Alert(slaveID string, result VerifyResult) error {
+	body, err := json.Marshal(map[string]interface{}{
+		"slave_id": slaveID,
+		"result":   result,
+	})
+	if err != nil {
+		return err
+	}
+
+	client := h.Client
+	if client == nil {
+		client = &http.Client{Timeout: verifyAlertTimeout}
+	}
+	resp, err := client.Post(h.URL, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// verifier 从节点的定期一致性校验器
+type verifier struct {
+	hooks   []AlertHook
+	history []VerifyResult
+	stop    chan struct{}
+	mu      sync.Mutex
+}
+
+// AddAlertHook 添加数据不一致告警钩子
+func (s *Slave) AddAlertHook(hook AlertHook) {
+	s.verifier.mu.Lock()
+	defer s.verifier.mu.Unlock()
+	s.verifier.hooks = append(s.verifier.hooks, hook)
+}
+
+// StartVerifier 按时间表定期与主节点校验数据
+func (s *Slave) StartVerifier(spec string) error {
+	sched, err := schedule.Parse(spec)
+	if err != nil {
+		return err
+	}
+
+	s.verifier.mu.Lock()
+	if s.verifier.stop != nil {
+		s.verifier.mu.Unlock()
+		return fmt.Errorf("verifier already running")
+	}
+	stop := make(chan struct{})
+	s.verifier.stop = stop
+	s.verifier.mu.Unlock()
+
+	go func() {
+		for {
+			next := sched.Next(time.Now())
+			if next.IsZero() {
+				log.Printf("Verify schedule %q has no upcoming run, verifier stopped", spec)
+				return
+			}
+
+			select {
+			case <-stop:
+				return
+			case <-time.After(time.Until(next)):
+				s.VerifyNow()
+			}
+		}
+	}()
+
+	log.Printf("Consistency verifier started with schedule %q", spec)
+	return nil
+}
+
+// StopVerifier 停止定期校验
+func (s *Slave) StopVerifier() {
+	s.verifier.mu.Lock()
+	defer s.verifier.mu.Unlock()
+	if s.verifier.stop != nil {
+		close(s.verifier.stop)
+		s.verifier.stop = nil
+	}
+}
+
+// VerifyHistory 获取校验历史（按时间先后）
+func (s *Slave) VerifyHistory() []VerifyResult {
+	s.verifier.mu.Lock()
+	defer s.verifier.mu.Unlock()
+	return append([]VerifyResult(nil), s.verifier.history...)
+}
+
+// VerifyNow 立即执行一次校验，记录结果并在不一致时告警
+func (s *Slave) VerifyNow() VerifyResult {
+	result := s.verify()
+
+	s.verifier.mu.Lock()
+	s.verifier.history = append(s.verifier.history, result)
+	if len(s.verifier.history) > verifyHistorySize {
+		s.verifier.history = s.verifier.history[len(s.verifier.history)-verifyHistorySize:]
+	}
+	hooks := s.verifier.hooks
+	s.verifier.mu.Unlock()
+
+	log.Printf("Consistency check at position %d: %s %s", result.Position, result.Status, result.Message)
+	if result.Status == verifyStatusDiverge {
+		for _, hook := range hooks {
+			if err := hook.Alert(s.slaveID, result); err != nil {
+				log.Printf("Failed to deliver divergence alert: %v", err)
+			}
+		}
+	}
+	return result
+}
+
+// verify 与主节点在同一binlog位置上比较校验和
+func (s *Slave) verify() VerifyResult {
+	result := VerifyResult{Time: time.Now()}
+
+	// 主节点计算期间可能有写入，最多尝试两次以对齐位置
+	for attempt := 0; attempt < 2; attempt++ {
+		master, err := s.fetchMasterChecksum()
+		if err != nil {
+			result.Status = verifyStatusError
+			result.Message = err.Error()
+			return result
+		}
+		result.Position = master.Position
+		result.MasterCount = master.Count
+		result.MasterChecksum = master.Checksum
+
+		// 等待从节点追上主节点的校验位置
+		deadline := time.Now().Add(verifyCatchUpWait)
+		for s.GetCurrentPosition() < master.Position && time.Now().Before(deadline) {
+			time.Sleep(verifyCatchUpPoll)
+		}
+
+		// 持有同步锁计算本地校验和，保证计算期间不会回放新的条目
+		s.syncMutex.Lock()
+		position := s.currentPosition
+		local, err := s.db.Checksum()
+		s.syncMutex.Unlock()
+		if err != nil {
+			result.Status = verifyStatusError
+			result.Message = err.Error()
+			return result
+		}
+
+		if position != master.Position {
+			result.Status = verifyStatusSkipped
+			result.Message = fmt.Sprintf("slave at position %d, master checksum taken at %d", position, master.Position)
+			continue
+		}
+
+		result.SlaveCount = local.Count
+		result.SlaveChecksum = local.Checksum
+		if local.Checksum == master.Checksum && local.Count == master.Count {
+			result.Status = verifyStatusOK
+			result.Message = ""
+			return result
+		}
+		result.Status = verifyStatusDiverge
+		result.Message = "checksum mismatch"
+	}
+
+	return result
+}
+
+// fetchMasterChecksum 获取主节点的数据校验和
+func (s *Slave) fetchMasterChecksum() (*ChecksumResult, error) {
+	resp, err := s.doRequest(http.MethodGet, s.masterURL+"/api/checksum", nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch master checksum: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("master checksum request failed with status %d", resp.StatusCode)
+	}
+
+	var result ChecksumResult
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("failed to decode master checksum: %w", err)
+	}
+	return &result, nil
+}
@@ -0,0 +1,103 @@
+package replication
+
+import (
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+// 从节点拒绝写请求的响应方式
+const (
+	WriteRejectModeReject   = "reject"   // 返回405（默认）
+	WriteRejectModeRedirect = "redirect" // 返回307并附带主节点地址，客户端可直接重试
+)
+
+// writeAuditSize 保留的被拒绝写请求条数
+const writeAuditSize = 100
+
+// WriteAttempt 一次被从节点拒绝的写请求
+type WriteAttempt struct {
+	Time   time.Time `json:"time"`
+	Method string    `json:"method"`
+	Path   string    `json:"path"`
+	Client string    `json:"client"` // 客户端地址
+}
+
+// writeAudit 被拒绝写请求的审计记录
+type writeAudit struct {
+	total    int64          // 累计拒绝次数
+	attempts []WriteAttempt // 最近的拒绝记录
+	mu       sync.Mutex
+}
+
+// RecordRejectedWrite 记录一次被拒绝的写请求并输出审计日志
+func (s *Slave) RecordRejectedWrite(method string, path string, client string) {
+	attempt := WriteAttempt{Time: time.Now(), Method: method, Path: path, Client: client}
+	log.Printf("Rejected write on slave %s: %s %s from %s", s.slaveID, method, path, client)
+
+	s.writes.mu.Lock()
+	defer s.writes.mu.Unlock()
+	s.writes.total++
+	s.writes.attempts = append(s.writes.attempts, attempt)
+	if len(s.writes.attempts) > writeAuditSize {
+		s.writes.attempts = s.writes.attempts[len(s.writes.attempts)-writeAuditSize:]
+	}
+}
+
+// RejectedWrites 获取最近被拒绝的写请求（按时间先后）
+func (s *Slave) RejectedWrites() []WriteAttempt {
+	s.writes.mu.Lock()
+	defer s.writes.mu.Unlock()
+	return append([]WriteAttempt(nil), s.writes.attempts...)
+}
+
+// rejectedWriteCount 获取累计拒绝的写请求数
+func (s *Slave) rejectedWriteCount() int64 {
+	s.writes.mu.Lock()
+	defer s.writes.mu.Unlock()
+	return s.writes.total
+}
+
+// WriteRejectMode 获取拒绝写请求的响应方式，未配置时为 WriteRejectModeReject
+func (s *Slave) WriteRejectMode() string {
+	if s.config.WriteRejectMode == WriteRejectModeRedirect {
+		return WriteRejectModeRedirect
+	}
+	return WriteRejectModeReject
+}
+
+// ReadOnly 本节点的数据库连接是否只读（提升为主节点后不再只读）
+func (s *Slave) ReadOnly() bool {
+	return s.db.ReadOnly()
+}
+
+// Promote 手动把本节点提升为主节点（由运维或切换工具在确认原主节点已下线后调用），不经过选举
+// 提升期间临时解除只读（见 storage.DB.OverrideReadOnly），完成后作为主节点接受写入
+func (s *Slave) Promote() error {
+	s.electionMu.Lock()
+	promoted := s.promoted != nil
+	s.electionMu.Unlock()
+	if promoted {
+		return fmt.Errorf("%w: this node is already the master", ErrElectionAborted)
+	}
+
+	s.syncMutex.Lock()
+	switch {
+	case s.resyncing:
+		s.syncMutex.Unlock()
+		return ErrResyncInFlight
+	case s.replaying:
+		s.syncMutex.Unlock()
+		return ErrReplayInFlight
+	}
+	s.syncMutex.Unlock()
+
+	log.Printf("Slave %s is being promoted to master manually", s.slaveID)
+	return s.promote()
+}
+
+// MasterURL 获取主节点的API地址
+func (s *Slave) MasterURL() string {
+	return s.masterURL
+}
@@ -0,0 +1,125 @@
+package schedule
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schedule 计划任务的触发时间表
+type Schedule interface {
+	// Next 返回给定时间之后的下一次触发时间
+	Next(t time.Time) time.Time
+}
+
+// every 固定间隔的时间表
+type every struct {
+	interval time.Duration
+}
+
+// Next 返回下一次触发时间
+func (e every) Next(t time.Time) time.Time {
+	return t.Add(e.interval)
+}
+
+// cron 五段式cron时间表（分 时 日 月 周）
+type cron struct {
+	minute, hour, dom, month, dow map[int]bool
+}
+
+// Parse 解析时间表描述，支持：
+//   - "@every 30s" 这样的固定间隔
+//   - "@hourly"、"@daily" 预定义描述
+//   - "*/5 * * * *" 五段式cron表达式，每段支持 *、*/n、a-b、a-b/n 以及逗号分隔的列表
+func Parse(spec string) (Schedule, error) {
+	spec = strings.TrimSpace(spec)
+	switch {
+	case strings.HasPrefix(spec, "@every "):
+		d, err := time.ParseDuration(strings.TrimSpace(strings.TrimPrefix(spec, "@every ")))
+		if err != nil {
+			return nil, fmt.Errorf("invalid @every interval: %w", err)
+		}
+		if d <= 0 {
+			return nil, fmt.Errorf("@every interval must be positive")
+		}
+		return every{interval: d}, nil
+	case spec == "@hourly":
+		spec = "0 * * * *"
+	case spec == "@daily":
+		spec = "0 0 * * *"
+	}
+
+	fields := strings.Fields(spec)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("expected 5 cron fields, got %d: %q", len(fields), spec)
+	}
+
+	bounds := [5][2]int{{0, 59}, {0, 23}, {1, 31}, {1, 12}, {0, 6}}
+	sets := make([]map[int]bool, 5)
+	for i, field := range fields {
+		set, err := parseField(field, bounds[i][0], bounds[i][1])
+		if err != nil {
+			return nil, fmt.Errorf("invalid cron field %q: %w", field, err)
+		}
+		sets[i] = set
+	}
+
+	return &cron{minute: sets[0], hour: sets[1], dom: sets[2], month: sets[3], dow: sets[4]}, nil
+}
+
+// parseField 解析cron表达式中的一段
+func parseField(field string, min, max int) (map[int]bool, error) {
+	set := make(map[int]bool)
+	for _, part := range strings.Split(field, ",") {
+		step := 1
+		if idx := strings.Index(part, "/"); idx >= 0 {
+			n, err := strconv.Atoi(part[idx+1:])
+			if err != nil || n <= 0 {
+				return nil, fmt.Errorf("invalid step %q", part[idx+1:])
+			}
+			step = n
+			part = part[:idx]
+		}
+
+		lo, hi := min, max
+		if part != "*" {
+			bounds := strings.SplitN(part, "-", 2)
+			var err error
+			if lo, err = strconv.Atoi(bounds[0]); err != nil {
+				return nil, fmt.Errorf("invalid value %q", bounds[0])
+			}
+			hi = lo
+			if len(bounds) == 2 {
+				if hi, err = strconv.Atoi(bounds[1]); err != nil {
+					return nil, fmt.Errorf("invalid value %q", bounds[1])
+				}
+			} else if step > 1 {
+				hi = max
+			}
+		}
+		if lo < min || hi > max || lo > hi {
+			return nil, fmt.Errorf("value out of range [%d,%d]", min, max)
+		}
+
+		for v := lo; v <= hi; v += step {
+			set[v] = true
+		}
+	}
+	return set, nil
+}
+
+// Next 返回下一次触发时间（精确到分钟）
+func (c *cron) Next(t time.Time) time.Time {
+	next := t.Truncate(time.Minute).Add(time.Minute)
+	// 最多向后查找5年，避免不可能满足的表达式（如2月31日）导致死循环
+	limit := next.AddDate(5, 0, 0)
+	for next.Before(limit) {
+		if c.month[int(next.Month())] && c.dom[next.Day()] && c.dow[int(next.Weekday())] &&
+			c.hour[next.Hour()] && c.minute[next.Minute()] {
+			return next
+		}
+		next = next.Add(time.Minute)
+	}
+	return time.Time{}
+}
@@ -0,0 +1,49 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// PublisherCheckpoint 主节点把binlog发布到外部系统（如Kafka）的进度，发布成功后更新
+// 主节点重启后从该位置之后继续发布，不会从头重新发布整个binlog
+type PublisherCheckpoint struct {
+	Name      string    `gorm:"primarykey;size:128"` // 发布目标，如 kafka:<topic>
+	Position  uint64    // 已发布并被确认的最后一个binlog条目ID
+	UpdatedAt time.Time `gorm:"autoUpdateTime"`
+}
+
+// TableName 发布进度表名
+func (PublisherCheckpoint) TableName() string {
+	return "publisher_checkpoints"
+}
+
+// LoadCheckpoint 读取发布进度，没有记录时返回0
+func (db *DB) LoadCheckpoint(name string) (uint64, error) {
+	var checkpoint PublisherCheckpoint
+	err := db.conn.Where("name = ?", name).First(&checkpoint).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to load publisher checkpoint: %w", err)
+	}
+	return checkpoint.Position, nil
+}
+
+// SaveCheckpoint 保存发布进度
+func (db *DB) SaveCheckpoint(name string, position uint64) error {
+	checkpoint := &PublisherCheckpoint{Name: name, Position: position}
+	err := db.conn.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "name"}},
+		DoUpdates: clause.AssignmentColumns([]string{"position", "updated_at"}),
+	}).Create(checkpoint).Error
+	if err != nil {
+		return fmt.Errorf("failed to save publisher checkpoint: %w", err)
+	}
+	return nil
+}
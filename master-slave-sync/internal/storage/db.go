@@ -2,6 +2,9 @@ package storage
 
 import (
 	"fmt"
+	"hash"
+	"hash/crc32"
+	"io"
 	"log"
 	"time"
 
@@ -12,16 +15,18 @@ import (
 
 // DB 数据库操作封装
 type DB struct {
-	conn *gorm.DB
-	role string // "master" 或 "slave"
+	conn  *gorm.DB
+	role  string      // "master" 或 "slave"，提升为主节点时修改，读写都持有guard.mu
+	guard *writeGuard // 从库连接的只读状态
 }
 
 // Record 示例数据模型，用于演示主从同步
 type Record struct {
-	ID        uint      `gorm:"primarykey"`
-	Content   string    `gorm:"size:255"`
-	CreatedAt time.Time `gorm:"autoCreateTime"`
-	UpdatedAt time.Time `gorm:"autoUpdateTime"`
+	ID        uint       `gorm:"primarykey"`
+	Content   string     `gorm:"size:255"`
+	CreatedAt time.Time  `gorm:"autoCreateTime"`
+	UpdatedAt time.Time  `gorm:"autoUpdateTime"`
+	ExpiresAt *time.Time `gorm:"index"` // 过期时间，为空表示永不过期
 }
 
 // NewDB 创建数据库连接
@@ -45,27 +50,67 @@ func NewDB(dsn string, role string) (*DB, error) {
 		return nil, fmt.Errorf("failed to connect database: %w", err)
 	}
 
-	// 自动迁移模式
-	err = db.AutoMigrate(&Record{})
+	// 自动迁移模式，复制日志、发布进度、binlog跟随位置和从节点登记表只在主节点使用，复制状态只在从节点使用
+	models := []interface{}{&Record{}}
+	if role == "master" {
+		models = append(models, &JournalEntry{}, &PublisherCheckpoint{}, &BinlogTailState{}, &RegisteredSlave{})
+	} else {
+		models = append(models, &ReplicationState{})
+	}
+	err = db.AutoMigrate(models...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to migrate database: %w", err)
 	}
 
+	// 主节点记录写语句，供基于语句的复制使用
+	if role == "master" {
+		if err := registerStatementLog(db); err != nil {
+			return nil, fmt.Errorf("failed to register statement log: %w", err)
+		}
+	}
+
 	return &DB{
-		conn: db,
-		role: role,
+		conn:  db,
+		role:  role,
+		guard: &writeGuard{},
 	}, nil
 }
 
+// PromoteToMaster 把从库连接提升为主库连接（故障切换时），之后不再只读，允许写入并记录写语句
+func (db *DB) PromoteToMaster() error {
+	if db.currentRole() == "master" {
+		return nil
+	}
+	if err := db.conn.AutoMigrate(&JournalEntry{}, &PublisherCheckpoint{}, &BinlogTailState{}, &RegisteredSlave{}); err != nil {
+		return fmt.Errorf("failed to migrate database: %w", err)
+	}
+	if err := registerStatementLog(db.conn); err != nil {
+		return fmt.Errorf("failed to register statement log: %w", err)
+	}
+	db.guard.mu.Lock()
+	db.role = "master"
+	db.guard.mu.Unlock()
+	return nil
+}
+
 // CreateRecord 创建新记录（仅主节点支持）
 func (db *DB) CreateRecord(content string) (*Record, error) {
-	if db.role != "master" {
-		return nil, fmt.Errorf("write operations not allowed on slave node")
+	return db.CreateRecordWithTTL(content, 0)
+}
+
+// CreateRecordWithTTL 创建带有效期的记录（仅主节点支持），ttl为0表示永不过期
+func (db *DB) CreateRecordWithTTL(content string, ttl time.Duration) (*Record, error) {
+	if err := db.checkWritable(); err != nil {
+		return nil, err
 	}
 
 	record := &Record{
 		Content: content,
 	}
+	if ttl > 0 {
+		expiresAt := time.Now().Add(ttl)
+		record.ExpiresAt = &expiresAt
+	}
 
 	result := db.conn.Create(record)
 	if result.Error != nil {
@@ -95,10 +140,23 @@ func (db *DB) ListRecords() ([]Record, error) {
 	return records, nil
 }
 
+// ListExpiredRecords 获取在指定时间之前已过期的记录，最多返回limit条
+func (db *DB) ListExpiredRecords(now time.Time, limit int) ([]Record, error) {
+	var records []Record
+	result := db.conn.Where("expires_at IS NOT NULL AND expires_at <= ?", now).
+		Order("expires_at").
+		Limit(limit).
+		Find(&records)
+	if result.Error != nil {
+		return nil, fmt.Errorf("failed to list expired records: %w", result.Error)
+	}
+	return records, nil
+}
+
 // UpdateRecord 更新记录（仅主节点支持）
 func (db *DB) UpdateRecord(id uint, content string) error {
-	if db.role != "master" {
-		return fmt.Errorf("write operations not allowed on slave node")
+	if err := db.checkWritable(); err != nil {
+		return err
 	}
 
 	result := db.conn.Model(&Record{}).Where("id = ?", id).Update("content", content)
@@ -113,8 +171,8 @@ func (db *DB) UpdateRecord(id uint, content string) error {
 
 // DeleteRecord 删除记录（仅主节点支持）
 func (db *DB) DeleteRecord(id uint) error {
-	if db.role != "master" {
-		return fmt.Errorf("write operations not allowed on slave node")
+	if err := db.checkWritable(); err != nil {
+		return err
 	}
 
 	result := db.conn.Delete(&Record{}, id)
@@ -127,6 +185,97 @@ func (db *DB) DeleteRecord(id uint) error {
 	return nil
 }
 
+// TableChecksum 记录表的校验和
+type TableChecksum struct {
+	Count    int64  `json:"count"`    // 记录数
+	Checksum string `json:"checksum"` // 按ID顺序计算的CRC32校验和
+}
+
+// Checksum 计算记录表的校验和
+// 只包含会被复制的字段（ID、内容、过期时间），时间戳在从节点回放时可能重新生成，不参与计算
+func (db *DB) Checksum() (TableChecksum, error) {
+	var records []Record
+	if err := db.conn.Order("id").Find(&records).Error; err != nil {
+		return TableChecksum{}, fmt.Errorf("failed to read records for checksum: %w", err)
+	}
+
+	hash := crc32.NewIEEE()
+	for _, r := range records {
+		writeChecksumRow(hash, r)
+	}
+
+	return TableChecksum{
+		Count:    int64(len(records)),
+		Checksum: fmt.Sprintf("%08x", hash.Sum32()),
+	}, nil
+}
+
+// writeChecksumRow 将参与校验的字段写入校验和
+func writeChecksumRow(w io.Writer, r Record) {
+	expiresAt := int64(0)
+	if r.ExpiresAt != nil {
+		expiresAt = r.ExpiresAt.Unix()
+	}
+	fmt.Fprintf(w, "%d\x00%s\x00%d\n", r.ID, r.Content, expiresAt)
+}
+
+// ChunkChecksum 记录表中一个ID区间（块）的校验和
+type ChunkChecksum struct {
+	FirstID  uint   `json:"first_id"` // 块的第一个ID（含）
+	LastID   uint   `json:"last_id"`  // 块的最后一个ID（含）
+	Count    int64  `json:"count"`    // 块中的记录数
+	Checksum string `json:"checksum"` // 按ID顺序计算的CRC32校验和
+}
+
+// ChunkOf 返回ID所在块的第一个和最后一个ID，块按ID划分为 [1, size]、[size+1, 2*size] ...
+func ChunkOf(id, size uint) (first, last uint) {
+	first = (id-1)/size*size + 1
+	return first, first + size - 1
+}
+
+// ChunkChecksums 按固定大小的ID区间分块计算记录表的校验和，只返回有记录的块（按ID顺序）
+// 块的边界只取决于ID，主从两边同一个块覆盖相同的ID，可以直接比较
+func (db *DB) ChunkChecksums(size uint) ([]ChunkChecksum, error) {
+	var records []Record
+	if err := db.conn.Order("id").Find(&records).Error; err != nil {
+		return nil, fmt.Errorf("failed to read records for checksum: %w", err)
+	}
+
+	var chunks []ChunkChecksum
+	var sum hash.Hash32
+	for _, r := range records {
+		first, last := ChunkOf(r.ID, size)
+		if len(chunks) == 0 || chunks[len(chunks)-1].FirstID != first {
+			if sum != nil {
+				chunks[len(chunks)-1].Checksum = fmt.Sprintf("%08x", sum.Sum32())
+			}
+			chunks = append(chunks, ChunkChecksum{FirstID: first, LastID: last})
+			sum = crc32.NewIEEE()
+		}
+		writeChecksumRow(sum, r)
+		chunks[len(chunks)-1].Count++
+	}
+	if sum != nil {
+		chunks[len(chunks)-1].Checksum = fmt.Sprintf("%08x", sum.Sum32())
+	}
+	return chunks, nil
+}
+
+// ListRecordsInRange 获取ID在 [first, last] 区间内的记录（按ID顺序）
+func (db *DB) ListRecordsInRange(first, last uint) ([]Record, error) {
+	var records []Record
+	if err := db.conn.Where("id BETWEEN ? AND ?", first, last).Order("id").Find(&records).Error; err != nil {
+		return nil, fmt.Errorf("failed to list records in range: %w", err)
+	}
+	return records, nil
+}
+
+// ExecDDL 执行一条DDL语句（主节点上执行提交的变更，从节点上应用DDL条目）
+// MySQL在DDL前后隐式提交，在事务中调用时之前的写入会被提交
+func (db *DB) ExecDDL(statement string) error {
+	return db.conn.Exec(statement).Error
+}
+
 // Close 关闭数据库连接
 func (db *DB) Close() error {
 	sqlDB, err := db.conn.DB()
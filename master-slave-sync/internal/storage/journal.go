@@ -0,0 +1,62 @@
+package storage
+
+import (
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// JournalEntry 复制日志条目，与数据写入在同一个事务中提交，binlog追加成功后删除
+// 进程在数据提交之后、binlog追加之前崩溃时，重启后根据残留的条目补发binlog
+type JournalEntry struct {
+	ID        uint64    `gorm:"primarykey"`
+	Operation string    `gorm:"size:16"`                   // 操作类型：INSERT, UPDATE, DELETE
+	Table     string    `gorm:"column:table_name;size:64"` // 被操作的表，为空表示 records（引入多表复制之前写入的条目）
+	Format    string    `gorm:"size:16"`                   // binlog格式，为空表示基于行
+	RecordID  uint      // 被操作记录的ID
+	Data      []byte    // 序列化后的记录数据（与binlog条目相同）
+	Before    []byte    // UPDATE之前的行（前映像），为空表示未记录
+	CreatedAt time.Time `gorm:"autoCreateTime"`
+}
+
+// TableName 复制日志表名
+func (JournalEntry) TableName() string {
+	return "replication_journal"
+}
+
+// Transaction 在一个数据库事务中执行fn，fn返回错误时回滚
+func (db *DB) Transaction(fn func(tx *DB) error) error {
+	return db.conn.Transaction(func(tx *gorm.DB) error {
+		return fn(&DB{conn: tx, role: db.currentRole(), guard: db.guard})
+	})
+}
+
+// AppendJournal 写入一条复制日志，应在与数据写入相同的事务中调用（仅主节点支持）
+func (db *DB) AppendJournal(entry *JournalEntry) (uint64, error) {
+	if err := db.checkWritable(); err != nil {
+		return 0, err
+	}
+
+	if err := db.conn.Create(entry).Error; err != nil {
+		return 0, fmt.Errorf("failed to append journal entry: %w", err)
+	}
+	return entry.ID, nil
+}
+
+// PendingJournal 获取尚未写入binlog的复制日志（按提交顺序）
+func (db *DB) PendingJournal() ([]JournalEntry, error) {
+	var entries []JournalEntry
+	if err := db.conn.Order("id").Find(&entries).Error; err != nil {
+		return nil, fmt.Errorf("failed to read journal: %w", err)
+	}
+	return entries, nil
+}
+
+// RemoveJournal 删除已写入binlog的复制日志
+func (db *DB) RemoveJournal(id uint64) error {
+	if err := db.conn.Delete(&JournalEntry{}, id).Error; err != nil {
+		return fmt.Errorf("failed to remove journal entry %d: %w", id, err)
+	}
+	return nil
+}
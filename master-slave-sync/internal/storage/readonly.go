@@ -0,0 +1,85 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+)
+
+// ErrReadOnly 从库连接只读，写操作应发往主节点
+var ErrReadOnly = errors.New("write operations not allowed on slave node")
+
+// ReadOnlyError 在只读的从库连接上写入时返回的错误，包含当前主节点的地址
+type ReadOnlyError struct {
+	MasterURL string // 当前主节点的API地址，未知时为空
+}
+
+// Error 实现error接口
+func (e *ReadOnlyError) Error() string {
+	if e.MasterURL == "" {
+		return ErrReadOnly.Error()
+	}
+	return fmt.Sprintf("%s, send writes to the master at %s", ErrReadOnly, e.MasterURL)
+}
+
+// Unwrap 支持 errors.Is(err, ErrReadOnly)
+func (e *ReadOnlyError) Unwrap() error {
+	return ErrReadOnly
+}
+
+// writeGuard 从库连接的只读状态，同一个连接在事务中派生的 DB 共用它
+type writeGuard struct {
+	mu        sync.RWMutex
+	masterURL string // 当前主节点的地址，写入被拒绝时返回给调用方
+	override  string // 临时解除只读的原因（类似关闭 super_read_only），为空表示只读
+}
+
+// SetMasterURL 设置当前主节点的地址（从节点启动和切换复制源时调用）
+func (db *DB) SetMasterURL(url string) {
+	db.guard.mu.Lock()
+	defer db.guard.mu.Unlock()
+	db.guard.masterURL = url
+}
+
+// currentRole 连接的角色，与 PromoteToMaster 并发时读到提升前或提升后的角色
+func (db *DB) currentRole() string {
+	db.guard.mu.RLock()
+	defer db.guard.mu.RUnlock()
+	return db.role
+}
+
+// ReadOnly 是否拒绝写操作：从库连接只读，除非正在提升（见 OverrideReadOnly）
+func (db *DB) ReadOnly() bool {
+	db.guard.mu.RLock()
+	defer db.guard.mu.RUnlock()
+	return db.role != "master" && db.guard.override == ""
+}
+
+// OverrideReadOnly 临时允许在从库连接上写入，只在提升为主节点期间使用，返回恢复只读的函数
+// 复制应用条目不经过这里的检查（与MySQL的复制线程不受 read_only 限制相同）
+func (db *DB) OverrideReadOnly(reason string) (restore func()) {
+	db.guard.mu.Lock()
+	db.guard.override = reason
+	db.guard.mu.Unlock()
+	log.Printf("Read-only disabled on slave database: %s", reason)
+
+	return func() {
+		db.guard.mu.Lock()
+		defer db.guard.mu.Unlock()
+		if db.guard.override == reason && db.role != "master" {
+			db.guard.override = ""
+			log.Printf("Read-only restored on slave database")
+		}
+	}
+}
+
+// checkWritable 只读时返回 *ReadOnlyError
+func (db *DB) checkWritable() error {
+	if !db.ReadOnly() {
+		return nil
+	}
+	db.guard.mu.RLock()
+	defer db.guard.mu.RUnlock()
+	return &ReadOnlyError{MasterURL: db.guard.masterURL}
+}
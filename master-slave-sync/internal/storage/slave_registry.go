@@ -0,0 +1,63 @@
+package storage
+
+import (
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// RegisteredSlave 主节点登记的从节点，主节点定期把内存中的从节点信息表写入该表，
+// 重启后据此恢复，不会忘记已注册的从节点和它们确认的位置
+type RegisteredSlave struct {
+	SlaveID   string    `gorm:"primarykey;size:128"`
+	Host      string    `gorm:"size:255"` // 主机地址
+	Port      int       // API端口
+	Position  uint64    // 最后确认的binlog位置
+	Filter    string    `gorm:"type:text"` // 注册的过滤规则(JSON)，为空表示不过滤
+	LastSeen  time.Time // 最后一次心跳、确认或注册的时间
+	UpdatedAt time.Time `gorm:"autoUpdateTime"`
+}
+
+// TableName 从节点登记表名
+func (RegisteredSlave) TableName() string {
+	return "registered_slaves"
+}
+
+// LoadRegisteredSlaves 读取登记的所有从节点（按ID排序）
+func (db *DB) LoadRegisteredSlaves() ([]RegisteredSlave, error) {
+	var slaves []RegisteredSlave
+	if err := db.conn.Order("slave_id").Find(&slaves).Error; err != nil {
+		return nil, fmt.Errorf("failed to load registered slaves: %w", err)
+	}
+	return slaves, nil
+}
+
+// SaveRegisteredSlaves 用slaves替换登记表的内容：写入或更新其中的从节点，删除不在其中的从节点
+func (db *DB) SaveRegisteredSlaves(slaves []RegisteredSlave) error {
+	err := db.conn.Transaction(func(tx *gorm.DB) error {
+		ids := make([]string, len(slaves))
+		for i, slave := range slaves {
+			ids[i] = slave.SlaveID
+		}
+		remove := tx.Session(&gorm.Session{AllowGlobalUpdate: true})
+		if len(ids) > 0 {
+			remove = remove.Where("slave_id NOT IN ?", ids)
+		}
+		if err := remove.Delete(&RegisteredSlave{}).Error; err != nil {
+			return err
+		}
+		if len(slaves) == 0 {
+			return nil
+		}
+		return tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "slave_id"}},
+			DoUpdates: clause.AssignmentColumns([]string{"host", "port", "position", "filter", "last_seen", "updated_at"}),
+		}).Create(&slaves).Error
+	})
+	if err != nil {
+		return fmt.Errorf("failed to save registered slaves: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,24 @@
+package storage
+
+import (
+	"fmt"
+
+	"gorm.io/gorm"
+)
+
+// ReadSnapshot 在一个只读的一致性快照事务中执行fn（START TRANSACTION WITH CONSISTENT SNAPSHOT）
+// 事务开始时即建立读视图，fn中的所有查询读到的都是开始时已提交的数据，不受之后的写入影响
+// 调用方可以在阻塞写入期间开始事务、记录对应的binlog位置，fn开始执行后即可放行写入
+func (db *DB) ReadSnapshot(fn func(tx *DB) error) error {
+	return db.conn.Connection(func(conn *gorm.DB) error {
+		// 一致性快照只在可重复读隔离级别下生效，SET TRANSACTION 只作用于下一个事务
+		if err := conn.Exec("SET TRANSACTION ISOLATION LEVEL REPEATABLE READ").Error; err != nil {
+			return fmt.Errorf("failed to set snapshot isolation level: %w", err)
+		}
+		if err := conn.Exec("START TRANSACTION WITH CONSISTENT SNAPSHOT, READ ONLY").Error; err != nil {
+			return fmt.Errorf("failed to start snapshot transaction: %w", err)
+		}
+		defer conn.Exec("ROLLBACK")
+		return fn(&DB{conn: conn, role: db.currentRole(), guard: db.guard})
+	})
+}
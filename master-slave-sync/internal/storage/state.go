@@ -0,0 +1,84 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ReplicationState 从节点已应用到的binlog位置，与应用的数据在同一个事务中更新
+// 从节点重启后从该位置继续同步，不会重新应用整个binlog
+type ReplicationState struct {
+	SlaveID   string    `gorm:"primarykey;size:64"` // 从节点ID
+	Position  uint64    // 已应用的最后一个binlog条目ID
+	Epoch     uint64    // 已应用的条目中最大的主节点纪元
+	UpdatedAt time.Time `gorm:"autoUpdateTime"`
+}
+
+// TableName 复制状态表名
+func (ReplicationState) TableName() string {
+	return "replication_state"
+}
+
+// LoadPosition 读取从节点已应用到的binlog位置，没有记录时返回0
+func (db *DB) LoadPosition(slaveID string) (uint64, error) {
+	return db.loadPosition(db.conn, slaveID)
+}
+
+// LockPosition 在事务中读取并锁定从节点的位置行（SELECT ... FOR UPDATE），没有记录时返回0
+// 同一从节点ID的并发应用（如误用同一ID启动的两个进程）在位置行上串行执行，
+// 后一个事务读到的是前一个事务提交后的位置，不会重复应用同一批条目，也不会用较旧的位置覆盖较新的位置
+func (db *DB) LockPosition(slaveID string) (uint64, error) {
+	return db.loadPosition(db.conn.Clauses(clause.Locking{Strength: "UPDATE"}), slaveID)
+}
+
+// loadPosition 读取位置行
+func (db *DB) loadPosition(conn *gorm.DB, slaveID string) (uint64, error) {
+	var state ReplicationState
+	err := conn.Where("slave_id = ?", slaveID).First(&state).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to load replication position: %w", err)
+	}
+	return state.Position, nil
+}
+
+// LoadEpoch 读取从节点已应用的条目中最大的主节点纪元，没有记录时返回0
+func (db *DB) LoadEpoch(slaveID string) (uint64, error) {
+	var state ReplicationState
+	err := db.conn.Where("slave_id = ?", slaveID).First(&state).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to load replication epoch: %w", err)
+	}
+	return state.Epoch, nil
+}
+
+// SaveEpoch 保存从节点已应用的最大纪元，应在 SavePosition 之后、同一个事务中调用
+func (db *DB) SaveEpoch(slaveID string, epoch uint64) error {
+	err := db.conn.Model(&ReplicationState{}).Where("slave_id = ?", slaveID).Update("epoch", epoch).Error
+	if err != nil {
+		return fmt.Errorf("failed to save replication epoch: %w", err)
+	}
+	return nil
+}
+
+// SavePosition 保存从节点已应用到的binlog位置，应在与应用数据相同的事务中调用
+func (db *DB) SavePosition(slaveID string, position uint64) error {
+	state := &ReplicationState{SlaveID: slaveID, Position: position}
+	err := db.conn.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "slave_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"position", "updated_at"}),
+	}).Create(state).Error
+	if err != nil {
+		return fmt.Errorf("failed to save replication position: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,130 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Statement 执行过的一条写语句及其参数，基于语句的复制在从库上按原样重放
+type Statement struct {
+	SQL  string         `json:"sql"`            // 带占位符的SQL
+	Args []StatementArg `json:"args,omitempty"` // 参数
+}
+
+// StatementArg 语句参数，时间和二进制参数单独保存，在从库上还原为原来的类型
+type StatementArg struct {
+	Value interface{} `json:"value,omitempty"` // 其他类型的值（数值解码为json.Number）
+	Time  *time.Time  `json:"time,omitempty"`  // 时间参数
+	Bytes []byte      `json:"bytes,omitempty"` // 二进制参数
+}
+
+// value 还原参数值
+func (a StatementArg) value() interface{} {
+	switch {
+	case a.Time != nil:
+		return *a.Time
+	case a.Bytes != nil:
+		return a.Bytes
+	default:
+		return a.Value
+	}
+}
+
+// statementLogKey 上下文中保存语句记录的键
+type statementLogKey struct{}
+
+// newStatementArgs 转换GORM执行语句时的参数
+func newStatementArgs(vars []interface{}) []StatementArg {
+	args := make([]StatementArg, 0, len(vars))
+	for _, v := range vars {
+		switch val := v.(type) {
+		case time.Time:
+			args = append(args, StatementArg{Time: &val})
+		case *time.Time:
+			if val == nil {
+				args = append(args, StatementArg{})
+			} else {
+				args = append(args, StatementArg{Time: val})
+			}
+		case []byte:
+			args = append(args, StatementArg{Bytes: val})
+		default:
+			args = append(args, StatementArg{Value: val})
+		}
+	}
+	return args
+}
+
+// registerStatementLog 注册在写语句执行成功后记录语句的回调，只记录通过 WithStatementLog 获得的连接上执行的语句
+func registerStatementLog(conn *gorm.DB) error {
+	record := func(db *gorm.DB) {
+		if db.Error != nil || db.DryRun || db.Statement.Context == nil {
+			return
+		}
+		log, ok := db.Statement.Context.Value(statementLogKey{}).(*[]Statement)
+		if !ok {
+			return
+		}
+		*log = append(*log, Statement{SQL: db.Statement.SQL.String(), Args: newStatementArgs(db.Statement.Vars)})
+	}
+
+	callbacks := conn.Callback()
+	if err := callbacks.Create().After("gorm:create").Register("replication:statement_create", record); err != nil {
+		return err
+	}
+	if err := callbacks.Update().After("gorm:update").Register("replication:statement_update", record); err != nil {
+		return err
+	}
+	if err := callbacks.Delete().After("gorm:delete").Register("replication:statement_delete", record); err != nil {
+		return err
+	}
+	return callbacks.Raw().After("gorm:raw").Register("replication:statement_raw", record)
+}
+
+// WithStatementLog 返回一个把执行成功的写语句追加到log的连接（仅主节点支持），用于基于语句的复制
+func (db *DB) WithStatementLog(log *[]Statement) *DB {
+	ctx := db.conn.Statement.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return &DB{conn: db.conn.WithContext(context.WithValue(ctx, statementLogKey{}, log)), role: db.currentRole(), guard: db.guard}
+}
+
+// ReplayStatements 在从库上按顺序重放语句，insertID不为0时先设置会话的 insert_id，
+// 使插入语句生成与主节点相同的自增ID（与MySQL在binlog中记录 INSERT_ID 的做法相同）
+func (db *DB) ReplayStatements(statements []Statement, insertID uint) error {
+	// 在同一个连接上执行，保证 insert_id 作用于随后的插入语句
+	return db.conn.Transaction(func(tx *gorm.DB) error {
+		if insertID != 0 {
+			if err := tx.Exec("SET insert_id = ?", insertID).Error; err != nil {
+				return fmt.Errorf("failed to set insert_id: %w", err)
+			}
+		}
+		for _, stmt := range statements {
+			args := make([]interface{}, 0, len(stmt.Args))
+			for _, arg := range stmt.Args {
+				args = append(args, arg.value())
+			}
+			if err := tx.Exec(stmt.SQL, args...).Error; err != nil {
+				return fmt.Errorf("failed to replay statement %q: %w", stmt.SQL, err)
+			}
+		}
+		return nil
+	})
+}
+
+// DecodeStatements 解码基于语句的binlog条目中的语句，数值参数保留为json.Number
+func DecodeStatements(data []byte) ([]Statement, error) {
+	var statements []Statement
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	if err := dec.Decode(&statements); err != nil {
+		return nil, fmt.Errorf("failed to decode statements: %w", err)
+	}
+	return statements, nil
+}
@@ -0,0 +1,102 @@
+package storage
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// BinlogTailState 主节点跟随MySQL自身binlog时（binlog_source: mysql）已转换到的位置，
+// 每个事务转换为条目并追加到binlog之后更新；主节点重启后从该位置继续读取
+type BinlogTailState struct {
+	Name      string    `gorm:"primarykey;size:64"`
+	File      string    `gorm:"size:255"` // MySQL binlog文件名
+	Pos       uint32    // 文件内已转换的最后一个事务之后的偏移
+	UpdatedAt time.Time `gorm:"autoUpdateTime"`
+}
+
+// TableName 跟随位置表名
+func (BinlogTailState) TableName() string {
+	return "binlog_tail_states"
+}
+
+// LoadTailState 读取跟随位置，没有记录时返回空文件名
+func (db *DB) LoadTailState(name string) (string, uint32, error) {
+	var state BinlogTailState
+	err := db.conn.Where("name = ?", name).First(&state).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return "", 0, nil
+	}
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to load binlog tail state: %w", err)
+	}
+	return state.File, state.Pos, nil
+}
+
+// SaveTailState 保存跟随位置
+func (db *DB) SaveTailState(name, file string, pos uint32) error {
+	state := &BinlogTailState{Name: name, File: file, Pos: pos}
+	err := db.conn.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "name"}},
+		DoUpdates: clause.AssignmentColumns([]string{"file", "pos", "updated_at"}),
+	}).Create(state).Error
+	if err != nil {
+		return fmt.Errorf("failed to save binlog tail state: %w", err)
+	}
+	return nil
+}
+
+// BinlogCoordinates MySQL当前binlog的文件名和位置（SHOW MASTER STATUS，MySQL 8.4 起为 SHOW BINARY LOG STATUS）
+// 没有开启binlog时返回错误
+func (db *DB) BinlogCoordinates() (string, uint32, error) {
+	var lastErr error
+	for _, statement := range []string{"SHOW MASTER STATUS", "SHOW BINARY LOG STATUS"} {
+		file, pos, err := db.binlogCoordinates(statement)
+		if err == nil {
+			return file, pos, nil
+		}
+		lastErr = err
+	}
+	return "", 0, fmt.Errorf("failed to read mysql binlog coordinates: %w", lastErr)
+}
+
+// binlogCoordinates 执行一种查询当前binlog位置的语句，只取前两列（文件名和位置）
+func (db *DB) binlogCoordinates(statement string) (string, uint32, error) {
+	rows, err := db.conn.Raw(statement).Rows()
+	if err != nil {
+		return "", 0, err
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return "", 0, err
+	}
+	if !rows.Next() {
+		if err := rows.Err(); err != nil {
+			return "", 0, err
+		}
+		return "", 0, fmt.Errorf("binary logging is not enabled")
+	}
+	values := make([]sql.RawBytes, len(columns))
+	dest := make([]interface{}, len(columns))
+	for i := range values {
+		dest[i] = &values[i]
+	}
+	if err := rows.Scan(dest...); err != nil {
+		return "", 0, err
+	}
+	if len(values) < 2 {
+		return "", 0, fmt.Errorf("unexpected result from %s", statement)
+	}
+	pos, err := strconv.ParseUint(string(values[1]), 10, 32)
+	if err != nil {
+		return "", 0, fmt.Errorf("invalid binlog position %q: %w", values[1], err)
+	}
+	return string(values[0]), uint32(pos), nil
+}
@@ -0,0 +1,340 @@
+package wsconn
+
+import (
+	"bufio"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// ErrClosed 连接已被对端或本端关闭
+var ErrClosed = errors.New("websocket connection closed")
+
+// ErrMessageTooLarge 收到的消息超过大小上限
+var ErrMessageTooLarge = errors.New("websocket message too large")
+
+// acceptGUID RFC 6455 中用于计算 Sec-WebSocket-Accept 的固定GUID
+const acceptGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// DefaultMaxMessageBytes 默认的单条消息大小上限
+const DefaultMaxMessageBytes = 64 << 20
+
+// 帧类型
+const (
+	opContinuation = 0x0
+	opText         = 0x1
+	opBinary       = 0x2
+	opClose        = 0x8
+	opPing         = 0x9
+	opPong         = 0xA
+)
+
+// Conn 一个WebSocket连接（RFC 6455 的最小实现：不支持扩展和子协议）
+// ReadMessage 只能在一个goroutine中调用，写方法可以并发调用
+type Conn struct {
+	rwc      io.ReadWriteCloser // 底层连接
+	br       *bufio.Reader      // 带缓冲的读取端
+	client   bool               // 是否为客户端（客户端发送的帧必须加掩码）
+	maxBytes int64              // 单条消息大小上限
+
+	writeMu   sync.Mutex // 写入锁
+	closeOnce sync.Once  // 保证只关闭一次
+	closeErr  error      // 关闭底层连接的结果
+}
+
+// newConn 基于已完成握手的连接创建WebSocket连接
+func newConn(rwc io.ReadWriteCloser, br *bufio.Reader, client bool) *Conn {
+	if br == nil {
+		br = bufio.NewReader(rwc)
+	}
+	return &Conn{rwc: rwc, br: br, client: client, maxBytes: DefaultMaxMessageBytes}
+}
+
+// SetMaxMessageBytes 设置单条消息大小上限，非正数表示使用默认值
+func (c *Conn) SetMaxMessageBytes(n int64) {
+	if n <= 0 {
+		n = DefaultMaxMessageBytes
+	}
+	c.maxBytes = n
+}
+
+// acceptKey 根据客户端的 Sec-WebSocket-Key 计算 Sec-WebSocket-Accept
+func acceptKey(key string) string {
+	h := sha1.New()
+	h.Write([]byte(key + acceptGUID))
+	return base64.StdEncoding.EncodeToString(h.Sum(nil))
+}
+
+// headerContains 判断逗号分隔的请求头中是否包含指定值（不区分大小写）
+func headerContains(h http.Header, name, value string) bool {
+	for _, v := range h.Values(name) {
+		for _, part := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(part), value) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// IsUpgradeRequest 判断请求是否为WebSocket升级请求
+func IsUpgradeRequest(r *http.Request) bool {
+	return headerContains(r.Header, "Connection", "upgrade") && headerContains(r.Header, "Upgrade", "websocket")
+}
+
+// Upgrade 服务端完成握手并接管HTTP连接，失败时已向客户端返回错误响应
+func Upgrade(w http.ResponseWriter, r *http.Request) (*Conn, error) {
+	if r.Method != http.MethodGet || !IsUpgradeRequest(r) {
+		http.Error(w, "websocket upgrade required", http.StatusUpgradeRequired)
+		return nil, fmt.Errorf("not a websocket upgrade request")
+	}
+	if r.Header.Get("Sec-WebSocket-Version") != "13" {
+		w.Header().Set("Sec-WebSocket-Version", "13")
+		http.Error(w, "unsupported websocket version", http.StatusBadRequest)
+		return nil, fmt.Errorf("unsupported websocket version %q", r.Header.Get("Sec-WebSocket-Version"))
+	}
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if key == "" {
+		http.Error(w, "missing Sec-WebSocket-Key", http.StatusBadRequest)
+		return nil, fmt.Errorf("missing Sec-WebSocket-Key")
+	}
+
+	hj, ok := w.(http.Hijacker)
+	if !ok {
+		http.Error(w, "websocket not supported", http.StatusInternalServerError)
+		return nil, fmt.Errorf("response writer does not support hijacking")
+	}
+	netConn, brw, err := hj.Hijack()
+	if err != nil {
+		return nil, fmt.Errorf("failed to hijack connection: %w", err)
+	}
+
+	response := "HTTP/1.1 101 Switching Protocols\r\n" +
+		"Upgrade: websocket\r\n" +
+		"Connection: Upgrade\r\n" +
+		"Sec-WebSocket-Accept: " + acceptKey(key) + "\r\n\r\n"
+	if _, err := brw.WriteString(response); err != nil {
+		netConn.Close()
+		return nil, fmt.Errorf("failed to write handshake: %w", err)
+	}
+	if err := brw.Flush(); err != nil {
+		netConn.Close()
+		return nil, fmt.Errorf("failed to write handshake: %w", err)
+	}
+	return newConn(netConn, brw.Reader, false), nil
+}
+
+// Dial 客户端通过给定的HTTP客户端发起握手，url为 http:// 地址
+// 客户端不应设置 Timeout（它同样限制升级后的连接），握手超时由 Transport 控制
+func Dial(client *http.Client, url string, header http.Header) (*Conn, error) {
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	for name, values := range header {
+		for _, v := range values {
+			req.Header.Add(name, v)
+		}
+	}
+
+	nonce := make([]byte, 16)
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate websocket key: %w", err)
+	}
+	key := base64.StdEncoding.EncodeToString(nonce)
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", "websocket")
+	req.Header.Set("Sec-WebSocket-Version", "13")
+	req.Header.Set("Sec-WebSocket-Key", key)
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		resp.Body.Close()
+		return nil, &HandshakeError{StatusCode: resp.StatusCode, Body: strings.TrimSpace(string(body))}
+	}
+	if resp.Header.Get("Sec-WebSocket-Accept") != acceptKey(key) {
+		resp.Body.Close()
+		return nil, fmt.Errorf("invalid Sec-WebSocket-Accept in handshake response")
+	}
+	rwc, ok := resp.Body.(io.ReadWriteCloser)
+	if !ok {
+		resp.Body.Close()
+		return nil, fmt.Errorf("upgraded response body is not writable")
+	}
+	return newConn(rwc, nil, true), nil
+}
+
+// HandshakeError 服务端拒绝了升级请求
+type HandshakeError struct {
+	StatusCode int    // 服务端返回的状态码
+	Body       string // 响应体（截断）
+}
+
+// Error 实现error接口
+func (e *HandshakeError) Error() string {
+	return fmt.Sprintf("websocket handshake failed with status %d: %s", e.StatusCode, e.Body)
+}
+
+// WriteMessage 发送一条文本消息
+func (c *Conn) WriteMessage(data []byte) error {
+	return c.writeFrame(opText, data)
+}
+
+// WriteBinaryMessage 发送一条二进制消息
+func (c *Conn) WriteBinaryMessage(data []byte) error {
+	return c.writeFrame(opBinary, data)
+}
+
+// Ping 发送一个ping帧，对端会回复pong（由对端的 ReadMessage 处理）
+func (c *Conn) Ping() error {
+	return c.writeFrame(opPing, nil)
+}
+
+// writeFrame 写入一个完整的（不分片的）帧
+func (c *Conn) writeFrame(opcode byte, payload []byte) error {
+	header := make([]byte, 0, 14)
+	header = append(header, 0x80|opcode)
+
+	maskBit := byte(0)
+	if c.client {
+		maskBit = 0x80
+	}
+	switch n := len(payload); {
+	case n <= 125:
+		header = append(header, maskBit|byte(n))
+	case n <= 0xFFFF:
+		header = append(header, maskBit|126, byte(n>>8), byte(n))
+	default:
+		header = append(header, maskBit|127)
+		header = binary.BigEndian.AppendUint64(header, uint64(n))
+	}
+
+	if c.client {
+		var mask [4]byte
+		if _, err := rand.Read(mask[:]); err != nil {
+			return fmt.Errorf("failed to generate frame mask: %w", err)
+		}
+		header = append(header, mask[:]...)
+		masked := make([]byte, len(payload))
+		for i := range payload {
+			masked[i] = payload[i] ^ mask[i%4]
+		}
+		payload = masked
+	}
+
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	if _, err := c.rwc.Write(append(header, payload...)); err != nil {
+		return err
+	}
+	return nil
+}
+
+// ReadMessage 读取下一条文本或二进制消息，自动回复ping并忽略pong
+// 对端关闭连接时返回 ErrClosed
+func (c *Conn) ReadMessage() ([]byte, error) {
+	var message []byte
+	started := false
+	for {
+		fin, opcode, payload, err := c.readFrame()
+		if err != nil {
+			return nil, err
+		}
+
+		switch opcode {
+		case opPing:
+			if err := c.writeFrame(opPong, payload); err != nil {
+				return nil, err
+			}
+		case opPong:
+		case opClose:
+			// 回显对端的关闭帧后关闭连接
+			c.closeOnce.Do(func() {
+				c.writeFrame(opClose, payload)
+				c.closeErr = c.rwc.Close()
+			})
+			return nil, ErrClosed
+		case opText, opBinary, opContinuation:
+			if (opcode == opContinuation) != started {
+				return nil, fmt.Errorf("unexpected websocket frame opcode %d", opcode)
+			}
+			started = true
+			if int64(len(message)+len(payload)) > c.maxBytes {
+				return nil, ErrMessageTooLarge
+			}
+			message = append(message, payload...)
+			if fin {
+				return message, nil
+			}
+		default:
+			return nil, fmt.Errorf("unknown websocket frame opcode %d", opcode)
+		}
+	}
+}
+
+// readFrame 读取一个帧并去掉掩码
+func (c *Conn) readFrame() (fin bool, opcode byte, payload []byte, err error) {
+	var head [2]byte
+	if _, err = io.ReadFull(c.br, head[:]); err != nil {
+		return false, 0, nil, err
+	}
+	fin = head[0]&0x80 != 0
+	opcode = head[0] & 0x0F
+	masked := head[1]&0x80 != 0
+
+	length := uint64(head[1] & 0x7F)
+	switch length {
+	case 126:
+		var ext [2]byte
+		if _, err = io.ReadFull(c.br, ext[:]); err != nil {
+			return false, 0, nil, err
+		}
+		length = uint64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err = io.ReadFull(c.br, ext[:]); err != nil {
+			return false, 0, nil, err
+		}
+		length = binary.BigEndian.Uint64(ext[:])
+	}
+	if length > uint64(c.maxBytes) {
+		return false, 0, nil, ErrMessageTooLarge
+	}
+
+	var mask [4]byte
+	if masked {
+		if _, err = io.ReadFull(c.br, mask[:]); err != nil {
+			return false, 0, nil, err
+		}
+	}
+	payload = make([]byte, length)
+	if _, err = io.ReadFull(c.br, payload); err != nil {
+		return false, 0, nil, err
+	}
+	if masked {
+		for i := range payload {
+			payload[i] ^= mask[i%4]
+		}
+	}
+	return fin, opcode, payload, nil
+}
+
+// Close 发送关闭帧并关闭底层连接，可以重复调用
+func (c *Conn) Close() error {
+	c.closeOnce.Do(func() {
+		c.writeFrame(opClose, []byte{0x03, 0xE8}) // 1000：正常关闭
+		c.closeErr = c.rwc.Close()
+	})
+	return c.closeErr
+}
@@ -0,0 +1,322 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"read-write-splitting/internal/db"
+)
+
+// ctlUsage ctl子命令的用法说明
+const ctlUsage = `Usage: main ctl [-admin URL] <command> [args]
+
+Commands:
+  topology                  show master/slaves with state and in-flight queries
+  stats [-watch interval]   show proxy stats (reads/writes/errors per node)
+  drain <slave> [-wait]     take a slave out of rotation; -wait blocks until in-flight queries finish
+  undrain <slave>           return a drained slave to rotation
+  force-master on|off       route every read to the master
+  profile [name]            show routing profiles, or switch to the named profile
+  routing [-route R] [-n N] top SQL fingerprints with read/write x master/slave routing counts
+`
+
+// ctlClient 访问管理API的客户端
+type ctlClient struct {
+	base string
+	http *http.Client
+}
+
+// runCtl 运维命令行入口（main ctl ...），通过管理API查看和操作正在运行的代理，返回进程退出码
+func runCtl(args []string) int {
+	fs := flag.NewFlagSet("ctl", flag.ContinueOnError)
+	admin := fs.String("admin", fmt.Sprintf("http://localhost:%d", adminPort), "admin API base URL")
+	fs.Usage = func() { fmt.Fprint(os.Stderr, ctlUsage) }
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return 2
+	}
+
+	c := &ctlClient{base: *admin, http: &http.Client{Timeout: 10 * time.Second}}
+	command, rest := fs.Arg(0), fs.Args()[1:]
+
+	var err error
+	switch command {
+	case "topology":
+		err = c.topology()
+	case "stats":
+		err = c.stats(rest)
+	case "drain":
+		err = c.drain(rest)
+	case "undrain":
+		err = c.undrain(rest)
+	case "force-master":
+		err = c.forceMaster(rest)
+	case "routing":
+		err = c.routing(rest)
+	case "profile":
+		err = c.profile(rest)
+	default:
+		fs.Usage()
+		return 2
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+// topology 以表格形式打印主从拓扑
+func (c *ctlClient) topology() error {
+	t, err := c.fetchTopology()
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("strategy: %s    force-master reads: %v\n\n", t.Strategy, t.ForceMasterReads)
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "ROLE\tNAME\tADDR\tSTATE\tIN-FLIGHT")
+	fmt.Fprintf(tw, "master\t%s\t%s\t%s\t%d\n", t.Master.Name, t.Master.Addr, t.Master.State, t.Master.InFlight)
+	for _, s := range t.Slaves {
+		fmt.Fprintf(tw, "slave\t%s\t%s\t%s\t%d\n", s.Name, s.Addr, s.State, s.InFlight)
+	}
+	return tw.Flush()
+}
+
+// stats 打印代理统计，-watch 指定间隔时持续刷新
+func (c *ctlClient) stats(args []string) error {
+	fs := flag.NewFlagSet("stats", flag.ContinueOnError)
+	watch := fs.Duration("watch", 0, "refresh interval (0 prints once)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	for {
+		var stats db.ProxyStats
+		if err := c.do(http.MethodGet, "/admin/stats", nil, &stats); err != nil {
+			return err
+		}
+		printStats(stats)
+		if *watch <= 0 {
+			return nil
+		}
+		time.Sleep(*watch)
+		fmt.Println()
+	}
+}
+
+// printStats 以表格形式打印代理统计
+func printStats(s db.ProxyStats) {
+	fmt.Printf("%s  strategy: %s  in-flight: %d  reads: %d  writes: %d  errors: %d  fallbacks: %d  stale-fallbacks: %d  consistency-fallbacks: %d\n",
+		time.Now().Format("15:04:05"), s.Strategy, s.InFlight, s.Reads, s.Writes, s.Errors, s.Fallbacks, s.StaleFallbacks, s.ConsistencyFallbacks)
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "NODE\tAVG-LATENCY\tIN-FLIGHT\tREADS\tWRITES\tERRORS\tWEIGHT\tLAG")
+	for i, n := range append([]db.NodeStats{s.Master}, s.Slaves...) {
+		lag := fmt.Sprintf("%.0fms", n.LagMs)
+		if i == 0 {
+			lag = "-"
+		} else if n.LagMs < 0 {
+			lag = "unknown"
+		}
+		fmt.Fprintf(tw, "%s\t%.2fms\t%d\t%d\t%d\t%d\t%.2f\t%s\n",
+			n.Name, n.AvgLatencyMs, n.InFlight, n.Reads, n.Writes, n.Errors, n.Weight, lag)
+	}
+	tw.Flush()
+}
+
+// drain 摘除从库，-wait 时等待节点上的在途查询全部结束
+func (c *ctlClient) drain(args []string) error {
+	fs := flag.NewFlagSet("drain", flag.ContinueOnError)
+	wait := fs.Bool("wait", false, "wait until in-flight queries on the slave finish")
+	timeout := fs.Duration("timeout", 30*time.Second, "maximum time to wait with -wait")
+	name, err := parseNodeArg(fs, args)
+	if err != nil {
+		return err
+	}
+
+	if err := c.do(http.MethodPost, "/admin/drain?node="+url.QueryEscape(name), nil, nil); err != nil {
+		return err
+	}
+	fmt.Printf("slave %s removed from rotation\n", name)
+	if !*wait {
+		return nil
+	}
+
+	deadline := time.Now().Add(*timeout)
+	for {
+		t, err := c.fetchTopology()
+		if err != nil {
+			return err
+		}
+		inFlight := int64(-1)
+		for _, s := range t.Slaves {
+			if s.Name == name {
+				inFlight = s.InFlight
+			}
+		}
+		if inFlight <= 0 {
+			fmt.Printf("slave %s drained\n", name)
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("slave %s still has %d queries in flight after %v", name, inFlight, *timeout)
+		}
+		fmt.Printf("waiting for %d in-flight queries...\n", inFlight)
+		time.Sleep(500 * time.Millisecond)
+	}
+}
+
+// undrain 将摘除的从库恢复到轮询中
+func (c *ctlClient) undrain(args []string) error {
+	name, err := parseNodeArg(flag.NewFlagSet("undrain", flag.ContinueOnError), args)
+	if err != nil {
+		return err
+	}
+	if err := c.do(http.MethodDelete, "/admin/drain?node="+url.QueryEscape(name), nil, nil); err != nil {
+		return err
+	}
+	fmt.Printf("slave %s back in rotation\n", name)
+	return nil
+}
+
+// forceMaster 开启或关闭强制读主库
+func (c *ctlClient) forceMaster(args []string) error {
+	if len(args) != 1 || args[0] != "on" && args[0] != "off" {
+		return fmt.Errorf("usage: force-master on|off")
+	}
+	enabled := args[0] == "on"
+
+	var resp map[string]bool
+	if err := c.do(http.MethodPost, fmt.Sprintf("/admin/force-master?enabled=%v", enabled), nil, &resp); err != nil {
+		return err
+	}
+	fmt.Printf("force-master reads: %v\n", resp["force_master_reads"])
+	return nil
+}
+
+// routing 打印SQL指纹的路由统计，-route 只看指定路由方向（如 read_master）
+func (c *ctlClient) routing(args []string) error {
+	fs := flag.NewFlagSet("routing", flag.ContinueOnError)
+	route := fs.String("route", "", "only fingerprints with this route (read_slave, read_master, write_master, write_slave)")
+	n := fs.Int("n", 20, "number of fingerprints to show")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	var stats []db.RoutingStats
+	path := fmt.Sprintf("/admin/digests/routing?route=%s&limit=%d", url.QueryEscape(*route), *n)
+	if err := c.do(http.MethodGet, path, nil, &stats); err != nil {
+		return err
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "COUNT\tREAD-SLAVE\tREAD-MASTER\tWRITE-MASTER\tWRITE-SLAVE\tFINGERPRINT")
+	for _, s := range stats {
+		fmt.Fprintf(tw, "%d\t%d\t%d\t%d\t%d\t%s\n", s.Count,
+			s.Routes[db.RouteReadSlave], s.Routes[db.RouteReadMaster],
+			s.Routes[db.RouteWriteMaster], s.Routes[db.RouteWriteSlave], s.Fingerprint)
+	}
+	return tw.Flush()
+}
+
+// profile 列出路由配置档，指定名称时切换到该配置档
+func (c *ctlClient) profile(args []string) error {
+	var resp struct {
+		Active   string              `json:"active"`
+		Profiles []db.RoutingProfile `json:"profiles"`
+	}
+	switch len(args) {
+	case 0:
+		if err := c.do(http.MethodGet, "/admin/profile", nil, &resp); err != nil {
+			return err
+		}
+	case 1:
+		if err := c.do(http.MethodPost, "/admin/profile?name="+url.QueryEscape(args[0]), nil, &resp); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("usage: profile [name]")
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "\tPROFILE\tSTRATEGY\tFORCE-MASTER\tDESCRIPTION")
+	for _, p := range resp.Profiles {
+		marker := ""
+		if p.Name == resp.Active {
+			marker = "*"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%v\t%s\n", marker, p.Name, p.Strategy, p.ForceMasterReads, p.Description)
+	}
+	return tw.Flush()
+}
+
+// parseNodeArg 解析子命令的标志和节点名称参数（节点名称可以在标志之前）
+func parseNodeArg(fs *flag.FlagSet, args []string) (string, error) {
+	if len(args) == 0 {
+		return "", fmt.Errorf("slave name is required")
+	}
+	name, flags := args[0], args[1:]
+	if len(name) > 0 && name[0] == '-' {
+		name, flags = "", args
+	}
+	if err := fs.Parse(flags); err != nil {
+		return "", err
+	}
+	if name == "" {
+		name = fs.Arg(0)
+	}
+	if name == "" {
+		return "", fmt.Errorf("slave name is required")
+	}
+	return name, nil
+}
+
+// fetchTopology 获取当前主从拓扑
+func (c *ctlClient) fetchTopology() (db.PoolTopology, error) {
+	var t db.PoolTopology
+	err := c.do(http.MethodGet, "/admin/topology", nil, &t)
+	return t, err
+}
+
+// do 调用管理API，非2xx响应时返回其中的错误信息；out为nil时忽略响应体
+func (c *ctlClient) do(method string, path string, body []byte, out interface{}) error {
+	req, err := http.NewRequest(method, c.base+path, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode/100 != 2 {
+		var apiErr struct {
+			Error string `json:"error"`
+		}
+		if json.Unmarshal(data, &apiErr) == nil && apiErr.Error != "" {
+			return fmt.Errorf("%s (%s)", apiErr.Error, resp.Status)
+		}
+		return fmt.Errorf("admin API returned %s", resp.Status)
+	}
+	if out == nil {
+		return nil
+	}
+	return json.Unmarshal(data, out)
+}
@@ -2,17 +2,34 @@ package main
 
 import (
 	"log"
+	"os"
+	"read-write-splitting/internal/api"
 	"read-write-splitting/internal/config"
-	"time"
 
 	"read-write-splitting/internal/db"
 	"read-write-splitting/internal/model"
 	"read-write-splitting/internal/service"
 )
 
+// API监听端口
+const (
+	apiPort   = 8080 // 用户API
+	adminPort = 9090 // 管理API
+)
+
 func main() {
-	// 初始化数据库配置
+	// 运维子命令：通过管理API查看和操作正在运行的代理
+	if len(os.Args) > 1 && os.Args[1] == "ctl" {
+		os.Exit(runCtl(os.Args[2:]))
+	}
+
+	// 初始化数据库配置，ROUTING_PROFILE 环境变量可以直接选择路由配置档（如 strict-consistency）
 	dbConfig := config.GetDefaultConfig()
+	if profile := os.Getenv("ROUTING_PROFILE"); profile != "" {
+		dbConfig.Profile = profile
+	}
+	// READ_PREFERENCE 环境变量设置默认读偏好（如 primaryPreferred、nearest）
+	dbConfig.ReadPreference = os.Getenv("READ_PREFERENCE")
 
 	// 创建数据库代理
 	dbProxy, err := db.NewDBProxy(dbConfig)
@@ -24,11 +41,22 @@ func main() {
 	// 自动迁移表结构
 	autoMigrate(dbProxy)
 
-	// 创建用户服务
-	userService := service.NewUserService(dbProxy)
+	// 启动管理API，可查看连接池和SQL指纹统计
+	adminServer := api.NewAdminServer(dbProxy, adminPort)
+	go func() {
+		if err := adminServer.Start(); err != nil {
+			log.Printf("Admin server error: %v", err)
+		}
+	}()
+
+	// 创建用户服务，读操作使用 ServiceReadPreferences["users"] 配置的读偏好
+	userService := service.NewUserService(dbProxy.ForService("users"))
 
-	// 演示读写分离
-	demonstrateReadWriteSplitting(userService)
+	// 启动用户REST API，可用压测工具产生并发读写流量观察路由效果
+	userServer := api.NewUserServer(userService, apiPort)
+	if err := userServer.Start(); err != nil {
+		log.Fatalf("User API server error: %v", err)
+	}
 }
 
 // 自动迁移表结构到数据库
@@ -40,100 +68,3 @@ func autoMigrate(dbProxy *db.DBProxy) {
 	}
 	log.Println("Migration completed successfully")
 }
-
-// 演示读写分离功能
-func demonstrateReadWriteSplitting(userService *service.UserService) {
-	log.Println("Demonstrating read-write splitting:")
-	log.Println("------------------------------------")
-
-	// 1. 创建用户（写操作，使用主库）
-	log.Println("1. Creating users (Write operation - Master DB)")
-	createDemoUsers(userService)
-
-	// 停顿一下，便于观察
-	time.Sleep(1 * time.Second)
-
-	// 2. 查询所有用户（读操作，使用从库）
-	log.Println("2. Fetching all users (Read operation - Slave DB)")
-	users, err := userService.GetAllUsers()
-	if err != nil {
-		log.Printf("Error fetching users: %v", err)
-	} else {
-		log.Printf("Retrieved %d users", len(users))
-		for _, user := range users {
-			log.Printf("User: %s, Email: %s, Age: %d", user.Username, user.Email, user.Age)
-		}
-	}
-
-	// 停顿一下，便于观察
-	time.Sleep(1 * time.Second)
-
-	// 3. 更新用户（写操作，使用主库）
-	log.Println("3. Updating user (Write operation - Master DB)")
-	if len(users) > 0 {
-		user := users[0]
-		user.Age = 30
-		err := userService.UpdateUser(&user)
-		if err != nil {
-			log.Printf("Error updating user: %v", err)
-		} else {
-			log.Printf("Updated user %s, new age: %d", user.Username, user.Age)
-		}
-	}
-
-	// 停顿一下，便于观察
-	time.Sleep(1 * time.Second)
-
-	// 4. 按年龄查询用户（读操作，使用从库）
-	log.Println("4. Searching users by age (Read operation - Slave DB)")
-	searchAge := 30
-	usersFound, err := userService.SearchUsersByAge(searchAge)
-	if err != nil {
-		log.Printf("Error searching users: %v", err)
-	} else {
-		log.Printf("Found %d users with age %d", len(usersFound), searchAge)
-		for _, user := range usersFound {
-			log.Printf("User: %s, Email: %s, Age: %d", user.Username, user.Email, user.Age)
-		}
-	}
-
-	// 停顿一下，便于观察
-	time.Sleep(1 * time.Second)
-
-	// 5. 删除一个用户（写操作，使用主库）
-	log.Println("5. Deleting a user (Write operation - Master DB)")
-	if len(users) > 0 {
-		err := userService.DeleteUser(users[0].ID)
-		if err != nil {
-			log.Printf("Error deleting user: %v", err)
-		} else {
-			log.Printf("Deleted user with ID %d", users[0].ID)
-		}
-	}
-
-	log.Println("------------------------------------")
-	log.Println("Demonstration completed")
-}
-
-// 创建演示用户
-func createDemoUsers(userService *service.UserService) {
-	demoUsers := []struct {
-		username string
-		email    string
-		password string
-		age      int
-	}{
-		{"alice", "alice@example.com", "password123", 25},
-		{"bob", "bob@example.com", "password456", 30},
-		{"charlie", "charlie@example.com", "password789", 35},
-	}
-
-	for _, u := range demoUsers {
-		user, err := userService.CreateUser(u.username, u.email, u.password, u.age)
-		if err != nil {
-			log.Printf("Failed to create user %s: %v", u.username, err)
-		} else {
-			log.Printf("Created user: %s (ID: %d)", user.Username, user.ID)
-		}
-	}
-}
@@ -0,0 +1,482 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+
+	"read-write-splitting/internal/db"
+)
+
+// AdminServer 提供读写分离代理的管理API
+type AdminServer struct {
+	proxy *db.DBProxy
+	port  int
+}
+
+// NewAdminServer 创建新的管理API服务器
+func NewAdminServer(proxy *db.DBProxy, port int) *AdminServer {
+	return &AdminServer{
+		proxy: proxy,
+		port:  port,
+	}
+}
+
+// Routes 注册管理API路由
+func (s *AdminServer) Routes() *http.ServeMux {
+	mux := http.NewServeMux()
+
+	// 连接池统计
+	mux.HandleFunc("/admin/stats", s.handleStats)
+
+	// 各租户连接池统计
+	mux.HandleFunc("/admin/tenants", func(w http.ResponseWriter, r *http.Request) {
+		respondWithJSON(w, http.StatusOK, s.proxy.TenantStats())
+	})
+
+	// SQL指纹统计
+	mux.HandleFunc("/admin/digests", s.handleDigests)
+	mux.HandleFunc("/admin/digests/reset", s.handleDigestsReset)
+	mux.HandleFunc("/admin/digests/routing", s.handleDigestRouting)
+
+	// 启动连通性报告
+	mux.HandleFunc("/admin/startup", func(w http.ResponseWriter, r *http.Request) {
+		respondWithJSON(w, http.StatusOK, s.proxy.StartupReport())
+	})
+
+	// 从库移出与重新加入轮询的事件
+	mux.HandleFunc("/admin/events", func(w http.ResponseWriter, r *http.Request) {
+		respondWithJSON(w, http.StatusOK, s.proxy.Events())
+	})
+
+	// 经代理开启、尚未结束的事务（持续时间、是否为长事务、开启时的调用栈）
+	mux.HandleFunc("/admin/transactions", func(w http.ResponseWriter, r *http.Request) {
+		respondWithJSON(w, http.StatusOK, s.proxy.Transactions())
+	})
+
+	// 主库可用性与写入队列状态
+	mux.HandleFunc("/admin/master", s.handleMasterStatus)
+
+	// 一致性分页会话（GET：列出会话，DELETE ?id=：强制关闭）
+	mux.HandleFunc("/admin/listings", s.handleListings)
+
+	// 混沌注入（GET：查看规则，POST：设置规则，DELETE ?node=：清除规则）
+	mux.HandleFunc("/admin/chaos", s.handleChaos)
+
+	// SQL改写钩子（GET：列出钩子，POST ?name=&enabled=true|false：启用或禁用）
+	mux.HandleFunc("/admin/rewrites", s.handleRewrites)
+
+	// 预处理语句缓存（GET：各节点统计，DELETE：清空缓存）
+	mux.HandleFunc("/admin/stmtcache", s.handleStmtCache)
+
+	// 维护操作：替换后端（POST {"role":"master|slave","name":"slave-0","dsn":"..."}）
+	mux.HandleFunc("/admin/backends", s.handleBackends)
+
+	// 拓扑（GET：当前主从节点及状态，POST：ha-switcher的拓扑变化回调，配置为其TopologyWebhooks）
+	mux.HandleFunc("/admin/topology", s.handleTopology)
+
+	// 维护操作：手动摘除从库（POST ?node=）或恢复（DELETE ?node=）
+	mux.HandleFunc("/admin/drain", s.handleDrain)
+
+	// 维护操作：强制所有读操作走主库（GET：查看，POST ?enabled=true|false：切换）
+	mux.HandleFunc("/admin/force-master", s.handleForceMaster)
+
+	// 路由配置档（GET：当前配置档及所有可选配置档，POST ?name=：切换）
+	mux.HandleFunc("/admin/profile", s.handleProfile)
+
+	// 读偏好（GET：默认及按服务的读偏好，POST ?pref=[&service=]：设置，service非空且pref为空时删除该服务的设置）
+	mux.HandleFunc("/admin/read-preference", s.handleReadPreference)
+
+	// 缓存失效广播统计（未启用时返回404）
+	mux.HandleFunc("/admin/invalidation", func(w http.ResponseWriter, r *http.Request) {
+		b := s.proxy.Invalidation()
+		if b == nil {
+			respondWithError(w, http.StatusNotFound, "Invalidation broadcast is not enabled")
+			return
+		}
+		respondWithJSON(w, http.StatusOK, b.Stats())
+	})
+
+	return mux
+}
+
+// Start 启动管理API服务器
+func (s *AdminServer) Start() error {
+	addr := fmt.Sprintf(":%d", s.port)
+	log.Printf("Starting admin server at http://localhost%s", addr)
+	return http.ListenAndServe(addr, s.Routes())
+}
+
+// handleStats 返回代理统计信息
+func (s *AdminServer) handleStats(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		respondWithError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+	respondWithJSON(w, http.StatusOK, s.proxy.Stats())
+}
+
+// handleMasterStatus 返回主库可用性状态
+func (s *AdminServer) handleMasterStatus(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		respondWithError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+	respondWithJSON(w, http.StatusOK, s.proxy.MasterStatus())
+}
+
+// handleListings 列出或强制关闭一致性分页会话
+func (s *AdminServer) handleListings(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		respondWithJSON(w, http.StatusOK, s.proxy.Listings())
+
+	case http.MethodDelete:
+		id := r.URL.Query().Get("id")
+		if id == "" {
+			respondWithError(w, http.StatusBadRequest, "Missing id parameter")
+			return
+		}
+		if err := s.proxy.CloseListing(id); err != nil {
+			respondWithError(w, http.StatusNotFound, err.Error())
+			return
+		}
+		respondWithJSON(w, http.StatusOK, map[string]string{"message": "Listing closed"})
+
+	default:
+		respondWithError(w, http.StatusMethodNotAllowed, "Method not allowed")
+	}
+}
+
+// handleStmtCache 返回或清空各节点的预处理语句缓存
+func (s *AdminServer) handleStmtCache(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		respondWithJSON(w, http.StatusOK, s.proxy.StmtCacheStats())
+
+	case http.MethodDelete:
+		s.proxy.PurgeStmtCache()
+		respondWithJSON(w, http.StatusOK, map[string]string{"message": "Statement cache purged"})
+
+	default:
+		respondWithError(w, http.StatusMethodNotAllowed, "Method not allowed")
+	}
+}
+
+// handleDigests 返回SQL指纹统计，支持 ?sort=count|total_latency|avg_latency&limit=N
+func (s *AdminServer) handleDigests(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		respondWithError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	limit := 0
+	if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
+		var err error
+		limit, err = strconv.Atoi(limitStr)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "Invalid limit parameter")
+			return
+		}
+	}
+
+	digests := s.proxy.Digests().Snapshot(r.URL.Query().Get("sort"), limit)
+	respondWithJSON(w, http.StatusOK, digests)
+}
+
+// handleDigestRouting 返回各SQL指纹的路由统计，支持 ?route=read_slave|read_master|write_master|write_slave&limit=N
+func (s *AdminServer) handleDigestRouting(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		respondWithError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	route := r.URL.Query().Get("route")
+	switch route {
+	case "", db.RouteReadSlave, db.RouteReadMaster, db.RouteWriteMaster, db.RouteWriteSlave:
+	default:
+		respondWithError(w, http.StatusBadRequest, "Invalid route parameter")
+		return
+	}
+
+	limit := 20
+	if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
+		var err error
+		limit, err = strconv.Atoi(limitStr)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "Invalid limit parameter")
+			return
+		}
+	}
+
+	respondWithJSON(w, http.StatusOK, s.proxy.Digests().Routing(route, limit))
+}
+
+// handleDigestsReset 清空SQL指纹统计
+func (s *AdminServer) handleDigestsReset(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		respondWithError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+	s.proxy.Digests().Reset()
+	respondWithJSON(w, http.StatusOK, map[string]string{"message": "Digests reset"})
+}
+
+// handleChaos 管理混沌注入规则
+func (s *AdminServer) handleChaos(w http.ResponseWriter, r *http.Request) {
+	chaos := s.proxy.Chaos()
+
+	switch r.Method {
+	case http.MethodGet:
+		respondWithJSON(w, http.StatusOK, chaos.Rules())
+
+	case http.MethodPost:
+		var rule db.ChaosRule
+		if err := json.NewDecoder(r.Body).Decode(&rule); err != nil {
+			respondWithError(w, http.StatusBadRequest, "Invalid request payload")
+			return
+		}
+		defer r.Body.Close()
+
+		if err := chaos.SetRule(rule); err != nil {
+			respondWithError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		respondWithJSON(w, http.StatusOK, chaos.Rules())
+
+	case http.MethodDelete:
+		chaos.ClearRule(r.URL.Query().Get("node"))
+		respondWithJSON(w, http.StatusOK, chaos.Rules())
+
+	default:
+		respondWithError(w, http.StatusMethodNotAllowed, "Method not allowed")
+	}
+}
+
+// handleRewrites 查看或切换SQL改写钩子
+func (s *AdminServer) handleRewrites(w http.ResponseWriter, r *http.Request) {
+	router := s.proxy.Router()
+
+	switch r.Method {
+	case http.MethodGet:
+		respondWithJSON(w, http.StatusOK, router.RewriteHooks())
+
+	case http.MethodPost:
+		enabled, err := strconv.ParseBool(r.URL.Query().Get("enabled"))
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "Invalid enabled parameter")
+			return
+		}
+		if err := router.SetRewriteHookEnabled(r.URL.Query().Get("name"), enabled); err != nil {
+			respondWithError(w, http.StatusNotFound, err.Error())
+			return
+		}
+		respondWithJSON(w, http.StatusOK, router.RewriteHooks())
+
+	default:
+		respondWithError(w, http.StatusMethodNotAllowed, "Method not allowed")
+	}
+}
+
+// backendRequest 替换后端请求
+type backendRequest struct {
+	Role string `json:"role"` // master 或 slave
+	Name string `json:"name"` // 从库节点名称（替换从库时必填）
+	DSN  string `json:"dsn"`  // 新后端的DSN
+}
+
+// handleBackends 替换主库或指定从库，新连接校验通过后才切换路由
+func (s *AdminServer) handleBackends(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		respondWithError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	var req backendRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	defer r.Body.Close()
+
+	if req.DSN == "" {
+		respondWithError(w, http.StatusBadRequest, "DSN is required")
+		return
+	}
+
+	var err error
+	switch req.Role {
+	case "master":
+		err = s.proxy.SwapMaster(req.DSN)
+	case "slave":
+		if req.Name == "" {
+			respondWithError(w, http.StatusBadRequest, "Slave name is required")
+			return
+		}
+		err = s.proxy.ReplaceSlave(req.Name, req.DSN)
+	default:
+		respondWithError(w, http.StatusBadRequest, "Role must be master or slave")
+		return
+	}
+	if err != nil {
+		code := http.StatusInternalServerError
+		if errors.Is(err, db.ErrBackendValidation) {
+			code = http.StatusBadGateway
+		}
+		respondWithError(w, code, err.Error())
+		return
+	}
+	respondWithJSON(w, http.StatusOK, s.proxy.PoolStats())
+}
+
+// handleTopology 查看当前主从拓扑，或接收ha-switcher推送的拓扑并切换主库
+func (s *AdminServer) handleTopology(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodGet {
+		respondWithJSON(w, http.StatusOK, s.proxy.Topology())
+		return
+	}
+	if r.Method != http.MethodPost {
+		respondWithError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	var topology db.Topology
+	if err := json.NewDecoder(r.Body).Decode(&topology); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	defer r.Body.Close()
+
+	if err := s.proxy.ApplyTopology(topology); err != nil {
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	respondWithJSON(w, http.StatusOK, map[string]string{"message": "Topology applied"})
+}
+
+// handleDrain 手动摘除从库或将其恢复到轮询中，返回操作后的拓扑
+func (s *AdminServer) handleDrain(w http.ResponseWriter, r *http.Request) {
+	node := r.URL.Query().Get("node")
+	if node == "" {
+		respondWithError(w, http.StatusBadRequest, "Node is required")
+		return
+	}
+
+	var err error
+	switch r.Method {
+	case http.MethodPost:
+		err = s.proxy.DrainSlave(node)
+	case http.MethodDelete:
+		err = s.proxy.UndrainSlave(node)
+	default:
+		respondWithError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	respondWithJSON(w, http.StatusOK, s.proxy.Topology())
+}
+
+// handleForceMaster 查看或切换强制读主库
+func (s *AdminServer) handleForceMaster(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+
+	case http.MethodPost:
+		enabled, err := strconv.ParseBool(r.URL.Query().Get("enabled"))
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "Invalid enabled parameter")
+			return
+		}
+		s.proxy.SetForceMasterReads(enabled)
+
+	default:
+		respondWithError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+	respondWithJSON(w, http.StatusOK, map[string]bool{"force_master_reads": s.proxy.Topology().ForceMasterReads})
+}
+
+// profileResponse 路由配置档查询结果
+type profileResponse struct {
+	Active   string              `json:"active"`   // 当前生效的配置档（未使用时为空）
+	Profiles []db.RoutingProfile `json:"profiles"` // 所有可选配置档
+}
+
+// handleProfile 查看或切换路由配置档
+func (s *AdminServer) handleProfile(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+
+	case http.MethodPost:
+		if err := s.proxy.ApplyProfile(r.URL.Query().Get("name")); err != nil {
+			respondWithError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+	default:
+		respondWithError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+	respondWithJSON(w, http.StatusOK, profileResponse{Active: s.proxy.Profile(), Profiles: db.Profiles()})
+}
+
+// readPreferenceResponse 读偏好查询结果
+type readPreferenceResponse struct {
+	Default  db.ReadPreference            `json:"default"`  // 默认读偏好
+	Services map[string]db.ReadPreference `json:"services"` // 按服务配置的读偏好
+}
+
+// handleReadPreference 查看或设置读偏好
+func (s *AdminServer) handleReadPreference(w http.ResponseWriter, r *http.Request) {
+	router := s.proxy.Router()
+	switch r.Method {
+	case http.MethodGet:
+
+	case http.MethodPost:
+		pref := db.ReadPreference(r.URL.Query().Get("pref"))
+		service := r.URL.Query().Get("service")
+		var err error
+		if service != "" {
+			err = router.SetServiceReadPreference(service, pref)
+		} else {
+			err = router.SetReadPreference(pref)
+		}
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+	default:
+		respondWithError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+	respondWithJSON(w, http.StatusOK, readPreferenceResponse{
+		Default:  router.DefaultReadPreference(),
+		Services: router.ServiceReadPreferences(),
+	})
+}
+
+// respondWithError 返回错误响应
+func respondWithError(w http.ResponseWriter, code int, message string) {
+	respondWithJSON(w, code, map[string]string{"error": message})
+}
+
+// respondWithJSON 返回JSON响应
+func respondWithJSON(w http.ResponseWriter, code int, payload interface{}) {
+	response, err := json.Marshal(payload)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(fmt.Sprintf("JSON marshaling error: %v", err)))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	w.Write(response)
+}
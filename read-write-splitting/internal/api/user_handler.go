@@ -0,0 +1,353 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"read-write-splitting/internal/db"
+	"read-write-splitting/internal/model"
+	"read-write-splitting/internal/service"
+)
+
+// UserServer 通过UserService提供用户的REST API，用于以真实的并发流量验证读写分离
+type UserServer struct {
+	service *service.UserService
+	port    int
+}
+
+// 请求和响应的结构体定义
+type createUserRequest struct {
+	Username string `json:"username"`
+	Email    string `json:"email"`
+	Password string `json:"password"`
+	Age      int    `json:"age"`
+}
+
+type updateUserRequest struct {
+	Username *string `json:"username"`
+	Email    *string `json:"email"`
+	Age      *int    `json:"age"`
+	Active   *bool   `json:"active"`
+}
+
+type userResponse struct {
+	ID        uint   `json:"id"`
+	Username  string `json:"username"`
+	Email     string `json:"email"`
+	Age       int    `json:"age"`
+	Active    bool   `json:"active"`
+	CreatedAt string `json:"created_at"`
+	UpdatedAt string `json:"updated_at"`
+}
+
+type openListingRequest struct {
+	IdleTimeoutSec int `json:"idle_timeout_sec"` // 空闲超时(秒)
+	MaxLifetimeSec int `json:"max_lifetime_sec"` // 最长存活时间(秒)
+}
+
+type userPageResponse struct {
+	Users    []userResponse `json:"users"`
+	Total    int64          `json:"total"`
+	Page     int            `json:"page"`
+	PageSize int            `json:"page_size"`
+}
+
+// NewUserServer 创建新的用户API服务器
+func NewUserServer(userService *service.UserService, port int) *UserServer {
+	return &UserServer{
+		service: userService,
+		port:    port,
+	}
+}
+
+// Routes 注册用户API路由
+func (s *UserServer) Routes() *http.ServeMux {
+	mux := http.NewServeMux()
+
+	// 用户集合（GET：分页列表，POST：创建用户）
+	mux.HandleFunc("/api/users", s.handleUsers)
+
+	// 用户搜索
+	mux.HandleFunc("/api/users/search", s.handleSearch)
+
+	// 一致性分页会话（POST：打开会话，GET：在会话中翻页，DELETE：关闭会话）
+	mux.HandleFunc("/api/users/listings", s.handleOpenListing)
+	mux.HandleFunc("/api/users/listings/", s.handleListing)
+
+	// 单个用户（GET/PUT/DELETE）
+	mux.HandleFunc("/api/users/", s.handleUserByID)
+
+	return mux
+}
+
+// Start 启动用户API服务器
+func (s *UserServer) Start() error {
+	addr := fmt.Sprintf(":%d", s.port)
+	log.Printf("Starting user API server at http://localhost%s", addr)
+	return http.ListenAndServe(addr, s.Routes())
+}
+
+// handleUsers 处理用户集合请求
+func (s *UserServer) handleUsers(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		query := r.URL.Query()
+		page, err := s.service.ListUsers(atoiOrZero(query.Get("page")), atoiOrZero(query.Get("page_size")))
+		if err != nil {
+			respondWithServiceError(w, err)
+			return
+		}
+		respondWithJSON(w, http.StatusOK, newUserPageResponse(page))
+
+	case http.MethodPost:
+		var req createUserRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			respondWithError(w, http.StatusBadRequest, "Invalid request payload")
+			return
+		}
+		defer r.Body.Close()
+
+		if req.Username == "" || req.Email == "" || req.Password == "" {
+			respondWithError(w, http.StatusBadRequest, "username, email and password are required")
+			return
+		}
+
+		user, err := s.service.CreateUser(req.Username, req.Email, req.Password, req.Age)
+		if err != nil {
+			respondWithServiceError(w, err)
+			return
+		}
+		respondWithJSON(w, http.StatusCreated, newUserResponse(user))
+
+	default:
+		respondWithError(w, http.StatusMethodNotAllowed, "Method not allowed")
+	}
+}
+
+// handleOpenListing 打开一致性分页会话
+func (s *UserServer) handleOpenListing(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		respondWithError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	var req openListingRequest
+	if r.ContentLength > 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			respondWithError(w, http.StatusBadRequest, "Invalid request payload")
+			return
+		}
+		defer r.Body.Close()
+	}
+
+	session, err := s.service.OpenListing(db.ListingOptions{
+		IdleTimeout: time.Duration(req.IdleTimeoutSec) * time.Second,
+		MaxLifetime: time.Duration(req.MaxLifetimeSec) * time.Second,
+	})
+	if err != nil {
+		respondWithServiceError(w, err)
+		return
+	}
+	respondWithJSON(w, http.StatusCreated, map[string]string{
+		"id":   session.ID(),
+		"node": session.Node(),
+	})
+}
+
+// handleListing 在一致性分页会话中翻页（GET，参数同搜索接口）或关闭会话（DELETE）
+func (s *UserServer) handleListing(w http.ResponseWriter, r *http.Request) {
+	id := strings.TrimPrefix(r.URL.Path, "/api/users/listings/")
+	if id == "" {
+		respondWithError(w, http.StatusBadRequest, "Missing listing ID")
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		q, err := parseUserQuery(r)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		page, err := s.service.SearchUsersInListing(id, q)
+		if err != nil {
+			respondWithServiceError(w, err)
+			return
+		}
+		respondWithJSON(w, http.StatusOK, newUserPageResponse(page))
+
+	case http.MethodDelete:
+		if err := s.service.CloseListing(id); err != nil {
+			respondWithServiceError(w, err)
+			return
+		}
+		respondWithJSON(w, http.StatusOK, map[string]string{"message": "Listing closed"})
+
+	default:
+		respondWithError(w, http.StatusMethodNotAllowed, "Method not allowed")
+	}
+}
+
+// handleSearch 按条件搜索用户，支持 ?q=&min_age=&max_age=&active=&page=&page_size=
+func (s *UserServer) handleSearch(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		respondWithError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	q, err := parseUserQuery(r)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	page, err := s.service.SearchUsers(q)
+	if err != nil {
+		respondWithServiceError(w, err)
+		return
+	}
+	respondWithJSON(w, http.StatusOK, newUserPageResponse(page))
+}
+
+// handleUserByID 处理单个用户请求
+func (s *UserServer) handleUserByID(w http.ResponseWriter, r *http.Request) {
+	idStr := strings.TrimPrefix(r.URL.Path, "/api/users/")
+	id, err := strconv.ParseUint(idStr, 10, 32)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid user ID")
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		user, err := s.service.GetUserByID(uint(id))
+		if err != nil {
+			respondWithServiceError(w, err)
+			return
+		}
+		respondWithJSON(w, http.StatusOK, newUserResponse(user))
+
+	case http.MethodPut:
+		var req updateUserRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			respondWithError(w, http.StatusBadRequest, "Invalid request payload")
+			return
+		}
+		defer r.Body.Close()
+
+		// 刚写入的数据可能尚未复制到从库，这里读取用于更新的数据也使用主库
+		user, err := s.service.GetUserByIDFromMaster(uint(id))
+		if err != nil {
+			respondWithServiceError(w, err)
+			return
+		}
+		if req.Username != nil {
+			user.Username = *req.Username
+		}
+		if req.Email != nil {
+			user.Email = *req.Email
+		}
+		if req.Age != nil {
+			user.Age = *req.Age
+		}
+		if req.Active != nil {
+			user.Active = *req.Active
+		}
+
+		if err := s.service.UpdateUser(user); err != nil {
+			respondWithServiceError(w, err)
+			return
+		}
+		respondWithJSON(w, http.StatusOK, newUserResponse(user))
+
+	case http.MethodDelete:
+		if err := s.service.DeleteUser(uint(id)); err != nil {
+			respondWithServiceError(w, err)
+			return
+		}
+		respondWithJSON(w, http.StatusOK, map[string]string{"message": "User deleted successfully"})
+
+	default:
+		respondWithError(w, http.StatusMethodNotAllowed, "Method not allowed")
+	}
+}
+
+// parseUserQuery 解析搜索参数 ?q=&min_age=&max_age=&active=&page=&page_size=
+func parseUserQuery(r *http.Request) (service.UserQuery, error) {
+	query := r.URL.Query()
+	q := service.UserQuery{
+		Keyword:  query.Get("q"),
+		MinAge:   atoiOrZero(query.Get("min_age")),
+		MaxAge:   atoiOrZero(query.Get("max_age")),
+		Page:     atoiOrZero(query.Get("page")),
+		PageSize: atoiOrZero(query.Get("page_size")),
+	}
+	if v := query.Get("active"); v != "" {
+		active, err := strconv.ParseBool(v)
+		if err != nil {
+			return q, errors.New("invalid active parameter")
+		}
+		q.Active = &active
+	}
+	return q, nil
+}
+
+// newUserResponse 将用户模型转换为响应结构（不包含密码）
+func newUserResponse(user *model.User) userResponse {
+	return userResponse{
+		ID:        user.ID,
+		Username:  user.Username,
+		Email:     user.Email,
+		Age:       user.Age,
+		Active:    user.Active,
+		CreatedAt: user.CreatedAt.Format(time.DateTime),
+		UpdatedAt: user.UpdatedAt.Format(time.DateTime),
+	}
+}
+
+// newUserPageResponse 将分页结果转换为响应结构
+func newUserPageResponse(page *service.UserPage) userPageResponse {
+	resp := userPageResponse{
+		Users:    make([]userResponse, 0, len(page.Users)),
+		Total:    page.Total,
+		Page:     page.Page,
+		PageSize: page.PageSize,
+	}
+	for i := range page.Users {
+		resp.Users = append(resp.Users, newUserResponse(&page.Users[i]))
+	}
+	return resp
+}
+
+// respondWithServiceError 根据服务层错误类型返回对应的状态码
+func respondWithServiceError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, service.ErrUserNotFound), errors.Is(err, db.ErrListingNotFound):
+		respondWithError(w, http.StatusNotFound, err.Error())
+	case errors.Is(err, db.ErrListingExpired):
+		respondWithError(w, http.StatusGone, err.Error())
+	case errors.Is(err, db.ErrTooManyListings):
+		respondWithError(w, http.StatusServiceUnavailable, err.Error())
+	case errors.Is(err, db.ErrMasterUnavailable):
+		respondWithError(w, http.StatusServiceUnavailable, err.Error())
+	case errors.Is(err, db.ErrQueryBudgetExceeded):
+		respondWithError(w, http.StatusTooManyRequests, err.Error())
+	default:
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+	}
+}
+
+// atoiOrZero 解析整数参数，无效时返回0（由服务层使用默认值）
+func atoiOrZero(s string) int {
+	n, err := strconv.Atoi(s)
+	if err != nil {
+		return 0
+	}
+	return n
+}
@@ -1,20 +1,73 @@
 package config
 
-import "fmt"
+import (
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+)
 
 // DBConfig 数据库配置
 type DBConfig struct {
+	Master   DBInfo   // 主库配置
+	Slaves   []DBInfo // 从库配置列表
+	Strategy string   // 从库选择策略名称（为空时使用延迟自适应策略）
+	// 路由配置档名称（如 local-demo、strict-consistency、max-throughput），设置后覆盖 Strategy
+	Profile string
+	// 默认读偏好（primary、primaryPreferred、secondary、secondaryPreferred、nearest），为空表示 secondaryPreferred
+	ReadPreference string
+	// 按服务名配置的读偏好，经 DBProxy.ForService 标记服务的读操作使用，覆盖 ReadPreference
+	ServiceReadPreferences map[string]string
+	// 声明为只读的存储过程（可带库名），调用它们的CALL语句按读操作路由；其他CALL语句和多语句批量都走主库
+	ReadOnlyProcedures []string
+	// 是否在从库会话上设置 transaction_read_only=1，由MySQL再做一层只读保护
+	ReadOnlySlaves bool
+	// 主库不可用时写入队列的容量，0表示不排队、直接返回 ErrMasterUnavailable
+	WriteQueueSize int
+	// 启动时各节点连通性探测的重试配置
+	ConnectRetry RetryConfig
+	// 是否允许部分节点不可用时降级启动：主库不可用时写操作快速失败，失败的节点在后台持续重试
+	DegradedStart bool
+	// 每个节点缓存的只读预处理语句数量上限（LRU淘汰），0表示不启用
+	StmtCacheSize int
+	// 经代理开启的事务持续超过该时长时记录告警（包括开启事务的调用栈），0表示不检测
+	LongTxThreshold time.Duration
+	// 从库复制延迟的检测间隔，0表示默认1秒，负数表示不检测（此时带有最大可容忍延迟的读操作都走主库）
+	LagCheckInterval time.Duration
+	// 携带一致性令牌的读操作等待从库追上的时长，超过后读主库，0表示默认100毫秒
+	ConsistencyWait time.Duration
+	// 租户列表，每个租户有独立的主从库，其余选项与默认库相同
+	Tenants []TenantConfig
+}
+
+// TenantConfig 单个租户（逻辑库）的主从库配置
+type TenantConfig struct {
+	Name   string   // 租户标识
 	Master DBInfo   // 主库配置
 	Slaves []DBInfo // 从库配置列表
 }
 
+// RetryConfig 连接重试配置，零值字段使用默认值
+type RetryConfig struct {
+	Attempts       int           // 最大尝试次数
+	InitialBackoff time.Duration // 首次重试前的等待时间
+	MaxBackoff     time.Duration // 重试等待时间上限（指数退避）
+}
+
 // DBInfo 单个数据库连接信息
 type DBInfo struct {
+	Name     string // 节点名称（可选，为空时自动生成）
 	Host     string // 主机地址
 	Port     int    // 端口号
 	User     string // 用户名
 	Password string // 密码
 	DBName   string // 数据库名
+	Weight   int    // 静态权重（用于weighted策略，默认为1）
+	Params   string // 额外的DSN参数（如 transaction_read_only=1）
 }
 
 // GetDefaultConfig 获取默认的数据库配置
@@ -44,11 +97,78 @@ func GetDefaultConfig() *DBConfig {
 				DBName:   "test_db2",
 			},
 		},
+		StmtCacheSize:   256,
+		LongTxThreshold: 5 * time.Second,
 	}
 }
 
+// ForTenant 生成租户的连接池配置：主从库取自租户配置，其余选项沿用当前配置
+func (c *DBConfig) ForTenant(tenant TenantConfig) *DBConfig {
+	cfg := *c
+	cfg.Master = tenant.Master
+	cfg.Slaves = tenant.Slaves
+	cfg.Tenants = nil
+	return &cfg
+}
+
 // GetDSN 根据数据库信息生成DSN连接字符串
 func (db DBInfo) GetDSN() string {
-	return fmt.Sprintf("%s:%s@tcp(%s:%d)/%s?charset=utf8mb4&parseTime=True&loc=Local",
+	dsn := fmt.Sprintf("%s:%s@tcp(%s:%d)/%s?charset=utf8mb4&parseTime=True&loc=Local",
 		db.User, db.Password, db.Host, db.Port, db.DBName)
+	if db.Params != "" {
+		dsn += "&" + db.Params
+	}
+	return dsn
+}
+
+// NodeName 获取节点名称，未配置时使用给定的默认名称
+func (db DBInfo) NodeName(fallback string) string {
+	if db.Name != "" {
+		return db.Name
+	}
+	return fallback
+}
+
+// ParseDSN 将MySQL DSN解析为数据库连接信息
+// charset、parseTime、loc 由 GetDSN 统一设置，超时参数和其他系统变量参数保留在 Params 中
+func ParseDSN(dsn string) (DBInfo, error) {
+	cfg, err := mysql.ParseDSN(dsn)
+	if err != nil {
+		return DBInfo{}, fmt.Errorf("invalid DSN: %w", err)
+	}
+	if cfg.Net != "tcp" {
+		return DBInfo{}, fmt.Errorf("unsupported DSN network %q, only tcp is supported", cfg.Net)
+	}
+
+	host, portStr, err := net.SplitHostPort(cfg.Addr)
+	if err != nil {
+		return DBInfo{}, fmt.Errorf("invalid DSN address %q: %w", cfg.Addr, err)
+	}
+	port, err := strconv.Atoi(portStr)
+	if err != nil {
+		return DBInfo{}, fmt.Errorf("invalid DSN port %q: %w", portStr, err)
+	}
+
+	params := make([]string, 0, len(cfg.Params))
+	for k, v := range cfg.Params {
+		if k == "charset" {
+			continue
+		}
+		params = append(params, k+"="+v)
+	}
+	for k, d := range map[string]time.Duration{"timeout": cfg.Timeout, "readTimeout": cfg.ReadTimeout, "writeTimeout": cfg.WriteTimeout} {
+		if d > 0 {
+			params = append(params, k+"="+d.String())
+		}
+	}
+	sort.Strings(params)
+
+	return DBInfo{
+		Host:     host,
+		Port:     port,
+		User:     cfg.User,
+		Password: cfg.Passwd,
+		DBName:   cfg.DBName,
+		Params:   strings.Join(params, "&"),
+	}, nil
 }
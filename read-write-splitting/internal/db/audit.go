@@ -0,0 +1,184 @@
+package db
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+type serviceContextKey struct{}
+
+// AuditRecord 一条语句审计记录
+type AuditRecord struct {
+	Time     time.Time `json:"time"`            // 执行时间
+	Node     string    `json:"node"`            // 执行节点
+	SQL      string    `json:"sql"`             // 规范化后的SQL（参数以占位符表示）
+	ArgsHash string    `json:"args_hash"`       // 参数的哈希值，避免记录敏感数据
+	Service  string    `json:"service"`         // 调用方服务名
+	Duration float64   `json:"duration_ms"`     // 执行耗时(毫秒)
+	Rows     int64     `json:"rows"`            // 影响或返回的行数
+	Error    string    `json:"error,omitempty"` // 错误信息
+}
+
+// AuditSink 审计记录的输出目标
+type AuditSink interface {
+	Write(record AuditRecord) error
+}
+
+// WithService 在上下文中标记调用方服务名，会出现在审计记录中
+func WithService(ctx context.Context, service string) context.Context {
+	return context.WithValue(ctx, serviceContextKey{}, service)
+}
+
+// ServiceFromContext 从上下文中获取调用方服务名
+func ServiceFromContext(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	service, _ := ctx.Value(serviceContextKey{}).(string)
+	return service
+}
+
+// Auditor 语句审计器，将每条执行过的语句分发给所有输出目标
+type Auditor struct {
+	sinks []AuditSink  // 输出目标
+	mu    sync.RWMutex // 保护sinks
+}
+
+// NewAuditor 创建新的审计器
+func NewAuditor() *Auditor {
+	return &Auditor{}
+}
+
+// AddSink 添加审计输出目标
+func (a *Auditor) AddSink(sink AuditSink) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.sinks = append(a.sinks, sink)
+}
+
+// enabled 是否配置了输出目标
+func (a *Auditor) enabled() bool {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return len(a.sinks) > 0
+}
+
+// record 将审计记录写入所有输出目标
+func (a *Auditor) record(record AuditRecord) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	for _, sink := range a.sinks {
+		if err := sink.Write(record); err != nil {
+			log.Printf("failed to write audit record: %v", err)
+		}
+	}
+}
+
+// ObserveStatement 实现StatementObserver接口，为每条语句生成审计记录
+func (a *Auditor) ObserveStatement(event StatementEvent) {
+	if !a.enabled() {
+		return
+	}
+
+	record := AuditRecord{
+		Time:     event.Time,
+		Node:     event.Node,
+		SQL:      normalizeSQL(event.SQL),
+		ArgsHash: hashArgs(event.Vars),
+		Service:  ServiceFromContext(event.Context),
+		Duration: float64(event.Duration) / float64(time.Millisecond),
+		Rows:     event.Rows,
+	}
+	if event.Err != nil {
+		record.Error = event.Err.Error()
+	}
+	a.record(record)
+}
+
+// normalizeSQL 折叠多余的空白字符
+func normalizeSQL(sql string) string {
+	return strings.Join(strings.Fields(sql), " ")
+}
+
+// hashArgs 计算参数列表的短哈希
+func hashArgs(vars []interface{}) string {
+	if len(vars) == 0 {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%#v", vars)))
+	return hex.EncodeToString(sum[:8])
+}
+
+// FileAuditSink 以JSON Lines格式将审计记录追加到文件
+type FileAuditSink struct {
+	file *os.File
+	mu   sync.Mutex
+}
+
+// NewFileAuditSink 打开（或创建）审计日志文件
+func NewFileAuditSink(path string) (*FileAuditSink, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open audit log file: %w", err)
+	}
+	return &FileAuditSink{file: file}, nil
+}
+
+// Write 写入一条审计记录
+func (s *FileAuditSink) Write(record AuditRecord) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, err = s.file.Write(append(data, '\n'))
+	return err
+}
+
+// Close 关闭审计日志文件
+func (s *FileAuditSink) Close() error {
+	return s.file.Close()
+}
+
+// ChannelAuditSink 将审计记录发送到通道，通道满时丢弃并计数，不阻塞查询
+type ChannelAuditSink struct {
+	ch      chan AuditRecord
+	dropped int64
+}
+
+// NewChannelAuditSink 创建带缓冲的通道输出目标
+func NewChannelAuditSink(buffer int) *ChannelAuditSink {
+	return &ChannelAuditSink{ch: make(chan AuditRecord, buffer)}
+}
+
+// Write 发送一条审计记录
+func (s *ChannelAuditSink) Write(record AuditRecord) error {
+	select {
+	case s.ch <- record:
+	default:
+		atomic.AddInt64(&s.dropped, 1)
+	}
+	return nil
+}
+
+// Records 获取接收审计记录的通道
+func (s *ChannelAuditSink) Records() <-chan AuditRecord {
+	return s.ch
+}
+
+// Dropped 获取因通道已满而丢弃的记录数
+func (s *ChannelAuditSink) Dropped() int64 {
+	return atomic.LoadInt64(&s.dropped)
+}
@@ -0,0 +1,280 @@
+package db
+
+import (
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// masterProbeInterval 主库不可用期间探测恢复的间隔
+const masterProbeInterval = time.Second
+
+// ErrMasterUnavailable 主库不可用
+var ErrMasterUnavailable = errors.New("master unavailable")
+
+// ErrWriteQueueFull 写入队列已满
+var ErrWriteQueueFull = errors.New("write queue full")
+
+// MasterUnavailableError 主库不可用错误，携带故障开始时间和最初的驱动错误
+type MasterUnavailableError struct {
+	Node  string    // 主库节点名称
+	Since time.Time // 不可用开始时间
+	Cause error     // 触发不可用判定的错误
+}
+
+func (e *MasterUnavailableError) Error() string {
+	return fmt.Sprintf("master %s unavailable since %s: %v", e.Node, e.Since.Format(time.RFC3339), e.Cause)
+}
+
+// Unwrap 使 errors.Is(err, ErrMasterUnavailable) 成立
+func (e *MasterUnavailableError) Unwrap() error {
+	return ErrMasterUnavailable
+}
+
+// WriteFunc 可排队执行的写操作
+type WriteFunc func(db *gorm.DB) error
+
+// MasterStatus 主库可用性状态
+type MasterStatus struct {
+	Available     bool      `json:"available"`      // 主库是否可用
+	Since         time.Time `json:"since"`          // 当前状态开始时间
+	LastError     string    `json:"last_error"`     // 最近一次连接错误
+	QueueLength   int       `json:"queue_length"`   // 排队中的写操作数
+	QueueCapacity int       `json:"queue_capacity"` // 写入队列容量（0表示未启用）
+	Flushed       int64     `json:"flushed"`        // 恢复后已重放的写操作数
+	Failed        int64     `json:"failed"`         // 重放失败被丢弃的写操作数
+}
+
+// masterAvailability 跟踪主库可用性：连接错误触发快速失败，后台探测恢复后重放排队的写操作
+type masterAvailability struct {
+	pool      *DBPool
+	available bool
+	since     time.Time
+	cause     error
+	queue     []WriteFunc
+	capacity  int
+	flushed   int64
+	failed    int64
+	probing   bool
+	mu        sync.Mutex
+}
+
+// newMasterAvailability 创建主库可用性跟踪器，capacity为0时不启用写入队列
+func newMasterAvailability(pool *DBPool, capacity int) *masterAvailability {
+	return &masterAvailability{
+		pool:      pool,
+		available: true,
+		since:     time.Now(),
+		capacity:  capacity,
+	}
+}
+
+// isConnectionError 判断错误是否由连接故障引起（而非SQL本身的错误）
+func isConnectionError(err error) bool {
+	if err == nil {
+		return false
+	}
+	if errors.Is(err, driver.ErrBadConn) {
+		return true
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return true
+	}
+	msg := strings.ToLower(err.Error())
+	for _, s := range []string{"invalid connection", "connection refused", "broken pipe", "connection reset", "bad connection"} {
+		if strings.Contains(msg, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// unavailableError 主库不可用时返回的错误，可用时返回nil
+func (a *masterAvailability) unavailableError() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.available {
+		return nil
+	}
+	return &MasterUnavailableError{Node: a.pool.masterNode().Name, Since: a.since, Cause: a.cause}
+}
+
+// markDown 将主库标记为不可用并启动恢复探测
+func (a *masterAvailability) markDown(cause error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.available {
+		a.available = false
+		a.since = time.Now()
+		log.Printf("Master marked unavailable: %v", cause)
+	}
+	a.cause = cause
+	if !a.probing {
+		a.probing = true
+		go a.probe()
+	}
+}
+
+// markUp 将主库标记为可用（例如替换主库后）
+func (a *masterAvailability) markUp() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if !a.available {
+		a.available = true
+		a.since = time.Now()
+		a.cause = nil
+		log.Println("Master marked available")
+	}
+}
+
+// probe 定期探测主库，恢复后重放排队的写操作
+func (a *masterAvailability) probe() {
+	ticker := time.NewTicker(masterProbeInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		a.mu.Lock()
+		available := a.available
+		a.mu.Unlock()
+
+		if !available {
+			sqlDB, err := a.pool.Master().DB()
+			if err == nil {
+				err = sqlDB.Ping()
+			}
+			if err != nil {
+				continue
+			}
+			a.markUp()
+		}
+
+		// 重放期间再次失败会重新标记为不可用，此时继续探测
+		if a.flush() {
+			a.mu.Lock()
+			a.probing = false
+			a.mu.Unlock()
+			return
+		}
+	}
+}
+
+// flush 按入队顺序重放写操作，全部完成返回true
+func (a *masterAvailability) flush() bool {
+	for {
+		a.mu.Lock()
+		if !a.available {
+			a.mu.Unlock()
+			return false
+		}
+		if len(a.queue) == 0 {
+			a.mu.Unlock()
+			return true
+		}
+		fn := a.queue[0]
+		a.mu.Unlock()
+
+		err := fn(a.pool.Master())
+		if isConnectionError(err) || errors.Is(err, ErrMasterUnavailable) {
+			return false
+		}
+
+		a.mu.Lock()
+		a.queue = a.queue[1:]
+		if err != nil {
+			a.failed++
+			log.Printf("Queued write failed and was dropped: %v", err)
+		} else {
+			a.flushed++
+		}
+		a.mu.Unlock()
+	}
+}
+
+// enqueue 将写操作加入队列
+func (a *masterAvailability) enqueue(fn WriteFunc) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if len(a.queue) >= a.capacity {
+		return fmt.Errorf("%w: capacity %d", ErrWriteQueueFull, a.capacity)
+	}
+	a.queue = append(a.queue, fn)
+	return nil
+}
+
+// status 获取主库可用性状态
+func (a *masterAvailability) status() MasterStatus {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	status := MasterStatus{
+		Available:     a.available,
+		Since:         a.since,
+		QueueLength:   len(a.queue),
+		QueueCapacity: a.capacity,
+		Flushed:       a.flushed,
+		Failed:        a.failed,
+	}
+	if a.cause != nil {
+		status.LastError = a.cause.Error()
+	}
+	return status
+}
+
+// attachMasterGuard 在主库节点上注册快速失败和连接错误检测回调
+func (p *DBPool) attachMasterGuard(node *Node) error {
+	guard := func(db *gorm.DB) {
+		if err := p.availability.unavailableError(); err != nil {
+			db.AddError(err)
+		}
+	}
+	detect := func(db *gorm.DB) {
+		if isConnectionError(db.Error) {
+			p.availability.markDown(db.Error)
+		}
+	}
+
+	cb := node.DB.Callback()
+	return errors.Join(
+		cb.Create().Before("gorm:create").Register("rws:master_guard", guard),
+		cb.Create().After("gorm:create").Register("rws:master_detect", detect),
+		cb.Query().Before("gorm:query").Register("rws:master_guard", guard),
+		cb.Query().After("gorm:query").Register("rws:master_detect", detect),
+		cb.Update().Before("gorm:update").Register("rws:master_guard", guard),
+		cb.Update().After("gorm:update").Register("rws:master_detect", detect),
+		cb.Delete().Before("gorm:delete").Register("rws:master_guard", guard),
+		cb.Delete().After("gorm:delete").Register("rws:master_detect", detect),
+		cb.Row().Before("gorm:row").Register("rws:master_guard", guard),
+		cb.Row().After("gorm:row").Register("rws:master_detect", detect),
+		cb.Raw().Before("gorm:raw").Register("rws:master_guard", guard),
+		cb.Raw().After("gorm:raw").Register("rws:master_detect", detect),
+	)
+}
+
+// Write 在主库上执行写操作
+// 主库不可用且启用了写入队列时，写操作进入队列并在主库恢复后按顺序执行，此时返回 queued=true
+func (p *DBPool) Write(fn WriteFunc) (queued bool, err error) {
+	if unavailable := p.availability.unavailableError(); unavailable != nil {
+		if p.availability.capacity == 0 {
+			return false, unavailable
+		}
+		if err := p.availability.enqueue(fn); err != nil {
+			return false, err
+		}
+		return true, nil
+	}
+	return false, fn(p.Master())
+}
+
+// MasterStatus 获取主库可用性状态
+func (p *DBPool) MasterStatus() MasterStatus {
+	return p.availability.status()
+}
@@ -0,0 +1,204 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"reflect"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// 批量写入的默认参数
+const (
+	defaultBatchChunkSize = 100
+	defaultBatchRetries   = 3
+	defaultBatchBackoff   = 100 * time.Millisecond
+)
+
+// 可重试的MySQL错误码：语句已被整体回滚，重新执行是安全的
+const (
+	mysqlErrLockWaitTimeout = 1205 // ER_LOCK_WAIT_TIMEOUT
+	mysqlErrDeadlock        = 1213 // ER_LOCK_DEADLOCK
+)
+
+// BatchOptions 批量写入参数，零值字段使用默认值
+type BatchOptions struct {
+	ChunkSize       int           // 每批条数（默认100）
+	Retries         int           // 每批遇到临时错误时的最大重试次数（默认3，负数表示不重试）
+	Backoff         time.Duration // 首次重试前的等待时间，之后每次翻倍（默认100ms）
+	ContinueOnError bool          // 某批最终失败后是否继续执行后续批次（默认停止）
+}
+
+// withDefaults 填充批量写入参数的默认值
+func (o BatchOptions) withDefaults() BatchOptions {
+	if o.ChunkSize <= 0 {
+		o.ChunkSize = defaultBatchChunkSize
+	}
+	if o.Retries == 0 {
+		o.Retries = defaultBatchRetries
+	} else if o.Retries < 0 {
+		o.Retries = 0
+	}
+	if o.Backoff <= 0 {
+		o.Backoff = defaultBatchBackoff
+	}
+	return o
+}
+
+// ChunkResult 单个批次的执行结果
+type ChunkResult struct {
+	Index      int     `json:"index"`           // 批次序号（从0开始）
+	Offset     int     `json:"offset"`          // 批次第一条在输入切片中的下标
+	Size       int     `json:"size"`            // 批次条数
+	Rows       int64   `json:"rows"`            // 影响的行数
+	Attempts   int     `json:"attempts"`        // 执行次数（含重试）
+	DurationMs float64 `json:"duration_ms"`     // 总耗时(毫秒，含重试等待)
+	Err        error   `json:"-"`               // 最终错误
+	Error      string  `json:"error,omitempty"` // 最终错误（文本）
+}
+
+// BatchResult 批量写入的汇总结果
+type BatchResult struct {
+	Chunks    []ChunkResult `json:"chunks"`    // 已执行的批次（停止后的批次不出现）
+	Rows      int64         `json:"rows"`      // 总影响行数
+	Succeeded int           `json:"succeeded"` // 成功的批次数
+	Failed    int           `json:"failed"`    // 失败的批次数
+	Skipped   int           `json:"skipped"`   // 因前面的批次失败而未执行的批次数
+}
+
+// CreateInBatches 将切片按批次插入主库，每批一条多行INSERT
+// 死锁和锁等待超时会重试；连接错误时无法确定语句是否已提交，为避免重复插入不重试（见 UpsertInBatches）
+// 成功插入的元素会回填自增主键；返回的错误合并了所有失败批次的错误
+func (p *DBProxy) CreateInBatches(values interface{}, opts BatchOptions) (BatchResult, error) {
+	return p.writeInBatches(values, opts, false, func(db *gorm.DB, chunk interface{}) *gorm.DB {
+		return db.Create(chunk)
+	})
+}
+
+// UpsertInBatches 将切片按批次写入主库（INSERT ... ON DUPLICATE KEY UPDATE），
+// updateColumns为冲突时更新的列，为空时更新全部列
+// 语句是幂等的，因此连接错误也会重试
+func (p *DBProxy) UpsertInBatches(values interface{}, updateColumns []string, opts BatchOptions) (BatchResult, error) {
+	onConflict := clause.OnConflict{UpdateAll: true}
+	if len(updateColumns) > 0 {
+		onConflict = clause.OnConflict{DoUpdates: clause.AssignmentColumns(updateColumns)}
+	}
+	return p.writeInBatches(values, opts, true, func(db *gorm.DB, chunk interface{}) *gorm.DB {
+		return db.Clauses(onConflict).Create(chunk)
+	})
+}
+
+// writeInBatches 按批次执行写操作，idempotent表示连接错误时重试是否安全
+func (p *DBProxy) writeInBatches(values interface{}, opts BatchOptions, idempotent bool, write func(db *gorm.DB, chunk interface{}) *gorm.DB) (BatchResult, error) {
+	var result BatchResult
+	if p.err != nil {
+		return result, p.err
+	}
+
+	rv := reflect.ValueOf(values)
+	if rv.Kind() == reflect.Ptr {
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Slice {
+		return result, fmt.Errorf("batch write requires a slice, got %T", values)
+	}
+	opts = opts.withDefaults()
+
+	total := rv.Len()
+	chunks := (total + opts.ChunkSize - 1) / opts.ChunkSize
+	var errs []error
+	for i := 0; i < chunks; i++ {
+		offset := i * opts.ChunkSize
+		end := offset + opts.ChunkSize
+		if end > total {
+			end = total
+		}
+
+		// 切片视图与原切片共享底层数组，GORM回填的主键会写回调用方的元素
+		chunk := p.writeChunk(rv.Slice(offset, end).Interface(), opts, idempotent, write)
+		chunk.Index, chunk.Offset, chunk.Size = i, offset, end-offset
+		result.Chunks = append(result.Chunks, chunk)
+		result.Rows += chunk.Rows
+
+		if chunk.Err == nil {
+			result.Succeeded++
+			continue
+		}
+		result.Failed++
+		errs = append(errs, fmt.Errorf("chunk %d (rows %d-%d): %w", i, offset, end-1, chunk.Err))
+		if !opts.ContinueOnError {
+			result.Skipped = chunks - i - 1
+			break
+		}
+	}
+
+	if len(errs) > 0 {
+		log.Printf("Batch write finished with %d/%d failed chunks (%d skipped)", result.Failed, chunks, result.Skipped)
+	}
+	return result, errors.Join(errs...)
+}
+
+// writeChunk 执行一个批次，遇到临时错误按指数退避重试
+func (p *DBProxy) writeChunk(chunk interface{}, opts BatchOptions, idempotent bool, write func(db *gorm.DB, chunk interface{}) *gorm.DB) ChunkResult {
+	var result ChunkResult
+	start := time.Now()
+	backoff := opts.Backoff
+
+	for {
+		result.Attempts++
+		tx := write(p.Master(), chunk)
+		result.Rows, result.Err = tx.RowsAffected, tx.Error
+		if result.Err == nil || result.Attempts > opts.Retries || !isTransientWriteError(result.Err, idempotent) {
+			break
+		}
+
+		log.Printf("Batch chunk attempt %d failed, retrying in %v: %v", result.Attempts, backoff, result.Err)
+		if err := p.sleep(backoff); err != nil {
+			result.Err = err
+			break
+		}
+		backoff *= 2
+	}
+
+	result.DurationMs = float64(time.Since(start).Microseconds()) / 1000
+	if result.Err != nil {
+		result.Rows = 0
+		result.Error = result.Err.Error()
+	}
+	return result
+}
+
+// sleep 等待指定时间，代理上的上下文取消时提前返回其错误
+func (p *DBProxy) sleep(d time.Duration) error {
+	ctx := p.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// isTransientWriteError 判断写入错误是否可以重试
+// 死锁、锁等待超时（语句已回滚）和主库快速失败（语句未发出）总是可以重试；
+// 连接错误只有在写操作幂等时才重试
+func isTransientWriteError(err error, idempotent bool) bool {
+	if errors.Is(err, ErrMasterUnavailable) {
+		return true
+	}
+	var mysqlErr *mysql.MySQLError
+	if errors.As(err, &mysqlErr) {
+		return mysqlErr.Number == mysqlErrDeadlock || mysqlErr.Number == mysqlErrLockWaitTimeout
+	}
+	return idempotent && isConnectionError(err)
+}
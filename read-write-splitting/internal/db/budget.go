@@ -0,0 +1,197 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ErrQueryBudgetExceeded 请求的查询预算已耗尽
+var ErrQueryBudgetExceeded = errors.New("query budget exceeded")
+
+// budgetStartKey 在GORM语句实例中记录预算计时开始时间的键
+const budgetStartKey = "rws:budget_start"
+
+type budgetContextKey struct{}
+
+// QueryBudget 单个请求的查询预算，用于发现N+1等查询模式
+type QueryBudget struct {
+	RequestID  string        // 请求标识，用于日志关联
+	MaxQueries int           // 最大查询次数（0表示不限制）
+	MaxDBTime  time.Duration // 最大数据库总耗时（0表示不限制）
+
+	mu       sync.Mutex
+	queries  int            // 已执行查询次数
+	dbTime   time.Duration  // 已消耗的数据库时间
+	rejected int            // 被拒绝的查询次数
+	sites    map[string]int // 超出预算时的调用位置统计
+}
+
+// BudgetExceededError 查询预算超限的详细错误
+type BudgetExceededError struct {
+	RequestID string        // 请求标识
+	Queries   int           // 已执行查询次数
+	DBTime    time.Duration // 已消耗的数据库时间
+	Reason    string        // 超限原因
+	CallSite  string        // 触发超限的调用位置
+}
+
+// Error 实现error接口
+func (e *BudgetExceededError) Error() string {
+	return fmt.Sprintf("query budget exceeded for request %s: %s (queries=%d, db_time=%v) at %s",
+		e.RequestID, e.Reason, e.Queries, e.DBTime, e.CallSite)
+}
+
+// Unwrap 支持errors.Is(err, ErrQueryBudgetExceeded)
+func (e *BudgetExceededError) Unwrap() error {
+	return ErrQueryBudgetExceeded
+}
+
+// NewQueryBudget 创建新的查询预算
+func NewQueryBudget(requestID string, maxQueries int, maxDBTime time.Duration) *QueryBudget {
+	return &QueryBudget{
+		RequestID:  requestID,
+		MaxQueries: maxQueries,
+		MaxDBTime:  maxDBTime,
+		sites:      make(map[string]int),
+	}
+}
+
+// WithQueryBudget 将查询预算附加到上下文
+func WithQueryBudget(ctx context.Context, budget *QueryBudget) context.Context {
+	return context.WithValue(ctx, budgetContextKey{}, budget)
+}
+
+// BudgetFromContext 从上下文中获取查询预算
+func BudgetFromContext(ctx context.Context) *QueryBudget {
+	if ctx == nil {
+		return nil
+	}
+	budget, _ := ctx.Value(budgetContextKey{}).(*QueryBudget)
+	return budget
+}
+
+// Usage 获取预算使用情况
+func (b *QueryBudget) Usage() (queries int, dbTime time.Duration, rejected int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.queries, b.dbTime, b.rejected
+}
+
+// CallSites 获取超出预算时记录的调用位置及次数
+func (b *QueryBudget) CallSites() map[string]int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	sites := make(map[string]int, len(b.sites))
+	for site, count := range b.sites {
+		sites[site] = count
+	}
+	return sites
+}
+
+// acquire 在执行查询前检查预算，超限时返回错误
+func (b *QueryBudget) acquire() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	reason := ""
+	switch {
+	case b.MaxQueries > 0 && b.queries >= b.MaxQueries:
+		reason = fmt.Sprintf("max queries %d reached", b.MaxQueries)
+	case b.MaxDBTime > 0 && b.dbTime >= b.MaxDBTime:
+		reason = fmt.Sprintf("max db time %v reached", b.MaxDBTime)
+	}
+
+	if reason == "" {
+		b.queries++
+		return nil
+	}
+
+	site := callerSite()
+	b.rejected++
+	b.sites[site]++
+	log.Printf("[request %s] query rejected: %s, call site: %s", b.RequestID, reason, site)
+
+	return &BudgetExceededError{
+		RequestID: b.RequestID,
+		Queries:   b.queries,
+		DBTime:    b.dbTime,
+		Reason:    reason,
+		CallSite:  site,
+	}
+}
+
+// release 在查询完成后累计耗时
+func (b *QueryBudget) release(elapsed time.Duration) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.dbTime += elapsed
+}
+
+// callerSite 查找调用栈中第一个不属于GORM或本包的调用位置
+func callerSite() string {
+	pcs := make([]uintptr, 32)
+	n := runtime.Callers(3, pcs)
+	frames := runtime.CallersFrames(pcs[:n])
+	for {
+		frame, more := frames.Next()
+		if !strings.Contains(frame.File, "gorm.io/") &&
+			!strings.Contains(frame.File, "read-write-splitting/internal/db/") &&
+			!strings.HasPrefix(frame.Function, "runtime.") {
+			return fmt.Sprintf("%s:%d", frame.File, frame.Line)
+		}
+		if !more {
+			return "unknown"
+		}
+	}
+}
+
+// registerBudgetCallbacks 在连接上注册查询预算的检查与计时回调
+func registerBudgetCallbacks(db *gorm.DB) error {
+	before := func(db *gorm.DB) {
+		budget := BudgetFromContext(db.Statement.Context)
+		if budget == nil {
+			return
+		}
+		if err := budget.acquire(); err != nil {
+			db.AddError(err)
+			return
+		}
+		db.InstanceSet(budgetStartKey, time.Now())
+	}
+	after := func(db *gorm.DB) {
+		budget := BudgetFromContext(db.Statement.Context)
+		if budget == nil {
+			return
+		}
+		if v, ok := db.InstanceGet(budgetStartKey); ok {
+			if startAt, ok := v.(time.Time); ok {
+				budget.release(time.Since(startAt))
+			}
+		}
+	}
+
+	cb := db.Callback()
+	return errors.Join(
+		cb.Create().Before("gorm:create").Register("rws:budget_check", before),
+		cb.Create().After("gorm:create").Register("rws:budget_release", after),
+		cb.Query().Before("gorm:query").Register("rws:budget_check", before),
+		cb.Query().After("gorm:query").Register("rws:budget_release", after),
+		cb.Update().Before("gorm:update").Register("rws:budget_check", before),
+		cb.Update().After("gorm:update").Register("rws:budget_release", after),
+		cb.Delete().Before("gorm:delete").Register("rws:budget_check", before),
+		cb.Delete().After("gorm:delete").Register("rws:budget_release", after),
+		cb.Row().Before("gorm:row").Register("rws:budget_check", before),
+		cb.Row().After("gorm:row").Register("rws:budget_release", after),
+		cb.Raw().Before("gorm:raw").Register("rws:budget_check", before),
+		cb.Raw().After("gorm:raw").Register("rws:budget_release", after),
+	)
+}
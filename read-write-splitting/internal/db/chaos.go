@@ -0,0 +1,130 @@
+package db
+
+import (
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ErrChaosInjected 由混沌注入产生的错误
+var ErrChaosInjected = errors.New("chaos injected error")
+
+// ChaosRule 单个节点的混沌注入规则
+type ChaosRule struct {
+	Node      string        `json:"node"`       // 节点名称
+	Latency   time.Duration `json:"-"`          // 每条语句额外增加的延迟
+	LatencyMs int64         `json:"latency_ms"` // 延迟（毫秒，便于JSON配置）
+	ErrorRate float64       `json:"error_rate"` // 语句失败的概率（0~1）
+	ConnError bool          `json:"conn_error"` // 注入的错误是否表现为连接错误（会触发主库快速失败等连接故障处理）
+}
+
+// ChaosInjector 按节点注入人为延迟和错误，用于观察延迟策略、主库快速失败等机制的反应
+type ChaosInjector struct {
+	rules map[string]ChaosRule
+	mu    sync.RWMutex
+}
+
+// NewChaosInjector 创建混沌注入器
+func NewChaosInjector() *ChaosInjector {
+	return &ChaosInjector{rules: make(map[string]ChaosRule)}
+}
+
+// SetRule 设置节点的注入规则
+func (c *ChaosInjector) SetRule(rule ChaosRule) error {
+	if rule.Node == "" {
+		return errors.New("chaos rule requires a node name")
+	}
+	if rule.ErrorRate < 0 || rule.ErrorRate > 1 {
+		return fmt.Errorf("error rate must be between 0 and 1, got %v", rule.ErrorRate)
+	}
+	if rule.Latency == 0 {
+		rule.Latency = time.Duration(rule.LatencyMs) * time.Millisecond
+	}
+	rule.LatencyMs = rule.Latency.Milliseconds()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.rules[rule.Node] = rule
+	return nil
+}
+
+// ClearRule 清除节点的注入规则，node为空时清除全部
+func (c *ChaosInjector) ClearRule(node string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if node == "" {
+		c.rules = make(map[string]ChaosRule)
+		return
+	}
+	delete(c.rules, node)
+}
+
+// Rules 获取所有注入规则
+func (c *ChaosInjector) Rules() []ChaosRule {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	rules := make([]ChaosRule, 0, len(c.rules))
+	for _, r := range c.rules {
+		rules = append(rules, r)
+	}
+	sort.Slice(rules, func(i, j int) bool { return rules[i].Node < rules[j].Node })
+	return rules
+}
+
+// ruleFor 获取节点的注入规则
+func (c *ChaosInjector) ruleFor(node string) (ChaosRule, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	rule, ok := c.rules[node]
+	return rule, ok
+}
+
+// attachChaos 在节点上注册混沌注入回调
+// 回调排在延迟采集之后，注入的延迟会计入节点的平均延迟
+func (p *DBPool) attachChaos(node *Node) error {
+	inject := func(db *gorm.DB) {
+		rule, ok := p.chaos.ruleFor(node.Name)
+		if !ok {
+			return
+		}
+
+		if rule.Latency > 0 {
+			select {
+			case <-time.After(rule.Latency):
+			case <-db.Statement.Context.Done():
+				db.AddError(db.Statement.Context.Err())
+				return
+			}
+		}
+
+		if rule.ErrorRate > 0 && rand.Float64() < rule.ErrorRate {
+			err := fmt.Errorf("%w on node %s", ErrChaosInjected, node.Name)
+			if rule.ConnError {
+				err = fmt.Errorf("%w: %w", err, driver.ErrBadConn)
+			}
+			db.AddError(err)
+		}
+	}
+
+	cb := node.DB.Callback()
+	return errors.Join(
+		cb.Create().Before("gorm:create").After("rws:latency_start").Register("rws:chaos", inject),
+		cb.Query().Before("gorm:query").After("rws:latency_start").Register("rws:chaos", inject),
+		cb.Update().Before("gorm:update").After("rws:latency_start").Register("rws:chaos", inject),
+		cb.Delete().Before("gorm:delete").After("rws:latency_start").Register("rws:chaos", inject),
+		cb.Row().Before("gorm:row").After("rws:latency_start").Register("rws:chaos", inject),
+		cb.Raw().Before("gorm:raw").After("rws:latency_start").Register("rws:chaos", inject),
+	)
+}
+
+// Chaos 获取混沌注入器
+func (p *DBPool) Chaos() *ChaosInjector {
+	return p.chaos
+}
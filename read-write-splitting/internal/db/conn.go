@@ -1,57 +1,199 @@
 package db
 
 import (
+	"errors"
 	"fmt"
 	"log"
 	"read-write-splitting/internal/config"
+	"sync"
 	"sync/atomic"
+	"time"
 
 	"gorm.io/driver/mysql"
 	"gorm.io/gorm"
 	"gorm.io/gorm/logger"
 )
 
+// masterDrainTimeout 替换主库后等待旧连接上在途查询完成的最长时间
+const masterDrainTimeout = 30 * time.Second
+
 // DBPool 数据库连接池
 type DBPool struct {
-	master     *gorm.DB         // 主库连接
-	slaves     []*gorm.DB       // 从库连接列表
-	slaveCount int32            // 从库数量
-	current    int32            // 当前使用的从库索引，用于轮询
-	config     *config.DBConfig // 数据库配置
+	master    *Node               // 主库节点
+	slaves    []*Node             // 从库节点列表
+	strategy  SelectionStrategy   // 从库选择策略
+	auditor   *Auditor            // 语句审计器
+	digests   *DigestCollector    // SQL指纹统计
+	observers []StatementObserver // 语句执行观察者
+	config    *config.DBConfig    // 数据库配置
+	mu        sync.RWMutex        // 保护主库节点、策略等可变字段
+
+	availability         *masterAvailability      // 主库可用性跟踪（快速失败与写入队列）
+	listings             *ListingManager          // 一致性分页会话
+	txs                  *TxTracker               // 经代理开启的事务（长事务检测）
+	rewrites             *RewriteChain            // SQL改写钩子链（通过SQLRouter管理）
+	chaos                *ChaosInjector           // 混沌注入（测试用）
+	detached             map[string]*Node         // 暂不在轮询中、后台重连中的从库（按名称）
+	drained              map[string]*Node         // 运维手动摘除的从库（按名称），见DrainSlave
+	forceMasterReads     atomic.Bool              // 是否强制所有读操作走主库
+	reconnect            config.RetryConfig       // 从库断开后后台重连的退避（路由配置档可修改）
+	profile              string                   // 当前生效的路由配置档名称
+	fallbacks            int64                    // 读操作因没有可用从库而使用主库的次数
+	staleFallbacks       int64                    // 读操作因从库延迟超过最大可容忍延迟而使用主库的次数
+	consistencyFallbacks int64                    // 携带一致性令牌的读操作因没有从库追上而使用主库的次数
+	startup              StartupReport            // 启动连通性报告
+	events               []PoolEvent              // 最近的从库移出/重新加入事件
+	listeners            []PoolEventListener      // 节点事件监听者
+	invalidation         *InvalidationBroadcaster // 缓存失效广播（可选），见 EnableInvalidation
+	stop                 chan struct{}            // 停止后台任务的信号
+}
+
+// PoolStats 连接池统计信息
+type PoolStats struct {
+	Strategy string      // 当前选择策略
+	Master   NodeStats   // 主库统计
+	Slaves   []NodeStats // 从库统计（包含生效权重）
 }
 
 // NewDBPool 创建新的数据库连接池
 func NewDBPool(config *config.DBConfig) (*DBPool, error) {
+	strategy, err := NewStrategy(config.Strategy)
+	if err != nil {
+		return nil, err
+	}
+	if err := validateReadPreferences(config); err != nil {
+		return nil, err
+	}
+
 	pool := &DBPool{
-		config: config,
+		config:   config,
+		strategy: strategy,
+		auditor:  NewAuditor(),
+		digests:  NewDigestCollector(),
+		startup:  StartupReport{StartedAt: time.Now()},
+		stop:     make(chan struct{}),
+		chaos:    NewChaosInjector(),
+		rewrites: NewRewriteChain(),
+		detached: make(map[string]*Node),
+		drained:  make(map[string]*Node),
+	}
+	pool.reconnect = config.ConnectRetry
+	if config.Profile != "" {
+		if err := pool.ApplyProfile(config.Profile); err != nil {
+			return nil, err
+		}
 	}
+	pool.observers = []StatementObserver{pool.auditor, pool.digests}
+	pool.availability = newMasterAvailability(pool, config.WriteQueueSize)
+	pool.listings = NewListingManager(pool)
+	pool.txs = newTxTracker(pool, config.LongTxThreshold)
+	retry := withRetryDefaults(config.ConnectRetry)
 
-	// 初始化主库连接
+	// 初始化主库连接，启动时显式探测连通性
 	masterDB, err := connectDB(config.Master)
 	if err != nil {
 		return nil, fmt.Errorf("failed to connect to master DB: %w", err)
 	}
-	pool.master = masterDB
+	pool.master = pool.addNode(config.Master, "master", masterDB)
+	if err := pool.attachMasterGuard(pool.master); err != nil {
+		log.Printf("failed to register master guard on node %s: %v", pool.master.Name, err)
+	}
+	masterResult := probeNode(pool.master, "master", config.Master, retry)
+	pool.startup.add(masterResult)
+	if !masterResult.Connected {
+		if !config.DegradedStart {
+			pool.master.close()
+			return nil, fmt.Errorf("failed to connect to master DB after %d attempts: %s", masterResult.Attempts, masterResult.Error)
+		}
+		// 降级启动：主库标记为不可用，写操作快速失败，后台探测恢复
+		pool.availability.markDown(errors.New(masterResult.Error))
+	}
 
 	// 初始化从库连接
-	pool.slaves = make([]*gorm.DB, 0, len(config.Slaves))
+	pool.slaves = make([]*Node, 0, len(config.Slaves))
 	for i, slaveConfig := range config.Slaves {
-		slaveDB, err := connectDB(slaveConfig)
+		node, slaveConfig, err := pool.connectSlave(slaveConfig, fmt.Sprintf("slave-%d", i))
 		if err != nil {
-			log.Printf("failed to connect to slave DB #%d: %v", i, err)
+			pool.startup.add(NodeStartup{
+				Name:  slaveConfig.NodeName(fmt.Sprintf("slave-%d", i)),
+				Role:  "slave",
+				Addr:  nodeAddr(slaveConfig),
+				Error: err.Error(),
+			})
+			continue
+		}
+
+		result := probeNode(node, "slave", slaveConfig, retry)
+		pool.startup.add(result)
+		if result.Connected {
+			pool.slaves = append(pool.slaves, node)
 			continue
 		}
-		pool.slaves = append(pool.slaves, slaveDB)
+		if config.DegradedStart {
+			// 降级启动：从库暂不加入轮询，后台继续重试，连通后再加入
+			pool.detached[node.Name] = node
+			go pool.retrySlave(node, slaveConfig, retry)
+		} else {
+			node.close()
+		}
 	}
 
-	pool.slaveCount = int32(len(pool.slaves))
-	if pool.slaveCount == 0 {
+	if len(pool.slaves) == 0 {
 		log.Println("Warning: no slave DBs available, using master DB for all operations")
 	}
 
+	pool.startup.Degraded = !masterResult.Connected || len(pool.slaves) < len(config.Slaves)
+	pool.startup.log()
+
+	// 定期测量从库复制延迟，供带有最大可容忍延迟的读操作筛选从库
+	if interval := pool.lagCheckInterval(); interval > 0 && len(config.Slaves) > 0 {
+		go pool.watchLag(interval)
+	}
+
 	return pool, nil
 }
 
+// addNode 创建节点并挂载连接池级别的回调（语句观察者）
+func (p *DBPool) addNode(info config.DBInfo, fallback string, db *gorm.DB) *Node {
+	node := newNode(info, fallback, db)
+	if err := p.attachObservers(node); err != nil {
+		log.Printf("failed to register observer callbacks on node %s: %v", node.Name, err)
+	}
+	if err := p.attachChaos(node); err != nil {
+		log.Printf("failed to register chaos callbacks on node %s: %v", node.Name, err)
+	}
+	if err := p.attachRewrite(node); err != nil {
+		log.Printf("failed to register rewrite callbacks on node %s: %v", node.Name, err)
+	}
+	if err := attachSessionVars(node); err != nil {
+		log.Printf("failed to register session variable callbacks on node %s: %v", node.Name, err)
+	}
+	if err := attachStmtCache(node, p.config.StmtCacheSize); err != nil {
+		log.Printf("failed to enable statement cache on node %s: %v", node.Name, err)
+	}
+	return node
+}
+
+// connectSlave 连接从库并创建节点，挂载只读保护和断线重连回调
+// 返回的配置包含实际使用的DSN参数（开启ReadOnlySlaves时追加 transaction_read_only=1）
+func (p *DBPool) connectSlave(info config.DBInfo, fallback string) (*Node, config.DBInfo, error) {
+	if p.config.ReadOnlySlaves {
+		info.Params = appendParam(info.Params, "transaction_read_only=1")
+	}
+	slaveDB, err := connectDB(info)
+	if err != nil {
+		return nil, info, err
+	}
+	node := p.addNode(info, fallback, slaveDB)
+	if err := registerReadOnlyGuard(node); err != nil {
+		log.Printf("failed to register read-only guard on node %s: %v", node.Name, err)
+	}
+	if err := p.attachReplicaGuard(node, info); err != nil {
+		log.Printf("failed to register replica guard on node %s: %v", node.Name, err)
+	}
+	return node, info, nil
+}
+
 // 连接到单个数据库
 func connectDB(dbInfo config.DBInfo) (*gorm.DB, error) {
 	dsn := dbInfo.GetDSN()
@@ -59,6 +201,8 @@ func connectDB(dbInfo config.DBInfo) (*gorm.DB, error) {
 	// 配置GORM
 	gormConfig := &gorm.Config{
 		Logger: logger.Default.LogMode(logger.Info), // 开启详细日志
+		// 连通性由调用方显式探测（带重试），以便区分配置错误和节点暂时不可用
+		DisableAutomaticPing: true,
 	}
 
 	db, err := gorm.Open(mysql.Open(dsn), gormConfig)
@@ -78,32 +222,214 @@ func connectDB(dbInfo config.DBInfo) (*gorm.DB, error) {
 	return db, nil
 }
 
+// appendParam 向DSN参数列表追加一个参数
+func appendParam(params string, param string) string {
+	if params == "" {
+		return param
+	}
+	return params + "&" + param
+}
+
 // Master 获取主库连接
 func (p *DBPool) Master() *gorm.DB {
+	return p.masterNode().DB
+}
+
+// masterNode 获取当前主库节点
+func (p *DBPool) masterNode() *Node {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
 	return p.master
 }
 
-// Slave 获取从库连接（使用简单轮询策略）
+// swapMaster 连接新的主库并原子替换，旧主库在在途查询结束后关闭
+func (p *DBPool) swapMaster(info config.DBInfo) error {
+	newDB, err := connectDB(info)
+	if err != nil {
+		return fmt.Errorf("failed to connect to new master DB: %w", err)
+	}
+	if err := pingDB(newDB); err != nil {
+		closeDB(newDB)
+		return fmt.Errorf("failed to connect to new master DB: %w", err)
+	}
+	p.installMaster(info, newDB)
+	return nil
+}
+
+// installMaster 用已连通的连接替换主库节点，旧主库在在途查询结束后关闭
+func (p *DBPool) installMaster(info config.DBInfo, newDB *gorm.DB) {
+	node := p.addNode(info, "master", newDB)
+	if err := p.attachMasterGuard(node); err != nil {
+		log.Printf("failed to register master guard on node %s: %v", node.Name, err)
+	}
+
+	p.mu.Lock()
+	old := p.master
+	p.master = node
+	p.config.Master = info
+	p.mu.Unlock()
+
+	// 新主库已连通，解除快速失败并重放排队的写操作
+	p.availability.markUp()
+
+	log.Printf("Master switched from %s to %s:%d/%s", old.Name, info.Host, info.Port, info.DBName)
+	if open, oldest := p.txs.openOn(old); open > 0 {
+		// 旧主库上的事务仍持有行锁，要等它们结束（或排空超时被强制关闭）旧主库才能下线
+		log.Printf("%d transactions still open on old master %s, oldest started %v ago", open, old.Name, time.Since(oldest).Round(time.Millisecond))
+	}
+	go old.drain(masterDrainTimeout)
+}
+
+// Slave 获取从库连接（由当前选择策略决定）
 func (p *DBPool) Slave() *gorm.DB {
+	return p.SlaveFor(QueryInfo{})
+}
+
+// SlaveFor 根据查询信息选择从库连接
+func (p *DBPool) SlaveFor(q QueryInfo) *gorm.DB {
+	return p.slaveNodeFor(q).DB
+}
+
+// slaveNodeFor 根据查询信息选择从库节点，没有可用从库时返回主库节点
+func (p *DBPool) slaveNodeFor(q QueryInfo) *Node {
+	if p.forceMasterReads.Load() {
+		return p.masterNode()
+	}
+	slaves, stale := p.readCandidates(q)
+
+	// 所有从库的延迟都超过查询可容忍的延迟，读主库
+	if stale {
+		atomic.AddInt64(&p.staleFallbacks, 1)
+		return p.masterNode()
+	}
+
 	// 如果没有从库，则返回主库
-	if p.slaveCount == 0 {
-		return p.master
+	if len(slaves) == 0 {
+		atomic.AddInt64(&p.fallbacks, 1)
+		return p.masterNode()
+	}
+
+	node := p.Strategy().Pick(slaves, q)
+	if node == nil {
+		atomic.AddInt64(&p.fallbacks, 1)
+		return p.masterNode()
 	}
+	return node
+}
+
+// slaveNodes 获取当前参与轮询的从库节点
+// 从库列表采用写时复制，返回的切片不会被修改
+func (p *DBPool) slaveNodes() []*Node {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.slaves
+}
+
+// Strategy 获取当前的从库选择策略
+func (p *DBPool) Strategy() SelectionStrategy {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.strategy
+}
 
-	// 简单的轮询策略选择从库
-	current := atomic.AddInt32(&p.current, 1) % p.slaveCount
-	return p.slaves[current]
+// SetStrategy 替换从库选择策略
+func (p *DBPool) SetStrategy(strategy SelectionStrategy) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.strategy = strategy
+	log.Printf("Slave selection strategy set to %s", strategy.Name())
+}
+
+// Stats 获取连接池统计信息，包括各从库的平均延迟和生效权重
+func (p *DBPool) Stats() PoolStats {
+	strategy := p.Strategy()
+	slaves := p.slaveNodes()
+	weights := make([]float64, len(slaves))
+	if w, ok := strategy.(Weigher); ok {
+		weights = w.Weights(slaves)
+	}
+
+	stats := PoolStats{
+		Strategy: strategy.Name(),
+		Master:   p.masterNode().stats(0),
+		Slaves:   make([]NodeStats, 0, len(slaves)),
+	}
+	for i, n := range slaves {
+		stats.Slaves = append(stats.Slaves, n.stats(weights[i]))
+	}
+	return stats
+}
+
+// ProxyStats 代理统计信息：当前策略、各节点在途查询与读写/错误计数以及回落到主库的次数
+type ProxyStats struct {
+	Strategy  string // 当前选择策略
+	Fallbacks int64  // 读操作因没有可用从库而使用主库的次数
+	// 读操作因从库延迟超过最大可容忍延迟（见WithMaxStaleness）而使用主库的次数
+	StaleFallbacks int64
+	// 携带一致性令牌（见WithConsistencyToken）的读操作因没有从库及时追上而使用主库的次数
+	ConsistencyFallbacks int64
+	InFlight             int64       // 所有节点的在途查询数
+	Reads                int64       // 所有节点已完成的读语句数
+	Writes               int64       // 所有节点已完成的写语句数
+	Errors               int64       // 所有节点出错的语句数
+	Master               NodeStats   // 主库统计
+	Slaves               []NodeStats // 当前参与轮询的从库统计
+}
+
+// ProxyStats 汇总连接池统计信息
+func (p *DBPool) ProxyStats() ProxyStats {
+	pool := p.Stats()
+	stats := ProxyStats{
+		Strategy:             pool.Strategy,
+		Fallbacks:            atomic.LoadInt64(&p.fallbacks),
+		StaleFallbacks:       atomic.LoadInt64(&p.staleFallbacks),
+		ConsistencyFallbacks: atomic.LoadInt64(&p.consistencyFallbacks),
+		Master:               pool.Master,
+		Slaves:               pool.Slaves,
+	}
+	for _, n := range append([]NodeStats{pool.Master}, pool.Slaves...) {
+		stats.InFlight += n.InFlight
+		stats.Reads += n.Reads
+		stats.Writes += n.Writes
+		stats.Errors += n.Errors
+	}
+	return stats
+}
+
+// Auditor 获取语句审计器
+func (p *DBPool) Auditor() *Auditor {
+	return p.auditor
+}
+
+// Digests 获取SQL指纹统计
+func (p *DBPool) Digests() *DigestCollector {
+	return p.digests
+}
+
+// Listings 获取一致性分页会话管理器
+func (p *DBPool) Listings() *ListingManager {
+	return p.listings
 }
 
 // Close 关闭所有数据库连接
 func (p *DBPool) Close() {
-	if p.master != nil {
-		sqlDB, _ := p.master.DB()
-		sqlDB.Close()
+	close(p.stop)
+	p.listings.CloseAll()
+	if p.invalidation != nil {
+		p.invalidation.close()
+	}
+
+	if master := p.masterNode(); master != nil {
+		master.close()
+	}
+
+	for _, slave := range p.slaveNodes() {
+		slave.close()
 	}
 
-	for _, slave := range p.slaves {
-		sqlDB, _ := slave.DB()
-		sqlDB.Close()
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	for _, slave := range p.drained {
+		slave.close()
 	}
 }
@@ -0,0 +1,139 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync/atomic"
+	"time"
+)
+
+// defaultConsistencyWait 带一致性令牌的读操作等待从库追上的默认时长
+const defaultConsistencyWait = 100 * time.Millisecond
+
+// ErrNoConsistencyToken 主库没有开启GTID，无法生成一致性令牌
+var ErrNoConsistencyToken = errors.New("gtid_executed is empty, enable gtid_mode to use consistency tokens")
+
+// ConsistencyToken 一致性令牌：写入完成时主库已执行的GTID集合
+// 读操作携带令牌时只会读到包含这些写入的数据（读己之写）
+type ConsistencyToken string
+
+// consistencyContextKey 上下文中一致性令牌的键
+type consistencyContextKey struct{}
+
+// WithConsistencyToken 在上下文中附加一致性令牌，经 DBProxy.WithContext 绑定后
+// 读操作只会使用已经回放了令牌中所有事务的从库；短暂等待后仍没有追上的从库时读主库
+func WithConsistencyToken(ctx context.Context, token ConsistencyToken) context.Context {
+	return context.WithValue(ctx, consistencyContextKey{}, token)
+}
+
+// ConsistencyTokenFromContext 从上下文中获取一致性令牌，未设置时返回空字符串
+func ConsistencyTokenFromContext(ctx context.Context) ConsistencyToken {
+	if ctx == nil {
+		return ""
+	}
+	token, _ := ctx.Value(consistencyContextKey{}).(ConsistencyToken)
+	return token
+}
+
+// ConsistencyToken 获取当前主库已执行的GTID集合作为一致性令牌，应在写操作（或事务）提交后调用
+// 令牌可以返回给客户端，之后的请求再通过 WithConsistencyToken 带上，保证读到自己的写入
+func (p *DBProxy) ConsistencyToken() (ConsistencyToken, error) {
+	if p.err != nil {
+		return "", p.err
+	}
+	sqlDB, err := p.pool.Master().DB()
+	if err != nil {
+		return "", err
+	}
+	ctx := p.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	var gtids string
+	if err := sqlDB.QueryRowContext(ctx, "SELECT @@GLOBAL.gtid_executed").Scan(&gtids); err != nil {
+		return "", fmt.Errorf("failed to read gtid_executed from master: %w", err)
+	}
+	if gtids == "" {
+		return "", ErrNoConsistencyToken
+	}
+	return ConsistencyToken(gtids), nil
+}
+
+// WithConsistencyToken 返回之后的读操作都满足一致性令牌的代理
+func (p *DBProxy) WithConsistencyToken(token ConsistencyToken) *DBProxy {
+	ctx := p.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return p.WithContext(WithConsistencyToken(ctx, token))
+}
+
+// consistencyWait 获取等待从库追上一致性令牌的时长
+func (p *DBPool) consistencyWait() time.Duration {
+	if p.config.ConsistencyWait > 0 {
+		return p.config.ConsistencyWait
+	}
+	return defaultConsistencyWait
+}
+
+// consistentNode 为携带一致性令牌的读操作确认节点：
+// 先立即检查选中的从库和其他候选从库是否已回放令牌，都没有时在选中的从库上等待一小段时间，
+// 仍未追上则回落到主库并计入一致性回落次数，不返回旧数据也不返回错误
+func (p *DBPool) consistentNode(q QueryInfo, chosen *Node, candidates []*Node) *Node {
+	token := ConsistencyTokenFromContext(q.Context)
+	master := p.masterNode()
+	if token == "" || chosen == master {
+		return chosen
+	}
+
+	ctx := q.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	for i, node := range append([]*Node{chosen}, candidates...) {
+		if node == master || i > 0 && node == chosen {
+			continue
+		}
+		if ok, err := gtidSubset(ctx, node, token); err == nil && ok {
+			return node
+		}
+	}
+
+	wait := p.consistencyWait()
+	if ok, err := waitForGTIDs(ctx, chosen, token, wait); err == nil && ok {
+		return chosen
+	} else if err != nil {
+		log.Printf("Failed to wait for consistency token on %s: %v", chosen.Name, err)
+	}
+
+	// 复制延迟突增时每次读都会回落，只计数不输出日志
+	atomic.AddInt64(&p.consistencyFallbacks, 1)
+	return master
+}
+
+// gtidSubset 检查节点是否已回放令牌中的所有事务（不等待）
+// 直接使用底层连接执行，不经过GORM回调，检测语句不计入节点统计和SQL指纹
+func gtidSubset(ctx context.Context, node *Node, token ConsistencyToken) (bool, error) {
+	sqlDB, err := node.DB.DB()
+	if err != nil {
+		return false, err
+	}
+	var subset bool
+	err = sqlDB.QueryRowContext(ctx, "SELECT GTID_SUBSET(?, @@GLOBAL.gtid_executed)", string(token)).Scan(&subset)
+	return subset, err
+}
+
+// waitForGTIDs 在节点上等待令牌中的事务回放完成，最多等待wait
+func waitForGTIDs(ctx context.Context, node *Node, token ConsistencyToken, wait time.Duration) (bool, error) {
+	sqlDB, err := node.DB.DB()
+	if err != nil {
+		return false, err
+	}
+	// WAIT_FOR_EXECUTED_GTID_SET 返回0表示已追上，1表示超时
+	var timedOut int
+	err = sqlDB.QueryRowContext(ctx, "SELECT WAIT_FOR_EXECUTED_GTID_SET(?, ?)", string(token), wait.Seconds()).Scan(&timedOut)
+	return timedOut == 0, err
+}
@@ -0,0 +1,153 @@
+package db
+
+import (
+	"fmt"
+	"log"
+)
+
+// 从库在拓扑视图中的状态
+const (
+	NodeStateActive       = "active"       // 参与轮询
+	NodeStateDrained      = "drained"      // 运维手动摘除，不再接收新的读请求
+	NodeStateReconnecting = "reconnecting" // 因连接错误移出轮询，后台重连中
+)
+
+// NodeState 拓扑视图中的单个节点
+type NodeState struct {
+	Name     string `json:"name"`      // 节点名称
+	Addr     string `json:"addr"`      // 节点地址
+	State    string `json:"state"`     // 节点状态
+	InFlight int64  `json:"in_flight"` // 在途查询数（摘除后降为0即排空完成）
+}
+
+// PoolTopology 连接池当前的主从拓扑
+type PoolTopology struct {
+	Strategy         string      `json:"strategy"`           // 从库选择策略
+	ForceMasterReads bool        `json:"force_master_reads"` // 是否强制所有读操作走主库
+	Master           NodeState   `json:"master"`             // 主库
+	Slaves           []NodeState `json:"slaves"`             // 所有从库（按配置顺序）
+}
+
+// DrainSlave 将从库移出轮询（写时复制），新的读请求不再路由到该节点，在途查询正常完成
+// 节点连接保持打开，可通过 UndrainSlave 恢复；在途查询数见 Topology
+func (p *DBPool) DrainSlave(name string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if _, ok := p.drained[name]; ok {
+		return nil
+	}
+	index := -1
+	for i, n := range p.slaves {
+		if n.Name == name {
+			index = i
+			break
+		}
+	}
+	if index < 0 {
+		return fmt.Errorf("slave %s is not in rotation", name)
+	}
+
+	node := p.slaves[index]
+	slaves := make([]*Node, 0, len(p.slaves)-1)
+	slaves = append(slaves, p.slaves[:index]...)
+	p.slaves = append(slaves, p.slaves[index+1:]...)
+	p.drained[name] = node
+
+	log.Printf("Slave %s drained by operator, %d queries in flight", name, node.InFlight())
+	return nil
+}
+
+// UndrainSlave 将手动摘除的从库重新加入轮询
+func (p *DBPool) UndrainSlave(name string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	node, ok := p.drained[name]
+	if !ok {
+		return fmt.Errorf("slave %s is not drained", name)
+	}
+	delete(p.drained, name)
+
+	slaves := make([]*Node, 0, len(p.slaves)+1)
+	slaves = append(slaves, p.slaves...)
+	p.slaves = append(slaves, node)
+
+	log.Printf("Slave %s returned to rotation by operator", name)
+	return nil
+}
+
+// SetForceMasterReads 开启后所有读操作都路由到主库（已固定读节点的代理除外），用于从库整体不可信时的临时处置
+func (p *DBPool) SetForceMasterReads(enabled bool) {
+	if p.forceMasterReads.Swap(enabled) != enabled {
+		log.Printf("Force-master reads set to %v", enabled)
+	}
+}
+
+// ForceMasterReads 是否强制所有读操作走主库
+func (p *DBPool) ForceMasterReads() bool {
+	return p.forceMasterReads.Load()
+}
+
+// Topology 获取连接池当前的主从拓扑，包括手动摘除和后台重连中的从库
+func (p *DBPool) Topology() PoolTopology {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	topology := PoolTopology{
+		Strategy:         p.strategy.Name(),
+		ForceMasterReads: p.forceMasterReads.Load(),
+		Master: NodeState{
+			Name:     p.master.Name,
+			Addr:     nodeAddr(p.config.Master),
+			State:    NodeStateActive,
+			InFlight: p.master.InFlight(),
+		},
+		Slaves: make([]NodeState, 0, len(p.config.Slaves)),
+	}
+
+	active := make(map[string]*Node, len(p.slaves))
+	for _, n := range p.slaves {
+		active[n.Name] = n
+	}
+	for i, info := range p.config.Slaves {
+		name := info.NodeName(fmt.Sprintf("slave-%d", i))
+		state := NodeState{Name: name, Addr: nodeAddr(info)}
+
+		var node *Node
+		switch {
+		case active[name] != nil:
+			node, state.State = active[name], NodeStateActive
+		case p.drained[name] != nil:
+			node, state.State = p.drained[name], NodeStateDrained
+		case p.detached[name] != nil:
+			node, state.State = p.detached[name], NodeStateReconnecting
+		default:
+			// 启动时连接失败且未开启降级启动的从库
+			continue
+		}
+		state.InFlight = node.InFlight()
+		topology.Slaves = append(topology.Slaves, state)
+	}
+	return topology
+}
+
+// DrainSlave 运维操作：将从库移出轮询，见 DBPool.DrainSlave
+func (p *DBProxy) DrainSlave(name string) error {
+	return p.pool.DrainSlave(name)
+}
+
+// UndrainSlave 运维操作：将手动摘除的从库重新加入轮询
+func (p *DBProxy) UndrainSlave(name string) error {
+	return p.pool.UndrainSlave(name)
+}
+
+// SetForceMasterReads 运维操作：开启或关闭强制读主库
+func (p *DBProxy) SetForceMasterReads(enabled bool) {
+	p.pool.SetForceMasterReads(enabled)
+}
+
+// Topology 获取连接池当前的主从拓扑
+func (p *DBProxy) Topology() PoolTopology {
+	return p.pool.Topology()
+}
@@ -0,0 +1,159 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+
+	"read-write-splitting/internal/config"
+
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// fakeConnector 不依赖MySQL的测试驱动：任何SELECT都返回一行 (id=1, user_id=1, name="test")
+type fakeConnector struct{}
+
+func (fakeConnector) Connect(context.Context) (driver.Conn, error) { return &fakeConn{}, nil }
+func (fakeConnector) Driver() driver.Driver                        { return fakeDriver{} }
+
+type fakeDriver struct{}
+
+func (fakeDriver) Open(string) (driver.Conn, error) { return &fakeConn{}, nil }
+
+type fakeConn struct{}
+
+func (c *fakeConn) Prepare(query string) (driver.Stmt, error) {
+	return nil, fmt.Errorf("prepare not supported")
+}
+func (c *fakeConn) Close() error              { return nil }
+func (c *fakeConn) Begin() (driver.Tx, error) { return fakeTx{}, nil }
+func (c *fakeConn) BeginTx(context.Context, driver.TxOptions) (driver.Tx, error) {
+	return fakeTx{}, nil
+}
+
+func (c *fakeConn) QueryContext(_ context.Context, query string, _ []driver.NamedValue) (driver.Rows, error) {
+	if !strings.HasPrefix(strings.ToUpper(strings.TrimSpace(query)), "SELECT") {
+		return &fakeRows{}, nil
+	}
+	return &fakeRows{
+		columns: []string{"id", "user_id", "name"},
+		data:    [][]driver.Value{{int64(1), int64(1), "test"}},
+	}, nil
+}
+
+func (c *fakeConn) ExecContext(context.Context, string, []driver.NamedValue) (driver.Result, error) {
+	return fakeResult{}, nil
+}
+
+type fakeResult struct{}
+
+func (fakeResult) LastInsertId() (int64, error) { return 1, nil }
+func (fakeResult) RowsAffected() (int64, error) { return 1, nil }
+
+type fakeTx struct{}
+
+func (fakeTx) Commit() error   { return nil }
+func (fakeTx) Rollback() error { return nil }
+
+type fakeRows struct {
+	columns []string
+	data    [][]driver.Value
+	next    int
+}
+
+func (r *fakeRows) Columns() []string { return r.columns }
+func (r *fakeRows) Close() error      { return nil }
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if r.next >= len(r.data) {
+		return io.EOF
+	}
+	copy(dest, r.data[r.next])
+	r.next++
+	return nil
+}
+
+// recordedStatement 观察到的一条语句
+type recordedStatement struct {
+	Node string
+	SQL  string
+}
+
+// statementRecorder 记录语句执行节点的观察者
+type statementRecorder struct {
+	mu    sync.Mutex
+	stmts []recordedStatement
+}
+
+func (r *statementRecorder) ObserveStatement(event StatementEvent) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.stmts = append(r.stmts, recordedStatement{Node: event.Node, SQL: event.SQL})
+}
+
+// take 取出并清空已记录的语句
+func (r *statementRecorder) take() []recordedStatement {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	stmts := r.stmts
+	r.stmts = nil
+	return stmts
+}
+
+// openFakeDB 打开基于测试驱动的GORM连接
+func openFakeDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(mysql.New(mysql.Config{
+		Conn:                      sql.OpenDB(fakeConnector{}),
+		SkipInitializeWithVersion: true,
+	}), &gorm.Config{
+		Logger:               logger.Discard,
+		DisableAutomaticPing: true,
+	})
+	if err != nil {
+		t.Fatalf("open fake db: %v", err)
+	}
+	return db
+}
+
+// newTestProxy 创建一主多从（轮询策略）的测试代理，返回代理和语句记录器
+func newTestProxy(t *testing.T, slaves int) (*DBProxy, *statementRecorder) {
+	t.Helper()
+	strategy, err := NewStrategy(StrategyRoundRobin)
+	if err != nil {
+		t.Fatalf("new strategy: %v", err)
+	}
+
+	recorder := &statementRecorder{}
+	pool := &DBPool{
+		config:    &config.DBConfig{},
+		strategy:  strategy,
+		auditor:   NewAuditor(),
+		digests:   NewDigestCollector(),
+		stop:      make(chan struct{}),
+		chaos:     NewChaosInjector(),
+		rewrites:  NewRewriteChain(),
+		detached:  make(map[string]*Node),
+		drained:   make(map[string]*Node),
+		observers: []StatementObserver{recorder},
+	}
+	pool.availability = newMasterAvailability(pool, 0)
+	pool.listings = NewListingManager(pool)
+	pool.txs = newTxTracker(pool, 0)
+
+	pool.master = pool.addNode(config.DBInfo{}, "master", openFakeDB(t))
+	for i := 0; i < slaves; i++ {
+		pool.slaves = append(pool.slaves, pool.addNode(config.DBInfo{}, fmt.Sprintf("slave-%d", i), openFakeDB(t)))
+	}
+	t.Cleanup(pool.Close)
+
+	router := NewSQLRouter(pool)
+	tenants := &tenantRegistry{fallback: tenantPool{pool: pool, router: router}}
+	return &DBProxy{router: router, pool: pool, tenants: tenants}, recorder
+}
@@ -0,0 +1,208 @@
+package db
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxDigestEntries 指纹统计的最大条目数，超出后新指纹归入 otherFingerprint
+const maxDigestEntries = 1000
+
+// otherFingerprint 超出条目上限后的汇总指纹
+const otherFingerprint = "<other>"
+
+var (
+	blockCommentRegex = regexp.MustCompile(`(?s)/\*.*?\*/`)
+	lineCommentRegex  = regexp.MustCompile(`(?m)(--|#)[^\n]*$`)
+	stringRegex       = regexp.MustCompile(`'(?:[^'\\]|\\.|'')*'|"(?:[^"\\]|\\.)*"`)
+	numberRegex       = regexp.MustCompile(`\b-?\d+(?:\.\d+)?(?:e[+-]?\d+)?\b`)
+	inListRegex       = regexp.MustCompile(`(?i)\bin\s*\(\s*\?(?:\s*,\s*\?)*\s*\)`)
+	valuesListRegex   = regexp.MustCompile(`\(\s*\?(?:\s*,\s*\?)*\s*\)(?:\s*,\s*\(\s*\?(?:\s*,\s*\?)*\s*\))+`)
+)
+
+// Fingerprint 将SQL规范化为指纹：去除注释，字面量替换为?，IN列表和多行VALUES折叠，统一小写
+func Fingerprint(sql string) string {
+	fp := blockCommentRegex.ReplaceAllString(sql, " ")
+	fp = lineCommentRegex.ReplaceAllString(fp, " ")
+	fp = stringRegex.ReplaceAllString(fp, "?")
+	fp = numberRegex.ReplaceAllString(fp, "?")
+	fp = strings.ToLower(strings.Join(strings.Fields(fp), " "))
+	fp = inListRegex.ReplaceAllString(fp, "in (?+)")
+	fp = valuesListRegex.ReplaceAllString(fp, "(?+)")
+	return fp
+}
+
+// DigestStats 单个SQL指纹的聚合统计（类似performance_schema的digest视图）
+type DigestStats struct {
+	Fingerprint    string           `json:"fingerprint"`      // SQL指纹
+	Count          int64            `json:"count"`            // 执行次数
+	Errors         int64            `json:"errors"`           // 出错次数
+	TotalLatencyMs float64          `json:"total_latency_ms"` // 总耗时(毫秒)
+	AvgLatencyMs   float64          `json:"avg_latency_ms"`   // 平均耗时(毫秒)
+	MaxLatencyMs   float64          `json:"max_latency_ms"`   // 最大耗时(毫秒)
+	Rows           int64            `json:"rows"`             // 累计影响或返回行数
+	Backends       map[string]int64 `json:"backends"`         // 各节点执行次数分布
+	Routes         map[string]int64 `json:"routes"`           // 各路由方向执行次数分布（见 RouteReadSlave 等）
+	FirstSeen      time.Time        `json:"first_seen"`       // 首次出现时间
+	LastSeen       time.Time        `json:"last_seen"`        // 最近出现时间
+}
+
+// 语句的路由方向：语句类型（读/写）与实际执行节点角色的组合，加锁读（FOR UPDATE等）按写语句统计
+const (
+	RouteReadSlave   = "read_slave"   // 读语句在从库执行（正常读写分离）
+	RouteReadMaster  = "read_master"  // 读语句在主库执行（事务内、强制读主、没有可用从库或显式使用主库）
+	RouteWriteMaster = "write_master" // 写语句在主库执行
+	RouteWriteSlave  = "write_slave"  // 写语句在从库执行（路由错误，从库只读时会失败）
+)
+
+// statementRoute 根据语句类型和执行节点角色得到路由方向
+func statementRoute(sql string, role string) string {
+	read := isReadOnlyStatement(sql)
+	switch {
+	case read && role == "master":
+		return RouteReadMaster
+	case read:
+		return RouteReadSlave
+	case role == "master":
+		return RouteWriteMaster
+	default:
+		return RouteWriteSlave
+	}
+}
+
+// DigestCollector 按SQL指纹聚合语句执行统计
+type DigestCollector struct {
+	digests map[string]*DigestStats
+	mu      sync.Mutex
+}
+
+// NewDigestCollector 创建新的指纹统计器
+func NewDigestCollector() *DigestCollector {
+	return &DigestCollector{
+		digests: make(map[string]*DigestStats),
+	}
+}
+
+// ObserveStatement 实现StatementObserver接口
+func (c *DigestCollector) ObserveStatement(event StatementEvent) {
+	if event.SQL == "" {
+		return
+	}
+	fp := Fingerprint(event.SQL)
+	latency := float64(event.Duration) / float64(time.Millisecond)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	d, ok := c.digests[fp]
+	if !ok {
+		if len(c.digests) >= maxDigestEntries {
+			fp = otherFingerprint
+			d, ok = c.digests[fp]
+		}
+		if !ok {
+			d = &DigestStats{
+				Fingerprint: fp,
+				Backends:    make(map[string]int64),
+				Routes:      make(map[string]int64),
+				FirstSeen:   event.Time,
+			}
+			c.digests[fp] = d
+		}
+	}
+
+	d.Count++
+	if event.Err != nil {
+		d.Errors++
+	}
+	d.TotalLatencyMs += latency
+	if latency > d.MaxLatencyMs {
+		d.MaxLatencyMs = latency
+	}
+	d.Rows += event.Rows
+	d.Backends[event.Node]++
+	d.Routes[statementRoute(event.SQL, event.Role)]++
+	d.LastSeen = event.Time
+}
+
+// Snapshot 获取指纹统计快照，按sortBy（count、total_latency、avg_latency）降序排列，limit<=0表示不限制
+func (c *DigestCollector) Snapshot(sortBy string, limit int) []DigestStats {
+	c.mu.Lock()
+	result := make([]DigestStats, 0, len(c.digests))
+	for _, d := range c.digests {
+		copied := *d
+		copied.Backends = make(map[string]int64, len(d.Backends))
+		for node, count := range d.Backends {
+			copied.Backends[node] = count
+		}
+		copied.Routes = make(map[string]int64, len(d.Routes))
+		for route, count := range d.Routes {
+			copied.Routes[route] = count
+		}
+		copied.AvgLatencyMs = copied.TotalLatencyMs / float64(copied.Count)
+		result = append(result, copied)
+	}
+	c.mu.Unlock()
+
+	sort.Slice(result, func(i, j int) bool {
+		switch sortBy {
+		case "count":
+			return result[i].Count > result[j].Count
+		case "avg_latency":
+			return result[i].AvgLatencyMs > result[j].AvgLatencyMs
+		default:
+			return result[i].TotalLatencyMs > result[j].TotalLatencyMs
+		}
+	})
+
+	if limit > 0 && len(result) > limit {
+		result = result[:limit]
+	}
+	return result
+}
+
+// RoutingStats 单个SQL指纹的路由统计
+type RoutingStats struct {
+	Fingerprint string           `json:"fingerprint"` // SQL指纹
+	Count       int64            `json:"count"`       // 执行次数（指定路由方向时为该方向的次数）
+	Total       int64            `json:"total"`       // 总执行次数
+	Routes      map[string]int64 `json:"routes"`      // 各路由方向执行次数分布
+	Backends    map[string]int64 `json:"backends"`    // 各节点执行次数分布
+}
+
+// Routing 获取按执行次数降序排列的前limit个指纹的路由统计，limit<=0表示不限制
+// route不为空时只统计该路由方向（如 read_master），用于确认某类查询是否落在预期的节点上
+func (c *DigestCollector) Routing(route string, limit int) []RoutingStats {
+	var result []RoutingStats
+	for _, d := range c.Snapshot("count", 0) {
+		count := d.Count
+		if route != "" {
+			if count = d.Routes[route]; count == 0 {
+				continue
+			}
+		}
+		result = append(result, RoutingStats{
+			Fingerprint: d.Fingerprint,
+			Count:       count,
+			Total:       d.Count,
+			Routes:      d.Routes,
+			Backends:    d.Backends,
+		})
+	}
+
+	sort.SliceStable(result, func(i, j int) bool { return result[i].Count > result[j].Count })
+	if limit > 0 && len(result) > limit {
+		result = result[:limit]
+	}
+	return result
+}
+
+// Reset 清空指纹统计
+func (c *DigestCollector) Reset() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.digests = make(map[string]*DigestStats)
+}
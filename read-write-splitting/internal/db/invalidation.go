@@ -0,0 +1,297 @@
+package db
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// writeTableRegex 匹配写语句的目标表（INSERT/REPLACE INTO、UPDATE、DELETE FROM）
+var writeTableRegex = regexp.MustCompile("(?is)^\\s*(?:insert|replace)\\s+(?:(?:low_priority|delayed|high_priority|ignore)\\s+)*(?:into\\s+)?([`\\w.]+)" +
+	"|^\\s*update\\s+(?:(?:low_priority|ignore)\\s+)*([`\\w.]+)" +
+	"|^\\s*delete\\s+(?:(?:low_priority|quick|ignore)\\s+)*from\\s+([`\\w.]+)")
+
+// Invalidation 一次缓存失效通知：某个应用实例写入了这些表
+type Invalidation struct {
+	Tables []string  `json:"tables"` // 被写入的表（不含库名）
+	Origin string    `json:"origin"` // 发起写入的实例ID
+	Time   time.Time `json:"time"`   // 写入完成的时间，用于计算失效延迟
+}
+
+// InvalidationBus 缓存失效通知的广播通道，多个应用实例通过同一个通道互相通知
+type InvalidationBus interface {
+	// Publish 广播一条失效通知（包括发往发布者自己）
+	Publish(ctx context.Context, inv Invalidation) error
+	// Subscribe 订阅失效通知，返回取消订阅的函数
+	Subscribe(handler func(Invalidation)) (cancel func(), err error)
+}
+
+// LocalInvalidationBus 进程内的失效通知通道，用于单进程内的多个代理实例或测试
+type LocalInvalidationBus struct {
+	handlers map[int]func(Invalidation)
+	nextID   int
+	mu       sync.RWMutex
+}
+
+// NewLocalInvalidationBus 创建进程内失效通知通道
+func NewLocalInvalidationBus() *LocalInvalidationBus {
+	return &LocalInvalidationBus{handlers: make(map[int]func(Invalidation))}
+}
+
+// Publish 同步调用所有订阅者
+func (b *LocalInvalidationBus) Publish(ctx context.Context, inv Invalidation) error {
+	b.mu.RLock()
+	handlers := make([]func(Invalidation), 0, len(b.handlers))
+	for _, h := range b.handlers {
+		handlers = append(handlers, h)
+	}
+	b.mu.RUnlock()
+
+	for _, h := range handlers {
+		h(inv)
+	}
+	return nil
+}
+
+// Subscribe 订阅失效通知
+func (b *LocalInvalidationBus) Subscribe(handler func(Invalidation)) (func(), error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	id := b.nextID
+	b.nextID++
+	b.handlers[id] = handler
+	return func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		delete(b.handlers, id)
+	}, nil
+}
+
+// PubSubClient 发布/订阅客户端（如Redis），由应用用自己的客户端库实现，代理不直接依赖Redis
+type PubSubClient interface {
+	// Publish 向频道发布一条消息
+	Publish(ctx context.Context, channel string, message []byte) error
+	// Subscribe 订阅频道，handler在收到消息时被调用，直到ctx被取消
+	Subscribe(ctx context.Context, channel string, handler func(message []byte)) error
+}
+
+// PubSubInvalidationBus 基于发布/订阅服务（如Redis pub/sub）的失效通知通道，用于跨进程的多个应用实例
+type PubSubInvalidationBus struct {
+	client  PubSubClient
+	channel string
+}
+
+// NewPubSubInvalidationBus 创建基于发布/订阅服务的失效通知通道
+func NewPubSubInvalidationBus(client PubSubClient, channel string) *PubSubInvalidationBus {
+	return &PubSubInvalidationBus{client: client, channel: channel}
+}
+
+// Publish 将失效通知编码为JSON发布到频道
+func (b *PubSubInvalidationBus) Publish(ctx context.Context, inv Invalidation) error {
+	data, err := json.Marshal(inv)
+	if err != nil {
+		return fmt.Errorf("failed to encode invalidation: %w", err)
+	}
+	return b.client.Publish(ctx, b.channel, data)
+}
+
+// Subscribe 订阅频道，无法解码的消息被忽略
+func (b *PubSubInvalidationBus) Subscribe(handler func(Invalidation)) (func(), error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	err := b.client.Subscribe(ctx, b.channel, func(message []byte) {
+		var inv Invalidation
+		if err := json.Unmarshal(message, &inv); err != nil {
+			log.Printf("ignoring malformed invalidation on channel %s: %v", b.channel, err)
+			return
+		}
+		handler(inv)
+	})
+	if err != nil {
+		cancel()
+		return nil, fmt.Errorf("failed to subscribe to channel %s: %w", b.channel, err)
+	}
+	return cancel, nil
+}
+
+// InvalidationStats 缓存失效广播统计
+type InvalidationStats struct {
+	Origin        string  `json:"origin"`          // 本实例ID
+	Published     int64   `json:"published"`       // 本实例发布的失效通知数
+	PublishErrors int64   `json:"publish_errors"`  // 发布失败次数
+	Received      int64   `json:"received"`        // 收到的其他实例的失效通知数
+	AvgLatencyMs  float64 `json:"avg_latency_ms"`  // 其他实例写入完成到本实例收到通知的平均延迟
+	MaxLatencyMs  float64 `json:"max_latency_ms"`  // 最大延迟
+	LastLatencyMs float64 `json:"last_latency_ms"` // 最近一次的延迟
+}
+
+// InvalidationBroadcaster 观察主库上的写语句，把被写入的表广播给所有应用实例，
+// 并把本实例和其他实例的失效通知转发给本地缓存注册的回调
+// 失效延迟按各实例的本地时钟计算，跨机器时包含时钟偏差
+type InvalidationBroadcaster struct {
+	bus      InvalidationBus
+	origin   string
+	cancel   func()
+	handlers []func(Invalidation)
+
+	published     int64
+	publishErrors int64
+	received      int64
+	totalLatency  time.Duration
+	maxLatency    time.Duration
+	lastLatency   time.Duration
+	mu            sync.Mutex
+}
+
+// newInvalidationBroadcaster 创建失效广播器并订阅通道
+func newInvalidationBroadcaster(bus InvalidationBus) (*InvalidationBroadcaster, error) {
+	b := &InvalidationBroadcaster{bus: bus, origin: newInstanceID()}
+	cancel, err := bus.Subscribe(b.receive)
+	if err != nil {
+		return nil, err
+	}
+	b.cancel = cancel
+	return b, nil
+}
+
+// OnInvalidate 注册失效回调，本实例或其他实例写入表后被调用，本地缓存在其中删除或重新加载相关条目
+func (b *InvalidationBroadcaster) OnInvalidate(handler func(Invalidation)) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.handlers = append(b.handlers, handler)
+}
+
+// ObserveStatement 主库上的写语句成功后发布失效通知
+func (b *InvalidationBroadcaster) ObserveStatement(event StatementEvent) {
+	if event.Role != "master" || event.Err != nil {
+		return
+	}
+	table := writeTable(event.SQL)
+	if table == "" {
+		return
+	}
+	b.Publish(Invalidation{Tables: []string{table}})
+}
+
+// Publish 手动发布失效通知（如经原始连接或存储过程写入，代理无法识别目标表时）
+func (b *InvalidationBroadcaster) Publish(inv Invalidation) {
+	inv.Origin = b.origin
+	if inv.Time.IsZero() {
+		inv.Time = time.Now()
+	}
+	err := b.bus.Publish(context.Background(), inv)
+
+	b.mu.Lock()
+	b.published++
+	if err != nil {
+		b.publishErrors++
+	}
+	b.mu.Unlock()
+
+	if err != nil {
+		log.Printf("failed to publish invalidation for %v: %v", inv.Tables, err)
+	}
+}
+
+// receive 处理通道上的失效通知，记录其他实例通知的延迟并调用本地回调
+func (b *InvalidationBroadcaster) receive(inv Invalidation) {
+	b.mu.Lock()
+	if inv.Origin != b.origin {
+		latency := time.Since(inv.Time)
+		if latency < 0 {
+			latency = 0
+		}
+		b.received++
+		b.totalLatency += latency
+		b.lastLatency = latency
+		if latency > b.maxLatency {
+			b.maxLatency = latency
+		}
+	}
+	handlers := b.handlers
+	b.mu.Unlock()
+
+	for _, h := range handlers {
+		h(inv)
+	}
+}
+
+// Stats 获取失效广播统计
+func (b *InvalidationBroadcaster) Stats() InvalidationStats {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	stats := InvalidationStats{
+		Origin:        b.origin,
+		Published:     b.published,
+		PublishErrors: b.publishErrors,
+		Received:      b.received,
+		MaxLatencyMs:  float64(b.maxLatency) / float64(time.Millisecond),
+		LastLatencyMs: float64(b.lastLatency) / float64(time.Millisecond),
+	}
+	if b.received > 0 {
+		stats.AvgLatencyMs = float64(b.totalLatency) / float64(b.received) / float64(time.Millisecond)
+	}
+	return stats
+}
+
+// close 取消订阅
+func (b *InvalidationBroadcaster) close() {
+	if b.cancel != nil {
+		b.cancel()
+	}
+}
+
+// writeTable 返回写语句的目标表名（去掉库名和反引号），不是写语句时返回空字符串
+func writeTable(sql string) string {
+	m := writeTableRegex.FindStringSubmatch(sql)
+	if m == nil {
+		return ""
+	}
+	name := m[1] + m[2] + m[3]
+	if i := strings.LastIndex(name, "."); i >= 0 {
+		name = name[i+1:]
+	}
+	return strings.ToLower(strings.Trim(name, "`"))
+}
+
+// newInstanceID 生成应用实例ID，用于区分失效通知的来源
+func newInstanceID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return fmt.Sprintf("%d", time.Now().UnixNano())
+	}
+	return hex.EncodeToString(b)
+}
+
+// EnableInvalidation 启用缓存失效广播：主库上的写语句会把目标表发布到bus，
+// 通过返回的广播器的 OnInvalidate 注册本地缓存的失效回调；重复调用返回已启用的广播器
+// 代理本身没有读缓存，失效通知供应用层缓存使用
+func (p *DBProxy) EnableInvalidation(bus InvalidationBus) (*InvalidationBroadcaster, error) {
+	p.pool.mu.Lock()
+	defer p.pool.mu.Unlock()
+	if p.pool.invalidation != nil {
+		return p.pool.invalidation, nil
+	}
+
+	b, err := newInvalidationBroadcaster(bus)
+	if err != nil {
+		return nil, err
+	}
+	p.pool.invalidation = b
+	p.pool.observers = append(p.pool.observers, b)
+	return b, nil
+}
+
+// Invalidation 获取缓存失效广播器，未启用时返回nil
+func (p *DBProxy) Invalidation() *InvalidationBroadcaster {
+	p.pool.mu.RLock()
+	defer p.pool.mu.RUnlock()
+	return p.pool.invalidation
+}
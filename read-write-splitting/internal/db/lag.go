@@ -0,0 +1,184 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+)
+
+// 复制延迟检测参数
+const (
+	defaultLagCheckInterval = time.Second // 默认检测间隔
+	lagStaleFactor          = 3           // 测量结果超过该倍数的检测间隔未更新时视为未知
+)
+
+// maxStalenessContextKey 上下文中最大可容忍延迟的键
+type maxStalenessContextKey struct{}
+
+// WithMaxStaleness 在上下文中设置读操作可容忍的最大复制延迟
+// 经 DBProxy.WithContext 绑定后，读操作只会路由到最近测得的延迟低于该值的从库，没有这样的从库时读主库
+func WithMaxStaleness(ctx context.Context, maxStaleness time.Duration) context.Context {
+	return context.WithValue(ctx, maxStalenessContextKey{}, maxStaleness)
+}
+
+// MaxStalenessFromContext 从上下文中获取最大可容忍延迟，未设置时ok为false
+func MaxStalenessFromContext(ctx context.Context) (maxStaleness time.Duration, ok bool) {
+	if ctx == nil {
+		return 0, false
+	}
+	maxStaleness, ok = ctx.Value(maxStalenessContextKey{}).(time.Duration)
+	return maxStaleness, ok
+}
+
+// WithMaxStaleness 返回之后的读操作都只使用延迟低于maxStaleness的从库的代理
+func (p *DBProxy) WithMaxStaleness(maxStaleness time.Duration) *DBProxy {
+	ctx := p.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return p.WithContext(WithMaxStaleness(ctx, maxStaleness))
+}
+
+// Lag 获取节点最近测得的复制延迟及测量时间，从未测得（非从库、复制线程未运行或查询失败）时ok为false
+func (n *Node) Lag() (lag time.Duration, measuredAt time.Time, ok bool) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.lag, n.lagAt, !n.lagAt.IsZero()
+}
+
+// setLag 记录一次复制延迟测量结果，err非nil时清除之前的结果
+func (n *Node) setLag(lag time.Duration, err error) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if err != nil {
+		n.lag, n.lagAt = 0, time.Time{}
+		return
+	}
+	n.lag, n.lagAt = lag, time.Now()
+}
+
+// lagCheckInterval 获取复制延迟检测间隔，0表示不检测
+func (p *DBPool) lagCheckInterval() time.Duration {
+	switch interval := p.config.LagCheckInterval; {
+	case interval < 0:
+		return 0
+	case interval == 0:
+		return defaultLagCheckInterval
+	default:
+		return interval
+	}
+}
+
+// watchLag 定期测量所有从库的复制延迟，直到连接池关闭
+func (p *DBPool) watchLag(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		p.measureLag(interval)
+		select {
+		case <-p.stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// measureLag 测量一轮从库的复制延迟，只在测量结果从可用变为不可用时输出日志
+func (p *DBPool) measureLag(timeout time.Duration) {
+	for _, node := range p.slaveNodes() {
+		lag, err := queryReplicaLag(node, timeout)
+		if _, _, known := node.Lag(); err != nil && known {
+			log.Printf("Replication lag of %s unknown, excluded from max-staleness reads: %v", node.Name, err)
+		}
+		node.setLag(lag, err)
+	}
+}
+
+// errNotReplica 节点上没有配置复制
+var errNotReplica = errors.New("replication is not configured")
+
+// queryReplicaLag 通过 SHOW REPLICA STATUS（旧版本为 SHOW SLAVE STATUS）查询复制延迟
+// 直接使用底层连接执行，不经过GORM回调，检测语句不计入节点统计和SQL指纹
+func queryReplicaLag(node *Node, timeout time.Duration) (time.Duration, error) {
+	sqlDB, err := node.DB.DB()
+	if err != nil {
+		return 0, err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	rows, err := sqlDB.QueryContext(ctx, "SHOW REPLICA STATUS")
+	if err != nil {
+		rows, err = sqlDB.QueryContext(ctx, "SHOW SLAVE STATUS")
+		if err != nil {
+			return 0, fmt.Errorf("failed to query replica status: %w", err)
+		}
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return 0, err
+	}
+	if !rows.Next() {
+		if err := rows.Err(); err != nil {
+			return 0, err
+		}
+		return 0, errNotReplica
+	}
+	values := make([]sql.NullString, len(columns))
+	dest := make([]interface{}, len(columns))
+	for i := range values {
+		dest[i] = &values[i]
+	}
+	if err := rows.Scan(dest...); err != nil {
+		return 0, fmt.Errorf("failed to scan replica status: %w", err)
+	}
+
+	for i, column := range columns {
+		if column != "Seconds_Behind_Source" && column != "Seconds_Behind_Master" {
+			continue
+		}
+		// 复制SQL线程未运行时为NULL，无法判断延迟
+		if !values[i].Valid {
+			return 0, errors.New("replication is not running")
+		}
+		seconds, err := strconv.ParseInt(values[i].String, 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid replication lag %q: %w", values[i].String, err)
+		}
+		return time.Duration(seconds) * time.Second, nil
+	}
+	return 0, errors.New("replica status has no lag column")
+}
+
+// freshSlaves 筛选最近测得的复制延迟低于maxStaleness的从库，测量结果过旧的从库视为延迟未知，不会入选
+func (p *DBPool) freshSlaves(slaves []*Node, maxStaleness time.Duration) []*Node {
+	expiry := time.Duration(lagStaleFactor) * p.lagCheckInterval()
+	fresh := make([]*Node, 0, len(slaves))
+	for _, n := range slaves {
+		lag, measuredAt, ok := n.Lag()
+		if !ok || expiry > 0 && time.Since(measuredAt) > expiry || lag >= maxStaleness {
+			continue
+		}
+		fresh = append(fresh, n)
+	}
+	return fresh
+}
+
+// readCandidates 获取可以承担该查询的从库：查询带有最大可容忍延迟时只保留延迟满足要求的从库
+// 因延迟而筛掉了全部从库时stale为true
+func (p *DBPool) readCandidates(q QueryInfo) (slaves []*Node, stale bool) {
+	slaves = p.slaveNodes()
+	maxStaleness, ok := MaxStalenessFromContext(q.Context)
+	if !ok || len(slaves) == 0 {
+		return slaves, false
+	}
+	fresh := p.freshSlaves(slaves, maxStaleness)
+	return fresh, len(fresh) == 0
+}
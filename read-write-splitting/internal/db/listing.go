@@ -0,0 +1,313 @@
+package db
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 一致性分页会话的默认参数
+const (
+	DefaultListingIdleTimeout = 30 * time.Second // 两次翻页之间的最长间隔
+	DefaultListingMaxLifetime = 5 * time.Minute  // 会话最长存活时间
+	DefaultMaxListings        = 64               // 同时打开的会话上限（每个会话占用一个连接）
+)
+
+var (
+	// ErrListingNotFound 会话不存在（已关闭或已过期被回收）
+	ErrListingNotFound = errors.New("listing session not found")
+	// ErrListingExpired 会话已过期
+	ErrListingExpired = errors.New("listing session expired")
+	// ErrTooManyListings 打开的会话数达到上限
+	ErrTooManyListings = errors.New("too many open listing sessions")
+)
+
+// ListingOptions 打开会话时的过期设置，零值使用默认值
+type ListingOptions struct {
+	IdleTimeout time.Duration // 空闲超时
+	MaxLifetime time.Duration // 最长存活时间
+}
+
+// ListingInfo 会话信息
+type ListingInfo struct {
+	ID          string    `json:"id"`
+	Node        string    `json:"node"`
+	CreatedAt   time.Time `json:"created_at"`
+	LastUsed    time.Time `json:"last_used"`
+	ExpiresAt   time.Time `json:"expires_at"`
+	IdleTimeout string    `json:"idle_timeout"`
+	Pages       int64     `json:"pages"`
+}
+
+// ListingSession 一致性分页会话
+// 会话固定在一个从库上，并在一个 REPEATABLE READ 只读事务中执行所有翻页查询，
+// 第一次查询建立的一致性快照在整个会话内保持不变，因此翻页不会因为切换从库或并发写入而跳过或重复行
+type ListingSession struct {
+	id          string
+	node        *Node
+	tx          *gorm.DB
+	createdAt   time.Time
+	lastUsed    time.Time
+	deadline    time.Time // 由最长存活时间决定的绝对过期时间
+	idleTimeout time.Duration
+	pages       int64
+	closed      bool
+	mu          sync.Mutex
+}
+
+// ID 会话ID
+func (s *ListingSession) ID() string {
+	return s.id
+}
+
+// Node 会话固定的节点名称
+func (s *ListingSession) Node() string {
+	return s.node.Name
+}
+
+// Do 在会话的事务中执行一次查询，同一会话上的查询串行执行
+func (s *ListingSession) Do(fn func(tx *gorm.DB) error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return ErrListingNotFound
+	}
+	now := time.Now()
+	if s.expiredAt(now) {
+		s.closeLocked()
+		return fmt.Errorf("%w: %s", ErrListingExpired, s.id)
+	}
+
+	s.lastUsed = now
+	s.pages++
+	return fn(s.tx)
+}
+
+// Extend 延长会话的最长存活时间
+func (s *ListingSession) Extend(d time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.deadline = s.deadline.Add(d)
+	s.lastUsed = time.Now()
+}
+
+// Close 关闭会话并回滚事务
+func (s *ListingSession) Close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closeLocked()
+}
+
+// closeLocked 在持有锁时关闭会话
+func (s *ListingSession) closeLocked() {
+	if s.closed {
+		return
+	}
+	s.closed = true
+	if err := s.tx.Rollback().Error; err != nil && !errors.Is(err, sql.ErrTxDone) {
+		log.Printf("Failed to release listing session %s: %v", s.id, err)
+	}
+}
+
+// expiredAt 判断会话在给定时间是否已过期
+func (s *ListingSession) expiredAt(now time.Time) bool {
+	return now.After(s.deadline) || now.Sub(s.lastUsed) > s.idleTimeout
+}
+
+// info 生成会话信息
+func (s *ListingSession) info() ListingInfo {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	expiresAt := s.lastUsed.Add(s.idleTimeout)
+	if s.deadline.Before(expiresAt) {
+		expiresAt = s.deadline
+	}
+	return ListingInfo{
+		ID:          s.id,
+		Node:        s.node.Name,
+		CreatedAt:   s.createdAt,
+		LastUsed:    s.lastUsed,
+		ExpiresAt:   expiresAt,
+		IdleTimeout: s.idleTimeout.String(),
+		Pages:       s.pages,
+	}
+}
+
+// ListingManager 管理一致性分页会话，后台定期回收过期会话以释放连接
+type ListingManager struct {
+	pool     *DBPool
+	sessions map[string]*ListingSession
+	max      int
+	stop     chan struct{}
+	mu       sync.Mutex
+}
+
+// NewListingManager 创建会话管理器
+func NewListingManager(pool *DBPool) *ListingManager {
+	return &ListingManager{
+		pool:     pool,
+		sessions: make(map[string]*ListingSession),
+		max:      DefaultMaxListings,
+	}
+}
+
+// SetMaxSessions 设置同时打开的会话上限
+func (m *ListingManager) SetMaxSessions(max int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.max = max
+}
+
+// Open 选择一个从库并打开会话
+func (m *ListingManager) Open(ctx context.Context, opts ListingOptions) (*ListingSession, error) {
+	if opts.IdleTimeout <= 0 {
+		opts.IdleTimeout = DefaultListingIdleTimeout
+	}
+	if opts.MaxLifetime <= 0 {
+		opts.MaxLifetime = DefaultListingMaxLifetime
+	}
+
+	m.mu.Lock()
+	if len(m.sessions) >= m.max {
+		m.mu.Unlock()
+		return nil, fmt.Errorf("%w: limit %d", ErrTooManyListings, m.max)
+	}
+	m.mu.Unlock()
+
+	node := m.pool.slaveNodeFor(QueryInfo{Context: ctx})
+	// 事务在会话生命周期内持续存在，不能绑定到单个请求的上下文
+	tx := node.DB.Begin(&sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true})
+	if tx.Error != nil {
+		return nil, fmt.Errorf("failed to begin listing transaction on %s: %w", node.Name, tx.Error)
+	}
+
+	now := time.Now()
+	session := &ListingSession{
+		id:          newListingID(),
+		node:        node,
+		tx:          tx,
+		createdAt:   now,
+		lastUsed:    now,
+		deadline:    now.Add(opts.MaxLifetime),
+		idleTimeout: opts.IdleTimeout,
+	}
+
+	m.mu.Lock()
+	m.sessions[session.id] = session
+	if m.stop == nil {
+		m.stop = make(chan struct{})
+		go m.reap(m.stop)
+	}
+	m.mu.Unlock()
+
+	return session, nil
+}
+
+// Get 获取会话
+func (m *ListingManager) Get(id string) (*ListingSession, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	session, ok := m.sessions[id]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrListingNotFound, id)
+	}
+	return session, nil
+}
+
+// Close 关闭会话
+func (m *ListingManager) Close(id string) error {
+	m.mu.Lock()
+	session, ok := m.sessions[id]
+	delete(m.sessions, id)
+	m.mu.Unlock()
+
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrListingNotFound, id)
+	}
+	session.Close()
+	return nil
+}
+
+// List 列出所有打开的会话
+func (m *ListingManager) List() []ListingInfo {
+	m.mu.Lock()
+	sessions := make([]*ListingSession, 0, len(m.sessions))
+	for _, s := range m.sessions {
+		sessions = append(sessions, s)
+	}
+	m.mu.Unlock()
+
+	infos := make([]ListingInfo, 0, len(sessions))
+	for _, s := range sessions {
+		infos = append(infos, s.info())
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].CreatedAt.Before(infos[j].CreatedAt) })
+	return infos
+}
+
+// CloseAll 关闭所有会话并停止回收任务
+func (m *ListingManager) CloseAll() {
+	m.mu.Lock()
+	sessions := m.sessions
+	m.sessions = make(map[string]*ListingSession)
+	if m.stop != nil {
+		close(m.stop)
+		m.stop = nil
+	}
+	m.mu.Unlock()
+
+	for _, s := range sessions {
+		s.Close()
+	}
+}
+
+// reap 定期回收过期会话
+func (m *ListingManager) reap(stop chan struct{}) {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case now := <-ticker.C:
+			var expired []*ListingSession
+			m.mu.Lock()
+			for id, s := range m.sessions {
+				s.mu.Lock()
+				if s.closed || s.expiredAt(now) {
+					expired = append(expired, s)
+					delete(m.sessions, id)
+				}
+				s.mu.Unlock()
+			}
+			m.mu.Unlock()
+
+			for _, s := range expired {
+				s.Close()
+				log.Printf("Listing session %s on %s expired", s.id, s.node.Name)
+			}
+		}
+	}
+}
+
+// newListingID 生成随机会话ID
+func newListingID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return fmt.Sprintf("%x", time.Now().UnixNano())
+	}
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,206 @@
+package db
+
+import (
+	"fmt"
+	"log"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// longTxScanInterval 检测长事务的最大扫描间隔，阈值较小时按阈值的一半扫描
+const longTxScanInterval = time.Second
+
+// maxTxStackDepth 记录开启事务调用栈的最大帧数
+const maxTxStackDepth = 32
+
+// TxInfo 一个通过 DBProxy.Transaction 开启、尚未结束的事务
+type TxInfo struct {
+	ID        uint64    `json:"id"`         // 事务序号
+	Node      string    `json:"node"`       // 执行事务的主库节点
+	Tenant    string    `json:"tenant"`     // 所属租户（默认库为空）
+	StartedAt time.Time `json:"started_at"` // 开启时间
+	Age       string    `json:"age"`        // 已持续时间
+	Long      bool      `json:"long"`       // 是否已超过长事务阈值
+	Stack     string    `json:"stack"`      // 开启事务的调用栈
+}
+
+// trackedTx 跟踪中的事务
+type trackedTx struct {
+	id        uint64
+	node      *Node // 开启事务时的主库节点
+	tenant    string
+	startedAt time.Time
+	pcs       []uintptr // 开启事务时的调用栈，告警时才解析为文本
+	alerted   bool      // 是否已经告警过
+}
+
+// stack 将调用栈解析为文本，每帧一行：函数名和文件位置
+func (t *trackedTx) stack() string {
+	var b strings.Builder
+	frames := runtime.CallersFrames(t.pcs)
+	for {
+		frame, more := frames.Next()
+		fmt.Fprintf(&b, "%s\n\t%s:%d\n", frame.Function, frame.File, frame.Line)
+		if !more {
+			break
+		}
+	}
+	return b.String()
+}
+
+// TxTracker 跟踪经代理开启的事务，持续时间超过阈值时记录日志并发出 EventLongTransaction 事件
+// 长事务会一直持有行锁和undo记录：主库切换时旧主库要等它结束才能关闭，
+// InnoDB purge 线程也无法清理比它更新的历史版本（history list length 持续增长）
+type TxTracker struct {
+	pool      *DBPool
+	threshold time.Duration // 长事务阈值，0表示只跟踪不告警
+	nextID    uint64
+	active    map[uint64]*trackedTx
+	mu        sync.Mutex
+}
+
+// newTxTracker 创建事务跟踪器，阈值大于0时启动后台检测
+func newTxTracker(pool *DBPool, threshold time.Duration) *TxTracker {
+	t := &TxTracker{
+		pool:      pool,
+		threshold: threshold,
+		active:    make(map[uint64]*trackedTx),
+	}
+	if threshold > 0 {
+		go t.watch(pool.stop)
+	}
+	return t
+}
+
+// begin 记录一个开启的事务，skip为调用栈中需要跳过的帧数（相对于begin的调用方）
+func (t *TxTracker) begin(node *Node, tenant string, skip int) *trackedTx {
+	pcs := make([]uintptr, maxTxStackDepth)
+	n := runtime.Callers(skip+2, pcs)
+
+	tx := &trackedTx{
+		id:        atomic.AddUint64(&t.nextID, 1),
+		node:      node,
+		tenant:    tenant,
+		startedAt: time.Now(),
+		pcs:       pcs[:n],
+	}
+	t.mu.Lock()
+	t.active[tx.id] = tx
+	t.mu.Unlock()
+	return tx
+}
+
+// end 移除结束的事务，告警过的长事务结束时再记录一次总时长
+func (t *TxTracker) end(tx *trackedTx) {
+	t.mu.Lock()
+	delete(t.active, tx.id)
+	alerted := tx.alerted
+	t.mu.Unlock()
+
+	if alerted {
+		log.Printf("Long transaction #%d on %s finished after %v", tx.id, tx.node.Name, time.Since(tx.startedAt).Round(time.Millisecond))
+	}
+}
+
+// watch 定期扫描进行中的事务，每个超过阈值的事务只告警一次
+func (t *TxTracker) watch(stop <-chan struct{}) {
+	interval := t.threshold / 2
+	if interval > longTxScanInterval {
+		interval = longTxScanInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			t.scan()
+		}
+	}
+}
+
+// scan 找出新超过阈值的事务并告警
+func (t *TxTracker) scan() {
+	now := time.Now()
+	var long []*trackedTx
+
+	t.mu.Lock()
+	for _, tx := range t.active {
+		if !tx.alerted && now.Sub(tx.startedAt) >= t.threshold {
+			tx.alerted = true
+			long = append(long, tx)
+		}
+	}
+	t.mu.Unlock()
+
+	for _, tx := range long {
+		age := now.Sub(tx.startedAt).Round(time.Millisecond)
+		stack := tx.stack()
+		log.Printf("Long transaction #%d on %s open for %v (threshold %v), started at:\n%s", tx.id, tx.node.Name, age, t.threshold, stack)
+		t.pool.emit(PoolEvent{
+			Type:     EventLongTransaction,
+			Node:     tx.node.Name,
+			Time:     now,
+			Duration: age.String(),
+			Stack:    stack,
+		})
+	}
+}
+
+// Active 获取进行中的事务（最早开启的在前）
+func (t *TxTracker) Active() []TxInfo {
+	now := time.Now()
+
+	t.mu.Lock()
+	txs := make([]*trackedTx, 0, len(t.active))
+	for _, tx := range t.active {
+		txs = append(txs, tx)
+	}
+	t.mu.Unlock()
+
+	sort.Slice(txs, func(i, j int) bool { return txs[i].id < txs[j].id })
+	infos := make([]TxInfo, 0, len(txs))
+	for _, tx := range txs {
+		age := now.Sub(tx.startedAt)
+		infos = append(infos, TxInfo{
+			ID:        tx.id,
+			Node:      tx.node.Name,
+			Tenant:    tx.tenant,
+			StartedAt: tx.startedAt,
+			Age:       age.Round(time.Millisecond).String(),
+			Long:      t.threshold > 0 && age >= t.threshold,
+			Stack:     tx.stack(),
+		})
+	}
+	return infos
+}
+
+// openOn 获取指定节点上进行中的事务数及其中最早开启的时间
+func (t *TxTracker) openOn(node *Node) (int, time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	count := 0
+	var oldest time.Time
+	for _, tx := range t.active {
+		if tx.node != node {
+			continue
+		}
+		count++
+		if oldest.IsZero() || tx.startedAt.Before(oldest) {
+			oldest = tx.startedAt
+		}
+	}
+	return count, oldest
+}
+
+// Transactions 获取经代理开启、尚未结束的事务（包括开启时的调用栈）
+func (p *DBProxy) Transactions() []TxInfo {
+	return p.pool.txs.Active()
+}
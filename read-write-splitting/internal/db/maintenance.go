@@ -0,0 +1,188 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"read-write-splitting/internal/config"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// slaveDrainTimeout 替换从库后等待旧连接上在途查询完成的最长时间
+const slaveDrainTimeout = 30 * time.Second
+
+// ErrBackendValidation 新后端未通过替换前的校验
+var ErrBackendValidation = errors.New("backend validation failed")
+
+// BackendValidationError 后端校验失败的详细错误
+type BackendValidationError struct {
+	Role  string // master 或 slave
+	Addr  string // 新后端地址
+	Probe string // 未通过的探测项
+	Cause error  // 探测错误
+}
+
+// Error 实现error接口
+func (e *BackendValidationError) Error() string {
+	return fmt.Sprintf("new %s %s failed %s probe: %v", e.Role, e.Addr, e.Probe, e.Cause)
+}
+
+// Unwrap 支持errors.Is(err, ErrBackendValidation)
+func (e *BackendValidationError) Unwrap() error {
+	return ErrBackendValidation
+}
+
+// validateBackend 在切换路由前对新连接执行校验探测：连通性、能否执行查询，主库还需可写
+func validateBackend(db *gorm.DB, role string, info config.DBInfo) error {
+	fail := func(probe string, err error) error {
+		return &BackendValidationError{Role: role, Addr: nodeAddr(info), Probe: probe, Cause: err}
+	}
+
+	if err := pingDB(db); err != nil {
+		return fail("ping", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), pingTimeout)
+	defer cancel()
+
+	var one int
+	if err := db.WithContext(ctx).Raw("SELECT 1").Scan(&one).Error; err != nil {
+		return fail("query", err)
+	}
+	if role != "master" {
+		return nil
+	}
+
+	var readOnly int
+	if err := db.WithContext(ctx).Raw("SELECT @@global.read_only").Scan(&readOnly).Error; err != nil {
+		return fail("read_only", err)
+	}
+	if readOnly != 0 {
+		return fail("read_only", errors.New("server is read-only"))
+	}
+	return nil
+}
+
+// SwapMaster 连接DSN指向的新主库，校验通过后原子替换主库路由，旧主库在在途查询结束后关闭
+// 用于主库维护（如迁移到新实例），校验失败时不影响当前主库
+func (p *DBPool) SwapMaster(dsn string) error {
+	info, err := config.ParseDSN(dsn)
+	if err != nil {
+		return err
+	}
+	return p.ReplaceMaster(info)
+}
+
+// ReplaceMaster 连接info指向的新主库，校验通过后原子替换主库路由，旧主库在在途查询结束（最长30秒）后关闭
+// 用于外部故障切换（如由编排系统提升了新主库）后让写操作指向新主库而无需重启进程；
+// info.Name为空时沿用当前主库的名称，校验失败时返回 ErrBackendValidation 且不影响当前主库
+func (p *DBPool) ReplaceMaster(info config.DBInfo) error {
+	if info.Name == "" {
+		info.Name = p.masterNode().Name
+	}
+
+	newDB, err := connectDB(info)
+	if err != nil {
+		return fmt.Errorf("failed to connect to new master DB: %w", err)
+	}
+	if err := validateBackend(newDB, "master", info); err != nil {
+		closeDB(newDB)
+		return err
+	}
+
+	p.installMaster(info, newDB)
+	return nil
+}
+
+// ReplaceSlave 将指定名称的从库替换为DSN指向的新实例，新节点沿用原名称和权重
+// 新连接校验通过后原子替换轮询中的节点，旧节点在在途查询结束后关闭；
+// 被替换的从库当前不在轮询中（断线重连、降级启动或手动摘除）时，新节点直接加入轮询，旧节点停止重连或排空后关闭
+func (p *DBPool) ReplaceSlave(name string, dsn string) error {
+	info, err := config.ParseDSN(dsn)
+	if err != nil {
+		return err
+	}
+	info.Name = name
+
+	p.mu.RLock()
+	old := p.detached[name]
+	drained, wasDrained := p.drained[name]
+	if wasDrained {
+		old = drained
+	}
+	for _, n := range p.slaves {
+		if n.Name == name {
+			old = n
+		}
+	}
+	p.mu.RUnlock()
+	if old == nil {
+		return fmt.Errorf("slave %s not found", name)
+	}
+	info.Weight = old.Weight
+
+	node, info, err := p.connectSlave(info, name)
+	if err != nil {
+		return fmt.Errorf("failed to connect to new slave DB: %w", err)
+	}
+	if err := validateBackend(node.DB, "slave", info); err != nil {
+		node.close()
+		return err
+	}
+
+	p.mu.Lock()
+	if old.Retired() {
+		// 并发的替换已经完成
+		p.mu.Unlock()
+		node.close()
+		return fmt.Errorf("slave %s was replaced concurrently", name)
+	}
+	old.retired.Store(true)
+	delete(p.detached, name)
+	delete(p.drained, name)
+
+	slaves := make([]*Node, 0, len(p.slaves)+1)
+	replaced := false
+	for _, n := range p.slaves {
+		if n == old {
+			n = node
+			replaced = true
+		}
+		slaves = append(slaves, n)
+	}
+	if !replaced {
+		slaves = append(slaves, node)
+	}
+	p.slaves = slaves
+	for i := range p.config.Slaves {
+		if p.config.Slaves[i].NodeName(fmt.Sprintf("slave-%d", i)) == name {
+			p.config.Slaves[i] = info
+		}
+	}
+	p.mu.Unlock()
+
+	log.Printf("Slave %s replaced by %s", name, nodeAddr(info))
+	if replaced || wasDrained {
+		go old.drain(slaveDrainTimeout)
+	}
+	// 后台重连中的旧节点由其重连任务在下一次重试时关闭
+	return nil
+}
+
+// SwapMaster 维护操作：将主库替换为DSN指向的新实例，见 DBPool.SwapMaster
+func (p *DBProxy) SwapMaster(dsn string) error {
+	return p.pool.SwapMaster(dsn)
+}
+
+// ReplaceMaster 外部故障切换后将主库替换为info指向的新主库，见 DBPool.ReplaceMaster
+func (p *DBProxy) ReplaceMaster(info config.DBInfo) error {
+	return p.pool.ReplaceMaster(info)
+}
+
+// ReplaceSlave 维护操作：将指定从库替换为DSN指向的新实例，见 DBPool.ReplaceSlave
+func (p *DBProxy) ReplaceSlave(name string, dsn string) error {
+	return p.pool.ReplaceSlave(name, dsn)
+}
@@ -0,0 +1,190 @@
+package db
+
+import (
+	"errors"
+	"log"
+	"read-write-splitting/internal/config"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// latencyAlpha 延迟指数移动平均的平滑系数，越大越偏向最近的样本
+const latencyAlpha = 0.2
+
+// latencyStartKey 在GORM语句实例中记录查询开始时间的键
+const latencyStartKey = "rws:latency_start"
+
+// Node 数据库节点，封装单个连接及其运行时统计信息
+type Node struct {
+	Name   string   // 节点名称
+	Weight int      // 静态权重（用于weighted策略）
+	DB     *gorm.DB // 节点连接
+
+	inFlight   int64         // 在途查询数
+	reads      int64         // 已完成的读语句数
+	writes     int64         // 已完成的写语句数
+	errors     int64         // 出错的语句数（不含记录不存在）
+	mu         sync.Mutex    // 保护统计字段
+	avgLatency time.Duration // 查询延迟的指数移动平均
+	samples    int64         // 已采集的延迟样本数
+	stmts      *StmtCache    // 预处理语句缓存（未启用时为nil）
+	retired    atomic.Bool   // 是否已被替换（见ReplaceSlave），被替换的节点不再加入轮询
+	lag        time.Duration // 最近测得的复制延迟
+	lagAt      time.Time     // 复制延迟的测量时间，零值表示延迟未知
+}
+
+// NodeStats 节点统计信息
+type NodeStats struct {
+	Name         string  // 节点名称
+	AvgLatencyMs float64 // 平均查询延迟(毫秒)
+	Samples      int64   // 延迟样本数
+	InFlight     int64   // 在途查询数
+	Reads        int64   // 已完成的读语句数
+	Writes       int64   // 已完成的写语句数
+	Errors       int64   // 出错的语句数（不含记录不存在）
+	Weight       float64 // 当前生效的选择权重（0~1）
+	LagMs        float64 // 最近测得的复制延迟(毫秒)，-1表示未知
+}
+
+// newNode 创建节点并注册统计回调，节点未配置名称时使用fallback
+func newNode(info config.DBInfo, fallback string, db *gorm.DB) *Node {
+	node := &Node{
+		Name:   info.NodeName(fallback),
+		Weight: info.Weight,
+		DB:     db,
+	}
+	node.registerCallbacks()
+	return node
+}
+
+// registerCallbacks 在节点连接上注册GORM回调，用于采集查询延迟、读写计数和执行查询预算
+func (n *Node) registerCallbacks() {
+	start := func(db *gorm.DB) {
+		atomic.AddInt64(&n.inFlight, 1)
+		db.InstanceSet(latencyStartKey, time.Now())
+	}
+	// end 按语句类型计数，read为nil时（原始SQL）根据SQL判断读写
+	end := func(read *bool) func(db *gorm.DB) {
+		return func(db *gorm.DB) {
+			v, ok := db.InstanceGet(latencyStartKey)
+			if !ok {
+				return
+			}
+			atomic.AddInt64(&n.inFlight, -1)
+			if startAt, ok := v.(time.Time); ok {
+				n.observeLatency(time.Since(startAt))
+			}
+
+			isRead := read != nil && *read || read == nil && isReadOnlyStatement(db.Statement.SQL.String())
+			if isRead {
+				atomic.AddInt64(&n.reads, 1)
+			} else {
+				atomic.AddInt64(&n.writes, 1)
+			}
+			if db.Error != nil && !errors.Is(db.Error, gorm.ErrRecordNotFound) {
+				atomic.AddInt64(&n.errors, 1)
+			}
+		}
+	}
+	read, write := true, false
+
+	cb := n.DB.Callback()
+	err := errors.Join(
+		cb.Create().Before("gorm:create").Register("rws:latency_start", start),
+		cb.Create().After("gorm:create").Register("rws:latency_end", end(&write)),
+		cb.Query().Before("gorm:query").Register("rws:latency_start", start),
+		cb.Query().After("gorm:query").Register("rws:latency_end", end(&read)),
+		cb.Update().Before("gorm:update").Register("rws:latency_start", start),
+		cb.Update().After("gorm:update").Register("rws:latency_end", end(&write)),
+		cb.Delete().Before("gorm:delete").Register("rws:latency_start", start),
+		cb.Delete().After("gorm:delete").Register("rws:latency_end", end(&write)),
+		cb.Row().Before("gorm:row").Register("rws:latency_start", start),
+		cb.Row().After("gorm:row").Register("rws:latency_end", end(&read)),
+		cb.Raw().Before("gorm:raw").Register("rws:latency_start", start),
+		cb.Raw().After("gorm:raw").Register("rws:latency_end", end(nil)),
+	)
+	if err != nil {
+		log.Printf("failed to register latency callbacks on node %s: %v", n.Name, err)
+	}
+
+	if err := registerBudgetCallbacks(n.DB); err != nil {
+		log.Printf("failed to register budget callbacks on node %s: %v", n.Name, err)
+	}
+}
+
+// observeLatency 记录一次查询延迟
+func (n *Node) observeLatency(d time.Duration) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if n.samples == 0 {
+		n.avgLatency = d
+	} else {
+		n.avgLatency = time.Duration(latencyAlpha*float64(d) + (1-latencyAlpha)*float64(n.avgLatency))
+	}
+	n.samples++
+}
+
+// AvgLatency 获取节点的平均查询延迟及样本数
+func (n *Node) AvgLatency() (time.Duration, int64) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.avgLatency, n.samples
+}
+
+// InFlight 获取节点当前在途查询数
+func (n *Node) InFlight() int64 {
+	return atomic.LoadInt64(&n.inFlight)
+}
+
+// Retired 节点是否已被替换
+func (n *Node) Retired() bool {
+	return n.retired.Load()
+}
+
+// stats 生成节点统计信息
+func (n *Node) stats(weight float64) NodeStats {
+	avg, samples := n.AvgLatency()
+	lagMs := -1.0
+	if lag, _, ok := n.Lag(); ok {
+		lagMs = float64(lag) / float64(time.Millisecond)
+	}
+	return NodeStats{
+		Name:         n.Name,
+		AvgLatencyMs: float64(avg) / float64(time.Millisecond),
+		Samples:      samples,
+		InFlight:     n.InFlight(),
+		Reads:        atomic.LoadInt64(&n.reads),
+		Writes:       atomic.LoadInt64(&n.writes),
+		Errors:       atomic.LoadInt64(&n.errors),
+		Weight:       weight,
+		LagMs:        lagMs,
+	}
+}
+
+// drain 等待在途查询结束（或超时）后关闭节点连接
+func (n *Node) drain(timeout time.Duration) {
+	deadline := time.Now().Add(timeout)
+	for n.InFlight() > 0 && time.Now().Before(deadline) {
+		time.Sleep(100 * time.Millisecond)
+	}
+	if inFlight := n.InFlight(); inFlight > 0 {
+		log.Printf("Closing node %s with %d queries still in flight", n.Name, inFlight)
+	}
+	n.close()
+}
+
+// close 关闭节点连接
+func (n *Node) close() {
+	if n.stmts != nil {
+		n.stmts.Purge()
+	}
+	sqlDB, err := n.DB.DB()
+	if err != nil {
+		return
+	}
+	sqlDB.Close()
+}
@@ -0,0 +1,82 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// StatementEvent 一条语句在某个节点上执行完成的事件
+type StatementEvent struct {
+	Time     time.Time       // 完成时间
+	Node     string          // 执行节点
+	Role     string          // 执行节点的角色（master/slave）
+	SQL      string          // 执行的SQL（参数以占位符表示）
+	Vars     []interface{}   // SQL参数
+	Context  context.Context // 请求上下文
+	Duration time.Duration   // 执行耗时
+	Rows     int64           // 影响或返回的行数
+	Err      error           // 执行错误
+}
+
+// StatementObserver 语句执行观察者，用于审计、统计等旁路功能
+// ObserveStatement 在查询路径上同步调用，实现应尽量轻量
+type StatementObserver interface {
+	ObserveStatement(event StatementEvent)
+}
+
+// AddObserver 添加语句执行观察者
+func (p *DBPool) AddObserver(observer StatementObserver) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.observers = append(p.observers, observer)
+}
+
+// notify 将事件分发给所有观察者
+func (p *DBPool) notify(event StatementEvent) {
+	p.mu.RLock()
+	observers := p.observers
+	p.mu.RUnlock()
+
+	for _, o := range observers {
+		o.ObserveStatement(event)
+	}
+}
+
+// attachObservers 在节点上注册语句完成回调
+func (p *DBPool) attachObservers(node *Node) error {
+	after := func(db *gorm.DB) {
+		role := "slave"
+		if p.masterNode() == node {
+			role = "master"
+		}
+		event := StatementEvent{
+			Time:    time.Now(),
+			Node:    node.Name,
+			Role:    role,
+			SQL:     db.Statement.SQL.String(),
+			Vars:    db.Statement.Vars,
+			Context: db.Statement.Context,
+			Rows:    db.RowsAffected,
+			Err:     db.Error,
+		}
+		if v, ok := db.InstanceGet(latencyStartKey); ok {
+			if startAt, ok := v.(time.Time); ok {
+				event.Duration = time.Since(startAt)
+			}
+		}
+		p.notify(event)
+	}
+
+	cb := node.DB.Callback()
+	return errors.Join(
+		cb.Create().After("gorm:create").Register("rws:observe", after),
+		cb.Query().After("gorm:query").Register("rws:observe", after),
+		cb.Update().After("gorm:update").Register("rws:observe", after),
+		cb.Delete().After("gorm:delete").Register("rws:observe", after),
+		cb.Row().After("gorm:row").Register("rws:observe", after),
+		cb.Raw().After("gorm:raw").Register("rws:observe", after),
+	)
+}
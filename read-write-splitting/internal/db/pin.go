@@ -0,0 +1,72 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+
+	"gorm.io/gorm"
+)
+
+// Pin 选择一个从库并返回固定到该节点的代理
+// 一个逻辑操作中的多次读取（先查主记录，再通过 Association().Find 或单独查询加载关联）
+// 使用固定后的代理，所有语句都会发往同一个从库，不会因为各子查询落在不同从库上而看到不一致的数据
+// 节点按读偏好选择；没有满足读偏好的节点时，返回的代理上的每个操作都返回该错误
+func (p *DBProxy) Pin() *DBProxy {
+	if p.pinned != nil {
+		return p
+	}
+	node, err := p.router.readNode(p.queryInfo(""))
+	if err != nil {
+		newProxy := *p
+		newProxy.err = err
+		return &newProxy
+	}
+	return p.pinTo(node)
+}
+
+// PinTo 返回固定到指定节点（节点名称，如 "slave-0" 或 "master"）的代理
+func (p *DBProxy) PinTo(name string) (*DBProxy, error) {
+	node := p.pool.nodeByName(name)
+	if node == nil {
+		return nil, fmt.Errorf("node %s not found", name)
+	}
+	return p.pinTo(node), nil
+}
+
+// Pinned 返回代理固定的读节点名称，未固定时返回空字符串
+func (p *DBProxy) Pinned() string {
+	if p.pinned == nil {
+		return ""
+	}
+	return p.pinned.Name
+}
+
+// ReadSnapshot 在一个固定从库上的 REPEATABLE READ 只读事务中执行一组读取
+// 与Pin相比，所有语句还共享同一个一致性快照，期间复制回放的新数据不会被看到
+func (p *DBProxy) ReadSnapshot(fn func(tx *gorm.DB) error) error {
+	if p.err != nil {
+		return p.err
+	}
+	pinned := p.Pin()
+	return pinned.Slave().Transaction(withSessionTx(p.SessionVars(), fn), &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true})
+}
+
+// pinTo 返回固定到给定节点的代理副本
+func (p *DBProxy) pinTo(node *Node) *DBProxy {
+	pinned := *p
+	pinned.pinned = node
+	return &pinned
+}
+
+// nodeByName 根据名称查找节点（包括主库）
+func (p *DBPool) nodeByName(name string) *Node {
+	if master := p.masterNode(); master.Name == name {
+		return master
+	}
+	for _, n := range p.slaveNodes() {
+		if n.Name == name {
+			return n
+		}
+	}
+	return nil
+}
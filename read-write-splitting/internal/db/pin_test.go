@@ -0,0 +1,178 @@
+package db
+
+import (
+	"context"
+	"testing"
+
+	"gorm.io/gorm"
+)
+
+type testUser struct {
+	ID      uint
+	Name    string
+	Orders  []testOrder `gorm:"foreignKey:UserID"`
+	Profile testProfile `gorm:"foreignKey:UserID"`
+}
+
+type testOrder struct {
+	ID     uint
+	UserID uint
+	Name   string
+}
+
+type testProfile struct {
+	ID     uint
+	UserID uint
+	Name   string
+}
+
+// nodesOf 返回语句涉及的节点集合
+func nodesOf(stmts []recordedStatement) map[string]int {
+	nodes := make(map[string]int)
+	for _, s := range stmts {
+		nodes[s.Node]++
+	}
+	return nodes
+}
+
+func TestPreloadUsesSingleReplica(t *testing.T) {
+	proxy, recorder := newTestProxy(t, 3)
+
+	var users []testUser
+	if err := proxy.Preload("Orders").Find(&users).Error; err != nil {
+		t.Fatalf("preload find: %v", err)
+	}
+
+	stmts := recorder.take()
+	if len(stmts) != 2 {
+		t.Fatalf("expected main query and preload query, got %d statements: %v", len(stmts), stmts)
+	}
+	if nodes := nodesOf(stmts); len(nodes) != 1 {
+		t.Fatalf("preload statements were spread across nodes: %v", stmts)
+	}
+	if stmts[0].Node == "master" {
+		t.Fatalf("preload was routed to master")
+	}
+}
+
+func TestUnpinnedReadsRotateReplicas(t *testing.T) {
+	proxy, recorder := newTestProxy(t, 2)
+
+	var users []testUser
+	proxy.Find(&users)
+	proxy.Find(&users)
+
+	if nodes := nodesOf(recorder.take()); len(nodes) != 2 {
+		t.Fatalf("expected round robin across 2 replicas, got %v", nodes)
+	}
+}
+
+func TestPinKeepsLogicalOperationOnOneReplica(t *testing.T) {
+	proxy, recorder := newTestProxy(t, 3)
+	pinned := proxy.Pin()
+	if pinned.Pinned() == "" || pinned.Pinned() == "master" {
+		t.Fatalf("expected a replica to be pinned, got %q", pinned.Pinned())
+	}
+
+	var user testUser
+	if err := pinned.First(&user).Error; err != nil {
+		t.Fatalf("first: %v", err)
+	}
+	var orders []testOrder
+	if err := pinned.Slave().Model(&user).Association("Orders").Find(&orders); err != nil {
+		t.Fatalf("association find: %v", err)
+	}
+	var users []testUser
+	if err := pinned.Joins("Profile").Preload("Orders").Find(&users).Error; err != nil {
+		t.Fatalf("joins find: %v", err)
+	}
+	if err := pinned.Raw("SELECT * FROM test_orders WHERE user_id = ?", user.ID).Scan(&orders).Error; err != nil {
+		t.Fatalf("raw: %v", err)
+	}
+
+	stmts := recorder.take()
+	if len(stmts) < 5 {
+		t.Fatalf("expected at least 5 statements, got %d: %v", len(stmts), stmts)
+	}
+	for _, s := range stmts {
+		if s.Node != pinned.Pinned() {
+			t.Fatalf("statement %q ran on %s, expected pinned node %s", s.SQL, s.Node, pinned.Pinned())
+		}
+	}
+
+	// 固定后的代理经过WithContext仍然保持固定
+	if got := pinned.WithContext(context.Background()).Pinned(); got != pinned.Pinned() {
+		t.Fatalf("WithContext dropped pin: got %q, want %q", got, pinned.Pinned())
+	}
+	// 重复Pin不会更换节点
+	if got := pinned.Pin().Pinned(); got != pinned.Pinned() {
+		t.Fatalf("Pin on pinned proxy changed node: got %q, want %q", got, pinned.Pinned())
+	}
+}
+
+func TestPinnedWritesGoToMaster(t *testing.T) {
+	proxy, recorder := newTestProxy(t, 2)
+	pinned, err := proxy.PinTo("slave-1")
+	if err != nil {
+		t.Fatalf("pin to slave-1: %v", err)
+	}
+
+	if err := pinned.Create(&testOrder{UserID: 1, Name: "x"}).Error; err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	if err := pinned.Exec("UPDATE test_orders SET name = ? WHERE id = ?", "y", 1).Error; err != nil {
+		t.Fatalf("exec: %v", err)
+	}
+	var orders []testOrder
+	pinned.Find(&orders)
+
+	stmts := recorder.take()
+	if len(stmts) != 3 {
+		t.Fatalf("expected 3 statements, got %d: %v", len(stmts), stmts)
+	}
+	if stmts[0].Node != "master" || stmts[1].Node != "master" {
+		t.Fatalf("writes on pinned proxy must go to master: %v", stmts)
+	}
+	if stmts[2].Node != "slave-1" {
+		t.Fatalf("read ran on %s, expected slave-1", stmts[2].Node)
+	}
+}
+
+func TestPinToUnknownNode(t *testing.T) {
+	proxy, _ := newTestProxy(t, 1)
+
+	if _, err := proxy.PinTo("slave-9"); err == nil {
+		t.Fatal("expected error for unknown node")
+	}
+	pinned, err := proxy.PinTo("master")
+	if err != nil {
+		t.Fatalf("pin to master: %v", err)
+	}
+	if pinned.Pinned() != "master" {
+		t.Fatalf("expected master pin, got %q", pinned.Pinned())
+	}
+}
+
+func TestReadSnapshotUsesOneReplica(t *testing.T) {
+	proxy, recorder := newTestProxy(t, 3)
+
+	err := proxy.ReadSnapshot(func(tx *gorm.DB) error {
+		var user testUser
+		if err := tx.First(&user).Error; err != nil {
+			return err
+		}
+		var orders []testOrder
+		return tx.Model(&user).Association("Orders").Find(&orders)
+	})
+	if err != nil {
+		t.Fatalf("read snapshot: %v", err)
+	}
+
+	stmts := recorder.take()
+	if len(stmts) != 2 {
+		t.Fatalf("expected 2 statements, got %d: %v", len(stmts), stmts)
+	}
+	if nodes := nodesOf(stmts); len(nodes) != 1 || stmts[0].Node == "master" {
+		t.Fatalf("snapshot statements were not pinned to one replica: %v", stmts)
+	}
+}
@@ -0,0 +1,130 @@
+package db
+
+import (
+	"log"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// 用于提取CALL语句中存储过程名称的正则表达式
+var callRegex = regexp.MustCompile("(?i)^\\s*CALL\\s+([`\\w.$]+)")
+
+// isMultiStatement 判断SQL是否包含多条语句（以分号分隔，忽略字符串、标识符和注释中的分号以及末尾的分号）
+func isMultiStatement(sql string) bool {
+	var quote byte
+	ended := false // 已经遇到过一个语句结尾的分号
+	for i := 0; i < len(sql); i++ {
+		c := sql[i]
+		switch {
+		case quote != 0:
+			if c == '\\' && quote != '`' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"' || c == '`':
+			if ended {
+				return true
+			}
+			quote = c
+		case c == '-' && i+1 < len(sql) && sql[i+1] == '-', c == '#':
+			// 单行注释
+			for i < len(sql) && sql[i] != '\n' {
+				i++
+			}
+		case c == '/' && i+1 < len(sql) && sql[i+1] == '*':
+			end := strings.Index(sql[i+2:], "*/")
+			if end < 0 {
+				return false
+			}
+			i += end + 3
+		case c == ';':
+			ended = true
+		case ended && c != ' ' && c != '\t' && c != '\n' && c != '\r':
+			return true
+		}
+	}
+	return false
+}
+
+// procedureName 提取CALL语句调用的存储过程名称（小写、去掉反引号），不是CALL语句时返回空字符串
+func procedureName(sql string) string {
+	m := callRegex.FindStringSubmatch(sql)
+	if m == nil {
+		return ""
+	}
+	return normalizeProcedure(m[1])
+}
+
+// normalizeProcedure 规范化存储过程名称：去掉反引号并转为小写
+func normalizeProcedure(name string) string {
+	return strings.ToLower(strings.ReplaceAll(strings.TrimSpace(name), "`", ""))
+}
+
+// readOnlyProcedures 声明为只读的存储过程，调用这些存储过程的CALL语句按读操作路由
+type readOnlyProcedures struct {
+	mu    sync.RWMutex
+	names map[string]bool
+}
+
+// newReadOnlyProcedures 根据配置创建只读存储过程列表
+func newReadOnlyProcedures(names []string) *readOnlyProcedures {
+	procs := &readOnlyProcedures{names: make(map[string]bool)}
+	for _, name := range names {
+		procs.names[normalizeProcedure(name)] = true
+	}
+	return procs
+}
+
+// contains 判断存储过程是否声明为只读
+// 声明时不带库名的存储过程也匹配带库名的调用（如声明 report_sales 匹配 CALL shop.report_sales()）
+func (p *readOnlyProcedures) contains(name string) bool {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if p.names[name] {
+		return true
+	}
+	if i := strings.LastIndex(name, "."); i >= 0 {
+		return p.names[name[i+1:]]
+	}
+	return false
+}
+
+// IsRead 判断SQL是否按读操作路由：单条SELECT语句，或调用声明为只读的存储过程的CALL语句
+// 多语句批量和其他CALL语句可能包含写操作，都路由到主库
+func (r *SQLRouter) IsRead(sql string) bool {
+	if name := procedureName(sql); name != "" {
+		return !isMultiStatement(sql) && r.procs.contains(name)
+	}
+	return IsReadOperation(sql)
+}
+
+// DeclareReadOnlyProcedure 声明存储过程为只读（只执行查询），之后调用它的CALL语句按读偏好路由到从库
+// name可以带库名；声明错误会导致写操作落到从库，应只声明确实不修改数据的存储过程
+func (r *SQLRouter) DeclareReadOnlyProcedure(name string) {
+	r.procs.mu.Lock()
+	r.procs.names[normalizeProcedure(name)] = true
+	r.procs.mu.Unlock()
+	log.Printf("Procedure %s declared read-only, calls are routed as reads", name)
+}
+
+// UndeclareReadOnlyProcedure 取消存储过程的只读声明，之后调用它的CALL语句路由到主库
+func (r *SQLRouter) UndeclareReadOnlyProcedure(name string) {
+	r.procs.mu.Lock()
+	delete(r.procs.names, normalizeProcedure(name))
+	r.procs.mu.Unlock()
+}
+
+// ReadOnlyProcedures 获取声明为只读的存储过程
+func (r *SQLRouter) ReadOnlyProcedures() []string {
+	r.procs.mu.RLock()
+	defer r.procs.mu.RUnlock()
+	names := make([]string, 0, len(r.procs.names))
+	for name := range r.procs.names {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,150 @@
+package db
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"read-write-splitting/internal/config"
+)
+
+// 内置的路由配置档名称
+const (
+	ProfileLocalDemo         = "local-demo"         // 本地演示：轮询选择，路由结果可预测，断开的从库快速重连
+	ProfileStrictConsistency = "strict-consistency" // 强一致：所有读走主库，从库只作为热备
+	ProfileMaxThroughput     = "max-throughput"     // 最大吞吐：按在途查询数分摊读请求
+)
+
+// RoutingProfile 路由配置档，将一组路由相关的设置打包，运行时一次切换
+// 便于在课程练习中快速对比不同设置下的行为
+type RoutingProfile struct {
+	Name             string             `json:"name"`               // 配置档名称
+	Description      string             `json:"description"`        // 说明
+	Strategy         string             `json:"strategy"`           // 从库选择策略
+	ForceMasterReads bool               `json:"force_master_reads"` // 是否强制所有读操作走主库
+	Reconnect        config.RetryConfig `json:"reconnect"`          // 从库断开后后台重连的退避，零值字段沿用 ConnectRetry
+}
+
+var (
+	profileMu       sync.RWMutex
+	profileRegistry = map[string]RoutingProfile{
+		ProfileLocalDemo: {
+			Name:        ProfileLocalDemo,
+			Description: "round-robin reads for predictable routing, fast replica reconnect",
+			Strategy:    StrategyRoundRobin,
+			Reconnect:   config.RetryConfig{InitialBackoff: 200 * time.Millisecond, MaxBackoff: 2 * time.Second},
+		},
+		ProfileStrictConsistency: {
+			Name:             ProfileStrictConsistency,
+			Description:      "every read goes to the master, replicas are hot standbys only",
+			Strategy:         StrategyLatency,
+			ForceMasterReads: true,
+		},
+		ProfileMaxThroughput: {
+			Name:        ProfileMaxThroughput,
+			Description: "least-connections reads spread load across replicas, patient reconnect",
+			Strategy:    StrategyLeastConn,
+			Reconnect:   config.RetryConfig{InitialBackoff: time.Second, MaxBackoff: 30 * time.Second},
+		},
+	}
+)
+
+// RegisterProfile 注册自定义路由配置档，同名配置档会被覆盖
+func RegisterProfile(profile RoutingProfile) error {
+	if profile.Name == "" {
+		return fmt.Errorf("profile name is required")
+	}
+	if _, err := NewStrategy(profile.Strategy); err != nil {
+		return err
+	}
+
+	profileMu.Lock()
+	defer profileMu.Unlock()
+	profileRegistry[profile.Name] = profile
+	return nil
+}
+
+// LookupProfile 根据名称查找路由配置档
+func LookupProfile(name string) (RoutingProfile, error) {
+	profileMu.RLock()
+	defer profileMu.RUnlock()
+
+	profile, ok := profileRegistry[name]
+	if !ok {
+		return RoutingProfile{}, fmt.Errorf("unknown routing profile: %s", name)
+	}
+	return profile, nil
+}
+
+// Profiles 获取所有已注册的路由配置档（按名称排序）
+func Profiles() []RoutingProfile {
+	profileMu.RLock()
+	defer profileMu.RUnlock()
+
+	profiles := make([]RoutingProfile, 0, len(profileRegistry))
+	for _, p := range profileRegistry {
+		profiles = append(profiles, p)
+	}
+	sort.Slice(profiles, func(i, j int) bool { return profiles[i].Name < profiles[j].Name })
+	return profiles
+}
+
+// ApplyProfile 切换到指定的路由配置档：替换选择策略、强制读主库开关和从库重连退避
+// 正在进行的重连沿用开始时的退避参数
+func (p *DBPool) ApplyProfile(name string) error {
+	profile, err := LookupProfile(name)
+	if err != nil {
+		return err
+	}
+	strategy, err := NewStrategy(profile.Strategy)
+	if err != nil {
+		return err
+	}
+
+	reconnect := profile.Reconnect
+	if reconnect.InitialBackoff <= 0 {
+		reconnect.InitialBackoff = p.config.ConnectRetry.InitialBackoff
+	}
+	if reconnect.MaxBackoff <= 0 {
+		reconnect.MaxBackoff = p.config.ConnectRetry.MaxBackoff
+	}
+
+	p.mu.Lock()
+	p.strategy = strategy
+	p.reconnect = reconnect
+	p.profile = profile.Name
+	p.mu.Unlock()
+	p.forceMasterReads.Store(profile.ForceMasterReads)
+
+	retry := withRetryDefaults(reconnect)
+	log.Printf("Routing profile %s applied: strategy=%s force-master=%v reconnect backoff=%v..%v",
+		profile.Name, strategy.Name(), profile.ForceMasterReads, retry.InitialBackoff, retry.MaxBackoff)
+	return nil
+}
+
+// Profile 获取当前生效的路由配置档名称，未使用配置档时为空
+// 之后单独修改策略或强制读主库开关不会清除该名称
+func (p *DBPool) Profile() string {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.profile
+}
+
+// reconnectPolicy 获取从库后台重连的退避参数（已填充默认值）
+func (p *DBPool) reconnectPolicy() config.RetryConfig {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return withRetryDefaults(p.reconnect)
+}
+
+// ApplyProfile 切换路由配置档，见 DBPool.ApplyProfile
+func (p *DBProxy) ApplyProfile(name string) error {
+	return p.pool.ApplyProfile(name)
+}
+
+// Profile 获取当前生效的路由配置档名称
+func (p *DBProxy) Profile() string {
+	return p.pool.Profile()
+}
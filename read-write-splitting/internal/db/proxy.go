@@ -3,17 +3,25 @@ package db
 import (
 	"context"
 	"read-write-splitting/internal/config"
+	"time"
 
 	"gorm.io/gorm"
 )
 
 // DBProxy 数据库代理，封装读写分离逻辑
 type DBProxy struct {
-	router *SQLRouter // SQL路由器
-	pool   *DBPool    // 数据库连接池
+	router  *SQLRouter      // SQL路由器
+	pool    *DBPool         // 数据库连接池
+	ctx     context.Context // 请求上下文（可选）
+	pinned  *Node           // 固定的读节点（可选），见Pin
+	tenant  string          // 当前路由到的租户（为空表示默认库）
+	tenants *tenantRegistry // 租户连接池（所有副本共享）
+	service string          // 发起查询的服务（见ForService），决定使用哪个服务的读偏好
+	err     error           // 绑定上下文时产生的错误（如未知租户），之后的操作都返回该错误
 }
 
 // NewDBProxy 创建新的数据库代理
+// 配置了租户时，每个租户创建独立的连接池，通过 WithTenant 标记的上下文选择
 func NewDBProxy(config *config.DBConfig) (*DBProxy, error) {
 	// 初始化数据库连接池
 	pool, err := NewDBPool(config)
@@ -24,20 +32,41 @@ func NewDBProxy(config *config.DBConfig) (*DBProxy, error) {
 	// 创建路由器
 	router := NewSQLRouter(pool)
 
+	tenants, err := newTenantRegistry(config, tenantPool{pool: pool, router: router})
+	if err != nil {
+		pool.Close()
+		return nil, err
+	}
+
 	return &DBProxy{
-		router: router,
-		pool:   pool,
+		router:  router,
+		pool:    pool,
+		tenants: tenants,
 	}, nil
 }
 
 // Master 获取主库连接
 func (p *DBProxy) Master() *gorm.DB {
-	return p.router.WriteDB()
+	return p.bind(p.router.WriteDB())
 }
 
-// Slave 获取从库连接
+// Slave 获取从库连接，代理已固定读节点时总是返回该节点
 func (p *DBProxy) Slave() *gorm.DB {
-	return p.router.ReadDB()
+	if p.pinned != nil {
+		return p.bind(p.pinned.DB)
+	}
+	return p.bind(p.router.ReadDBFor(p.queryInfo("")))
+}
+
+// bind 将代理上的上下文绑定到连接
+func (p *DBProxy) bind(db *gorm.DB) *gorm.DB {
+	if p.ctx != nil {
+		db = db.WithContext(p.ctx)
+	}
+	if p.err != nil {
+		return failed(db, p.err)
+	}
+	return db
 }
 
 // DB 根据操作类型自动选择数据库连接
@@ -84,31 +113,173 @@ func (p *DBProxy) Take(dest interface{}, conds ...interface{}) *gorm.DB {
 	return p.Slave().Take(dest, conds...)
 }
 
+// Preload 预加载关联（读操作），主查询和所有预加载子查询都在同一个从库上执行
+func (p *DBProxy) Preload(query string, args ...interface{}) *gorm.DB {
+	return p.Slave().Preload(query, args...)
+}
+
+// Joins 关联查询（读操作）
+func (p *DBProxy) Joins(query string, args ...interface{}) *gorm.DB {
+	return p.Slave().Joins(query, args...)
+}
+
 // Raw 执行原始SQL
 func (p *DBProxy) Raw(sql string, values ...interface{}) *gorm.DB {
-	return p.router.Route(sql).Raw(sql, values...)
+	return p.bind(p.route(sql)).Raw(sql, values...)
 }
 
 // Exec 执行原始SQL
 func (p *DBProxy) Exec(sql string, values ...interface{}) *gorm.DB {
-	return p.router.Route(sql).Exec(sql, values...)
+	return p.bind(p.route(sql)).Exec(sql, values...)
+}
+
+// route 路由原始SQL，代理已固定读节点时读操作使用该节点
+func (p *DBProxy) route(sql string) *gorm.DB {
+	if p.pinned != nil && p.router.IsRead(sql) {
+		return p.pinned.DB
+	}
+	return p.router.RouteQuery(p.queryInfo(sql))
+}
+
+// Write 在主库上执行写操作，主库不可用时按配置排队（queued=true）或返回 ErrMasterUnavailable
+// 排队的写操作在主库恢复后执行，届时请求已结束，因此不绑定代理上的上下文
+func (p *DBProxy) Write(fn WriteFunc) (queued bool, err error) {
+	if p.err != nil {
+		return false, p.err
+	}
+	return p.pool.Write(fn)
+}
+
+// MasterStatus 获取主库可用性状态
+func (p *DBProxy) MasterStatus() MasterStatus {
+	return p.pool.MasterStatus()
 }
 
 // Transaction 执行事务（总是使用主库）
 func (p *DBProxy) Transaction(fc func(tx *gorm.DB) error) error {
-	return p.Master().Transaction(fc)
+	if p.err != nil {
+		return p.err
+	}
+	// 开启事务不经过GORM回调，需要单独做快速失败和连接错误检测
+	if err := p.pool.availability.unavailableError(); err != nil {
+		return err
+	}
+	tracked := p.pool.txs.begin(p.pool.masterNode(), p.tenant, 1)
+	defer p.pool.txs.end(tracked)
+
+	err := p.Master().Transaction(withSessionTx(p.SessionVars(), fc))
+	if isConnectionError(err) {
+		p.pool.availability.markDown(err)
+	}
+	return err
 }
 
 // WithContext 设置上下文
+// 上下文会传递给后续的每条查询，可携带查询预算（见WithQueryBudget）；
+// 上下文标记了租户（见WithTenant）时，之后的读写都在该租户的主从库内路由，
+// 租户不存在时之后的每个操作都返回 ErrUnknownTenant
 func (p *DBProxy) WithContext(ctx context.Context) *DBProxy {
-	newProxy := &DBProxy{
-		router: p.router,
-		pool:   p.pool,
+	newProxy := *p
+	newProxy.ctx = ctx
+	if tenant := TenantFromContext(ctx); tenant != "" {
+		routed, err := p.withTenant(tenant)
+		if err != nil {
+			newProxy.err = err
+			return &newProxy
+		}
+		newProxy = *routed
+		newProxy.ctx = ctx
 	}
-	return newProxy
+	return &newProxy
+}
+
+// OpenListing 打开一致性分页会话，会话内的所有翻页查询固定在同一从库的同一事务中执行
+func (p *DBProxy) OpenListing(opts ListingOptions) (*ListingSession, error) {
+	if p.err != nil {
+		return nil, p.err
+	}
+	return p.pool.Listings().Open(p.ctx, opts)
+}
+
+// Listing 获取已打开的一致性分页会话
+func (p *DBProxy) Listing(id string) (*ListingSession, error) {
+	return p.pool.Listings().Get(id)
+}
+
+// CloseListing 关闭一致性分页会话
+func (p *DBProxy) CloseListing(id string) error {
+	return p.pool.Listings().Close(id)
+}
+
+// Listings 列出所有打开的一致性分页会话
+func (p *DBProxy) Listings() []ListingInfo {
+	return p.pool.Listings().List()
+}
+
+// Router 获取SQL路由器（用于注册SQL改写钩子等）
+func (p *DBProxy) Router() *SQLRouter {
+	return p.router
+}
+
+// SetStrategy 替换从库选择策略
+func (p *DBProxy) SetStrategy(strategy SelectionStrategy) {
+	p.pool.SetStrategy(strategy)
+}
+
+// AddAuditSink 添加语句审计输出目标，添加后每条执行的语句都会被记录
+func (p *DBProxy) AddAuditSink(sink AuditSink) {
+	p.pool.Auditor().AddSink(sink)
+}
+
+// Digests 获取SQL指纹统计
+func (p *DBProxy) Digests() *DigestCollector {
+	return p.pool.Digests()
+}
+
+// ApplyTopology 应用ha-switcher推送的拓扑变化
+func (p *DBProxy) ApplyTopology(t Topology) error {
+	return p.pool.ApplyTopology(t)
+}
+
+// WatchTopology 启动对ha-switcher拓扑API的轮询，返回的订阅器需由调用方停止
+func (p *DBProxy) WatchTopology(statusURL string, interval time.Duration) *TopologyWatcher {
+	watcher := NewTopologyWatcher(p.pool, statusURL, interval)
+	watcher.Start()
+	return watcher
+}
+
+// Stats 获取代理统计信息（当前租户的连接池）：各节点在途查询、读写与错误计数、回落到主库的次数和当前策略
+func (p *DBProxy) Stats() ProxyStats {
+	return p.pool.ProxyStats()
+}
+
+// PoolStats 获取连接池统计信息（各节点延迟与生效权重）
+func (p *DBProxy) PoolStats() PoolStats {
+	return p.pool.Stats()
+}
+
+// StmtCacheStats 获取各节点的预处理语句缓存统计
+func (p *DBProxy) StmtCacheStats() []StmtCacheStats {
+	return p.pool.StmtCacheStats()
+}
+
+// PurgeStmtCache 清空所有节点缓存的预处理语句
+func (p *DBProxy) PurgeStmtCache() {
+	p.pool.PurgeStmtCache()
+}
+
+// Chaos 获取混沌注入器，可按节点注入延迟和错误
+func (p *DBProxy) Chaos() *ChaosInjector {
+	return p.pool.Chaos()
+}
+
+// StartupReport 获取连接池启动报告
+func (p *DBProxy) StartupReport() StartupReport {
+	return p.pool.StartupReport()
 }
 
-// Close 关闭所有数据库连接
+// Close 关闭所有数据库连接（包括所有租户的连接池）
 func (p *DBProxy) Close() {
-	p.pool.Close()
+	p.tenants.fallback.pool.Close()
+	p.tenants.close()
 }
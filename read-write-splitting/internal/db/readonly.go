@@ -0,0 +1,71 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// ErrWriteOnReplica 写操作被路由到了从库连接
+var ErrWriteOnReplica = errors.New("write operation rejected on replica")
+
+// WriteOnReplicaError 从库写拦截的详细错误
+type WriteOnReplicaError struct {
+	Node string // 从库节点名称
+	SQL  string // 被拦截的语句（为空表示由Create/Update/Delete发起）
+}
+
+// Error 实现error接口
+func (e *WriteOnReplicaError) Error() string {
+	if e.SQL == "" {
+		return fmt.Sprintf("write operation rejected on replica %s", e.Node)
+	}
+	return fmt.Sprintf("write operation rejected on replica %s: %s", e.Node, e.SQL)
+}
+
+// Unwrap 支持errors.Is(err, ErrWriteOnReplica)
+func (e *WriteOnReplicaError) Unwrap() error {
+	return ErrWriteOnReplica
+}
+
+// 只读语句前缀及会产生锁或写入的SELECT子句
+var (
+	readOnlyRegex     = regexp.MustCompile(`(?i)^\s*(SELECT|SHOW|EXPLAIN|DESCRIBE|DESC)\b`)
+	lockingReadRegex  = regexp.MustCompile(`(?i)\bFOR\s+(UPDATE|SHARE)\b|\bLOCK\s+IN\s+SHARE\s+MODE\b|\bINTO\s+(OUTFILE|DUMPFILE)\b`)
+	leadingParenRegex = regexp.MustCompile(`^[\s(]+`)
+)
+
+// isReadOnlyStatement 判断语句是否可以安全地在从库上执行
+func isReadOnlyStatement(sql string) bool {
+	sql = leadingParenRegex.ReplaceAllString(strings.TrimSpace(sql), "")
+	if !readOnlyRegex.MatchString(sql) {
+		return false
+	}
+	return !lockingReadRegex.MatchString(sql)
+}
+
+// registerReadOnlyGuard 在从库节点上注册写拦截回调，在语句到达MySQL之前拒绝写操作
+func registerReadOnlyGuard(node *Node) error {
+	rejectAll := func(db *gorm.DB) {
+		db.AddError(&WriteOnReplicaError{Node: node.Name})
+	}
+	checkSQL := func(db *gorm.DB) {
+		sql := db.Statement.SQL.String()
+		if sql != "" && !isReadOnlyStatement(sql) {
+			db.AddError(&WriteOnReplicaError{Node: node.Name, SQL: normalizeSQL(sql)})
+		}
+	}
+
+	cb := node.DB.Callback()
+	return errors.Join(
+		cb.Create().Before("gorm:create").Register("rws:readonly_guard", rejectAll),
+		cb.Update().Before("gorm:update").Register("rws:readonly_guard", rejectAll),
+		cb.Delete().Before("gorm:delete").Register("rws:readonly_guard", rejectAll),
+		cb.Query().Before("gorm:query").Register("rws:readonly_guard", checkSQL),
+		cb.Row().Before("gorm:row").Register("rws:readonly_guard", checkSQL),
+		cb.Raw().Before("gorm:raw").Register("rws:readonly_guard", checkSQL),
+	)
+}
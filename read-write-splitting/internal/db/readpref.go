@@ -0,0 +1,267 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+
+	"read-write-splitting/internal/config"
+)
+
+// ReadPreference 读偏好，决定读操作在主库和从库之间如何选择（参照MongoDB的读偏好）
+type ReadPreference string
+
+// 支持的读偏好
+const (
+	ReadPrimary            ReadPreference = "primary"            // 总是读主库
+	ReadPrimaryPreferred   ReadPreference = "primaryPreferred"   // 优先读主库，主库不可用时读从库
+	ReadSecondary          ReadPreference = "secondary"          // 只读从库，没有可用从库时返回 ErrNoReplicaAvailable
+	ReadSecondaryPreferred ReadPreference = "secondaryPreferred" // 优先读从库，没有可用从库时读主库（默认）
+	ReadNearest            ReadPreference = "nearest"            // 在主库和从库中选延迟最低的节点
+)
+
+// nearestLatencyWindow nearest读偏好的延迟窗口：延迟不超过最低延迟加该值的节点都是候选，在其中随机选择
+const nearestLatencyWindow = 15 * time.Millisecond
+
+// ErrNoReplicaAvailable 读偏好要求读从库，但当前没有可用的从库
+var ErrNoReplicaAvailable = errors.New("no replica available")
+
+// readPreferenceContextKey 上下文中读偏好的键
+type readPreferenceContextKey struct{}
+
+// ParseReadPreference 解析读偏好名称，空字符串表示默认的 secondaryPreferred
+func ParseReadPreference(name string) (ReadPreference, error) {
+	switch pref := ReadPreference(name); pref {
+	case "":
+		return ReadSecondaryPreferred, nil
+	case ReadPrimary, ReadPrimaryPreferred, ReadSecondary, ReadSecondaryPreferred, ReadNearest:
+		return pref, nil
+	}
+	return "", fmt.Errorf("unknown read preference: %s", name)
+}
+
+// WithReadPreference 在上下文中设置读偏好，经 DBProxy.WithContext 绑定后对之后的每个读操作生效，
+// 优先级高于按服务配置的读偏好
+func WithReadPreference(ctx context.Context, pref ReadPreference) context.Context {
+	return context.WithValue(ctx, readPreferenceContextKey{}, pref)
+}
+
+// ReadPreferenceFromContext 从上下文中获取读偏好，未设置时返回空字符串
+func ReadPreferenceFromContext(ctx context.Context) ReadPreference {
+	if ctx == nil {
+		return ""
+	}
+	pref, _ := ctx.Value(readPreferenceContextKey{}).(ReadPreference)
+	return pref
+}
+
+// validateReadPreferences 校验配置中的默认读偏好和按服务配置的读偏好
+func validateReadPreferences(cfg *config.DBConfig) error {
+	if _, err := ParseReadPreference(cfg.ReadPreference); err != nil {
+		return err
+	}
+	for service, name := range cfg.ServiceReadPreferences {
+		if _, err := ParseReadPreference(name); err != nil {
+			return fmt.Errorf("service %s: %w", service, err)
+		}
+	}
+	return nil
+}
+
+// readPreferences 路由器的读偏好设置：默认值及按服务的覆盖
+type readPreferences struct {
+	mu       sync.RWMutex
+	fallback ReadPreference            // 默认读偏好
+	services map[string]ReadPreference // 按服务名的读偏好
+}
+
+// newReadPreferences 根据配置创建读偏好设置（配置已在创建连接池时校验）
+func newReadPreferences(cfg *config.DBConfig) *readPreferences {
+	prefs := &readPreferences{services: make(map[string]ReadPreference)}
+	prefs.fallback, _ = ParseReadPreference(cfg.ReadPreference)
+	for service, name := range cfg.ServiceReadPreferences {
+		if pref, err := ParseReadPreference(name); err == nil {
+			prefs.services[service] = pref
+		}
+	}
+	return prefs
+}
+
+// ReadPreference 获取查询生效的读偏好：上下文中的设置优先，其次是服务的配置，最后是默认值
+func (r *SQLRouter) ReadPreference(q QueryInfo) ReadPreference {
+	if pref := ReadPreferenceFromContext(q.Context); pref != "" {
+		return pref
+	}
+	r.prefs.mu.RLock()
+	defer r.prefs.mu.RUnlock()
+	if pref, ok := r.prefs.services[q.Service]; ok && q.Service != "" {
+		return pref
+	}
+	return r.prefs.fallback
+}
+
+// DefaultReadPreference 获取默认读偏好
+func (r *SQLRouter) DefaultReadPreference() ReadPreference {
+	r.prefs.mu.RLock()
+	defer r.prefs.mu.RUnlock()
+	return r.prefs.fallback
+}
+
+// SetReadPreference 设置默认读偏好，pref为空时恢复为 secondaryPreferred
+func (r *SQLRouter) SetReadPreference(pref ReadPreference) error {
+	pref, err := ParseReadPreference(string(pref))
+	if err != nil {
+		return err
+	}
+	r.prefs.mu.Lock()
+	r.prefs.fallback = pref
+	r.prefs.mu.Unlock()
+	log.Printf("Default read preference set to %s", pref)
+	return nil
+}
+
+// SetServiceReadPreference 设置服务的读偏好，pref为空时删除该服务的设置（使用默认值）
+func (r *SQLRouter) SetServiceReadPreference(service string, pref ReadPreference) error {
+	r.prefs.mu.Lock()
+	defer r.prefs.mu.Unlock()
+	if pref == "" {
+		delete(r.prefs.services, service)
+		return nil
+	}
+	if _, err := ParseReadPreference(string(pref)); err != nil {
+		return err
+	}
+	r.prefs.services[service] = pref
+	return nil
+}
+
+// ServiceReadPreferences 获取按服务配置的读偏好
+func (r *SQLRouter) ServiceReadPreferences() map[string]ReadPreference {
+	r.prefs.mu.RLock()
+	defer r.prefs.mu.RUnlock()
+	services := make(map[string]ReadPreference, len(r.prefs.services))
+	for service, pref := range r.prefs.services {
+		services[service] = pref
+	}
+	return services
+}
+
+// readNode 按读偏好选择读节点，查询携带一致性令牌（见 WithConsistencyToken）时再确认选中的从库已经追上
+func (r *SQLRouter) readNode(q QueryInfo) (*Node, error) {
+	node, err := r.preferredNode(q)
+	if err != nil || ConsistencyTokenFromContext(q.Context) == "" {
+		return node, err
+	}
+	candidates, _ := r.dbPool.readCandidates(q)
+	return r.dbPool.consistentNode(q, node, candidates), nil
+}
+
+// preferredNode 按读偏好选择读节点
+// 运维打开强制读主库开关（见 SetForceMasterReads）时，除 primary 外的读偏好也都走主库；
+// 查询带有最大可容忍延迟（见 WithMaxStaleness）时，延迟不满足要求的从库不参与选择
+func (r *SQLRouter) preferredNode(q QueryInfo) (*Node, error) {
+	pool := r.dbPool
+	pref := r.ReadPreference(q)
+	switch pref {
+	case ReadPrimary:
+		return pool.masterNode(), nil
+
+	case ReadPrimaryPreferred:
+		if pool.availability.unavailableError() == nil {
+			return pool.masterNode(), nil
+		}
+		return pool.slaveNodeFor(q), nil
+
+	case ReadSecondary:
+		if pool.forceMasterReads.Load() {
+			return pool.masterNode(), nil
+		}
+		slaves, stale := pool.readCandidates(q)
+		if len(slaves) > 0 {
+			if node := pool.Strategy().Pick(slaves, q); node != nil {
+				return node, nil
+			}
+		}
+		if stale {
+			maxStaleness, _ := MaxStalenessFromContext(q.Context)
+			return nil, fmt.Errorf("%w for read preference %s within max staleness %v", ErrNoReplicaAvailable, pref, maxStaleness)
+		}
+		return nil, fmt.Errorf("%w for read preference %s", ErrNoReplicaAvailable, pref)
+
+	case ReadNearest:
+		if pool.forceMasterReads.Load() {
+			return pool.masterNode(), nil
+		}
+		candidates, _ := pool.readCandidates(q)
+		if pool.availability.unavailableError() == nil {
+			candidates = append([]*Node{pool.masterNode()}, candidates...)
+		}
+		if len(candidates) == 0 {
+			return pool.masterNode(), nil
+		}
+		return pickNearest(candidates), nil
+	}
+
+	return pool.slaveNodeFor(q), nil
+}
+
+// pickNearest 在延迟窗口内的节点中随机选择一个，尚无延迟样本的节点总是候选，以便采集到延迟
+func pickNearest(nodes []*Node) *Node {
+	type sampled struct {
+		node    *Node
+		latency time.Duration
+	}
+	var known []sampled
+	var eligible []*Node
+	for _, n := range nodes {
+		avg, samples := n.AvgLatency()
+		if samples == 0 {
+			eligible = append(eligible, n)
+			continue
+		}
+		known = append(known, sampled{node: n, latency: avg})
+	}
+
+	if len(known) > 0 {
+		sort.Slice(known, func(i, j int) bool { return known[i].latency < known[j].latency })
+		limit := known[0].latency + nearestLatencyWindow
+		for _, s := range known {
+			if s.latency > limit {
+				break
+			}
+			eligible = append(eligible, s.node)
+		}
+	}
+	return eligible[rand.Intn(len(eligible))]
+}
+
+// ForService 返回以指定服务身份路由的代理，读操作使用该服务配置的读偏好（见 DBConfig.ServiceReadPreferences）
+func (p *DBProxy) ForService(service string) *DBProxy {
+	newProxy := *p
+	newProxy.service = service
+	return &newProxy
+}
+
+// WithReadPreference 返回之后的读操作都使用指定读偏好的代理
+func (p *DBProxy) WithReadPreference(pref ReadPreference) *DBProxy {
+	ctx := p.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return p.WithContext(WithReadPreference(ctx, pref))
+}
+
+// ReadPreference 获取代理当前读操作生效的读偏好
+func (p *DBProxy) ReadPreference() ReadPreference {
+	return p.router.ReadPreference(p.queryInfo(""))
+}
+
+// queryInfo 生成路由所需的查询信息
+func (p *DBProxy) queryInfo(sql string) QueryInfo {
+	return QueryInfo{SQL: sql, Context: p.ctx, Service: p.service}
+}
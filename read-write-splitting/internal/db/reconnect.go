@@ -0,0 +1,189 @@
+package db
+
+import (
+	"errors"
+	"log"
+	"math/rand"
+	"read-write-splitting/internal/config"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// maxPoolEvents 保留的最近节点事件数
+const maxPoolEvents = 100
+
+// 节点事件类型
+const (
+	EventReplicaLeft     = "replica_left"     // 从库因连接错误移出轮询
+	EventReplicaRejoined = "replica_rejoined" // 从库重连成功，重新加入轮询
+	EventLongTransaction = "long_transaction" // 事务持续时间超过阈值（见 DBConfig.LongTxThreshold）
+)
+
+// PoolEvent 连接池中节点进出轮询以及长事务告警事件
+type PoolEvent struct {
+	Type     string    `json:"type"`               // 事件类型
+	Node     string    `json:"node"`               // 节点名称
+	Time     time.Time `json:"time"`               // 事件时间
+	Error    string    `json:"error,omitempty"`    // 移出时的连接错误
+	Attempts int       `json:"attempts,omitempty"` // 重新加入前的重连次数
+	Downtime string    `json:"downtime,omitempty"` // 离开轮询的时长
+	Duration string    `json:"duration,omitempty"` // 长事务已持续的时间
+	Stack    string    `json:"stack,omitempty"`    // 长事务开启时的调用栈
+}
+
+// PoolEventListener 节点事件监听者，OnPoolEvent 在后台任务中同步调用，实现应尽量轻量
+type PoolEventListener interface {
+	OnPoolEvent(event PoolEvent)
+}
+
+// AddEventListener 添加节点事件监听者
+func (p *DBPool) AddEventListener(listener PoolEventListener) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.listeners = append(p.listeners, listener)
+}
+
+// Events 获取最近的节点事件（按时间先后）
+func (p *DBPool) Events() []PoolEvent {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return append([]PoolEvent(nil), p.events...)
+}
+
+// emit 记录事件并分发给所有监听者
+func (p *DBPool) emit(event PoolEvent) {
+	p.mu.Lock()
+	p.events = append(p.events, event)
+	if len(p.events) > maxPoolEvents {
+		p.events = p.events[len(p.events)-maxPoolEvents:]
+	}
+	listeners := p.listeners
+	p.mu.Unlock()
+
+	for _, l := range listeners {
+		l.OnPoolEvent(event)
+	}
+}
+
+// jitterBackoff 在等待时间的50%~100%之间随机取值，避免多个从库同时重连
+func jitterBackoff(d time.Duration) time.Duration {
+	half := d / 2
+	return half + time.Duration(rand.Int63n(int64(half)+1))
+}
+
+// attachReplicaGuard 在从库节点上注册连接错误检测回调，连接断开时将从库移出轮询并在后台重连
+func (p *DBPool) attachReplicaGuard(node *Node, info config.DBInfo) error {
+	detect := func(db *gorm.DB) {
+		if isConnectionError(db.Error) {
+			p.detachSlave(node, info, db.Error)
+		}
+	}
+
+	cb := node.DB.Callback()
+	return errors.Join(
+		cb.Query().After("gorm:query").Register("rws:replica_detect", detect),
+		cb.Row().After("gorm:row").Register("rws:replica_detect", detect),
+		cb.Raw().After("gorm:raw").Register("rws:replica_detect", detect),
+	)
+}
+
+// detachSlave 将从库移出轮询并启动后台重连，同一节点只会有一个重连任务
+func (p *DBPool) detachSlave(node *Node, info config.DBInfo, cause error) {
+	p.mu.Lock()
+	index := -1
+	for i, n := range p.slaves {
+		if n == node {
+			index = i
+			break
+		}
+	}
+	if index < 0 {
+		// 已经移出（其他查询先检测到）或节点已被替换
+		p.mu.Unlock()
+		return
+	}
+	slaves := make([]*Node, 0, len(p.slaves)-1)
+	slaves = append(slaves, p.slaves[:index]...)
+	p.slaves = append(slaves, p.slaves[index+1:]...)
+	p.detached[node.Name] = node
+	p.mu.Unlock()
+
+	left := time.Now()
+	log.Printf("Slave %s removed from rotation after connection error: %v", node.Name, cause)
+	p.emit(PoolEvent{Type: EventReplicaLeft, Node: node.Name, Time: left, Error: cause.Error()})
+
+	go p.reconnectSlave(node, info, left)
+}
+
+// reconnectSlave 以带抖动的指数退避持续探测从库，连通后重新加入轮询
+// 退避参数默认沿用启动探测的 ConnectRetry 配置（InitialBackoff/MaxBackoff），可由路由配置档修改，重连次数不设上限
+func (p *DBPool) reconnectSlave(node *Node, info config.DBInfo, left time.Time) {
+	retry := p.reconnectPolicy()
+	backoff := retry.InitialBackoff
+	for attempt := 1; ; attempt++ {
+		select {
+		case <-p.stop:
+			node.close()
+			return
+		case <-time.After(jitterBackoff(backoff)):
+		}
+		if node.Retired() {
+			// 重连期间节点已被 ReplaceSlave 替换
+			node.close()
+			return
+		}
+
+		if err := pingDB(node.DB); err != nil {
+			backoff = nextBackoff(backoff, retry.MaxBackoff)
+			continue
+		}
+
+		if !p.rejoinSlave(node) {
+			node.close()
+			return
+		}
+		downtime := time.Since(left).Round(time.Millisecond)
+		log.Printf("Slave %s (%s) reconnected after %d attempts, back in rotation (down %v)", node.Name, nodeAddr(info), attempt, downtime)
+		p.emit(PoolEvent{
+			Type:     EventReplicaRejoined,
+			Node:     node.Name,
+			Time:     time.Now(),
+			Attempts: attempt,
+			Downtime: downtime.String(),
+		})
+		return
+	}
+}
+
+// rejoinSlave 将从库重新加入轮询（写时复制），节点已被替换时返回false
+func (p *DBPool) rejoinSlave(node *Node) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if node.Retired() {
+		return false
+	}
+	if p.detached[node.Name] == node {
+		delete(p.detached, node.Name)
+	}
+	for _, n := range p.slaves {
+		if n == node {
+			return true
+		}
+	}
+	slaves := make([]*Node, 0, len(p.slaves)+1)
+	slaves = append(slaves, p.slaves...)
+	p.slaves = append(slaves, node)
+	return true
+}
+
+// Events 获取最近的从库移出与重新加入事件
+func (p *DBProxy) Events() []PoolEvent {
+	return p.pool.Events()
+}
+
+// AddEventListener 添加节点事件监听者（从库移出、重新加入轮询时通知）
+func (p *DBProxy) AddEventListener(listener PoolEventListener) {
+	p.pool.AddEventListener(listener)
+}
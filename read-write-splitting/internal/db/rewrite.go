@@ -0,0 +1,243 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/callbacks"
+)
+
+// ErrRewriteHookNotFound 改写钩子不存在
+var ErrRewriteHookNotFound = errors.New("rewrite hook not found")
+
+// RewriteInfo 改写钩子的输入
+type RewriteInfo struct {
+	SQL     string          // 当前SQL（已经过排在前面的钩子改写）
+	Read    bool            // 是否为读操作
+	Node    string          // 执行节点
+	Replica bool            // 是否在从库上执行
+	Context context.Context // 请求上下文（可能为nil）
+}
+
+// RewriteFunc 改写函数，返回改写后的SQL，不需要改写时原样返回
+type RewriteFunc func(info RewriteInfo) string
+
+// RewriteHookInfo 改写钩子的状态
+type RewriteHookInfo struct {
+	Name    string `json:"name"`    // 钩子名称
+	Order   int    `json:"order"`   // 执行顺序，越小越先执行
+	Enabled bool   `json:"enabled"` // 是否启用
+	Applied int64  `json:"applied"` // 实际改变了SQL的次数
+}
+
+// rewriteHook 一个已注册的改写钩子
+type rewriteHook struct {
+	RewriteHookInfo
+	fn  RewriteFunc
+	seq int // 注册顺序，同一Order下按注册顺序执行
+}
+
+// RewriteChain SQL改写钩子链
+type RewriteChain struct {
+	mu    sync.RWMutex
+	hooks []*rewriteHook
+	seq   int
+}
+
+// NewRewriteChain 创建空的改写钩子链
+func NewRewriteChain() *RewriteChain {
+	return &RewriteChain{}
+}
+
+// Add 注册改写钩子（默认启用），同名钩子返回错误
+func (c *RewriteChain) Add(name string, order int, fn RewriteFunc) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, h := range c.hooks {
+		if h.Name == name {
+			return fmt.Errorf("rewrite hook %s already registered", name)
+		}
+	}
+	c.seq++
+	c.hooks = append(c.hooks, &rewriteHook{
+		RewriteHookInfo: RewriteHookInfo{Name: name, Order: order, Enabled: true},
+		fn:              fn,
+		seq:             c.seq,
+	})
+	sort.SliceStable(c.hooks, func(i, j int) bool {
+		if c.hooks[i].Order != c.hooks[j].Order {
+			return c.hooks[i].Order < c.hooks[j].Order
+		}
+		return c.hooks[i].seq < c.hooks[j].seq
+	})
+	return nil
+}
+
+// Remove 移除改写钩子
+func (c *RewriteChain) Remove(name string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for i, h := range c.hooks {
+		if h.Name == name {
+			c.hooks = append(c.hooks[:i:i], c.hooks[i+1:]...)
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: %s", ErrRewriteHookNotFound, name)
+}
+
+// SetEnabled 启用或禁用改写钩子
+func (c *RewriteChain) SetEnabled(name string, enabled bool) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, h := range c.hooks {
+		if h.Name == name {
+			h.Enabled = enabled
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: %s", ErrRewriteHookNotFound, name)
+}
+
+// Hooks 按执行顺序返回所有钩子的状态
+func (c *RewriteChain) Hooks() []RewriteHookInfo {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	infos := make([]RewriteHookInfo, 0, len(c.hooks))
+	for _, h := range c.hooks {
+		infos = append(infos, h.RewriteHookInfo)
+	}
+	return infos
+}
+
+// empty 是否没有注册任何钩子
+func (c *RewriteChain) empty() bool {
+	if c == nil {
+		return true
+	}
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return len(c.hooks) == 0
+}
+
+// Rewrite 依次执行所有启用的钩子，返回最终SQL
+func (c *RewriteChain) Rewrite(info RewriteInfo) string {
+	if c == nil {
+		return info.SQL
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, h := range c.hooks {
+		if !h.Enabled {
+			continue
+		}
+		if rewritten := h.fn(info); rewritten != info.SQL {
+			info.SQL = rewritten
+			h.Applied++
+		}
+	}
+	return info.SQL
+}
+
+// AddRewriteHook 在路由器上注册SQL改写钩子
+// 钩子在语句发往节点前执行，作用于查询（Find/First/Raw/Rows等，包括预加载子查询）和Exec执行的原始SQL，
+// 不作用于GORM生成的INSERT/UPDATE/DELETE；读写判断基于改写前的SQL，钩子不会改变路由结果
+func (r *SQLRouter) AddRewriteHook(name string, order int, fn RewriteFunc) error {
+	return r.dbPool.rewrites.Add(name, order, fn)
+}
+
+// RemoveRewriteHook 移除SQL改写钩子
+func (r *SQLRouter) RemoveRewriteHook(name string) error {
+	return r.dbPool.rewrites.Remove(name)
+}
+
+// SetRewriteHookEnabled 启用或禁用SQL改写钩子
+func (r *SQLRouter) SetRewriteHookEnabled(name string, enabled bool) error {
+	return r.dbPool.rewrites.SetEnabled(name, enabled)
+}
+
+// RewriteHooks 获取所有SQL改写钩子的状态
+func (r *SQLRouter) RewriteHooks() []RewriteHookInfo {
+	return r.dbPool.rewrites.Hooks()
+}
+
+// attachRewrite 在节点上注册SQL改写回调
+func (p *DBPool) attachRewrite(node *Node) error {
+	rewrite := func(read bool) func(db *gorm.DB) {
+		return func(db *gorm.DB) {
+			if db.Error != nil || p.rewrites.empty() {
+				return
+			}
+			// 查询语句在gorm:query/gorm:row中才会生成，这里提前生成（生成后GORM不会重复生成）
+			if read {
+				callbacks.BuildQuerySQL(db)
+			}
+			if db.Statement.SQL.Len() == 0 {
+				return
+			}
+
+			original := db.Statement.SQL.String()
+			rewritten := p.rewrites.Rewrite(RewriteInfo{
+				SQL:     original,
+				Read:    read || IsReadOperation(original),
+				Node:    node.Name,
+				Replica: node != p.masterNode(),
+				Context: db.Statement.Context,
+			})
+			if rewritten != original {
+				db.Statement.SQL.Reset()
+				db.Statement.SQL.WriteString(rewritten)
+			}
+		}
+	}
+
+	cb := node.DB.Callback()
+	return errors.Join(
+		cb.Query().Before("gorm:query").Register("rws:rewrite", rewrite(true)),
+		cb.Row().Before("gorm:row").Register("rws:rewrite", rewrite(true)),
+		cb.Raw().Before("gorm:raw").Register("rws:rewrite", rewrite(false)),
+	)
+}
+
+// selectPrefixRegex 匹配SELECT关键字，用于在其后插入优化器提示
+var selectPrefixRegex = regexp.MustCompile(`(?i)^\s*SELECT\b`)
+
+// MaxExecutionTimeHook 为从库读操作添加 MAX_EXECUTION_TIME 优化器提示，超时后MySQL主动中止查询
+func MaxExecutionTimeHook(limit time.Duration) RewriteFunc {
+	hint := fmt.Sprintf("/*+ MAX_EXECUTION_TIME(%d) */", limit.Milliseconds())
+	return func(info RewriteInfo) string {
+		if !info.Replica || !info.Read || strings.Contains(strings.ToUpper(info.SQL), "MAX_EXECUTION_TIME") {
+			return info.SQL
+		}
+		loc := selectPrefixRegex.FindStringIndex(info.SQL)
+		if loc == nil {
+			return info.SQL
+		}
+		return info.SQL[:loc[1]] + " " + hint + info.SQL[loc[1]:]
+	}
+}
+
+// RoutingCommentHook 在SQL末尾追加路由注释（执行节点和读写类型），便于在慢日志和processlist中追踪
+func RoutingCommentHook() RewriteFunc {
+	return func(info RewriteInfo) string {
+		kind := "write"
+		if info.Read {
+			kind = "read"
+		}
+		sql := strings.TrimRight(info.SQL, "; \t\n")
+		return fmt.Sprintf("%s /* rws node=%s op=%s */", sql, strings.ReplaceAll(info.Node, "*/", ""), kind)
+	}
+}
@@ -9,29 +9,41 @@ import (
 
 // SQLRouter SQL路由器，负责判断SQL类型并路由到合适的数据库
 type SQLRouter struct {
-	dbPool *DBPool // 数据库连接池
+	dbPool *DBPool             // 数据库连接池
+	prefs  *readPreferences    // 读偏好设置
+	procs  *readOnlyProcedures // 声明为只读的存储过程
 }
 
-// NewSQLRouter 创建新的SQL路由器
+// NewSQLRouter 创建新的SQL路由器，默认读偏好和按服务的读偏好取自连接池的配置
 func NewSQLRouter(pool *DBPool) *SQLRouter {
 	return &SQLRouter{
 		dbPool: pool,
+		prefs:  newReadPreferences(pool.config),
+		procs:  newReadOnlyProcedures(pool.config.ReadOnlyProcedures),
 	}
 }
 
 // 用于判断是否为SELECT语句的正则表达式
 var selectRegex = regexp.MustCompile(`(?i)^\s*SELECT`)
 
-// IsReadOperation 判断SQL是否为读操作
+// IsReadOperation 判断SQL是否为读操作（单条SELECT语句）
+// 以SELECT开头的多语句批量（如 SELECT ...; UPDATE ...）可能包含写操作，不视为读操作；
+// 加锁读（FOR UPDATE、FOR SHARE、LOCK IN SHARE MODE）和写文件的 INTO OUTFILE/DUMPFILE 会被从库的写拦截拒绝（见 isReadOnlyStatement），
+// 同样路由到主库
 func IsReadOperation(sql string) bool {
 	trimSQL := strings.TrimSpace(sql)
-	return selectRegex.MatchString(trimSQL)
+	return selectRegex.MatchString(trimSQL) && isReadOnlyStatement(trimSQL) && !isMultiStatement(trimSQL)
 }
 
 // Route 根据SQL类型路由到合适的数据库连接
 func (r *SQLRouter) Route(sql string) *gorm.DB {
-	if IsReadOperation(sql) {
-		return r.dbPool.Slave()
+	return r.RouteQuery(QueryInfo{SQL: sql})
+}
+
+// RouteQuery 根据查询信息路由到合适的数据库连接，读操作（见 IsRead）按读偏好选择节点
+func (r *SQLRouter) RouteQuery(q QueryInfo) *gorm.DB {
+	if r.IsRead(q.SQL) {
+		return r.ReadDBFor(q)
 	}
 	return r.dbPool.Master()
 }
@@ -43,7 +55,17 @@ func (r *SQLRouter) ForceMaster() *gorm.DB {
 
 // ReadDB 获取用于读操作的数据库连接
 func (r *SQLRouter) ReadDB() *gorm.DB {
-	return r.dbPool.Slave()
+	return r.ReadDBFor(QueryInfo{})
+}
+
+// ReadDBFor 根据查询信息获取用于读操作的数据库连接（按读偏好选择节点）
+// 读偏好为 secondary 且没有可用从库时，返回的连接带有 ErrNoReplicaAvailable 错误
+func (r *SQLRouter) ReadDBFor(q QueryInfo) *gorm.DB {
+	node, err := r.readNode(q)
+	if err != nil {
+		return failed(r.dbPool.Master(), err)
+	}
+	return node.DB
 }
 
 // WriteDB 获取用于写操作的数据库连接
@@ -0,0 +1,43 @@
+package db
+
+import "testing"
+
+// 从库的写拦截会拒绝的SELECT语句
+var lockingReads = []string{
+	"SELECT * FROM test_orders WHERE id = 1 FOR UPDATE",
+	"SELECT * FROM test_orders WHERE id = 1 FOR UPDATE NOWAIT",
+	"SELECT * FROM test_orders WHERE id = 1 FOR SHARE",
+	"SELECT * FROM test_orders WHERE id = 1 for share skip locked",
+	"SELECT * FROM test_orders WHERE id = 1 LOCK IN SHARE MODE",
+	"SELECT * FROM test_orders INTO OUTFILE '/tmp/orders.csv'",
+	"SELECT name FROM test_orders LIMIT 1 INTO DUMPFILE '/tmp/order.bin'",
+}
+
+func TestLockingReadsAreNotReadOperations(t *testing.T) {
+	for _, sql := range lockingReads {
+		if IsReadOperation(sql) {
+			t.Errorf("IsReadOperation(%q) = true, want false", sql)
+		}
+	}
+	if !IsReadOperation("SELECT * FROM test_orders WHERE id = 1") {
+		t.Errorf("plain SELECT is not a read operation")
+	}
+}
+
+func TestLockingReadsRouteToMaster(t *testing.T) {
+	proxy, recorder := newTestProxy(t, 2)
+	pinned := proxy.Pin()
+
+	for _, sql := range lockingReads {
+		for name, p := range map[string]*DBProxy{"proxy": proxy, "pinned": pinned} {
+			var orders []testOrder
+			if err := p.Raw(sql).Scan(&orders).Error; err != nil {
+				t.Fatalf("%s %q: %v", name, sql, err)
+			}
+			stmts := recorder.take()
+			if len(stmts) != 1 || stmts[0].Node != "master" {
+				t.Errorf("%s %q ran on %v, want master", name, sql, stmts)
+			}
+		}
+	}
+}
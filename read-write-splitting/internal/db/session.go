@@ -0,0 +1,304 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// ErrInvalidSessionVar 会话变量名称或取值不合法
+var ErrInvalidSessionVar = errors.New("invalid session variable")
+
+// sessionConnKey 在GORM语句实例中记录已设置会话变量的专用连接的键
+const sessionConnKey = "rws:session_conn"
+
+var (
+	sessionVarNameRegex  = regexp.MustCompile(`^[a-z_][a-z0-9_]*$`)
+	sessionVarValueRegex = regexp.MustCompile(`^('([^'\\]|\\.|'')*'|-?[0-9]+(\.[0-9]+)?|[A-Za-z_][A-Za-z0-9_]*)$`)
+	setAssignmentRegex   = regexp.MustCompile(`^(?i:(?:SESSION\s+|@@SESSION\.|@@LOCAL\.|LOCAL\s+|@@)?)([A-Za-z_][A-Za-z0-9_]*)\s*=\s*(.+)$`)
+)
+
+type sessionContextKey struct{}
+
+// SessionVar 会话级系统变量
+type SessionVar struct {
+	Name    string // 变量名（小写），如 time_zone、sql_mode
+	Literal string // SQL字面量形式的取值，如 '+00:00'
+}
+
+// SessionVars 按设置顺序排列的会话变量
+type SessionVars []SessionVar
+
+// With 返回设置（或覆盖）了一个变量的新列表
+func (v SessionVars) With(name, literal string) SessionVars {
+	out := make(SessionVars, 0, len(v)+1)
+	for _, sv := range v {
+		if sv.Name != name {
+			out = append(out, sv)
+		}
+	}
+	return append(out, SessionVar{Name: name, Literal: literal})
+}
+
+// setSQL 生成保存原值并设置新值的语句
+func (v SessionVars) setSQL() string {
+	parts := make([]string, 0, 2*len(v))
+	for _, sv := range v {
+		parts = append(parts,
+			fmt.Sprintf("@rws_saved_%s = @@SESSION.%s", sv.Name, sv.Name),
+			fmt.Sprintf("SESSION %s = %s", sv.Name, sv.Literal))
+	}
+	return "SET " + strings.Join(parts, ", ")
+}
+
+// resetSQL 生成恢复原值的语句
+func (v SessionVars) resetSQL() string {
+	parts := make([]string, 0, 2*len(v))
+	for _, sv := range v {
+		parts = append(parts,
+			fmt.Sprintf("SESSION %s = @rws_saved_%s", sv.Name, sv.Name),
+			fmt.Sprintf("@rws_saved_%s = NULL", sv.Name))
+	}
+	return "SET " + strings.Join(parts, ", ")
+}
+
+// WithSessionVars 将会话变量附加到上下文
+func WithSessionVars(ctx context.Context, vars SessionVars) context.Context {
+	return context.WithValue(ctx, sessionContextKey{}, vars)
+}
+
+// SessionVarsFromContext 从上下文中获取会话变量
+func SessionVarsFromContext(ctx context.Context) SessionVars {
+	if ctx == nil {
+		return nil
+	}
+	vars, _ := ctx.Value(sessionContextKey{}).(SessionVars)
+	return vars
+}
+
+// quoteLiteral 将字符串转换为SQL字符串字面量
+func quoteLiteral(value string) string {
+	value = strings.ReplaceAll(value, `\`, `\\`)
+	value = strings.ReplaceAll(value, `'`, `''`)
+	return "'" + value + "'"
+}
+
+// validateSessionVar 校验变量名和字面量，返回规范化的变量名
+func validateSessionVar(name, literal string) (string, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if !sessionVarNameRegex.MatchString(name) {
+		return "", fmt.Errorf("%w: bad name %q", ErrInvalidSessionVar, name)
+	}
+	if !sessionVarValueRegex.MatchString(literal) {
+		return "", fmt.Errorf("%w: bad value %s for %s", ErrInvalidSessionVar, literal, name)
+	}
+	return name, nil
+}
+
+// ParseSetStatement 解析 SET [SESSION] a = x, b = y 形式的语句
+// 只支持会话级系统变量，GLOBAL/PERSIST 变量和用户变量（@var）会返回错误
+func ParseSetStatement(stmt string) (SessionVars, error) {
+	stmt = strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(stmt), ";"))
+	if len(stmt) < 4 || !strings.EqualFold(stmt[:4], "SET ") {
+		return nil, fmt.Errorf("%w: not a SET statement", ErrInvalidSessionVar)
+	}
+
+	var vars SessionVars
+	for _, assignment := range splitAssignments(stmt[4:]) {
+		assignment = strings.TrimSpace(assignment)
+		upper := strings.ToUpper(assignment)
+		if strings.HasPrefix(upper, "GLOBAL") || strings.HasPrefix(upper, "PERSIST") || strings.HasPrefix(upper, "@@GLOBAL") {
+			return nil, fmt.Errorf("%w: only session variables can be set through the proxy: %s", ErrInvalidSessionVar, assignment)
+		}
+		m := setAssignmentRegex.FindStringSubmatch(assignment)
+		if m == nil {
+			return nil, fmt.Errorf("%w: cannot parse %q", ErrInvalidSessionVar, assignment)
+		}
+		literal := strings.TrimSpace(m[2])
+		name, err := validateSessionVar(m[1], literal)
+		if err != nil {
+			return nil, err
+		}
+		vars = vars.With(name, literal)
+	}
+	return vars, nil
+}
+
+// splitAssignments 按逗号拆分赋值列表，忽略引号内的逗号
+func splitAssignments(s string) []string {
+	var parts []string
+	start, inQuote := 0, false
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			if inQuote {
+				i++
+			}
+		case '\'':
+			inQuote = !inQuote
+		case ',':
+			if !inQuote {
+				parts = append(parts, s[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return append(parts, s[start:])
+}
+
+// SetSessionVar 返回设置了会话变量的代理，value按字符串字面量处理
+// 之后经该代理执行的每条语句，无论路由到主库还是从库，都会在实际使用的连接上重放这些变量，
+// 语句结束后恢复连接原来的取值再归还连接池
+func (p *DBProxy) SetSessionVar(name, value string) (*DBProxy, error) {
+	literal := quoteLiteral(value)
+	name, err := validateSessionVar(name, literal)
+	if err != nil {
+		return nil, err
+	}
+	return p.withSessionVars(p.SessionVars().With(name, literal)), nil
+}
+
+// SetSession 解析 SET 语句并返回设置了相应会话变量的代理，例如：
+//
+//	proxy, err = proxy.SetSession("SET time_zone = '+00:00', sql_mode = 'STRICT_TRANS_TABLES'")
+func (p *DBProxy) SetSession(stmt string) (*DBProxy, error) {
+	parsed, err := ParseSetStatement(stmt)
+	if err != nil {
+		return nil, err
+	}
+	vars := p.SessionVars()
+	for _, sv := range parsed {
+		vars = vars.With(sv.Name, sv.Literal)
+	}
+	return p.withSessionVars(vars), nil
+}
+
+// SessionVars 获取代理当前的会话变量
+func (p *DBProxy) SessionVars() SessionVars {
+	return SessionVarsFromContext(p.ctx)
+}
+
+// withSessionVars 返回上下文中携带给定会话变量的代理副本
+func (p *DBProxy) withSessionVars(vars SessionVars) *DBProxy {
+	ctx := p.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return p.WithContext(WithSessionVars(ctx, vars))
+}
+
+// applySessionVars 在事务连接上设置会话变量，返回的函数用于在事务结束前恢复原值
+func applySessionVars(tx *gorm.DB, vars SessionVars) (func() error, error) {
+	if len(vars) == 0 {
+		return func() error { return nil }, nil
+	}
+	if err := tx.Exec(vars.setSQL()).Error; err != nil {
+		return nil, fmt.Errorf("failed to set session variables: %w", err)
+	}
+	return func() error { return tx.Exec(vars.resetSQL()).Error }, nil
+}
+
+// withSessionTx 在事务开始时设置会话变量、结束前恢复
+func withSessionTx(vars SessionVars, fc func(tx *gorm.DB) error) func(tx *gorm.DB) error {
+	if len(vars) == 0 {
+		return fc
+	}
+	return func(tx *gorm.DB) error {
+		reset, err := applySessionVars(tx, vars)
+		if err != nil {
+			return err
+		}
+		// 回滚不会撤销SET，出错时也要恢复，避免连接带着变量回到连接池
+		if err := fc(tx); err != nil {
+			if resetErr := reset(); resetErr != nil {
+				log.Printf("failed to reset session variables after transaction error: %v", resetErr)
+			}
+			return err
+		}
+		return reset()
+	}
+}
+
+// attachSessionVars 在节点上注册会话变量重放回调
+// 语句开始前从连接池取出一个专用连接并设置变量，语句（包括预加载子查询和默认事务）都在该连接上执行
+func attachSessionVars(node *Node) error {
+	acquire := func(db *gorm.DB) {
+		vars := SessionVarsFromContext(db.Statement.Context)
+		if len(vars) == 0 || db.Error != nil {
+			return
+		}
+		// 已在事务或专用连接上执行时由外层负责（见Transaction），避免重复设置
+		sqlDB, ok := poolDB(db.Statement.ConnPool)
+		if !ok {
+			return
+		}
+		ctx := db.Statement.Context
+		conn, err := sqlDB.Conn(ctx)
+		if err != nil {
+			db.AddError(fmt.Errorf("failed to acquire connection for session variables: %w", err))
+			return
+		}
+		if _, err := conn.ExecContext(ctx, vars.setSQL()); err != nil {
+			conn.Close()
+			db.AddError(fmt.Errorf("failed to set session variables on node %s: %w", node.Name, err))
+			return
+		}
+		db.Statement.ConnPool = conn
+		db.InstanceSet(sessionConnKey, conn)
+	}
+
+	release := func(db *gorm.DB) {
+		v, ok := db.InstanceGet(sessionConnKey)
+		if !ok {
+			return
+		}
+		conn := v.(*sql.Conn)
+		db.Statement.ConnPool = db.ConnPool
+		vars := SessionVarsFromContext(db.Statement.Context)
+		if _, err := conn.ExecContext(context.Background(), vars.resetSQL()); err != nil {
+			log.Printf("failed to reset session variables on node %s, discarding connection: %v", node.Name, err)
+			discardConn(conn)
+			return
+		}
+		conn.Close()
+	}
+
+	// Row/Rows 返回时结果集仍占用连接，无法立即恢复变量：结果集关闭后直接丢弃该连接
+	releaseRows := func(db *gorm.DB) {
+		v, ok := db.InstanceGet(sessionConnKey)
+		if !ok {
+			return
+		}
+		db.Statement.ConnPool = db.ConnPool
+		go discardConn(v.(*sql.Conn))
+	}
+
+	cb := node.DB.Callback()
+	return errors.Join(
+		cb.Create().Before("*").Register("rws:session_acquire", acquire),
+		cb.Create().After("*").Register("rws:session_release", release),
+		cb.Query().Before("*").Register("rws:session_acquire", acquire),
+		cb.Query().After("*").Register("rws:session_release", release),
+		cb.Update().Before("*").Register("rws:session_acquire", acquire),
+		cb.Update().After("*").Register("rws:session_release", release),
+		cb.Delete().Before("*").Register("rws:session_acquire", acquire),
+		cb.Delete().After("*").Register("rws:session_release", release),
+		cb.Raw().Before("*").Register("rws:session_acquire", acquire),
+		cb.Raw().After("*").Register("rws:session_release", release),
+		cb.Row().Before("*").Register("rws:session_acquire", acquire),
+		cb.Row().After("*").Register("rws:session_release", releaseRows),
+	)
+}
+
+// discardConn 关闭专用连接并让连接池丢弃底层连接（阻塞到结果集关闭为止）
+func discardConn(conn *sql.Conn) {
+	conn.Raw(func(any) error { return driver.ErrBadConn })
+	conn.Close()
+}
@@ -0,0 +1,184 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"read-write-splitting/internal/config"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 启动连通性探测的默认参数
+const (
+	defaultConnectAttempts = 3
+	defaultInitialBackoff  = 500 * time.Millisecond
+	defaultMaxBackoff      = 5 * time.Second
+	pingTimeout            = 3 * time.Second
+)
+
+// NodeStartup 单个节点的启动探测结果
+type NodeStartup struct {
+	Name        string     `json:"name"`                   // 节点名称
+	Role        string     `json:"role"`                   // master 或 slave
+	Addr        string     `json:"addr"`                   // 地址
+	Connected   bool       `json:"connected"`              // 启动时是否连通
+	Attempts    int        `json:"attempts"`               // 尝试次数
+	Elapsed     string     `json:"elapsed"`                // 探测耗时
+	Error       string     `json:"error,omitempty"`        // 最后一次错误
+	RecoveredAt *time.Time `json:"recovered_at,omitempty"` // 后台重试连通的时间
+}
+
+// StartupReport 连接池启动报告
+type StartupReport struct {
+	StartedAt time.Time     `json:"started_at"` // 启动时间
+	Degraded  bool          `json:"degraded"`   // 是否有节点未能在启动时连通
+	Nodes     []NodeStartup `json:"nodes"`      // 各节点探测结果
+}
+
+// add 添加节点探测结果
+func (r *StartupReport) add(n NodeStartup) {
+	r.Nodes = append(r.Nodes, n)
+}
+
+// log 输出启动报告
+func (r *StartupReport) log() {
+	log.Println("DB pool startup report:")
+	for _, n := range r.Nodes {
+		status := "UP"
+		if !n.Connected {
+			status = "DOWN"
+		}
+		line := fmt.Sprintf("  %-6s %-12s %-24s %-4s attempts=%d elapsed=%s", n.Role, n.Name, n.Addr, status, n.Attempts, n.Elapsed)
+		if n.Error != "" {
+			line += " error=" + n.Error
+		}
+		log.Println(line)
+	}
+	if r.Degraded {
+		log.Println("  pool started in DEGRADED mode")
+	}
+}
+
+// withRetryDefaults 填充重试配置的默认值
+func withRetryDefaults(c config.RetryConfig) config.RetryConfig {
+	if c.Attempts <= 0 {
+		c.Attempts = defaultConnectAttempts
+	}
+	if c.InitialBackoff <= 0 {
+		c.InitialBackoff = defaultInitialBackoff
+	}
+	if c.MaxBackoff <= 0 {
+		c.MaxBackoff = defaultMaxBackoff
+	}
+	return c
+}
+
+// nextBackoff 计算下一次重试的等待时间（指数退避）
+func nextBackoff(current time.Duration, max time.Duration) time.Duration {
+	next := current * 2
+	if next > max {
+		return max
+	}
+	return next
+}
+
+// nodeAddr 节点地址
+func nodeAddr(info config.DBInfo) string {
+	return fmt.Sprintf("%s:%d/%s", info.Host, info.Port, info.DBName)
+}
+
+// pingDB 在超时时间内探测连接
+func pingDB(db *gorm.DB) error {
+	sqlDB, err := db.DB()
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), pingTimeout)
+	defer cancel()
+	return sqlDB.PingContext(ctx)
+}
+
+// closeDB 关闭连接
+func closeDB(db *gorm.DB) {
+	if sqlDB, err := db.DB(); err == nil {
+		sqlDB.Close()
+	}
+}
+
+// probeNode 带重试地探测节点连通性
+func probeNode(node *Node, role string, info config.DBInfo, retry config.RetryConfig) NodeStartup {
+	result := NodeStartup{Name: node.Name, Role: role, Addr: nodeAddr(info)}
+	start := time.Now()
+	backoff := retry.InitialBackoff
+
+	for attempt := 1; attempt <= retry.Attempts; attempt++ {
+		result.Attempts = attempt
+		err := pingDB(node.DB)
+		if err == nil {
+			result.Connected = true
+			result.Error = ""
+			break
+		}
+		result.Error = err.Error()
+		log.Printf("Ping %s %s failed (attempt %d/%d): %v", role, node.Name, attempt, retry.Attempts, err)
+
+		if attempt < retry.Attempts {
+			time.Sleep(backoff)
+			backoff = nextBackoff(backoff, retry.MaxBackoff)
+		}
+	}
+
+	result.Elapsed = time.Since(start).Round(time.Millisecond).String()
+	return result
+}
+
+// retrySlave 在后台以带抖动的指数退避持续重试启动时不可用的从库，连通后加入轮询
+func (p *DBPool) retrySlave(node *Node, info config.DBInfo, retry config.RetryConfig) {
+	backoff := retry.InitialBackoff
+	for attempt := 1; ; attempt++ {
+		select {
+		case <-p.stop:
+			node.close()
+			return
+		case <-time.After(jitterBackoff(backoff)):
+		}
+		if node.Retired() {
+			node.close()
+			return
+		}
+
+		if err := pingDB(node.DB); err != nil {
+			backoff = nextBackoff(backoff, retry.MaxBackoff)
+			continue
+		}
+
+		now := time.Now()
+		if !p.rejoinSlave(node) {
+			node.close()
+			return
+		}
+		p.mu.Lock()
+		for i := range p.startup.Nodes {
+			if p.startup.Nodes[i].Name == node.Name {
+				p.startup.Nodes[i].RecoveredAt = &now
+			}
+		}
+		p.mu.Unlock()
+
+		log.Printf("Slave %s (%s) is reachable, added to rotation", node.Name, nodeAddr(info))
+		p.emit(PoolEvent{Type: EventReplicaRejoined, Node: node.Name, Time: now, Attempts: attempt})
+		return
+	}
+}
+
+// StartupReport 获取启动报告（包含后台重试的恢复时间）
+func (p *DBPool) StartupReport() StartupReport {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	report := p.startup
+	report.Nodes = append([]NodeStartup(nil), p.startup.Nodes...)
+	return report
+}
@@ -0,0 +1,234 @@
+package db
+
+import (
+	"container/list"
+	"context"
+	"database/sql"
+	"log"
+	"strings"
+	"sync"
+
+	"gorm.io/gorm"
+)
+
+// StmtCacheStats 单个节点的预处理语句缓存统计
+type StmtCacheStats struct {
+	Node          string  `json:"node"`           // 节点名称
+	Capacity      int     `json:"capacity"`       // 缓存容量
+	Size          int     `json:"size"`           // 当前缓存的语句数
+	Hits          int64   `json:"hits"`           // 命中次数
+	Misses        int64   `json:"misses"`         // 未命中次数（需要重新预处理）
+	Evictions     int64   `json:"evictions"`      // LRU淘汰次数
+	PrepareErrors int64   `json:"prepare_errors"` // 预处理失败次数（失败时回退为直接执行）
+	HitRate       float64 `json:"hit_rate"`       // 命中率（0~1）
+}
+
+// stmtEntry 缓存中的一条预处理语句
+type stmtEntry struct {
+	key  string    // 规范化后的SQL
+	stmt *sql.Stmt // 预处理语句
+}
+
+// StmtCache 节点级别的预处理语句缓存，按规范化SQL缓存只读语句的 *sql.Stmt，超出容量时按LRU淘汰
+// 实现 gorm.ConnPool，替换节点连接的连接池后对GORM透明；写语句和事务内的语句不经过缓存
+type StmtCache struct {
+	node     string                   // 节点名称
+	db       *sql.DB                  // 底层连接池
+	capacity int                      // 缓存容量
+	entries  map[string]*list.Element // 规范化SQL -> LRU链表元素
+	lru      *list.List               // 最近使用的在前
+	mu       sync.Mutex               // 保护缓存和统计字段
+
+	hits          int64
+	misses        int64
+	evictions     int64
+	prepareErrors int64
+}
+
+// newStmtCache 创建预处理语句缓存
+func newStmtCache(node string, db *sql.DB, capacity int) *StmtCache {
+	return &StmtCache{
+		node:     node,
+		db:       db,
+		capacity: capacity,
+		entries:  make(map[string]*list.Element),
+		lru:      list.New(),
+	}
+}
+
+// normalizeStmtSQL 规范化SQL作为缓存键：折叠空白并去掉末尾分号
+// 与指纹不同，这里保留字面量，字面量不同的SQL是不同的预处理语句
+func normalizeStmtSQL(query string) string {
+	return strings.TrimRight(strings.Join(strings.Fields(query), " "), ";")
+}
+
+// PrepareContext 实现gorm.ConnPool
+func (c *StmtCache) PrepareContext(ctx context.Context, query string) (*sql.Stmt, error) {
+	return c.db.PrepareContext(ctx, query)
+}
+
+// ExecContext 实现gorm.ConnPool，写语句直接执行
+func (c *StmtCache) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	return c.db.ExecContext(ctx, query, args...)
+}
+
+// QueryContext 实现gorm.ConnPool，只读语句使用缓存的预处理语句
+func (c *StmtCache) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	if stmt := c.stmt(ctx, query); stmt != nil {
+		return stmt.QueryContext(ctx, args...)
+	}
+	return c.db.QueryContext(ctx, query, args...)
+}
+
+// QueryRowContext 实现gorm.ConnPool，只读语句使用缓存的预处理语句
+func (c *StmtCache) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	if stmt := c.stmt(ctx, query); stmt != nil {
+		return stmt.QueryRowContext(ctx, args...)
+	}
+	return c.db.QueryRowContext(ctx, query, args...)
+}
+
+// BeginTx 实现gorm.TxBeginner，事务直接在底层连接池上开启
+func (c *StmtCache) BeginTx(ctx context.Context, opts *sql.TxOptions) (*sql.Tx, error) {
+	return c.db.BeginTx(ctx, opts)
+}
+
+// GetDBConn 实现gorm.GetDBConnector，使 gorm.DB.DB() 仍返回底层连接池
+func (c *StmtCache) GetDBConn() (*sql.DB, error) {
+	return c.db, nil
+}
+
+// stmt 获取SQL对应的预处理语句，非只读语句或预处理失败时返回nil（由调用方直接执行）
+func (c *StmtCache) stmt(ctx context.Context, query string) *sql.Stmt {
+	if !isReadOnlyStatement(query) {
+		return nil
+	}
+	key := normalizeStmtSQL(query)
+
+	c.mu.Lock()
+	if elem, ok := c.entries[key]; ok {
+		c.lru.MoveToFront(elem)
+		c.hits++
+		c.mu.Unlock()
+		return elem.Value.(*stmtEntry).stmt
+	}
+	c.misses++
+	c.mu.Unlock()
+
+	// 预处理需要一次往返，不持有锁；并发未命中时以先写入缓存的为准
+	stmt, err := c.db.PrepareContext(ctx, query)
+	if err != nil {
+		c.mu.Lock()
+		c.prepareErrors++
+		c.mu.Unlock()
+		return nil
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if elem, ok := c.entries[key]; ok {
+		stmt.Close()
+		c.lru.MoveToFront(elem)
+		return elem.Value.(*stmtEntry).stmt
+	}
+	c.entries[key] = c.lru.PushFront(&stmtEntry{key: key, stmt: stmt})
+	for c.lru.Len() > c.capacity {
+		c.evictOldest()
+	}
+	return stmt
+}
+
+// evictOldest 淘汰最久未使用的语句，调用方需持有锁
+// 正在使用该语句的查询不受影响，database/sql会在结果集关闭后再真正释放语句
+func (c *StmtCache) evictOldest() {
+	elem := c.lru.Back()
+	if elem == nil {
+		return
+	}
+	entry := c.lru.Remove(elem).(*stmtEntry)
+	delete(c.entries, entry.key)
+	c.evictions++
+	if err := entry.stmt.Close(); err != nil {
+		log.Printf("failed to close cached statement on node %s: %v", c.node, err)
+	}
+}
+
+// Stats 获取缓存统计
+func (c *StmtCache) Stats() StmtCacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	stats := StmtCacheStats{
+		Node:          c.node,
+		Capacity:      c.capacity,
+		Size:          c.lru.Len(),
+		Hits:          c.hits,
+		Misses:        c.misses,
+		Evictions:     c.evictions,
+		PrepareErrors: c.prepareErrors,
+	}
+	if total := c.hits + c.misses; total > 0 {
+		stats.HitRate = float64(c.hits) / float64(total)
+	}
+	return stats
+}
+
+// Purge 关闭并清空所有缓存的语句，统计计数保留
+func (c *StmtCache) Purge() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for elem := c.lru.Front(); elem != nil; elem = elem.Next() {
+		elem.Value.(*stmtEntry).stmt.Close()
+	}
+	c.entries = make(map[string]*list.Element)
+	c.lru.Init()
+}
+
+// attachStmtCache 为节点启用预处理语句缓存，capacity<=0时不启用
+func attachStmtCache(node *Node, capacity int) error {
+	if capacity <= 0 {
+		return nil
+	}
+	sqlDB, err := node.DB.DB()
+	if err != nil {
+		return err
+	}
+	cache := newStmtCache(node.Name, sqlDB, capacity)
+	node.DB.ConnPool = cache
+	node.DB.Statement.ConnPool = cache
+	node.stmts = cache
+	return nil
+}
+
+// poolDB 获取连接池对应的 *sql.DB，连接池为事务或专用连接时返回false
+func poolDB(pool gorm.ConnPool) (*sql.DB, bool) {
+	switch p := pool.(type) {
+	case *sql.DB:
+		return p, true
+	case *StmtCache:
+		return p.db, true
+	}
+	return nil, false
+}
+
+// StmtCacheStats 获取各节点的预处理语句缓存统计（主库在前），未启用缓存时返回空列表
+func (p *DBPool) StmtCacheStats() []StmtCacheStats {
+	nodes := append([]*Node{p.masterNode()}, p.slaveNodes()...)
+	stats := make([]StmtCacheStats, 0, len(nodes))
+	for _, n := range nodes {
+		if n.stmts != nil {
+			stats = append(stats, n.stmts.Stats())
+		}
+	}
+	return stats
+}
+
+// PurgeStmtCache 清空所有节点缓存的预处理语句
+func (p *DBPool) PurgeStmtCache() {
+	for _, n := range append([]*Node{p.masterNode()}, p.slaveNodes()...) {
+		if n.stmts != nil {
+			n.stmts.Purge()
+		}
+	}
+}
@@ -0,0 +1,227 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// 内置的从库选择策略名称
+const (
+	StrategyRoundRobin = "round_robin" // 轮询
+	StrategyWeighted   = "weighted"    // 按配置的静态权重随机
+	StrategyLeastConn  = "least_conn"  // 最少在途查询
+	StrategyLatency    = "latency"     // 按延迟自适应加权（默认）
+)
+
+// minWeightShare 每个从库的最小流量占比，保证慢节点仍能被采样以便恢复
+const minWeightShare = 0.05
+
+// QueryInfo 描述一次待路由的读查询，供选择策略参考
+type QueryInfo struct {
+	SQL     string          // SQL语句（通过Raw/Exec执行时可用）
+	Context context.Context // 请求上下文（可能为nil）
+	Service string          // 发起查询的服务（见 DBProxy.ForService，可能为空）
+}
+
+// SelectionStrategy 从库选择策略
+// Pick 从给定的候选从库中选出一个，slaves保证非空
+type SelectionStrategy interface {
+	Name() string
+	Pick(slaves []*Node, q QueryInfo) *Node
+}
+
+// Weigher 可选接口，策略实现后其生效权重会出现在统计信息中
+type Weigher interface {
+	Weights(slaves []*Node) []float64
+}
+
+// StrategyFactory 选择策略的构造函数
+type StrategyFactory func() SelectionStrategy
+
+var (
+	strategyMu       sync.RWMutex
+	strategyRegistry = map[string]StrategyFactory{
+		StrategyRoundRobin: func() SelectionStrategy { return &RoundRobinStrategy{} },
+		StrategyWeighted:   func() SelectionStrategy { return &WeightedStrategy{} },
+		StrategyLeastConn:  func() SelectionStrategy { return &LeastConnStrategy{} },
+		StrategyLatency:    func() SelectionStrategy { return &LatencyStrategy{} },
+	}
+)
+
+// RegisterStrategy 注册自定义选择策略，同名策略会被覆盖
+func RegisterStrategy(name string, factory StrategyFactory) {
+	strategyMu.Lock()
+	defer strategyMu.Unlock()
+	strategyRegistry[name] = factory
+}
+
+// NewStrategy 根据名称创建选择策略，名称为空时使用默认的延迟自适应策略
+func NewStrategy(name string) (SelectionStrategy, error) {
+	if name == "" {
+		name = StrategyLatency
+	}
+
+	strategyMu.RLock()
+	factory, ok := strategyRegistry[name]
+	strategyMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown selection strategy: %s", name)
+	}
+	return factory(), nil
+}
+
+// StrategyNames 获取所有已注册的策略名称
+func StrategyNames() []string {
+	strategyMu.RLock()
+	defer strategyMu.RUnlock()
+
+	names := make([]string, 0, len(strategyRegistry))
+	for name := range strategyRegistry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// RoundRobinStrategy 简单轮询策略
+type RoundRobinStrategy struct {
+	current uint32 // 当前轮询计数
+}
+
+// Name 策略名称
+func (s *RoundRobinStrategy) Name() string { return StrategyRoundRobin }
+
+// Pick 按顺序轮流选择从库
+func (s *RoundRobinStrategy) Pick(slaves []*Node, q QueryInfo) *Node {
+	current := atomic.AddUint32(&s.current, 1)
+	return slaves[current%uint32(len(slaves))]
+}
+
+// WeightedStrategy 按配置的静态权重随机选择，未配置权重的节点按1处理
+type WeightedStrategy struct{}
+
+// Name 策略名称
+func (s *WeightedStrategy) Name() string { return StrategyWeighted }
+
+// Pick 按静态权重随机选择从库
+func (s *WeightedStrategy) Pick(slaves []*Node, q QueryInfo) *Node {
+	return pickWeighted(slaves, s.Weights(slaves))
+}
+
+// Weights 获取归一化后的静态权重
+func (s *WeightedStrategy) Weights(slaves []*Node) []float64 {
+	weights := make([]float64, len(slaves))
+	var total float64
+	for i, n := range slaves {
+		w := float64(n.Weight)
+		if w <= 0 {
+			w = 1
+		}
+		weights[i] = w
+		total += w
+	}
+	for i := range weights {
+		weights[i] /= total
+	}
+	return weights
+}
+
+// LeastConnStrategy 选择在途查询最少的从库
+type LeastConnStrategy struct{}
+
+// Name 策略名称
+func (s *LeastConnStrategy) Name() string { return StrategyLeastConn }
+
+// Pick 选择在途查询数最少的从库，相同时取靠前的节点
+func (s *LeastConnStrategy) Pick(slaves []*Node, q QueryInfo) *Node {
+	best := slaves[0]
+	for _, n := range slaves[1:] {
+		if n.InFlight() < best.InFlight() {
+			best = n
+		}
+	}
+	return best
+}
+
+// LatencyStrategy 按查询延迟的移动平均自适应加权，延迟越高的从库流量越少
+type LatencyStrategy struct{}
+
+// Name 策略名称
+func (s *LatencyStrategy) Name() string { return StrategyLatency }
+
+// Pick 按延迟权重随机选择从库
+func (s *LatencyStrategy) Pick(slaves []*Node, q QueryInfo) *Node {
+	return pickWeighted(slaves, s.Weights(slaves))
+}
+
+// Weights 根据平均延迟计算各节点的选择权重（与延迟成反比，总和为1）
+func (s *LatencyStrategy) Weights(slaves []*Node) []float64 {
+	weights := make([]float64, len(slaves))
+	if len(slaves) == 0 {
+		return weights
+	}
+
+	// 先计算有样本节点的原始权重
+	var known, knownSum float64
+	for i, n := range slaves {
+		avg, samples := n.AvgLatency()
+		if samples == 0 {
+			continue
+		}
+		ms := float64(avg) / float64(time.Millisecond)
+		if ms < 0.01 {
+			ms = 0.01
+		}
+		weights[i] = 1 / ms
+		known++
+		knownSum += weights[i]
+	}
+
+	// 尚无样本的节点使用已知节点的平均权重，全部无样本时等权
+	fill := 1.0
+	if known > 0 {
+		fill = knownSum / known
+	}
+	var total float64
+	for i := range weights {
+		if weights[i] == 0 {
+			weights[i] = fill
+		}
+		total += weights[i]
+	}
+
+	// 归一化并保证最小占比
+	floor := minWeightShare
+	if floor*float64(len(slaves)) > 1 {
+		floor = 1 / float64(len(slaves))
+	}
+	var adjusted float64
+	for i := range weights {
+		weights[i] /= total
+		if weights[i] < floor {
+			weights[i] = floor
+		}
+		adjusted += weights[i]
+	}
+	for i := range weights {
+		weights[i] /= adjusted
+	}
+	return weights
+}
+
+// pickWeighted 按归一化权重随机选择节点
+func pickWeighted(slaves []*Node, weights []float64) *Node {
+	r := rand.Float64()
+	for i, w := range weights {
+		if r < w {
+			return slaves[i]
+		}
+		r -= w
+	}
+	return slaves[len(slaves)-1]
+}
@@ -0,0 +1,159 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"read-write-splitting/internal/config"
+	"sort"
+
+	"gorm.io/gorm"
+)
+
+// ErrUnknownTenant 上下文中的租户没有对应的连接池
+var ErrUnknownTenant = errors.New("unknown tenant")
+
+// UnknownTenantError 未知租户的详细错误
+type UnknownTenantError struct {
+	Tenant string // 租户标识
+}
+
+// Error 实现error接口
+func (e *UnknownTenantError) Error() string {
+	return fmt.Sprintf("unknown tenant %q", e.Tenant)
+}
+
+// Unwrap 支持errors.Is(err, ErrUnknownTenant)
+func (e *UnknownTenantError) Unwrap() error {
+	return ErrUnknownTenant
+}
+
+// tenantContextKey 上下文中租户标识的键
+type tenantContextKey struct{}
+
+// WithTenant 在上下文中标记租户，经 DBProxy.WithContext 绑定后语句路由到该租户的主从库
+func WithTenant(ctx context.Context, tenant string) context.Context {
+	return context.WithValue(ctx, tenantContextKey{}, tenant)
+}
+
+// TenantFromContext 从上下文中获取租户标识
+func TenantFromContext(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	tenant, _ := ctx.Value(tenantContextKey{}).(string)
+	return tenant
+}
+
+// tenantPool 单个租户的连接池及路由器
+type tenantPool struct {
+	pool   *DBPool    // 租户连接池
+	router *SQLRouter // 租户SQL路由器
+}
+
+// tenantRegistry 租户标识到连接池的映射，创建后只读，由代理及其所有副本共享
+type tenantRegistry struct {
+	fallback tenantPool            // 默认库（上下文中没有租户时使用）
+	tenants  map[string]tenantPool // 租户标识 -> 连接池
+}
+
+// newTenantRegistry 为每个租户创建独立的连接池，任一租户创建失败时关闭已创建的连接池
+func newTenantRegistry(cfg *config.DBConfig, fallback tenantPool) (*tenantRegistry, error) {
+	registry := &tenantRegistry{fallback: fallback, tenants: make(map[string]tenantPool, len(cfg.Tenants))}
+	for _, tenant := range cfg.Tenants {
+		if tenant.Name == "" {
+			registry.close()
+			return nil, errors.New("tenant requires a name")
+		}
+		if _, ok := registry.tenants[tenant.Name]; ok {
+			registry.close()
+			return nil, fmt.Errorf("duplicate tenant %s", tenant.Name)
+		}
+		pool, err := NewDBPool(cfg.ForTenant(tenant))
+		if err != nil {
+			registry.close()
+			return nil, fmt.Errorf("failed to create pool for tenant %s: %w", tenant.Name, err)
+		}
+		registry.tenants[tenant.Name] = tenantPool{pool: pool, router: NewSQLRouter(pool)}
+	}
+	return registry, nil
+}
+
+// lookup 根据租户标识查找连接池，tenant为空时返回默认库
+func (r *tenantRegistry) lookup(tenant string) (tenantPool, error) {
+	if tenant == "" {
+		return r.fallback, nil
+	}
+	tp, ok := r.tenants[tenant]
+	if !ok {
+		return tenantPool{}, &UnknownTenantError{Tenant: tenant}
+	}
+	return tp, nil
+}
+
+// names 获取所有租户标识（按名称排序）
+func (r *tenantRegistry) names() []string {
+	names := make([]string, 0, len(r.tenants))
+	for name := range r.tenants {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// close 关闭所有租户连接池（不包括默认库）
+func (r *tenantRegistry) close() {
+	for _, tp := range r.tenants {
+		tp.pool.Close()
+	}
+}
+
+// withTenant 返回路由到指定租户的代理副本，tenant为空时使用默认库
+// 固定的读节点属于原来的连接池，切换租户后不再保留
+func (p *DBProxy) withTenant(tenant string) (*DBProxy, error) {
+	if tenant == p.tenant && p.err == nil {
+		return p, nil
+	}
+
+	tp, err := p.tenants.lookup(tenant)
+	if err != nil {
+		return nil, err
+	}
+	cp := *p
+	cp.tenant = tenant
+	cp.pool, cp.router = tp.pool, tp.router
+	cp.pinned = nil
+	cp.err = nil
+	return &cp, nil
+}
+
+// ForTenant 返回路由到指定租户主从库的代理，tenant为空时返回默认库的代理
+func (p *DBProxy) ForTenant(tenant string) (*DBProxy, error) {
+	return p.withTenant(tenant)
+}
+
+// Tenant 获取代理当前路由到的租户，默认库返回空字符串
+func (p *DBProxy) Tenant() string {
+	return p.tenant
+}
+
+// Tenants 获取所有已配置的租户标识
+func (p *DBProxy) Tenants() []string {
+	return p.tenants.names()
+}
+
+// TenantStats 获取各租户连接池的统计信息
+func (p *DBProxy) TenantStats() map[string]ProxyStats {
+	stats := make(map[string]ProxyStats, len(p.tenants.tenants))
+	for name, tp := range p.tenants.tenants {
+		stats[name] = tp.pool.ProxyStats()
+	}
+	return stats
+}
+
+// failed 返回一个带有错误的连接，代理绑定了未知租户时用它代替真实连接返回给调用方
+func failed(db *gorm.DB, err error) *gorm.DB {
+	db = db.Session(&gorm.Session{})
+	db.AddError(err)
+	return db
+}
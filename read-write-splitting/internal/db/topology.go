@@ -0,0 +1,121 @@
+package db
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Endpoint 数据库节点地址（与ha-switcher拓扑API的格式一致）
+type Endpoint struct {
+	Host     string `json:"host"`
+	Port     int    `json:"port"`
+	Database string `json:"database"`
+}
+
+// Topology ha-switcher发布的主从拓扑
+type Topology struct {
+	Active      string    `json:"active"`       // 当前承担写入的节点角色（master/slave）
+	Writer      Endpoint  `json:"writer"`       // 当前写入节点地址
+	Master      Endpoint  `json:"master"`       // 原主库地址
+	Slave       Endpoint  `json:"slave"`        // 从库地址
+	SwitchCount int       `json:"switch_count"` // 切换次数
+	LastSwitch  time.Time `json:"last_switch"`  // 最后一次切换时间
+}
+
+// ApplyTopology 根据拓扑调整主库连接，写入节点变化时切换主库
+func (p *DBPool) ApplyTopology(t Topology) error {
+	if t.Writer.Host == "" || t.Writer.Port == 0 {
+		return fmt.Errorf("topology has no writer endpoint")
+	}
+
+	p.mu.RLock()
+	current := p.config.Master
+	p.mu.RUnlock()
+
+	if current.Host == t.Writer.Host && current.Port == t.Writer.Port && current.DBName == t.Writer.Database {
+		return nil
+	}
+
+	// 沿用当前主库的凭据和参数，只替换地址
+	next := current
+	next.Host = t.Writer.Host
+	next.Port = t.Writer.Port
+	next.DBName = t.Writer.Database
+
+	log.Printf("Topology changed (active=%s, switch_count=%d), repointing master to %s:%d/%s",
+		t.Active, t.SwitchCount, next.Host, next.Port, next.DBName)
+	return p.swapMaster(next)
+}
+
+// TopologyWatcher 定期轮询ha-switcher的拓扑API，在故障切换后自动替换主库连接
+type TopologyWatcher struct {
+	pool      *DBPool
+	statusURL string
+	interval  time.Duration
+	client    *http.Client
+	stopChan  chan struct{}
+	wg        sync.WaitGroup
+}
+
+// NewTopologyWatcher 创建拓扑订阅器，statusURL为ha-switcher的 /api/topology 地址
+func NewTopologyWatcher(pool *DBPool, statusURL string, interval time.Duration) *TopologyWatcher {
+	return &TopologyWatcher{
+		pool:      pool,
+		statusURL: statusURL,
+		interval:  interval,
+		client:    &http.Client{Timeout: 5 * time.Second},
+		stopChan:  make(chan struct{}),
+	}
+}
+
+// Start 开始后台轮询
+func (w *TopologyWatcher) Start() {
+	w.wg.Add(1)
+	go func() {
+		defer w.wg.Done()
+
+		ticker := time.NewTicker(w.interval)
+		defer ticker.Stop()
+
+		for {
+			if err := w.poll(); err != nil {
+				log.Printf("Topology poll failed: %v", err)
+			}
+
+			select {
+			case <-w.stopChan:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// Stop 停止轮询
+func (w *TopologyWatcher) Stop() {
+	close(w.stopChan)
+	w.wg.Wait()
+}
+
+// poll 拉取一次拓扑并应用
+func (w *TopologyWatcher) poll() error {
+	resp, err := w.client.Get(w.statusURL)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("topology endpoint returned status: %s", resp.Status)
+	}
+
+	var topology Topology
+	if err := json.NewDecoder(resp.Body).Decode(&topology); err != nil {
+		return fmt.Errorf("failed to decode topology: %w", err)
+	}
+	return w.pool.ApplyTopology(topology)
+}
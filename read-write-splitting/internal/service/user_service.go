@@ -7,8 +7,37 @@ import (
 
 	"read-write-splitting/internal/db"
 	"read-write-splitting/internal/model"
+
+	"gorm.io/gorm"
+)
+
+// 分页参数默认值与上限
+const (
+	DefaultPageSize = 20
+	MaxPageSize     = 100
 )
 
+// ErrUserNotFound 用户不存在
+var ErrUserNotFound = errors.New("user not found")
+
+// UserQuery 用户搜索条件，零值字段表示不限制
+type UserQuery struct {
+	Keyword  string // 按用户名或邮箱模糊匹配
+	MinAge   int    // 最小年龄
+	MaxAge   int    // 最大年龄
+	Active   *bool  // 激活状态
+	Page     int    // 页码（从1开始）
+	PageSize int    // 每页数量
+}
+
+// UserPage 分页查询结果
+type UserPage struct {
+	Users    []model.User // 当前页的用户
+	Total    int64        // 满足条件的总数
+	Page     int          // 当前页码
+	PageSize int          // 每页数量
+}
+
 // UserService 用户服务，处理用户相关业务逻辑
 type UserService struct {
 	dbProxy *db.DBProxy // 数据库代理
@@ -42,6 +71,9 @@ func (s *UserService) GetUserByID(id uint) (*model.User, error) {
 
 	// 使用从库执行读操作
 	result := s.dbProxy.First(&user, id)
+	if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+		return nil, fmt.Errorf("%w: ID %d", ErrUserNotFound, id)
+	}
 	if result.Error != nil {
 		log.Printf("Failed to find user by ID %d: %v", id, result.Error)
 		return nil, result.Error
@@ -50,6 +82,22 @@ func (s *UserService) GetUserByID(id uint) (*model.User, error) {
 	return &user, nil
 }
 
+// GetUserByIDFromMaster 从主库获取用户，用于读后写等需要最新数据的场景
+func (s *UserService) GetUserByIDFromMaster(id uint) (*model.User, error) {
+	var user model.User
+
+	result := s.dbProxy.Master().First(&user, id)
+	if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+		return nil, fmt.Errorf("%w: ID %d", ErrUserNotFound, id)
+	}
+	if result.Error != nil {
+		log.Printf("Failed to find user by ID %d on master: %v", id, result.Error)
+		return nil, result.Error
+	}
+
+	return &user, nil
+}
+
 // GetAllUsers 获取所有用户（读操作，使用从库）
 func (s *UserService) GetAllUsers() ([]model.User, error) {
 	var users []model.User
@@ -97,6 +145,93 @@ func (s *UserService) SearchUsersByAge(age int) ([]model.User, error) {
 	return users, nil
 }
 
+// ListUsers 分页获取用户（读操作，使用从库）
+func (s *UserService) ListUsers(page, pageSize int) (*UserPage, error) {
+	return s.SearchUsers(UserQuery{Page: page, PageSize: pageSize})
+}
+
+// SearchUsers 按条件分页搜索用户（读操作，使用从库）
+// 计数和分页查询在同一个从库连接上执行，结果按ID排序以保证分页稳定
+func (s *UserService) SearchUsers(q UserQuery) (*UserPage, error) {
+	return searchUsers(s.dbProxy.Slave(), q)
+}
+
+// OpenListing 打开一致性分页会话，用于翻阅较长的列表
+func (s *UserService) OpenListing(opts db.ListingOptions) (*db.ListingSession, error) {
+	return s.dbProxy.OpenListing(opts)
+}
+
+// SearchUsersInListing 在一致性分页会话中分页搜索用户
+// 同一会话的所有页都来自同一从库上的同一快照，翻页期间的并发写入不会造成漏行或重复
+func (s *UserService) SearchUsersInListing(listingID string, q UserQuery) (*UserPage, error) {
+	session, err := s.dbProxy.Listing(listingID)
+	if err != nil {
+		return nil, err
+	}
+
+	var page *UserPage
+	err = session.Do(func(tx *gorm.DB) error {
+		var err error
+		page, err = searchUsers(tx, q)
+		return err
+	})
+	return page, err
+}
+
+// CloseListing 关闭一致性分页会话
+func (s *UserService) CloseListing(listingID string) error {
+	return s.dbProxy.CloseListing(listingID)
+}
+
+// searchUsers 在给定连接上按条件分页搜索用户
+func searchUsers(conn *gorm.DB, q UserQuery) (*UserPage, error) {
+	if q.Page < 1 {
+		q.Page = 1
+	}
+	if q.PageSize <= 0 {
+		q.PageSize = DefaultPageSize
+	}
+	if q.PageSize > MaxPageSize {
+		q.PageSize = MaxPageSize
+	}
+
+	// 条件需要分别应用到计数和分页查询上，GORM的链式语句不宜在Count之后复用
+	filter := func(db *gorm.DB) *gorm.DB {
+		db = db.Model(&model.User{})
+		if q.Keyword != "" {
+			pattern := "%" + q.Keyword + "%"
+			db = db.Where("username LIKE ? OR email LIKE ?", pattern, pattern)
+		}
+		if q.MinAge > 0 {
+			db = db.Where("age >= ?", q.MinAge)
+		}
+		if q.MaxAge > 0 {
+			db = db.Where("age <= ?", q.MaxAge)
+		}
+		if q.Active != nil {
+			db = db.Where("active = ?", *q.Active)
+		}
+		return db
+	}
+
+	page := &UserPage{Page: q.Page, PageSize: q.PageSize}
+	if err := filter(conn).Count(&page.Total).Error; err != nil {
+		log.Printf("Failed to count users: %v", err)
+		return nil, err
+	}
+
+	result := filter(conn).Order("id").
+		Offset((q.Page - 1) * q.PageSize).
+		Limit(q.PageSize).
+		Find(&page.Users)
+	if result.Error != nil {
+		log.Printf("Failed to search users: %v", result.Error)
+		return nil, result.Error
+	}
+
+	return page, nil
+}
+
 // DeleteUser 删除用户（写操作，使用主库）
 func (s *UserService) DeleteUser(id uint) error {
 	// 创建一个带有ID的用户对象
@@ -111,7 +246,7 @@ func (s *UserService) DeleteUser(id uint) error {
 
 	if result.RowsAffected == 0 {
 		log.Printf("No user found with ID %d", id)
-		return fmt.Errorf("user with ID %d not found", id)
+		return fmt.Errorf("%w: ID %d", ErrUserNotFound, id)
 	}
 
 	log.Printf("Deleted user ID %d", id)
@@ -129,7 +264,7 @@ func (s *UserService) UpdateUserActive(id uint, active bool) error {
 
 	if result.RowsAffected == 0 {
 		log.Printf("No user found with ID %d", id)
-		return fmt.Errorf("user with ID %d not found", id)
+		return fmt.Errorf("%w: ID %d", ErrUserNotFound, id)
 	}
 
 	status := "activated"